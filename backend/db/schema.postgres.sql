@@ -0,0 +1,318 @@
+-- 1L0Gx: PostgreSQL Schema (database.driver: postgres)
+-- The tables of schema.sql for PostgreSQL with the pgvector extension.
+-- Every statement is IF NOT EXISTS, so `log_ingestor migrate` can re-run it.
+
+CREATE EXTENSION IF NOT EXISTS vector;
+
+-- Table for raw and structured security log entries.
+CREATE TABLE IF NOT EXISTS logs (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default', -- owning tenant; all reads are scoped by it
+    timestamp TIMESTAMPTZ NOT NULL,
+    source VARCHAR(50),         -- e.g., Firewall, Auth, IDS, System
+    severity VARCHAR(20),       -- e.g., INFO, WARNING, ALERT, CRITICAL
+    message TEXT,               -- full log line
+    ip_address VARCHAR(45),     -- IPv4 or IPv6
+    fields JSONB,               -- structured attributes (e.g. CEF/LEEF extensions)
+    labels JSONB,               -- caller-assigned tags (env, team, host, ...)
+    risk_score SMALLINT NOT NULL DEFAULT 0, -- 0-100 triage priority computed at ingest
+    embedding vector(768),      -- vector embedding of message for semantic search
+    embedding_model VARCHAR(100), -- embedding model that computed embedding, e.g. hash/768
+    cluster_id BIGINT NULL,     -- log_clusters group, assigned in the background
+    processed BOOLEAN DEFAULT FALSE, -- Flag to indicate if the log has been processed by the agent
+    deleted_at TIMESTAMPTZ NULL, -- soft-delete marker; restorable until purged by retention
+    deleted_by VARCHAR(100),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_log_time_severity ON logs (timestamp, severity);
+CREATE INDEX IF NOT EXISTS idx_log_processed ON logs (processed);
+CREATE INDEX IF NOT EXISTS idx_log_deleted ON logs (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_log_tenant_time ON logs (tenant_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_log_cluster ON logs (tenant_id, cluster_id);
+CREATE INDEX IF NOT EXISTS idx_log_tenant_risk ON logs (tenant_id, risk_score);
+CREATE INDEX IF NOT EXISTS idx_log_tenant_ip ON logs (tenant_id, ip_address);
+CREATE INDEX IF NOT EXISTS idx_log_created ON logs (created_at); -- change feed poll source
+
+-- Approximate nearest-neighbour index for semantic search (pgvector 0.5+).
+CREATE INDEX IF NOT EXISTS idx_log_embedding ON logs USING hnsw (embedding vector_cosine_ops);
+
+-- Table for storing analyzed incidents after LLM processing.
+CREATE TABLE IF NOT EXISTS incidents (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    log_ids JSONB,              -- array of related log IDs
+    summary TEXT,               -- human-readable incident summary
+    severity VARCHAR(20),       -- LOW, MEDIUM, HIGH, CRITICAL
+    recommendation TEXT,        -- recommended actions
+    status VARCHAR(20) DEFAULT 'OPEN',  -- OPEN, INVESTIGATING, MITIGATED, CLOSED
+    assignee VARCHAR(100),      -- analyst working the incident
+    alert_id BIGINT NULL,       -- alert the incident was opened from, if any
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_incident_tenant_status ON incidents (tenant_id, status);
+
+-- Analyst comments on incidents, oldest first.
+CREATE TABLE IF NOT EXISTS incident_comments (
+    id BIGSERIAL PRIMARY KEY,
+    incident_id BIGINT NOT NULL REFERENCES incidents(id),
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    author VARCHAR(100),
+    body TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_incident_comment ON incident_comments (incident_id);
+
+-- Table for tracking automated responses executed by the agent.
+CREATE TABLE IF NOT EXISTS actions (
+    id BIGSERIAL PRIMARY KEY,
+    incident_id BIGINT REFERENCES incidents(id),
+    action_type VARCHAR(50),    -- e.g., BLOCK_IP, SLACK_ALERT, CREATE_TICKET
+    details JSONB,              -- metadata about the action
+    status VARCHAR(20) DEFAULT 'PENDING', -- PENDING, SUCCESS, FAILED
+    executed_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Rolling per-IP reputation from the severity and frequency of each tenant's
+-- events. points decay with a half-life from last_checked; the 0-100 score is
+-- derived from them (see ReputationConfig).
+CREATE TABLE IF NOT EXISTS ip_reputation (
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    ip_address VARCHAR(45) NOT NULL,
+    points DOUBLE PRECISION NOT NULL DEFAULT 0,
+    events BIGINT NOT NULL DEFAULT 0,
+    offenses INT NOT NULL DEFAULT 0, -- times the score crossed the offender threshold
+    first_seen TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_checked TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP, -- when points were last merged
+    PRIMARY KEY (tenant_id, ip_address)
+);
+
+-- Legal holds protect matching logs from soft-deletion and retention purges.
+-- NULL bounds/filters match everything.
+CREATE TABLE IF NOT EXISTS legal_holds (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    name VARCHAR(100) NOT NULL,
+    reason TEXT,
+    from_time TIMESTAMPTZ NULL,
+    to_time TIMESTAMPTZ NULL,
+    source VARCHAR(50) NULL,
+    ip_address VARCHAR(45) NULL,
+    created_by VARCHAR(100),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    released_at TIMESTAMPTZ NULL
+);
+
+-- Audit trail of administrative actions (deletes, restores, holds, purges,
+-- key changes, exports, reloads), served by GET /api/audit.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    actor VARCHAR(100),
+    action VARCHAR(50),         -- e.g., logs.soft_delete, hold.create
+    details JSONB,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_audit_time ON audit_log (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_tenant_action ON audit_log (tenant_id, action);
+
+-- API keys for the ingest HTTP API. Only a SHA-256 hash of each secret is stored.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,      -- first characters of the secret, for identification
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default', -- requests with this key are scoped to it
+    role VARCHAR(16) NOT NULL DEFAULT 'viewer', -- viewer, analyst, or admin; enforced when rbac is enabled
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    allowed_cidrs TEXT,               -- comma-separated expected source ranges; empty = any
+    last_used_at TIMESTAMPTZ NULL,
+    last_used_ip VARCHAR(45),
+    unexpected_ip VARCHAR(45),        -- last address outside allowed_cidrs
+    unexpected_ip_at TIMESTAMPTZ NULL,
+    revoked_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Alerts raised by background jobs (e.g. severity trend forecasting).
+CREATE TABLE IF NOT EXISTS alerts (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    kind VARCHAR(50),           -- e.g., severity_trend
+    severity VARCHAR(20),
+    title TEXT,
+    details JSONB,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_alert_tenant_time ON alerts (tenant_id, created_at);
+
+-- Review queue for findings from the scheduled hunting pack. One row per
+-- hunt/key per hunted window, so re-running a window updates in place.
+CREATE TABLE IF NOT EXISTS hunt_findings (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    hunt_id VARCHAR(64) NOT NULL,
+    pack_version INT NOT NULL,
+    severity VARCHAR(20),
+    finding_key VARCHAR(255) NOT NULL, -- e.g., account, or src->dst pair
+    summary TEXT,
+    hits BIGINT,
+    first_seen TIMESTAMPTZ,
+    last_seen TIMESTAMPTZ,
+    window_start TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'new', -- new, confirmed, dismissed
+    note TEXT,
+    reviewed_by VARCHAR(100),
+    reviewed_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uniq_hunt_finding UNIQUE (tenant_id, hunt_id, finding_key, window_start)
+);
+CREATE INDEX IF NOT EXISTS idx_hunt_finding_status ON hunt_findings (tenant_id, status);
+
+-- Indicators of compromise pulled from threat intelligence feeds. Each pull
+-- replaces its feed's rows; every replica matches against the whole table.
+CREATE TABLE IF NOT EXISTS threat_indicators (
+    feed VARCHAR(64) NOT NULL,
+    indicator VARCHAR(64) NOT NULL, -- IP address or CIDR range
+    confidence SMALLINT NOT NULL DEFAULT 100, -- 0-100
+    description VARCHAR(255),
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (feed, indicator)
+);
+
+-- Sigma rule matches against stored logs.
+CREATE TABLE IF NOT EXISTS detections (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    rule_id VARCHAR(255) NOT NULL, -- the rule's id, or its title when it has none
+    title VARCHAR(255) NOT NULL,
+    level VARCHAR(16) NOT NULL, -- Sigma level: informational, low, medium, high, critical
+    severity VARCHAR(20) NOT NULL,
+    tags JSONB,
+    log_id BIGINT NOT NULL,
+    source VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(45),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_detections_tenant_time ON detections (tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_detections_rule ON detections (tenant_id, rule_id);
+
+-- Saved log queries. Scheduled ones run on their cron schedule; whichever
+-- replica advances next_run_at first runs it.
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    filters JSONB,               -- GET /api/logs parameters
+    query TEXT,                  -- optional natural-language query, ranked like /api/search
+    time_window VARCHAR(32) NOT NULL, -- span each run covers, e.g. "24h"
+    result_limit INT NOT NULL,
+    schedule VARCHAR(100),       -- cron expression; NULL runs on demand only
+    email JSONB,                 -- report recipients
+    webhook VARCHAR(2048),
+    created_by VARCHAR(100),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    next_run_at TIMESTAMPTZ NULL,
+    last_run_at TIMESTAMPTZ NULL,
+    CONSTRAINT uq_saved_search_name UNIQUE (tenant_id, name)
+);
+CREATE INDEX IF NOT EXISTS idx_saved_search_due ON saved_searches (next_run_at);
+
+-- Results of saved search runs, the newest keep_runs per search.
+CREATE TABLE IF NOT EXISTS report_runs (
+    id BIGSERIAL PRIMARY KEY,
+    search_id BIGINT NOT NULL,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    trigger_type VARCHAR(20) NOT NULL, -- schedule or manual
+    window_start TIMESTAMPTZ NOT NULL,
+    window_end TIMESTAMPTZ NOT NULL,
+    matches INT NOT NULL,
+    results JSONB,               -- matching logs, up to max_results
+    delivered JSONB,             -- channels the report reached: email, webhook
+    error TEXT,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_report_runs_search ON report_runs (tenant_id, search_id, id);
+
+-- Groups of logs with similar embeddings. The centroid is the running mean
+-- of the members' unit-length embeddings.
+CREATE TABLE IF NOT EXISTS log_clusters (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    representative TEXT NOT NULL, -- message of the log that started the cluster
+    source VARCHAR(50),
+    size BIGINT NOT NULL,
+    severity VARCHAR(20),         -- highest severity seen
+    first_seen TIMESTAMPTZ NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL,
+    centroid vector(768) NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_log_clusters_tenant ON log_clusters (tenant_id, last_seen);
+
+-- Checkpoints of replayable pipeline inputs, committed in the same
+-- transaction as the entries read up to them, so a restart resumes where
+-- the last stored batch ended.
+CREATE TABLE IF NOT EXISTS ingest_offsets (
+    source_key VARCHAR(255) PRIMARY KEY, -- e.g. file:<pipeline>
+    generation VARCHAR(128) NOT NULL,    -- identifies the file the position is in
+    position BIGINT NOT NULL,            -- bytes read and stored
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- Maintenance jobs, such as re-embedding logs, with their progress. A job
+-- that stopped before finishing resumes after cursor_id.
+CREATE TABLE IF NOT EXISTS maintenance_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,          -- reembed
+    status VARCHAR(20) NOT NULL,        -- running, completed, failed
+    model VARCHAR(100),                 -- reembed: the embedding model written
+    scope VARCHAR(20),                  -- reembed: stale (missing or other model) or all
+    total BIGINT NOT NULL DEFAULT 0,    -- rows to process, counted when the job started
+    processed BIGINT NOT NULL DEFAULT 0,
+    cursor_id BIGINT NOT NULL DEFAULT 0, -- last row processed
+    max_id BIGINT NOT NULL DEFAULT 0,   -- rows after this arrived once the job started
+    error TEXT,
+    created_by VARCHAR(100),
+    started_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NULL
+);
+CREATE INDEX IF NOT EXISTS idx_maintenance_jobs_kind ON maintenance_jobs (kind, id);
+
+-- Correlations: completed joins across sources (see log_ingestor/joins),
+-- each with the correlated incident event ingested for it.
+CREATE TABLE IF NOT EXISTS correlations (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    rule_id VARCHAR(100) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    join_key VARCHAR(255) NOT NULL,      -- the value the linked logs share
+    severity VARCHAR(20) NOT NULL,       -- escalated above the linked logs'
+    event_log_id BIGINT NULL,            -- the correlated incident event
+    first_seen TIMESTAMPTZ NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_correlations_tenant_time ON correlations (tenant_id, created_at);
+
+-- The link graph: each correlation's logs in the order they happened, each
+-- linked from the one before it.
+CREATE TABLE IF NOT EXISTS correlation_links (
+    id BIGSERIAL PRIMARY KEY,
+    correlation_id BIGINT NOT NULL,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    position INT NOT NULL,
+    step VARCHAR(100) NOT NULL,          -- the join step the log matched
+    log_id BIGINT NOT NULL,
+    source VARCHAR(255) NOT NULL,
+    severity VARCHAR(20) NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    linked_from BIGINT NULL              -- the previous log in the chain
+);
+CREATE INDEX IF NOT EXISTS idx_correlation_links_chain ON correlation_links (correlation_id, position);
+CREATE INDEX IF NOT EXISTS idx_correlation_links_log ON correlation_links (tenant_id, log_id);
@@ -4,31 +4,62 @@
 -- Table for raw and structured security log entries.
 CREATE TABLE IF NOT EXISTS logs (
     id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default', -- owning tenant; all reads are scoped by it
     timestamp DATETIME NOT NULL,
     source VARCHAR(50),         -- e.g., Firewall, Auth, IDS, System
     severity VARCHAR(20),       -- e.g., INFO, WARNING, ALERT, CRITICAL
     message TEXT,               -- full log line
     ip_address VARCHAR(45),     -- IPv4 or IPv6
+    fields JSON,                -- structured attributes (e.g. CEF/LEEF extensions)
+    labels JSON,                -- caller-assigned tags (env, team, host, ...)
+    risk_score TINYINT UNSIGNED NOT NULL DEFAULT 0, -- 0-100 triage priority computed at ingest
     embedding VECTOR(768),      -- vector embedding of message for semantic search
+    embedding_model VARCHAR(100), -- embedding model that computed embedding, e.g. hash/768
+    cluster_id BIGINT NULL,     -- log_clusters group, assigned in the background
     processed BOOLEAN DEFAULT FALSE, -- Flag to indicate if the log has been processed by the agent
+    deleted_at TIMESTAMP NULL,  -- soft-delete marker; restorable until purged by retention
+    deleted_by VARCHAR(100),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
 -- Create an index on the timestamp and severity for faster querying of new, severe logs.
 CREATE INDEX idx_log_time_severity ON logs (timestamp, severity);
 CREATE INDEX idx_log_processed ON logs (processed);
+CREATE INDEX idx_log_deleted ON logs (deleted_at);
+CREATE INDEX idx_log_tenant_time ON logs (tenant_id, timestamp);
+CREATE INDEX idx_log_cluster ON logs (tenant_id, cluster_id);
+CREATE INDEX idx_log_tenant_risk ON logs (tenant_id, risk_score);
+CREATE INDEX idx_log_tenant_ip ON logs (tenant_id, ip_address);
+CREATE INDEX idx_log_created ON logs (created_at); -- change feed poll source
 
 
 -- Table for storing analyzed incidents after LLM processing.
 CREATE TABLE IF NOT EXISTS incidents (
     id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
     log_ids JSON,               -- array of related log IDs
     summary TEXT,               -- human-readable incident summary
     severity VARCHAR(20),       -- LOW, MEDIUM, HIGH, CRITICAL
     recommendation TEXT,        -- recommended actions
-    status VARCHAR(20) DEFAULT 'OPEN',  -- OPEN, MITIGATED, CLOSED
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    status VARCHAR(20) DEFAULT 'OPEN',  -- OPEN, INVESTIGATING, MITIGATED, CLOSED
+    assignee VARCHAR(100),      -- analyst working the incident
+    alert_id BIGINT NULL,       -- alert the incident was opened from, if any
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_incident_tenant_status ON incidents (tenant_id, status);
+
+-- Analyst comments on incidents, oldest first.
+CREATE TABLE IF NOT EXISTS incident_comments (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    incident_id BIGINT NOT NULL,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    author VARCHAR(100),
+    body TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (incident_id) REFERENCES incidents(id)
 );
+CREATE INDEX idx_incident_comment ON incident_comments (incident_id);
 
 -- Table for tracking automated responses executed by the agent.
 CREATE TABLE IF NOT EXISTS actions (
@@ -42,12 +73,247 @@ CREATE TABLE IF NOT EXISTS actions (
     FOREIGN KEY (incident_id) REFERENCES incidents(id)
 );
 
--- Optional table for caching results from external IP reputation APIs.
+-- Rolling per-IP reputation from the severity and frequency of each tenant's
+-- events. points decay with a half-life from last_checked; the 0-100 score is
+-- derived from them (see ReputationConfig).
 CREATE TABLE IF NOT EXISTS ip_reputation (
-    ip_address VARCHAR(45) PRIMARY KEY,
-    risk_score INT,             -- 0–100
-    last_checked TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    ip_address VARCHAR(45) NOT NULL,
+    points DOUBLE NOT NULL DEFAULT 0,
+    events BIGINT NOT NULL DEFAULT 0,
+    offenses INT NOT NULL DEFAULT 0, -- times the score crossed the offender threshold
+    first_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_checked TIMESTAMP DEFAULT CURRENT_TIMESTAMP, -- when points were last merged
+    PRIMARY KEY (tenant_id, ip_address)
+);
+
+-- Legal holds protect matching logs from soft-deletion and retention purges.
+-- NULL bounds/filters match everything.
+CREATE TABLE IF NOT EXISTS legal_holds (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    name VARCHAR(100) NOT NULL,
+    reason TEXT,
+    from_time DATETIME NULL,
+    to_time DATETIME NULL,
+    source VARCHAR(50) NULL,
+    ip_address VARCHAR(45) NULL,
+    created_by VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    released_at TIMESTAMP NULL
+);
+
+-- Audit trail of administrative actions (deletes, restores, holds, purges,
+-- key changes, exports, reloads), served by GET /api/audit.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    actor VARCHAR(100),
+    action VARCHAR(50),         -- e.g., logs.soft_delete, hold.create
+    details JSON,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_audit_time ON audit_log (created_at);
+CREATE INDEX idx_audit_tenant_action ON audit_log (tenant_id, action);
+
+-- API keys for the ingest HTTP API. Only a SHA-256 hash of each secret is stored.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,      -- first characters of the secret, for identification
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default', -- requests with this key are scoped to it
+    role VARCHAR(16) NOT NULL DEFAULT 'viewer', -- viewer, analyst, or admin; enforced when rbac is enabled
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    allowed_cidrs TEXT,               -- comma-separated expected source ranges; empty = any
+    last_used_at TIMESTAMP NULL,
+    last_used_ip VARCHAR(45),
+    unexpected_ip VARCHAR(45),        -- last address outside allowed_cidrs
+    unexpected_ip_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Alerts raised by background jobs (e.g. severity trend forecasting).
+CREATE TABLE IF NOT EXISTS alerts (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    kind VARCHAR(50),           -- e.g., severity_trend
+    severity VARCHAR(20),
+    title TEXT,
+    details JSON,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_alert_tenant_time ON alerts (tenant_id, created_at);
+
+-- Review queue for findings from the scheduled hunting pack. One row per
+-- hunt/key per hunted window, so re-running a window updates in place.
+CREATE TABLE IF NOT EXISTS hunt_findings (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    hunt_id VARCHAR(64) NOT NULL,
+    pack_version INT NOT NULL,
+    severity VARCHAR(20),
+    finding_key VARCHAR(255) NOT NULL, -- e.g., account, or src->dst pair
+    summary TEXT,
+    hits BIGINT,
+    first_seen DATETIME,
+    last_seen DATETIME,
+    window_start DATETIME NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'new', -- new, confirmed, dismissed
+    note TEXT,
+    reviewed_by VARCHAR(100),
+    reviewed_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uniq_hunt_finding (tenant_id, hunt_id, finding_key, window_start)
+);
+CREATE INDEX idx_hunt_finding_status ON hunt_findings (tenant_id, status);
+
+-- Indicators of compromise pulled from threat intelligence feeds. Each pull
+-- replaces its feed's rows; every replica matches against the whole table.
+CREATE TABLE IF NOT EXISTS threat_indicators (
+    feed VARCHAR(64) NOT NULL,
+    indicator VARCHAR(64) NOT NULL, -- IP address or CIDR range
+    confidence TINYINT UNSIGNED NOT NULL DEFAULT 100, -- 0-100
+    description VARCHAR(255),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (feed, indicator)
+);
+
+-- Sigma rule matches against stored logs.
+CREATE TABLE IF NOT EXISTS detections (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    rule_id VARCHAR(255) NOT NULL, -- the rule's id, or its title when it has none
+    title VARCHAR(255) NOT NULL,
+    level VARCHAR(16) NOT NULL, -- Sigma level: informational, low, medium, high, critical
+    severity VARCHAR(20) NOT NULL,
+    tags JSON,
+    log_id BIGINT NOT NULL,
+    source VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(45),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_detections_tenant_time (tenant_id, created_at),
+    INDEX idx_detections_rule (tenant_id, rule_id)
+);
+
+-- Saved log queries. Scheduled ones run on their cron schedule; whichever
+-- replica advances next_run_at first runs it.
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    filters JSON,                -- GET /api/logs parameters
+    query TEXT,                  -- optional natural-language query, ranked like /api/search
+    time_window VARCHAR(32) NOT NULL, -- span each run covers, e.g. "24h"
+    result_limit INT NOT NULL,
+    schedule VARCHAR(100),       -- cron expression; NULL runs on demand only
+    email JSON,                  -- report recipients
+    webhook VARCHAR(2048),
+    created_by VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    next_run_at DATETIME NULL,
+    last_run_at DATETIME NULL,
+    UNIQUE KEY uq_saved_search_name (tenant_id, name)
+);
+CREATE INDEX idx_saved_search_due ON saved_searches (next_run_at);
+
+-- Results of saved search runs, the newest keep_runs per search.
+CREATE TABLE IF NOT EXISTS report_runs (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    search_id BIGINT NOT NULL,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    trigger_type VARCHAR(20) NOT NULL, -- schedule or manual
+    window_start DATETIME NOT NULL,
+    window_end DATETIME NOT NULL,
+    matches INT NOT NULL,
+    results JSON,                -- matching logs, up to max_results
+    delivered JSON,              -- channels the report reached: email, webhook
+    error TEXT,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NOT NULL,
+    INDEX idx_report_runs_search (tenant_id, search_id, id)
+);
+
+-- Groups of logs with similar embeddings. The centroid is the running mean
+-- of the members' unit-length embeddings.
+CREATE TABLE IF NOT EXISTS log_clusters (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    representative TEXT NOT NULL, -- message of the log that started the cluster
+    source VARCHAR(50),
+    size BIGINT NOT NULL,
+    severity VARCHAR(20),         -- highest severity seen
+    first_seen DATETIME NOT NULL,
+    last_seen DATETIME NOT NULL,
+    centroid VECTOR(768) NOT NULL
+);
+CREATE INDEX idx_log_clusters_tenant ON log_clusters (tenant_id, last_seen);
+
+-- Checkpoints of replayable pipeline inputs, committed in the same
+-- transaction as the entries read up to them, so a restart resumes where
+-- the last stored batch ended.
+CREATE TABLE IF NOT EXISTS ingest_offsets (
+    source_key VARCHAR(255) PRIMARY KEY, -- e.g. file:<pipeline>
+    generation VARCHAR(128) NOT NULL,    -- identifies the file the position is in
+    position BIGINT NOT NULL,            -- bytes read and stored
+    updated_at DATETIME NOT NULL
+);
+
+-- Maintenance jobs, such as re-embedding logs, with their progress. A job
+-- that stopped before finishing resumes after cursor_id.
+CREATE TABLE IF NOT EXISTS maintenance_jobs (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    kind VARCHAR(50) NOT NULL,          -- reembed
+    status VARCHAR(20) NOT NULL,        -- running, completed, failed
+    model VARCHAR(100),                 -- reembed: the embedding model written
+    scope VARCHAR(20),                  -- reembed: stale (missing or other model) or all
+    total BIGINT NOT NULL DEFAULT 0,    -- rows to process, counted when the job started
+    processed BIGINT NOT NULL DEFAULT 0,
+    cursor_id BIGINT NOT NULL DEFAULT 0, -- last row processed
+    max_id BIGINT NOT NULL DEFAULT 0,   -- rows after this arrived once the job started
+    error TEXT,
+    created_by VARCHAR(100),
+    started_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    finished_at DATETIME NULL
+);
+CREATE INDEX idx_maintenance_jobs_kind ON maintenance_jobs (kind, id);
+
+-- Correlations: completed joins across sources (see log_ingestor/joins),
+-- each with the correlated incident event ingested for it.
+CREATE TABLE IF NOT EXISTS correlations (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    rule_id VARCHAR(100) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    join_key VARCHAR(255) NOT NULL,      -- the value the linked logs share
+    severity VARCHAR(20) NOT NULL,       -- escalated above the linked logs'
+    event_log_id BIGINT NULL,            -- the correlated incident event
+    first_seen DATETIME NOT NULL,
+    last_seen DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_correlations_tenant_time ON correlations (tenant_id, created_at);
+
+-- The link graph: each correlation's logs in the order they happened, each
+-- linked from the one before it.
+CREATE TABLE IF NOT EXISTS correlation_links (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    correlation_id BIGINT NOT NULL,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    position INT NOT NULL,
+    step VARCHAR(100) NOT NULL,          -- the join step the log matched
+    log_id BIGINT NOT NULL,
+    source VARCHAR(255) NOT NULL,
+    severity VARCHAR(20) NOT NULL,
+    timestamp DATETIME NOT NULL,
+    linked_from BIGINT NULL              -- the previous log in the chain
 );
+CREATE INDEX idx_correlation_links_chain ON correlation_links (correlation_id, position);
+CREATE INDEX idx_correlation_links_log ON correlation_links (tenant_id, log_id);
 
 -- Add a vector index on the embedding column for fast semantic search.
 -- Note: The exact syntax for creating a vector index may vary based on TiDB version.
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"1logx/log_ingestor/internal/pipeline"
+	"1logx/log_ingestor/internal/store"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretConfigKeys are config keys, or _-separated key suffixes, whose
+// values GET /api/admin/config masks.
+var secretConfigKeys = []string{"password", "passwd", "secret", "secret_key", "access_key", "api_key", "token", "private_key", "credentials"}
+
+// dsnPasswordPattern finds passwords in key=value DSNs, such as Postgres's
+// "host=db password=x", and in URL query parameters.
+var dsnPasswordPattern = regexp.MustCompile(`(?i)\b(password|passwd|pwd)=('[^']*'|[^\s&;]*)`)
+
+func secretConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	return slices.ContainsFunc(secretConfigKeys, func(secret string) bool {
+		return key == secret || strings.HasSuffix(key, "_"+secret)
+	})
+}
+
+// sourcesHandler serves GET /api/admin/sources.
+func sourcesHandler(ss *pipeline.Sources) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"sources": ss.Status()})
+	}
+}
+
+// pauseSourceHandler serves POST /api/admin/sources/{name}/pause and
+// /resume.
+func pauseSourceHandler(db *store.DB, ss *pipeline.Sources, paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := ss.Get(r.PathValue("name"))
+		if s == nil {
+			writeError(w, http.StatusNotFound, "no source named "+r.PathValue("name"))
+			return
+		}
+		s.SetPaused(paused)
+		action := "source.resume"
+		if paused {
+			action = "source.pause"
+		}
+		recordAudit(db, defaultTenant, requestActor(r), action, map[string]string{"source": s.Name()})
+		writeJSON(w, http.StatusOK, s.Status())
+	}
+}
+
+// AdminConfig holds the config the server is running with, swapped on
+// reload, for GET /api/admin/config.
+type AdminConfig struct {
+	mu       sync.Mutex
+	config   Config
+	simFlags simulatorFlags
+	db       store.Store
+}
+
+func NewAdminConfig(config Config, simFlags simulatorFlags, db store.Store) *AdminConfig {
+	return &AdminConfig{config: config, simFlags: simFlags, db: db}
+}
+
+func (a *AdminConfig) reload(c Config) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.config = c
+	return nil
+}
+
+// handler serves GET /api/admin/config: the effective config, defaults and
+// flags applied, with secrets masked. Sections needing a restart show the
+// file's latest values, which may not be running yet.
+func (a *AdminConfig) handler(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	c := effectiveConfig(a.config, a.db)
+	a.mu.Unlock()
+	c.Simulator = a.simFlags.apply(c.Simulator)
+
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to encode config")
+		return
+	}
+	var view map[string]any
+	if err := yaml.Unmarshal(data, &view); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to encode config")
+		return
+	}
+	writeJSON(w, http.StatusOK, maskSecrets(view))
+}
+
+// effectiveConfig applies each section's defaults, as the server on db does
+// when it builds that section's component.
+func effectiveConfig(c Config, db store.Store) Config {
+	c.Database = c.Database.WithDefaults()
+	c.LLM = c.LLM.WithDefaults()
+	c.API = c.API.WithDefaults()
+	c.Retention = c.Retention.WithDefaults()
+	c.Auth = c.Auth.WithDefaults()
+	c.Forecast = c.Forecast.WithDefaults()
+	c.GRPC = c.GRPC.WithDefaults()
+	c.NetFlow = c.NetFlow.WithDefaults()
+	c.Pipelines = slices.Clone(c.Pipelines)
+	for i := range c.Pipelines {
+		c.Pipelines[i] = c.Pipelines[i].WithDefaults()
+	}
+	c.Hunts = c.Hunts.WithDefaults()
+	c.Reports = c.Reports.WithDefaults()
+	c.Clustering = c.Clustering.WithDefaults()
+	c.Stream = c.Stream.WithDefaults()
+	c.Fanout = c.Fanout.WithDefaults()
+	c.Cluster = c.Cluster.WithDefaults()
+	c.ChangeFeed = c.ChangeFeed.WithDefaults()
+	c.Retry = c.Retry.WithDefaults()
+	c.Limits = c.Limits.WithDefaults()
+	c.Redaction = c.Redaction.WithDefaults()
+	c.TLS = c.TLS.WithDefaults()
+	c.Schema = c.Schema.WithDefaults(db.Schema())
+	c.Embedding = c.Embedding.WithDefaults()
+	c.Search = c.Search.WithDefaults()
+	c.Quality = c.Quality.WithDefaults()
+	c.Canary = c.Canary.WithDefaults()
+	c.Recent = c.Recent.WithDefaults()
+	c.Aggregates = c.Aggregates.WithDefaults()
+	c.Joins = c.Joins.WithDefaults()
+	c.Export = c.Export.WithDefaults()
+	c.Risk = c.Risk.WithDefaults()
+	c.ThreatIntel = c.ThreatIntel.WithDefaults()
+	c.Reputation = c.Reputation.WithDefaults()
+	c.Autoscale = c.Autoscale.WithDefaults()
+	c.Reload = c.Reload.WithDefaults()
+	c.Logging = c.Logging.WithDefaults()
+	c.Compression = c.Compression.WithDefaults()
+	return c
+}
+
+// maskSecrets replaces non-empty secret values, and passwords in URLs and
+// key=value DSNs, throughout v.
+func maskSecrets(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, val := range v {
+			if s, ok := val.(string); ok && s != "" && secretConfigKey(k) {
+				v[k] = "********"
+				continue
+			}
+			v[k] = maskSecrets(val)
+		}
+	case []any:
+		for i := range v {
+			v[i] = maskSecrets(v[i])
+		}
+	case string:
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			v = u.Redacted()
+		}
+		return dsnPasswordPattern.ReplaceAllString(v, "$1=********")
+	}
+	return v
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMaskSecrets(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want any
+	}{
+		{
+			name: "secret keys and suffixes",
+			in:   map[string]any{"password": "x", "api_token": "t", "private_key": "k", "jwt_secret": "s", "tls_key": "server.key", "token": ""},
+			want: map[string]any{"password": "********", "api_token": "********", "private_key": "********", "jwt_secret": "********", "tls_key": "server.key", "token": ""},
+		},
+		{
+			name: "url dsn",
+			in:   map[string]any{"dsn": "postgres://logx:hunter2@db:5432/logx?sslmode=disable"},
+			want: map[string]any{"dsn": "postgres://logx:xxxxx@db:5432/logx?sslmode=disable"},
+		},
+		{
+			name: "key=value dsn",
+			in:   map[string]any{"dsn": "host=db user=logx password=hunter2 dbname=logx"},
+			want: map[string]any{"dsn": "host=db user=logx password=******** dbname=logx"},
+		},
+		{
+			name: "quoted password in a list",
+			in:   []any{map[string]any{"dsn": "host=db password='a b' sslmode=require"}},
+			want: []any{map[string]any{"dsn": "host=db password=******** sslmode=require"}},
+		},
+		{
+			name: "query parameter",
+			in:   map[string]any{"url": "https://sink.example/?user=a&password=b&x=1"},
+			want: map[string]any{"url": "https://sink.example/?user=a&password=********&x=1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maskSecrets(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("maskSecrets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireRolePlatform(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	handler := requireRole(RBACConfig{Enabled: true}, rolePlatform, ok)
+	tests := []struct {
+		role string
+		want int
+	}{
+		{roleAdmin, http.StatusForbidden},
+		{rolePlatform, http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api/admin/reload", nil)
+		r = r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey, &APIKey{TenantID: "acme", Role: tt.role}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: POST /api/admin/reload = %d, want %d", tt.role, w.Code, tt.want)
+		}
+	}
+}
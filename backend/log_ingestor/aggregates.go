@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// aggregateSlots is how many buckets each window is split into; counts
+// expire one slot (1/12 of the window) at a time.
+const aggregateSlots = 12
+
+// aggregateBucket holds the counts of one slot.
+type aggregateBucket struct {
+	start      time.Time
+	total      int
+	sources    map[string]int
+	severities map[string]int
+	ips        map[string]int
+}
+
+// rollingWindow counts events over the last span in aggregateSlots buckets.
+type rollingWindow struct {
+	name    string
+	span    time.Duration
+	step    time.Duration
+	buckets [aggregateSlots]*aggregateBucket
+}
+
+func (w *rollingWindow) add(e LogEntry, now time.Time) {
+	start := now.Truncate(w.step)
+	i := int(start.UnixNano()/int64(w.step)) % aggregateSlots
+	b := w.buckets[i]
+	if b == nil || !b.start.Equal(start) {
+		b = &aggregateBucket{start: start, sources: map[string]int{}, severities: map[string]int{}, ips: map[string]int{}}
+		w.buckets[i] = b
+	}
+	b.total++
+	b.sources[e.Source]++
+	b.severities[e.Severity.String()]++
+	if e.IPAddress != "" {
+		b.ips[e.IPAddress]++
+	}
+}
+
+// KeyCount is one ranked entry of an aggregate.
+type KeyCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// WindowAggregate is a window's counts at push time.
+type WindowAggregate struct {
+	Total      int            `json:"total"`
+	PerSecond  float64        `json:"per_second"`
+	Severities map[string]int `json:"severities"`
+	TopSources []KeyCount     `json:"top_sources"`
+	TopIPs     []KeyCount     `json:"top_ips"`
+}
+
+// sum totals the buckets still inside the window at now.
+func (w *rollingWindow) sum(now time.Time, top int) WindowAggregate {
+	agg := WindowAggregate{Severities: map[string]int{}}
+	sources, ips := map[string]int{}, map[string]int{}
+	cutoff := now.Truncate(w.step).Add(-w.span + w.step)
+	for _, b := range w.buckets {
+		if b == nil || b.start.Before(cutoff) {
+			continue
+		}
+		agg.Total += b.total
+		for k, n := range b.sources {
+			sources[k] += n
+		}
+		for k, n := range b.severities {
+			agg.Severities[k] += n
+		}
+		for k, n := range b.ips {
+			ips[k] += n
+		}
+	}
+	agg.PerSecond = float64(agg.Total) / w.span.Seconds()
+	agg.TopSources = topCounts(sources, top)
+	agg.TopIPs = topCounts(ips, top)
+	return agg
+}
+
+// topCounts ranks counts descending, ties by key, keeping n.
+func topCounts(counts map[string]int, n int) []KeyCount {
+	out := make([]KeyCount, 0, len(counts))
+	for k, c := range counts {
+		out = append(out, KeyCount{Key: k, Count: c})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Key < out[j].Key
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// LiveAggregates keeps each tenant's rolling windows.
+type LiveAggregates struct {
+	cfg     AggregatesConfig
+	windows []time.Duration
+	hub     *streamHub
+
+	mu      sync.Mutex
+	tenants map[string][]*rollingWindow
+}
+
+// NewLiveAggregates returns aggregates pushed to h's clients.
+func NewLiveAggregates(cfg AggregatesConfig, h *streamHub) (*LiveAggregates, error) {
+	a := &LiveAggregates{cfg: cfg, hub: h, tenants: map[string][]*rollingWindow{}}
+	for _, s := range cfg.Windows {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < aggregateSlots*time.Second {
+			return nil, fmt.Errorf("invalid aggregate window %q: must be at least %ds", s, aggregateSlots)
+		}
+		a.windows = append(a.windows, d)
+	}
+	return a, nil
+}
+
+func (a *LiveAggregates) observe(e LogEntry) {
+	if a == nil {
+		return
+	}
+	tenant := e.TenantID
+	if tenant == "" {
+		tenant = defaultTenant
+	}
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	windows := a.tenants[tenant]
+	if windows == nil {
+		for i, d := range a.windows {
+			windows = append(windows, &rollingWindow{name: a.cfg.Windows[i], span: d, step: d / aggregateSlots})
+		}
+		a.tenants[tenant] = windows
+	}
+	for _, w := range windows {
+		w.add(e, now)
+	}
+}
+
+// snapshot returns every tenant's windows. Tenants with no events left in
+// any window are reported one last time, so widgets clear, then dropped.
+func (a *LiveAggregates) snapshot() map[string]map[string]WindowAggregate {
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := map[string]map[string]WindowAggregate{}
+	for tenant, windows := range a.tenants {
+		aggs, total := map[string]WindowAggregate{}, 0
+		for _, w := range windows {
+			agg := w.sum(now, a.cfg.Top)
+			aggs[w.name] = agg
+			total += agg.Total
+		}
+		out[tenant] = aggs
+		if total == 0 {
+			delete(a.tenants, tenant)
+		}
+	}
+	return out
+}
+
+// Run pushes each tenant's aggregates to its clients every interval.
+func (a *LiveAggregates) Run() {
+	interval, _ := time.ParseDuration(a.cfg.Interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		for tenant, windows := range a.snapshot() {
+			a.hub.BroadcastMessage(tenant, wsMessage{Type: "aggregates", Data: map[string]any{"at": now, "windows": windows}})
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// Alerts stores raised alerts, broadcasts them to the tenant's WebSocket
+// clients, and opens incidents for the kinds configured to get one.
+type Alerts struct {
+	db        *store.DB
+	hub       *streamHub
+	incidents map[string]bool // alert kinds that open an incident
+}
+
+func NewAlerts(db *store.DB, h *streamHub, cfg IncidentConfig) *Alerts {
+	al := &Alerts{db: db, hub: h, incidents: map[string]bool{}}
+	for _, kind := range cfg.AutoCreate {
+		al.incidents[kind] = true
+	}
+	return al
+}
+
+// Raise stores a and broadcasts it to the tenant's WebSocket clients.
+func (al *Alerts) Raise(a Alert) {
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	details, err := json.Marshal(a.Details)
+	if err != nil {
+		details = []byte("{}")
+	}
+	a.ID, err = al.db.Insert(`
+		INSERT INTO alerts (tenant_id, kind, severity, title, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		a.TenantID, a.Kind, a.Severity, a.Title, string(details), a.CreatedAt,
+	)
+	if err != nil {
+		slog.Warn("Failed to store alert", "title", a.Title, "err", err)
+	}
+
+	slog.Warn("Alert raised", "severity", a.Severity, "title", a.Title, "tenant", a.TenantID)
+	al.hub.BroadcastMessage(a.TenantID, wsMessage{Type: "alert", Data: a})
+	if a.ID != 0 && al.incidents[a.Kind] {
+		openIncidentForAlert(al.db, al.hub, a)
+	}
+}
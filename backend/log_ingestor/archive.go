@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// Archiver writes expired logs to object storage and reads them back.
+type Archiver struct {
+	cfg   ArchiveConfig
+	store objectStore
+}
+
+func NewArchiver(cfg ArchiveConfig) (*Archiver, error) {
+	store, err := newObjectStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	return &Archiver{cfg: cfg, store: store}, nil
+}
+
+// partition is the key prefix of tenant's logs for the hour containing t.
+func (a *Archiver) partition(tenant string, t time.Time) string {
+	t = t.UTC()
+	return fmt.Sprintf("%s/tenant=%s/date=%s/hour=%02d/", a.cfg.Prefix, tenant, t.Format("2006-01-02"), t.Hour())
+}
+
+// encode serializes entries in the configured format.
+func (a *Archiver) encode(entries []LogEntry) (body []byte, ext, contentType string, err error) {
+	var buf bytes.Buffer
+	if a.cfg.Format == "parquet" {
+		pw, err := newParquetWriter(&buf, len(entries))
+		if err != nil {
+			return nil, "", "", err
+		}
+		pw.gzip = true
+		for _, e := range entries {
+			if err := pw.Write(e); err != nil {
+				return nil, "", "", err
+			}
+		}
+		if err := pw.Close(); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), ".parquet", "application/vnd.apache.parquet", nil
+	}
+
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return nil, "", "", err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), ".ndjson.gz", "application/x-ndjson", nil
+}
+
+// archiveExpired uploads, in batches, logs older than cfg.MaxAge that aren't
+// soft-deleted or under a legal hold, then deletes them. A batch is only
+// deleted once all of its objects are written; if the delete fails the next
+// run uploads the rows again, which restore tolerates since it keeps ids.
+func (a *Archiver) archiveExpired(ctx context.Context, db *store.DB, cfg RetentionConfig) (int64, error) {
+	maxAge, err := time.ParseDuration(cfg.MaxAge)
+	if err != nil || maxAge <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-maxAge)
+
+	var total int64
+	for {
+		rows, err := db.QueryContext(ctx, `
+			SELECT `+logColumns+`
+			FROM logs
+			WHERE timestamp < ? AND deleted_at IS NULL AND `+notHeldClause+`
+			ORDER BY id
+			LIMIT ?`, cutoff, cfg.BatchSize)
+		if err != nil {
+			return total, err
+		}
+		var batch []LogEntry
+		for rows.Next() {
+			e, err := scanLog(rows)
+			if err != nil {
+				rows.Close()
+				return total, err
+			}
+			batch = append(batch, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return total, err
+		}
+		if len(batch) == 0 {
+			return total, nil
+		}
+
+		partitions := map[string][]LogEntry{}
+		var order []string
+		for _, e := range batch {
+			key := a.partition(e.TenantID, e.Timestamp)
+			if _, ok := partitions[key]; !ok {
+				order = append(order, key)
+			}
+			partitions[key] = append(partitions[key], e)
+		}
+		for _, prefix := range order {
+			entries := partitions[prefix]
+			body, ext, contentType, err := a.encode(entries)
+			if err != nil {
+				return total, err
+			}
+			key := fmt.Sprintf("%s%d-%d%s", prefix, entries[0].ID, entries[len(entries)-1].ID, ext)
+			if err := a.store.Put(ctx, key, body, contentType); err != nil {
+				return total, fmt.Errorf("upload %s: %w", key, err)
+			}
+		}
+
+		ids := make([]any, len(batch))
+		for i, e := range batch {
+			ids[i] = e.ID
+		}
+		res, err := db.ExecContext(ctx, `DELETE FROM logs WHERE id IN (`+placeholders(len(ids))+`)`, ids...)
+		if err != nil {
+			return total, err
+		}
+		n, _ := res.RowsAffected()
+		total += n
+		if len(batch) < cfg.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// ArchiveRestoreResult reports a restore from the archive.
+type ArchiveRestoreResult struct {
+	Objects  int      `json:"objects"`  // objects read
+	Restored int64    `json:"restored"` // logs inserted
+	Existing int64    `json:"existing"` // logs in range that were already present
+	Skipped  []string `json:"skipped,omitempty"`
+}
+
+// restore re-inserts tenant's archived logs with timestamps in [from, to)
+// under their original ids, so restoring the same range twice is harmless.
+// Embeddings aren't archived; restored logs are searchable by filters but
+// not semantically until re-embedded.
+func (a *Archiver) restore(ctx context.Context, db *store.DB, tenant string, from, to time.Time) (ArchiveRestoreResult, error) {
+	var res ArchiveRestoreResult
+	if !to.After(from) {
+		return res, fmt.Errorf("'to' must be after 'from'")
+	}
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.Add(24 * time.Hour) {
+		dayPrefix := fmt.Sprintf("%s/tenant=%s/date=%s/", a.cfg.Prefix, tenant, day.Format("2006-01-02"))
+		keys, err := a.store.List(ctx, dayPrefix)
+		if err != nil {
+			return res, err
+		}
+		for _, key := range keys {
+			if !a.hourInRange(key, day, from, to) {
+				continue
+			}
+			if !strings.HasSuffix(key, ".ndjson.gz") {
+				res.Skipped = append(res.Skipped, key)
+				continue
+			}
+			body, err := a.store.Get(ctx, key)
+			if err != nil {
+				return res, fmt.Errorf("download %s: %w", key, err)
+			}
+			inserted, existing, err := restoreObject(ctx, db, body, tenant, from, to)
+			if err != nil {
+				return res, fmt.Errorf("restore %s: %w", key, err)
+			}
+			res.Objects++
+			res.Restored += inserted
+			res.Existing += existing
+		}
+	}
+	return res, nil
+}
+
+// hourInRange reports whether key's hour partition overlaps [from, to).
+func (a *Archiver) hourInRange(key string, day, from, to time.Time) bool {
+	i := strings.Index(key, "/hour=")
+	if i < 0 {
+		return false
+	}
+	var hour int
+	if _, err := fmt.Sscanf(key[i+len("/hour="):], "%02d", &hour); err != nil {
+		return false
+	}
+	start := day.Add(time.Duration(hour) * time.Hour)
+	return start.Before(to) && start.Add(time.Hour).After(from)
+}
+
+// restoreObject inserts the entries of one gzipped NDJSON object that fall
+// in [from, to).
+func restoreObject(ctx context.Context, db *store.DB, body []byte, tenant string, from, to time.Time) (inserted, existing int64, err error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, tx.InsertIgnore(`
+		INSERT INTO logs (id, tenant_id, timestamp, source, severity, message, ip_address, fields, labels, risk_score)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stmt.Close()
+
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e LogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return 0, 0, err
+		}
+		if e.TenantID != tenant || e.Timestamp.Before(from) || !e.Timestamp.Before(to) {
+			continue
+		}
+		fields, _ := store.EncodeFields(e.Fields)
+		labels, _ := store.EncodeFields(e.Labels)
+		r, err := stmt.ExecContext(ctx, e.ID, e.TenantID, e.Timestamp, e.Source, e.Severity, e.Message, e.IPAddress, fields, labels, e.RiskScore)
+		if err != nil {
+			return 0, 0, err
+		}
+		if n, _ := r.RowsAffected(); n > 0 {
+			inserted++
+		} else {
+			existing++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	return inserted, existing, tx.Commit()
+}
+
+// archiveRestoreHandler serves POST /api/archive/restore {"from", "to"}.
+func archiveRestoreHandler(db *store.DB, a *Archiver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			From time.Time `json:"from"`
+			To   time.Time `json:"to"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if req.From.IsZero() {
+			writeError(w, http.StatusBadRequest, "'from' is required")
+			return
+		}
+		if req.To.IsZero() {
+			req.To = time.Now()
+		}
+		tenant := tenantFromRequest(r)
+		res, err := a.restore(r.Context(), db, tenant, req.From, req.To)
+		if err != nil {
+			slog.Error("Archive restore failed", "err", err)
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		actor := requestActor(r)
+		recordAudit(db, tenant, actor, "archive.restore", map[string]any{"from": req.From, "to": req.To, "result": res})
+		slog.Info("Restored archived logs", "actor", actor, "restored", res.Restored, "from", req.From, "to", req.To)
+		writeJSON(w, http.StatusOK, res)
+	}
+}
+
+// archiveCommand lists or restores archived logs.
+func archiveCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: archive <list|restore> [flags]")
+	}
+	sub, args := args[0], args[1:]
+	fs := flag.NewFlagSet("archive "+sub, flag.ExitOnError)
+	configPath := fs.String("config", "../config.yaml", "path to config file")
+	tenant := fs.String("tenant", defaultTenant, "tenant whose logs to read")
+	fromFlag := fs.String("from", "", "start of time range, RFC3339 (required)")
+	toFlag := fs.String("to", "", "end of time range, RFC3339 (default now)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	cfg := config.Retention.WithDefaults().Archive
+	if !cfg.Enabled {
+		return errors.New("retention.archive is not enabled in the config")
+	}
+	a, err := NewArchiver(cfg)
+	if err != nil {
+		return err
+	}
+	from, err := time.Parse(time.RFC3339, *fromFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %v", err)
+	}
+	to := time.Now()
+	if *toFlag != "" {
+		if to, err = time.Parse(time.RFC3339, *toFlag); err != nil {
+			return fmt.Errorf("invalid -to: %v", err)
+		}
+	}
+	ctx := context.Background()
+
+	switch sub {
+	case "list":
+		for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.Add(24 * time.Hour) {
+			keys, err := a.store.List(ctx, fmt.Sprintf("%s/tenant=%s/date=%s/", cfg.Prefix, *tenant, day.Format("2006-01-02")))
+			if err != nil {
+				return err
+			}
+			for _, key := range keys {
+				if a.hourInRange(key, day, from, to) {
+					fmt.Println(key)
+				}
+			}
+		}
+		return nil
+
+	case "restore":
+		db, err := store.Open(config)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		res, err := a.restore(ctx, db, *tenant, from, to)
+		if err != nil {
+			return err
+		}
+		recordAudit(db, *tenant, "cli", "archive.restore", map[string]any{"from": from, "to": to, "result": res})
+		for _, key := range res.Skipped {
+			slog.Warn("Skipped archive: only ndjson archives can be restored", "key", key)
+		}
+		slog.Info("Restored archived logs", "restored", res.Restored, "objects", res.Objects, "existing", res.Existing)
+		return nil
+	}
+	return fmt.Errorf("unknown archive command %q", sub)
+}
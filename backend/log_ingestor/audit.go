@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// recordAudit appends an administrative action to the audit_log table.
+// Failures are logged rather than returned so that auditing never blocks
+// the action itself.
+func recordAudit(db *store.DB, tenantID, actor, action string, details any) {
+	data, err := json.Marshal(details)
+	if err != nil {
+		slog.Warn("Failed to encode audit details", "action", action, "err", err)
+		return
+	}
+	if _, err := db.Exec(`
+		INSERT INTO audit_log (tenant_id, actor, action, details) VALUES (?, ?, ?, ?)`,
+		tenantID, actor, action, string(data),
+	); err != nil {
+		slog.Warn("Failed to record audit entry", "action", action, "actor", actor, "err", err)
+		return
+	}
+	slog.Info("Audit", "action", action, "actor", actor, "tenant", tenantID)
+}
+
+// AuditEntry is one recorded administrative action.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	TenantID  string          `json:"tenant_id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Details   json.RawMessage `json:"details,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// auditHandler serves GET /api/audit, newest first. Filters: action (exact,
+// or a prefix such as "key." when it ends in a dot), actor, from and to
+// (RFC3339), and before (an entry id, for paging).
+func auditHandler(db *store.DB, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limit := cfg.DefaultPageSize
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > cfg.MaxPageSize {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("'limit' must be between 1 and %d", cfg.MaxPageSize))
+				return
+			}
+			limit = n
+		}
+		where := []string{"tenant_id = ?"}
+		args := []any{tenantFromRequest(r)}
+		if action := q.Get("action"); strings.HasSuffix(action, ".") {
+			where = append(where, "action LIKE ?")
+			args = append(args, action+"%")
+		} else if action != "" {
+			where = append(where, "action = ?")
+			args = append(args, action)
+		}
+		if actor := q.Get("actor"); actor != "" {
+			where = append(where, "actor = ?")
+			args = append(args, actor)
+		}
+		for _, bound := range []struct{ param, cond string }{{"from", "created_at >= ?"}, {"to", "created_at < ?"}} {
+			v := q.Get(bound.param)
+			if v == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("'%s' must be an RFC3339 timestamp", bound.param))
+				return
+			}
+			where = append(where, bound.cond)
+			args = append(args, t)
+		}
+		if v := q.Get("before"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "'before' must be an audit entry id")
+				return
+			}
+			where = append(where, "id < ?")
+			args = append(args, id)
+		}
+
+		rows, err := db.Query(`
+			SELECT id, tenant_id, COALESCE(actor, ''), COALESCE(action, ''), details, created_at
+			FROM audit_log
+			WHERE `+strings.Join(where, " AND ")+`
+			ORDER BY id DESC
+			LIMIT ?`, append(args, limit)...)
+		if err != nil {
+			slog.Error("Failed to list audit log", "err", err)
+			writeError(w, http.StatusInternalServerError, "failed to list audit log")
+			return
+		}
+		defer rows.Close()
+		entries := []AuditEntry{}
+		for rows.Next() {
+			var (
+				e       AuditEntry
+				details []byte
+			)
+			if err := rows.Scan(&e.ID, &e.TenantID, &e.Actor, &e.Action, &details, &e.CreatedAt); err != nil {
+				slog.Error("Failed to read audit entry", "err", err)
+				writeError(w, http.StatusInternalServerError, "failed to list audit log")
+				return
+			}
+			if len(details) > 0 {
+				e.Details = details
+			}
+			entries = append(entries, e)
+		}
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+// requestActor identifies who made a request for the audit trail.
+func requestActor(r *http.Request) string {
+	if actor := r.Header.Get("X-Actor"); actor != "" {
+		return actor
+	}
+	if actor := certActor(r); actor != "" {
+		return actor
+	}
+	if key := keyFromContext(r.Context()); key != nil {
+		return "key:" + key.Prefix
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"time"
+
+	"1logx/log_ingestor/internal/pipeline"
+)
+
+// PressureSignal is one input to the pressure, smoothed.
+type PressureSignal struct {
+	Value    float64 `json:"value"`
+	Target   float64 `json:"target"`
+	Pressure float64 `json:"pressure"` // value / target, capped at 1
+}
+
+// AutoscaleReport is the replica's load as served to autoscalers.
+type AutoscaleReport struct {
+	Pressure float64                   `json:"pressure"` // the highest signal pressure, 0-1
+	Dominant string                    `json:"dominant"` // the signal that set it
+	Signals  map[string]PressureSignal `json:"signals"`
+	At       time.Time                 `json:"at"`
+}
+
+// Autoscaler samples load signals and reduces them to a single 0-1 pressure.
+// The pressure is the maximum of the signals, not an average, so one
+// saturated resource is enough to ask for more replicas.
+type Autoscaler struct {
+	cfg    AutoscaleConfig
+	retry  *RetryQueue // nil without a retry queue
+	load   *pipeline.Load
+	target time.Duration
+
+	mu      sync.Mutex
+	values  map[string]float64 // smoothed
+	cpu     []metrics.Sample
+	lastCPU [2]float64 // idle, total cpu-seconds at the last sample
+	report  AutoscaleReport
+}
+
+func NewAutoscaler(cfg AutoscaleConfig, retry *RetryQueue, load *pipeline.Load) *Autoscaler {
+	target, _ := time.ParseDuration(cfg.TargetLatency)
+	a := &Autoscaler{
+		cfg:    cfg,
+		retry:  retry,
+		load:   load,
+		target: target,
+		values: map[string]float64{},
+		cpu:    []metrics.Sample{{Name: "/cpu/classes/idle:cpu-seconds"}, {Name: "/cpu/classes/total:cpu-seconds"}},
+	}
+	a.sample()
+	return a
+}
+
+// cpuUtilization is the share of CPU time available to the Go runtime that
+// was spent busy since the last call. The runtime's accounting is an
+// estimate, but it needs no OS-specific code.
+func (a *Autoscaler) cpuUtilization() float64 {
+	metrics.Read(a.cpu)
+	var now [2]float64
+	for i, s := range a.cpu {
+		if s.Value.Kind() == metrics.KindFloat64 {
+			now[i] = s.Value.Float64()
+		}
+	}
+	idle, total := now[0]-a.lastCPU[0], now[1]-a.lastCPU[1]
+	a.lastCPU = now
+	if total <= 0 {
+		return 0
+	}
+	return math.Max(0, math.Min(1, 1-idle/total))
+}
+
+// sample folds the current readings into the smoothed signals and rebuilds
+// the report.
+func (a *Autoscaler) sample() {
+	readings := map[string]float64{
+		"in_flight":      float64(a.load.InFlight()),
+		"insert_latency": a.load.InsertLatency(),
+	}
+	if a.retry != nil {
+		readings["retry_queue"] = float64(len(a.retry.queue)) / float64(cap(a.retry.queue))
+	}
+	targets := map[string]float64{
+		"in_flight":      a.cfg.TargetInFlight,
+		"insert_latency": a.target.Seconds(),
+		"retry_queue":    a.cfg.TargetQueue,
+		"cpu":            a.cfg.TargetCPU,
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	readings["cpu"] = a.cpuUtilization()
+
+	report := AutoscaleReport{Signals: map[string]PressureSignal{}, At: time.Now()}
+	names := make([]string, 0, len(readings))
+	for name := range readings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v, seen := a.values[name]
+		if seen {
+			v += a.cfg.Smoothing * (readings[name] - v)
+		} else {
+			v = readings[name]
+		}
+		a.values[name] = v
+		sig := PressureSignal{Value: v, Target: targets[name], Pressure: math.Min(1, v/targets[name])}
+		report.Signals[name] = sig
+		if sig.Pressure > report.Pressure || report.Dominant == "" {
+			report.Pressure, report.Dominant = sig.Pressure, name
+		}
+	}
+	a.report = report
+}
+
+// Run samples the signals every interval.
+func (a *Autoscaler) Run() {
+	interval, _ := time.ParseDuration(a.cfg.Interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.sample()
+	}
+}
+
+// handler serves GET /autoscale with the replica's pressure as JSON, or in
+// the Prometheus text format with ?format=prometheus for metrics adapters
+// (e.g. the Kubernetes HPA via prometheus-adapter).
+func (a *Autoscaler) handler(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	report := a.report
+	a.mu.Unlock()
+
+	if r.URL.Query().Get("format") != "prometheus" {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP logx_ingestor_pressure Replica load, 0-1; 1 means at capacity.")
+	fmt.Fprintln(w, "# TYPE logx_ingestor_pressure gauge")
+	fmt.Fprintf(w, "logx_ingestor_pressure %g\n", report.Pressure)
+	fmt.Fprintln(w, "# HELP logx_ingestor_signal_pressure Pressure of each load signal, 0-1.")
+	fmt.Fprintln(w, "# TYPE logx_ingestor_signal_pressure gauge")
+	names := make([]string, 0, len(report.Signals))
+	for name := range report.Signals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "logx_ingestor_signal_pressure{signal=%q} %g\n", name, report.Signals[name].Pressure)
+	}
+}
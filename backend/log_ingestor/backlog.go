@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// backlogHold buffers a client's live logs while its backlog is loaded, so
+// the backlog and the live feed join up without gaps or repeats.
+type backlogHold struct {
+	mu      sync.Mutex
+	pending [][]byte
+	ids     []int64
+	holding bool
+}
+
+// hold queues entry for later delivery if a backlog is loading.
+func (h *backlogHold) hold(entry LogEntry, data []byte) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.holding {
+		return false
+	}
+	h.pending = append(h.pending, data)
+	h.ids = append(h.ids, entry.ID)
+	return true
+}
+
+// parseBacklog reads a backlog size: 0 (none) up to cfg.MaxBacklog.
+func parseBacklog(s string, cfg StreamConfig) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > cfg.MaxBacklog {
+		return 0, fmt.Errorf("'backlog' must be between 0 and %d", cfg.MaxBacklog)
+	}
+	return n, nil
+}
+
+// sendBacklog sends the last n stored logs the client would receive live,
+// further narrowed by filter, oldest first, then resumes its live feed.
+// Live logs arriving meanwhile are held and sent after the backlog, minus
+// any the backlog already included.
+func sendBacklog(ctx context.Context, db *store.DB, cfg StreamConfig, client *wsClient, n int, filter StreamFilter) error {
+	h := &client.backlog
+	h.mu.Lock()
+	if h.holding {
+		h.mu.Unlock()
+		return fmt.Errorf("a backlog is already loading")
+	}
+	h.holding = true
+	h.mu.Unlock()
+
+	// Logs broadcast before the hold started were stored before it too, so
+	// the query below sees them.
+	entries, err := loadBacklog(ctx, db, cfg, client, n, filter)
+	sent := map[int64]bool{}
+	if err == nil {
+		client.send(wsMessage{Type: "backlog_started", Data: map[string]any{"count": len(entries)}})
+		for i := len(entries) - 1; i >= 0; i-- {
+			if err = client.send(entries[i]); err != nil {
+				break
+			}
+			sent[entries[i].ID] = true
+		}
+	}
+	if err == nil {
+		client.send(wsMessage{Type: "backlog_finished", Data: map[string]any{"sent": len(entries)}})
+	}
+
+	// Flush under the lock: deliveries racing with it wait, then go out
+	// directly, after everything held. Held logs are offered like any live
+	// log, since broadcasts wait on the lock and mustn't be held up by a
+	// slow client; what doesn't fit is dropped.
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, data := range h.pending {
+		if !sent[h.ids[i]] {
+			client.offer(data)
+		}
+	}
+	h.pending, h.ids, h.holding = nil, nil, false
+	return err
+}
+
+// loadBacklog returns the last n matching logs of the client's tenant,
+// newest first. Filters are applied as rows are read, so at most
+// cfg.MaxBacklogScan rows are examined.
+func loadBacklog(ctx context.Context, db *store.DB, cfg StreamConfig, client *wsClient, n int, filter StreamFilter) ([]LogEntry, error) {
+	var (
+		entries []LogEntry
+		lastID  int64
+		scanned int
+	)
+	for len(entries) < n && scanned < cfg.MaxBacklogScan {
+		where := []string{"tenant_id = ?", "deleted_at IS NULL"}
+		args := []any{client.tenantID}
+		if lastID > 0 {
+			where = append(where, "id < ?")
+			args = append(args, lastID)
+		}
+		batch := min(replayBatchSize, cfg.MaxBacklogScan-scanned)
+		rows, err := db.QueryContext(ctx, `
+			SELECT `+logColumns+`
+			FROM logs
+			WHERE `+strings.Join(where, " AND ")+`
+			ORDER BY id DESC
+			LIMIT ?`, append(args, batch)...)
+		if err != nil {
+			return nil, err
+		}
+		page, err := scanLogs(rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range page {
+			if (client.subs == nil || client.subs.wants(e)) && filter.matches(e) {
+				entries = append(entries, e)
+				if len(entries) == n {
+					break
+				}
+			}
+		}
+		scanned += len(page)
+		if len(page) < batch {
+			break
+		}
+		lastID = page[len(page)-1].ID
+	}
+	if scanned >= cfg.MaxBacklogScan && len(entries) < n {
+		slog.Warn("Backlog scan limit reached", "scanned", scanned, "found", len(entries), "wanted", n)
+	}
+	return entries, nil
+}
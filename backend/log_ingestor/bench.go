@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"1logx/log_ingestor/internal/pipeline"
+)
+
+// benchRecorder collects per-insert latencies during a benchmark run.
+type benchRecorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int
+	failures  map[string]int // error message -> count
+}
+
+func (b *benchRecorder) record(d time.Duration, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		b.errors++
+		if b.failures == nil {
+			b.failures = map[string]int{}
+		}
+		b.failures[err.Error()]++
+		return
+	}
+	b.latencies = append(b.latencies, d)
+}
+
+// runBench drives the simulator into the ingestor for duration and prints
+// achieved insert throughput and latency percentiles, for sizing TiDB.
+func runBench(in *pipeline.Ingestor, sim *Simulator, duration time.Duration) {
+	slog.Info("Benchmarking ingest", "duration", duration, "target_rate", sim.naturalRate()*sim.scale)
+
+	var rec benchRecorder
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+	start := time.Now()
+	sim.Run(ctx, func(entry LogEntry) {
+		t := time.Now()
+		_, err := in.Ingest(entry)
+		rec.record(time.Since(t), err)
+	})
+	elapsed := time.Since(start)
+
+	lat := rec.latencies
+	sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+	fmt.Printf("\nIngest benchmark\n")
+	fmt.Printf("  duration:    %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("  inserted:    %d (%d errors)\n", len(lat), rec.errors)
+	fmt.Printf("  throughput:  %.1f inserts/sec\n", float64(len(lat))/elapsed.Seconds())
+	if len(lat) == 0 {
+		return
+	}
+	fmt.Printf("  latency:     p50=%s p90=%s p95=%s p99=%s max=%s\n",
+		percentile(lat, 50), percentile(lat, 90), percentile(lat, 95), percentile(lat, 99), lat[len(lat)-1])
+}
+
+// percentile returns the p-th percentile of sorted, using nearest rank.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank].Round(time.Microsecond)
+}
+
+// benchCommand generates synthetic load against a remote ingest endpoint and
+// reports throughput, latency percentiles, and errors, for capacity planning
+// before onboarding a new source.
+func benchCommand(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	target := fs.String("url", "http://localhost:8080/api/ingest", "ingest endpoint")
+	key := fs.String("key", os.Getenv("LOGX_API_KEY"), "ingest API key (default $LOGX_API_KEY)")
+	concurrency := fs.Int("concurrency", 8, "concurrent requests")
+	batch := fs.Int("batch", 10, "entries per request")
+	payload := fs.Int("payload", 256, "message size in bytes")
+	duration := fs.Duration("duration", 30*time.Second, "how long to generate load")
+	rate := fs.Float64("rate", 0, "max requests/sec across workers (0 = unlimited)")
+	source := fs.String("source", "bench", "source of the generated entries")
+	timeout := fs.Duration("timeout", 10*time.Second, "per-request timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *concurrency <= 0 || *batch <= 0 || *payload <= 0 || *duration <= 0 {
+		return errors.New("-concurrency, -batch, -payload, and -duration must be positive")
+	}
+
+	client := &http.Client{
+		Timeout:   *timeout,
+		Transport: &http.Transport{MaxIdleConnsPerHost: *concurrency},
+	}
+	var pace <-chan time.Time
+	if *rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+
+	slog.Info("Benchmarking", "target", *target, "duration", *duration,
+		"workers", *concurrency, "batch", *batch, "payload_bytes", *payload)
+	var rec benchRecorder
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for {
+				if pace != nil {
+					select {
+					case <-pace:
+					case <-ctx.Done():
+						return
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				body, _ := json.Marshal(benchEntries(rng, *source, *batch, *payload))
+				t := time.Now()
+				err := benchPost(ctx, client, *target, *key, body)
+				if ctx.Err() != nil {
+					return // cut off by the deadline, not a failure
+				}
+				rec.record(time.Since(t), err)
+			}
+		}(start.UnixNano() + int64(w))
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	lat := rec.latencies
+	sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+	total := len(lat) + rec.errors
+	fmt.Printf("\nRemote ingest benchmark: %s\n", *target)
+	fmt.Printf("  duration:    %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("  requests:    %d (%d failed", total, rec.errors)
+	if total > 0 {
+		fmt.Printf(", %.2f%% error rate", 100*float64(rec.errors)/float64(total))
+	}
+	fmt.Printf(")\n")
+	fmt.Printf("  throughput:  %.1f requests/sec, %.1f events/sec\n",
+		float64(len(lat))/elapsed.Seconds(), float64(len(lat)**batch)/elapsed.Seconds())
+	if len(lat) > 0 {
+		fmt.Printf("  latency:     p50=%s p90=%s p95=%s p99=%s max=%s\n",
+			percentile(lat, 50), percentile(lat, 90), percentile(lat, 95), percentile(lat, 99), lat[len(lat)-1])
+	}
+	reasons := make([]string, 0, len(rec.failures))
+	for reason := range rec.failures {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Printf("  error:       %s x%d\n", reason, rec.failures[reason])
+	}
+	return nil
+}
+
+var benchSeverities = []Severity{SeverityInfo, SeverityInfo, SeverityInfo, SeverityWarning, SeverityAlert, SeverityCritical}
+
+// benchEntries builds n synthetic entries with messages of about size bytes.
+func benchEntries(rng *rand.Rand, source string, n, size int) []LogEntry {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789 "
+	entries := make([]LogEntry, n)
+	for i := range entries {
+		var msg strings.Builder
+		msg.Grow(size)
+		for msg.Len() < size {
+			msg.WriteByte(alphabet[rng.Intn(len(alphabet))])
+		}
+		entries[i] = LogEntry{
+			Timestamp: time.Now(),
+			Source:    source,
+			Severity:  benchSeverities[rng.Intn(len(benchSeverities))],
+			Message:   msg.String(),
+			IPAddress: fmt.Sprintf("10.%d.%d.%d", rng.Intn(256), rng.Intn(256), 1+rng.Intn(254)),
+		}
+	}
+	return entries
+}
+
+// benchPost sends one batch and fails on any non-2xx status.
+func benchPost(ctx context.Context, client *http.Client, target, key string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var netErr interface{ Timeout() bool }
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return errors.New("timeout")
+		}
+		return errors.New("connection error")
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// localOnlyMessages are computed by every replica from the shared stream,
+// so relaying them would deliver each one once per replica.
+var localOnlyMessages = map[string]bool{"aggregates": true}
+
+// busMessage is one broadcast relayed between replicas.
+type busMessage struct {
+	Origin string          `json:"origin"` // replica that broadcast it
+	Tenant string          `json:"tenant"`
+	Type   string          `json:"type"` // "logs" for a log entry, else the envelope type
+	Data   json.RawMessage `json:"data"`
+}
+
+// busTransport is the pub/sub system replicas share.
+type busTransport interface {
+	Publish(data []byte) error
+	Subscribe(handle func([]byte)) error
+	Close() error
+}
+
+type redisBus struct {
+	client  *redis.Client
+	channel string
+}
+
+func (b redisBus) Publish(data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+// Subscribe delivers messages until the client is closed. go-redis
+// resubscribes by itself after a dropped connection.
+func (b redisBus) Subscribe(handle func([]byte)) error {
+	sub := b.client.Subscribe(context.Background(), b.channel)
+	if _, err := sub.Receive(context.Background()); err != nil {
+		sub.Close()
+		return err
+	}
+	go func() {
+		for msg := range sub.Channel() {
+			handle([]byte(msg.Payload))
+		}
+	}()
+	return nil
+}
+
+func (b redisBus) Close() error { return b.client.Close() }
+
+type natsBus struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func (b natsBus) Publish(data []byte) error { return b.conn.Publish(b.subject, data) }
+
+func (b natsBus) Subscribe(handle func([]byte)) error {
+	_, err := b.conn.Subscribe(b.subject, func(m *nats.Msg) { handle(m.Data) })
+	return err
+}
+
+func (b natsBus) Close() error {
+	b.conn.Close()
+	return nil
+}
+
+// ClusterBus relays this replica's broadcasts to the others and delivers
+// theirs to local clients. Publishing is asynchronous like the fan-out, so
+// a slow broker never stalls ingestion.
+type ClusterBus struct {
+	id        string
+	transport busTransport
+	queue     chan busMessage
+
+	hub *streamHub
+	// Remote entries feed the recent buffer and live aggregates too, so
+	// every replica answers with the whole cluster's stream.
+	recent     *RecentEvents
+	aggregates *LiveAggregates // nil when aggregates are disabled
+
+	sent, received, dropped atomic.Int64
+}
+
+// NewClusterBus joins the bus, delivering other replicas' broadcasts to h.
+func NewClusterBus(cfg ClusterConfig, h *streamHub, recent *RecentEvents, aggregates *LiveAggregates) (*ClusterBus, error) {
+	var transport busTransport
+	switch cfg.Backend {
+	case "nats":
+		conn, err := nats.Connect(cfg.Addr, nats.Name("1l0gx-ingestor"), nats.MaxReconnects(-1))
+		if err != nil {
+			return nil, fmt.Errorf("connect to nats at %s: %w", cfg.Addr, err)
+		}
+		transport = natsBus{conn: conn, subject: cfg.Channel}
+	default:
+		client := redis.NewClient(&redis.Options{Addr: cfg.Addr, Password: cfg.Password, DB: cfg.DB})
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := client.Ping(ctx).Err()
+		cancel()
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("connect to redis at %s: %w", cfg.Addr, err)
+		}
+		transport = redisBus{client: client, channel: cfg.Channel}
+	}
+
+	b := &ClusterBus{id: replicaID(), transport: transport, queue: make(chan busMessage, cfg.QueueSize),
+		hub: h, recent: recent, aggregates: aggregates}
+	if err := transport.Subscribe(b.receive); err != nil {
+		transport.Close()
+		return nil, fmt.Errorf("subscribe to %s: %w", cfg.Channel, err)
+	}
+	return b, nil
+}
+
+// replicaID names this replica on the bus: the hostname (the pod name on
+// Kubernetes) plus a random suffix, since hostnames can repeat.
+func replicaID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return host + "-" + hex.EncodeToString(suffix)
+}
+
+// Run publishes queued broadcasts, and reports drops periodically.
+func (b *ClusterBus) Run() {
+	report := time.NewTicker(time.Minute)
+	defer report.Stop()
+	for {
+		select {
+		case msg := <-b.queue:
+			data, _ := json.Marshal(msg)
+			if err := b.transport.Publish(data); err != nil {
+				slog.Warn("Failed to relay to cluster", "err", err)
+				continue
+			}
+			b.sent.Add(1)
+		case <-report.C:
+			if n := b.dropped.Swap(0); n > 0 {
+				slog.Warn("Cluster bus queue full; dropped events in the last minute", "dropped", n)
+			}
+		}
+	}
+}
+
+// Publish queues a local broadcast for the other replicas.
+func (b *ClusterBus) Publish(eventType, tenantID string, data []byte) {
+	if localOnlyMessages[eventType] {
+		return
+	}
+	select {
+	case b.queue <- busMessage{Origin: b.id, Tenant: tenantID, Type: eventType, Data: data}:
+	default:
+		b.dropped.Add(1)
+	}
+}
+
+// receive delivers another replica's broadcast to local clients. Its own
+// broadcasts come back too and are skipped, as they were delivered already.
+func (b *ClusterBus) receive(data []byte) {
+	var msg busMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		slog.Warn("Ignoring malformed cluster message", "err", err)
+		return
+	}
+	if msg.Origin == b.id {
+		return
+	}
+	b.received.Add(1)
+	if msg.Type != "logs" {
+		b.hub.DeliverMessage(msg.Tenant, msg.Data)
+		return
+	}
+	var entry LogEntry
+	if err := json.Unmarshal(msg.Data, &entry); err != nil {
+		slog.Warn("Ignoring malformed cluster log", "err", err)
+		return
+	}
+	b.recent.add(entry)
+	b.aggregates.observe(entry)
+	b.hub.DeliverLog(entry, msg.Data)
+}
+
+// ClusterStatus is this replica's view of the bus.
+type ClusterStatus struct {
+	Replica  string `json:"replica"`
+	Sent     int64  `json:"sent"`
+	Received int64  `json:"received"`
+	Queued   int    `json:"queued"`
+}
+
+// handler serves GET /api/admin/cluster.
+func (b *ClusterBus) handler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, ClusterStatus{
+		Replica:  b.id,
+		Sent:     b.sent.Load(),
+		Received: b.received.Load(),
+		Queued:   len(b.queue),
+	})
+}
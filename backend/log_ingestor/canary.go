@@ -0,0 +1,306 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ParserDivergence is one message the stable and candidate parsers disagree on.
+type ParserDivergence struct {
+	At          time.Time `json:"at"`
+	TenantID    string    `json:"tenant_id"`
+	Message     string    `json:"message"`
+	Differences []string  `json:"differences"`
+	Stable      *LogEntry `json:"stable,omitempty"`
+	Candidate   *LogEntry `json:"candidate,omitempty"`
+}
+
+// HuntDivergence compares one hunt's findings between the stable and
+// candidate packs for a window.
+type HuntDivergence struct {
+	At            time.Time `json:"at"`
+	TenantID      string    `json:"tenant_id"`
+	HuntID        string    `json:"hunt_id"`
+	WindowStart   time.Time `json:"window_start"`
+	Stable        int       `json:"stable"`
+	Candidate     int       `json:"candidate"`
+	OnlyStable    []string  `json:"only_stable,omitempty"`
+	OnlyCandidate []string  `json:"only_candidate,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// CanaryMonitor compares candidate output with stable output and keeps
+// divergence counters and recent samples.
+type CanaryMonitor struct {
+	cfg       CanaryConfig
+	candidate func(string) (LogEntry, error)
+	// redactor masks sampled messages before they're kept.
+	redactor *Redactor
+
+	mu                                 sync.Mutex
+	sampled, diverged, candidateFailed int64
+	parserSamples                      []ParserDivergence
+	huntRuns, huntDiverged             int64
+	huntSamples                        []HuntDivergence
+}
+
+// NewCanaryMonitor selects the stable parser version and prepares the
+// candidate. It returns a nil monitor when no candidate is configured.
+func NewCanaryMonitor(cfg CanaryConfig, redactor *Redactor) (*CanaryMonitor, error) {
+	stable, ok := parserVersions[cfg.Parser.Stable]
+	if !ok {
+		return nil, fmt.Errorf("unknown stable parser version %q", cfg.Parser.Stable)
+	}
+	structuredParser = stable
+
+	if cfg.Parser.Candidate == "" && cfg.HuntPack == "" {
+		return nil, nil
+	}
+	m := &CanaryMonitor{cfg: cfg, redactor: redactor}
+	if cfg.Parser.Candidate != "" {
+		if m.candidate, ok = parserVersions[cfg.Parser.Candidate]; !ok {
+			return nil, fmt.Errorf("unknown candidate parser version %q", cfg.Parser.Candidate)
+		}
+	}
+	return m, nil
+}
+
+// Sample reports whether the next message should also go to the candidate.
+func (m *CanaryMonitor) Sample() bool {
+	return m != nil && m.candidate != nil && rand.Float64()*100 < m.cfg.Parser.Percent
+}
+
+// CompareParse parses message with both versions. A panicking candidate
+// counts as a failure instead of taking down the ingestor.
+func (m *CanaryMonitor) CompareParse(tenant, message string) {
+	stable, stableErr := structuredParser(message)
+	candidate, candidateErr := func() (e LogEntry, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("candidate parser panicked: %v", r)
+			}
+		}()
+		return m.candidate(message)
+	}()
+
+	// Messages neither version recognizes aren't comparisons worth counting.
+	if errors.Is(stableErr, errNotStructured) && errors.Is(candidateErr, errNotStructured) {
+		return
+	}
+	diffs := diffParsed(stable, stableErr, candidate, candidateErr)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sampled++
+	if candidateErr != nil && !errors.Is(candidateErr, errNotStructured) {
+		m.candidateFailed++
+	}
+	if len(diffs) == 0 {
+		return
+	}
+	m.diverged++
+	if tenant == "" {
+		tenant = defaultTenant
+	}
+	if len(message) > 1024 {
+		message = message[:1024]
+	}
+	d := ParserDivergence{At: time.Now(), TenantID: tenant, Message: m.redactor.redactText(message), Differences: diffs}
+	if stableErr == nil {
+		d.Stable = &stable
+	}
+	if candidateErr == nil {
+		d.Candidate = &candidate
+	}
+	m.parserSamples = appendSample(m.parserSamples, d, m.cfg.Samples)
+}
+
+// diffParsed lists how two parse results differ.
+func diffParsed(a LogEntry, aErr error, b LogEntry, bErr error) []string {
+	if aErr != nil || bErr != nil {
+		if fmt.Sprint(aErr) == fmt.Sprint(bErr) {
+			return nil
+		}
+		return []string{fmt.Sprintf("error: %v != %v", aErr, bErr)}
+	}
+	var diffs []string
+	field := func(name string, x, y any) {
+		if x != y {
+			diffs = append(diffs, fmt.Sprintf("%s: %v != %v", name, x, y))
+		}
+	}
+	field("source", a.Source, b.Source)
+	field("severity", a.Severity, b.Severity)
+	field("timestamp", a.Timestamp.UTC(), b.Timestamp.UTC())
+	field("ip_address", a.IPAddress, b.IPAddress)
+	field("message", a.Message, b.Message)
+
+	keys := map[string]bool{}
+	for k := range a.Fields {
+		keys[k] = true
+	}
+	for k := range b.Fields {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		x, inA := a.Fields[k]
+		y, inB := b.Fields[k]
+		switch {
+		case !inA:
+			diffs = append(diffs, fmt.Sprintf("fields.%s: only in candidate", k))
+		case !inB:
+			diffs = append(diffs, fmt.Sprintf("fields.%s: only in stable", k))
+		default:
+			field("fields."+k, x, y)
+		}
+	}
+	return diffs
+}
+
+// compareHunt records how a candidate hunt's findings differ from the
+// stable hunt's, per tenant. A hunt only in the candidate pack compares
+// against no findings.
+func (m *CanaryMonitor) compareHunt(huntID string, windowStart time.Time, stable, candidate []HuntFinding, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.huntRuns++
+	if err != nil {
+		m.huntDiverged++
+		m.huntSamples = appendSample(m.huntSamples, HuntDivergence{
+			At: time.Now(), TenantID: defaultTenant, HuntID: huntID, WindowStart: windowStart, Error: err.Error(),
+		}, m.cfg.Samples)
+		return
+	}
+
+	keys := func(findings []HuntFinding) map[string]map[string]bool {
+		out := map[string]map[string]bool{}
+		for _, f := range findings {
+			if out[f.TenantID] == nil {
+				out[f.TenantID] = map[string]bool{}
+			}
+			out[f.TenantID][f.FindingKey] = true
+		}
+		return out
+	}
+	s, c := keys(stable), keys(candidate)
+	tenants := map[string]bool{}
+	for t := range s {
+		tenants[t] = true
+	}
+	for t := range c {
+		tenants[t] = true
+	}
+
+	diverged := false
+	for tenant := range tenants {
+		d := HuntDivergence{At: time.Now(), TenantID: tenant, HuntID: huntID, WindowStart: windowStart, Stable: len(s[tenant]), Candidate: len(c[tenant])}
+		for k := range s[tenant] {
+			if !c[tenant][k] {
+				d.OnlyStable = append(d.OnlyStable, k)
+			}
+		}
+		for k := range c[tenant] {
+			if !s[tenant][k] {
+				d.OnlyCandidate = append(d.OnlyCandidate, k)
+			}
+		}
+		if len(d.OnlyStable) == 0 && len(d.OnlyCandidate) == 0 {
+			continue
+		}
+		sort.Strings(d.OnlyStable)
+		sort.Strings(d.OnlyCandidate)
+		diverged = true
+		m.huntSamples = appendSample(m.huntSamples, d, m.cfg.Samples)
+	}
+	if diverged {
+		m.huntDiverged++
+	}
+}
+
+// appendSample appends v, keeping only the newest max samples.
+func appendSample[T any](samples []T, v T, max int) []T {
+	samples = append(samples, v)
+	if len(samples) > max {
+		samples = samples[len(samples)-max:]
+	}
+	return samples
+}
+
+// Run logs divergence totals every interval while the canary is diverging.
+func (m *CanaryMonitor) Run() {
+	interval, _ := time.ParseDuration(m.cfg.Interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastParser, lastHunts int64
+	for range ticker.C {
+		m.mu.Lock()
+		sampled, diverged, failed := m.sampled, m.diverged, m.candidateFailed
+		runs, huntDiverged := m.huntRuns, m.huntDiverged
+		m.mu.Unlock()
+
+		if m.candidate != nil && diverged > lastParser {
+			slog.Warn("Parser canary diverged", "candidate", m.cfg.Parser.Candidate, "stable", m.cfg.Parser.Stable,
+				"diverged", diverged, "sampled", sampled, "candidate_failures", failed)
+		}
+		if huntDiverged > lastHunts {
+			slog.Warn("Hunt pack canary diverged", "diverged", huntDiverged, "runs", runs)
+		}
+		lastParser, lastHunts = diverged, huntDiverged
+	}
+}
+
+// handler serves GET /api/canary with divergence totals and the caller's
+// tenant's recent samples.
+func (m *CanaryMonitor) handler(w http.ResponseWriter, r *http.Request) {
+	tenant := tenantFromRequest(r)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	parserSamples := []ParserDivergence{}
+	for _, d := range m.parserSamples {
+		if d.TenantID == tenant {
+			parserSamples = append(parserSamples, d)
+		}
+	}
+	huntSamples := []HuntDivergence{}
+	for _, d := range m.huntSamples {
+		if d.TenantID == tenant {
+			huntSamples = append(huntSamples, d)
+		}
+	}
+	rate := 0.0
+	if m.sampled > 0 {
+		rate = float64(m.diverged) / float64(m.sampled)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"parser": map[string]any{
+			"stable":             m.cfg.Parser.Stable,
+			"candidate":          m.cfg.Parser.Candidate,
+			"percent":            m.cfg.Parser.Percent,
+			"sampled":            m.sampled,
+			"diverged":           m.diverged,
+			"divergence_rate":    rate,
+			"candidate_failures": m.candidateFailed,
+			"samples":            parserSamples,
+		},
+		"hunts": map[string]any{
+			"candidate_pack": m.cfg.HuntPack,
+			"runs":           m.huntRuns,
+			"diverged":       m.huntDiverged,
+			"samples":        huntSamples,
+		},
+	})
+}
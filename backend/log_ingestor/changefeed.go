@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// ChangeFeed follows the logs table and broadcasts each inserted row.
+// Updates and deletes aren't streamed, matching the ingest path.
+type ChangeFeed struct {
+	cfg   ChangeFeedConfig
+	db    *store.DB
+	store objectStore // ticdc only
+	dir   string      // ticdc: key prefix of the logs table's files
+
+	hub        *streamHub
+	fanout     *Fanout // nil without a fan-out
+	recent     *RecentEvents
+	aggregates *LiveAggregates // nil when aggregates are disabled
+
+	lastKey string // ticdc: newest file delivered
+
+	// poll: rows are read by created_at from overlap before since, the
+	// newest created_at seen, since ids aren't allocated in commit order.
+	// seen holds the ids read in that window, so none is delivered twice.
+	overlap time.Duration
+	since   time.Time
+	seen    map[int64]time.Time // id -> created_at
+}
+
+func NewChangeFeed(db *store.DB, cfg ChangeFeedConfig, h *streamHub, fanout *Fanout, recent *RecentEvents, aggregates *LiveAggregates) (*ChangeFeed, error) {
+	f := &ChangeFeed{cfg: cfg, db: db, hub: h, fanout: fanout, recent: recent, aggregates: aggregates, seen: map[int64]time.Time{}}
+	f.overlap, _ = time.ParseDuration(cfg.Overlap)
+	if cfg.Source == "ticdc" {
+		if cfg.Database == "" {
+			return nil, fmt.Errorf("changefeed: 'database' is required for the ticdc source")
+		}
+		store, err := newObjectStore(cfg.Storage)
+		if err != nil {
+			return nil, fmt.Errorf("changefeed: %w", err)
+		}
+		f.store, f.dir = store, path.Join(cfg.Storage.Prefix, cfg.Database, "logs")+"/"
+	}
+	return f, nil
+}
+
+// start positions the feed at the current end of the table, so only
+// changes made from now on are streamed.
+func (f *ChangeFeed) start(ctx context.Context) error {
+	if f.cfg.Source == "poll" {
+		err := f.db.QueryRowContext(ctx, `
+			SELECT created_at FROM logs
+			WHERE created_at IS NOT NULL
+			ORDER BY created_at DESC
+			LIMIT 1`).Scan(&f.since)
+		if errors.Is(err, sql.ErrNoRows) {
+			f.since, err = time.Now(), nil
+		}
+		if err != nil {
+			return err
+		}
+		// Rows already in the window were there before the feed started.
+		return f.scanWindow(ctx, func(LogEntry) {})
+	}
+	files, err := f.files(ctx)
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		f.lastKey = files[len(files)-1]
+	}
+	return nil
+}
+
+// Run fetches and broadcasts changes every interval. A failed fetch is
+// retried on the next tick from the same position.
+func (f *ChangeFeed) Run() {
+	for {
+		err := f.start(context.Background())
+		if err == nil {
+			break
+		}
+		slog.Warn("Change feed failed to start, retrying", "err", err)
+		time.Sleep(10 * time.Second)
+	}
+	slog.Info("Following the logs table", "source", f.cfg.Source)
+
+	interval, _ := time.ParseDuration(f.cfg.Interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		var err error
+		if f.cfg.Source == "poll" {
+			err = f.pollTable(context.Background())
+		} else {
+			err = f.pollSink(context.Background())
+		}
+		if err != nil {
+			slog.Warn("Change feed fetch failed", "err", err)
+		}
+	}
+}
+
+// pollTable delivers rows committed since the last poll. Rows are read by
+// created_at rather than id: a row can commit after one with a higher id, on
+// TiDB because each server allocates ids from its own range, and anywhere
+// because transactions commit out of order. Rows committing later than the
+// overlap after they were inserted are still missed.
+func (f *ChangeFeed) pollTable(ctx context.Context) error {
+	if err := f.scanWindow(ctx, f.deliver); err != nil {
+		return err
+	}
+	for id, created := range f.seen {
+		if created.Before(f.since.Add(-f.overlap)) {
+			delete(f.seen, id)
+		}
+	}
+	return nil
+}
+
+// scanWindow calls fn with the rows created from overlap before since that
+// haven't been seen, in id order a batch at a time, and advances since.
+func (f *ChangeFeed) scanWindow(ctx context.Context, fn func(LogEntry)) error {
+	from, lastID := f.since.Add(-f.overlap), int64(0)
+	for {
+		rows, err := f.db.QueryContext(ctx, `
+			SELECT `+logColumns+`, created_at
+			FROM logs
+			WHERE created_at >= ? AND id > ? AND deleted_at IS NULL
+			ORDER BY id
+			LIMIT ?`, from, lastID, f.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		entries, created, err := scanCreatedLogs(rows)
+		if err != nil {
+			return err
+		}
+		for i, e := range entries {
+			if _, ok := f.seen[e.ID]; !ok {
+				f.seen[e.ID] = created[i]
+				fn(e)
+			}
+			if created[i].After(f.since) {
+				f.since = created[i]
+			}
+			lastID = e.ID
+		}
+		if len(entries) < f.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+// scanCreatedLogs is scanLogs for rows with created_at after logColumns.
+func scanCreatedLogs(rows *sql.Rows) ([]LogEntry, []time.Time, error) {
+	defer rows.Close()
+	var (
+		entries []LogEntry
+		created []time.Time
+	)
+	for rows.Next() {
+		var at time.Time
+		e, err := scanLog(rows, &at)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries, created = append(entries, e), append(created, at)
+	}
+	return entries, created, rows.Err()
+}
+
+// files lists the sink's data files for the logs table, oldest first. The
+// sink names them <table version>/[<date>/]CDC<n>.json with zero-padded
+// numbers, so key order is write order.
+func (f *ChangeFeed) files(ctx context.Context) ([]string, error) {
+	keys, err := f.store.List(ctx, f.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, k := range keys {
+		if base := path.Base(k); strings.HasPrefix(base, "CDC") && strings.HasSuffix(base, ".json") {
+			files = append(files, k)
+		}
+	}
+	return files, nil
+}
+
+// pollSink delivers the inserts in data files written since the last one
+// seen.
+func (f *ChangeFeed) pollSink(ctx context.Context) error {
+	files, err := f.files(ctx)
+	if err != nil {
+		return err
+	}
+	for _, key := range files {
+		if key <= f.lastKey {
+			continue
+		}
+		body, err := f.store.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", key, err)
+		}
+		entries, err := parseCanalJSON(body)
+		if err != nil {
+			// Skip the file rather than stall the feed on it forever.
+			slog.Warn("Skipping change feed file", "key", key, "err", err)
+		}
+		for _, e := range entries {
+			f.deliver(e)
+		}
+		f.lastKey = key
+	}
+	return nil
+}
+
+// canalMessage is one canal-json event; every column value is a string.
+type canalMessage struct {
+	Type  string               `json:"type"` // INSERT, UPDATE, DELETE, or a DDL type
+	IsDDL bool                 `json:"isDdl"`
+	Data  []map[string]*string `json:"data"`
+}
+
+// parseCanalJSON reads the inserted rows from a file of newline-delimited
+// canal-json events. Rows that can't be read are skipped and reported.
+func parseCanalJSON(data []byte) ([]LogEntry, error) {
+	var (
+		entries []LogEntry
+		bad     int
+	)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg canalMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			bad++
+			continue
+		}
+		if msg.IsDDL || msg.Type != "INSERT" {
+			continue
+		}
+		for _, row := range msg.Data {
+			e, err := canalRowEntry(row)
+			if err != nil {
+				bad++
+				continue
+			}
+			entries = append(entries, e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return entries, err
+	}
+	if bad > 0 {
+		return entries, fmt.Errorf("%d unreadable events", bad)
+	}
+	return entries, nil
+}
+
+// canalRowEntry converts a logs row from canal-json. Rows written by other
+// services may leave out columns the ingestor always sets.
+func canalRowEntry(row map[string]*string) (LogEntry, error) {
+	col := func(name string) string {
+		if v := row[name]; v != nil {
+			return *v
+		}
+		return ""
+	}
+	var e LogEntry
+	var err error
+	if e.ID, err = strconv.ParseInt(col("id"), 10, 64); err != nil {
+		return e, fmt.Errorf("id: %w", err)
+	}
+	if e.Timestamp, err = time.Parse(time.DateTime, col("timestamp")); err != nil {
+		return e, fmt.Errorf("timestamp: %w", err)
+	}
+	e.TenantID = col("tenant_id")
+	if e.TenantID == "" {
+		e.TenantID = defaultTenant
+	}
+	e.Source = col("source")
+	e.Severity.Scan(col("severity"))
+	e.Message = col("message")
+	e.IPAddress = col("ip_address")
+	if e.Fields, err = store.DecodeFields([]byte(col("fields"))); err != nil {
+		return e, fmt.Errorf("fields: %w", err)
+	}
+	if e.Labels, err = store.DecodeFields([]byte(col("labels"))); err != nil {
+		return e, fmt.Errorf("labels: %w", err)
+	}
+	e.RiskScore, _ = strconv.Atoi(col("risk_score"))
+	return e, nil
+}
+
+// deliver streams a row from the change feed. Every replica follows the
+// feed itself, so entries aren't relayed over the cluster bus.
+func (f *ChangeFeed) deliver(entry LogEntry) {
+	f.recent.add(entry)
+	f.aggregates.observe(entry)
+	data, _ := json.Marshal(entry)
+	if f.fanout != nil {
+		f.fanout.Publish("logs", entry.TenantID, data)
+	}
+	f.hub.DeliverLog(entry, data)
+}
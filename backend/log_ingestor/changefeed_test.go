@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestChangeFeedPollLateCommit(t *testing.T) {
+	p, db := newTestPipeline(t)
+	insert := func(id int64, message string) {
+		t.Helper()
+		_, err := db.Exec(`INSERT INTO logs (id, tenant_id, timestamp, source, severity, message, ip_address) VALUES (?, ?, ?, 'Auth', 'INFO', ?, '')`,
+			id, defaultTenant, time.Now(), message)
+		if err != nil {
+			t.Fatalf("insert log %d: %v", id, err)
+		}
+	}
+	delivered := func() map[int64]int {
+		ids := map[int64]int{}
+		for _, e := range p.recent.query(defaultTenant, RecentQuery{Limit: 100}) {
+			ids[e.ID]++
+		}
+		return ids
+	}
+
+	insert(10, "before the feed started")
+	cfg := ChangeFeedConfig{Source: "poll"}.WithDefaults()
+	f, err := NewChangeFeed(db, cfg, p.hub, nil, p.recent, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := f.start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	// Row 5 commits after row 10, as when TiDB servers allocate ids from
+	// separate ranges.
+	insert(5, "committed late")
+	insert(11, "committed in order")
+	for range 2 {
+		if err := f.pollTable(ctx); err != nil {
+			t.Fatalf("pollTable: %v", err)
+		}
+	}
+	got := delivered()
+	for id, want := range map[int64]int{5: 1, 10: 0, 11: 1} {
+		if got[id] != want {
+			t.Errorf("log %d delivered %d times, want %d", id, got[id], want)
+		}
+	}
+}
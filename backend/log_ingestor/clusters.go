@@ -0,0 +1,440 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// maxCentroidWeight caps how many logs a centroid averages over, so a
+// long-lived cluster still follows its pattern's drift.
+const maxCentroidWeight = 1000
+
+// LogCluster is a group of logs with similar embeddings. Its representative
+// is the message of the log that started it.
+type LogCluster struct {
+	ID             int64     `json:"id"`
+	TenantID       string    `json:"tenant_id"`
+	Representative string    `json:"representative"`
+	Source         string    `json:"source"`
+	Size           int64     `json:"size"`            // logs assigned since the cluster started
+	Count          int64     `json:"count,omitempty"` // logs in the requested window
+	Severity       Severity  `json:"severity"`        // highest severity seen
+	FirstSeen      time.Time `json:"first_seen"`
+	LastSeen       time.Time `json:"last_seen"`
+
+	centroid []float32 // unit length
+	dirty    bool
+}
+
+// Clusterer assigns new logs to clusters in the background.
+type Clusterer struct {
+	db  *store.DB
+	hub *streamHub
+	cfg ClusteringConfig
+
+	mu       sync.Mutex
+	clusters map[string][]*LogCluster // tenant -> clusters
+	lastID   int64                    // highest log id examined
+}
+
+func NewClusterer(db *store.DB, h *streamHub, cfg ClusteringConfig) (*Clusterer, error) {
+	if !db.Vectors() {
+		return nil, fmt.Errorf("the %s store has no embeddings to cluster", db.Driver())
+	}
+	c := &Clusterer{db: db, hub: h, cfg: cfg, clusters: map[string][]*LogCluster{}}
+	rows, err := db.Query(`
+		SELECT id, tenant_id, representative, source, size, severity, first_seen, last_seen, ` + db.VectorText("centroid") + `
+		FROM log_clusters`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			lc       LogCluster
+			centroid string
+		)
+		if err := rows.Scan(&lc.ID, &lc.TenantID, &lc.Representative, &lc.Source, &lc.Size, &lc.Severity,
+			&lc.FirstSeen, &lc.LastSeen, &centroid); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(centroid), &lc.centroid); err != nil {
+			return nil, fmt.Errorf("decode centroid of cluster %d: %w", lc.ID, err)
+		}
+		c.clusters[lc.TenantID] = append(c.clusters[lc.TenantID], &lc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Resume after the last clustered log, or start with logs young enough
+	// that their clusters wouldn't already have expired.
+	var last sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(id) FROM logs WHERE cluster_id IS NOT NULL`).Scan(&last); err != nil {
+		return nil, err
+	}
+	if !last.Valid {
+		expire, _ := time.ParseDuration(cfg.ExpireAfter)
+		if err := db.QueryRow(`SELECT MIN(id) - 1 FROM logs WHERE timestamp >= ?`, time.Now().Add(-expire)).Scan(&last); err != nil {
+			return nil, err
+		}
+	}
+	c.lastID = last.Int64
+	return c, nil
+}
+
+// Run clusters new logs every interval.
+func (c *Clusterer) Run() {
+	interval, _ := time.ParseDuration(c.cfg.Interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := c.expire(time.Now()); err != nil {
+			slog.Error("Failed to expire log clusters", "err", err)
+		}
+		for {
+			n, err := c.clusterBatch()
+			if err != nil {
+				slog.Error("Failed to cluster logs", "err", err)
+				break
+			}
+			if n < c.cfg.BatchSize {
+				break
+			}
+		}
+	}
+}
+
+// clusterBatch assigns the next batch of logs and returns how many were
+// read. Logs stored without an embedding are skipped.
+func (c *Clusterer) clusterBatch() (int, error) {
+	rows, err := c.db.Query(`
+		SELECT id, tenant_id, timestamp, source, severity, message, `+c.db.VectorText("embedding")+`
+		FROM logs
+		WHERE id > ? AND deleted_at IS NULL
+		ORDER BY id
+		LIMIT ?`, c.lastID, c.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	type candidate struct {
+		entry LogEntry
+		vec   []float32
+	}
+	var (
+		batch []candidate
+		read  int
+		maxID int64
+	)
+	for rows.Next() {
+		var (
+			e   LogEntry
+			src sql.NullString
+			vec sql.NullString
+		)
+		if err := rows.Scan(&e.ID, &e.TenantID, &e.Timestamp, &src, &e.Severity, &e.Message, &vec); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		read, maxID, e.Source = read+1, e.ID, src.String
+		var v []float32
+		if !vec.Valid || json.Unmarshal([]byte(vec.String), &v) != nil || !normalize(v) {
+			continue
+		}
+		batch = append(batch, candidate{entry: e, vec: v})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	assigned := map[int64][]any{} // cluster -> log ids
+	for _, cand := range batch {
+		lc, err := c.assign(cand.entry, cand.vec)
+		if err != nil {
+			return 0, err
+		}
+		assigned[lc.ID] = append(assigned[lc.ID], cand.entry.ID)
+	}
+	for id, logIDs := range assigned {
+		if _, err := c.db.Exec(`UPDATE logs SET cluster_id = ? WHERE id IN (`+placeholders(len(logIDs))+`)`,
+			append([]any{id}, logIDs...)...); err != nil {
+			return 0, err
+		}
+	}
+	if err := c.flush(); err != nil {
+		return 0, err
+	}
+	if read > 0 {
+		c.lastID = maxID
+	}
+	return read, nil
+}
+
+// assign adds e to the most similar of its tenant's clusters, or to a new
+// one if none is similar enough. Called with c.mu held.
+func (c *Clusterer) assign(e LogEntry, vec []float32) (*LogCluster, error) {
+	var (
+		best    *LogCluster
+		bestSim = -1.0
+	)
+	for _, lc := range c.clusters[e.TenantID] {
+		if sim := dot(lc.centroid, vec); sim > bestSim {
+			best, bestSim = lc, sim
+		}
+	}
+	if best == nil || (bestSim < c.cfg.Threshold && len(c.clusters[e.TenantID]) < c.cfg.MaxClusters) {
+		return c.create(e, vec)
+	}
+
+	best.Size++
+	weight := float32(min(best.Size, maxCentroidWeight))
+	for i := range best.centroid {
+		best.centroid[i] += (vec[i] - best.centroid[i]) / weight
+	}
+	normalize(best.centroid)
+	if e.Severity > best.Severity {
+		best.Severity = e.Severity
+	}
+	if e.Timestamp.After(best.LastSeen) {
+		best.LastSeen = e.Timestamp
+	}
+	best.dirty = true
+	return best, nil
+}
+
+// create stores a new cluster seeded by e and announces it on the tenant's
+// stream.
+func (c *Clusterer) create(e LogEntry, vec []float32) (*LogCluster, error) {
+	lc := &LogCluster{
+		TenantID:       e.TenantID,
+		Representative: e.Message,
+		Source:         e.Source,
+		Size:           1,
+		Severity:       e.Severity,
+		FirstSeen:      e.Timestamp,
+		LastSeen:       e.Timestamp,
+		centroid:       vec,
+	}
+	id, err := c.db.Insert(`
+		INSERT INTO log_clusters (tenant_id, representative, source, size, severity, first_seen, last_seen, centroid)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		lc.TenantID, lc.Representative, lc.Source, lc.Size, lc.Severity, lc.FirstSeen, lc.LastSeen, store.FormatVector(vec))
+	if err != nil {
+		return nil, err
+	}
+	lc.ID = id
+	c.clusters[e.TenantID] = append(c.clusters[e.TenantID], lc)
+	c.hub.BroadcastMessage(lc.TenantID, wsMessage{Type: "cluster", Data: lc})
+	return lc, nil
+}
+
+// flush writes changed clusters. Called with c.mu held.
+func (c *Clusterer) flush() error {
+	for _, list := range c.clusters {
+		for _, lc := range list {
+			if !lc.dirty {
+				continue
+			}
+			if _, err := c.db.Exec(`
+				UPDATE log_clusters SET size = ?, severity = ?, last_seen = ?, centroid = ? WHERE id = ?`,
+				lc.Size, lc.Severity, lc.LastSeen, store.FormatVector(lc.centroid), lc.ID); err != nil {
+				return err
+			}
+			lc.dirty = false
+		}
+	}
+	return nil
+}
+
+// expire drops clusters that haven't had a log for cfg.ExpireAfter. Their
+// logs keep the cluster_id.
+func (c *Clusterer) expire(now time.Time) error {
+	d, _ := time.ParseDuration(c.cfg.ExpireAfter)
+	cutoff := now.Add(-d)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ids []any
+	for tenant, list := range c.clusters {
+		kept := list[:0]
+		for _, lc := range list {
+			if lc.LastSeen.Before(cutoff) {
+				ids = append(ids, lc.ID)
+				continue
+			}
+			kept = append(kept, lc)
+		}
+		c.clusters[tenant] = kept
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := c.db.Exec(`DELETE FROM log_clusters WHERE id IN (`+placeholders(len(ids))+`)`, ids...)
+	if err == nil {
+		slog.Info("Expired log clusters", "clusters", len(ids))
+	}
+	return err
+}
+
+// normalize scales v to unit length, reporting false for a zero vector.
+func normalize(v []float32) bool {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return false
+	}
+	n := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= n
+	}
+	return true
+}
+
+// dot is the cosine similarity of two unit vectors.
+func dot(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return -1
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// clusterColumns are the log_clusters columns read by the API, in order.
+const clusterColumns = "c.id, c.tenant_id, c.representative, c.source, c.size, c.severity, c.first_seen, c.last_seen"
+
+func scanCluster(row interface{ Scan(...any) error }, extra ...any) (LogCluster, error) {
+	var lc LogCluster
+	err := row.Scan(append([]any{&lc.ID, &lc.TenantID, &lc.Representative, &lc.Source, &lc.Size, &lc.Severity,
+		&lc.FirstSeen, &lc.LastSeen}, extra...)...)
+	return lc, err
+}
+
+// clustersHandler serves GET /api/clusters: the tenant's clusters with the
+// most logs in the window (?since=24h), optionally for one source or a
+// minimum severity, largest first.
+func clustersHandler(db *store.DB, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v := r.URL.Query()
+		since, err := time.ParseDuration(v.Get("since"))
+		if v.Get("since") == "" {
+			since, err = time.ParseDuration(cfg.DefaultWindow)
+		}
+		if err != nil || since <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid 'since'")
+			return
+		}
+		limit := cfg.DefaultPageSize
+		if s := v.Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 || n > cfg.MaxPageSize {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("'limit' must be between 1 and %d", cfg.MaxPageSize))
+				return
+			}
+			limit = n
+		}
+		where := []string{"l.tenant_id = ?", "l.deleted_at IS NULL", "l.timestamp >= ?"}
+		args := []any{tenantFromRequest(r), time.Now().Add(-since)}
+		if src := v.Get("source"); src != "" {
+			where = append(where, "c.source = ?")
+			args = append(args, src)
+		}
+		if s := v.Get("min_severity"); s != "" {
+			sev, err := ParseSeverity(s)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			var levels []any
+			for _, level := range AllSeverities {
+				if level >= sev {
+					levels = append(levels, level)
+				}
+			}
+			where = append(where, "c.severity IN ("+placeholders(len(levels))+")")
+			args = append(args, levels...)
+		}
+
+		rows, err := db.Query(`
+			SELECT `+clusterColumns+`, COUNT(*) AS n
+			FROM logs l JOIN log_clusters c ON c.id = l.cluster_id
+			WHERE `+strings.Join(where, " AND ")+`
+			GROUP BY `+clusterColumns+`
+			ORDER BY n DESC, c.id
+			LIMIT ?`, append(args, limit)...)
+		if err != nil {
+			slog.Error("Cluster query failed", "err", err)
+			writeError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		defer rows.Close()
+		clusters := []LogCluster{}
+		for rows.Next() {
+			var n int64
+			lc, err := scanCluster(rows, &n)
+			if err != nil {
+				slog.Error("Cluster query failed", "err", err)
+				writeError(w, http.StatusInternalServerError, "query failed")
+				return
+			}
+			lc.Count = n
+			clusters = append(clusters, lc)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"clusters": clusters, "since": since.String()})
+	}
+}
+
+// clusterHandler serves GET /api/clusters/{id}: the cluster and its most
+// recent logs. GET /api/logs?cluster={id} pages through the rest.
+func clusterHandler(db *store.DB, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid cluster id")
+			return
+		}
+		tenant := tenantFromRequest(r)
+		lc, err := scanCluster(db.QueryRow(`SELECT `+clusterColumns+` FROM log_clusters c WHERE c.id = ? AND c.tenant_id = ?`, id, tenant))
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "no cluster with that id")
+			return
+		}
+		if err != nil {
+			slog.Error("Cluster query failed", "err", err)
+			writeError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		rows, err := db.Query(`
+			SELECT `+logColumns+` FROM logs
+			WHERE tenant_id = ? AND cluster_id = ? AND deleted_at IS NULL
+			ORDER BY id DESC LIMIT ?`, tenant, id, cfg.DefaultPageSize)
+		if err != nil {
+			slog.Error("Cluster query failed", "err", err)
+			writeError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		logs, err := scanLogs(rows)
+		if err != nil {
+			slog.Error("Cluster query failed", "err", err)
+			writeError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"cluster": lc, "logs": logs})
+	}
+}
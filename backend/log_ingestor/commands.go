@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// runCommand dispatches a subcommand. Running the binary without one, or
+// with only server flags, serves.
+func runCommand(name string, args []string) {
+	var err error
+	switch name {
+	case "serve":
+		err = serveCommand(args)
+	case "simulate":
+		err = simulateCommand(args)
+	case "migrate":
+		err = migrateCommand(args)
+	case "export":
+		err = exportCommand(args)
+	case "replay":
+		err = replayCommand(args)
+	case "snapshot":
+		err = snapshotCommand(args)
+	case "restore":
+		err = restoreCommand(args)
+	case "keys":
+		err = keysCommand(args)
+	case "hunts":
+		err = huntsCommand(args)
+	case "archive":
+		err = archiveCommand(args)
+	case "bench":
+		err = benchCommand(args)
+	case "reembed":
+		err = reembedCommand(args)
+	case "help", "-h", "--help":
+		printUsage()
+		return
+	default:
+		printUsage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fatal("Command failed", "command", name, "err", err)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, `Usage: log_ingestor [command] [flags]
+
+Without a command, serves (flags: -config, -rate, -duration, -burst-every,
+-burst-length, -burst-multiplier).
+
+Commands:
+  serve      run the log simulator, ingest API, and WebSocket server
+  simulate   run the simulator through the ingest pipeline without serving, or -bench inserts
+  migrate    create missing tables, columns, and indexes from db/schema.sql
+  export     write logs matching GET /api/logs filters as CSV, NDJSON, or Parquet
+  replay     re-send stored logs, paced by their original timing, as NDJSON or to an ingest endpoint
+  snapshot   export a time range (schema, logs, embeddings, incidents) to an archive
+  restore    import a snapshot archive into the configured database
+  keys       manage ingest API keys (create, list, revoke, hygiene)
+  hunts      list or run the hunting query pack
+  archive    list or restore logs archived to object storage by retention
+  bench      generate load against a remote ingest endpoint and report capacity
+  reembed    fill in missing or stale log embeddings (-all recomputes every one), or -status
+
+Run 'log_ingestor <command> -h' for command flags.`)
+}
+
+// commandDB parses the shared -config flag and opens the database.
+func commandDB(fs *flag.FlagSet, args []string) (*store.DB, error) {
+	_, db, err := commandConfig(fs, args)
+	return db, err
+}
+
+// commandConfig is commandDB for commands that also need the config.
+func commandConfig(fs *flag.FlagSet, args []string) (Config, *store.DB, error) {
+	configPath := fs.String("config", "../config.yaml", "path to config file")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return config, nil, err
+	}
+	db, err := store.Open(config)
+	return config, db, err
+}
+
+// queryFlag collects repeated -filter key=value flags into GET /api/logs
+// parameters.
+type queryFlag url.Values
+
+func (q queryFlag) String() string { return url.Values(q).Encode() }
+
+func (q queryFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("filter %q must be key=value", s)
+	}
+	url.Values(q).Add(key, value)
+	return nil
+}
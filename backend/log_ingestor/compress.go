@@ -0,0 +1,168 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsCompressionLevel is applied to every live-stream connection that
+// negotiated permessage-deflate.
+var wsCompressionLevel = flate.BestSpeed
+
+// setupWSCompression enables permessage-deflate on the upgrader.
+func setupWSCompression(cfg CompressionConfig) {
+	upgrader.EnableCompression = cfg.WebSocket
+	wsCompressionLevel = cfg.Level
+}
+
+// compressConn applies the configured level; without a negotiated
+// extension, writes stay uncompressed.
+func compressConn(conn *websocket.Conn) {
+	if upgrader.EnableCompression {
+		conn.SetCompressionLevel(wsCompressionLevel)
+	}
+}
+
+// gzipHandler gzips responses under the configured endpoints for clients
+// that accept it. WebSocket upgrades pass through untouched.
+func gzipHandler(cfg CompressionConfig, next http.Handler) http.Handler {
+	if !cfg.HTTP {
+		return next
+	}
+	pool := sync.Pool{New: func() any {
+		zw, _ := gzip.NewWriterLevel(nil, cfg.Level)
+		return zw
+	}}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsGzip(r) || websocket.IsWebSocketUpgrade(r) || !cfg.Covers(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		gw := &gzipResponseWriter{ResponseWriter: w, cfg: cfg, pool: &pool}
+		defer gw.finish()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the first MinSize bytes of a response to decide
+// whether compressing it is worthwhile, then either gzips or passes through
+// the rest. A Flush, as the streaming export does, decides early.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	cfg  CompressionConfig
+	pool *sync.Pool
+
+	status  int
+	buf     []byte
+	decided bool
+	zw      *gzip.Writer // nil when passing through
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.status == 0 {
+		g.status = status
+	}
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	if !g.decided {
+		g.buf = append(g.buf, p...)
+		if len(g.buf) < g.cfg.MinSize {
+			return len(p), nil
+		}
+		if err := g.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if g.zw != nil {
+		return g.zw.Write(p)
+	}
+	return g.ResponseWriter.Write(p)
+}
+
+// decide starts the response, compressed if it's worth it, and writes what
+// was buffered.
+func (g *gzipResponseWriter) decide(large bool) error {
+	g.decided = true
+	h := g.Header()
+	compress := large && h.Get("Content-Encoding") == "" && g.status != http.StatusNoContent && g.status != http.StatusNotModified
+	for _, skip := range g.cfg.SkipTypes {
+		if strings.HasPrefix(h.Get("Content-Type"), skip) {
+			compress = false
+		}
+	}
+	if h.Get("Content-Type") == "" && len(g.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(g.buf))
+	}
+	if compress {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		g.zw = g.pool.Get().(*gzip.Writer)
+		g.zw.Reset(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+	buf := g.buf
+	g.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if g.zw != nil {
+		_, err = g.zw.Write(buf)
+	} else {
+		_, err = g.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (g *gzipResponseWriter) Flush() {
+	if !g.decided {
+		if g.status == 0 {
+			g.status = http.StatusOK
+		}
+		g.decide(true)
+	}
+	if g.zw != nil {
+		g.zw.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter { return g.ResponseWriter }
+
+// finish ends the response, sending a small one uncompressed.
+func (g *gzipResponseWriter) finish() {
+	if !g.decided {
+		if g.status == 0 {
+			return // nothing written; net/http sends the default response
+		}
+		g.decide(false)
+	}
+	if g.zw != nil {
+		g.zw.Close()
+		g.pool.Put(g.zw)
+	}
+}
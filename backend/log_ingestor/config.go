@@ -0,0 +1,67 @@
+package main
+
+import "1logx/log_ingestor/internal/config"
+
+// The config types are defined in internal/config so that other packages
+// can be built from a loaded config without depending on main.
+type (
+	APIConfig               = config.APIConfig
+	AggregatesConfig        = config.AggregatesConfig
+	ArchiveConfig           = config.ArchiveConfig
+	AuthConfig              = config.AuthConfig
+	AutoscaleConfig         = config.AutoscaleConfig
+	BreakerConfig           = config.BreakerConfig
+	BurstConfig             = config.BurstConfig
+	CanaryConfig            = config.CanaryConfig
+	ChangeFeedConfig        = config.ChangeFeedConfig
+	ClusterConfig           = config.ClusterConfig
+	ClusteringConfig        = config.ClusteringConfig
+	CompressionConfig       = config.CompressionConfig
+	Config                  = config.Config
+	DatabaseConfig          = config.DatabaseConfig
+	EmbeddingBackfillConfig = config.EmbeddingBackfillConfig
+	EmbeddingConfig         = config.EmbeddingConfig
+	ExportConfig            = config.ExportConfig
+	FanoutConfig            = config.FanoutConfig
+	ForecastConfig          = config.ForecastConfig
+	GRPCConfig              = config.GRPCConfig
+	HuntConfig              = config.HuntConfig
+	IncidentConfig          = config.IncidentConfig
+	JWTConfig               = config.JWTConfig
+	JoinConfig              = config.JoinConfig
+	LLMConfig               = config.LLMConfig
+	LimitsConfig            = config.LimitsConfig
+	LoggingConfig           = config.LoggingConfig
+	NetFlowConfig           = config.NetFlowConfig
+	ParserDefinition        = config.ParserDefinition
+	ParsersConfig           = config.ParsersConfig
+	PipelineConfig          = config.PipelineConfig
+	PipelineInput           = config.PipelineInput
+	QualityConfig           = config.QualityConfig
+	RBACConfig              = config.RBACConfig
+	RateLimit               = config.RateLimit
+	RecentConfig            = config.RecentConfig
+	RedactionConfig         = config.RedactionConfig
+	RedactionRule           = config.RedactionRule
+	ReloadConfig            = config.ReloadConfig
+	ReportsConfig           = config.ReportsConfig
+	ReputationConfig        = config.ReputationConfig
+	RetentionConfig         = config.RetentionConfig
+	RetryConfig             = config.RetryConfig
+	RiskConfig              = config.RiskConfig
+	SchemaConfig            = config.SchemaConfig
+	SearchConfig            = config.SearchConfig
+	SearchWeights           = config.SearchWeights
+	SigmaConfig             = config.SigmaConfig
+	SimulatorConfig         = config.SimulatorConfig
+	StreamConfig            = config.StreamConfig
+	TLSConfig               = config.TLSConfig
+	TenancyConfig           = config.TenancyConfig
+	Tenant                  = config.Tenant
+	ThreatFeed              = config.ThreatFeed
+	ThreatIntelConfig       = config.ThreatIntelConfig
+)
+
+const defaultTenant = config.DefaultTenant
+
+func loadConfig(path string) (Config, error) { return config.Load(path) }
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/pipeline"
+	"1logx/log_ingestor/internal/store"
+)
+
+// Correlation is a completed join: the logs it linked across sources, and
+// the correlated incident event ingested for it.
+type Correlation struct {
+	ID         int64             `json:"id"`
+	TenantID   string            `json:"tenant_id"`
+	RuleID     string            `json:"rule_id"`
+	Title      string            `json:"title"`
+	Key        string            `json:"key"` // the value the logs share, e.g. an IP address
+	Window     string            `json:"-"`   // the rule's, for its alert
+	Severity   Severity          `json:"severity"`
+	EventLogID int64             `json:"event_log_id,omitempty"`
+	FirstSeen  time.Time         `json:"first_seen"`
+	LastSeen   time.Time         `json:"last_seen"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Links      []CorrelationLink `json:"links,omitempty"`
+	Event      *LogEntry         `json:"event,omitempty"` // on GET /api/correlations/{id}
+}
+
+// CorrelationLink is one log in a correlation's chain. Each links from the
+// log before it, so the chain reads as the order events happened in.
+type CorrelationLink struct {
+	Position   int       `json:"position"`
+	Step       string    `json:"step"`
+	LogID      int64     `json:"log_id"`
+	Source     string    `json:"source"`
+	Severity   Severity  `json:"severity"`
+	Timestamp  time.Time `json:"timestamp"`
+	LinkedFrom int64     `json:"linked_from,omitempty"`
+	Log        *LogEntry `json:"log,omitempty"` // on GET /api/correlations/{id}; nil once purged
+}
+
+// newCorrelation builds the correlation for a completed join, its chain in
+// time order.
+func newCorrelation(r *JoinRule, tenant, key string, chain []joinOccurrence) Correlation {
+	c := Correlation{
+		TenantID:  tenant,
+		RuleID:    r.ID,
+		Title:     fmt.Sprintf("%s (%s)", r.Name, key),
+		Key:       key,
+		Window:    r.Window,
+		CreatedAt: time.Now(),
+	}
+	top := SeverityInfo
+	for i, o := range chain {
+		c.Links = append(c.Links, CorrelationLink{Step: r.Steps[i].Name, LogID: o.LogID, Source: o.Source, Severity: o.Severity, Timestamp: o.Timestamp})
+		top = max(top, o.Severity)
+	}
+	slices.SortStableFunc(c.Links, func(a, b CorrelationLink) int { return a.Timestamp.Compare(b.Timestamp) })
+	for i := range c.Links {
+		c.Links[i].Position = i
+		if i > 0 {
+			c.Links[i].LinkedFrom = c.Links[i-1].LogID
+		}
+	}
+	c.FirstSeen, c.LastSeen = c.Links[0].Timestamp, c.Links[len(c.Links)-1].Timestamp
+	c.Severity = escalatedSeverity(r.Severity, top)
+	return c
+}
+
+// escalatedSeverity is one level above the most severe linked event, since
+// together they say more than any one of them, or the rule's severity if
+// that's higher.
+func escalatedSeverity(rule, top Severity) Severity {
+	return max(rule, min(top+1, SeverityCritical))
+}
+
+// correlate stores c with its links, ingests its correlated incident event,
+// and raises it as an alert.
+func (j *JoinEngine) correlate(c Correlation) {
+	if err := storeCorrelation(j.db, &c); err != nil {
+		slog.Warn("Failed to store correlation", "title", c.Title, "err", err)
+	}
+	if j.emit != nil {
+		id := c.ID
+		event, err := j.emit(j.correlationEvent(c), func(event LogEntry) { j.linkEvent(id, event.ID) })
+		switch {
+		case err == nil:
+			c.EventLogID = event.ID
+			j.linkEvent(c.ID, event.ID)
+		case errors.Is(err, pipeline.ErrQueuedForRetry):
+			// Linked once the retry queue stores it.
+		default:
+			slog.Warn("Failed to ingest correlated incident event", "title", c.Title, "err", err)
+		}
+	}
+	j.alerts.Raise(joinAlert(c))
+}
+
+// linkEvent records eventID as correlation id's incident event.
+func (j *JoinEngine) linkEvent(id, eventID int64) {
+	if id == 0 {
+		return
+	}
+	if _, err := j.db.Exec(`UPDATE correlations SET event_log_id = ? WHERE id = ?`, eventID, id); err != nil {
+		slog.Warn("Failed to link correlated incident event", "correlation", id, "err", err)
+	}
+}
+
+// correlationEvent is the log entry standing for c: the chain summed up in
+// one event at c's escalated severity, timed at its last link.
+func (j *JoinEngine) correlationEvent(c Correlation) LogEntry {
+	sources := make([]string, len(c.Links))
+	logIDs := make([]string, len(c.Links))
+	for i, l := range c.Links {
+		sources[i] = l.Source
+		logIDs[i] = strconv.FormatInt(l.LogID, 10)
+	}
+	e := LogEntry{
+		TenantID:  c.TenantID,
+		Timestamp: c.LastSeen,
+		Source:    j.cfg.Source,
+		Severity:  c.Severity,
+		Message:   fmt.Sprintf("Correlated incident: %s: %s", c.Title, strings.Join(sources, " -> ")),
+		Fields: map[string]string{
+			"rule":    c.RuleID,
+			"key":     c.Key,
+			"log_ids": strings.Join(logIDs, ","),
+		},
+	}
+	if c.ID != 0 {
+		e.Fields["correlation_id"] = strconv.FormatInt(c.ID, 10)
+	}
+	if net.ParseIP(c.Key) != nil {
+		e.IPAddress = c.Key
+	}
+	return e
+}
+
+// storeCorrelation inserts c and its links in one transaction and sets its
+// ID.
+func storeCorrelation(db *store.DB, c *Correlation) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	id, err := tx.Insert(`
+		INSERT INTO correlations (tenant_id, rule_id, title, join_key, severity, first_seen, last_seen, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.TenantID, c.RuleID, c.Title, c.Key, c.Severity, c.FirstSeen, c.LastSeen, c.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	for _, l := range c.Links {
+		var from any
+		if l.LinkedFrom != 0 {
+			from = l.LinkedFrom
+		}
+		_, err := tx.Exec(`
+			INSERT INTO correlation_links (correlation_id, tenant_id, position, step, log_id, source, severity, timestamp, linked_from)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, c.TenantID, l.Position, l.Step, l.LogID, l.Source, l.Severity, l.Timestamp, from,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	c.ID = id
+	return nil
+}
+
+const correlationColumns = "id, tenant_id, rule_id, title, join_key, severity, event_log_id, first_seen, last_seen, created_at"
+
+func scanCorrelation(row interface{ Scan(...any) error }) (Correlation, error) {
+	var (
+		c     Correlation
+		event sql.NullInt64
+	)
+	err := row.Scan(&c.ID, &c.TenantID, &c.RuleID, &c.Title, &c.Key, &c.Severity, &event, &c.FirstSeen, &c.LastSeen, &c.CreatedAt)
+	c.EventLogID = event.Int64
+	return c, err
+}
+
+// loadCorrelation reads one of the tenant's correlations with its chain,
+// including the linked logs that haven't been purged.
+func loadCorrelation(db *store.DB, tenant string, id int64) (Correlation, error) {
+	c, err := scanCorrelation(db.QueryRow(`SELECT `+correlationColumns+` FROM correlations WHERE tenant_id = ? AND id = ?`, tenant, id))
+	if err != nil {
+		return c, err
+	}
+	rows, err := db.Query(`
+		SELECT position, step, log_id, source, severity, timestamp, linked_from
+		FROM correlation_links
+		WHERE correlation_id = ?
+		ORDER BY position`, id)
+	if err != nil {
+		return c, err
+	}
+	defer rows.Close()
+	ids := []any{tenant}
+	for rows.Next() {
+		var (
+			l    CorrelationLink
+			from sql.NullInt64
+		)
+		if err := rows.Scan(&l.Position, &l.Step, &l.LogID, &l.Source, &l.Severity, &l.Timestamp, &from); err != nil {
+			return c, err
+		}
+		l.LinkedFrom = from.Int64
+		c.Links = append(c.Links, l)
+		ids = append(ids, l.LogID)
+	}
+	if err := rows.Err(); err != nil {
+		return c, err
+	}
+	if c.EventLogID != 0 {
+		ids = append(ids, c.EventLogID)
+	}
+	if len(ids) == 1 {
+		return c, nil
+	}
+
+	logRows, err := db.Query(`SELECT `+logColumns+` FROM logs
+		WHERE tenant_id = ? AND deleted_at IS NULL AND id IN (`+placeholders(len(ids)-1)+`)`, ids...)
+	if err != nil {
+		return c, err
+	}
+	logs, err := scanLogs(logRows)
+	if err != nil {
+		return c, err
+	}
+	byID := map[int64]*LogEntry{}
+	for i := range logs {
+		byID[logs[i].ID] = &logs[i]
+	}
+	for i := range c.Links {
+		c.Links[i].Log = byID[c.Links[i].LogID]
+	}
+	c.Event = byID[c.EventLogID]
+	return c, nil
+}
+
+// --- HTTP Handlers ---
+
+// correlationsHandler serves GET /api/correlations?rule=&log_id=&limit=,
+// newest first. log_id lists the correlations a log is linked into.
+func correlationsHandler(db *store.DB, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limit := cfg.DefaultPageSize
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > cfg.MaxPageSize {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("'limit' must be between 1 and %d", cfg.MaxPageSize))
+				return
+			}
+			limit = n
+		}
+		tenant := tenantFromRequest(r)
+		where := []string{"tenant_id = ?"}
+		args := []any{tenant}
+		if rule := q.Get("rule"); rule != "" {
+			where = append(where, "rule_id = ?")
+			args = append(args, rule)
+		}
+		if v := q.Get("log_id"); v != "" {
+			logID, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid 'log_id'")
+				return
+			}
+			where = append(where, "(event_log_id = ? OR id IN (SELECT correlation_id FROM correlation_links WHERE tenant_id = ? AND log_id = ?))")
+			args = append(args, logID, tenant, logID)
+		}
+		rows, err := db.Query(`
+			SELECT `+correlationColumns+`
+			FROM correlations
+			WHERE `+strings.Join(where, " AND ")+`
+			ORDER BY created_at DESC, id DESC
+			LIMIT ?`, append(args, limit)...)
+		if err != nil {
+			slog.Error("Failed to list correlations", "err", err)
+			writeError(w, http.StatusInternalServerError, "failed to list correlations")
+			return
+		}
+		defer rows.Close()
+		correlations := []Correlation{}
+		for rows.Next() {
+			c, err := scanCorrelation(rows)
+			if err != nil {
+				slog.Error("Failed to read correlation", "err", err)
+				writeError(w, http.StatusInternalServerError, "failed to list correlations")
+				return
+			}
+			correlations = append(correlations, c)
+		}
+		writeJSON(w, http.StatusOK, correlations)
+	}
+}
+
+// correlationHandler serves GET /api/correlations/{id}: the correlation with
+// its chain of contributing logs and its correlated incident event.
+func correlationHandler(db *store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid correlation id")
+			return
+		}
+		c, err := loadCorrelation(db, tenantFromRequest(r), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "correlation not found")
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to load correlation", "id", id, "err", err)
+			writeError(w, http.StatusInternalServerError, "failed to load correlation")
+			return
+		}
+		writeJSON(w, http.StatusOK, c)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// defaultSigmaRules are example Sigma rules for the simulator's traffic.
+//
+//go:embed sigma/*.yml
+var defaultSigmaRules embed.FS
+
+// sigmaSkip is a rule file that couldn't be loaded.
+type sigmaSkip struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+}
+
+// loadSigmaRules reads every .yml/.yaml rule under paths, or the built-in
+// rules. Rules that fail to parse or use unsupported features are skipped
+// and reported, since community rulesets always contain some.
+func loadSigmaRules(paths []string) ([]*SigmaRule, []sigmaSkip, error) {
+	var (
+		rules   []*SigmaRule
+		skipped []sigmaSkip
+	)
+	seen := map[string]bool{}
+	add := func(file string, data []byte) {
+		r, err := parseSigmaRule(data)
+		switch {
+		case err != nil:
+			skipped = append(skipped, sigmaSkip{File: file, Reason: err.Error()})
+		case r.Status == "deprecated" || r.Status == "unsupported":
+			skipped = append(skipped, sigmaSkip{File: file, Reason: "status " + r.Status})
+		case seen[r.ID]:
+			skipped = append(skipped, sigmaSkip{File: file, Reason: "duplicate id " + r.ID})
+		default:
+			seen[r.ID] = true
+			r.file = file
+			rules = append(rules, r)
+		}
+	}
+	isRule := func(name string) bool {
+		ext := strings.ToLower(filepath.Ext(name))
+		return ext == ".yml" || ext == ".yaml"
+	}
+
+	if len(paths) == 0 {
+		err := fs.WalkDir(defaultSigmaRules, "sigma", func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !isRule(p) {
+				return err
+			}
+			data, err := defaultSigmaRules.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			add(p, data)
+			return nil
+		})
+		return rules, skipped, err
+	}
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !isRule(p) {
+				return nil
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			add(p, data)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("load sigma rules: %w", err)
+		}
+	}
+	return rules, skipped, nil
+}
+
+// Detection is a Sigma rule matching a stored log.
+type Detection struct {
+	ID        int64     `json:"id,omitempty"`
+	TenantID  string    `json:"tenant_id"`
+	RuleID    string    `json:"rule_id"`
+	Title     string    `json:"title"`
+	Level     string    `json:"level"`
+	Severity  Severity  `json:"severity"`
+	Tags      []string  `json:"tags,omitempty"`
+	LogID     int64     `json:"log_id"`
+	Source    string    `json:"source"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SigmaEngine evaluates the loaded rules against every stored log.
+type SigmaEngine struct {
+	db  *store.DB
+	hub *streamHub
+
+	mu      sync.RWMutex // guards the fields below, which reload swaps
+	cfg     SigmaConfig
+	rules   []*SigmaRule
+	skipped []sigmaSkip
+	matched map[string]int64 // per rule, since startup
+}
+
+func NewSigmaEngine(db *store.DB, h *streamHub, cfg SigmaConfig) (*SigmaEngine, error) {
+	s := &SigmaEngine{db: db, hub: h, matched: map[string]int64{}}
+	return s, s.reload(cfg)
+}
+
+// reload swaps in the rules under cfg.Rules. Rules that can't be loaded
+// are skipped, but a missing path keeps the current rules.
+func (s *SigmaEngine) reload(cfg SigmaConfig) error {
+	rules, skipped, err := loadSigmaRules(cfg.Rules)
+	if err != nil {
+		return err
+	}
+	for _, sk := range skipped {
+		slog.Warn("Skipping Sigma rule", "file", sk.File, "reason", sk.Reason)
+	}
+	slog.Info("Loaded Sigma rules", "rules", len(rules), "skipped", len(skipped))
+	s.mu.Lock()
+	s.cfg, s.rules, s.skipped = cfg, rules, skipped
+	s.mu.Unlock()
+	return nil
+}
+
+// appliesTo reports whether r's logsource covers entries from source.
+func (s *SigmaEngine) appliesTo(r *SigmaRule, source string) bool {
+	check := func(kind, name string, fallback bool) bool {
+		if name == "" {
+			return true
+		}
+		if sources, ok := s.cfg.LogSources[kind+":"+name]; ok {
+			for _, src := range sources {
+				if strings.EqualFold(src, source) {
+					return true
+				}
+			}
+			return false
+		}
+		return !fallback || strings.EqualFold(name, source)
+	}
+	ls := r.LogSource
+	return check("product", ls.Product, false) && check("service", ls.Service, true) && check("category", ls.Category, true)
+}
+
+// Observe evaluates every rule against a stored entry, recording and
+// streaming each match.
+func (s *SigmaEngine) Observe(e LogEntry) {
+	if s == nil {
+		return
+	}
+	tenant := e.TenantID
+	if tenant == "" {
+		tenant = defaultTenant
+	}
+	var hits []Detection
+
+	s.mu.Lock()
+	ev := &sigmaEvent{entry: e, fieldMap: s.cfg.FieldMap}
+	for _, r := range s.rules {
+		if !s.appliesTo(r, e.Source) || !r.match(ev) {
+			continue
+		}
+		s.matched[r.ID]++
+		hits = append(hits, Detection{
+			TenantID:  tenant,
+			RuleID:    r.ID,
+			Title:     r.Title,
+			Level:     r.Level,
+			Severity:  sigmaLevels[r.Level],
+			Tags:      r.Tags,
+			LogID:     e.ID,
+			Source:    e.Source,
+			IPAddress: e.IPAddress,
+			CreatedAt: time.Now(),
+		})
+	}
+	s.mu.Unlock()
+
+	for _, d := range hits {
+		s.record(d)
+	}
+}
+
+// record stores d and streams it to the tenant's clients.
+func (s *SigmaEngine) record(d Detection) {
+	tags, _ := json.Marshal(d.Tags)
+	var err error
+	d.ID, err = s.db.Insert(`
+		INSERT INTO detections (tenant_id, rule_id, title, level, severity, tags, log_id, source, ip_address, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.TenantID, d.RuleID, d.Title, d.Level, d.Severity, string(tags), d.LogID, d.Source, d.IPAddress, d.CreatedAt,
+	)
+	if err != nil {
+		slog.Warn("Failed to store detection", "title", d.Title, "err", err)
+	}
+	slog.Info("Sigma rule matched", "level", d.Level, "title", d.Title, "log_id", d.LogID, "tenant", d.TenantID)
+	s.hub.BroadcastMessage(d.TenantID, wsMessage{Type: "detection", Data: d})
+}
+
+// rulesHandler serves GET /api/sigma/rules: the loaded rules with how
+// often each matched, and the rules that were skipped.
+func (s *SigmaEngine) rulesHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type ruleStatus struct {
+		*SigmaRule
+		Matched int64 `json:"matched"`
+	}
+	rules := make([]ruleStatus, 0, len(s.rules))
+	for _, rule := range s.rules {
+		rules = append(rules, ruleStatus{SigmaRule: rule, Matched: s.matched[rule.ID]})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Matched > rules[j].Matched })
+	skipped := s.skipped
+	if skipped == nil {
+		skipped = []sigmaSkip{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"rules": rules, "skipped": skipped})
+}
+
+// detectionsHandler serves GET /api/detections?rule=&level=&limit=, newest
+// first.
+func detectionsHandler(db *store.DB, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limit := cfg.DefaultPageSize
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > cfg.MaxPageSize {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("'limit' must be between 1 and %d", cfg.MaxPageSize))
+				return
+			}
+			limit = n
+		}
+		where := []string{"tenant_id = ?"}
+		args := []any{tenantFromRequest(r)}
+		if rule := q.Get("rule"); rule != "" {
+			where = append(where, "rule_id = ?")
+			args = append(args, rule)
+		}
+		if level := q.Get("level"); level != "" {
+			if _, ok := sigmaLevels[level]; !ok {
+				writeError(w, http.StatusBadRequest, "invalid 'level'")
+				return
+			}
+			where = append(where, "level = ?")
+			args = append(args, level)
+		}
+		rows, err := db.Query(`
+			SELECT id, tenant_id, rule_id, title, level, severity, tags, log_id, source, COALESCE(ip_address, ''), created_at
+			FROM detections
+			WHERE `+strings.Join(where, " AND ")+`
+			ORDER BY created_at DESC, id DESC
+			LIMIT ?`, append(args, limit)...)
+		if err != nil {
+			slog.Error("Failed to list detections", "err", err)
+			writeError(w, http.StatusInternalServerError, "failed to list detections")
+			return
+		}
+		defer rows.Close()
+		detections := []Detection{}
+		for rows.Next() {
+			var (
+				d    Detection
+				tags []byte
+			)
+			if err := rows.Scan(&d.ID, &d.TenantID, &d.RuleID, &d.Title, &d.Level, &d.Severity, &tags, &d.LogID, &d.Source, &d.IPAddress, &d.CreatedAt); err != nil {
+				slog.Error("Failed to read detection", "err", err)
+				writeError(w, http.StatusInternalServerError, "failed to list detections")
+				return
+			}
+			json.Unmarshal(tags, &d.Tags)
+			detections = append(detections, d)
+		}
+		writeJSON(w, http.StatusOK, detections)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+
+	"github.com/gorilla/websocket"
+)
+
+// ClientInfo describes a connected live-stream client.
+type ClientInfo struct {
+	ID            uint64                  `json:"id"`
+	TenantID      string                  `json:"tenant_id"`
+	Identity      string                  `json:"identity"`
+	RemoteAddr    string                  `json:"remote_addr"`
+	ConnectedAt   time.Time               `json:"connected_at"`
+	Subscriptions map[string]StreamFilter `json:"subscriptions"`
+	Paused        bool                    `json:"paused,omitempty"`
+	Queued        int                     `json:"queued"` // messages waiting in the send buffer
+}
+
+// listClientsHandler serves GET /api/admin/stream/clients: the caller's
+// tenant's clients on this replica, oldest first.
+func listClientsHandler(h *streamHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := tenantFromRequest(r)
+		list := []ClientInfo{}
+		for _, c := range h.Clients() {
+			if c.tenantID != tenant || c.stats == nil {
+				continue
+			}
+			list = append(list, ClientInfo{
+				ID:            c.stats.id,
+				TenantID:      c.tenantID,
+				Identity:      c.stats.identity,
+				RemoteAddr:    c.stats.remoteAddr,
+				ConnectedAt:   c.stats.connectedAt,
+				Subscriptions: c.subs.list(),
+				Paused:        c.paused.Load(),
+				Queued:        len(c.out),
+			})
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		writeJSON(w, http.StatusOK, map[string]any{"clients": list, "connections": h.Connections()})
+	}
+}
+
+// disconnectClientHandler serves DELETE /api/admin/stream/clients/{id},
+// closing the connection once its send buffer is flushed.
+func disconnectClientHandler(db *store.DB, h *streamHub, cfg StreamConfig) http.HandlerFunc {
+	timeout, _ := time.ParseDuration(cfg.DrainTimeout)
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid client id")
+			return
+		}
+		tenant := tenantFromRequest(r)
+		var client *wsClient
+		for _, c := range h.Clients() {
+			if c.stats != nil && c.stats.id == id && c.tenantID == tenant {
+				client = c
+				break
+			}
+		}
+		if client == nil {
+			writeError(w, http.StatusNotFound, "no client with that id")
+			return
+		}
+
+		go client.close(websocket.ClosePolicyViolation, "disconnected by an administrator", timeout)
+		recordAudit(db, tenant, requestActor(r), "stream.disconnect", map[string]any{
+			"id": id, "identity": client.stats.identity, "remote_addr": client.stats.remoteAddr,
+		})
+		writeJSON(w, http.StatusOK, map[string]any{"disconnected": id})
+	}
+}
+
+// drainClients refuses new connections and closes every client with "going
+// away", so dashboards reconnect to another replica, waiting up to timeout
+// for their buffers to flush.
+func drainClients(h *streamHub, timeout time.Duration) {
+	draining := h.Drain()
+
+	slog.Info("Draining live-stream clients", "clients", len(draining), "timeout", timeout)
+	var wg sync.WaitGroup
+	for _, c := range draining {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.close(websocket.CloseGoingAway, "server shutting down", timeout)
+		}()
+	}
+	wg.Wait()
+}
+
+// watchShutdown drains the hub and exits on SIGTERM or SIGINT.
+func watchShutdown(h *streamHub, cfg StreamConfig) {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	sig := <-stop
+	slog.Info("Shutting down", "signal", sig.String())
+	timeout, _ := time.ParseDuration(cfg.DrainTimeout)
+	drainClients(h, timeout)
+	os.Exit(0)
+}
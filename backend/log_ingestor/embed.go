@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Embedder turns text into a fixed-size vector.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+func newEmbedder(cfg EmbeddingConfig) (Embedder, error) {
+	switch cfg.Provider {
+	case "hash":
+		return hashEmbedder{dims: cfg.Dims}, nil
+	case "openai":
+		if cfg.URL == "" || cfg.Model == "" {
+			return nil, fmt.Errorf("embedding provider %q needs url and model", cfg.Provider)
+		}
+		timeout, _ := time.ParseDuration(cfg.Timeout)
+		return &httpEmbedder{cfg: cfg, client: &http.Client{Timeout: timeout}}, nil
+	}
+	return nil, fmt.Errorf("unknown embedding provider %q", cfg.Provider)
+}
+
+// hashEmbedder hashes words and word pairs into a normalized vector. It
+// needs no model, so texts sharing vocabulary score as similar but synonyms
+// don't; configure a real provider for true semantic search.
+type hashEmbedder struct {
+	dims int
+}
+
+func (h hashEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	vec := make([]float32, h.dims)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	add := func(feature string, weight float32) {
+		f := fnv.New64a()
+		f.Write([]byte(feature))
+		sum := f.Sum64()
+		sign := float32(1)
+		if sum&1 == 1 {
+			sign = -1
+		}
+		vec[(sum>>1)%uint64(h.dims)] += sign * weight
+	}
+	for i, w := range words {
+		add(w, 1)
+		if i > 0 {
+			add(words[i-1]+" "+w, 0.5)
+		}
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vec {
+			vec[i] *= scale
+		}
+	}
+	return vec, nil
+}
+
+// httpEmbedder calls an OpenAI-compatible embeddings endpoint.
+type httpEmbedder struct {
+	cfg    EmbeddingConfig
+	client *http.Client
+}
+
+func (e *httpEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	body, err := json.Marshal(map[string]any{"model": e.cfg.Model, "input": text, "dimensions": e.cfg.Dims})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(e.cfg.URL, "/")+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.cfg.APIKey)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("embeddings API returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var out struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode embeddings response: %w", err)
+	}
+	if len(out.Data) == 0 {
+		return nil, fmt.Errorf("embeddings API returned no data")
+	}
+	if n := len(out.Data[0].Embedding); n != e.cfg.Dims {
+		return nil, fmt.Errorf("embeddings API returned %d dimensions, expected %d", n, e.cfg.Dims)
+	}
+	return out.Data[0].Embedding, nil
+}
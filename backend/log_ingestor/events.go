@@ -0,0 +1,25 @@
+package main
+
+import "1logx/events"
+
+// The event types are defined in the shared 1logx/events module so that Go
+// consumers of the stream use the same definitions as the ingestor.
+type (
+	LogEntry = events.LogEntry
+	Alert    = events.Alert
+	Incident = events.Incident
+	Severity = events.Severity
+)
+
+const (
+	SeverityInfo     = events.SeverityInfo
+	SeverityWarning  = events.SeverityWarning
+	SeverityAlert    = events.SeverityAlert
+	SeverityCritical = events.SeverityCritical
+)
+
+var AllSeverities = events.AllSeverities
+
+func ParseSeverity(s string) (Severity, error) { return events.ParseSeverity(s) }
+
+func NormalizeSeverity(s string) Severity { return events.NormalizeSeverity(s) }
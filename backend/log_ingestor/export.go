@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// logExporter writes logs in one export format.
+type logExporter interface {
+	Write(LogEntry) error
+	Close() error
+}
+
+var exportFormats = map[string]struct {
+	contentType string
+	open        func(w io.Writer, cfg ExportConfig) (logExporter, error)
+}{
+	"csv":     {"text/csv; charset=utf-8", newCSVExporter},
+	"ndjson":  {"application/x-ndjson", newNDJSONExporter},
+	"parquet": {"application/vnd.apache.parquet", func(w io.Writer, cfg ExportConfig) (logExporter, error) { return newParquetWriter(w, cfg.RowGroupSize) }},
+}
+
+var exportCSVHeader = []string{"id", "tenant_id", "timestamp", "source", "severity", "message", "ip_address", "fields", "labels", "risk_score"}
+
+type csvExporter struct{ w *csv.Writer }
+
+func newCSVExporter(w io.Writer, _ ExportConfig) (logExporter, error) {
+	c := &csvExporter{w: csv.NewWriter(w)}
+	return c, c.w.Write(exportCSVHeader)
+}
+
+func (c *csvExporter) Write(e LogEntry) error {
+	return c.w.Write([]string{
+		strconv.FormatInt(e.ID, 10),
+		e.TenantID,
+		e.Timestamp.UTC().Format(time.RFC3339Nano),
+		e.Source,
+		e.Severity.String(),
+		e.Message,
+		e.IPAddress,
+		string(jsonColumn(e.Fields)),
+		string(jsonColumn(e.Labels)),
+		strconv.Itoa(e.RiskScore),
+	})
+}
+
+func (c *csvExporter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonExporter struct{ enc *json.Encoder }
+
+func newNDJSONExporter(w io.Writer, _ ExportConfig) (logExporter, error) {
+	return &ndjsonExporter{enc: json.NewEncoder(w)}, nil
+}
+
+func (n *ndjsonExporter) Write(e LogEntry) error { return n.enc.Encode(e) }
+func (n *ndjsonExporter) Close() error           { return nil }
+
+// flushWriter flushes the response every so many rows so the export streams
+// with chunked transfer instead of sitting in the server's buffer.
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (f flushWriter) Write(p []byte) (int, error) { return f.w.Write(p) }
+
+func (f flushWriter) flush() {
+	if f.flusher != nil {
+		f.flusher.Flush()
+	}
+}
+
+// exportLogs streams the logs matching q, oldest first, into exp. Rows are
+// read and written one at a time; only Parquet buffers a row group.
+func exportLogs(ctx context.Context, db *store.DB, q LogQuery, exp logExporter, out flushWriter) (int, error) {
+	where, args := q.conditions(db)
+	if q.Cursor > 0 {
+		where = append(where, "id < ?")
+		args = append(args, q.Cursor)
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+logColumns+`
+		FROM logs
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY timestamp, id
+		LIMIT ?`, append(args, q.Limit)...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		e, err := scanLog(rows)
+		if err != nil {
+			return n, err
+		}
+		if err := exp.Write(e); err != nil {
+			return n, err
+		}
+		if n++; n%1000 == 0 {
+			out.flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	return n, exp.Close()
+}
+
+// exportHandler serves GET /api/export?format=csv|ndjson|parquet with the
+// filters and cost limit of GET /api/logs. 'limit' caps the rows exported,
+// up to export.max_rows, instead of paging.
+func exportHandler(db *store.DB, api APIConfig, cfg ExportConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v := r.URL.Query()
+		format := strings.ToLower(v.Get("format"))
+		if format == "" {
+			format = "ndjson"
+		}
+		f, ok := exportFormats[format]
+		if !ok {
+			writeError(w, http.StatusBadRequest, "'format' must be csv, ndjson, or parquet")
+			return
+		}
+
+		limit := cfg.MaxRows
+		if s := v.Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid 'limit': %q", s))
+				return
+			}
+			limit = min(n, cfg.MaxRows)
+			v.Del("limit")
+		}
+		q, err := parseLogQuery(v, api)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		q.TenantID = tenantFromRequest(r)
+		q.Limit = limit
+
+		if cost := q.EstimateCost(); cost > api.MaxQueryCost {
+			if !q.Force {
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+					"error":          "export too expensive; narrow the time range, add filters, or pass force=true",
+					"estimated_cost": cost,
+					"max_cost":       api.MaxQueryCost,
+				})
+				return
+			}
+			slog.Warn("Forced expensive export", "remote", r.RemoteAddr, "cost", cost, "max_cost", api.MaxQueryCost)
+		}
+
+		timeout, _ := time.ParseDuration(cfg.Timeout)
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		name := fmt.Sprintf("logs-%s-%s.%s", q.From.UTC().Format("20060102T150405Z"), q.To.UTC().Format("20060102T150405Z"), format)
+		w.Header().Set("Content-Type", f.contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		flusher, _ := w.(http.Flusher)
+		out := flushWriter{w: w, flusher: flusher}
+
+		exp, err := f.open(out, cfg)
+		if err != nil {
+			slog.Error("Export failed to start", "err", err)
+			return
+		}
+		// Once streaming has started the status is sent; a failure can only
+		// cut the body short, which clients see as a truncated download.
+		n, err := exportLogs(ctx, db, q, exp, out)
+		if err != nil {
+			slog.Error("Export failed", "rows", n, "err", err)
+			return
+		}
+		out.flush()
+
+		tenant := tenantFromRequest(r)
+		recordAudit(db, tenant, requestActor(r), "logs.export", map[string]any{
+			"format": format, "rows": n, "from": q.From, "to": q.To, "query": r.URL.RawQuery,
+		})
+		slog.Info("Exported logs", "rows", n, "format", format, "tenant", tenant)
+	}
+}
+
+// exportCommand writes the logs matching GET /api/logs filters to a file or
+// stdout, under the same row and cost limits as GET /api/export.
+func exportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	filters := queryFlag{}
+	fs.Var(filters, "filter", "GET /api/logs parameter as key=value, e.g. severity=ALERT (repeatable)")
+	from := fs.String("from", "", "start of time range, RFC3339 (default api.default_window ago)")
+	to := fs.String("to", "", "end of time range, RFC3339 (default now)")
+	format := fs.String("format", "ndjson", "csv, ndjson, or parquet")
+	out := fs.String("out", "", "output file (default stdout)")
+	tenant := fs.String("tenant", defaultTenant, "tenant to export")
+	limit := fs.Int("limit", 0, "max rows (default and cap: export.max_rows)")
+	config, db, err := commandConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	f, ok := exportFormats[strings.ToLower(*format)]
+	if !ok {
+		return fmt.Errorf("-format must be csv, ndjson, or parquet")
+	}
+	cfg, api := config.Export.WithDefaults(), config.API.WithDefaults()
+	v := url.Values(filters)
+	if *from != "" {
+		v.Set("from", *from)
+	}
+	if *to != "" {
+		v.Set("to", *to)
+	}
+	q, err := parseLogQuery(v, api)
+	if err != nil {
+		return err
+	}
+	q.TenantID, q.Limit = *tenant, cfg.MaxRows
+	if *limit > 0 {
+		q.Limit = min(*limit, cfg.MaxRows)
+	}
+	if cost := q.EstimateCost(); cost > api.MaxQueryCost && !q.Force {
+		return fmt.Errorf("export too expensive (estimated cost %.0f > %.0f); narrow the range or pass -filter force=true", cost, api.MaxQueryCost)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	timeout, _ := time.ParseDuration(cfg.Timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	exp, err := f.open(w, cfg)
+	if err != nil {
+		return err
+	}
+	n, err := exportLogs(ctx, db, q, exp, flushWriter{w: w})
+	if err != nil {
+		return fmt.Errorf("export failed after %d rows: %w", n, err)
+	}
+	recordAudit(db, *tenant, "cli", "logs.export", map[string]any{
+		"format": *format, "rows": n, "from": q.From, "to": q.To, "query": v.Encode(),
+	})
+	slog.Info("Exported logs", "rows", n, "format", *format, "tenant", *tenant)
+	return nil
+}
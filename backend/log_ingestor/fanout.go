@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// eventSink is a message broker the stream is fanned out to.
+type eventSink interface {
+	Name() string
+	Publish(topic string, data []byte) error
+	Close() error
+}
+
+type redisSink struct{ client *redis.Client }
+
+func (s redisSink) Name() string { return "redis" }
+
+func (s redisSink) Publish(topic string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.client.Publish(ctx, topic, data).Err()
+}
+
+func (s redisSink) Close() error { return s.client.Close() }
+
+type natsSink struct{ conn *nats.Conn }
+
+func (s natsSink) Name() string { return "nats" }
+
+func (s natsSink) Publish(topic string, data []byte) error { return s.conn.Publish(topic, data) }
+
+func (s natsSink) Close() error {
+	s.conn.Close()
+	return nil
+}
+
+type fanoutEvent struct {
+	topic string
+	data  []byte
+}
+
+// Fanout publishes events asynchronously so a slow or unavailable broker
+// never stalls ingestion; when the queue is full, events are dropped.
+type Fanout struct {
+	prefix  string
+	sinks   []eventSink
+	queue   chan fanoutEvent
+	dropped atomic.Int64
+}
+
+// NewFanout connects the configured sinks. It returns nil if none are enabled.
+func NewFanout(cfg FanoutConfig) (*Fanout, error) {
+	var sinks []eventSink
+	if cfg.Redis.Enabled {
+		client := redis.NewClient(&redis.Options{Addr: cfg.Redis.Addr, Password: cfg.Redis.Password, DB: cfg.Redis.DB})
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := client.Ping(ctx).Err()
+		cancel()
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("connect to redis at %s: %w", cfg.Redis.Addr, err)
+		}
+		sinks = append(sinks, redisSink{client})
+	}
+	if cfg.NATS.Enabled {
+		conn, err := nats.Connect(cfg.NATS.URL, nats.Name("1l0gx-ingestor"), nats.MaxReconnects(-1))
+		if err != nil {
+			for _, s := range sinks {
+				s.Close()
+			}
+			return nil, fmt.Errorf("connect to nats at %s: %w", cfg.NATS.URL, err)
+		}
+		sinks = append(sinks, natsSink{conn})
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return &Fanout{prefix: cfg.Prefix, sinks: sinks, queue: make(chan fanoutEvent, cfg.QueueSize)}, nil
+}
+
+// Run delivers queued events to every sink, and reports drops periodically.
+func (f *Fanout) Run() {
+	report := time.NewTicker(time.Minute)
+	defer report.Stop()
+	for {
+		select {
+		case ev := <-f.queue:
+			for _, s := range f.sinks {
+				if err := s.Publish(ev.topic, ev.data); err != nil {
+					slog.Warn("Failed to publish to fan-out sink", "sink", s.Name(), "err", err)
+				}
+			}
+		case <-report.C:
+			if n := f.dropped.Swap(0); n > 0 {
+				slog.Warn("Fan-out queue full; dropped events in the last minute", "dropped", n)
+			}
+		}
+	}
+}
+
+// Publish queues an event of the given type for tenantID.
+func (f *Fanout) Publish(eventType, tenantID string, data []byte) {
+	select {
+	case f.queue <- fanoutEvent{topic: f.prefix + "." + tenantID + "." + eventType, data: data}:
+	default:
+		f.dropped.Add(1)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// holtWinters is additive triple exponential smoothing. With fewer than two
+// full seasons of data it degrades to Holt's linear (double) smoothing.
+type holtWinters struct {
+	alpha, beta, gamma float64
+	season             int
+}
+
+type hwFit struct {
+	level, trend float64
+	seasonal     []float64 // nil when fitted without seasonality
+	trends       []float64 // trend after each observation
+	n            int
+}
+
+func (hw holtWinters) fit(y []float64) hwFit {
+	f := hwFit{n: len(y)}
+	if len(y) < 2 {
+		if len(y) == 1 {
+			f.level = y[0]
+		}
+		return f
+	}
+
+	L := hw.season
+	if len(y) >= 2*L {
+		f.level = mean(y[:L])
+		f.trend = (mean(y[L:2*L]) - f.level) / float64(L)
+		f.seasonal = make([]float64, L)
+		for i := 0; i < L; i++ {
+			f.seasonal[i] = y[i] - f.level
+		}
+	} else {
+		f.level, f.trend = y[0], y[1]-y[0]
+	}
+
+	for t, v := range y {
+		s := 0.0
+		if f.seasonal != nil {
+			s = f.seasonal[t%L]
+		}
+		prev := f.level
+		f.level = hw.alpha*(v-s) + (1-hw.alpha)*(f.level+f.trend)
+		f.trend = hw.beta*(f.level-prev) + (1-hw.beta)*f.trend
+		if f.seasonal != nil {
+			f.seasonal[t%L] = hw.gamma*(v-f.level) + (1-hw.gamma)*s
+		}
+		f.trends = append(f.trends, f.trend)
+	}
+	return f
+}
+
+// forecast predicts the next h values, clamped at zero since they are counts.
+func (f hwFit) forecast(h int) []float64 {
+	out := make([]float64, h)
+	for k := 1; k <= h; k++ {
+		v := f.level + float64(k)*f.trend
+		if f.seasonal != nil {
+			v += f.seasonal[(f.n+k-1)%len(f.seasonal)]
+		}
+		out[k-1] = math.Max(0, v)
+	}
+	return out
+}
+
+func mean(xs []float64) float64 {
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// SeverityForecast is the latest fit for one tenant's severity series.
+type SeverityForecast struct {
+	Severity   Severity  `json:"severity"`
+	BucketSize string    `json:"bucket_size"`
+	LastBucket time.Time `json:"last_bucket"`
+	LastActual float64   `json:"last_actual"`
+	Level      float64   `json:"level"`
+	Trend      float64   `json:"trend"` // change in count per bucket
+	Expected   []float64 `json:"expected"`
+	Rising     bool      `json:"rising"` // sustained upward trend
+}
+
+// Forecaster periodically refits per-tenant severity series and alerts when
+// ALERT/CRITICAL volume is trending up, before absolute thresholds trip.
+type Forecaster struct {
+	db     *store.DB
+	alerts *Alerts
+	cfg    ForecastConfig
+
+	mu      sync.RWMutex
+	latest  map[string][]SeverityForecast // tenant -> forecasts
+	alerted map[string]bool               // tenant/severity currently flagged
+}
+
+func NewForecaster(db *store.DB, alerts *Alerts, cfg ForecastConfig) *Forecaster {
+	return &Forecaster{db: db, alerts: alerts, cfg: cfg, latest: map[string][]SeverityForecast{}, alerted: map[string]bool{}}
+}
+
+func (f *Forecaster) Run() {
+	interval, _ := time.ParseDuration(f.cfg.Interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		if err := f.refit(); err != nil {
+			slog.Error("Severity forecast failed", "err", err)
+		}
+	}
+}
+
+// refit loads bucketed counts for complete buckets and refits every series.
+func (f *Forecaster) refit() error {
+	bucket, _ := time.ParseDuration(f.cfg.Bucket)
+	history, _ := time.ParseDuration(f.cfg.History)
+	secs := int64(bucket.Seconds())
+	current := time.Now().Unix() / secs
+	first := current - int64(history/bucket)
+	if current-first < 2 {
+		return fmt.Errorf("history %s must span at least two %s buckets", f.cfg.History, f.cfg.Bucket)
+	}
+
+	rows, err := f.db.Query(`
+		SELECT tenant_id, severity, `+f.db.TimeBucket("timestamp")+` AS b, COUNT(*)
+		FROM logs
+		WHERE deleted_at IS NULL AND timestamp >= ? AND timestamp < ?
+		GROUP BY tenant_id, severity, b`,
+		secs, time.Unix(first*secs, 0), time.Unix(current*secs, 0),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	n := int(current - first)
+	series := map[string]map[Severity][]float64{}
+	for rows.Next() {
+		var (
+			tenant string
+			sev    Severity
+			b      int64
+			count  float64
+		)
+		if err := rows.Scan(&tenant, &sev, &b, &count); err != nil {
+			return err
+		}
+		if series[tenant] == nil {
+			series[tenant] = map[Severity][]float64{}
+		}
+		if series[tenant][sev] == nil {
+			series[tenant][sev] = make([]float64, n)
+		}
+		if i := int(b - first); i >= 0 && i < n {
+			series[tenant][sev][i] += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	hw := holtWinters{alpha: f.cfg.Alpha, beta: f.cfg.Beta, gamma: f.cfg.Gamma, season: f.cfg.Season}
+	latest := map[string][]SeverityForecast{}
+	lastBucket := time.Unix((current-1)*secs, 0)
+	for tenant, bySev := range series {
+		for sev, y := range bySev {
+			y = trimLeadingZeros(y)
+			fit := hw.fit(y)
+			fc := SeverityForecast{
+				Severity:   sev,
+				BucketSize: f.cfg.Bucket,
+				LastBucket: lastBucket,
+				LastActual: y[len(y)-1],
+				Level:      fit.level,
+				Trend:      fit.trend,
+				Expected:   fit.forecast(f.cfg.Horizon),
+				Rising:     f.sustainedRise(fit),
+			}
+			latest[tenant] = append(latest[tenant], fc)
+			f.maybeAlert(tenant, fc)
+		}
+		sort.Slice(latest[tenant], func(i, j int) bool { return latest[tenant][i].Severity < latest[tenant][j].Severity })
+	}
+
+	f.mu.Lock()
+	f.latest = latest
+	f.mu.Unlock()
+	return nil
+}
+
+// trimLeadingZeros drops empty buckets before a series' first event so a
+// new tenant's ramp-up isn't mistaken for a trend. At least one value remains.
+func trimLeadingZeros(y []float64) []float64 {
+	for i, v := range y {
+		if v != 0 {
+			return y[i:]
+		}
+	}
+	return y[len(y)-1:]
+}
+
+// sustainedRise reports whether the trend has exceeded MinGrowth of the
+// level for the last Sustain buckets.
+func (f *Forecaster) sustainedRise(fit hwFit) bool {
+	if len(fit.trends) < f.cfg.Sustain {
+		return false
+	}
+	threshold := math.Max(1, fit.level) * f.cfg.MinGrowth
+	for _, t := range fit.trends[len(fit.trends)-f.cfg.Sustain:] {
+		if t <= threshold {
+			return false
+		}
+	}
+	return true
+}
+
+// maybeAlert raises an alert when an ALERT/CRITICAL series starts rising.
+// Alerts are edge-triggered: a series must stop rising before it alerts again.
+func (f *Forecaster) maybeAlert(tenant string, fc SeverityForecast) {
+	if fc.Severity < SeverityAlert {
+		return
+	}
+	key := tenant + "/" + fc.Severity.String()
+	f.mu.Lock()
+	was := f.alerted[key]
+	f.alerted[key] = fc.Rising
+	f.mu.Unlock()
+	if !fc.Rising || was {
+		return
+	}
+
+	f.alerts.Raise(Alert{
+		TenantID: tenant,
+		Kind:     "severity_trend",
+		Severity: fc.Severity,
+		Title:    fmt.Sprintf("%s volume trending up (+%.1f per %s)", fc.Severity, fc.Trend, fc.BucketSize),
+		Details: map[string]any{
+			"level":       fc.Level,
+			"trend":       fc.Trend,
+			"last_actual": fc.LastActual,
+			"expected":    fc.Expected,
+		},
+	})
+}
+
+// handler serves GET /api/forecast for the caller's tenant.
+func (f *Forecaster) handler(w http.ResponseWriter, r *http.Request) {
+	f.mu.RLock()
+	forecasts := f.latest[tenantFromRequest(r)]
+	f.mu.RUnlock()
+	if forecasts == nil {
+		forecasts = []SeverityForecast{}
+	}
+	writeJSON(w, http.StatusOK, forecasts)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHoltWintersFit(t *testing.T) {
+	repeat := func(pattern []float64, n int) []float64 {
+		var y []float64
+		for range n {
+			y = append(y, pattern...)
+		}
+		return y
+	}
+	linear := func(start, step float64, n int) []float64 {
+		y := make([]float64, n)
+		for i := range y {
+			y[i] = start + step*float64(i)
+		}
+		return y
+	}
+	tests := []struct {
+		name         string
+		hw           holtWinters
+		y            []float64
+		wantLevel    float64
+		wantTrend    float64
+		wantSeasonal bool
+		wantForecast []float64
+		tolerance    float64
+	}{
+		{
+			name:         "empty",
+			hw:           holtWinters{alpha: 0.5, beta: 0.3, gamma: 0.3, season: 4},
+			wantForecast: []float64{0, 0},
+		},
+		{
+			name:         "single point",
+			hw:           holtWinters{alpha: 0.5, beta: 0.3, gamma: 0.3, season: 4},
+			y:            []float64{7},
+			wantLevel:    7,
+			wantForecast: []float64{7, 7},
+		},
+		{
+			name:         "linear converges without a full second season",
+			hw:           holtWinters{alpha: 0.5, beta: 0.3, gamma: 0.3, season: 24},
+			y:            linear(10, 2, 30),
+			wantLevel:    68,
+			wantTrend:    2,
+			wantForecast: []float64{70, 72, 74},
+			tolerance:    0.01,
+		},
+		{
+			name:         "constant with seasonality",
+			hw:           holtWinters{alpha: 0.3, beta: 0.1, gamma: 0.2, season: 4},
+			y:            repeat([]float64{5, 5, 5, 5}, 3),
+			wantLevel:    5,
+			wantSeasonal: true,
+			wantForecast: []float64{5, 5, 5, 5},
+		},
+		{
+			name:         "pure seasonal pattern",
+			hw:           holtWinters{alpha: 0.3, beta: 0.1, gamma: 0.2, season: 4},
+			y:            repeat([]float64{10, 20, 30, 20}, 3),
+			wantLevel:    20,
+			wantSeasonal: true,
+			wantForecast: []float64{10, 20, 30, 20, 10},
+		},
+		{
+			name:         "season offset continues from the last point",
+			hw:           holtWinters{alpha: 0.3, beta: 0.1, gamma: 0.2, season: 4},
+			y:            repeat([]float64{10, 20, 30, 20}, 3)[:10],
+			wantLevel:    20,
+			wantSeasonal: true,
+			wantForecast: []float64{30, 20, 10},
+		},
+		{
+			name:         "seasonal with trend",
+			hw:           holtWinters{alpha: 0.5, beta: 0.3, gamma: 0.3, season: 4},
+			y:            addSeries(linear(100, 5, 48), repeat([]float64{-10, 0, 10, 0}, 12)),
+			wantLevel:    335,
+			wantTrend:    5,
+			wantSeasonal: true,
+			wantForecast: []float64{330, 345, 360, 355},
+			tolerance:    1.5,
+		},
+		{
+			name:         "falling counts clamp at zero",
+			hw:           holtWinters{alpha: 0.5, beta: 0.3, gamma: 0.3, season: 24},
+			y:            linear(110, -10, 12),
+			wantLevel:    0,
+			wantTrend:    -10,
+			wantForecast: []float64{0, 0, 0},
+			tolerance:    0.1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tol := math.Max(tt.tolerance, 1e-9)
+			f := tt.hw.fit(tt.y)
+			if f.n != len(tt.y) {
+				t.Errorf("n = %d, want %d", f.n, len(tt.y))
+			}
+			if math.Abs(f.level-tt.wantLevel) > tol || math.Abs(f.trend-tt.wantTrend) > tol {
+				t.Errorf("level %g trend %g, want %g %g", f.level, f.trend, tt.wantLevel, tt.wantTrend)
+			}
+			if (f.seasonal != nil) != tt.wantSeasonal {
+				t.Errorf("seasonal = %v, want seasonal %v", f.seasonal, tt.wantSeasonal)
+			}
+			if len(tt.y) >= 2 && len(f.trends) != len(tt.y) {
+				t.Errorf("%d trends for %d points", len(f.trends), len(tt.y))
+			}
+			got := f.forecast(len(tt.wantForecast))
+			for i := range got {
+				if math.Abs(got[i]-tt.wantForecast[i]) > tol {
+					t.Errorf("forecast = %v, want %v", got, tt.wantForecast)
+					break
+				}
+			}
+		})
+	}
+}
+
+func addSeries(a, b []float64) []float64 {
+	out := make([]float64, len(a))
+	for i := range a {
+		out[i] = a[i] + b[i]
+	}
+	return out
+}
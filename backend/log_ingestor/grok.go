@@ -0,0 +1,506 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"1logx/log_ingestor/internal/pipeline"
+)
+
+// grokPatterns is the built-in pattern library, a subset of Logstash's
+// written for RE2: no lookarounds or atomic groups.
+var grokPatterns = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"INT":               `[+-]?[0-9]+`,
+	"BASE10NUM":         `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+	"NUMBER":            `%{BASE10NUM}`,
+	"BASE16NUM":         `(?:0[xX])?[0-9A-Fa-f]+`,
+	"POSINT":            `\b[1-9][0-9]*\b`,
+	"NONNEGINT":         `\b[0-9]+\b`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+	"QS":                `%{QUOTEDSTRING}`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"MAC":               `(?:[A-Fa-f0-9]{2}[:-]){5}[A-Fa-f0-9]{2}|(?:[A-Fa-f0-9]{4}\.){2}[A-Fa-f0-9]{4}`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}(?:%{IPV4}|[0-9A-Fa-f]{0,4})`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":          `%{IPORHOST}:%{POSINT}`,
+	"UNIXPATH":          `(?:/[\w_%!$@:.,+~-]*)+`,
+	"WINPATH":           `(?:[A-Za-z]+:|\\)(?:\\[^\\?*]*)+`,
+	"PATH":              `(?:%{UNIXPATH}|%{WINPATH})`,
+	"URIPROTO":          `[A-Za-z][A-Za-z0-9+\-.]*`,
+	"URIPATH":           `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":          `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":               `%{URIPROTO}://(?:%{USER}(?::[^@]*)?@)?(?:%{IPORHOST}(?::%{POSINT})?)?(?:%{URIPATHPARAM})?`,
+	"MONTH":             `\b(?:[Jj]an(?:uary)?|[Ff]eb(?:ruary)?|[Mm]ar(?:ch)?|[Aa]pr(?:il)?|[Mm]ay|[Jj]une?|[Jj]uly?|[Aa]ug(?:ust)?|[Ss]ep(?:tember)?|[Oo]ct(?:ober)?|[Nn]ov(?:ember)?|[Dd]ec(?:ember)?)\b`,
+	"MONTHNUM":          `(?:0?[1-9]|1[0-2])`,
+	"MONTHDAY":          `(?:0[1-9]|[12][0-9]|3[01]|[1-9])`,
+	"DAY":               `\b(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)\b`,
+	"YEAR":              `(?:\d\d){1,2}`,
+	"HOUR":              `(?:2[0123]|[01]?[0-9])`,
+	"MINUTE":            `[0-5][0-9]`,
+	"SECOND":            `(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"PROG":              `[\x21-\x5a\x5c\x5e-\x7e]+`,
+	"SYSLOGPROG":        `%{PROG:program}(?:\[%{POSINT:pid:int}\])?`,
+	"SYSLOGBASE":        `%{SYSLOGTIMESTAMP:timestamp} %{IPORHOST:host} %{SYSLOGPROG}:`,
+	"LOGLEVEL":          `(?:[Aa]lert|ALERT|[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo(?:rmation)?|INFO(?:RMATION)?|[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Ff]atal|FATAL|[Ss]evere|SEVERE|[Ee]merg(?:ency)?|EMERG(?:ENCY)?)`,
+	"COMMONAPACHELOG":   `%{IPORHOST:ip_address} %{NOTSPACE:ident} %{NOTSPACE:auth} \[%{HTTPDATE:timestamp}\] "(?:%{WORD:verb} %{NOTSPACE:request}(?: HTTP/%{NUMBER:httpversion})?|%{DATA:rawrequest})" %{INT:response:int} (?:%{INT:bytes:int}|-)`,
+	"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} %{QS:referrer} %{QS:agent}`,
+}
+
+// grokReference matches %{NAME}, %{NAME:capture}, and %{NAME:capture:type}.
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::([\w.\-]+))?(?::(\w+))?\}`)
+
+// grokTimeLayouts are tried, in order, for timestamp captures without a
+// layout, before the vendor layouts shared with the CEF/LEEF parser.
+var grokTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05,999",
+	"02/Jan/2006:15:04:05 -0700",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.UnixDate,
+	time.Stamp,
+}
+
+// customParser is a compiled ParserDefinition.
+type customParser struct {
+	def      ParserDefinition
+	re       *regexp.Regexp
+	captures []string          // per subexpression; "" for unnamed groups
+	types    map[string]string // capture -> type
+}
+
+// compileParser expands def's grok references with patterns, falling back
+// to the built-in library, and compiles the result.
+func compileParser(def ParserDefinition, patterns map[string]string) (*customParser, error) {
+	if def.Name == "" {
+		return nil, fmt.Errorf("parser %q needs a name", def.Pattern)
+	}
+	p := &customParser{def: def, types: map[string]string{}}
+	var captures []string
+	expr, err := expandGrok(def.Pattern, patterns, &captures, 0)
+	if err != nil {
+		return nil, fmt.Errorf("parser %s: %w", def.Name, err)
+	}
+	if p.re, err = regexp.Compile(expr); err != nil {
+		return nil, fmt.Errorf("parser %s: %w", def.Name, err)
+	}
+
+	// Grok captures are numbered groups named grok<n>; any other named group
+	// came from a plain regex and keeps its name.
+	for _, name := range p.re.SubexpNames()[1:] {
+		if n, ok := strings.CutPrefix(name, "grok"); ok {
+			i, _ := strconv.Atoi(n)
+			name, typ, _ := strings.Cut(captures[i], ":")
+			if typ != "" {
+				p.types[name] = typ
+			}
+			p.captures = append(p.captures, name)
+			continue
+		}
+		p.captures = append(p.captures, name)
+	}
+	for name, typ := range def.Types {
+		p.types[name] = typ
+	}
+	for name, typ := range p.types {
+		if err := checkGrokType(typ); err != nil {
+			return nil, fmt.Errorf("parser %s: capture %s: %w", def.Name, name, err)
+		}
+	}
+	return p, nil
+}
+
+// expandGrok replaces %{...} references in pattern with their expressions,
+// recording each capture as "name" or "name:type".
+func expandGrok(pattern string, patterns map[string]string, captures *[]string, depth int) (string, error) {
+	if depth > 16 {
+		return "", fmt.Errorf("grok patterns nest too deeply")
+	}
+	var expandErr error
+	expr := grokReference.ReplaceAllStringFunc(pattern, func(ref string) string {
+		m := grokReference.FindStringSubmatch(ref)
+		def, ok := patterns[m[1]]
+		if !ok {
+			def, ok = grokPatterns[m[1]]
+		}
+		if !ok {
+			expandErr = fmt.Errorf("unknown grok pattern %q", m[1])
+			return ""
+		}
+		inner, err := expandGrok(def, patterns, captures, depth+1)
+		if err != nil {
+			expandErr = err
+			return ""
+		}
+		if m[2] == "" {
+			return "(?:" + inner + ")"
+		}
+		capture := m[2]
+		if m[3] != "" {
+			capture += ":" + m[3]
+		}
+		*captures = append(*captures, capture)
+		return fmt.Sprintf("(?P<grok%d>%s)", len(*captures)-1, inner)
+	})
+	return expr, expandErr
+}
+
+func checkGrokType(typ string) error {
+	kind, layout, _ := strings.Cut(typ, ":")
+	switch kind {
+	case "int", "float", "bool", "ip":
+		if layout == "" {
+			return nil
+		}
+	case "timestamp":
+		return nil
+	}
+	return fmt.Errorf("unknown type %q", typ)
+}
+
+// coerceGrok converts a captured value to typ, normalizing its text.
+func coerceGrok(v, typ string) (string, time.Time, error) {
+	kind, layout, _ := strings.Cut(typ, ":")
+	switch kind {
+	case "int":
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("%q is not an integer", v)
+		}
+		return strconv.FormatInt(n, 10), time.Time{}, nil
+	case "float":
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("%q is not a number", v)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), time.Time{}, nil
+	case "bool":
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("%q is not a boolean", v)
+		}
+		return strconv.FormatBool(b), time.Time{}, nil
+	case "ip":
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return "", time.Time{}, fmt.Errorf("%q is not an IP address", v)
+		}
+		return ip.String(), time.Time{}, nil
+	case "timestamp":
+		t := parseGrokTime(v, layout)
+		if t.IsZero() {
+			return "", time.Time{}, fmt.Errorf("%q is not a recognized timestamp", v)
+		}
+		return t.UTC().Format(time.RFC3339Nano), t, nil
+	}
+	return v, time.Time{}, nil
+}
+
+// parseGrokTime parses s with layout, one of unix or unix_ms, or, without a
+// layout, any of the common layouts. Layouts without a year get this year.
+func parseGrokTime(s, layout string) time.Time {
+	s = strings.TrimSpace(s)
+	var layouts []string
+	switch layout {
+	case "unix", "unix_ms":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}
+		}
+		if layout == "unix_ms" {
+			return time.UnixMilli(int64(f))
+		}
+		return time.Unix(0, int64(f*float64(time.Second)))
+	case "":
+		layouts = grokTimeLayouts
+	default:
+		layouts = []string{layout}
+	}
+	for _, l := range layouts {
+		if t, err := time.Parse(l, s); err == nil {
+			if t.Year() == 0 {
+				t = t.AddDate(time.Now().Year(), 0, 0)
+			}
+			return t
+		}
+	}
+	if layout == "" {
+		return parseVendorTime(s)
+	}
+	return time.Time{}
+}
+
+// appliesTo reports whether p parses entries from source.
+func (p *customParser) appliesTo(source string) bool {
+	if len(p.def.Sources) == 0 {
+		return true
+	}
+	for _, s := range p.def.Sources {
+		if strings.EqualFold(s, source) {
+			return true
+		}
+	}
+	return false
+}
+
+// parse matches msg, returning its non-empty captures converted to their
+// types, or ok false if it doesn't match.
+func (p *customParser) parse(msg string) (captures map[string]string, ts time.Time, ok bool, err error) {
+	m := p.re.FindStringSubmatch(msg)
+	if m == nil {
+		return nil, time.Time{}, false, nil
+	}
+	captures = map[string]string{}
+	for i, name := range p.captures {
+		v := m[i+1]
+		if name == "" || v == "" {
+			continue
+		}
+		if typ, ok := p.types[name]; ok {
+			var t time.Time
+			if v, t, err = coerceGrok(v, typ); err != nil {
+				return nil, time.Time{}, true, fmt.Errorf("parser %s: capture %s: %w", p.def.Name, name, err)
+			}
+			if name == "timestamp" {
+				ts = t
+			}
+		}
+		captures[name] = v
+	}
+	if _, typed := p.types["timestamp"]; !typed && captures["timestamp"] != "" {
+		ts = parseGrokTime(captures["timestamp"], "")
+	}
+	return captures, ts, true, nil
+}
+
+// apply sets entry's columns and fields from captures.
+func (p *customParser) apply(entry *LogEntry, captures map[string]string, ts time.Time) {
+	for name, v := range captures {
+		switch name {
+		case "timestamp":
+			if !ts.IsZero() {
+				entry.Timestamp = ts
+				continue
+			}
+		case "message":
+			entry.Message = v
+			continue
+		case "severity":
+			entry.Severity = NormalizeSeverity(v)
+			continue
+		case "ip_address":
+			if ip := net.ParseIP(v); ip != nil {
+				entry.IPAddress = ip.String()
+				continue
+			}
+		case "source":
+			entry.Source = v
+			continue
+		}
+		if entry.Fields == nil {
+			entry.Fields = map[string]string{}
+		}
+		if len(v) > pipeline.MaxAttributeValue {
+			v = v[:pipeline.MaxAttributeValue]
+		}
+		entry.Fields[name] = v
+	}
+}
+
+// CustomParsers holds the compiled parsers from config.
+type CustomParsers struct {
+	mu      sync.RWMutex // guards parsers and patterns, which reload swaps
+	parsers []*customParser
+	// patterns are the config's extra grok patterns, for test requests.
+	patterns map[string]string
+}
+
+func NewCustomParsers(cfg ParsersConfig) (*CustomParsers, error) {
+	c := &CustomParsers{}
+	return c, c.reload(cfg)
+}
+
+// reload compiles cfg's parsers and swaps them in. Invalid parsers leave the
+// current ones in place.
+func (c *CustomParsers) reload(cfg ParsersConfig) error {
+	if !cfg.Enabled {
+		cfg.Parsers = nil
+	}
+	parsers := make([]*customParser, 0, len(cfg.Parsers))
+	names := map[string]bool{}
+	for _, def := range cfg.Parsers {
+		if names[def.Name] {
+			return fmt.Errorf("duplicate parser name %q", def.Name)
+		}
+		names[def.Name] = true
+		p, err := compileParser(def, cfg.Patterns)
+		if err != nil {
+			return err
+		}
+		parsers = append(parsers, p)
+	}
+	c.mu.Lock()
+	c.parsers, c.patterns = parsers, cfg.Patterns
+	c.mu.Unlock()
+	return nil
+}
+
+// match returns the first parser that applies to entry and matches its
+// message, with its captures.
+func (c *CustomParsers) match(entry LogEntry) (*customParser, map[string]string, time.Time, error) {
+	c.mu.RLock()
+	parsers := c.parsers
+	c.mu.RUnlock()
+	return matchParsers(parsers, entry, false)
+}
+
+// named returns the configured parser called name, or nil.
+func (c *CustomParsers) named(name string) *customParser {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, p := range c.parsers {
+		if p.def.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// matchParsers is match over parsers; anySource ignores their sources.
+func matchParsers(parsers []*customParser, entry LogEntry, anySource bool) (*customParser, map[string]string, time.Time, error) {
+	for _, p := range parsers {
+		if !anySource && !p.appliesTo(entry.Source) {
+			continue
+		}
+		captures, ts, ok, err := p.parse(entry.Message)
+		if ok {
+			return p, captures, ts, err
+		}
+	}
+	return nil, nil, time.Time{}, nil
+}
+
+// applyCustomParsers is the custom pipeline stage. A parser whose captures
+// fail type coercion leaves the entry unchanged. It has no parsers while
+// custom parsing is disabled.
+func (c *CustomParsers) applyCustomParsers(entry *LogEntry) error {
+	if c == nil {
+		return nil
+	}
+	p, captures, ts, err := c.match(*entry)
+	if p == nil || err != nil {
+		return err
+	}
+	p.apply(entry, captures, ts)
+	return nil
+}
+
+// parserTestRequest is a sample line for POST /api/parsers/test, checked
+// against a configured parser by name, an unsaved definition, or, with
+// neither, every configured parser in order.
+type parserTestRequest struct {
+	Line       string            `json:"line"`
+	Source     string            `json:"source,omitempty"`
+	Parser     string            `json:"parser,omitempty"`
+	Definition *ParserDefinition `json:"definition,omitempty"`
+}
+
+// parserTestHandler serves POST /api/parsers/test. It reports whether the
+// line matched, the typed captures, and the resulting entry, without
+// running the rest of the pipeline or storing anything.
+func parserTestHandler(c *CustomParsers) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBody))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed to read body")
+			return
+		}
+		var req parserTestRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		if req.Line == "" {
+			writeError(w, http.StatusBadRequest, "'line' is required")
+			return
+		}
+		if c == nil {
+			writeError(w, http.StatusServiceUnavailable, "custom parsers are not loaded")
+			return
+		}
+
+		c.mu.RLock()
+		parsers, patterns := c.parsers, c.patterns
+		c.mu.RUnlock()
+		switch {
+		case req.Definition != nil:
+			if req.Definition.Name == "" {
+				req.Definition.Name = "test"
+			}
+			p, err := compileParser(*req.Definition, patterns)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			parsers = []*customParser{p}
+		case req.Parser != "":
+			var found *customParser
+			for _, p := range parsers {
+				if p.def.Name == req.Parser {
+					found = p
+				}
+			}
+			if found == nil {
+				writeError(w, http.StatusNotFound, "no parser named "+req.Parser)
+				return
+			}
+			parsers = []*customParser{found}
+		}
+
+		// A named or unsaved parser is tested regardless of its sources.
+		entry := LogEntry{Message: req.Line, Source: req.Source, TenantID: tenantFromRequest(r)}
+		p, captures, ts, err := matchParsers(parsers, entry, req.Parser != "" || req.Definition != nil)
+		resp := map[string]any{"matched": p != nil}
+		if p != nil {
+			resp["parser"] = p.def.Name
+		}
+		if err != nil {
+			resp["error"] = err.Error()
+		} else if p != nil {
+			p.apply(&entry, captures, ts)
+			resp["captures"] = captures
+			resp["entry"] = entry
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestCompileParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     ParserDefinition
+		wantErr bool
+	}{
+		{"grok", ParserDefinition{Name: "ssh", Pattern: `%{SYSLOGBASE} Failed password for %{USER:user} from %{IP:ip_address}`}, false},
+		{"plain regex", ParserDefinition{Name: "kv", Pattern: `user=(?P<user>\w+)`}, false},
+		{"typed in definition", ParserDefinition{Name: "n", Pattern: `%{INT:count}`, Types: map[string]string{"count": "int"}}, false},
+		{"missing name", ParserDefinition{Pattern: `%{WORD:w}`}, true},
+		{"unknown pattern", ParserDefinition{Name: "x", Pattern: `%{NOPE:w}`}, true},
+		{"unknown type", ParserDefinition{Name: "x", Pattern: `%{WORD:w:date}`}, true},
+		{"layout on a non-timestamp", ParserDefinition{Name: "x", Pattern: `%{INT:n}`, Types: map[string]string{"n": "int:hex"}}, true},
+		{"invalid regex", ParserDefinition{Name: "x", Pattern: `(?<=a)b`}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileParser(tt.def, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileParserNestingLimit(t *testing.T) {
+	patterns := map[string]string{"LOOP": `%{LOOP}`}
+	if _, err := compileParser(ParserDefinition{Name: "loop", Pattern: `%{LOOP:x}`}, patterns); err == nil {
+		t.Fatal("self-referencing pattern compiled")
+	}
+}
+
+func TestCustomParserApply(t *testing.T) {
+	year := time.Now().Year()
+	tests := []struct {
+		name     string
+		def      ParserDefinition
+		patterns map[string]string
+		msg      string
+		match    bool
+		wantErr  bool
+		want     LogEntry
+	}{
+		{
+			name:  "syslog ssh failure",
+			def:   ParserDefinition{Name: "ssh", Pattern: `%{SYSLOGBASE} Failed password for %{USER:user} from %{IP:ip_address} port %{POSINT:port:int}`},
+			msg:   "Mar  4 09:15:02 bastion sshd[812]: Failed password for root from 203.0.113.7 port 52144",
+			match: true,
+			want: LogEntry{
+				Timestamp: time.Date(year, 3, 4, 9, 15, 2, 0, time.UTC),
+				IPAddress: "203.0.113.7",
+				Fields:    map[string]string{"host": "bastion", "program": "sshd", "pid": "812", "user": "root", "port": "52144"},
+			},
+		},
+		{
+			name:  "combined apache log",
+			def:   ParserDefinition{Name: "apache", Pattern: `%{COMBINEDAPACHELOG}`},
+			msg:   `198.51.100.4 - frank [10/Oct/2024:13:55:36 -0700] "GET /admin HTTP/1.1" 403 512 "-" "curl/8.0"`,
+			match: true,
+			want: LogEntry{
+				Timestamp: time.Date(2024, 10, 10, 20, 55, 36, 0, time.UTC),
+				IPAddress: "198.51.100.4",
+				Fields: map[string]string{
+					"ident": "-", "auth": "frank", "verb": "GET", "request": "/admin", "httpversion": "1.1",
+					"response": "403", "bytes": "512", "referrer": `"-"`, "agent": `"curl/8.0"`,
+				},
+			},
+		},
+		{
+			name:     "custom pattern sets columns",
+			def:      ParserDefinition{Name: "app", Pattern: `%{APPLEVEL:severity} \[%{WORD:source}\] %{GREEDYDATA:message}`},
+			patterns: map[string]string{"APPLEVEL": `%{LOGLEVEL}`},
+			msg:      "ERROR [billing] charge declined",
+			match:    true,
+			want:     LogEntry{Severity: SeverityAlert, Source: "billing", Message: "charge declined"},
+		},
+		{
+			name:  "typed captures normalize",
+			def:   ParserDefinition{Name: "t", Pattern: `ok=%{WORD:ok:bool} ratio=%{NUMBER:ratio:float} at=%{NUMBER:timestamp}`, Types: map[string]string{"timestamp": "timestamp:unix_ms"}},
+			msg:   "ok=TRUE ratio=0.50 at=1700000000000",
+			match: true,
+			want: LogEntry{
+				Timestamp: time.UnixMilli(1700000000000),
+				Fields:    map[string]string{"ok": "true", "ratio": "0.5"},
+			},
+		},
+		{
+			name:    "failed coercion",
+			def:     ParserDefinition{Name: "t", Pattern: `n=%{NOTSPACE:n:int}`},
+			msg:     "n=twelve",
+			match:   true,
+			wantErr: true,
+		},
+		{
+			name: "no match",
+			def:  ParserDefinition{Name: "t", Pattern: `^user=%{WORD:user}$`},
+			msg:  "something else",
+		},
+		{
+			name:  "unparsable ip address becomes a field",
+			def:   ParserDefinition{Name: "t", Pattern: `from %{NOTSPACE:ip_address}`},
+			msg:   "from gateway",
+			match: true,
+			want:  LogEntry{Fields: map[string]string{"ip_address": "gateway"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := compileParser(tt.def, tt.patterns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			captures, ts, ok, err := p.parse(tt.msg)
+			if ok != tt.match {
+				t.Fatalf("matched = %v, want %v", ok, tt.match)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !ok || err != nil {
+				return
+			}
+			var got LogEntry
+			p.apply(&got, captures, ts)
+			if !got.Timestamp.Equal(tt.want.Timestamp) || got.IPAddress != tt.want.IPAddress ||
+				got.Severity != tt.want.Severity || got.Source != tt.want.Source || got.Message != tt.want.Message {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if !maps.Equal(got.Fields, tt.want.Fields) {
+				t.Errorf("fields = %v, want %v", got.Fields, tt.want.Fields)
+			}
+		})
+	}
+}
+
+func TestCustomParserAppliesTo(t *testing.T) {
+	tests := []struct {
+		sources []string
+		source  string
+		want    bool
+	}{
+		{nil, "anything", true},
+		{[]string{"Firewall"}, "firewall", true},
+		{[]string{"Firewall", "IDS"}, "IDS", true},
+		{[]string{"Firewall"}, "Auth", false},
+	}
+	for _, tt := range tests {
+		p := &customParser{def: ParserDefinition{Sources: tt.sources}}
+		if got := p.appliesTo(tt.source); got != tt.want {
+			t.Errorf("appliesTo(%q) with sources %v = %v, want %v", tt.source, tt.sources, got, tt.want)
+		}
+	}
+}
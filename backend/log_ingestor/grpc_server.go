@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+
+	"1logx/log_ingestor/internal/pipeline"
+	ingestv1 "1logx/log_ingestor/proto/ingestv1"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcIngestServer implements ingestv1.IngestServiceServer on top of the
+// same Ingestor the HTTP API uses.
+type grpcIngestServer struct {
+	ingestv1.UnimplementedIngestServiceServer
+	in *pipeline.Ingestor
+}
+
+// IngestLog stores a single entry.
+func (s *grpcIngestServer) IngestLog(ctx context.Context, req *ingestv1.IngestLogRequest) (*ingestv1.IngestLogResponse, error) {
+	stored, err := s.ingest(ctx, req.GetEntry())
+	if err != nil {
+		return nil, err
+	}
+	return &ingestv1.IngestLogResponse{Id: stored.ID}, nil
+}
+
+// IngestLogs stores a client stream of entries. Invalid and shed entries are
+// reported per index; rate limits and storage failures abort the stream.
+func (s *grpcIngestServer) IngestLogs(stream ingestv1.IngestService_IngestLogsServer) error {
+	resp := &ingestv1.IngestLogsResponse{}
+	for i := int64(0); ; i++ {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(resp)
+		}
+		if err != nil {
+			return err
+		}
+		stored, err := s.ingest(stream.Context(), req.GetEntry())
+		if c := status.Code(err); c == codes.InvalidArgument || c == codes.Unavailable {
+			resp.Errors = append(resp.Errors, &ingestv1.IngestError{Index: i, Message: status.Convert(err).Message()})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		resp.Accepted++
+		resp.Ids = append(resp.Ids, stored.ID)
+	}
+}
+
+func (s *grpcIngestServer) ingest(ctx context.Context, pb *ingestv1.LogEntry) (LogEntry, error) {
+	if pb == nil {
+		return LogEntry{}, status.Error(codes.InvalidArgument, "entry is required")
+	}
+	entry, err := logEntryFromProto(pb)
+	if err != nil {
+		return LogEntry{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// The tenant always comes from the credential, never the payload.
+	entry.TenantID = tenantFromContext(ctx)
+	origin := grpcOrigin(ctx)
+	origin.Input = "grpc"
+	stored, err := s.in.IngestFrom(origin, entry)
+	if err != nil {
+		if errors.Is(err, pipeline.ErrQueuedForRetry) {
+			return stored, nil // accepted; the id is assigned once it is stored
+		}
+		if errors.Is(err, pipeline.ErrInvalidEntry) {
+			return stored, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if errors.Is(err, pipeline.ErrRateLimited) {
+			return stored, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		if errors.Is(err, pipeline.ErrShed) || errors.Is(err, pipeline.ErrSourcePaused) {
+			return stored, status.Error(codes.Unavailable, err.Error())
+		}
+		slog.Error("Failed to ingest gRPC log", "err", err)
+		return stored, status.Error(codes.Internal, "failed to store log")
+	}
+	return stored, nil
+}
+
+// grpcOrigin identifies the client behind a gRPC call.
+func grpcOrigin(ctx context.Context) pipeline.Origin {
+	origin := pipeline.Origin{IP: peerIP(ctx)}
+	if key := keyFromContext(ctx); key != nil {
+		origin.Key = key.Prefix
+	}
+	return origin
+}
+
+var severityFromProto = map[ingestv1.Severity]Severity{
+	ingestv1.Severity_SEVERITY_INFO:     SeverityInfo,
+	ingestv1.Severity_SEVERITY_WARNING:  SeverityWarning,
+	ingestv1.Severity_SEVERITY_ALERT:    SeverityAlert,
+	ingestv1.Severity_SEVERITY_CRITICAL: SeverityCritical,
+}
+
+func logEntryFromProto(pb *ingestv1.LogEntry) (LogEntry, error) {
+	entry := LogEntry{
+		Source:    pb.GetSource(),
+		Message:   pb.GetMessage(),
+		IPAddress: pb.GetIpAddress(),
+		Fields:    pb.GetFields(),
+		Labels:    pb.GetLabels(),
+	}
+	if ts := pb.GetTimestamp(); ts != nil {
+		if err := ts.CheckValid(); err != nil {
+			return entry, fmt.Errorf("invalid timestamp: %w", err)
+		}
+		entry.Timestamp = ts.AsTime()
+	}
+	if sev, ok := severityFromProto[pb.GetSeverity()]; ok {
+		entry.Severity = sev
+	} else if text := pb.GetSeverityText(); text != "" {
+		sev, err := ParseSeverity(text)
+		if err != nil {
+			return entry, err
+		}
+		entry.Severity = sev
+	}
+	return entry, nil
+}
+
+// grpcAuth authenticates RPCs with the same API keys as HTTP, read from the
+// "x-api-key" or "authorization: Bearer" metadata. Unless required is set,
+// calls without a key proceed anonymously; invalid keys are always rejected.
+func grpcAuth(ks *KeyStore, required bool) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	authenticate := func(ctx context.Context) (context.Context, error) {
+		var secret string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get("x-api-key"); len(v) > 0 {
+				secret = v[0]
+			} else if v := md.Get("authorization"); len(v) > 0 && strings.HasPrefix(v[0], "Bearer ") {
+				secret = strings.TrimPrefix(v[0], "Bearer ")
+			}
+		}
+		key, err := ks.AuthenticateSecret(secret, peerIP(ctx))
+		switch {
+		case err == errMissingKey && !required:
+			return ctx, nil
+		case err != nil:
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return context.WithValue(ctx, apiKeyCtxKey, key), nil
+	}
+
+	unary := func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+	}
+	return unary, stream
+}
+
+// authedStream carries the authenticated context into stream handlers.
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedStream) Context() context.Context { return s.ctx }
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// runGRPCServer serves the gRPC ingestion API until the listener fails.
+func runGRPCServer(cfg GRPCConfig, in *pipeline.Ingestor, ks *KeyStore, requireKey bool) error {
+	unary, stream := grpcAuth(ks, requireKey)
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary),
+		grpc.ChainStreamInterceptor(stream),
+		grpc.MaxRecvMsgSize(maxIngestBody),
+	}
+	if cfg.TLSCert != "" || cfg.TLSKey != "" {
+		creds, err := credentials.NewServerTLSFromFile(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return fmt.Errorf("load TLS credentials: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	lis, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(opts...)
+	ingestv1.RegisterIngestServiceServer(srv, &grpcIngestServer{in: in})
+	collogspb.RegisterLogsServiceServer(srv, &otlpReceiver{in: in})
+	slog.Info("gRPC ingest API (and OTLP/logs) running", "addr", cfg.Addr, "tls", cfg.TLSCert != "")
+	return srv.Serve(lis)
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// LegalHold protects matching logs from soft-deletion and retention purges.
+// Unset bounds match everything, so a hold with only IPAddress set covers
+// every log from that address.
+type LegalHold struct {
+	ID         int64      `json:"id"`
+	TenantID   string     `json:"tenant_id"`
+	Name       string     `json:"name"`
+	Reason     string     `json:"reason,omitempty"`
+	From       *time.Time `json:"from,omitempty"`
+	To         *time.Time `json:"to,omitempty"`
+	Source     string     `json:"source,omitempty"`
+	IPAddress  string     `json:"ip_address,omitempty"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReleasedAt *time.Time `json:"released_at,omitempty"`
+}
+
+// notHeldClause excludes logs rows covered by an active legal hold.
+const notHeldClause = `NOT EXISTS (
+	SELECT 1 FROM legal_holds h
+	WHERE h.released_at IS NULL
+	  AND h.tenant_id = logs.tenant_id
+	  AND (h.from_time IS NULL OR logs.timestamp >= h.from_time)
+	  AND (h.to_time IS NULL OR logs.timestamp < h.to_time)
+	  AND (h.source IS NULL OR logs.source = h.source)
+	  AND (h.ip_address IS NULL OR logs.ip_address = h.ip_address))`
+
+// LogSelector picks logs for soft-delete and restore, either by ID or by
+// time range with optional source/IP filters.
+type LogSelector struct {
+	TenantID  string     `json:"-"`
+	IDs       []int64    `json:"ids,omitempty"`
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+	Source    string     `json:"source,omitempty"`
+	IPAddress string     `json:"ip_address,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+func (s LogSelector) where() (string, []any, error) {
+	var (
+		where []string
+		args  []any
+	)
+	if len(s.IDs) > 0 {
+		where = append(where, "id IN ("+placeholders(len(s.IDs))+")")
+		for _, id := range s.IDs {
+			args = append(args, id)
+		}
+	}
+	if s.From != nil {
+		where = append(where, "timestamp >= ?")
+		args = append(args, *s.From)
+	}
+	if s.To != nil {
+		where = append(where, "timestamp < ?")
+		args = append(args, *s.To)
+	}
+	if len(where) == 0 {
+		return "", nil, fmt.Errorf("selector needs 'ids' or a 'from'/'to' range")
+	}
+	where = append(where, "tenant_id = ?")
+	args = append(args, s.TenantID)
+	if s.Source != "" {
+		where = append(where, "source = ?")
+		args = append(args, s.Source)
+	}
+	if s.IPAddress != "" {
+		where = append(where, "ip_address = ?")
+		args = append(args, s.IPAddress)
+	}
+	return strings.Join(where, " AND "), args, nil
+}
+
+// softDeleteLogs marks selected logs as deleted, skipping any under hold.
+func softDeleteLogs(db *store.DB, sel LogSelector, actor string) (int64, error) {
+	where, args, err := sel.where()
+	if err != nil {
+		return 0, err
+	}
+	args = append([]any{actor}, args...)
+	res, err := db.Exec(`
+		UPDATE logs SET deleted_at = CURRENT_TIMESTAMP, deleted_by = ?
+		WHERE deleted_at IS NULL AND `+where+` AND `+notHeldClause, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// restoreLogs clears the soft-delete marker on selected logs.
+func restoreLogs(db *store.DB, sel LogSelector) (int64, error) {
+	where, args, err := sel.where()
+	if err != nil {
+		return 0, err
+	}
+	res, err := db.Exec(`
+		UPDATE logs SET deleted_at = NULL, deleted_by = NULL
+		WHERE deleted_at IS NOT NULL AND `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func createHold(db *store.DB, h *LegalHold) error {
+	id, err := db.Insert(`
+		INSERT INTO legal_holds (tenant_id, name, reason, from_time, to_time, source, ip_address, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		h.TenantID, h.Name, h.Reason, h.From, h.To, nullString(h.Source), nullString(h.IPAddress), h.CreatedBy,
+	)
+	if err != nil {
+		return err
+	}
+	h.ID = id
+	h.CreatedAt = time.Now()
+	return nil
+}
+
+func releaseHold(db *store.DB, tenantID string, id int64) (bool, error) {
+	res, err := db.Exec(`UPDATE legal_holds SET released_at = CURRENT_TIMESTAMP WHERE id = ? AND tenant_id = ? AND released_at IS NULL`, id, tenantID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func listHolds(db *store.DB, tenantID string, includeReleased bool) ([]LegalHold, error) {
+	query := `SELECT id, tenant_id, name, reason, from_time, to_time, source, ip_address, created_by, created_at, released_at
+		FROM legal_holds WHERE tenant_id = ?`
+	if !includeReleased {
+		query += ` AND released_at IS NULL`
+	}
+	rows, err := db.Query(query+` ORDER BY id`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := []LegalHold{}
+	for rows.Next() {
+		var (
+			h                 LegalHold
+			reason, src, ip   sql.NullString
+			from, to, release sql.NullTime
+		)
+		if err := rows.Scan(&h.ID, &h.TenantID, &h.Name, &reason, &from, &to, &src, &ip, &h.CreatedBy, &h.CreatedAt, &release); err != nil {
+			return nil, err
+		}
+		h.Reason, h.Source, h.IPAddress = reason.String, src.String, ip.String
+		h.From, h.To, h.ReleasedAt = timePtr(from), timePtr(to), timePtr(release)
+		holds = append(holds, h)
+	}
+	return holds, rows.Err()
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// --- HTTP Handlers ---
+
+// deleteLogsHandler serves POST /api/logs/delete.
+func deleteLogsHandler(db *store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var sel LogSelector
+		if err := json.NewDecoder(r.Body).Decode(&sel); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		sel.TenantID = tenantFromRequest(r)
+		actor := requestActor(r)
+		n, err := softDeleteLogs(db, sel, actor)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		recordAudit(db, sel.TenantID, actor, "logs.soft_delete", map[string]any{"selector": sel, "deleted": n})
+		slog.Info("Soft-deleted logs", "actor", actor, "deleted", n)
+		writeJSON(w, http.StatusOK, map[string]any{"deleted": n})
+	}
+}
+
+// restoreLogsHandler serves POST /api/logs/restore.
+func restoreLogsHandler(db *store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var sel LogSelector
+		if err := json.NewDecoder(r.Body).Decode(&sel); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		sel.TenantID = tenantFromRequest(r)
+		n, err := restoreLogs(db, sel)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		actor := requestActor(r)
+		recordAudit(db, sel.TenantID, actor, "logs.restore", map[string]any{"selector": sel, "restored": n})
+		slog.Info("Restored soft-deleted logs", "actor", actor, "restored", n)
+		writeJSON(w, http.StatusOK, map[string]any{"restored": n})
+	}
+}
+
+// holdsHandler serves GET and POST /api/holds.
+func holdsHandler(db *store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			holds, err := listHolds(db, tenantFromRequest(r), r.URL.Query().Get("all") == "true")
+			if err != nil {
+				slog.Error("Failed to list legal holds", "err", err)
+				writeError(w, http.StatusInternalServerError, "query failed")
+				return
+			}
+			writeJSON(w, http.StatusOK, holds)
+
+		case http.MethodPost:
+			var h LegalHold
+			if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid JSON body")
+				return
+			}
+			if h.Name == "" {
+				writeError(w, http.StatusBadRequest, "'name' is required")
+				return
+			}
+			h.TenantID = tenantFromRequest(r)
+			h.CreatedBy = requestActor(r)
+			if err := createHold(db, &h); err != nil {
+				slog.Error("Failed to create legal hold", "err", err)
+				writeError(w, http.StatusInternalServerError, "failed to create hold")
+				return
+			}
+			recordAudit(db, h.TenantID, h.CreatedBy, "hold.create", h)
+			slog.Info("Legal hold placed", "name", h.Name, "actor", h.CreatedBy)
+			writeJSON(w, http.StatusCreated, h)
+
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	}
+}
+
+// releaseHoldHandler serves POST /api/holds/{id}/release.
+func releaseHoldHandler(db *store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid hold id")
+			return
+		}
+		ok, err := releaseHold(db, tenantFromRequest(r), id)
+		if err != nil {
+			slog.Error("Failed to release legal hold", "id", id, "err", err)
+			writeError(w, http.StatusInternalServerError, "failed to release hold")
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusNotFound, "no active hold with that id")
+			return
+		}
+		actor := requestActor(r)
+		recordAudit(db, tenantFromRequest(r), actor, "hold.release", map[string]any{"id": id})
+		slog.Info("Legal hold released", "id", id, "actor", actor)
+		writeJSON(w, http.StatusOK, map[string]any{"released": id})
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"1logx/events"
+	"1logx/log_ingestor/internal/hub"
+	"1logx/log_ingestor/internal/store"
+
+	"github.com/gorilla/websocket"
+)
+
+// streamHub is the live-stream clients connected to this replica.
+type streamHub = hub.Hub[*wsClient]
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true }, // allow all origins for hackathon
+	// EnableCompression is set from the compression config.
+}
+
+const wsWriteTimeout = 10 * time.Second
+
+var errClientClosed = errors.New("client closed")
+
+// wsClient is a connection with a bounded send buffer. Only its writer
+// goroutine writes to the connection, so broadcasts and per-client streams
+// (e.g. replay) never write to it concurrently, and a slow client only
+// backs up its own buffer.
+type wsClient struct {
+	conn     *websocket.Conn
+	tenantID string // only this tenant's logs are delivered
+	out      chan []byte
+	// closing is closed once the client accepts no more writes; the writer
+	// then flushes out and sends closeCode, and closes done.
+	closing     chan struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+	closeCode   int
+	closeReason string
+	// paused suspends live broadcast delivery, e.g. while a replay runs.
+	paused atomic.Bool
+	// subs narrows live delivery to the client's subscriptions; nil for
+	// connections that don't accept commands.
+	subs *subscriptions
+	// stats measures delivery for the QoS report; nil for replay sockets.
+	stats *clientStats
+	// backlog holds live logs while stored ones are being sent.
+	backlog backlogHold
+}
+
+func newWSClient(conn *websocket.Conn, tenantID string, buffer int) *wsClient {
+	c := &wsClient{
+		conn:     conn,
+		tenantID: tenantID,
+		out:      make(chan []byte, buffer),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	compressConn(conn)
+	go c.writeLoop()
+	return c
+}
+
+func (c *wsClient) write(data []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	start := time.Now()
+	err := c.conn.WriteMessage(websocket.TextMessage, data)
+	if c.stats != nil {
+		c.stats.observe(time.Since(start), err)
+	}
+	return err
+}
+
+// writeLoop writes queued messages until the client is closed. A failed
+// write drops the connection, which ends the handler's read loop.
+func (c *wsClient) writeLoop() {
+	defer close(c.done)
+	for {
+		select {
+		case data := <-c.out:
+			if c.write(data) != nil {
+				c.shutdown(websocket.CloseAbnormalClosure, "")
+				c.conn.Close()
+				return
+			}
+		case <-c.closing:
+			for {
+				select {
+				case data := <-c.out:
+					if c.write(data) != nil {
+						c.conn.Close()
+						return
+					}
+				default:
+					c.conn.WriteControl(websocket.CloseMessage,
+						websocket.FormatCloseMessage(c.closeCode, c.closeReason), time.Now().Add(wsWriteTimeout))
+					return
+				}
+			}
+		}
+	}
+}
+
+// shutdown stops accepting writes; the first call's code and reason are
+// sent once the queue is flushed.
+func (c *wsClient) shutdown(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode, c.closeReason = code, reason
+		close(c.closing)
+	})
+}
+
+// close flushes the queue and sends a close frame, dropping the connection
+// if that takes longer than timeout.
+func (c *wsClient) close(code int, reason string, timeout time.Duration) {
+	c.shutdown(code, reason)
+	select {
+	case <-c.done:
+	case <-time.After(timeout):
+	}
+	c.conn.Close()
+}
+
+// sendRaw queues data, waiting for room in the buffer. Per-client streams
+// use it so they're paced by the client rather than dropped.
+func (c *wsClient) sendRaw(data []byte) error {
+	select {
+	case <-c.closing:
+		return errClientClosed
+	default:
+	}
+	select {
+	case c.out <- data:
+		return nil
+	case <-c.closing:
+		return errClientClosed
+	}
+}
+
+// offer queues data if there's room. Broadcasts use it so one slow client
+// never delays the others; what doesn't fit is counted as dropped.
+func (c *wsClient) offer(data []byte) bool {
+	select {
+	case <-c.closing:
+		return false
+	default:
+	}
+	select {
+	case c.out <- data:
+		return true
+	default:
+		if c.stats != nil {
+			c.stats.drop()
+		}
+		return false
+	}
+}
+
+func (c *wsClient) Tenant() string { return c.tenantID }
+
+// Log offers a live log entry unless the client is paused or hasn't
+// subscribed to it, holding it while stored logs are being sent.
+func (c *wsClient) Log(entry LogEntry, data []byte) {
+	if c.paused.Load() || (c.subs != nil && !c.subs.wants(entry)) {
+		return
+	}
+	if !c.backlog.hold(entry, data) {
+		c.offer(data)
+	}
+}
+
+// Message offers a protocol message, even while the live log feed is
+// paused.
+func (c *wsClient) Message(data []byte) { c.offer(data) }
+
+func (c *wsClient) send(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.sendRaw(data)
+}
+
+// wsMessage is the envelope for anything on the stream other than a live log
+// entry, which is sent bare for compatibility with existing dashboards.
+type wsMessage = events.Envelope
+
+// wsCommand is a client -> server protocol message.
+type wsCommand struct {
+	Type     string       `json:"type"`            // replay, replay_stop, subscribe, unsubscribe, backlog
+	ID       string       `json:"id,omitempty"`    // subscription id
+	Limit    int          `json:"limit,omitempty"` // backlog size
+	From     string       `json:"from,omitempty"`
+	To       string       `json:"to,omitempty"`
+	Speed    string       `json:"speed,omitempty"`
+	Source   string       `json:"source,omitempty"`
+	Severity string       `json:"severity,omitempty"`
+	IP       string       `json:"ip,omitempty"`
+	Force    bool         `json:"force,omitempty"`
+	Filter   StreamFilter `json:"filter,omitempty"`
+}
+
+// --- WebSocket Handlers ---
+func wsHandler(db *store.DB, h *streamHub, cfg APIConfig, stream StreamConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		backlog, err := parseBacklog(r.URL.Query().Get("backlog"), stream)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := h.Admit(); err != nil {
+			w.Header().Set("Retry-After", "5")
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		defer h.Release()
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			slog.Warn("WebSocket upgrade failed", "err", err)
+			return
+		}
+		defer conn.Close()
+		client := newWSClient(conn, tenantFromRequest(r), stream.SendBuffer)
+		client.subs = newSubscriptions(stream, keyFromContext(r.Context()))
+		client.stats = streamQoS.connect(client.tenantID, clientIdentity(r), r.RemoteAddr)
+
+		h.Add(client)
+
+		slog.Info("WebSocket client connected", "tenant", client.tenantID, "remote", r.RemoteAddr)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		backfill := func(n int, filter StreamFilter) {
+			if err := sendBacklog(ctx, db, stream, client, n, filter); err != nil && ctx.Err() == nil {
+				slog.Warn("Backlog failed", "err", err)
+				client.send(wsMessage{Type: "error", Error: "backlog failed: " + err.Error()})
+			}
+		}
+		if backlog > 0 {
+			go backfill(backlog, StreamFilter{})
+		}
+
+		var replays replayRunner
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				break
+			}
+			var cmd wsCommand
+			if err := json.Unmarshal(data, &cmd); err != nil {
+				client.send(wsMessage{Type: "error", Error: "invalid command"})
+				continue
+			}
+			switch cmd.Type {
+			case "replay":
+				replays.start(db, cfg, client, cmd)
+			case "replay_stop":
+				replays.stop()
+			case "subscribe":
+				if err := client.subs.add(cmd.ID, cmd.Filter); err != nil {
+					client.send(wsMessage{Type: "error", Error: err.Error()})
+					continue
+				}
+				client.send(wsMessage{Type: "subscribed", Data: map[string]any{"id": cmd.ID, "terms": cmd.Filter.terms()}})
+			case "backlog":
+				n, err := parseBacklog(strconv.Itoa(cmd.Limit), stream)
+				if err == nil {
+					err = cmd.Filter.validate(stream)
+				}
+				if err != nil {
+					client.send(wsMessage{Type: "error", Error: err.Error()})
+					continue
+				}
+				go backfill(n, cmd.Filter)
+			case "unsubscribe":
+				if !client.subs.remove(cmd.ID) {
+					client.send(wsMessage{Type: "error", Error: "no subscription " + cmd.ID})
+					continue
+				}
+				client.send(wsMessage{Type: "unsubscribed", Data: map[string]any{"id": cmd.ID}})
+			default:
+				client.send(wsMessage{Type: "error", Error: "unknown command " + cmd.Type})
+			}
+		}
+		// Unblock replays and backlogs waiting on a full buffer.
+		client.shutdown(websocket.CloseNormalClosure, "")
+		replays.stop()
+		client.subs.clear()
+		streamQoS.disconnect(client.stats)
+
+		h.Remove(client)
+		slog.Info("WebSocket client disconnected", "tenant", client.tenantID, "remote", r.RemoteAddr)
+	}
+}
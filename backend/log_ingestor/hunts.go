@@ -0,0 +1,429 @@
+package main
+
+import (
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultHuntPack is the curated hunting pack shipped with the ingestor.
+//
+//go:embed hunts/pack.yaml
+var defaultHuntPack []byte
+
+// HuntPack is a versioned set of hunting queries.
+type HuntPack struct {
+	Version int    `yaml:"version" json:"version"`
+	Hunts   []Hunt `yaml:"hunts" json:"hunts"`
+}
+
+// Hunt is one hunting query. See hunts/pack.yaml for the query contract.
+type Hunt struct {
+	ID          string   `yaml:"id" json:"id"`
+	Name        string   `yaml:"name" json:"name"`
+	Description string   `yaml:"description" json:"description"`
+	Severity    Severity `yaml:"severity" json:"severity"`
+	Params      []string `yaml:"params" json:"params"`
+	Query       string   `yaml:"query" json:"-"`
+}
+
+// HuntFinding is a hunt result awaiting analyst review.
+type HuntFinding struct {
+	ID          int64      `json:"id"`
+	TenantID    string     `json:"tenant_id"`
+	HuntID      string     `json:"hunt_id"`
+	PackVersion int        `json:"pack_version"`
+	Severity    Severity   `json:"severity"`
+	FindingKey  string     `json:"finding_key"`
+	Summary     string     `json:"summary"`
+	Hits        int64      `json:"hits"`
+	FirstSeen   time.Time  `json:"first_seen"`
+	LastSeen    time.Time  `json:"last_seen"`
+	WindowStart time.Time  `json:"window_start"`
+	Status      string     `json:"status"`
+	Note        string     `json:"note,omitempty"`
+	ReviewedBy  string     `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Finding review states.
+const (
+	findingNew       = "new"
+	findingConfirmed = "confirmed"
+	findingDismissed = "dismissed"
+)
+
+// loadHuntPack reads the pack at path, or the built-in pack if path is empty.
+func loadHuntPack(path string) (HuntPack, error) {
+	var pack HuntPack
+	data := defaultHuntPack
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return pack, err
+		}
+	}
+	if err := yaml.Unmarshal(data, &pack); err != nil {
+		return pack, fmt.Errorf("parse hunt pack: %w", err)
+	}
+	seen := map[string]bool{}
+	for _, h := range pack.Hunts {
+		if h.ID == "" || seen[h.ID] {
+			return pack, fmt.Errorf("hunt pack: missing or duplicate id %q", h.ID)
+		}
+		seen[h.ID] = true
+		for _, p := range h.Params {
+			if _, ok := (huntParams{}).values()[p]; !ok {
+				return pack, fmt.Errorf("hunt %s: unknown param %q", h.ID, p)
+			}
+		}
+	}
+	return pack, nil
+}
+
+// huntParams are the values a hunt query may bind.
+type huntParams struct {
+	windowStart, windowEnd, lookbackStart time.Time
+	cfg                                   HuntConfig
+}
+
+func (p huntParams) values() map[string]any {
+	return map[string]any{
+		"window_start":   p.windowStart,
+		"window_end":     p.windowEnd,
+		"lookback_start": p.lookbackStart,
+		"business_start": p.cfg.BusinessStart,
+		"business_end":   p.cfg.BusinessEnd,
+		"rare_threshold": p.cfg.RareThreshold,
+	}
+}
+
+// HuntRunner runs the pack on a schedule and queues findings for review.
+type HuntRunner struct {
+	db *store.DB
+
+	mu   sync.RWMutex // guards cfg and pack, which reload swaps
+	cfg  HuntConfig
+	pack HuntPack
+
+	// canary, when set, is a candidate pack run over the same windows. Its
+	// findings are compared with the stable pack's and never queued.
+	canary  *HuntPack
+	monitor *CanaryMonitor
+}
+
+func NewHuntRunner(db *store.DB, cfg HuntConfig) (*HuntRunner, error) {
+	pack, err := loadHuntPack(cfg.PackPath)
+	if err != nil {
+		return nil, err
+	}
+	return &HuntRunner{db: db, cfg: cfg, pack: pack}, nil
+}
+
+// current returns the config and pack in effect.
+func (hr *HuntRunner) current() (HuntConfig, HuntPack) {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	return hr.cfg, hr.pack
+}
+
+// reload swaps in cfg and the pack it names. A pack that fails to load
+// or validate leaves the current one running.
+func (hr *HuntRunner) reload(cfg HuntConfig) error {
+	pack, err := loadHuntPack(cfg.PackPath)
+	if err != nil {
+		return err
+	}
+	hr.mu.Lock()
+	hr.cfg, hr.pack = cfg, pack
+	hr.mu.Unlock()
+	return nil
+}
+
+func (hr *HuntRunner) Run() {
+	cfg, _ := hr.current()
+	interval, _ := time.ParseDuration(cfg.Interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := hr.RunOnce(time.Now())
+		if err != nil {
+			slog.Error("Hunting pack run failed", "err", err)
+		}
+		cfg, pack := hr.current()
+		if n > 0 {
+			slog.Info("Hunting pack queued findings for review", "version", pack.Version, "findings", n)
+		}
+		if d, _ := time.ParseDuration(cfg.Interval); d != interval {
+			interval = d
+			ticker.Reset(interval)
+		}
+	}
+}
+
+// RunOnce runs every hunt over the window ending at now. A failing hunt is
+// logged and skipped so one bad query doesn't stop the rest of the pack.
+func (hr *HuntRunner) RunOnce(now time.Time) (int, error) {
+	cfg, pack := hr.current()
+	window, _ := time.ParseDuration(cfg.Window)
+	lookback, _ := time.ParseDuration(cfg.Lookback)
+	values := huntParams{
+		windowStart:   now.Add(-window),
+		windowEnd:     now,
+		lookbackStart: now.Add(-lookback),
+		cfg:           cfg,
+	}.values()
+
+	var (
+		total   int
+		lastErr error
+	)
+	stable := map[string][]HuntFinding{}
+	for _, h := range pack.Hunts {
+		findings, err := hr.queryHunt(h, pack.Version, values)
+		if err == nil {
+			stable[h.ID] = findings
+			err = hr.queueFindings(findings)
+		}
+		if err != nil {
+			slog.Warn("Hunt failed", "hunt", h.ID, "err", err)
+			lastErr = err
+			continue
+		}
+		total += len(findings)
+	}
+
+	if hr.canary != nil {
+		for _, h := range hr.canary.Hunts {
+			findings, err := hr.queryHunt(h, hr.canary.Version, values)
+			hr.monitor.compareHunt(h.ID, values["window_start"].(time.Time), stable[h.ID], findings, err)
+		}
+	}
+	return total, lastErr
+}
+
+// queryHunt runs h and returns its findings without queueing them.
+func (hr *HuntRunner) queryHunt(h Hunt, packVersion int, values map[string]any) ([]HuntFinding, error) {
+	args := make([]any, len(h.Params))
+	for i, p := range h.Params {
+		args[i] = values[p]
+	}
+	rows, err := hr.db.Query(h.Query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var findings []HuntFinding
+	for rows.Next() {
+		f := HuntFinding{HuntID: h.ID, PackVersion: packVersion, Severity: h.Severity, WindowStart: values["window_start"].(time.Time)}
+		if err := rows.Scan(&f.TenantID, &f.FindingKey, &f.Summary, &f.Hits, &f.FirstSeen, &f.LastSeen); err != nil {
+			return nil, err
+		}
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
+
+func (hr *HuntRunner) queueFindings(findings []HuntFinding) error {
+	for _, f := range findings {
+		if err := queueFinding(hr.db, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queueFinding stores f. Re-running a window updates the existing finding
+// instead of duplicating it.
+func queueFinding(db *store.DB, f HuntFinding) error {
+	_, err := db.Exec(`
+		INSERT INTO hunt_findings (tenant_id, hunt_id, pack_version, severity, finding_key, summary, hits, first_seen, last_seen, window_start)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`+db.Upsert("tenant_id", "hunt_id", "finding_key", "window_start")+` pack_version = `+db.Excluded("pack_version")+`, summary = `+db.Excluded("summary")+`,
+			hits = `+db.Excluded("hits")+`, first_seen = `+db.Excluded("first_seen")+`, last_seen = `+db.Excluded("last_seen"),
+		f.TenantID, f.HuntID, f.PackVersion, f.Severity, f.FindingKey, f.Summary, f.Hits, f.FirstSeen, f.LastSeen, f.WindowStart,
+	)
+	return err
+}
+
+func listFindings(db *store.DB, tenantID, status string, limit int) ([]HuntFinding, error) {
+	query := `SELECT id, tenant_id, hunt_id, pack_version, severity, finding_key, summary, hits, first_seen, last_seen,
+			window_start, status, note, reviewed_by, reviewed_at, created_at
+		FROM hunt_findings WHERE tenant_id = ?`
+	args := []any{tenantID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	rows, err := db.Query(query+` ORDER BY id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	findings := []HuntFinding{}
+	for rows.Next() {
+		var (
+			f              HuntFinding
+			note, reviewer sql.NullString
+			reviewed       sql.NullTime
+		)
+		if err := rows.Scan(&f.ID, &f.TenantID, &f.HuntID, &f.PackVersion, &f.Severity, &f.FindingKey, &f.Summary, &f.Hits,
+			&f.FirstSeen, &f.LastSeen, &f.WindowStart, &f.Status, &note, &reviewer, &reviewed, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		f.Note, f.ReviewedBy, f.ReviewedAt = note.String, reviewer.String, timePtr(reviewed)
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
+
+func reviewFinding(db *store.DB, tenantID string, id int64, status, note, actor string) (bool, error) {
+	res, err := db.Exec(`
+		UPDATE hunt_findings SET status = ?, note = ?, reviewed_by = ?, reviewed_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND tenant_id = ?`,
+		status, nullString(note), actor, id, tenantID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// --- HTTP Handlers ---
+
+// huntPackHandler serves GET /api/hunts, describing the active pack.
+func (hr *HuntRunner) huntPackHandler(w http.ResponseWriter, r *http.Request) {
+	_, pack := hr.current()
+	writeJSON(w, http.StatusOK, pack)
+}
+
+// findingsHandler serves GET /api/hunts/findings?status=new.
+func findingsHandler(db *store.DB, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := cfg.DefaultPageSize
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > cfg.MaxPageSize {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("'limit' must be between 1 and %d", cfg.MaxPageSize))
+				return
+			}
+			limit = n
+		}
+		findings, err := listFindings(db, tenantFromRequest(r), r.URL.Query().Get("status"), limit)
+		if err != nil {
+			slog.Error("Failed to list hunt findings", "err", err)
+			writeError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		writeJSON(w, http.StatusOK, findings)
+	}
+}
+
+// reviewFindingHandler serves POST /api/hunts/findings/{id}/review with a
+// body of {"status": "confirmed"|"dismissed"|"new", "note": "..."}.
+func reviewFindingHandler(db *store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid finding id")
+			return
+		}
+		var body struct {
+			Status string `json:"status"`
+			Note   string `json:"note"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		switch body.Status {
+		case findingNew, findingConfirmed, findingDismissed:
+		default:
+			writeError(w, http.StatusBadRequest, "'status' must be new, confirmed, or dismissed")
+			return
+		}
+
+		tenant, actor := tenantFromRequest(r), requestActor(r)
+		ok, err := reviewFinding(db, tenant, id, body.Status, body.Note, actor)
+		if err != nil {
+			slog.Error("Failed to review hunt finding", "id", id, "err", err)
+			writeError(w, http.StatusInternalServerError, "failed to review finding")
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusNotFound, "no finding with that id")
+			return
+		}
+		recordAudit(db, tenant, actor, "hunt.review", map[string]any{"id": id, "status": body.Status, "note": body.Note})
+		writeJSON(w, http.StatusOK, map[string]any{"id": id, "status": body.Status})
+	}
+}
+
+// --- CLI ---
+
+// huntsCommand implements `hunts list` and `hunts run`.
+func huntsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: hunts <list|run> [flags]")
+	}
+	sub, args := args[0], args[1:]
+	fs := flag.NewFlagSet("hunts "+sub, flag.ExitOnError)
+	configPath := fs.String("config", "../config.yaml", "path to config file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	cfg := config.Hunts.WithDefaults()
+
+	switch sub {
+	case "list":
+		pack, err := loadHuntPack(cfg.PackPath)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Pack version %d\n\nID\tSEVERITY\tNAME\n", pack.Version)
+		for _, h := range pack.Hunts {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", h.ID, h.Severity, h.Name)
+		}
+		return tw.Flush()
+
+	case "run":
+		db, err := store.Open(config)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		hr, err := NewHuntRunner(db, cfg)
+		if err != nil {
+			return err
+		}
+		n, err := hr.RunOnce(time.Now())
+		fmt.Printf("Queued %d findings from pack v%d\n", n, hr.pack.Version)
+		return err
+
+	default:
+		return fmt.Errorf("unknown hunts command %q", sub)
+	}
+}
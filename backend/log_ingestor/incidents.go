@@ -0,0 +1,548 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/pipeline"
+	"1logx/log_ingestor/internal/store"
+)
+
+var (
+	incidentStatuses   = []string{"OPEN", "INVESTIGATING", "MITIGATED", "CLOSED"}
+	incidentSeverities = []string{"LOW", "MEDIUM", "HIGH", "CRITICAL"}
+)
+
+var errIncidentNotFound = errors.New("incident not found")
+
+// incidentSeverityOf maps a log or alert severity onto the incident scale.
+func incidentSeverityOf(sev Severity) string {
+	switch {
+	case sev >= SeverityCritical:
+		return "CRITICAL"
+	case sev >= SeverityAlert:
+		return "HIGH"
+	case sev >= SeverityWarning:
+		return "MEDIUM"
+	}
+	return "LOW"
+}
+
+// IncidentComment is an analyst note on an incident.
+type IncidentComment struct {
+	ID         int64     `json:"id"`
+	IncidentID int64     `json:"incident_id"`
+	TenantID   string    `json:"tenant_id"`
+	Author     string    `json:"author"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// incidentColumns are the columns scanIncident expects, in order.
+const incidentColumns = "id, tenant_id, log_ids, summary, severity, recommendation, status, assignee, alert_id, created_at, updated_at"
+
+func scanIncident(row interface{ Scan(...any) error }) (Incident, error) {
+	var (
+		inc                                    Incident
+		logIDs                                 []byte
+		summary, severity, recommendation, who sql.NullString
+		alertID                                sql.NullInt64
+		updated                                sql.NullTime
+	)
+	if err := row.Scan(&inc.ID, &inc.TenantID, &logIDs, &summary, &severity, &recommendation, &inc.Status,
+		&who, &alertID, &inc.CreatedAt, &updated); err != nil {
+		return inc, err
+	}
+	inc.Summary, inc.Severity, inc.Recommendation, inc.Assignee = summary.String, severity.String, recommendation.String, who.String
+	inc.AlertID, inc.UpdatedAt = alertID.Int64, updated.Time
+	if inc.UpdatedAt.IsZero() {
+		inc.UpdatedAt = inc.CreatedAt
+	}
+	inc.LogIDs = []int64{}
+	if len(logIDs) > 0 {
+		if err := json.Unmarshal(logIDs, &inc.LogIDs); err != nil {
+			return inc, fmt.Errorf("decode log_ids of incident %d: %w", inc.ID, err)
+		}
+	}
+	return inc, nil
+}
+
+// storeIncident inserts inc, OPEN unless a status is set, and sets its ID.
+func storeIncident(db *store.DB, inc *Incident) error {
+	if inc.LogIDs == nil {
+		inc.LogIDs = []int64{}
+	}
+	logIDs, err := json.Marshal(inc.LogIDs)
+	if err != nil {
+		return err
+	}
+	if inc.Status == "" {
+		inc.Status = "OPEN"
+	}
+	inc.CreatedAt = time.Now()
+	inc.UpdatedAt = inc.CreatedAt
+	var alertID any
+	if inc.AlertID != 0 {
+		alertID = inc.AlertID
+	}
+	inc.ID, err = db.Insert(`
+		INSERT INTO incidents (tenant_id, log_ids, summary, severity, recommendation, status, assignee, alert_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		inc.TenantID, string(logIDs), inc.Summary, inc.Severity, inc.Recommendation, inc.Status,
+		nullString(inc.Assignee), alertID, inc.CreatedAt, inc.UpdatedAt,
+	)
+	return err
+}
+
+func getIncident(db *store.DB, tenant string, id int64) (Incident, error) {
+	inc, err := scanIncident(db.QueryRow(`SELECT `+incidentColumns+` FROM incidents WHERE id = ? AND tenant_id = ?`, id, tenant))
+	if errors.Is(err, sql.ErrNoRows) {
+		return inc, errIncidentNotFound
+	}
+	return inc, err
+}
+
+func listIncidents(db *store.DB, tenant, status, assignee string, limit int) ([]Incident, error) {
+	query := `SELECT ` + incidentColumns + ` FROM incidents WHERE tenant_id = ?`
+	args := []any{tenant}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	if assignee != "" {
+		query += ` AND assignee = ?`
+		args = append(args, assignee)
+	}
+	rows, err := db.Query(query+` ORDER BY id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	incidents := []Incident{}
+	for rows.Next() {
+		inc, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, inc)
+	}
+	return incidents, rows.Err()
+}
+
+// incidentUpdate holds the triage fields a PATCH may change; nil fields are
+// left as they are.
+type incidentUpdate struct {
+	Status   *string `json:"status"`
+	Assignee *string `json:"assignee"`
+	Severity *string `json:"severity"`
+}
+
+func (u *incidentUpdate) validate() error {
+	if u.Status == nil && u.Assignee == nil && u.Severity == nil {
+		return fmt.Errorf("nothing to update; set status, assignee, or severity")
+	}
+	if u.Status != nil {
+		*u.Status = strings.ToUpper(*u.Status)
+		if !contains(incidentStatuses, *u.Status) {
+			return fmt.Errorf("'status' must be one of %s", strings.Join(incidentStatuses, ", "))
+		}
+	}
+	if u.Severity != nil {
+		*u.Severity = strings.ToUpper(*u.Severity)
+		if !contains(incidentSeverities, *u.Severity) {
+			return fmt.Errorf("'severity' must be one of %s", strings.Join(incidentSeverities, ", "))
+		}
+	}
+	return nil
+}
+
+func updateIncident(db *store.DB, tenant string, id int64, u incidentUpdate) (Incident, error) {
+	var (
+		sets []string
+		args []any
+	)
+	if u.Status != nil {
+		sets, args = append(sets, "status = ?"), append(args, *u.Status)
+	}
+	if u.Assignee != nil {
+		sets, args = append(sets, "assignee = ?"), append(args, nullString(*u.Assignee))
+	}
+	if u.Severity != nil {
+		sets, args = append(sets, "severity = ?"), append(args, *u.Severity)
+	}
+	sets = append(sets, "updated_at = ?")
+	args = append(args, time.Now(), id, tenant)
+	// MySQL reports no affected rows for no-op updates too, so a missing
+	// incident is found by reading it back.
+	if _, err := db.Exec(`UPDATE incidents SET `+strings.Join(sets, ", ")+` WHERE id = ? AND tenant_id = ?`, args...); err != nil {
+		return Incident{}, err
+	}
+	return getIncident(db, tenant, id)
+}
+
+// attachLogs adds the tenant's logs ids to an incident, ignoring ones
+// already attached. It returns an error naming any ID that isn't a log of
+// the tenant.
+func attachLogs(db *store.DB, tenant string, id int64, ids []int64) (Incident, error) {
+	logs, err := loadLogsByID(db, tenant, ids)
+	if err != nil {
+		return Incident{}, err
+	}
+	found := map[int64]bool{}
+	for _, e := range logs {
+		found[e.ID] = true
+	}
+	for _, logID := range ids {
+		if !found[logID] {
+			return Incident{}, fmt.Errorf("%w: no log %d", pipeline.ErrInvalidEntry, logID)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return Incident{}, err
+	}
+	defer tx.Rollback()
+	inc, err := scanIncident(tx.QueryRow(`SELECT `+incidentColumns+` FROM incidents WHERE id = ? AND tenant_id = ?`+tx.ForUpdate(), id, tenant))
+	if errors.Is(err, sql.ErrNoRows) {
+		return inc, errIncidentNotFound
+	}
+	if err != nil {
+		return inc, err
+	}
+	for _, logID := range ids {
+		if !contains(inc.LogIDs, logID) {
+			inc.LogIDs = append(inc.LogIDs, logID)
+		}
+	}
+	data, _ := json.Marshal(inc.LogIDs)
+	inc.UpdatedAt = time.Now()
+	if _, err := tx.Exec(`UPDATE incidents SET log_ids = ?, updated_at = ? WHERE id = ?`, string(data), inc.UpdatedAt, id); err != nil {
+		return inc, err
+	}
+	return inc, tx.Commit()
+}
+
+func addIncidentComment(db *store.DB, c *IncidentComment) error {
+	c.CreatedAt = time.Now()
+	var err error
+	c.ID, err = db.Insert(`
+		INSERT INTO incident_comments (incident_id, tenant_id, author, body, created_at) VALUES (?, ?, ?, ?, ?)`,
+		c.IncidentID, c.TenantID, c.Author, c.Body, c.CreatedAt,
+	)
+	return err
+}
+
+func listIncidentComments(db *store.DB, tenant string, incidentID int64) ([]IncidentComment, error) {
+	rows, err := db.Query(`
+		SELECT id, incident_id, tenant_id, author, body, created_at FROM incident_comments
+		WHERE incident_id = ? AND tenant_id = ? ORDER BY id`, incidentID, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []IncidentComment{}
+	for rows.Next() {
+		var c IncidentComment
+		if err := rows.Scan(&c.ID, &c.IncidentID, &c.TenantID, &c.Author, &c.Body, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// getAlert reads one of the tenant's alerts.
+func getAlert(db *store.DB, tenant string, id int64) (Alert, error) {
+	var (
+		a       Alert
+		details []byte
+	)
+	err := db.QueryRow(`
+		SELECT id, tenant_id, kind, severity, title, details, created_at FROM alerts
+		WHERE id = ? AND tenant_id = ?`, id, tenant,
+	).Scan(&a.ID, &a.TenantID, &a.Kind, &a.Severity, &a.Title, &details, &a.CreatedAt)
+	if err != nil {
+		return a, err
+	}
+	if len(details) > 0 {
+		json.Unmarshal(details, &a.Details)
+	}
+	return a, nil
+}
+
+// incidentFromAlert describes an incident opened for a.
+func incidentFromAlert(a Alert) Incident {
+	inc := Incident{
+		TenantID: a.TenantID,
+		AlertID:  a.ID,
+		Summary:  a.Title,
+		Severity: incidentSeverityOf(a.Severity),
+	}
+	if len(a.Details) > 0 {
+		details, _ := json.Marshal(a.Details)
+		inc.Summary += "\n\nAlert details: " + string(details)
+	}
+	return inc
+}
+
+// openIncidentForAlert opens an incident for a just-raised alert whose kind
+// is configured to auto-create one.
+func openIncidentForAlert(db *store.DB, h *streamHub, a Alert) {
+	inc := incidentFromAlert(a)
+	if err := storeIncident(db, &inc); err != nil {
+		slog.Warn("Failed to open incident for alert", "title", a.Title, "err", err)
+		return
+	}
+	slog.Info("Incident opened from alert", "id", inc.ID, "kind", a.Kind)
+	publishIncident(h, inc)
+}
+
+// publishIncident pushes an incident's current state to its tenant's stream.
+func publishIncident(h *streamHub, inc Incident) {
+	h.BroadcastMessage(inc.TenantID, wsMessage{Type: "incident", Data: inc})
+}
+
+// --- HTTP Handlers ---
+
+func incidentID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid incident id")
+		return 0, false
+	}
+	return id, true
+}
+
+// writeIncidentError maps incident store errors onto responses.
+func writeIncidentError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, errIncidentNotFound):
+		writeError(w, http.StatusNotFound, "no incident with that id")
+	case errors.Is(err, pipeline.ErrInvalidEntry):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		slog.Error("Incident operation failed", "op", op, "err", err)
+		writeError(w, http.StatusInternalServerError, "failed to "+op)
+	}
+}
+
+// listIncidentsHandler serves GET /api/incidents?status=OPEN&assignee=...
+func listIncidentsHandler(db *store.DB, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := cfg.DefaultPageSize
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > cfg.MaxPageSize {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("'limit' must be between 1 and %d", cfg.MaxPageSize))
+				return
+			}
+			limit = n
+		}
+		status := strings.ToUpper(r.URL.Query().Get("status"))
+		incidents, err := listIncidents(db, tenantFromRequest(r), status, r.URL.Query().Get("assignee"), limit)
+		if err != nil {
+			writeIncidentError(w, "list incidents", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, incidents)
+	}
+}
+
+// createIncidentHandler serves POST /api/incidents, opening an incident from
+// selected logs ({"log_ids": [...]}) or an alert ({"alert_id": n}).
+func createIncidentHandler(db *store.DB, h *streamHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			LogIDs         []int64 `json:"log_ids"`
+			AlertID        int64   `json:"alert_id"`
+			Summary        string  `json:"summary"`
+			Severity       string  `json:"severity"`
+			Recommendation string  `json:"recommendation"`
+			Assignee       string  `json:"assignee"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if len(body.LogIDs) == 0 && body.AlertID == 0 {
+			writeError(w, http.StatusBadRequest, "provide 'log_ids' or 'alert_id'")
+			return
+		}
+		tenant, actor := tenantFromRequest(r), requestActor(r)
+
+		inc := Incident{TenantID: tenant, Severity: "MEDIUM"}
+		if body.AlertID != 0 {
+			a, err := getAlert(db, tenant, body.AlertID)
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, http.StatusNotFound, "no alert with that id")
+				return
+			}
+			if err != nil {
+				writeIncidentError(w, "load alert", err)
+				return
+			}
+			inc = incidentFromAlert(a)
+		}
+		if len(body.LogIDs) > 0 {
+			logs, err := loadLogsByID(db, tenant, body.LogIDs)
+			if err != nil {
+				writeIncidentError(w, "load logs", err)
+				return
+			}
+			if len(logs) != len(body.LogIDs) {
+				writeError(w, http.StatusBadRequest, "some 'log_ids' are not logs of this tenant")
+				return
+			}
+			worst := SeverityInfo
+			for _, e := range logs {
+				inc.LogIDs = append(inc.LogIDs, e.ID)
+				worst = max(worst, e.Severity)
+			}
+			if body.AlertID == 0 {
+				inc.Severity = incidentSeverityOf(worst)
+				inc.Summary = fmt.Sprintf("Incident opened by %s from %d logs", actor, len(logs))
+			}
+		}
+
+		if body.Summary != "" {
+			inc.Summary = body.Summary
+		}
+		if body.Severity != "" {
+			inc.Severity = strings.ToUpper(body.Severity)
+			if !contains(incidentSeverities, inc.Severity) {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("'severity' must be one of %s", strings.Join(incidentSeverities, ", ")))
+				return
+			}
+		}
+		inc.Recommendation, inc.Assignee = body.Recommendation, body.Assignee
+
+		if err := storeIncident(db, &inc); err != nil {
+			writeIncidentError(w, "create incident", err)
+			return
+		}
+		recordAudit(db, tenant, actor, "incident.create", map[string]any{"id": inc.ID, "log_ids": inc.LogIDs, "alert_id": inc.AlertID})
+		publishIncident(h, inc)
+		writeJSON(w, http.StatusCreated, inc)
+	}
+}
+
+// getIncidentHandler serves GET /api/incidents/{id} with its comments.
+func getIncidentHandler(db *store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := incidentID(w, r)
+		if !ok {
+			return
+		}
+		tenant := tenantFromRequest(r)
+		inc, err := getIncident(db, tenant, id)
+		if err != nil {
+			writeIncidentError(w, "load incident", err)
+			return
+		}
+		comments, err := listIncidentComments(db, tenant, id)
+		if err != nil {
+			writeIncidentError(w, "load comments", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"incident": inc, "comments": comments})
+	}
+}
+
+// updateIncidentHandler serves PATCH /api/incidents/{id} with any of
+// {"status", "assignee", "severity"}.
+func updateIncidentHandler(db *store.DB, h *streamHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := incidentID(w, r)
+		if !ok {
+			return
+		}
+		var u incidentUpdate
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		if err := u.validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		tenant, actor := tenantFromRequest(r), requestActor(r)
+		inc, err := updateIncident(db, tenant, id, u)
+		if err != nil {
+			writeIncidentError(w, "update incident", err)
+			return
+		}
+		recordAudit(db, tenant, actor, "incident.update", map[string]any{"id": id, "changes": u})
+		publishIncident(h, inc)
+		writeJSON(w, http.StatusOK, inc)
+	}
+}
+
+// attachLogsHandler serves POST /api/incidents/{id}/logs with {"log_ids": [...]}.
+func attachLogsHandler(db *store.DB, h *streamHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := incidentID(w, r)
+		if !ok {
+			return
+		}
+		var body struct {
+			LogIDs []int64 `json:"log_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.LogIDs) == 0 {
+			writeError(w, http.StatusBadRequest, "body must be {\"log_ids\": [...]}")
+			return
+		}
+
+		tenant, actor := tenantFromRequest(r), requestActor(r)
+		inc, err := attachLogs(db, tenant, id, body.LogIDs)
+		if err != nil {
+			writeIncidentError(w, "attach logs", err)
+			return
+		}
+		recordAudit(db, tenant, actor, "incident.attach_logs", map[string]any{"id": id, "log_ids": body.LogIDs})
+		publishIncident(h, inc)
+		writeJSON(w, http.StatusOK, inc)
+	}
+}
+
+// commentIncidentHandler serves POST /api/incidents/{id}/comments with
+// {"body": "..."}. Comments are pushed to the stream as incident_comment.
+func commentIncidentHandler(db *store.DB, h *streamHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := incidentID(w, r)
+		if !ok {
+			return
+		}
+		var body struct {
+			Body string `json:"body"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body); err != nil || strings.TrimSpace(body.Body) == "" {
+			writeError(w, http.StatusBadRequest, "body must be {\"body\": \"...\"}")
+			return
+		}
+
+		tenant := tenantFromRequest(r)
+		if _, err := getIncident(db, tenant, id); err != nil {
+			writeIncidentError(w, "load incident", err)
+			return
+		}
+		c := IncidentComment{IncidentID: id, TenantID: tenant, Author: requestActor(r), Body: body.Body}
+		if err := addIncidentComment(db, &c); err != nil {
+			writeIncidentError(w, "add comment", err)
+			return
+		}
+		db.Exec(`UPDATE incidents SET updated_at = ? WHERE id = ?`, c.CreatedAt, id)
+		h.BroadcastMessage(tenant, wsMessage{Type: "incident_comment", Data: c})
+		writeJSON(w, http.StatusCreated, c)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"1logx/log_ingestor/internal/pipeline"
+)
+
+// maxIngestBody caps an ingest request body.
+const maxIngestBody = 5 << 20
+
+// readIngestBody reads an ingest request body, gunzipping it if it's
+// gzip-encoded, and writes the error response if that fails. The cap applies
+// to the decompressed body as well, so a small gzip bomb can't exhaust memory.
+func readIngestBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	var body io.Reader = http.MaxBytesReader(w, r.Body, maxIngestBody)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid gzip body")
+			return nil, false
+		}
+		defer gz.Close()
+		body = http.MaxBytesReader(w, gz, maxIngestBody)
+	}
+	data, err := io.ReadAll(body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("body exceeds %d bytes", maxIngestBody))
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read body")
+		return nil, false
+	}
+	return data, true
+}
+
+// decodeEntries accepts either a single JSON LogEntry or an array of them.
+func decodeEntries(r io.Reader) ([]LogEntry, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	body = []byte(strings.TrimSpace(string(body)))
+	if len(body) > 0 && body[0] == '[' {
+		var entries []LogEntry
+		err := json.Unmarshal(body, &entries)
+		return entries, err
+	}
+	var entry LogEntry
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return nil, err
+	}
+	return []LogEntry{entry}, nil
+}
+
+// requestOrigin identifies the client behind an ingest request.
+func requestOrigin(r *http.Request) pipeline.Origin {
+	origin := pipeline.Origin{IP: clientIP(r)}
+	if key := keyFromContext(r.Context()); key != nil {
+		origin.Key = key.Prefix
+	}
+	return origin
+}
+
+// ingestHandler serves POST /api/ingest, accepting one entry or an array.
+func ingestHandler(in *pipeline.Ingestor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entries, err := decodeEntries(http.MaxBytesReader(w, r.Body, maxIngestBody))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		ingestEntries(w, r, in, "http", entries)
+	}
+}
+
+// ingestEntries stores entries in order for an ingest request to input and
+// writes the response: the IDs accepted, or the index of the entry that
+// stopped it.
+func ingestEntries(w http.ResponseWriter, r *http.Request, in *pipeline.Ingestor, input string, entries []LogEntry) {
+	// The tenant always comes from the credential, never the payload.
+	tenant := tenantFromRequest(r)
+	origin := requestOrigin(r)
+	origin.Input = input
+	ids := make([]int64, 0, len(entries))
+	queued, shed := 0, 0
+	for i, entry := range entries {
+		entry.TenantID = tenant
+		stored, err := in.IngestFrom(origin, entry)
+		if errors.Is(err, pipeline.ErrQueuedForRetry) {
+			queued++
+			continue
+		}
+		if errors.Is(err, pipeline.ErrShed) {
+			shed++
+			continue
+		}
+		if errors.Is(err, pipeline.ErrRateLimited) || errors.Is(err, pipeline.ErrSourcePaused) {
+			// Entries from index on weren't stored; the client resends them.
+			status := http.StatusTooManyRequests
+			if errors.Is(err, pipeline.ErrSourcePaused) {
+				status = http.StatusServiceUnavailable
+			}
+			w.Header().Set("Retry-After", "1")
+			writeJSON(w, status, map[string]any{
+				"error":    err.Error(),
+				"index":    i,
+				"accepted": ids,
+			})
+			return
+		}
+		if err != nil {
+			status := http.StatusBadRequest
+			if !errors.Is(err, pipeline.ErrInvalidEntry) {
+				slog.Error("Failed to ingest log", "err", err)
+				status = http.StatusInternalServerError
+			}
+			writeJSON(w, status, map[string]any{
+				"error":    err.Error(),
+				"index":    i,
+				"accepted": ids,
+			})
+			return
+		}
+		ids = append(ids, stored.ID)
+	}
+	resp := map[string]any{"accepted": ids}
+	if queued > 0 {
+		resp["queued"] = queued // stored once the database recovers
+	}
+	if shed > 0 {
+		resp["shed"] = shed // dropped by the circuit breaker
+		w.Header().Set("Retry-After", "1")
+	}
+	writeJSON(w, http.StatusAccepted, resp)
+}
@@ -0,0 +1,25 @@
+package config
+
+import "time"
+
+// AggregatesConfig controls the rolling counts pushed to WebSocket clients as
+// "aggregates" messages, so dashboards get live widgets without polling.
+type AggregatesConfig struct {
+	Enabled  bool     `yaml:"enabled"`
+	Interval string   `yaml:"interval"` // how often aggregates are pushed
+	Windows  []string `yaml:"windows"`  // rolling windows, e.g. ["1m", "5m", "1h"]
+	Top      int      `yaml:"top"`      // sources and IPs listed per window
+}
+
+func (c AggregatesConfig) WithDefaults() AggregatesConfig {
+	if d, err := time.ParseDuration(c.Interval); err != nil || d < time.Second {
+		c.Interval = "5s"
+	}
+	if len(c.Windows) == 0 {
+		c.Windows = []string{"1m", "5m", "1h"}
+	}
+	if c.Top <= 0 {
+		c.Top = 10
+	}
+	return c
+}
@@ -0,0 +1,31 @@
+package config
+
+import "time"
+
+// APIConfig holds limits for the query API.
+type APIConfig struct {
+	DefaultPageSize int     `yaml:"default_page_size"`
+	MaxPageSize     int     `yaml:"max_page_size"`
+	MaxQueryCost    float64 `yaml:"max_query_cost"`
+	DefaultWindow   string  `yaml:"default_window"` // e.g. "24h"
+}
+
+// withDefaults fills in any limits left unset in config.
+func (c APIConfig) WithDefaults() APIConfig {
+	if c.DefaultPageSize <= 0 {
+		c.DefaultPageSize = 100
+	}
+	if c.MaxPageSize <= 0 {
+		c.MaxPageSize = 1000
+	}
+	if c.DefaultPageSize > c.MaxPageSize {
+		c.DefaultPageSize = c.MaxPageSize
+	}
+	if c.MaxQueryCost <= 0 {
+		c.MaxQueryCost = 2000
+	}
+	if _, err := time.ParseDuration(c.DefaultWindow); err != nil {
+		c.DefaultWindow = "24h"
+	}
+	return c
+}
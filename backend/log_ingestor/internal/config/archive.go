@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// ArchiveConfig moves logs that age out of retention to object storage
+// instead of dropping them. Objects are partitioned by tenant and hour:
+//
+//	<prefix>/tenant=<id>/date=YYYY-MM-DD/hour=HH/<first id>-<last id>.ndjson.gz
+//
+// which Athena, BigQuery, and Spark can query as Hive-style partitions.
+type ArchiveConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Backend   string `yaml:"backend"` // s3, gcs, or file
+	Bucket    string `yaml:"bucket"`
+	Prefix    string `yaml:"prefix"`
+	Region    string `yaml:"region"`
+	Endpoint  string `yaml:"endpoint"`   // for S3-compatible stores, e.g. MinIO
+	AccessKey string `yaml:"access_key"` // or ARCHIVE_ACCESS_KEY / AWS_ACCESS_KEY_ID
+	SecretKey string `yaml:"secret_key"` // or ARCHIVE_SECRET_KEY / AWS_SECRET_ACCESS_KEY
+	Path      string `yaml:"path"`       // directory for the file backend
+	// Format is ndjson (gzipped) or parquet (GZIP pages). Only ndjson
+	// archives can be restored into TiDB; parquet suits analytics engines.
+	Format string `yaml:"format"`
+}
+
+func (c ArchiveConfig) WithDefaults() ArchiveConfig {
+	if c.Backend == "" {
+		c.Backend = "s3"
+	}
+	if c.Prefix == "" {
+		c.Prefix = "logs"
+	}
+	c.Prefix = strings.Trim(c.Prefix, "/")
+	switch c.Backend {
+	case "s3":
+		if c.Region == "" {
+			c.Region = "us-east-1"
+		}
+		if c.Endpoint == "" {
+			c.Endpoint = "https://s3." + c.Region + ".amazonaws.com"
+		}
+	case "gcs":
+		// GCS's XML API is S3-compatible with HMAC keys.
+		if c.Region == "" {
+			c.Region = "auto"
+		}
+		if c.Endpoint == "" {
+			c.Endpoint = "https://storage.googleapis.com"
+		}
+	}
+	if c.AccessKey == "" {
+		c.AccessKey = firstEnv("ARCHIVE_ACCESS_KEY", "AWS_ACCESS_KEY_ID")
+	}
+	if c.SecretKey == "" {
+		c.SecretKey = firstEnv("ARCHIVE_SECRET_KEY", "AWS_SECRET_ACCESS_KEY")
+	}
+	if c.Path == "" {
+		c.Path = "archive"
+	}
+	if c.Format != "parquet" {
+		c.Format = "ndjson"
+	}
+	return c
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
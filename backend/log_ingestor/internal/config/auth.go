@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// AuthConfig controls API key enforcement and credential hygiene checks.
+type AuthConfig struct {
+	RequireIngestKey bool   `yaml:"require_ingest_key"`
+	StaleAfter       string `yaml:"stale_after"`    // flag keys unused for this long, e.g. "720h"
+	CheckInterval    string `yaml:"check_interval"` // how often hygiene is checked
+	// JWT, when configured, accepts identity provider tokens as credentials.
+	JWT JWTConfig `yaml:"jwt"`
+}
+
+func (c AuthConfig) WithDefaults() AuthConfig {
+	if d, err := time.ParseDuration(c.StaleAfter); err != nil || d <= 0 {
+		c.StaleAfter = "720h"
+	}
+	if d, err := time.ParseDuration(c.CheckInterval); err != nil || d <= 0 {
+		c.CheckInterval = "1h"
+	}
+	c.JWT = c.JWT.WithDefaults()
+	return c
+}
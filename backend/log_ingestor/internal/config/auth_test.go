@@ -0,0 +1,24 @@
+package config
+
+import "testing"
+
+func TestAuthConfigIntervals(t *testing.T) {
+	tests := []struct {
+		in, wantCheck, wantStale string
+	}{
+		{"", "1h", "720h"},
+		{"soon", "1h", "720h"},
+		{"0s", "1h", "720h"},
+		{"-5m", "1h", "720h"},
+		{"15m", "15m", "15m"},
+	}
+	for _, tt := range tests {
+		c := AuthConfig{CheckInterval: tt.in, StaleAfter: tt.in}.WithDefaults()
+		if c.CheckInterval != tt.wantCheck {
+			t.Errorf("check_interval %q = %q, want %q", tt.in, c.CheckInterval, tt.wantCheck)
+		}
+		if c.StaleAfter != tt.wantStale {
+			t.Errorf("stale_after %q = %q, want %q", tt.in, c.StaleAfter, tt.wantStale)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package config
+
+import "time"
+
+// AutoscaleConfig defines when a replica counts as fully loaded. Each signal
+// is divided by its target, so a pressure of 1 means "at capacity" and an
+// autoscaler targeting e.g. 0.7 keeps headroom.
+type AutoscaleConfig struct {
+	Interval       string  `yaml:"interval"`         // sampling interval
+	Smoothing      float64 `yaml:"smoothing"`        // EWMA weight of the newest sample, 0-1
+	TargetInFlight float64 `yaml:"target_in_flight"` // concurrent ingests
+	TargetQueue    float64 `yaml:"target_queue"`     // retry queue fill, 0-1
+	TargetLatency  string  `yaml:"target_latency"`   // mean insert latency
+	TargetCPU      float64 `yaml:"target_cpu"`       // process CPU utilization, 0-1
+}
+
+func (c AutoscaleConfig) WithDefaults() AutoscaleConfig {
+	if d, err := time.ParseDuration(c.Interval); err != nil || d <= 0 {
+		c.Interval = "5s"
+	}
+	if c.Smoothing <= 0 || c.Smoothing > 1 {
+		c.Smoothing = 0.3
+	}
+	if c.TargetInFlight <= 0 {
+		c.TargetInFlight = 200
+	}
+	if c.TargetQueue <= 0 || c.TargetQueue > 1 {
+		c.TargetQueue = 0.5
+	}
+	if d, err := time.ParseDuration(c.TargetLatency); err != nil || d <= 0 {
+		c.TargetLatency = "100ms"
+	}
+	if c.TargetCPU <= 0 || c.TargetCPU > 1 {
+		c.TargetCPU = 0.8
+	}
+	return c
+}
@@ -0,0 +1,33 @@
+package config
+
+import "time"
+
+// CanaryConfig routes a share of live traffic through candidate versions of
+// the parser and hunting pack, next to the stable ones, and reports where
+// their outputs diverge. Canary output is only compared, never stored.
+type CanaryConfig struct {
+	Parser struct {
+		Stable    string  `yaml:"stable"`    // parser version used by the pipeline
+		Candidate string  `yaml:"candidate"` // version compared against it; empty disables
+		Percent   float64 `yaml:"percent"`   // share of structured messages also parsed by the candidate
+	} `yaml:"parser"`
+	HuntPack string `yaml:"hunt_pack"` // candidate hunting pack, run on the stable pack's schedule
+	Samples  int    `yaml:"samples"`   // divergences kept for the report
+	Interval string `yaml:"interval"`  // how often divergence totals are logged
+}
+
+func (c CanaryConfig) WithDefaults() CanaryConfig {
+	if c.Parser.Stable == "" {
+		c.Parser.Stable = "v1"
+	}
+	if c.Parser.Percent <= 0 || c.Parser.Percent > 100 {
+		c.Parser.Percent = 5
+	}
+	if c.Samples <= 0 {
+		c.Samples = 100
+	}
+	if d, err := time.ParseDuration(c.Interval); err != nil || d <= 0 {
+		c.Interval = "15m"
+	}
+	return c
+}
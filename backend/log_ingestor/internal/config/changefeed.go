@@ -0,0 +1,49 @@
+package config
+
+import "time"
+
+// ChangeFeedConfig streams the logs table's changes to WebSocket clients in
+// place of the ingestor's own inserts, so rows written by other services or
+// bulk backfills show up live too.
+//
+// The ticdc source reads a TiCDC storage sink using the canal-json protocol,
+// e.g. a changefeed created with
+//
+//	--sink-uri="s3://bucket/cdc?protocol=canal-json"
+//
+// The poll source tails the table instead. It needs no TiCDC, but since ids
+// aren't allocated in commit order it reads rows by created_at, re-reading
+// the last Overlap each poll; a row that takes longer than that to commit
+// after it's inserted is never streamed.
+type ChangeFeedConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Source    string        `yaml:"source"`     // ticdc (default) or poll
+	Interval  string        `yaml:"interval"`   // how often new changes are fetched
+	Database  string        `yaml:"database"`   // schema in sink paths; default the tidb database
+	BatchSize int           `yaml:"batch_size"` // poll: rows per query
+	Overlap   string        `yaml:"overlap"`    // poll: how far back each poll re-reads for late commits
+	Storage   ArchiveConfig `yaml:"storage"`    // ticdc: where the sink writes, as for archives
+}
+
+func (c ChangeFeedConfig) WithDefaults() ChangeFeedConfig {
+	if c.Source != "poll" {
+		c.Source = "ticdc"
+	}
+	if d, err := time.ParseDuration(c.Interval); err != nil || d <= 0 {
+		c.Interval = "2s"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 500
+	}
+	if d, err := time.ParseDuration(c.Overlap); err != nil || d <= 0 {
+		c.Overlap = "30s"
+	}
+	if c.Storage.Prefix == "" {
+		c.Storage.Prefix = "cdc"
+	}
+	if c.Storage.Path == "" {
+		c.Storage.Path = "cdc"
+	}
+	c.Storage = c.Storage.WithDefaults()
+	return c
+}
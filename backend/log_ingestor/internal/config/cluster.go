@@ -0,0 +1,35 @@
+package config
+
+import "github.com/nats-io/nats.go"
+
+// ClusterConfig shares the live stream between ingestor replicas. Every
+// broadcast is relayed over Redis pub/sub or NATS, so WebSocket clients see
+// the full stream whichever replica they're connected to.
+type ClusterConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Backend   string `yaml:"backend"`    // redis (default) or nats
+	Channel   string `yaml:"channel"`    // Redis channel or NATS subject
+	Addr      string `yaml:"addr"`       // Redis address or NATS URL
+	Password  string `yaml:"password"`   // Redis only
+	DB        int    `yaml:"db"`         // Redis only
+	QueueSize int    `yaml:"queue_size"` // outgoing events buffered before dropping
+}
+
+func (c ClusterConfig) WithDefaults() ClusterConfig {
+	if c.Backend != "nats" {
+		c.Backend = "redis"
+	}
+	if c.Channel == "" {
+		c.Channel = "1l0gx.cluster"
+	}
+	if c.Addr == "" {
+		c.Addr = "localhost:6379"
+		if c.Backend == "nats" {
+			c.Addr = nats.DefaultURL
+		}
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 10000
+	}
+	return c
+}
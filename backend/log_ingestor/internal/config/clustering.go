@@ -0,0 +1,34 @@
+package config
+
+import "time"
+
+// ClusteringConfig groups similar logs by their embeddings so analysts can
+// triage by pattern. Run it on one replica: clusters are kept in memory
+// between batches.
+type ClusteringConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	Interval    string  `yaml:"interval"`     // how often new logs are clustered
+	BatchSize   int     `yaml:"batch_size"`   // logs read per query
+	Threshold   float64 `yaml:"threshold"`    // cosine similarity needed to join a cluster, 0-1
+	MaxClusters int     `yaml:"max_clusters"` // per tenant; beyond it logs join the nearest cluster
+	ExpireAfter string  `yaml:"expire_after"` // clusters without new logs for this long are dropped
+}
+
+func (c ClusteringConfig) WithDefaults() ClusteringConfig {
+	if d, err := time.ParseDuration(c.Interval); err != nil || d <= 0 {
+		c.Interval = "30s"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 500
+	}
+	if c.Threshold <= 0 || c.Threshold >= 1 {
+		c.Threshold = 0.85
+	}
+	if c.MaxClusters <= 0 {
+		c.MaxClusters = 1000
+	}
+	if d, err := time.ParseDuration(c.ExpireAfter); err != nil || d <= 0 {
+		c.ExpireAfter = "168h"
+	}
+	return c
+}
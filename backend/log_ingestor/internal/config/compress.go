@@ -0,0 +1,44 @@
+package config
+
+import (
+	"compress/flate"
+	"strings"
+)
+
+// CompressionConfig compresses the live stream and REST responses. Log
+// payloads are repetitive, so even the fastest level shrinks them several
+// times over for dashboards on slow links.
+type CompressionConfig struct {
+	WebSocket bool     `yaml:"websocket"` // permessage-deflate, when the client offers it
+	HTTP      bool     `yaml:"http"`      // gzip, when the client accepts it
+	Level     int      `yaml:"level"`     // 1 (fastest) to 9 (smallest)
+	MinSize   int      `yaml:"min_size"`  // HTTP responses smaller than this are sent as is
+	Endpoints []string `yaml:"endpoints"` // HTTP path prefixes to gzip; empty means every /api path
+	// SkipTypes are content type prefixes that are already compressed.
+	SkipTypes []string `yaml:"skip_types"`
+}
+
+func (c CompressionConfig) WithDefaults() CompressionConfig {
+	if c.Level < flate.BestSpeed || c.Level > flate.BestCompression {
+		c.Level = flate.BestSpeed
+	}
+	if c.MinSize <= 0 {
+		c.MinSize = 1024
+	}
+	if len(c.Endpoints) == 0 {
+		c.Endpoints = []string{"/api/"}
+	}
+	if c.SkipTypes == nil {
+		c.SkipTypes = []string{"application/vnd.apache.parquet", "application/gzip", "application/zip", "image/"}
+	}
+	return c
+}
+
+func (c CompressionConfig) Covers(path string) bool {
+	for _, prefix := range c.Endpoints {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
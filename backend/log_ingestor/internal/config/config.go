@@ -0,0 +1,76 @@
+// Package config defines the ingestor's YAML configuration and each
+// section's defaults.
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config struct for database credentials
+type Config struct {
+	TiDB struct {
+		Host     string `yaml:"host"`
+		Port     int    `yaml:"port"`
+		User     string `yaml:"user"`
+		Password string `yaml:"password"`
+		Database string `yaml:"database"`
+	} `yaml:"tidb"`
+	Database    DatabaseConfig    `yaml:"database"`
+	LLM         LLMConfig         `yaml:"llm"`
+	API         APIConfig         `yaml:"api"`
+	Retention   RetentionConfig   `yaml:"retention"`
+	Auth        AuthConfig        `yaml:"auth"`
+	RBAC        RBACConfig        `yaml:"rbac"`
+	Tenancy     TenancyConfig     `yaml:"tenancy"`
+	Forecast    ForecastConfig    `yaml:"forecast"`
+	GRPC        GRPCConfig        `yaml:"grpc"`
+	NetFlow     NetFlowConfig     `yaml:"netflow"`
+	Pipelines   []PipelineConfig  `yaml:"pipelines"`
+	Hunts       HuntConfig        `yaml:"hunts"`
+	Reports     ReportsConfig     `yaml:"reports"`
+	Clustering  ClusteringConfig  `yaml:"clustering"`
+	Stream      StreamConfig      `yaml:"stream"`
+	Simulator   SimulatorConfig   `yaml:"simulator"`
+	Fanout      FanoutConfig      `yaml:"fanout"`
+	Cluster     ClusterConfig     `yaml:"cluster"`
+	ChangeFeed  ChangeFeedConfig  `yaml:"changefeed"`
+	Retry       RetryConfig       `yaml:"retry"`
+	Limits      LimitsConfig      `yaml:"limits"`
+	Redaction   RedactionConfig   `yaml:"redaction"`
+	Parsers     ParsersConfig     `yaml:"parsers"`
+	TLS         TLSConfig         `yaml:"tls"`
+	Schema      SchemaConfig      `yaml:"schema"`
+	Embedding   EmbeddingConfig   `yaml:"embedding"`
+	Search      SearchConfig      `yaml:"search"`
+	Quality     QualityConfig     `yaml:"quality"`
+	Canary      CanaryConfig      `yaml:"canary"`
+	Incidents   IncidentConfig    `yaml:"incidents"`
+	Recent      RecentConfig      `yaml:"recent"`
+	Aggregates  AggregatesConfig  `yaml:"aggregates"`
+	Joins       JoinConfig        `yaml:"joins"`
+	Export      ExportConfig      `yaml:"export"`
+	Risk        RiskConfig        `yaml:"risk"`
+	ThreatIntel ThreatIntelConfig `yaml:"threat_intel"`
+	Sigma       SigmaConfig       `yaml:"sigma"`
+	Reputation  ReputationConfig  `yaml:"reputation"`
+	Autoscale   AutoscaleConfig   `yaml:"autoscale"`
+	Reload      ReloadConfig      `yaml:"reload"`
+	Logging     LoggingConfig     `yaml:"logging"`
+	Compression CompressionConfig `yaml:"compression"`
+}
+
+// Load reads and parses the YAML config file at path.
+func Load(path string) (Config, error) {
+	var config Config
+	configFile, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.Unmarshal(configFile, &config); err != nil {
+		return config, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return config, nil
+}
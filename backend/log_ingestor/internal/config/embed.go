@@ -0,0 +1,60 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+// EmbeddingConfig selects how log messages and search queries are embedded.
+// Both must use the same provider, or vector similarity is meaningless.
+type EmbeddingConfig struct {
+	Provider string `yaml:"provider"` // "hash" (local feature hashing) or "openai" (any OpenAI-compatible /embeddings API)
+	URL      string `yaml:"url"`      // e.g. https://api.openai.com/v1
+	APIKey   string `yaml:"api_key"`
+	Model    string `yaml:"model"`
+	Dims     int    `yaml:"dims"` // must match the logs.embedding column
+	Timeout  string `yaml:"timeout"`
+
+	Backfill EmbeddingBackfillConfig `yaml:"backfill"`
+}
+
+// EmbeddingBackfillConfig paces re-embedding jobs, which fill in logs
+// stored without an embedding or with another model's.
+type EmbeddingBackfillConfig struct {
+	BatchSize int     `yaml:"batch_size"` // logs updated per transaction
+	Rate      float64 `yaml:"rate"`       // embeddings per second, to stay under provider rate limits
+}
+
+func (c EmbeddingBackfillConfig) WithDefaults() EmbeddingBackfillConfig {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.Rate <= 0 {
+		c.Rate = 20
+	}
+	return c
+}
+
+func (c EmbeddingConfig) WithDefaults() EmbeddingConfig {
+	if c.Provider == "" {
+		c.Provider = "hash"
+	}
+	if c.Dims <= 0 {
+		c.Dims = 768
+	}
+	if d, err := time.ParseDuration(c.Timeout); err != nil || d <= 0 {
+		c.Timeout = "10s"
+	}
+	c.Backfill = c.Backfill.WithDefaults()
+	return c
+}
+
+// ModelID identifies the embeddings this config computes, for
+// logs.embedding_model: embeddings from different models, or of different
+// sizes, aren't comparable.
+func (c EmbeddingConfig) ModelID() string {
+	if c.Provider == "hash" {
+		return "hash/" + strconv.Itoa(c.Dims)
+	}
+	return c.Provider + "/" + c.Model + "/" + strconv.Itoa(c.Dims)
+}
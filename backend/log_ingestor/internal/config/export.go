@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// ExportConfig limits GET /api/export.
+type ExportConfig struct {
+	MaxRows      int    `yaml:"max_rows"`       // rows per export
+	RowGroupSize int    `yaml:"row_group_size"` // Parquet rows buffered per row group
+	Timeout      string `yaml:"timeout"`        // per export
+}
+
+func (c ExportConfig) WithDefaults() ExportConfig {
+	if c.MaxRows <= 0 {
+		c.MaxRows = 1000000
+	}
+	if c.RowGroupSize <= 0 {
+		c.RowGroupSize = 10000
+	}
+	if d, err := time.ParseDuration(c.Timeout); err != nil || d <= 0 {
+		c.Timeout = "10m"
+	}
+	return c
+}
@@ -0,0 +1,39 @@
+package config
+
+import "github.com/nats-io/nats.go"
+
+// FanoutConfig publishes every broadcast event to Redis pub/sub and/or NATS,
+// so backend consumers can follow the stream without a WebSocket. Events go
+// to "<prefix>.<tenant>.<type>", e.g. "1l0gx.default.logs" or
+// "1l0gx.acme.alert"; subscribe with PSUBSCRIBE 1l0gx.*.logs (Redis) or
+// 1l0gx.*.logs (NATS).
+type FanoutConfig struct {
+	Prefix    string `yaml:"prefix"`
+	QueueSize int    `yaml:"queue_size"` // events buffered before dropping
+	Redis     struct {
+		Enabled  bool   `yaml:"enabled"`
+		Addr     string `yaml:"addr"`
+		Password string `yaml:"password"`
+		DB       int    `yaml:"db"`
+	} `yaml:"redis"`
+	NATS struct {
+		Enabled bool   `yaml:"enabled"`
+		URL     string `yaml:"url"`
+	} `yaml:"nats"`
+}
+
+func (c FanoutConfig) WithDefaults() FanoutConfig {
+	if c.Prefix == "" {
+		c.Prefix = "1l0gx"
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 10000
+	}
+	if c.Redis.Addr == "" {
+		c.Redis.Addr = "localhost:6379"
+	}
+	if c.NATS.URL == "" {
+		c.NATS.URL = nats.DefaultURL
+	}
+	return c
+}
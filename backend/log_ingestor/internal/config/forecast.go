@@ -0,0 +1,52 @@
+package config
+
+import "time"
+
+// ForecastConfig controls the severity trend forecasting job.
+type ForecastConfig struct {
+	Enabled   bool    `yaml:"enabled"`
+	Interval  string  `yaml:"interval"`   // how often to refit
+	Bucket    string  `yaml:"bucket"`     // aggregation bucket, e.g. "1h"
+	History   string  `yaml:"history"`    // how much history to fit on
+	Season    int     `yaml:"season"`     // buckets per seasonal cycle (24 for daily with 1h buckets)
+	Horizon   int     `yaml:"horizon"`    // buckets to forecast ahead
+	Sustain   int     `yaml:"sustain"`    // consecutive rising buckets before alerting
+	MinGrowth float64 `yaml:"min_growth"` // per-bucket trend as a fraction of level that counts as rising
+	Alpha     float64 `yaml:"alpha"`
+	Beta      float64 `yaml:"beta"`
+	Gamma     float64 `yaml:"gamma"`
+}
+
+func (c ForecastConfig) WithDefaults() ForecastConfig {
+	if d, err := time.ParseDuration(c.Interval); err != nil || d <= 0 {
+		c.Interval = "15m"
+	}
+	if d, err := time.ParseDuration(c.Bucket); err != nil || d < time.Minute {
+		c.Bucket = "1h"
+	}
+	if _, err := time.ParseDuration(c.History); err != nil {
+		c.History = "336h"
+	}
+	if c.Season <= 0 {
+		c.Season = 24
+	}
+	if c.Horizon <= 0 {
+		c.Horizon = 6
+	}
+	if c.Sustain <= 0 {
+		c.Sustain = 3
+	}
+	if c.MinGrowth <= 0 {
+		c.MinGrowth = 0.1
+	}
+	if c.Alpha <= 0 || c.Alpha >= 1 {
+		c.Alpha = 0.5
+	}
+	if c.Beta <= 0 || c.Beta >= 1 {
+		c.Beta = 0.3
+	}
+	if c.Gamma <= 0 || c.Gamma >= 1 {
+		c.Gamma = 0.2
+	}
+	return c
+}
@@ -0,0 +1,17 @@
+package config
+
+// GRPCConfig controls the gRPC ingestion listener. Setting TLSCert and
+// TLSKey serves TLS; setting only one is a startup error.
+type GRPCConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Addr    string `yaml:"addr"`
+	TLSCert string `yaml:"tls_cert"`
+	TLSKey  string `yaml:"tls_key"`
+}
+
+func (c GRPCConfig) WithDefaults() GRPCConfig {
+	if c.Addr == "" {
+		c.Addr = ":9090"
+	}
+	return c
+}
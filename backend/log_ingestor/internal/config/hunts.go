@@ -0,0 +1,40 @@
+package config
+
+import (
+	_ "embed"
+	"time"
+)
+
+// HuntConfig controls the scheduled hunting pack.
+type HuntConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	PackPath      string `yaml:"pack_path"` // custom pack; empty uses the built-in one
+	Interval      string `yaml:"interval"`  // how often the pack runs, e.g. "168h"
+	Window        string `yaml:"window"`    // period each run hunts over
+	Lookback      string `yaml:"lookback"`  // baseline for rarity checks
+	BusinessStart int    `yaml:"business_start"`
+	BusinessEnd   int    `yaml:"business_end"`
+	RareThreshold int    `yaml:"rare_threshold"`
+}
+
+func (c HuntConfig) WithDefaults() HuntConfig {
+	if d, err := time.ParseDuration(c.Interval); err != nil || d <= 0 {
+		c.Interval = "168h"
+	}
+	if _, err := time.ParseDuration(c.Window); err != nil {
+		c.Window = c.Interval
+	}
+	if _, err := time.ParseDuration(c.Lookback); err != nil {
+		c.Lookback = "720h"
+	}
+	if c.BusinessStart <= 0 || c.BusinessStart > 23 {
+		c.BusinessStart = 8
+	}
+	if c.BusinessEnd <= c.BusinessStart || c.BusinessEnd > 24 {
+		c.BusinessEnd = 18
+	}
+	if c.RareThreshold <= 0 {
+		c.RareThreshold = 3
+	}
+	return c
+}
@@ -0,0 +1,6 @@
+package config
+
+// IncidentConfig controls incidents opened automatically from alerts.
+type IncidentConfig struct {
+	AutoCreate []string `yaml:"auto_create"` // alert kinds that open an incident, e.g. severity_trend
+}
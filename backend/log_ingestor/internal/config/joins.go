@@ -0,0 +1,23 @@
+package config
+
+import _ "embed"
+
+// JoinConfig controls windowed correlation joins, evaluated in memory as
+// logs are stored. Each completed join is stored as a correlation linking
+// the logs that made it up, and ingested as a correlated incident event.
+type JoinConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	RulesPath string `yaml:"rules_path"` // custom rules; empty uses the built-in ones
+	MaxKeys   int    `yaml:"max_keys"`   // open join keys per rule and tenant
+	Source    string `yaml:"source"`     // source of correlated incident events; reserved
+}
+
+func (c JoinConfig) WithDefaults() JoinConfig {
+	if c.MaxKeys <= 0 {
+		c.MaxKeys = 10000
+	}
+	if c.Source == "" {
+		c.Source = "Correlation"
+	}
+	return c
+}
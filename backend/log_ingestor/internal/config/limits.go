@@ -0,0 +1,55 @@
+package config
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimit is a token bucket refilled at Rate events/sec up to Burst.
+// A zero Rate disables the limit.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// BreakerConfig sheds low-severity entries when the write path saturates.
+// Saturation is the higher of the retry queue's fill and in-flight ingests
+// over MaxInFlight, 0-1.
+type BreakerConfig struct {
+	Enabled     bool               `yaml:"enabled"`
+	MaxInFlight int                `yaml:"max_in_flight"`
+	Shed        map[string]float64 `yaml:"shed"`     // severity -> saturation at which it is shed
+	Interval    string             `yaml:"interval"` // how often saturation is sampled
+}
+
+// LimitsConfig protects the ingest paths (HTTP, OTLP, gRPC, and HTTP
+// pipelines) from floods.
+type LimitsConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	PerSource RateLimit     `yaml:"per_source"` // per tenant and source
+	PerIP     RateLimit     `yaml:"per_ip"`     // per client address
+	PerKey    RateLimit     `yaml:"per_key"`    // per API key
+	IdleAfter string        `yaml:"idle_after"` // forget buckets unused this long
+	Breaker   BreakerConfig `yaml:"breaker"`
+}
+
+func (c LimitsConfig) WithDefaults() LimitsConfig {
+	for _, l := range []*RateLimit{&c.PerSource, &c.PerIP, &c.PerKey} {
+		if l.Rate > 0 && l.Burst <= 0 {
+			l.Burst = int(math.Ceil(l.Rate))
+		}
+	}
+	if d, err := time.ParseDuration(c.IdleAfter); err != nil || d <= 0 {
+		c.IdleAfter = "10m"
+	}
+	if c.Breaker.MaxInFlight <= 0 {
+		c.Breaker.MaxInFlight = 1000
+	}
+	if len(c.Breaker.Shed) == 0 {
+		c.Breaker.Shed = map[string]float64{"INFO": 0.8, "WARNING": 0.95}
+	}
+	if d, err := time.ParseDuration(c.Breaker.Interval); err != nil || d <= 0 {
+		c.Breaker.Interval = "1s"
+	}
+	return c
+}
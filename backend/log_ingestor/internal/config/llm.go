@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// LLMConfig is the shared llm section, also read by the incident agent. The
+// LLM_PROVIDER, LLM_API_KEY, and LLM_MODEL environment variables override it.
+type LLMConfig struct {
+	Provider string `yaml:"provider"` // groq, openai, or mock (no API calls)
+	APIKey   string `yaml:"api_key"`
+	Model    string `yaml:"model"`
+	URL      string `yaml:"url"`      // OpenAI-compatible base URL; defaults per provider
+	Timeout  string `yaml:"timeout"`  // per completion
+	MaxLogs  int    `yaml:"max_logs"` // logs summarized per request
+}
+
+// llmProviderURLs are the OpenAI-compatible endpoints of known providers.
+var llmProviderURLs = map[string]string{
+	"groq":   "https://api.groq.com/openai/v1",
+	"openai": "https://api.openai.com/v1",
+}
+
+func (c LLMConfig) WithDefaults() LLMConfig {
+	for env, field := range map[string]*string{"LLM_PROVIDER": &c.Provider, "LLM_API_KEY": &c.APIKey, "LLM_MODEL": &c.Model} {
+		if v := os.Getenv(env); v != "" {
+			*field = v
+		}
+	}
+	if c.URL == "" {
+		c.URL = llmProviderURLs[c.Provider]
+	}
+	// Without credentials there is nothing to call; summarize locally.
+	if c.Provider == "" || (c.APIKey == "" && llmProviderURLs[c.Provider] != "") {
+		c.Provider = "mock"
+	}
+	if d, err := time.ParseDuration(c.Timeout); err != nil || d <= 0 {
+		c.Timeout = "60s"
+	}
+	if c.MaxLogs <= 0 {
+		c.MaxLogs = 500
+	}
+	return c
+}
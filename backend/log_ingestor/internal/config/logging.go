@@ -0,0 +1,20 @@
+package config
+
+import "strings"
+
+// LoggingConfig controls the ingestor's own log output.
+type LoggingConfig struct {
+	Level  string `yaml:"level"`  // debug, info (default), warn, or error; reloadable
+	Format string `yaml:"format"` // text (default) or json
+}
+
+func (c LoggingConfig) WithDefaults() LoggingConfig {
+	c.Level = strings.ToLower(c.Level)
+	if c.Level == "" {
+		c.Level = "info"
+	}
+	if c.Format != "json" {
+		c.Format = "text"
+	}
+	return c
+}
@@ -0,0 +1,35 @@
+package config
+
+import "time"
+
+// NetFlowConfig controls the UDP flow collector. NetFlow v5, v9, and IPFIX
+// exporters share one port; each flow record is stored as a log entry so
+// traffic can be searched and joined with firewall logs.
+type NetFlowConfig struct {
+	Enabled     bool     `yaml:"enabled"`
+	Addr        string   `yaml:"addr"`         // UDP listen address
+	Source      string   `yaml:"source"`       // source of flow entries
+	Tenant      string   `yaml:"tenant"`       // tenant flows are stored under
+	Exporters   []string `yaml:"exporters"`    // IPs or CIDRs allowed to send; empty allows any
+	TemplateTTL string   `yaml:"template_ttl"` // v9/IPFIX templates not refreshed within this are dropped
+	Workers     int      `yaml:"workers"`      // packets decoded and stored concurrently
+}
+
+func (c NetFlowConfig) WithDefaults() NetFlowConfig {
+	if c.Addr == "" {
+		c.Addr = ":2055"
+	}
+	if c.Source == "" {
+		c.Source = "NetFlow"
+	}
+	if c.Tenant == "" {
+		c.Tenant = DefaultTenant
+	}
+	if d, err := time.ParseDuration(c.TemplateTTL); err != nil || d <= 0 {
+		c.TemplateTTL = "30m"
+	}
+	if c.Workers <= 0 {
+		c.Workers = 4
+	}
+	return c
+}
@@ -0,0 +1,25 @@
+package config
+
+// ParserDefinition is a custom line parser: a grok pattern such as
+// "%{IP:client} %{WORD:method}", or a regular expression with named groups.
+// Captures named timestamp, message, severity, ip_address, or source set the
+// entry's columns; the rest become fields.
+type ParserDefinition struct {
+	Name    string   `yaml:"name" json:"name"`
+	Sources []string `yaml:"sources" json:"sources,omitempty"` // entry sources it applies to; empty means any
+	Pattern string   `yaml:"pattern" json:"pattern"`
+	// Types coerces captures: int, float, bool, ip, timestamp, or
+	// timestamp:<Go layout>, timestamp:unix, or timestamp:unix_ms. A value
+	// that doesn't convert fails the parse.
+	Types map[string]string `yaml:"types" json:"types,omitempty"`
+}
+
+// ParsersConfig onboards appliance formats the built-in CEF/LEEF parser
+// doesn't know. The first parser whose sources and pattern match an entry's
+// message applies.
+type ParsersConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Patterns adds named grok patterns, usable as %{NAME} in parsers.
+	Patterns map[string]string  `yaml:"patterns"`
+	Parsers  []ParserDefinition `yaml:"parsers"`
+}
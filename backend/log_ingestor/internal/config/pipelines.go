@@ -0,0 +1,80 @@
+package config
+
+import "time"
+
+// PipelineEnrichStages are the stages a pipeline may choose among; the
+// parse, redact, defaults, and validate stages aren't optional.
+var PipelineEnrichStages = []string{"threat", "reputation", "risk"}
+
+// PipelineInput is where a pipeline's entries come from.
+type PipelineInput struct {
+	Type     string `yaml:"type"`     // http, syslog, netflow, or file
+	Addr     string `yaml:"addr"`     // syslog and netflow listen address
+	Protocol string `yaml:"protocol"` // syslog: udp or tcp
+	Path     string `yaml:"path"`     // file: the file to tail
+}
+
+// PipelineConfig is a named ingestion path with its own input, parsing,
+// enrichment, batching, and destination. Each pipeline queues and stores
+// independently, so a flood or a failing table in one only drops that
+// pipeline's entries.
+type PipelineConfig struct {
+	Name   string        `yaml:"name"`
+	Input  PipelineInput `yaml:"input"`
+	Source string        `yaml:"source"` // for entries that don't set one; defaults to the name
+	Tenant string        `yaml:"tenant"` // syslog, netflow, and file entries; HTTP entries use the credential's tenant
+	// Parser is "cef" for the CEF/LEEF parser only, "none", the name of a
+	// custom parser, or empty for the same parse and custom stages as
+	// /api/ingest.
+	Parser string `yaml:"parser"`
+	// Enrich lists the enrichment stages to run, of threat, reputation, and
+	// risk. Omitted runs all of them; [] runs none.
+	Enrich        []string `yaml:"enrich"`
+	BatchSize     int      `yaml:"batch_size"`
+	FlushInterval string   `yaml:"flush_interval"`
+	QueueSize     int      `yaml:"queue_size"`
+	Workers       int      `yaml:"workers"`
+	// Table is the destination, laid out like logs. Entries stored elsewhere
+	// aren't embedded, broadcast, or seen by detections.
+	Table     string `yaml:"table"`
+	Partition string `yaml:"partition"` // TiDB only
+}
+
+func (c PipelineConfig) WithDefaults() PipelineConfig {
+	if c.Input.Protocol == "" {
+		c.Input.Protocol = "udp"
+	}
+	if c.Input.Addr == "" {
+		switch c.Input.Type {
+		case "syslog":
+			c.Input.Addr = ":5514"
+		case "netflow":
+			c.Input.Addr = ":2055"
+		}
+	}
+	if c.Source == "" {
+		c.Source = c.Name
+	}
+	if c.Tenant == "" {
+		c.Tenant = DefaultTenant
+	}
+	if c.Enrich == nil {
+		c.Enrich = PipelineEnrichStages
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if d, err := time.ParseDuration(c.FlushInterval); err != nil || d <= 0 {
+		c.FlushInterval = "1s"
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 10000
+	}
+	if c.Workers <= 0 {
+		c.Workers = 1
+	}
+	if c.Table == "" {
+		c.Table = "logs"
+	}
+	return c
+}
@@ -0,0 +1,49 @@
+package config
+
+import "time"
+
+// QualityConfig controls per-source data quality scoring. Each source is
+// measured over tumbling windows at ingest time and alerts when it degrades.
+type QualityConfig struct {
+	Enabled       bool    `yaml:"enabled"`
+	Window        string  `yaml:"window"`         // scoring window, e.g. "15m"
+	History       int     `yaml:"history"`        // windows kept for the dashboard
+	MinEvents     int     `yaml:"min_events"`     // sources with fewer events per window are not judged
+	SkewThreshold string  `yaml:"skew_threshold"` // timestamps further than this from arrival count as skewed
+	MinScore      float64 `yaml:"min_score"`      // 0-100; below this a source is degraded
+	MaxMissing    float64 `yaml:"max_missing"`    // per-metric rate limits, 0-1
+	MaxInvalidIP  float64 `yaml:"max_invalid_ip"`
+	MaxSkewed     float64 `yaml:"max_skewed"`
+	MaxParseFail  float64 `yaml:"max_parse_failures"`
+}
+
+func (c QualityConfig) WithDefaults() QualityConfig {
+	if d, err := time.ParseDuration(c.Window); err != nil || d < time.Minute {
+		c.Window = "15m"
+	}
+	if c.History <= 0 {
+		c.History = 96
+	}
+	if c.MinEvents <= 0 {
+		c.MinEvents = 50
+	}
+	if d, err := time.ParseDuration(c.SkewThreshold); err != nil || d <= 0 {
+		c.SkewThreshold = "5m"
+	}
+	if c.MinScore <= 0 {
+		c.MinScore = 80
+	}
+	if c.MaxMissing <= 0 {
+		c.MaxMissing = 0.2
+	}
+	if c.MaxInvalidIP <= 0 {
+		c.MaxInvalidIP = 0.05
+	}
+	if c.MaxSkewed <= 0 {
+		c.MaxSkewed = 0.1
+	}
+	if c.MaxParseFail <= 0 {
+		c.MaxParseFail = 0.05
+	}
+	return c
+}
@@ -0,0 +1,33 @@
+package config
+
+import "os"
+
+// RBACConfig enforces roles on the query and admin APIs. When enabled every
+// request to them needs an API key or JWT; ingest endpoints are unaffected.
+type RBACConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// JWTConfig accepts bearer JWTs from an identity provider alongside API
+// keys. Tokens are verified with Secret (HS256) or PublicKey (RS256).
+type JWTConfig struct {
+	Secret      string `yaml:"secret"`     // or JWT_SECRET
+	PublicKey   string `yaml:"public_key"` // PEM file with an RSA public key or certificate
+	Issuer      string `yaml:"issuer"`     // required "iss" when set
+	Audience    string `yaml:"audience"`   // required in "aud" when set
+	TenantClaim string `yaml:"tenant_claim"`
+	RoleClaim   string `yaml:"role_claim"` // a role name or a list of them; the highest wins
+}
+
+func (c JWTConfig) WithDefaults() JWTConfig {
+	if c.Secret == "" {
+		c.Secret = os.Getenv("JWT_SECRET")
+	}
+	if c.TenantClaim == "" {
+		c.TenantClaim = "tenant"
+	}
+	if c.RoleClaim == "" {
+		c.RoleClaim = "role"
+	}
+	return c
+}
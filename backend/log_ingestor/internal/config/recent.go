@@ -0,0 +1,14 @@
+package config
+
+// RecentConfig sizes the in-memory ring of recently stored events served by
+// GET /api/logs/recent without touching the database.
+type RecentConfig struct {
+	Size int `yaml:"size"` // events kept per tenant
+}
+
+func (c RecentConfig) WithDefaults() RecentConfig {
+	if c.Size <= 0 {
+		c.Size = 1000
+	}
+	return c
+}
@@ -0,0 +1,34 @@
+package config
+
+import "time"
+
+// RedactionRule masks matches of Pattern with Replacement.
+type RedactionRule struct {
+	Name        string `yaml:"name"`
+	Pattern     string `yaml:"pattern"`
+	Replacement string `yaml:"replacement"` // default "[REDACTED:<name>]"
+}
+
+// RedactionConfig masks PII in messages, structured fields, and labels
+// before entries are stored, embedded, or broadcast.
+type RedactionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Builtins names built-in rules: email, credit_card, us_ssn, uk_nino,
+	// iban, and phone.
+	Builtins []string        `yaml:"builtins"`
+	Rules    []RedactionRule `yaml:"rules"`
+	// MaskFields are field and label keys whose whole value is masked, e.g.
+	// password.
+	MaskFields    []string `yaml:"mask_fields"`
+	AuditInterval string   `yaml:"audit_interval"` // how often counts are written to the audit log
+}
+
+func (c RedactionConfig) WithDefaults() RedactionConfig {
+	if c.Builtins == nil {
+		c.Builtins = []string{"email", "credit_card", "us_ssn"}
+	}
+	if d, err := time.ParseDuration(c.AuditInterval); err != nil || d <= 0 {
+		c.AuditInterval = "1m"
+	}
+	return c
+}
@@ -0,0 +1,21 @@
+package config
+
+import "time"
+
+// ReloadConfig controls runtime reloading of rules and config. A reload is
+// triggered by SIGHUP, POST /api/admin/reload, or, with Watch, by a change
+// to the config file or any rule file it references. Reloads cover join
+// rules, the hunt pack, simulator scenarios, redaction, risk weights, ingest
+// limits, and TLS certificates; turning joins, hunts, or limits on or off,
+// and every other section, still needs a restart.
+type ReloadConfig struct {
+	Watch    bool   `yaml:"watch"`
+	Interval string `yaml:"interval"` // how often watched files are checked
+}
+
+func (c ReloadConfig) WithDefaults() ReloadConfig {
+	if d, err := time.ParseDuration(c.Interval); err != nil || d <= 0 {
+		c.Interval = "5s"
+	}
+	return c
+}
@@ -0,0 +1,47 @@
+package config
+
+import "time"
+
+// ReportsConfig runs scheduled saved searches and delivers their results.
+// Saved searches can be created and run on demand while it's disabled.
+type ReportsConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Interval   string `yaml:"interval"`    // how often due searches are checked for
+	TimeZone   string `yaml:"timezone"`    // zone cron schedules are read in, e.g. "Europe/Berlin"
+	MaxResults int    `yaml:"max_results"` // logs kept per run and sent per report
+	KeepRuns   int    `yaml:"keep_runs"`   // runs kept per saved search; older ones are pruned
+	Timeout    string `yaml:"timeout"`     // per run, including delivery
+	SMTP       struct {
+		Host     string `yaml:"host"` // empty disables email delivery
+		Port     int    `yaml:"port"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+		From     string `yaml:"from"`
+	} `yaml:"smtp"`
+	// AllowPrivateWebhooks lets webhooks reach loopback, private, and
+	// link-local addresses. Off by default, since any analyst can set a
+	// webhook and could otherwise reach internal services.
+	AllowPrivateWebhooks bool `yaml:"allow_private_webhooks"`
+}
+
+func (c ReportsConfig) WithDefaults() ReportsConfig {
+	if d, err := time.ParseDuration(c.Interval); err != nil || d <= 0 {
+		c.Interval = "30s"
+	}
+	if _, err := time.LoadLocation(c.TimeZone); err != nil || c.TimeZone == "" {
+		c.TimeZone = "UTC"
+	}
+	if c.MaxResults <= 0 {
+		c.MaxResults = 500
+	}
+	if c.KeepRuns <= 0 {
+		c.KeepRuns = 50
+	}
+	if d, err := time.ParseDuration(c.Timeout); err != nil || d <= 0 {
+		c.Timeout = "2m"
+	}
+	if c.SMTP.Port == 0 {
+		c.SMTP.Port = 587
+	}
+	return c
+}
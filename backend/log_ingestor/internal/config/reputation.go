@@ -0,0 +1,51 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// ReputationConfig scores every IP by the severity and frequency of its
+// events. Each event adds its severity's points, which decay with a
+// half-life, and the 0-100 score is
+//
+//	score = 100 * (1 - exp(-points / scale))
+//
+// so a burst of alerts pushes an IP toward 100 and a quiet one drifts back.
+type ReputationConfig struct {
+	Enabled       bool               `yaml:"enabled"`
+	HalfLife      string             `yaml:"half_life"`      // how quickly points decay
+	Weights       map[string]float64 `yaml:"weights"`        // points per event, by severity
+	Scale         float64            `yaml:"scale"`          // points at which the score reaches 63
+	OffenderScore int                `yaml:"offender_score"` // crossing it counts an offense
+	FlushInterval string             `yaml:"flush_interval"` // how often points are merged into ip_reputation
+	MaxTracked    int                `yaml:"max_tracked"`    // IPs kept in memory per replica
+	History       int                `yaml:"history"`        // events listed by GET /api/ips/{ip}
+}
+
+func (c ReputationConfig) WithDefaults() ReputationConfig {
+	if d, err := time.ParseDuration(c.HalfLife); err != nil || d <= 0 {
+		c.HalfLife = "24h"
+	}
+	weights := map[string]float64{"INFO": 0.2, "WARNING": 1, "ALERT": 4, "CRITICAL": 8}
+	for sev, w := range c.Weights {
+		weights[strings.ToUpper(sev)] = w
+	}
+	c.Weights = weights
+	if c.Scale <= 0 {
+		c.Scale = 20
+	}
+	if c.OffenderScore <= 0 || c.OffenderScore > 100 {
+		c.OffenderScore = 70
+	}
+	if d, err := time.ParseDuration(c.FlushInterval); err != nil || d <= 0 {
+		c.FlushInterval = "10s"
+	}
+	if c.MaxTracked <= 0 {
+		c.MaxTracked = 100000
+	}
+	if c.History <= 0 {
+		c.History = 50
+	}
+	return c
+}
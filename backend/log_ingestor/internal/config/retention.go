@@ -0,0 +1,29 @@
+package config
+
+import "time"
+
+// RetentionConfig controls the background purge of old and soft-deleted logs.
+type RetentionConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	MaxAge       string `yaml:"max_age"`       // purge logs older than this, e.g. "720h"
+	DeletedGrace string `yaml:"deleted_grace"` // how long soft-deleted logs stay restorable
+	Interval     string `yaml:"interval"`
+	BatchSize    int    `yaml:"batch_size"`
+	// Archive, when enabled, uploads logs older than MaxAge to object
+	// storage before they're purged.
+	Archive ArchiveConfig `yaml:"archive"`
+}
+
+func (c RetentionConfig) WithDefaults() RetentionConfig {
+	if _, err := time.ParseDuration(c.DeletedGrace); err != nil {
+		c.DeletedGrace = "168h"
+	}
+	if d, err := time.ParseDuration(c.Interval); err != nil || d <= 0 {
+		c.Interval = "1h"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 10000
+	}
+	c.Archive = c.Archive.WithDefaults()
+	return c
+}
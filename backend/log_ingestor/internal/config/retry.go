@@ -0,0 +1,32 @@
+package config
+
+import "time"
+
+// RetryConfig controls retries of failed inserts and the dead-letter file
+// entries are spilled to once retries are exhausted.
+type RetryConfig struct {
+	MaxAttempts    int    `yaml:"max_attempts"` // retries after the first failed insert
+	InitialBackoff string `yaml:"initial_backoff"`
+	MaxBackoff     string `yaml:"max_backoff"`
+	QueueSize      int    `yaml:"queue_size"`
+	DeadLetterPath string `yaml:"dead_letter_path"`
+}
+
+func (c RetryConfig) WithDefaults() RetryConfig {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+	if _, err := time.ParseDuration(c.InitialBackoff); err != nil {
+		c.InitialBackoff = "500ms"
+	}
+	if _, err := time.ParseDuration(c.MaxBackoff); err != nil {
+		c.MaxBackoff = "30s"
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 10000
+	}
+	if c.DeadLetterPath == "" {
+		c.DeadLetterPath = "dead_letter.jsonl"
+	}
+	return c
+}
@@ -0,0 +1,43 @@
+package config
+
+import "time"
+
+// RiskConfig tunes the 0-100 risk score computed for every event. The score
+// starts from the severity's base and each signal raises it toward 100:
+//
+//	risk = 100 * (1 - (1-base) * (1-w_intel*intel) * (1-w_anomaly*anomaly) * (1-w_rules*rules))
+//
+// where every signal is 0-1, so no single signal can exceed its weight and
+// signals compound without overflowing the scale.
+type RiskConfig struct {
+	Base          map[string]float64 `yaml:"base"`           // per severity, 0-100
+	ThreatIntel   float64            `yaml:"threat_intel"`   // weight of a threat intelligence match
+	Anomaly       float64            `yaml:"anomaly"`        // weight of a source seeing a new IP
+	RuleHits      float64            `yaml:"rule_hits"`      // weight of matching detection rules
+	NoveltyWindow string             `yaml:"novelty_window"` // how long a source/IP pair stays familiar
+	MaxTracked    int                `yaml:"max_tracked"`    // source/IP pairs remembered per replica
+}
+
+func (c RiskConfig) WithDefaults() RiskConfig {
+	base := map[string]float64{"INFO": 10, "WARNING": 35, "ALERT": 65, "CRITICAL": 85}
+	for sev, v := range c.Base {
+		base[sev] = v
+	}
+	c.Base = base
+	if c.ThreatIntel <= 0 {
+		c.ThreatIntel = 0.6
+	}
+	if c.Anomaly <= 0 {
+		c.Anomaly = 0.3
+	}
+	if c.RuleHits <= 0 {
+		c.RuleHits = 0.4
+	}
+	if d, err := time.ParseDuration(c.NoveltyWindow); err != nil || d <= 0 {
+		c.NoveltyWindow = "24h"
+	}
+	if c.MaxTracked <= 0 {
+		c.MaxTracked = 100000
+	}
+	return c
+}
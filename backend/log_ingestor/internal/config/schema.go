@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// SchemaConfig controls drift detection between db/schema.sql and the live
+// database, so hand-applied hotfixes don't silently diverge environments.
+type SchemaConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Path     string `yaml:"path"`     // expected schema, relative to the working directory; default: the database driver's script
+	Interval string `yaml:"interval"` // re-check period after the startup check
+}
+
+// WithDefaults fills in unset fields; schema is the database driver's
+// script.
+func (c SchemaConfig) WithDefaults(schema string) SchemaConfig {
+	if c.Path == "" {
+		c.Path = schema
+	}
+	if d, err := time.ParseDuration(c.Interval); err != nil || d <= 0 {
+		c.Interval = "1h"
+	}
+	return c
+}
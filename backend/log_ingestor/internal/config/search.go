@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// SearchConfig tunes the hybrid ranking of POST /api/search.
+type SearchConfig struct {
+	Candidates int           `yaml:"candidates"` // rows fetched per ranking signal before re-ranking
+	HalfLife   string        `yaml:"half_life"`  // age at which the recency score halves
+	Weights    SearchWeights `yaml:"weights"`
+}
+
+// SearchWeights weigh the ranking signals. They are normalized to sum to 1.
+type SearchWeights struct {
+	Vector  float64 `yaml:"vector" json:"vector"`
+	Keyword float64 `yaml:"keyword" json:"keyword"`
+	Recency float64 `yaml:"recency" json:"recency"`
+}
+
+func (c SearchConfig) WithDefaults() SearchConfig {
+	if c.Candidates <= 0 {
+		c.Candidates = 200
+	}
+	if d, err := time.ParseDuration(c.HalfLife); err != nil || d <= 0 {
+		c.HalfLife = "24h"
+	}
+	if c.Weights == (SearchWeights{}) {
+		c.Weights = SearchWeights{Vector: 0.6, Keyword: 0.3, Recency: 0.1}
+	}
+	return c
+}
+
+func (w SearchWeights) Normalize() (SearchWeights, error) {
+	if w.Vector < 0 || w.Keyword < 0 || w.Recency < 0 {
+		return w, fmt.Errorf("weights must not be negative")
+	}
+	sum := w.Vector + w.Keyword + w.Recency
+	if sum == 0 {
+		return w, fmt.Errorf("at least one weight must be positive")
+	}
+	return SearchWeights{Vector: w.Vector / sum, Keyword: w.Keyword / sum, Recency: w.Recency / sum}, nil
+}
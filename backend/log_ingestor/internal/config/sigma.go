@@ -0,0 +1,17 @@
+package config
+
+// SigmaConfig evaluates Sigma rules against stored logs, recording matches
+// in the detections table and streaming them as "detection" events.
+type SigmaConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Rules   []string `yaml:"rules"` // rule files or directories (searched recursively); empty uses the built-in ones
+	// FieldMap renames Sigma fields to entry fields, e.g. SourceIp:
+	// ip_address or TargetUserName: field.usrName. Unmapped names match a
+	// field, then a label, then message/source/severity/ip_address.
+	FieldMap map[string]string `yaml:"field_map"`
+	// LogSources maps a rule's logsource to entry sources, keyed
+	// product:<name>, service:<name>, or category:<name>. An unmapped
+	// service or category matches the source of the same name; an unmapped
+	// product matches everything.
+	LogSources map[string][]string `yaml:"logsources"`
+}
@@ -0,0 +1,20 @@
+package config
+
+import _ "embed"
+
+// SimulatorConfig controls the built-in log simulator.
+type SimulatorConfig struct {
+	Enabled   bool        `yaml:"enabled"`
+	Scenarios string      `yaml:"scenarios"` // scenario file; empty uses the built-in set
+	Rate      float64     `yaml:"rate"`      // target events/sec; 0 keeps the scenarios' own rates
+	Duration  string      `yaml:"duration"`  // stop after this long; empty runs forever
+	Burst     BurstConfig `yaml:"burst"`
+}
+
+// BurstConfig periodically multiplies the event rate, e.g. 10x for 5s every
+// minute, to exercise ingest under spiky load.
+type BurstConfig struct {
+	Every      string  `yaml:"every"`
+	Length     string  `yaml:"length"`
+	Multiplier float64 `yaml:"multiplier"`
+}
@@ -0,0 +1,54 @@
+package config
+
+import "time"
+
+// StreamConfig limits what a single WebSocket connection and API key may ask
+// of the live stream, so one integration can't turn the hub into a per-event
+// rules engine.
+type StreamConfig struct {
+	MaxSubscriptions    int    `yaml:"max_subscriptions"`     // per connection
+	MaxKeySubscriptions int    `yaml:"max_key_subscriptions"` // per API key, across connections
+	MaxFilterTerms      int    `yaml:"max_filter_terms"`      // per subscription
+	MaxSearchLength     int    `yaml:"max_search_length"`
+	QoSInterval         string `yaml:"qos_interval"`     // QoS report window
+	QoSSlowest          int    `yaml:"qos_slowest"`      // clients listed in the report
+	MaxBacklog          int    `yaml:"max_backlog"`      // stored logs sent on connect (?backlog=N) or on request
+	MaxBacklogScan      int    `yaml:"max_backlog_scan"` // rows examined to fill a filtered backlog
+	MaxClients          int    `yaml:"max_clients"`      // concurrent connections per replica; 0 = unlimited
+	SendBuffer          int    `yaml:"send_buffer"`      // messages queued per connection before dropping
+	DrainTimeout        string `yaml:"drain_timeout"`    // how long shutdown waits for buffers to flush
+}
+
+func (c StreamConfig) WithDefaults() StreamConfig {
+	if c.MaxSubscriptions <= 0 {
+		c.MaxSubscriptions = 10
+	}
+	if c.MaxKeySubscriptions <= 0 {
+		c.MaxKeySubscriptions = 50
+	}
+	if c.MaxFilterTerms <= 0 {
+		c.MaxFilterTerms = 16
+	}
+	if c.MaxSearchLength <= 0 {
+		c.MaxSearchLength = 256
+	}
+	if d, err := time.ParseDuration(c.QoSInterval); err != nil || d <= 0 {
+		c.QoSInterval = "1m"
+	}
+	if c.QoSSlowest <= 0 {
+		c.QoSSlowest = 10
+	}
+	if c.MaxBacklog <= 0 {
+		c.MaxBacklog = 1000
+	}
+	if c.MaxBacklogScan <= 0 {
+		c.MaxBacklogScan = 20000
+	}
+	if c.SendBuffer <= 0 {
+		c.SendBuffer = 256
+	}
+	if d, err := time.ParseDuration(c.DrainTimeout); err != nil || d <= 0 {
+		c.DrainTimeout = "10s"
+	}
+	return c
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultTenant owns generated logs and all requests when multi-tenancy is
+// disabled, so single-team deployments work without any tenant setup.
+const DefaultTenant = "default"
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// TenancyConfig enables per-tenant isolation. When enabled, every API and
+// WebSocket request must present an API key, and is scoped to that key's tenant.
+type TenancyConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Tenants []Tenant `yaml:"tenants"`
+}
+
+type Tenant struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+}
+
+// validate checks that configured tenant IDs are well-formed and unique.
+func (c TenancyConfig) Validate() error {
+	seen := map[string]bool{}
+	for _, t := range c.Tenants {
+		if !tenantIDPattern.MatchString(t.ID) {
+			return fmt.Errorf("invalid tenant id %q", t.ID)
+		}
+		if seen[t.ID] {
+			return fmt.Errorf("duplicate tenant id %q", t.ID)
+		}
+		seen[t.ID] = true
+	}
+	return nil
+}
+
+// known reports whether id may own data. The default tenant always exists.
+func (c TenancyConfig) Known(id string) bool {
+	if id == DefaultTenant {
+		return true
+	}
+	for _, t := range c.Tenants {
+		if t.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"1logx/events"
+)
+
+// ThreatIntelConfig pulls indicator-of-compromise feeds into the
+// threat_indicators table. Entries from a listed IP are tagged with
+// threat_match fields, escalated, and alerted on.
+type ThreatIntelConfig struct {
+	Enabled       bool         `yaml:"enabled"`
+	Interval      string       `yaml:"interval"`       // how often feeds are pulled
+	EscalateTo    string       `yaml:"escalate_to"`    // minimum severity of matching entries
+	AlertCooldown string       `yaml:"alert_cooldown"` // per tenant and IP
+	Feeds         []ThreatFeed `yaml:"feeds"`
+}
+
+// ThreatFeed is one indicator source.
+type ThreatFeed struct {
+	Name string `yaml:"name"`
+	// Type is abuseipdb (the blacklist API), csv (indicator[,confidence[,
+	// description]] per line), or stix (a STIX 2.1 bundle).
+	Type          string `yaml:"type"`
+	URL           string `yaml:"url"`            // csv and stix: fetched over HTTP
+	Path          string `yaml:"path"`           // csv and stix: read from disk instead
+	APIKey        string `yaml:"api_key"`        // abuseipdb; or ABUSEIPDB_API_KEY
+	MinConfidence int    `yaml:"min_confidence"` // 0-100; lower-confidence indicators are dropped
+}
+
+func (c ThreatIntelConfig) WithDefaults() ThreatIntelConfig {
+	if d, err := time.ParseDuration(c.Interval); err != nil || d <= 0 {
+		c.Interval = "1h"
+	}
+	if _, err := events.ParseSeverity(c.EscalateTo); err != nil {
+		c.EscalateTo = "ALERT"
+	}
+	if d, err := time.ParseDuration(c.AlertCooldown); err != nil || d <= 0 {
+		c.AlertCooldown = "1h"
+	}
+	for i := range c.Feeds {
+		f := &c.Feeds[i]
+		if f.Name == "" {
+			f.Name = f.Type
+		}
+		if f.Type == "abuseipdb" {
+			if f.APIKey == "" {
+				f.APIKey = os.Getenv("ABUSEIPDB_API_KEY")
+			}
+			if f.URL == "" {
+				f.URL = "https://api.abuseipdb.com/api/v2/blacklist"
+			}
+			if f.MinConfidence <= 0 {
+				f.MinConfidence = 90
+			}
+		}
+	}
+	return c
+}
+
+func (c ThreatIntelConfig) Validate() error {
+	seen := map[string]bool{}
+	for _, f := range c.Feeds {
+		if seen[f.Name] {
+			return fmt.Errorf("threat_intel: duplicate feed %q", f.Name)
+		}
+		seen[f.Name] = true
+		switch f.Type {
+		case "abuseipdb":
+			if f.APIKey == "" {
+				return fmt.Errorf("threat_intel: feed %s needs an api_key", f.Name)
+			}
+		case "csv", "stix":
+			if (f.URL == "") == (f.Path == "") {
+				return fmt.Errorf("threat_intel: feed %s needs exactly one of url and path", f.Name)
+			}
+		default:
+			return fmt.Errorf("threat_intel: feed %s has unknown type %q", f.Name, f.Type)
+		}
+	}
+	return nil
+}
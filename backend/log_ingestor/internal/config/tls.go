@@ -0,0 +1,38 @@
+package config
+
+import "errors"
+
+// TLSConfig serves the HTTP/WebSocket API over TLS. With ClientCA set,
+// clients must also present a certificate signed by it (mTLS). Certificates
+// are re-read on every reload, so renewals don't need a restart; Addr and
+// MinVersion only take effect at startup.
+type TLSConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	Addr         string `yaml:"addr"`
+	Cert         string `yaml:"cert"`
+	Key          string `yaml:"key"`
+	ClientCA     string `yaml:"client_ca"`     // PEM bundle trusted for client certificates
+	ClientAuth   string `yaml:"client_auth"`   // require (default with client_ca) or optional
+	RedirectHTTP bool   `yaml:"redirect_http"` // plain :8080 redirects to HTTPS rather than refusing; probes are served either way
+	MinVersion   string `yaml:"min_version"`   // 1.2 or 1.3
+}
+
+func (c TLSConfig) WithDefaults() TLSConfig {
+	if c.Addr == "" {
+		c.Addr = ":8443"
+	}
+	if c.ClientAuth != "optional" {
+		c.ClientAuth = "require"
+	}
+	if c.MinVersion != "1.3" {
+		c.MinVersion = "1.2"
+	}
+	return c
+}
+
+func (c TLSConfig) Validate() error {
+	if c.Cert == "" || c.Key == "" {
+		return errors.New("tls: 'cert' and 'key' are required")
+	}
+	return nil
+}
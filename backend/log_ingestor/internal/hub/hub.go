@@ -0,0 +1,164 @@
+// Package hub tracks the live-stream clients connected to this replica and
+// broadcasts logs and protocol messages to them.
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"1logx/events"
+)
+
+// ErrDraining is returned by Admit once the hub is draining.
+var ErrDraining = errors.New("server is shutting down")
+
+// ErrFull is returned by Admit when the replica has its maximum clients.
+var ErrFull = errors.New("too many live-stream clients")
+
+// Client is a live-stream connection. Delivery must not block: a slow
+// client only backs up its own buffer.
+type Client interface {
+	comparable
+	// Tenant is the only tenant whose broadcasts the client receives.
+	Tenant() string
+	// Log delivers a live log entry, already encoded as data.
+	Log(entry events.LogEntry, data []byte)
+	// Message delivers an encoded protocol message.
+	Message(data []byte)
+}
+
+// Relay carries broadcasts beyond this replica, e.g. to the other replicas
+// or to message brokers.
+type Relay interface {
+	Publish(eventType, tenantID string, data []byte)
+}
+
+// Hub is the set of clients connected to this replica.
+type Hub[C Client] struct {
+	maxClients int64
+
+	mu      sync.Mutex
+	clients map[C]struct{}
+	relays  []Relay
+
+	// connections counts connections, including ones still being upgraded.
+	connections atomic.Int64
+	// draining is set on shutdown: new connections are refused.
+	draining atomic.Bool
+}
+
+// New returns a hub admitting up to maxClients connections; 0 is unlimited.
+func New[C Client](maxClients int) *Hub[C] {
+	return &Hub[C]{maxClients: int64(maxClients), clients: map[C]struct{}{}}
+}
+
+// AddRelay has broadcasts published to r as well as delivered locally.
+func (h *Hub[C]) AddRelay(r Relay) {
+	h.mu.Lock()
+	h.relays = append(h.relays, r)
+	h.mu.Unlock()
+}
+
+// Admit reserves a connection slot; the caller gives it back with Release.
+func (h *Hub[C]) Admit() error {
+	if h.draining.Load() {
+		return ErrDraining
+	}
+	if n := h.connections.Add(1); h.maxClients > 0 && n > h.maxClients {
+		h.connections.Add(-1)
+		return ErrFull
+	}
+	return nil
+}
+
+// Release gives back a slot reserved by Admit.
+func (h *Hub[C]) Release() { h.connections.Add(-1) }
+
+// Connections is the number of admitted connections.
+func (h *Hub[C]) Connections() int64 { return h.connections.Load() }
+
+// Draining reports whether Drain has been called.
+func (h *Hub[C]) Draining() bool { return h.draining.Load() }
+
+// Add registers c for broadcasts.
+func (h *Hub[C]) Add(c C) {
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+}
+
+// Remove stops broadcasts to c.
+func (h *Hub[C]) Remove(c C) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+}
+
+// Clients returns the registered clients, in no particular order.
+func (h *Hub[C]) Clients() []C {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	list := make([]C, 0, len(h.clients))
+	for c := range h.clients {
+		list = append(list, c)
+	}
+	return list
+}
+
+// Drain refuses new connections and returns the clients to close.
+func (h *Hub[C]) Drain() []C {
+	h.draining.Store(true)
+	return h.Clients()
+}
+
+// BroadcastLog sends entry to this tenant's clients on every replica.
+func (h *Hub[C]) BroadcastLog(entry events.LogEntry) {
+	data, _ := json.Marshal(entry)
+	h.publish("logs", entry.TenantID, data)
+	h.DeliverLog(entry, data)
+}
+
+// DeliverLog sends entry, already encoded as data, to this replica's
+// clients.
+func (h *Hub[C]) DeliverLog(entry events.LogEntry, data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		if client.Tenant() == entry.TenantID {
+			client.Log(entry, data)
+		}
+	}
+}
+
+// BroadcastMessage sends a protocol message to every client of tenantID,
+// including clients whose live log feed is paused.
+func (h *Hub[C]) BroadcastMessage(tenantID string, msg events.Envelope) {
+	data, _ := json.Marshal(msg)
+	h.publish(msg.Type, tenantID, data)
+	h.DeliverMessage(tenantID, data)
+}
+
+// DeliverMessage sends an encoded protocol message to this replica's
+// clients of tenantID.
+func (h *Hub[C]) DeliverMessage(tenantID string, data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		if client.Tenant() == tenantID {
+			client.Message(data)
+		}
+	}
+}
+
+func (h *Hub[C]) publish(eventType, tenantID string, data []byte) {
+	h.mu.Lock()
+	relays := h.relays
+	h.mu.Unlock()
+	for _, r := range relays {
+		r.Publish(eventType, tenantID, data)
+	}
+}
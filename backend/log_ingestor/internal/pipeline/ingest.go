@@ -0,0 +1,258 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"1logx/events"
+	"1logx/log_ingestor/internal/store"
+)
+
+// Embedder turns text into a fixed-size vector.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// Observer is fed every stored entry.
+type Observer interface {
+	Observe(entry events.LogEntry)
+}
+
+// Canary parses a sample of messages with a candidate parser as well.
+type Canary interface {
+	Sample() bool
+	CompareParse(tenant, message string)
+}
+
+// Quality scores processed entries, accepted or not.
+type Quality interface {
+	Observe(entry events.LogEntry, sentTime bool, received time.Time, failed []string, err error)
+}
+
+// Joins correlate stored entries. CheckSource rejects client entries
+// claiming the source correlated events are stored under.
+type Joins interface {
+	Observer
+	CheckSource(entry events.LogEntry) error
+}
+
+// Retrier stores entries whose first insert failed once the database
+// recovers.
+type Retrier interface {
+	Enqueue(entry events.LogEntry, origin Origin, err error)
+}
+
+// Limiter applies rate limits and the circuit breaker to client entries.
+type Limiter interface {
+	Allow(origin Origin, entry events.LogEntry) error
+	Shed(entry events.LogEntry) bool
+}
+
+// Deps are what an Ingestor feeds. Nil parts are disabled.
+type Deps struct {
+	Stages   []Stage
+	Embedder Embedder
+	// EmbedModel identifies Embedder in logs.embedding_model.
+	EmbedModel string
+	Canary     Canary
+	Quality    Quality
+	Joins      Joins
+	// Detectors are fed stored entries after the joins.
+	Detectors []Observer
+	// Live streams stored entries; nil when the change feed streams inserts
+	// instead.
+	Live func(events.LogEntry)
+}
+
+// Ingestor is the single path every log source goes through: normalization,
+// persistence, and broadcast to WebSocket clients.
+type Ingestor struct {
+	db      *store.DB
+	deps    Deps
+	stats   *Stats
+	sources *Sources
+	load    *Load
+	retry   Retrier // nil: failed inserts are returned to the caller
+	limits  Limiter // nil when rate limits and the breaker are disabled
+}
+
+func New(db *store.DB, deps Deps) *Ingestor {
+	return &Ingestor{db: db, deps: deps, stats: NewStats(), sources: NewSources(), load: &Load{}}
+}
+
+// SetRetry queues failed inserts on r. The retry queue stores entries
+// through the ingestor, so it's set once both exist, before ingesting.
+func (in *Ingestor) SetRetry(r Retrier) { in.retry = r }
+
+// SetLimits has client entries rate limited and shed by l, before
+// ingesting.
+func (in *Ingestor) SetLimits(l Limiter) { in.limits = l }
+
+func (in *Ingestor) DB() *store.DB { return in.db }
+
+// Sources are the inputs entries arrive through.
+func (in *Ingestor) Sources() *Sources { return in.sources }
+
+func (in *Ingestor) Load() *Load { return in.load }
+
+func (in *Ingestor) Stages() []Stage { return in.deps.Stages }
+
+// StageStatus is the throughput of the ingestor's stages.
+func (in *Ingestor) StageStatus() []StageStatus { return in.stats.Status(in.deps.Stages) }
+
+// Ingest processes, stores, and broadcasts an entry produced by this
+// process, which isn't rate limited or shed. If the insert fails and a retry
+// queue is configured, the entry is queued and ErrQueuedForRetry is
+// returned.
+func (in *Ingestor) Ingest(entry events.LogEntry) (events.LogEntry, error) {
+	return in.IngestFrom(Origin{Internal: true}, entry)
+}
+
+// IngestDerived is Ingest for an event synthesized from stored logs, such as
+// a correlated incident event, which isn't fed back into the joins. If it's
+// queued for retry, stored is called with it once the retry queue stores it.
+func (in *Ingestor) IngestDerived(entry events.LogEntry, stored func(events.LogEntry)) (events.LogEntry, error) {
+	return in.IngestFrom(Origin{Internal: true, Derived: true, OnStored: stored}, entry)
+}
+
+// IngestFrom is Ingest for entries from origin. Unless origin is internal
+// they are subject to rate limits: it returns ErrRateLimited when origin or
+// entry's source is over its limit, and ErrShed when the circuit breaker is
+// dropping entry's severity, and entries claiming the correlation source are
+// invalid. It returns ErrSourcePaused when origin's input is paused.
+func (in *Ingestor) IngestFrom(origin Origin, entry events.LogEntry) (events.LogEntry, error) {
+	source := in.sources.Get(origin.Input)
+	if source.Paused() {
+		return entry, ErrSourcePaused
+	}
+	received := time.Now()
+	stored, err := in.ingestFrom(origin, entry)
+	source.Observe(stored, received, err)
+	return stored, err
+}
+
+// Allow applies the rate limits to an entry from origin, returning
+// ErrRateLimited when origin or entry's source is over its limit. Internal
+// origins are always allowed.
+func (in *Ingestor) Allow(origin Origin, entry events.LogEntry) error {
+	if origin.Internal || in.limits == nil {
+		return nil
+	}
+	return in.limits.Allow(origin, entry)
+}
+
+func (in *Ingestor) ingestFrom(origin Origin, entry events.LogEntry) (events.LogEntry, error) {
+	if err := in.Allow(origin, entry); err != nil {
+		return entry, err
+	}
+	in.load.inFlight.Add(1)
+	defer in.load.inFlight.Add(-1)
+	if in.deps.Canary != nil && in.deps.Canary.Sample() {
+		go in.deps.Canary.CompareParse(entry.TenantID, entry.Message)
+	}
+	received, sentTime := time.Now(), !entry.Timestamp.IsZero()
+	failed, err := in.Process(&entry, nil)
+	if err == nil && !origin.Internal {
+		err = in.checkSource(entry)
+	}
+	in.observeQuality(entry, sentTime, received, failed, err)
+	if err != nil {
+		return entry, fmt.Errorf("%w: %v", ErrInvalidEntry, err)
+	}
+	if !origin.Internal && in.Shed(entry) {
+		return entry, ErrShed
+	}
+	stored, err := in.Store(entry, origin.Derived)
+	if err != nil && in.retry != nil {
+		in.retry.Enqueue(entry, origin, err)
+		return entry, fmt.Errorf("%w: %v", ErrQueuedForRetry, err)
+	}
+	return stored, err
+}
+
+// Process runs entry through the ingestor's stages, as RunStages does.
+func (in *Ingestor) Process(entry *events.LogEntry, trace *[]Trace) ([]string, error) {
+	return RunStages(in.deps.Stages, in.stats, entry, trace)
+}
+
+// Checked finishes an entry processed by other stages, from an input that
+// can't claim the correlation source: it's rejected if it does, and its
+// data quality is scored either way. err is the stages' error.
+func (in *Ingestor) Checked(entry events.LogEntry, sentTime bool, received time.Time, failed []string, err error) error {
+	if err == nil {
+		err = in.checkSource(entry)
+	}
+	in.observeQuality(entry, sentTime, received, failed, err)
+	return err
+}
+
+func (in *Ingestor) checkSource(entry events.LogEntry) error {
+	if in.deps.Joins == nil {
+		return nil
+	}
+	return in.deps.Joins.CheckSource(entry)
+}
+
+func (in *Ingestor) observeQuality(entry events.LogEntry, sentTime bool, received time.Time, failed []string, err error) {
+	if in.deps.Quality != nil {
+		in.deps.Quality.Observe(entry, sentTime, received, failed, err)
+	}
+}
+
+// Shed reports whether the circuit breaker drops entry.
+func (in *Ingestor) Shed(entry events.LogEntry) bool {
+	return in.limits != nil && in.limits.Shed(entry)
+}
+
+// Store inserts an already processed entry and broadcasts it. derived is
+// passed on to Publish.
+func (in *Ingestor) Store(entry events.LogEntry, derived bool) (events.LogEntry, error) {
+	embedding := in.Embed(entry)
+	start := time.Now()
+	err := store.InsertLog(in.db, &entry, embedding)
+	in.load.ObserveInsert(time.Since(start))
+	if err != nil {
+		return entry, fmt.Errorf("insert: %w", err)
+	}
+	in.Publish(entry, derived)
+	return entry, nil
+}
+
+// Embed returns entry's embedding columns. A failed embedding shouldn't
+// lose the log; it's stored without one and only drops out of vector search
+// until a re-embedding job fills it in. Stores without vectors keep none.
+func (in *Ingestor) Embed(entry events.LogEntry) store.Embedding {
+	if !in.db.Vectors() || in.deps.Embedder == nil {
+		return store.Embedding{}
+	}
+	vec, err := in.deps.Embedder.Embed(context.Background(), EmbeddingText(entry))
+	if err != nil {
+		slog.Warn("Failed to embed log, storing without embedding", "err", err)
+		return store.Embedding{}
+	}
+	return store.Embedding{Vector: store.FormatVector(vec), Model: in.deps.EmbedModel}
+}
+
+// Publish broadcasts a stored entry and feeds it to the detectors. A
+// derived entry skips the joins, which would otherwise correlate their own
+// incident events.
+func (in *Ingestor) Publish(entry events.LogEntry, derived bool) {
+	slog.Debug("Ingested log", "id", entry.ID, "severity", entry.Severity, "source", entry.Source, "tenant", entry.TenantID)
+
+	if in.deps.Live != nil {
+		in.deps.Live(entry)
+	}
+	if !derived && in.deps.Joins != nil {
+		in.deps.Joins.Observe(entry)
+	}
+	for _, d := range in.deps.Detectors {
+		d.Observe(entry)
+	}
+}
+
+// EmbeddingText is the text embedded for a log entry.
+func EmbeddingText(e events.LogEntry) string {
+	return e.Source + ": " + e.Message
+}
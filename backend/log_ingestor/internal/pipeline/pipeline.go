@@ -0,0 +1,158 @@
+// Package pipeline is the path every log source goes through: processing
+// stages, persistence, and publishing stored entries to the live stream and
+// the detectors.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"regexp"
+	"strings"
+	"time"
+
+	"1logx/events"
+	"1logx/log_ingestor/internal/config"
+)
+
+// Limits on user-supplied structured attributes.
+const (
+	MaxAttributes     = 64
+	MaxAttributeValue = 4096
+)
+
+// AttributeKeyPattern restricts field/label keys so they are safe to use in
+// JSON paths and query parameters.
+var AttributeKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,64}$`)
+
+var (
+	// ErrInvalidEntry wraps validation failures so callers can tell bad
+	// input apart from storage errors.
+	ErrInvalidEntry = errors.New("invalid log entry")
+	// ErrQueuedForRetry reports that an entry passed validation but could
+	// not be stored yet; it is held in the retry queue rather than lost.
+	ErrQueuedForRetry = errors.New("insert failed; queued for retry")
+	// ErrRateLimited is returned when an entry exceeds a rate limit; the
+	// client should back off and resend it.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrShed is returned when the circuit breaker drops an entry to
+	// protect the write path. Shed entries are not stored.
+	ErrShed = errors.New("shed under load")
+	// ErrSourcePaused is returned for entries from a source an admin
+	// paused; the client should resend them once it's resumed.
+	ErrSourcePaused = errors.New("source paused")
+)
+
+// Origin identifies who sent an entry, for rate limiting.
+type Origin struct {
+	IP  string
+	Key string // API key prefix; empty when unauthenticated
+	// Input names the Sources entry the entry arrived through; empty for
+	// internal callers.
+	Input string
+	// Internal marks entries produced by this process (the simulator,
+	// benchmarks, correlated incident events), which skip rate limits and
+	// the breaker.
+	Internal bool
+	// Derived marks events synthesized from stored logs, such as correlated
+	// incident events, which aren't fed back into the joins that produced
+	// them. No client ingest path sets it.
+	Derived bool
+	// OnStored, if set, is called with the entry once the retry queue stores
+	// it after its first insert failed.
+	OnStored func(events.LogEntry)
+}
+
+// Stage is one step of entry processing. Failures in optional stages are
+// logged and the entry continues; failures in required stages reject it.
+type Stage struct {
+	Name     string
+	Run      func(*events.LogEntry) error
+	Optional bool
+}
+
+// Trace records an entry's state after a stage, for dry runs.
+type Trace struct {
+	Stage  string          `json:"stage"`
+	Output events.LogEntry `json:"output"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// RunStages processes entry through stages, counted in stats unless traced,
+// and returns the names of optional stages that failed. If trace is non-nil,
+// a copy of the entry after each stage is appended to it.
+func RunStages(stages []Stage, stats *Stats, entry *events.LogEntry, trace *[]Trace) ([]string, error) {
+	var failed []string
+	for _, stage := range stages {
+		start := time.Now()
+		err := stage.Run(entry)
+		if trace != nil {
+			t := Trace{Stage: stage.Name, Output: CloneEntry(*entry)}
+			if err != nil {
+				t.Error = err.Error()
+			}
+			*trace = append(*trace, t)
+		} else {
+			stats.observe(stage.Name, time.Since(start), err)
+		}
+		if err == nil {
+			continue
+		}
+		if !stage.Optional {
+			return failed, err
+		}
+		failed = append(failed, stage.Name)
+		if trace == nil {
+			slog.Warn("Pipeline stage failed", "stage", stage.Name, "err", err)
+		}
+	}
+	return failed, nil
+}
+
+// ApplyDefaults is the defaults stage.
+func ApplyDefaults(entry *events.LogEntry) error {
+	if entry.TenantID == "" {
+		entry.TenantID = config.DefaultTenant
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if entry.Source == "" {
+		entry.Source = "Unknown"
+	}
+	return nil
+}
+
+// ValidateEntry is the validate stage.
+func ValidateEntry(entry *events.LogEntry) error {
+	if strings.TrimSpace(entry.Message) == "" {
+		return fmt.Errorf("message is required")
+	}
+	if err := validateAttributes("fields", entry.Fields); err != nil {
+		return err
+	}
+	return validateAttributes("labels", entry.Labels)
+}
+
+func validateAttributes(kind string, attrs map[string]string) error {
+	if len(attrs) > MaxAttributes {
+		return fmt.Errorf("too many %s (%d > %d)", kind, len(attrs), MaxAttributes)
+	}
+	for k, v := range attrs {
+		if !AttributeKeyPattern.MatchString(k) {
+			return fmt.Errorf("invalid %s key %q", kind, k)
+		}
+		if len(v) > MaxAttributeValue {
+			return fmt.Errorf("%s value for %q exceeds %d bytes", kind, k, MaxAttributeValue)
+		}
+	}
+	return nil
+}
+
+// CloneEntry copies entry so later stages can't mutate a recorded trace.
+func CloneEntry(entry events.LogEntry) events.LogEntry {
+	entry.Fields = maps.Clone(entry.Fields)
+	entry.Labels = maps.Clone(entry.Labels)
+	return entry
+}
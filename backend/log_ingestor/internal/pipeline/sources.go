@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"1logx/events"
+)
+
+// Source is one input's traffic since startup. Admins can pause it, which
+// rejects or holds its entries until it's resumed.
+type Source struct {
+	name     string
+	paused   atomic.Bool
+	received atomic.Int64
+	errors   atomic.Int64
+	backlog  func() int // entries waiting to be processed; nil when unbuffered
+
+	mu          sync.Mutex
+	lastEvent   time.Time
+	lag         time.Duration // between the last entry's timestamp and its arrival
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func (s *Source) Name() string { return s.name }
+
+// SetBacklog reports the input's queued entries with backlog.
+func (s *Source) SetBacklog(backlog func() int) { s.backlog = backlog }
+
+// Observe records an entry that arrived at received. Entries rejected for
+// being paused aren't counted; queued-for-retry ones aren't errors.
+func (s *Source) Observe(entry events.LogEntry, received time.Time, err error) {
+	if s == nil || errors.Is(err, ErrSourcePaused) {
+		return
+	}
+	s.received.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil && !errors.Is(err, ErrQueuedForRetry) {
+		s.errors.Add(1)
+		s.lastError, s.lastErrorAt = err.Error(), received
+		return
+	}
+	s.lastEvent = received
+	s.lag = max(received.Sub(entry.Timestamp), 0)
+}
+
+// Fail records n received entries that then failed to store.
+func (s *Source) Fail(n int, err error) {
+	if s == nil {
+		return
+	}
+	s.errors.Add(int64(n))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastError, s.lastErrorAt = err.Error(), time.Now()
+}
+
+// Paused reports whether s is paused; a nil source never is.
+func (s *Source) Paused() bool {
+	return s != nil && s.paused.Load()
+}
+
+func (s *Source) SetPaused(paused bool) { s.paused.Store(paused) }
+
+// SourceStatus is a Source for GET /api/admin/sources.
+type SourceStatus struct {
+	Name        string     `json:"name"`
+	Paused      bool       `json:"paused"`
+	Received    int64      `json:"received"`
+	Errors      int64      `json:"errors"`
+	LastEvent   *time.Time `json:"last_event,omitempty"`
+	LagSeconds  float64    `json:"lag_seconds"`
+	Backlog     *int       `json:"backlog,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+func (s *Source) Status() SourceStatus {
+	st := SourceStatus{Name: s.name, Paused: s.paused.Load(), Received: s.received.Load(), Errors: s.errors.Load()}
+	if s.backlog != nil {
+		n := s.backlog()
+		st.Backlog = &n
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.lastEvent.IsZero() {
+		st.LastEvent, st.LagSeconds = &s.lastEvent, s.lag.Seconds()
+	}
+	if s.lastError != "" {
+		st.LastError, st.LastErrorAt = s.lastError, &s.lastErrorAt
+	}
+	return st
+}
+
+// Sources are the inputs the server runs, by name: http, windows, otlp,
+// grpc, netflow, simulator, and pipeline:<name>.
+type Sources struct {
+	mu      sync.RWMutex
+	sources map[string]*Source
+}
+
+func NewSources() *Sources {
+	return &Sources{sources: map[string]*Source{}}
+}
+
+// Add registers a source, or returns the one already named name.
+func (ss *Sources) Add(name string) *Source {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if s := ss.sources[name]; s != nil {
+		return s
+	}
+	s := &Source{name: name}
+	ss.sources[name] = s
+	return s
+}
+
+// Get returns the source named name, or nil for entries from no registered
+// input.
+func (ss *Sources) Get(name string) *Source {
+	if name == "" {
+		return nil
+	}
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.sources[name]
+}
+
+// Status lists the sources by name.
+func (ss *Sources) Status() []SourceStatus {
+	ss.mu.RLock()
+	statuses := make([]SourceStatus, 0, len(ss.sources))
+	for _, s := range ss.sources {
+		statuses = append(statuses, s.Status())
+	}
+	ss.mu.RUnlock()
+	slices.SortFunc(statuses, func(a, b SourceStatus) int { return strings.Compare(a.Name, b.Name) })
+	return statuses
+}
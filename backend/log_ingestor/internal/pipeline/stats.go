@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stageCounter is one stage's throughput since startup.
+type stageCounter struct {
+	processed, failed, nanos atomic.Int64
+}
+
+// Stats counts each stage of a pipeline by name. Dry runs aren't counted.
+type Stats struct {
+	mu       sync.Mutex
+	counters map[string]*stageCounter
+	since    time.Time
+}
+
+func NewStats() *Stats {
+	return &Stats{counters: map[string]*stageCounter{}, since: time.Now()}
+}
+
+func (st *Stats) observe(stage string, took time.Duration, err error) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	c := st.counters[stage]
+	if c == nil {
+		c = &stageCounter{}
+		st.counters[stage] = c
+	}
+	st.mu.Unlock()
+	c.processed.Add(1)
+	c.nanos.Add(int64(took))
+	if err != nil {
+		c.failed.Add(1)
+	}
+}
+
+// StageStatus is a stage's throughput for GET /api/admin/pipelines.
+type StageStatus struct {
+	Stage     string  `json:"stage"`
+	Processed int64   `json:"processed"`
+	Failed    int64   `json:"failed"`
+	PerSecond float64 `json:"per_second"` // averaged since startup
+	AvgMillis float64 `json:"avg_ms"`
+}
+
+// Status lists stages in the order they run.
+func (st *Stats) Status(stages []Stage) []StageStatus {
+	elapsed := time.Since(st.since).Seconds()
+	out := make([]StageStatus, 0, len(stages))
+	for _, stage := range stages {
+		st.mu.Lock()
+		c := st.counters[stage.Name]
+		st.mu.Unlock()
+		s := StageStatus{Stage: stage.Name}
+		if c != nil {
+			s.Processed, s.Failed = c.processed.Load(), c.failed.Load()
+			if s.Processed > 0 {
+				s.AvgMillis = float64(c.nanos.Load()) / float64(s.Processed) / 1e6
+			}
+			if elapsed > 0 {
+				s.PerSecond = float64(s.Processed) / elapsed
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// Load is the ingest path's load, sampled by the autoscaler and the
+// circuit breaker.
+type Load struct {
+	inFlight atomic.Int64
+
+	mu          sync.Mutex
+	insertTotal time.Duration
+	inserts     int64
+}
+
+// InFlight is the number of entries being processed.
+func (l *Load) InFlight() int64 { return l.inFlight.Load() }
+
+// ObserveInsert records an insert, of one entry or a batch, that took d.
+func (l *Load) ObserveInsert(d time.Duration) {
+	l.mu.Lock()
+	l.insertTotal += d
+	l.inserts++
+	l.mu.Unlock()
+}
+
+// InsertLatency returns the mean insert time in seconds since it was last
+// called, or 0 without inserts.
+func (l *Load) InsertLatency() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var latency float64
+	if l.inserts > 0 {
+		latency = (l.insertTotal / time.Duration(l.inserts)).Seconds()
+	}
+	l.insertTotal, l.inserts = 0, 0
+	return latency
+}
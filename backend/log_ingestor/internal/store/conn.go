@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// storeConnector opens connections whose queries are rewritten for the
+// backend and whose arguments are stored the way the backend expects: times
+// in UTC, so times written as text (SQLite) compare in order, and values
+// passed through convert when set.
+type storeConnector struct {
+	dsn     string
+	driver  driver.Driver
+	rewrite func(query string) string
+	convert func(driver.Value) driver.Value
+}
+
+func (c storeConnector) Driver() driver.Driver { return c.driver }
+
+func (c storeConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	sc := storeConn{Conn: conn, rewrite: c.rewrite, convert: c.convert}
+	var ok bool
+	if sc.queryer, ok = conn.(driver.QueryerContext); !ok {
+		conn.Close()
+		return nil, fmt.Errorf("%T doesn't support queries with a context", conn)
+	}
+	if sc.execer, ok = conn.(driver.ExecerContext); !ok {
+		conn.Close()
+		return nil, fmt.Errorf("%T doesn't support statements with a context", conn)
+	}
+	if sc.preparer, ok = conn.(driver.ConnPrepareContext); !ok {
+		conn.Close()
+		return nil, fmt.Errorf("%T doesn't support prepares with a context", conn)
+	}
+	if sc.beginner, ok = conn.(driver.ConnBeginTx); !ok {
+		conn.Close()
+		return nil, fmt.Errorf("%T doesn't support transactions with a context", conn)
+	}
+	return sc, nil
+}
+
+type storeConn struct {
+	driver.Conn
+	rewrite  func(string) string
+	convert  func(driver.Value) driver.Value
+	queryer  driver.QueryerContext
+	execer   driver.ExecerContext
+	preparer driver.ConnPrepareContext
+	beginner driver.ConnBeginTx
+}
+
+func (c storeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.queryer.QueryContext(ctx, c.rewrite(query), args)
+}
+
+func (c storeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.execer.ExecContext(ctx, c.rewrite(query), args)
+}
+
+func (c storeConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.preparer.PrepareContext(ctx, c.rewrite(query))
+}
+
+func (c storeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.beginner.BeginTx(ctx, opts)
+}
+
+func (c storeConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c storeConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c storeConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c storeConn) CheckNamedValue(nv *driver.NamedValue) error {
+	v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	if t, ok := v.(time.Time); ok {
+		v = t.UTC()
+	}
+	if c.convert != nil {
+		v = c.convert(v)
+	}
+	nv.Value = v
+	return nil
+}
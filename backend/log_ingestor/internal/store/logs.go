@@ -0,0 +1,78 @@
+package store
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"1logx/events"
+)
+
+// Embedding is a log's embedding columns: a vector literal and the
+// embedding model that computed it. The zero value stores none.
+type Embedding struct {
+	Vector, Model any
+}
+
+// InsertLog writes entry to the logs table and sets its ID.
+func InsertLog(db Conn, entry *events.LogEntry, embedding Embedding) error {
+	return InsertLogInto(db, "INSERT INTO logs", entry, embedding)
+}
+
+// InsertLogInto is InsertLog with the INSERT INTO clause naming the
+// destination, for tables laid out like logs.
+func InsertLogInto(db Conn, into string, entry *events.LogEntry, embedding Embedding) error {
+	fields, err := EncodeFields(entry.Fields)
+	if err != nil {
+		return err
+	}
+	labels, err := EncodeFields(entry.Labels)
+	if err != nil {
+		return err
+	}
+	entry.ID, err = db.Insert(into+`
+		(tenant_id, timestamp, source, severity, message, ip_address, fields, labels, risk_score, embedding, embedding_model)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.TenantID, entry.Timestamp, entry.Source, entry.Severity, entry.Message, entry.IPAddress, fields, labels, entry.RiskScore, embedding.Vector, embedding.Model,
+	)
+	return err
+}
+
+// EncodeFields returns the JSON column value for a fields or labels map,
+// or NULL if empty.
+func EncodeFields(fields map[string]string) (any, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// DecodeFields parses a fields or labels JSON column read from the logs table.
+func DecodeFields(data []byte) (map[string]string, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var fields map[string]string
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// FormatVector renders vec in TiDB's vector literal syntax, e.g. "[0.1,0.2]".
+func FormatVector(vec []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, v := range vec {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(v), 'f', 6, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
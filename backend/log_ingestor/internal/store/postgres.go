@@ -0,0 +1,139 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/config"
+
+	"github.com/lib/pq"
+)
+
+// postgresStore is PostgreSQL with the pgvector extension for embeddings.
+type postgresStore struct{}
+
+func (postgresStore) Driver() string { return "postgres" }
+func (postgresStore) Schema() string { return "../db/schema.postgres.sql" }
+func (postgresStore) Vectors() bool  { return true }
+
+func (postgresStore) Open(cfg config.Config) (*sql.DB, error) {
+	if cfg.Database.DSN == "" {
+		return nil, errors.New("database.dsn is required for the postgres driver")
+	}
+	db := sql.OpenDB(storeConnector{dsn: cfg.Database.DSN, driver: &pq.Driver{}, rewrite: rebind, convert: postgresValue})
+	db.SetConnMaxLifetime(time.Minute * 3)
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(10)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping to Postgres failed: %w", err)
+	}
+	return db, nil
+}
+
+func (postgresStore) InsertID(db Runner, query string, args ...any) (int64, error) {
+	var id int64
+	err := db.QueryRow(query+" RETURNING id", args...).Scan(&id)
+	return id, err
+}
+
+func (postgresStore) InsertIgnore(insert string) string { return insert + " ON CONFLICT DO NOTHING" }
+
+func (postgresStore) Upsert(key ...string) string {
+	return "ON CONFLICT (" + strings.Join(key, ", ") + ") DO UPDATE SET"
+}
+
+func (postgresStore) Excluded(column string) string { return "excluded." + column }
+func (postgresStore) ForUpdate() string             { return " FOR UPDATE" }
+
+func (postgresStore) DeleteLimit(table, where string) string {
+	return "DELETE FROM " + table + " WHERE id IN (SELECT id FROM " + table + " WHERE " + where + " LIMIT ?)"
+}
+
+func (postgresStore) Greatest(exprs ...string) string {
+	return "GREATEST(" + strings.Join(exprs, ", ") + ")"
+}
+
+func (postgresStore) Least(exprs ...string) string { return "LEAST(" + strings.Join(exprs, ", ") + ")" }
+
+func (postgresStore) SecondsBetween(from, to string) string {
+	return "EXTRACT(EPOCH FROM (" + to + " - " + from + "))"
+}
+
+func (postgresStore) TimeBucket(column string) string {
+	return "FLOOR(EXTRACT(EPOCH FROM " + column + ") / ?)"
+}
+
+func (postgresStore) JSONText(column string) string {
+	return "(jsonb_path_query_first(" + column + ", CAST(? AS jsonpath)) #>> '{}')"
+}
+
+func (postgresStore) VectorText(column string) string { return "CAST(" + column + " AS TEXT)" }
+
+func (postgresStore) VectorDistance(column string) string {
+	return "(" + column + " <=> CAST(? AS vector))"
+}
+
+func (postgresStore) IsDuplicateKey(err error) bool {
+	var pe *pq.Error
+	return errors.As(err, &pe) && pe.Code == "23505"
+}
+
+// rebind numbers the ? placeholders of query as $1, $2, ... for Postgres,
+// leaving quoted strings, identifiers, and comments alone.
+func rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch c {
+		case '\'', '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case '-':
+			if strings.HasPrefix(query[i:], "--") {
+				end := strings.IndexByte(query[i:], '\n')
+				if end < 0 {
+					b.WriteString(query[i:])
+					return b.String()
+				}
+				b.WriteString(query[i : i+end])
+				i += end - 1
+				continue
+			}
+			b.WriteByte(c)
+		case '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// postgresValue passes JSON held as []byte as text, since lib/pq sends
+// []byte as bytea, which doesn't convert to jsonb. No table has a binary
+// column.
+func postgresValue(v driver.Value) driver.Value {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"1logx/log_ingestor/internal/config"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteStore is a local SQLite file, for development and single-node
+// deployments. It has no vector support: embeddings aren't stored, search
+// ranks by keywords only, and clustering is unavailable.
+type sqliteStore struct{}
+
+func (sqliteStore) Driver() string { return "sqlite" }
+func (sqliteStore) Schema() string { return "../db/schema.sqlite.sql" }
+func (sqliteStore) Vectors() bool  { return false }
+
+func (sqliteStore) Open(cfg config.Config) (*sql.DB, error) {
+	if cfg.Database.DSN == "" {
+		return nil, errors.New("database.dsn is required for the sqlite driver")
+	}
+	// Times are written in a format SQLite's date functions understand;
+	// WAL and a busy timeout let readers and the single writer wait on each
+	// other instead of failing with SQLITE_BUSY.
+	sep := "?"
+	if strings.Contains(cfg.Database.DSN, "?") {
+		sep = "&"
+	}
+	dsn := cfg.Database.DSN + sep + "_time_format=sqlite&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+
+	db := sql.OpenDB(storeConnector{dsn: dsn, driver: &sqlite.Driver{}, rewrite: func(q string) string { return q }})
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	return db, nil
+}
+
+func (sqliteStore) InsertID(db Runner, query string, args ...any) (int64, error) {
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (sqliteStore) InsertIgnore(insert string) string {
+	return strings.Replace(insert, "INSERT INTO", "INSERT OR IGNORE INTO", 1)
+}
+
+func (sqliteStore) Upsert(key ...string) string {
+	return "ON CONFLICT (" + strings.Join(key, ", ") + ") DO UPDATE SET"
+}
+
+func (sqliteStore) Excluded(column string) string { return "excluded." + column }
+
+// ForUpdate is empty: SQLite has a single writer, so a write transaction
+// already excludes other writers.
+func (sqliteStore) ForUpdate() string { return "" }
+
+func (sqliteStore) DeleteLimit(table, where string) string {
+	return "DELETE FROM " + table + " WHERE id IN (SELECT id FROM " + table + " WHERE " + where + " LIMIT ?)"
+}
+
+// Greatest and Least use SQLite's multi-argument max and min, which behave
+// like GREATEST and LEAST.
+func (sqliteStore) Greatest(exprs ...string) string { return "max(" + strings.Join(exprs, ", ") + ")" }
+func (sqliteStore) Least(exprs ...string) string    { return "min(" + strings.Join(exprs, ", ") + ")" }
+
+func (sqliteStore) SecondsBetween(from, to string) string {
+	return "((julianday(" + to + ") - julianday(" + from + ")) * 86400)"
+}
+
+func (sqliteStore) TimeBucket(column string) string {
+	return "(CAST(strftime('%s', " + column + ") AS INTEGER) / ?)"
+}
+
+func (sqliteStore) JSONText(column string) string { return "json_extract(" + column + ", ?)" }
+
+// VectorText and VectorDistance are never used: Vectors is false.
+func (sqliteStore) VectorText(column string) string     { return column }
+func (sqliteStore) VectorDistance(column string) string { return "NULL" }
+
+func (sqliteStore) IsDuplicateKey(err error) bool {
+	var se *sqlite.Error
+	if !errors.As(err, &se) {
+		return false
+	}
+	return se.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || se.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
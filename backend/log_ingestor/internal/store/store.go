@@ -0,0 +1,135 @@
+// Package store opens the configured database backend and papers over the
+// SQL the backends don't share.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"1logx/log_ingestor/internal/config"
+)
+
+// Store is the database backend. Features share one DB and write SQL
+// with ? placeholders in the subset the backends have in common; Store
+// covers where they differ.
+type Store interface {
+	// Driver is the database.driver the store was opened with.
+	Driver() string
+	// Open connects to the database and verifies the connection.
+	Open(config config.Config) (*sql.DB, error)
+	// Schema is the default schema script for the backend.
+	Schema() string
+	// Vectors reports whether embeddings are stored and searchable.
+	Vectors() bool
+
+	// InsertID runs an INSERT on db and returns the id of the new row.
+	InsertID(db Runner, query string, args ...any) (int64, error)
+	// InsertIgnore rewrites an "INSERT INTO ... VALUES (...)" statement to
+	// skip rows conflicting on a unique key.
+	InsertIgnore(insert string) string
+	// Upsert is the clause after VALUES that updates the row conflicting on
+	// key instead, followed by "col = expr" assignments.
+	Upsert(key ...string) string
+	// Excluded refers, in an Upsert assignment, to the value that was
+	// being inserted into column.
+	Excluded(column string) string
+	// DeleteLimit deletes up to ? rows of table matching where.
+	DeleteLimit(table, where string) string
+	// ForUpdate is appended to a SELECT in a transaction to lock its rows.
+	ForUpdate() string
+	// Greatest and Least are the largest and smallest of exprs.
+	Greatest(exprs ...string) string
+	Least(exprs ...string) string
+	// SecondsBetween is the number of seconds from one time to another.
+	SecondsBetween(from, to string) string
+	// TimeBucket numbers the ?-second bucket a time column falls in.
+	TimeBucket(column string) string
+	// JSONText is the text at the JSON path ? in column, e.g. $."user".
+	JSONText(column string) string
+	// VectorText renders a vector column as "[0.1,0.2]".
+	VectorText(column string) string
+	// VectorDistance is the cosine distance of a vector column to the
+	// vector literal ?.
+	VectorDistance(column string) string
+	// IsDuplicateKey reports whether err is a unique key violation.
+	IsDuplicateKey(err error) bool
+}
+
+// Runner is a *sql.DB or *sql.Tx.
+type Runner interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Conn is a *DB or *Tx: statements run on it, in its backend's dialect.
+type Conn interface {
+	Store
+	Runner
+	Insert(query string, args ...any) (int64, error)
+}
+
+// New returns the backend cfg selects.
+func New(cfg config.DatabaseConfig) (Store, error) {
+	switch cfg.Driver {
+	case "tidb":
+		return tidbStore{}, nil
+	case "postgres":
+		return postgresStore{}, nil
+	case "sqlite":
+		return sqliteStore{}, nil
+	}
+	return nil, fmt.Errorf("unknown database driver %q (use tidb, postgres, or sqlite)", cfg.Driver)
+}
+
+// DB is a connection pool and the backend it's connected to, so features
+// issue their SQL against the backend they were given.
+type DB struct {
+	*sql.DB
+	Store
+}
+
+// Open connects to the configured database.
+func Open(config config.Config) (*DB, error) {
+	store, err := New(config.Database.WithDefaults())
+	if err != nil {
+		return nil, err
+	}
+	db, err := store.Open(config)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{DB: db, Store: store}, nil
+}
+
+// Driver is the backend's database.driver; (*sql.DB).Driver is db.DB.Driver.
+func (db *DB) Driver() string { return db.Store.Driver() }
+
+// Insert runs an INSERT and returns the id of the new row.
+func (db *DB) Insert(query string, args ...any) (int64, error) {
+	return db.InsertID(db.DB, query, args...)
+}
+
+// Begin starts a transaction.
+func (db *DB) Begin() (*Tx, error) { return db.BeginTx(context.Background(), nil) }
+
+// BeginTx starts a transaction.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, Store: db.Store}, nil
+}
+
+// Tx is a transaction on a DB.
+type Tx struct {
+	*sql.Tx
+	Store
+}
+
+// Insert runs an INSERT in the transaction and returns the id of the new
+// row.
+func (tx *Tx) Insert(query string, args ...any) (int64, error) {
+	return tx.InsertID(tx.Tx, query, args...)
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/config"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// tidbStore is TiDB (or MySQL without vector search).
+type tidbStore struct{}
+
+func (tidbStore) Driver() string { return "tidb" }
+func (tidbStore) Schema() string { return "../db/schema.sql" }
+func (tidbStore) Vectors() bool  { return true }
+
+func (tidbStore) Open(cfg config.Config) (*sql.DB, error) {
+	// Build DSN
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?tls=true&parseTime=true",
+		cfg.TiDB.User,
+		cfg.TiDB.Password,
+		cfg.TiDB.Host,
+		cfg.TiDB.Port,
+		cfg.TiDB.Database,
+	)
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to TiDB: %w", err)
+	}
+	db.SetConnMaxLifetime(time.Minute * 3)
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(10)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping to TiDB failed: %w", err)
+	}
+	return db, nil
+}
+
+func (tidbStore) InsertID(db Runner, query string, args ...any) (int64, error) {
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (tidbStore) InsertIgnore(insert string) string {
+	return strings.Replace(insert, "INSERT INTO", "INSERT IGNORE INTO", 1)
+}
+
+func (tidbStore) Upsert(...string) string       { return "ON DUPLICATE KEY UPDATE" }
+func (tidbStore) Excluded(column string) string { return "VALUES(" + column + ")" }
+func (tidbStore) ForUpdate() string             { return " FOR UPDATE" }
+
+func (tidbStore) DeleteLimit(table, where string) string {
+	return "DELETE FROM " + table + " WHERE " + where + " LIMIT ?"
+}
+
+func (tidbStore) Greatest(exprs ...string) string {
+	return "GREATEST(" + strings.Join(exprs, ", ") + ")"
+}
+func (tidbStore) Least(exprs ...string) string { return "LEAST(" + strings.Join(exprs, ", ") + ")" }
+
+func (tidbStore) SecondsBetween(from, to string) string {
+	return "TIMESTAMPDIFF(SECOND, " + from + ", " + to + ")"
+}
+
+func (tidbStore) TimeBucket(column string) string { return "FLOOR(UNIX_TIMESTAMP(" + column + ") / ?)" }
+func (tidbStore) JSONText(column string) string {
+	return "JSON_UNQUOTE(JSON_EXTRACT(" + column + ", ?))"
+}
+func (tidbStore) VectorText(column string) string { return "VEC_AS_TEXT(" + column + ")" }
+
+func (tidbStore) VectorDistance(column string) string {
+	return "VEC_COSINE_DISTANCE(" + column + ", ?)"
+}
+
+func (tidbStore) IsDuplicateKey(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == 1062
+}
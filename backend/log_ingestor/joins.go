@@ -0,0 +1,441 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"1logx/log_ingestor/internal/pipeline"
+	"1logx/log_ingestor/internal/store"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultJoinRules are the correlation joins shipped with the ingestor.
+//
+//go:embed joins/rules.yaml
+var defaultJoinRules []byte
+
+// JoinRule joins events from several steps that share a key within a window.
+// See joins/rules.yaml for the rule format.
+type JoinRule struct {
+	ID       string     `yaml:"id" json:"id"`
+	Name     string     `yaml:"name" json:"name"`
+	Severity Severity   `yaml:"severity" json:"severity"`
+	Window   string     `yaml:"window" json:"window"`
+	Key      string     `yaml:"key" json:"key"`
+	Ordered  bool       `yaml:"ordered" json:"ordered"`
+	Steps    []JoinStep `yaml:"steps" json:"steps"`
+
+	window time.Duration
+}
+
+// JoinStep is one side of a join.
+type JoinStep struct {
+	Name   string       `yaml:"name" json:"name"`
+	Filter StreamFilter `yaml:"filter" json:"filter"`
+	Key    string       `yaml:"key" json:"key,omitempty"` // overrides the rule's key
+}
+
+// loadJoinRules reads the rules at path, or the built-in rules if path is empty.
+func loadJoinRules(path string) ([]JoinRule, error) {
+	data := defaultJoinRules
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	var file struct {
+		Joins []JoinRule `yaml:"joins"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse join rules: %w", err)
+	}
+	seen := map[string]bool{}
+	for i := range file.Joins {
+		r := &file.Joins[i]
+		if r.ID == "" || seen[r.ID] {
+			return nil, fmt.Errorf("join rules: missing or duplicate id %q", r.ID)
+		}
+		seen[r.ID] = true
+		d, err := time.ParseDuration(r.Window)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("join %s: invalid window %q", r.ID, r.Window)
+		}
+		r.window = d
+		if len(r.Steps) < 2 {
+			return nil, fmt.Errorf("join %s: needs at least two steps", r.ID)
+		}
+		for j := range r.Steps {
+			s := &r.Steps[j]
+			if s.Key == "" {
+				s.Key = r.Key
+			}
+			if err := validJoinKey(s.Key); err != nil {
+				return nil, fmt.Errorf("join %s step %s: %w", r.ID, s.Name, err)
+			}
+		}
+	}
+	return file.Joins, nil
+}
+
+func validJoinKey(key string) error {
+	switch key {
+	case "ip_address", "source":
+		return nil
+	}
+	kind, name, ok := strings.Cut(key, ".")
+	if ok && (kind == "field" || kind == "label") && pipeline.AttributeKeyPattern.MatchString(name) {
+		return nil
+	}
+	return fmt.Errorf("invalid key %q: use ip_address, source, field.<name>, or label.<name>", key)
+}
+
+// joinKeyValue extracts key from e; empty when e doesn't carry it.
+func joinKeyValue(e LogEntry, key string) string {
+	switch key {
+	case "ip_address":
+		return e.IPAddress
+	case "source":
+		return e.Source
+	}
+	kind, name, _ := strings.Cut(key, ".")
+	if kind == "field" {
+		return e.Fields[name]
+	}
+	return e.Labels[name]
+}
+
+// joinOccurrence is one event that matched a step.
+type joinOccurrence struct {
+	LogID     int64     `json:"log_id"`
+	Source    string    `json:"source"`
+	Severity  Severity  `json:"severity"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// maxJoinOccurrences bounds the events kept per step and key; a burst only
+// needs its newest events to complete a join.
+const maxJoinOccurrences = 16
+
+// joinState is the open side of one rule for one key.
+type joinState struct {
+	steps   [][]joinOccurrence // per step, oldest first
+	touched time.Time          // last arrival, for expiry
+}
+
+// JoinEngine evaluates join rules against stored logs. State is keyed by
+// rule, tenant, and join key, so each event costs a map lookup per matching
+// step rather than a query.
+type JoinEngine struct {
+	db     *store.DB
+	alerts *Alerts
+	cfg    JoinConfig
+	rules  []JoinRule
+	// emit ingests a correlated incident event, calling stored if it's
+	// only stored by the retry queue; nil stores only the correlation and
+	// its alert.
+	emit func(event LogEntry, stored func(LogEntry)) (LogEntry, error)
+
+	mu     sync.Mutex
+	state  map[string]map[string]*joinState // rule/tenant -> key -> state
+	fired  map[string]int64                 // per rule
+	capped map[string]int64                 // events dropped at max_keys, per rule
+}
+
+func NewJoinEngine(db *store.DB, alerts *Alerts, cfg JoinConfig) (*JoinEngine, error) {
+	rules, err := loadJoinRules(cfg.RulesPath)
+	if err != nil {
+		return nil, err
+	}
+	return &JoinEngine{
+		db:     db,
+		alerts: alerts,
+		cfg:    cfg,
+		rules:  rules,
+		state:  map[string]map[string]*joinState{},
+		fired:  map[string]int64{},
+		capped: map[string]int64{},
+	}, nil
+}
+
+// Observe feeds a stored entry to every rule and correlates each join it
+// completes. Correlated incident events aren't fed back in, so a rule can't
+// complete on its own output.
+func (j *JoinEngine) Observe(e LogEntry) {
+	if j == nil {
+		return
+	}
+	tenant := e.TenantID
+	if tenant == "" {
+		tenant = defaultTenant
+	}
+	var completed []Correlation
+
+	j.mu.Lock()
+	for i := range j.rules {
+		r := &j.rules[i]
+		for step, s := range r.Steps {
+			if !s.Filter.matches(e) {
+				continue
+			}
+			key := joinKeyValue(e, s.Key)
+			if key == "" {
+				continue
+			}
+			if chain := j.record(r, tenant, key, step, e); chain != nil {
+				completed = append(completed, newCorrelation(r, tenant, key, chain))
+			}
+		}
+	}
+	j.mu.Unlock()
+
+	for _, c := range completed {
+		j.correlate(c)
+	}
+}
+
+// CheckSource rejects a client entry claiming the source reserved for
+// correlated incident events, so it can't pose as one.
+func (j *JoinEngine) CheckSource(e LogEntry) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	reserved := j.cfg.Source
+	j.mu.Unlock()
+	if e.Source == reserved {
+		return fmt.Errorf("source %q is reserved for correlated incident events", e.Source)
+	}
+	return nil
+}
+
+// hits counts the rules with a step matching e, for risk scoring.
+func (j *JoinEngine) hits(e LogEntry) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	n := 0
+	for _, r := range j.rules {
+		for _, s := range r.Steps {
+			if s.Filter.matches(e) && joinKeyValue(e, s.Key) != "" {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// record adds e to the step's occurrences and returns one occurrence per
+// step when the join is complete, clearing the key.
+func (j *JoinEngine) record(r *JoinRule, tenant, key string, step int, e LogEntry) []joinOccurrence {
+	bucket := j.state[r.ID+"/"+tenant]
+	if bucket == nil {
+		bucket = map[string]*joinState{}
+		j.state[r.ID+"/"+tenant] = bucket
+	}
+	st := bucket[key]
+	if st == nil {
+		if len(bucket) >= j.cfg.MaxKeys {
+			j.capped[r.ID]++
+			return nil
+		}
+		st = &joinState{steps: make([][]joinOccurrence, len(r.Steps))}
+		bucket[key] = st
+	}
+	st.touched = time.Now()
+
+	occ := joinOccurrence{LogID: e.ID, Source: e.Source, Severity: e.Severity, Timestamp: e.Timestamp}
+	kept := st.steps[step][:0]
+	for _, o := range st.steps[step] {
+		if absDuration(occ.Timestamp.Sub(o.Timestamp)) <= r.window {
+			kept = append(kept, o)
+		}
+	}
+	st.steps[step] = appendSample(kept, occ, maxJoinOccurrences)
+
+	chain := r.complete(st, step, occ)
+	if chain != nil {
+		delete(bucket, key)
+		j.fired[r.ID]++
+	}
+	return chain
+}
+
+// complete looks for one occurrence per step, including occ for its step,
+// that all fall within the window (and in step order for ordered rules).
+func (r *JoinRule) complete(st *joinState, step int, occ joinOccurrence) []joinOccurrence {
+	chain := make([]joinOccurrence, len(r.Steps))
+	chain[step] = occ
+	if r.Ordered {
+		// Only the last step completes an ordered join; walk back from it,
+		// taking the newest earlier occurrence each time.
+		if step != len(r.Steps)-1 {
+			return nil
+		}
+		for i := step - 1; i >= 0; i-- {
+			found := false
+			for k := len(st.steps[i]) - 1; k >= 0; k-- {
+				o := st.steps[i][k]
+				if !o.Timestamp.After(chain[i+1].Timestamp) && occ.Timestamp.Sub(o.Timestamp) <= r.window {
+					chain[i], found = o, true
+					break
+				}
+			}
+			if !found {
+				return nil
+			}
+		}
+		return chain
+	}
+
+	// Unordered: take each other step's occurrence nearest occ, then check
+	// the whole chain spans no more than the window.
+	first, last := occ.Timestamp, occ.Timestamp
+	for i, occs := range st.steps {
+		if i == step {
+			continue
+		}
+		if len(occs) == 0 {
+			return nil
+		}
+		best := occs[0]
+		for _, o := range occs[1:] {
+			if absDuration(o.Timestamp.Sub(occ.Timestamp)) < absDuration(best.Timestamp.Sub(occ.Timestamp)) {
+				best = o
+			}
+		}
+		chain[i] = best
+		if best.Timestamp.Before(first) {
+			first = best.Timestamp
+		}
+		if best.Timestamp.After(last) {
+			last = best.Timestamp
+		}
+	}
+	if last.Sub(first) > r.window {
+		return nil
+	}
+	return chain
+}
+
+// joinAlert raises a stored correlation as an alert at its escalated
+// severity.
+func joinAlert(c Correlation) Alert {
+	steps := make([]map[string]any, len(c.Links))
+	logIDs := make([]int64, len(c.Links))
+	for i, l := range c.Links {
+		steps[i] = map[string]any{"step": l.Step, "log_id": l.LogID, "source": l.Source, "timestamp": l.Timestamp}
+		logIDs[i] = l.LogID
+	}
+	details := map[string]any{
+		"rule":           c.RuleID,
+		"key":            c.Key,
+		"window":         c.Window,
+		"log_ids":        logIDs,
+		"steps":          steps,
+		"correlation_id": c.ID,
+	}
+	if c.EventLogID != 0 {
+		details["event_log_id"] = c.EventLogID
+	}
+	return Alert{
+		TenantID: c.TenantID,
+		Kind:     "correlation",
+		Severity: c.Severity,
+		Title:    c.Title,
+		Details:  details,
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// Run expires keys that haven't seen an event for longer than their rule's
+// window, so memory follows the rate of distinct keys rather than growing.
+func (j *JoinEngine) Run() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		j.mu.Lock()
+		windows := map[string]time.Duration{}
+		for _, r := range j.rules {
+			windows[r.ID] = r.window
+		}
+		for id, bucket := range j.state {
+			ruleID, _, _ := strings.Cut(id, "/")
+			for key, st := range bucket {
+				if now.Sub(st.touched) > windows[ruleID] {
+					delete(bucket, key)
+				}
+			}
+			if len(bucket) == 0 {
+				delete(j.state, id)
+			}
+		}
+		j.mu.Unlock()
+	}
+}
+
+// reload swaps in the rules at cfg.RulesPath. Open keys are kept for rules
+// that didn't change and dropped for changed or removed ones, since their
+// steps may no longer line up.
+func (j *JoinEngine) reload(cfg JoinConfig) error {
+	rules, err := loadJoinRules(cfg.RulesPath)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	keep := map[string]bool{}
+	for _, r := range rules {
+		for _, old := range j.rules {
+			if old.ID == r.ID && reflect.DeepEqual(old, r) {
+				keep[r.ID] = true
+			}
+		}
+	}
+	for id := range j.state {
+		if ruleID, _, _ := strings.Cut(id, "/"); !keep[ruleID] {
+			delete(j.state, id)
+		}
+	}
+	j.rules, j.cfg = rules, cfg
+	return nil
+}
+
+// handler serves GET /api/joins: the loaded rules with how often each fired
+// and how many keys the caller's tenant has open.
+func (j *JoinEngine) handler(w http.ResponseWriter, r *http.Request) {
+	tenant := tenantFromRequest(r)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	type ruleStatus struct {
+		JoinRule
+		OpenKeys int   `json:"open_keys"`
+		Fired    int64 `json:"fired"`
+		Capped   int64 `json:"capped,omitempty"`
+	}
+	rules := make([]ruleStatus, 0, len(j.rules))
+	for _, rule := range j.rules {
+		rules = append(rules, ruleStatus{
+			JoinRule: rule,
+			OpenKeys: len(j.state[rule.ID+"/"+tenant]),
+			Fired:    j.fired[rule.ID],
+			Capped:   j.capped[rule.ID],
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"joins": rules, "max_keys": j.cfg.MaxKeys})
+}
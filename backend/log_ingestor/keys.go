@@ -0,0 +1,515 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+
+	"github.com/gorilla/websocket"
+)
+
+// APIKey is an API credential. Only a SHA-256 hash of the secret is stored.
+type APIKey struct {
+	ID             int64      `json:"id"`
+	Name           string     `json:"name"`
+	Prefix         string     `json:"prefix"`
+	TenantID       string     `json:"tenant_id"`
+	Role           string     `json:"role"` // enforced when rbac is enabled
+	AllowedCIDRs   []string   `json:"allowed_cidrs,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP     string     `json:"last_used_ip,omitempty"`
+	UnexpectedIP   string     `json:"unexpected_ip,omitempty"`
+	UnexpectedIPAt *time.Time `json:"unexpected_ip_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+
+	hash     string
+	networks []*net.IPNet
+}
+
+// allows reports whether ip falls within the key's expected ranges. Keys
+// without ranges accept any address.
+func (k *APIKey) allows(ip net.IP) bool {
+	if len(k.networks) == 0 {
+		return true
+	}
+	for _, n := range k.networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	errMissingKey = errors.New("missing API key")
+	errInvalidKey = errors.New("invalid API key")
+)
+
+type keyUse struct {
+	at     time.Time
+	ip     string
+	tenant string
+}
+
+type ctxKey int
+
+const apiKeyCtxKey ctxKey = iota
+
+// keyFromContext returns the API key that authenticated the request, if any.
+func keyFromContext(ctx context.Context) *APIKey {
+	k, _ := ctx.Value(apiKeyCtxKey).(*APIKey)
+	return k
+}
+
+// KeyStore caches active API keys in memory and batches last-used updates,
+// so authenticating a request costs one primary key lookup to confirm the
+// key hasn't been revoked since the cache was refreshed.
+type KeyStore struct {
+	db  *store.DB
+	cfg AuthConfig
+	jwt *jwtVerifier // nil unless JWTs are accepted
+
+	mu     sync.RWMutex
+	byHash map[string]*APIKey
+	usage  map[int64]keyUse
+	flags  map[int64]keyUse // key ID -> use from an unexpected IP awaiting flush
+}
+
+func NewKeyStore(db *store.DB, cfg AuthConfig) (*KeyStore, error) {
+	jwt, err := newJWTVerifier(cfg.JWT)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyStore{
+		db:     db,
+		cfg:    cfg,
+		jwt:    jwt,
+		byHash: map[string]*APIKey{},
+		usage:  map[int64]keyUse{},
+		flags:  map[int64]keyUse{},
+	}, nil
+}
+
+// Refresh reloads active keys from the database.
+func (ks *KeyStore) Refresh() error {
+	keys, err := listAPIKeys(ks.db, false)
+	if err != nil {
+		return err
+	}
+	byHash := make(map[string]*APIKey, len(keys))
+	for i := range keys {
+		byHash[keys[i].hash] = &keys[i]
+	}
+	ks.mu.Lock()
+	ks.byHash = byHash
+	ks.mu.Unlock()
+	return nil
+}
+
+// Authenticate resolves the key presented in the Authorization (Bearer) or
+// X-API-Key header (or api_key parameter on WebSocket upgrades) and records
+// its use.
+func (ks *KeyStore) Authenticate(r *http.Request) (*APIKey, error) {
+	secret := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); secret == "" && strings.HasPrefix(auth, "Bearer ") {
+		secret = strings.TrimPrefix(auth, "Bearer ")
+	}
+	// Browsers can't set headers on WebSocket upgrades.
+	if secret == "" && websocket.IsWebSocketUpgrade(r) {
+		secret = r.URL.Query().Get("api_key")
+	}
+	return ks.AuthenticateSecret(secret, clientIP(r))
+}
+
+// AuthenticateSecret resolves a raw key secret presented from ip and records
+// its use. It is shared by the HTTP and gRPC front ends.
+func (ks *KeyStore) AuthenticateSecret(secret, ip string) (*APIKey, error) {
+	if secret == "" {
+		return nil, errMissingKey
+	}
+	if ks.jwt != nil && looksLikeJWT(secret) {
+		return ks.jwt.verify(secret)
+	}
+
+	hash := hashKey(secret)
+	ks.mu.RLock()
+	key := ks.byHash[hash]
+	ks.mu.RUnlock()
+	if key == nil {
+		return nil, errInvalidKey
+	}
+	if ks.revoked(key) {
+		ks.mu.Lock()
+		delete(ks.byHash, hash)
+		ks.mu.Unlock()
+		return nil, errInvalidKey
+	}
+
+	ks.mu.Lock()
+	use := keyUse{at: time.Now(), ip: ip, tenant: key.TenantID}
+	ks.usage[key.ID] = use
+	if parsed := net.ParseIP(ip); parsed != nil && !key.allows(parsed) {
+		if _, pending := ks.flags[key.ID]; !pending && key.UnexpectedIP != ip {
+			slog.Warn("API key used from unexpected address", "key", key.Name, "prefix", key.Prefix, "ip", ip)
+		}
+		ks.flags[key.ID] = use
+		key.UnexpectedIP = ip
+	}
+	ks.mu.Unlock()
+	return key, nil
+}
+
+// revoked reports whether key was revoked after the cache was refreshed,
+// e.g. by 'keys revoke' or on another replica. If the check itself fails
+// the cached key stands, so a database outage doesn't turn away clients
+// whose entries the retry queue would keep.
+func (ks *KeyStore) revoked(key *APIKey) bool {
+	var revokedAt sql.NullTime
+	err := ks.db.QueryRow(`SELECT revoked_at FROM api_keys WHERE id = ?`, key.ID).Scan(&revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true
+	}
+	if err != nil {
+		slog.Warn("Failed to check API key revocation", "key", key.Name, "err", err)
+		return false
+	}
+	return revokedAt.Valid
+}
+
+// Middleware authenticates requests. Unless required is set, requests
+// without a key pass through anonymously; invalid keys are always rejected.
+func (ks *KeyStore) Middleware(required bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, err := ks.Authenticate(r)
+		switch {
+		case err == errMissingKey && !required:
+			next.ServeHTTP(w, r)
+		case err != nil:
+			writeError(w, http.StatusUnauthorized, err.Error())
+		default:
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey, key)))
+		}
+	})
+}
+
+// Run flushes usage, refreshes the key cache, and checks credential hygiene.
+func (ks *KeyStore) Run() {
+	flush := time.NewTicker(30 * time.Second)
+	defer flush.Stop()
+	interval, _ := time.ParseDuration(ks.cfg.CheckInterval)
+	hygiene := time.NewTicker(interval)
+	defer hygiene.Stop()
+
+	for {
+		select {
+		case <-flush.C:
+			if err := ks.flushUsage(); err != nil {
+				slog.Warn("Failed to record API key usage", "err", err)
+			}
+			if err := ks.Refresh(); err != nil {
+				slog.Warn("Failed to refresh API keys", "err", err)
+			}
+		case <-hygiene.C:
+			ks.checkHygiene()
+		}
+	}
+}
+
+// flushUsage writes pending usage and unexpected-IP flags. Entries that
+// fail to write are put back for the next flush unless a newer use has
+// replaced them since.
+func (ks *KeyStore) flushUsage() error {
+	ks.mu.Lock()
+	usage, flags := ks.usage, ks.flags
+	ks.usage, ks.flags = map[int64]keyUse{}, map[int64]keyUse{}
+	ks.mu.Unlock()
+
+	var errs []error
+	unflushed, unflagged := map[int64]keyUse{}, map[int64]keyUse{}
+	for id, u := range usage {
+		if _, err := ks.db.Exec(`UPDATE api_keys SET last_used_at = ?, last_used_ip = ? WHERE id = ?`, u.at, u.ip, id); err != nil {
+			errs = append(errs, fmt.Errorf("key %d usage: %w", id, err))
+			unflushed[id] = u
+		}
+	}
+	for id, u := range flags {
+		if _, err := ks.db.Exec(`UPDATE api_keys SET unexpected_ip = ?, unexpected_ip_at = ? WHERE id = ?`, u.ip, u.at, id); err != nil {
+			errs = append(errs, fmt.Errorf("key %d unexpected ip: %w", id, err))
+			unflagged[id] = u
+			continue
+		}
+		recordAudit(ks.db, u.tenant, "auth", "key.unexpected_ip", map[string]any{"key_id": id, "ip": u.ip})
+	}
+
+	if len(errs) > 0 {
+		ks.mu.Lock()
+		for id, u := range unflushed {
+			if _, newer := ks.usage[id]; !newer {
+				ks.usage[id] = u
+			}
+		}
+		for id, u := range unflagged {
+			if _, newer := ks.flags[id]; !newer {
+				ks.flags[id] = u
+			}
+		}
+		ks.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// KeyHygieneReport lists credentials that are candidates for revocation.
+type KeyHygieneReport struct {
+	Stale        []APIKey `json:"stale"`
+	UnexpectedIP []APIKey `json:"unexpected_ip"`
+}
+
+func (ks *KeyStore) checkHygiene() {
+	report, err := keyHygiene(ks.db, ks.cfg)
+	if err != nil {
+		slog.Warn("API key hygiene check failed", "err", err)
+		return
+	}
+	for _, k := range report.Stale {
+		slog.Warn("API key unused; consider revoking", "key", k.Name, "prefix", k.Prefix, "last_seen", lastSeen(k))
+	}
+	for _, k := range report.UnexpectedIP {
+		slog.Warn("API key was used from unexpected address", "key", k.Name, "prefix", k.Prefix, "ip", k.UnexpectedIP)
+	}
+}
+
+func keyHygiene(db *store.DB, cfg AuthConfig) (KeyHygieneReport, error) {
+	report := KeyHygieneReport{Stale: []APIKey{}, UnexpectedIP: []APIKey{}}
+	keys, err := listAPIKeys(db, false)
+	if err != nil {
+		return report, err
+	}
+	staleAfter, _ := time.ParseDuration(cfg.StaleAfter)
+	cutoff := time.Now().Add(-staleAfter)
+	for _, k := range keys {
+		if lastSeen(k).Before(cutoff) {
+			report.Stale = append(report.Stale, k)
+		}
+		if k.UnexpectedIPAt != nil {
+			report.UnexpectedIP = append(report.UnexpectedIP, k)
+		}
+	}
+	return report, nil
+}
+
+func lastSeen(k APIKey) time.Time {
+	if k.LastUsedAt != nil {
+		return *k.LastUsedAt
+	}
+	return k.CreatedAt
+}
+
+// clientIP returns the request's remote address without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// createAPIKey generates and stores a new key, returning the secret. The
+// secret is shown once and cannot be recovered later.
+func createAPIKey(db *store.DB, name, tenantID, role string, cidrs []string) (APIKey, string, error) {
+	if !validRole(role) {
+		return APIKey{}, "", fmt.Errorf("invalid role %q; use viewer, analyst, admin, or platform", role)
+	}
+	for _, c := range cidrs {
+		if _, _, err := net.ParseCIDR(c); err != nil {
+			return APIKey{}, "", fmt.Errorf("invalid CIDR %q", c)
+		}
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return APIKey{}, "", err
+	}
+	secret := "1lx_" + hex.EncodeToString(buf)
+	key := APIKey{Name: name, Prefix: secret[:12], TenantID: tenantID, Role: role, AllowedCIDRs: cidrs, CreatedAt: time.Now()}
+
+	id, err := db.Insert(`
+		INSERT INTO api_keys (name, prefix, tenant_id, role, key_hash, allowed_cidrs) VALUES (?, ?, ?, ?, ?, ?)`,
+		name, key.Prefix, tenantID, role, hashKey(secret), strings.Join(cidrs, ","),
+	)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	key.ID = id
+	return key, secret, nil
+}
+
+func revokeAPIKey(db *store.DB, id int64) (bool, error) {
+	res, err := db.Exec(`UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func listAPIKeys(db *store.DB, includeRevoked bool) ([]APIKey, error) {
+	query := `SELECT id, name, prefix, tenant_id, role, key_hash, allowed_cidrs, created_at, last_used_at, last_used_ip,
+		unexpected_ip, unexpected_ip_at, revoked_at FROM api_keys`
+	if !includeRevoked {
+		query += ` WHERE revoked_at IS NULL`
+	}
+	rows, err := db.Query(query + ` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		var (
+			k                                 APIKey
+			cidrs, lastIP, unexpectedIP       sql.NullString
+			lastUsed, unexpectedAt, revokedAt sql.NullTime
+		)
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &k.TenantID, &k.Role, &k.hash, &cidrs, &k.CreatedAt, &lastUsed, &lastIP,
+			&unexpectedIP, &unexpectedAt, &revokedAt); err != nil {
+			return nil, err
+		}
+		k.LastUsedIP, k.UnexpectedIP = lastIP.String, unexpectedIP.String
+		k.LastUsedAt, k.UnexpectedIPAt, k.RevokedAt = timePtr(lastUsed), timePtr(unexpectedAt), timePtr(revokedAt)
+		k.AllowedCIDRs = splitList(cidrs.String)
+		for _, c := range k.AllowedCIDRs {
+			if _, n, err := net.ParseCIDR(c); err == nil {
+				k.networks = append(k.networks, n)
+			}
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// heartbeatHandler serves POST /api/heartbeat so agents can prove liveness
+// (and keep their key from being flagged stale) while they have nothing to send.
+func heartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	if keyFromContext(r.Context()) == nil {
+		writeError(w, http.StatusUnauthorized, errMissingKey.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// keysCommand manages API keys: create, list, revoke, hygiene.
+func keysCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: keys <create|list|revoke|hygiene> [flags]")
+	}
+	sub, args := args[0], args[1:]
+	fs := flag.NewFlagSet("keys "+sub, flag.ExitOnError)
+	name := fs.String("name", "", "key name (create)")
+	cidrs := fs.String("cidrs", "", "comma-separated expected source ranges (create)")
+	tenant := fs.String("tenant", defaultTenant, "tenant the key belongs to (create)")
+	role := fs.String("role", roleViewer, "viewer, analyst, admin, or platform; enforced when rbac is enabled (create)")
+	id := fs.Int64("id", 0, "key id (revoke)")
+	all := fs.Bool("all", false, "include revoked keys (list)")
+	configPath := fs.String("config", "../config.yaml", "path to config file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	db, err := store.Open(config)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	switch sub {
+	case "create":
+		if *name == "" {
+			return fmt.Errorf("-name is required")
+		}
+		if !config.Tenancy.Known(*tenant) {
+			return fmt.Errorf("unknown tenant %q; add it under tenancy.tenants in config", *tenant)
+		}
+		key, secret, err := createAPIKey(db, *name, *tenant, *role, splitList(*cidrs))
+		if err != nil {
+			return err
+		}
+		recordAudit(db, key.TenantID, "cli", "key.create", key)
+		fmt.Fprintf(tw, "Created key %d (%s). Store this secret now; it will not be shown again:\n%s\n", key.ID, key.Name, secret)
+
+	case "list":
+		keys, err := listAPIKeys(db, *all)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(tw, "ID\tNAME\tTENANT\tROLE\tPREFIX\tLAST USED\tLAST IP\tFLAG\tREVOKED")
+		for _, k := range keys {
+			flag := ""
+			if k.UnexpectedIPAt != nil {
+				flag = "unexpected ip " + k.UnexpectedIP
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.TenantID, k.Role, k.Prefix, formatTimePtr(k.LastUsedAt), k.LastUsedIP, flag, formatTimePtr(k.RevokedAt))
+		}
+
+	case "revoke":
+		ok, err := revokeAPIKey(db, *id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("no active key with id %d", *id)
+		}
+		recordAudit(db, defaultTenant, "cli", "key.revoke", map[string]any{"id": *id})
+		fmt.Fprintf(tw, "Revoked key %d\n", *id)
+
+	case "hygiene":
+		report, err := keyHygiene(db, config.Auth.WithDefaults())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tISSUE")
+		for _, k := range report.Stale {
+			fmt.Fprintf(tw, "%d\t%s\t%s\tunused since %s\n", k.ID, k.Name, k.Prefix, lastSeen(k).Format(time.RFC3339))
+		}
+		for _, k := range report.UnexpectedIP {
+			fmt.Fprintf(tw, "%d\t%s\t%s\tused from unexpected address %s\n", k.ID, k.Name, k.Prefix, k.UnexpectedIP)
+		}
+
+	default:
+		return fmt.Errorf("unknown keys command %q", sub)
+	}
+	return nil
+}
+
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
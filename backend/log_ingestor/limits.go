@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"1logx/log_ingestor/internal/pipeline"
+)
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills b for the time since its last use and takes a token if one
+// is available. With dryRun the bucket is refilled but no token is taken.
+func (b *tokenBucket) take(l RateLimit, now time.Time, dryRun bool) bool {
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	if !dryRun {
+		b.tokens--
+	}
+	return true
+}
+
+// shedHysteresis is how far saturation must fall below a severity's
+// threshold before it is accepted again, so shedding doesn't flap.
+const shedHysteresis = 0.1
+
+// IngestLimiter applies rate limits and the circuit breaker.
+type IngestLimiter struct {
+	retry *RetryQueue // nil without a retry queue
+	load  *pipeline.Load
+
+	mu         sync.Mutex // guards cfg and idleAfter too, which reload swaps
+	cfg        LimitsConfig
+	idleAfter  time.Duration
+	buckets    map[string]*tokenBucket // dimension:key -> bucket
+	limited    map[string]int64        // dimension -> rejected entries
+	shedCounts map[Severity]int64
+	saturation float64
+
+	// shedding holds a bit per Severity currently shed, read on every ingest.
+	shedding atomic.Uint32
+}
+
+func NewIngestLimiter(cfg LimitsConfig, retry *RetryQueue, load *pipeline.Load) *IngestLimiter {
+	idle, _ := time.ParseDuration(cfg.IdleAfter)
+	return &IngestLimiter{
+		cfg:        cfg,
+		retry:      retry,
+		load:       load,
+		idleAfter:  idle,
+		buckets:    map[string]*tokenBucket{},
+		limited:    map[string]int64{},
+		shedCounts: map[Severity]int64{},
+	}
+}
+
+// Allow takes a token from each bucket entry falls under, or none if any is
+// empty, so a rejection by one limit doesn't spend the others.
+func (l *IngestLimiter) Allow(origin pipeline.Origin, entry LogEntry) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.cfg.Enabled {
+		return nil
+	}
+	type check struct {
+		dimension, key string
+		limit          RateLimit
+	}
+	checks := []check{{"source", entry.TenantID + "/" + entry.Source, l.cfg.PerSource}}
+	if origin.IP != "" {
+		checks = append(checks, check{"ip", origin.IP, l.cfg.PerIP})
+	}
+	if origin.Key != "" {
+		checks = append(checks, check{"key", origin.Key, l.cfg.PerKey})
+	}
+
+	now := time.Now()
+	buckets := make([]*tokenBucket, len(checks))
+	for i, c := range checks {
+		if c.limit.Rate <= 0 {
+			continue
+		}
+		id := c.dimension + ":" + c.key
+		b, ok := l.buckets[id]
+		if !ok {
+			b = &tokenBucket{tokens: float64(c.limit.Burst), last: now}
+			l.buckets[id] = b
+		}
+		if !b.take(c.limit, now, true) {
+			l.limited[c.dimension]++
+			return fmt.Errorf("%w: %s %s exceeds %g events/sec", pipeline.ErrRateLimited, c.dimension, c.key, c.limit.Rate)
+		}
+		buckets[i] = b
+	}
+	for i, b := range buckets {
+		if b != nil {
+			b.take(checks[i].limit, now, false)
+		}
+	}
+	return nil
+}
+
+// Shed reports whether the breaker is currently dropping entries of
+// entry's severity, counting the entry if so.
+func (l *IngestLimiter) Shed(entry LogEntry) bool {
+	if l == nil || l.shedding.Load()&(1<<uint(entry.Severity)) == 0 {
+		return false
+	}
+	l.mu.Lock()
+	l.shedCounts[entry.Severity]++
+	l.mu.Unlock()
+	return true
+}
+
+// sample measures saturation and updates which severities are shed.
+func (l *IngestLimiter) sample() {
+	l.mu.Lock()
+	breaker := l.cfg.Breaker
+	l.mu.Unlock()
+	if !breaker.Enabled {
+		if l.shedding.Swap(0) != 0 {
+			slog.Info("Ingest breaker disabled")
+		}
+		return
+	}
+
+	saturation := float64(l.load.InFlight()) / float64(breaker.MaxInFlight)
+	if l.retry != nil {
+		saturation = math.Max(saturation, float64(len(l.retry.queue))/float64(cap(l.retry.queue)))
+	}
+
+	prev := l.shedding.Load()
+	var next uint32
+	for _, sev := range AllSeverities {
+		threshold, ok := breaker.Shed[sev.String()]
+		if !ok {
+			continue
+		}
+		bit := uint32(1) << uint(sev)
+		if saturation >= threshold || (prev&bit != 0 && saturation > threshold-shedHysteresis) {
+			next |= bit
+		}
+	}
+	l.shedding.Store(next)
+
+	l.mu.Lock()
+	l.saturation = saturation
+	l.mu.Unlock()
+	if next != prev {
+		if next == 0 {
+			slog.Info("Ingest breaker closed", "saturation", saturation)
+		} else {
+			slog.Warn("Ingest breaker shedding", "severities", severityNames(next), "saturation", saturation)
+		}
+	}
+}
+
+func severityNames(mask uint32) []string {
+	var names []string
+	for _, sev := range AllSeverities {
+		if mask&(1<<uint(sev)) != 0 {
+			names = append(names, sev.String())
+		}
+	}
+	return names
+}
+
+// sweep forgets buckets that have been idle long enough to be full again.
+func (l *IngestLimiter) sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for id, b := range l.buckets {
+		if now.Sub(b.last) > l.idleAfter {
+			delete(l.buckets, id)
+		}
+	}
+}
+
+// setConfig swaps in reloaded limits. Existing buckets keep their tokens
+// and refill at the new rates.
+func (l *IngestLimiter) setConfig(cfg LimitsConfig) {
+	idle, _ := time.ParseDuration(cfg.IdleAfter)
+	l.mu.Lock()
+	l.cfg, l.idleAfter = cfg, idle
+	l.mu.Unlock()
+}
+
+// Run samples the breaker and sweeps idle buckets.
+func (l *IngestLimiter) Run() {
+	l.mu.Lock()
+	interval, _ := time.ParseDuration(l.cfg.Breaker.Interval)
+	l.mu.Unlock()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastSweep := time.Now()
+	for now := range ticker.C {
+		l.sample()
+		l.mu.Lock()
+		idle := l.idleAfter
+		next, _ := time.ParseDuration(l.cfg.Breaker.Interval)
+		l.mu.Unlock()
+		if now.Sub(lastSweep) > idle {
+			l.sweep(now)
+			lastSweep = now
+		}
+		if next != interval {
+			interval = next
+			ticker.Reset(interval)
+		}
+	}
+}
+
+// LimitsReport is the limiter's state and counters since startup.
+type LimitsReport struct {
+	Saturation  float64          `json:"saturation"`
+	Shedding    []string         `json:"shedding"`
+	RateLimited map[string]int64 `json:"rate_limited"` // by dimension
+	Shed        map[string]int64 `json:"shed"`         // by severity
+	Buckets     int              `json:"buckets"`
+}
+
+func (l *IngestLimiter) report() LimitsReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := LimitsReport{
+		Saturation:  l.saturation,
+		Shedding:    severityNames(l.shedding.Load()),
+		RateLimited: map[string]int64{"source": l.limited["source"], "ip": l.limited["ip"], "key": l.limited["key"]},
+		Shed:        map[string]int64{},
+		Buckets:     len(l.buckets),
+	}
+	for _, sev := range AllSeverities {
+		r.Shed[sev.String()] = l.shedCounts[sev]
+	}
+	return r
+}
+
+// limitsHandler serves GET /api/admin/ingest/limits as JSON, or in the
+// Prometheus text format with ?format=prometheus.
+func limitsHandler(l *IngestLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := l.report()
+		if r.URL.Query().Get("format") != "prometheus" {
+			writeJSON(w, http.StatusOK, report)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintln(w, "# HELP logx_ingest_saturation Write path saturation seen by the circuit breaker, 0-1.")
+		fmt.Fprintln(w, "# TYPE logx_ingest_saturation gauge")
+		fmt.Fprintf(w, "logx_ingest_saturation %g\n", report.Saturation)
+		fmt.Fprintln(w, "# HELP logx_ingest_rate_limited_total Entries rejected by a rate limit.")
+		fmt.Fprintln(w, "# TYPE logx_ingest_rate_limited_total counter")
+		for _, dim := range sortedKeys(report.RateLimited) {
+			fmt.Fprintf(w, "logx_ingest_rate_limited_total{dimension=%q} %d\n", dim, report.RateLimited[dim])
+		}
+		fmt.Fprintln(w, "# HELP logx_ingest_shed_total Entries dropped by the circuit breaker.")
+		fmt.Fprintln(w, "# TYPE logx_ingest_shed_total counter")
+		for _, sev := range sortedKeys(report.Shed) {
+			fmt.Fprintf(w, "logx_ingest_shed_total{severity=%q} %d\n", sev, report.Shed[sev])
+		}
+	}
+}
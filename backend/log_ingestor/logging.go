@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// logLevel is the minimum level logged, adjustable at runtime.
+var logLevel = new(slog.LevelVar)
+
+// setLogLevel parses and applies a level name such as "debug".
+func setLogLevel(name string) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return fmt.Errorf("invalid log level %q", name)
+	}
+	logLevel.Set(level)
+	return nil
+}
+
+// setupLogging installs the default logger. Output of the standard log
+// package, e.g. from dependencies, goes through it too at info level.
+func setupLogging(cfg LoggingConfig) error {
+	if err := setLogLevel(cfg.Level); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// fatal logs msg at error level and exits, like log.Fatal.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,533 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"1logx/log_ingestor/internal/pipeline"
+)
+
+// Information elements read from v9 and IPFIX records. v9 field types and
+// IPFIX element IDs agree for these.
+const (
+	ieOctetDeltaCount     = 1
+	iePacketDeltaCount    = 2
+	ieProtocolIdentifier  = 4
+	ieTCPControlBits      = 6
+	ieSourceTransportPort = 7
+	ieSourceIPv4Address   = 8
+	ieDestTransportPort   = 11
+	ieDestIPv4Address     = 12
+	ieFlowEndSysUpTime    = 21 // v9 only: IPFIX uptimes need an init time
+	ieFlowStartSysUpTime  = 22
+	ieSourceIPv6Address   = 27
+	ieDestIPv6Address     = 28
+	ieOctetTotalCount     = 85
+	iePacketTotalCount    = 86
+	ieFlowStartSeconds    = 150
+	ieFlowEndSeconds      = 151
+	ieFlowStartMillis     = 152
+	ieFlowEndMillis       = 153
+)
+
+// Packet layout.
+const (
+	netflowV5HeaderLength  = 24
+	netflowV5RecordLength  = 48
+	netflowV9HeaderLength  = 20
+	ipfixHeaderLength      = 16
+	netflowV9TemplateSetID = 0
+	ipfixTemplateSetID     = 2
+	minDataSetID           = 256   // lower set IDs are templates and options templates
+	ipfixVariableLength    = 65535 // field length of variable-length elements
+)
+
+var errShortFlowPacket = errors.New("truncated flow packet")
+
+// flowRecord is one decoded flow, before it's mapped onto a LogEntry.
+type flowRecord struct {
+	Version          int
+	Exporter         netip.Addr
+	Src, Dst         netip.Addr
+	SrcPort, DstPort uint16
+	Protocol         uint8
+	TCPFlags         uint16
+	Bytes, Packets   uint64
+	Start, End       time.Time
+}
+
+var flowProtocols = map[uint8]string{1: "ICMP", 6: "TCP", 17: "UDP", 47: "GRE", 50: "ESP", 58: "ICMPv6", 132: "SCTP"}
+
+func (r flowRecord) protocolName() string {
+	if name, ok := flowProtocols[r.Protocol]; ok {
+		return name
+	}
+	return strconv.Itoa(int(r.Protocol))
+}
+
+// tcpFlagNames lists TCP control bits, lowest first.
+var tcpFlagNames = []string{"FIN", "SYN", "RST", "PSH", "ACK", "URG", "ECE", "CWR"}
+
+func (r flowRecord) flags() string {
+	var names []string
+	for i, name := range tcpFlagNames {
+		if r.TCPFlags&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// hasPorts reports whether the protocol carries transport ports.
+func (r flowRecord) hasPorts() bool {
+	return r.Protocol == 6 || r.Protocol == 17 || r.Protocol == 132
+}
+
+// entry maps r onto a LogEntry. Fields use the CEF extension names firewall
+// logs carry (src, dst, spt, dpt, proto), so joins can key on either.
+func (r flowRecord) entry(source string) LogEntry {
+	proto := r.protocolName()
+	src, dst := r.Src.String(), r.Dst.String()
+	entry := LogEntry{
+		Timestamp: r.End,
+		Source:    source,
+		Severity:  SeverityInfo,
+		IPAddress: src,
+		Fields: map[string]string{
+			"src":          src,
+			"dst":          dst,
+			"proto":        proto,
+			"bytes":        strconv.FormatUint(r.Bytes, 10),
+			"packets":      strconv.FormatUint(r.Packets, 10),
+			"exporter":     r.Exporter.String(),
+			"flow_version": strconv.Itoa(r.Version),
+		},
+	}
+	if r.hasPorts() {
+		entry.Fields["spt"] = strconv.Itoa(int(r.SrcPort))
+		entry.Fields["dpt"] = strconv.Itoa(int(r.DstPort))
+		src = net.JoinHostPort(src, entry.Fields["spt"])
+		dst = net.JoinHostPort(dst, entry.Fields["dpt"])
+	}
+	entry.Message = fmt.Sprintf("%s %s -> %s, %d bytes in %d packets", proto, src, dst, r.Bytes, r.Packets)
+	if flags := r.flags(); r.Protocol == 6 && flags != "" {
+		entry.Fields["tcp_flags"] = flags
+		entry.Message += ", flags " + flags
+	}
+	if !r.Start.IsZero() && !r.End.Before(r.Start) {
+		entry.Fields["duration_ms"] = strconv.FormatInt(r.End.Sub(r.Start).Milliseconds(), 10)
+	}
+	return entry
+}
+
+// flowTemplateKey identifies a v9/IPFIX template: template IDs are only
+// unique per exporter and observation domain (v9 source ID).
+type flowTemplateKey struct {
+	exporter netip.Addr
+	version  uint16
+	domain   uint32
+	id       uint16
+}
+
+type flowTemplate struct {
+	fields []flowField
+	seen   time.Time
+}
+
+type flowField struct {
+	id         uint16
+	length     uint16
+	enterprise bool // IPFIX enterprise-specific element, skipped
+}
+
+// NetFlowCollector receives flow export packets and ingests their records.
+type NetFlowCollector struct {
+	cfg NetFlowConfig
+	// ingest stores one record: the Ingestor, or a pipeline's queue.
+	ingest    func(pipeline.Origin, LogEntry) error
+	exporters []netip.Prefix
+	ttl       time.Duration
+
+	mu        sync.Mutex
+	templates map[flowTemplateKey]flowTemplate
+}
+
+func NewNetFlowCollector(cfg NetFlowConfig, in *pipeline.Ingestor) (*NetFlowCollector, error) {
+	c := &NetFlowCollector{cfg: cfg, templates: map[flowTemplateKey]flowTemplate{}}
+	c.ingest = func(origin pipeline.Origin, entry LogEntry) error {
+		_, err := in.IngestFrom(origin, entry)
+		return err
+	}
+	c.ttl, _ = time.ParseDuration(cfg.TemplateTTL)
+	for _, e := range cfg.Exporters {
+		p, err := parseIndicator(e)
+		if err != nil {
+			return nil, fmt.Errorf("exporter %q: %w", e, err)
+		}
+		c.exporters = append(c.exporters, p)
+	}
+	return c, nil
+}
+
+type flowPacket struct {
+	exporter netip.Addr
+	data     []byte
+}
+
+// Run listens for export packets until the socket fails. A store slower than
+// the exporters backs up into the socket buffer, where the kernel drops
+// packets as UDP does.
+func (c *NetFlowCollector) Run() error {
+	conn, err := net.ListenPacket("udp", c.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	slog.Info("NetFlow/IPFIX collector running", "addr", c.cfg.Addr, "source", c.cfg.Source, "tenant", c.cfg.Tenant)
+
+	packets := make(chan flowPacket, c.cfg.Workers)
+	defer close(packets)
+	for range c.cfg.Workers {
+		go func() {
+			for p := range packets {
+				c.handle(p)
+			}
+		}()
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		udp, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		exporter, _ := netip.AddrFromSlice(udp.IP)
+		exporter = exporter.Unmap()
+		if !c.allowed(exporter) {
+			continue
+		}
+		packets <- flowPacket{exporter: exporter, data: append([]byte(nil), buf[:n]...)}
+	}
+}
+
+func (c *NetFlowCollector) allowed(exporter netip.Addr) bool {
+	if len(c.exporters) == 0 {
+		return true
+	}
+	for _, p := range c.exporters {
+		if p.Contains(exporter) {
+			return true
+		}
+	}
+	return false
+}
+
+// handle decodes one packet and ingests its flows. Failures are logged once
+// per packet rather than per record.
+func (c *NetFlowCollector) handle(p flowPacket) {
+	records, missing, err := c.decode(p.exporter, p.data, time.Now())
+	if err != nil {
+		slog.Debug("Dropped malformed flow packet", "exporter", p.exporter, "err", err)
+		return
+	}
+	if missing > 0 {
+		// Normal until the exporter next sends its templates.
+		slog.Debug("Skipped flow sets without a known template", "exporter", p.exporter, "sets", missing)
+	}
+	origin := pipeline.Origin{IP: p.exporter.String(), Input: "netflow"}
+	var dropped int
+	var lastErr error
+	for _, r := range records {
+		entry := r.entry(c.cfg.Source)
+		entry.TenantID = c.cfg.Tenant
+		if err := c.ingest(origin, entry); err != nil && !errors.Is(err, pipeline.ErrQueuedForRetry) {
+			dropped++
+			lastErr = err
+		}
+	}
+	if dropped > 0 {
+		slog.Warn("Failed to ingest flow records", "exporter", p.exporter, "dropped", dropped, "of", len(records), "err", lastErr)
+	}
+}
+
+// decode parses a NetFlow v5, v9, or IPFIX packet. missing counts data sets
+// skipped because their template hasn't been received yet.
+func (c *NetFlowCollector) decode(exporter netip.Addr, data []byte, now time.Time) (records []flowRecord, missing int, err error) {
+	if len(data) < 2 {
+		return nil, 0, errShortFlowPacket
+	}
+	switch version := binary.BigEndian.Uint16(data); version {
+	case 5:
+		records, err = decodeNetFlowV5(exporter, data)
+		return records, 0, err
+	case 9, 10:
+		return c.decodeTemplated(exporter, version, data, now)
+	default:
+		return nil, 0, fmt.Errorf("unsupported flow export version %d", version)
+	}
+}
+
+func decodeNetFlowV5(exporter netip.Addr, data []byte) ([]flowRecord, error) {
+	if len(data) < netflowV5HeaderLength {
+		return nil, errShortFlowPacket
+	}
+	count := int(binary.BigEndian.Uint16(data[2:]))
+	uptime := binary.BigEndian.Uint32(data[4:])
+	exported := time.Unix(int64(binary.BigEndian.Uint32(data[8:])), int64(binary.BigEndian.Uint32(data[12:])))
+	if len(data) < netflowV5HeaderLength+count*netflowV5RecordLength {
+		return nil, errShortFlowPacket
+	}
+	records := make([]flowRecord, 0, count)
+	for i := range count {
+		b := data[netflowV5HeaderLength+i*netflowV5RecordLength:]
+		records = append(records, flowRecord{
+			Version:  5,
+			Exporter: exporter,
+			Src:      netip.AddrFrom4([4]byte(b[0:4])),
+			Dst:      netip.AddrFrom4([4]byte(b[4:8])),
+			Packets:  uint64(binary.BigEndian.Uint32(b[16:])),
+			Bytes:    uint64(binary.BigEndian.Uint32(b[20:])),
+			Start:    uptimeTime(exported, uptime, binary.BigEndian.Uint32(b[24:])),
+			End:      uptimeTime(exported, uptime, binary.BigEndian.Uint32(b[28:])),
+			SrcPort:  binary.BigEndian.Uint16(b[32:]),
+			DstPort:  binary.BigEndian.Uint16(b[34:]),
+			TCPFlags: uint16(b[37]),
+			Protocol: b[38],
+		})
+	}
+	return records, nil
+}
+
+// uptimeTime converts a router uptime in milliseconds to wall time, given
+// the uptime at export.
+func uptimeTime(exported time.Time, uptime, at uint32) time.Time {
+	return exported.Add(-time.Duration(int32(uptime-at)) * time.Millisecond)
+}
+
+// decodeTemplated parses v9 and IPFIX packets, which share a layout: a
+// header followed by sets of templates or of records laid out by one.
+func (c *NetFlowCollector) decodeTemplated(exporter netip.Addr, version uint16, data []byte, now time.Time) ([]flowRecord, int, error) {
+	var uptime uint32
+	var exported time.Time
+	var domain uint32
+	var body []byte
+	if version == 9 {
+		if len(data) < netflowV9HeaderLength {
+			return nil, 0, errShortFlowPacket
+		}
+		uptime = binary.BigEndian.Uint32(data[4:])
+		exported = time.Unix(int64(binary.BigEndian.Uint32(data[8:])), 0)
+		domain = binary.BigEndian.Uint32(data[16:])
+		body = data[netflowV9HeaderLength:]
+	} else {
+		if len(data) < ipfixHeaderLength {
+			return nil, 0, errShortFlowPacket
+		}
+		length := int(binary.BigEndian.Uint16(data[2:]))
+		if length < ipfixHeaderLength || length > len(data) {
+			return nil, 0, errShortFlowPacket
+		}
+		exported = time.Unix(int64(binary.BigEndian.Uint32(data[4:])), 0)
+		domain = binary.BigEndian.Uint32(data[12:])
+		body = data[ipfixHeaderLength:length]
+	}
+
+	var records []flowRecord
+	missing := 0
+	for len(body) >= 4 {
+		setID := binary.BigEndian.Uint16(body)
+		length := int(binary.BigEndian.Uint16(body[2:]))
+		if length < 4 || length > len(body) {
+			return records, missing, errShortFlowPacket
+		}
+		set := body[4:length]
+		body = body[length:]
+
+		switch {
+		case setID == netflowV9TemplateSetID && version == 9, setID == ipfixTemplateSetID && version == 10:
+			if err := c.storeTemplates(flowTemplateKey{exporter: exporter, version: version, domain: domain}, set, version == 10, now); err != nil {
+				return records, missing, err
+			}
+		case setID >= minDataSetID:
+			key := flowTemplateKey{exporter: exporter, version: version, domain: domain, id: setID}
+			c.mu.Lock()
+			t, ok := c.templates[key]
+			if ok && now.Sub(t.seen) > c.ttl {
+				delete(c.templates, key)
+				ok = false
+			}
+			c.mu.Unlock()
+			if !ok {
+				missing++
+				continue
+			}
+			records = append(records, decodeDataSet(t, set, flowRecord{Version: int(version), Exporter: exporter}, exported, uptime)...)
+		}
+		// Options templates and their data describe the exporter, not
+		// traffic, and are skipped.
+	}
+	return records, missing, nil
+}
+
+// storeTemplates reads the templates of a template set. In IPFIX a template
+// with no fields withdraws it.
+func (c *NetFlowCollector) storeTemplates(key flowTemplateKey, set []byte, ipfix bool, now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(set) >= 4 {
+		key.id = binary.BigEndian.Uint16(set)
+		count := int(binary.BigEndian.Uint16(set[2:]))
+		set = set[4:]
+		if key.id < minDataSetID {
+			return fmt.Errorf("invalid template id %d", key.id)
+		}
+		if count == 0 {
+			delete(c.templates, key)
+			continue
+		}
+		t := flowTemplate{fields: make([]flowField, 0, count), seen: now}
+		for range count {
+			if len(set) < 4 {
+				return errShortFlowPacket
+			}
+			f := flowField{id: binary.BigEndian.Uint16(set), length: binary.BigEndian.Uint16(set[2:])}
+			set = set[4:]
+			if ipfix && f.id&0x8000 != 0 {
+				if len(set) < 4 {
+					return errShortFlowPacket
+				}
+				f.id &^= 0x8000
+				f.enterprise = true
+				set = set[4:]
+			}
+			t.fields = append(t.fields, f)
+		}
+		c.templates[key] = t
+	}
+	return nil
+}
+
+// decodeDataSet reads records laid out by t until the set runs out; what's
+// left is padding. Records without both addresses aren't traffic and are
+// skipped.
+func decodeDataSet(t flowTemplate, set []byte, base flowRecord, exported time.Time, uptime uint32) []flowRecord {
+	var records []flowRecord
+	for len(set) > 0 {
+		r, n, ok := decodeFlowRecord(t, set, base, exported, uptime)
+		if !ok || n == 0 {
+			break
+		}
+		set = set[n:]
+		if r.Src.IsValid() && r.Dst.IsValid() {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// decodeFlowRecord reads one record and returns the bytes it took. ok is
+// false if the set is too short for the record.
+func decodeFlowRecord(t flowTemplate, data []byte, r flowRecord, exported time.Time, uptime uint32) (flowRecord, int, bool) {
+	off := 0
+	var totalBytes, totalPackets uint64
+	for _, f := range t.fields {
+		length := int(f.length)
+		if f.length == ipfixVariableLength {
+			if off >= len(data) {
+				return r, 0, false
+			}
+			length = int(data[off])
+			off++
+			if length == 255 {
+				if off+2 > len(data) {
+					return r, 0, false
+				}
+				length = int(binary.BigEndian.Uint16(data[off:]))
+				off += 2
+			}
+		}
+		if off+length > len(data) {
+			return r, 0, false
+		}
+		v := data[off : off+length]
+		off += length
+		if f.enterprise {
+			continue
+		}
+
+		switch f.id {
+		case ieSourceIPv4Address, ieSourceIPv6Address:
+			if a, ok := netip.AddrFromSlice(v); ok {
+				r.Src = a
+			}
+		case ieDestIPv4Address, ieDestIPv6Address:
+			if a, ok := netip.AddrFromSlice(v); ok {
+				r.Dst = a
+			}
+		case ieSourceTransportPort:
+			r.SrcPort = uint16(flowUint(v))
+		case ieDestTransportPort:
+			r.DstPort = uint16(flowUint(v))
+		case ieProtocolIdentifier:
+			r.Protocol = uint8(flowUint(v))
+		case ieTCPControlBits:
+			r.TCPFlags = uint16(flowUint(v))
+		case ieOctetDeltaCount:
+			r.Bytes = flowUint(v)
+		case iePacketDeltaCount:
+			r.Packets = flowUint(v)
+		case ieOctetTotalCount:
+			totalBytes = flowUint(v)
+		case iePacketTotalCount:
+			totalPackets = flowUint(v)
+		case ieFlowStartSysUpTime:
+			if r.Version == 9 {
+				r.Start = uptimeTime(exported, uptime, uint32(flowUint(v)))
+			}
+		case ieFlowEndSysUpTime:
+			if r.Version == 9 {
+				r.End = uptimeTime(exported, uptime, uint32(flowUint(v)))
+			}
+		case ieFlowStartSeconds:
+			r.Start = time.Unix(int64(flowUint(v)), 0)
+		case ieFlowEndSeconds:
+			r.End = time.Unix(int64(flowUint(v)), 0)
+		case ieFlowStartMillis:
+			r.Start = time.UnixMilli(int64(flowUint(v)))
+		case ieFlowEndMillis:
+			r.End = time.UnixMilli(int64(flowUint(v)))
+		}
+	}
+	// Exporters that only report running totals still get a volume.
+	if r.Bytes == 0 {
+		r.Bytes = totalBytes
+	}
+	if r.Packets == 0 {
+		r.Packets = totalPackets
+	}
+	if r.End.IsZero() {
+		r.End = exported
+	}
+	return r, off, true
+}
+
+// flowUint reads a big-endian unsigned integer of up to eight bytes; IPFIX
+// exporters may shorten fields (reduced-size encoding).
+func flowUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"maps"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	be           = binary.BigEndian
+	flowExporter = netip.MustParseAddr("192.0.2.1")
+	flowExported = time.Unix(1700000000, 0)
+)
+
+// v5Packet builds a NetFlow v5 packet exported at flowExported with the
+// router up 100s. Each record is src, dst, ports, protocol, and flags.
+func v5Packet(records ...flowRecord) []byte {
+	b := be.AppendUint16(nil, 5)
+	b = be.AppendUint16(b, uint16(len(records)))
+	b = be.AppendUint32(b, 100000) // uptime
+	b = be.AppendUint32(b, uint32(flowExported.Unix()))
+	b = be.AppendUint32(b, 0)
+	b = append(b, make([]byte, 8)...) // sequence, engine, sampling
+	for _, r := range records {
+		b = append(b, r.Src.AsSlice()...)
+		b = append(b, r.Dst.AsSlice()...)
+		b = append(b, make([]byte, 8)...) // next hop, interfaces
+		b = be.AppendUint32(b, uint32(r.Packets))
+		b = be.AppendUint32(b, uint32(r.Bytes))
+		b = be.AppendUint32(b, 90000) // first, 10s before export
+		b = be.AppendUint32(b, 99000) // last, 1s before export
+		b = be.AppendUint16(b, r.SrcPort)
+		b = be.AppendUint16(b, r.DstPort)
+		b = append(b, 0, byte(r.TCPFlags), r.Protocol, 0)
+		b = append(b, make([]byte, 8)...) // AS numbers, masks
+	}
+	return b
+}
+
+// templatedPacket builds a v9 (domain 7, up 100s) or IPFIX (domain 7)
+// packet exported at flowExported.
+func templatedPacket(version uint16, sets ...[]byte) []byte {
+	var body []byte
+	for _, s := range sets {
+		body = append(body, s...)
+	}
+	b := be.AppendUint16(nil, version)
+	if version == 9 {
+		b = be.AppendUint16(b, uint16(len(sets)))
+		b = be.AppendUint32(b, 100000)
+		b = be.AppendUint32(b, uint32(flowExported.Unix()))
+		b = be.AppendUint32(b, 1) // sequence
+	} else {
+		b = be.AppendUint16(b, uint16(ipfixHeaderLength+len(body)))
+		b = be.AppendUint32(b, uint32(flowExported.Unix()))
+		b = be.AppendUint32(b, 1)
+	}
+	b = be.AppendUint32(b, 7)
+	return append(b, body...)
+}
+
+func flowSet(id uint16, body []byte) []byte {
+	b := be.AppendUint16(nil, id)
+	b = be.AppendUint16(b, uint16(4+len(body)))
+	return append(b, body...)
+}
+
+// flowTemplateRecord is a template definition; each field is an element ID
+// and length.
+func flowTemplateRecord(id uint16, fields ...[2]uint16) []byte {
+	b := be.AppendUint16(nil, id)
+	b = be.AppendUint16(b, uint16(len(fields)))
+	for _, f := range fields {
+		b = be.AppendUint16(b, f[0])
+		b = be.AppendUint16(b, f[1])
+	}
+	return b
+}
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func TestNetFlowDecode(t *testing.T) {
+	src, dst := netip.MustParseAddr("10.0.0.5"), netip.MustParseAddr("10.0.0.9")
+	v4Template := flowTemplateRecord(256,
+		[2]uint16{ieSourceIPv4Address, 4}, [2]uint16{ieDestIPv4Address, 4},
+		[2]uint16{ieSourceTransportPort, 2}, [2]uint16{ieDestTransportPort, 2},
+		[2]uint16{ieProtocolIdentifier, 1}, [2]uint16{ieOctetDeltaCount, 4},
+		[2]uint16{iePacketDeltaCount, 4}, [2]uint16{ieFlowStartSysUpTime, 4},
+		[2]uint16{ieFlowEndSysUpTime, 4},
+	)
+	v4Record := concat(src.AsSlice(), dst.AsSlice(),
+		be.AppendUint16(nil, 51000), be.AppendUint16(nil, 443), []byte{6},
+		be.AppendUint32(nil, 1500), be.AppendUint32(nil, 3),
+		be.AppendUint32(nil, 90000), be.AppendUint32(nil, 99000),
+	)
+	v6src, v6dst := netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2")
+
+	tests := []struct {
+		name        string
+		packets     [][]byte // decoded in order; records are from the last
+		want        []flowRecord
+		wantMissing int
+		wantErr     error
+	}{
+		{
+			name: "v5",
+			packets: [][]byte{v5Packet(flowRecord{
+				Src: src, Dst: dst, SrcPort: 51000, DstPort: 22, Protocol: 6, TCPFlags: 0x12, Bytes: 840, Packets: 6,
+			})},
+			want: []flowRecord{{
+				Version: 5, Exporter: flowExporter, Src: src, Dst: dst, SrcPort: 51000, DstPort: 22,
+				Protocol: 6, TCPFlags: 0x12, Bytes: 840, Packets: 6,
+				Start: flowExported.Add(-10 * time.Second), End: flowExported.Add(-time.Second),
+			}},
+		},
+		{
+			name:    "v9 template and data in one packet",
+			packets: [][]byte{templatedPacket(9, flowSet(netflowV9TemplateSetID, v4Template), flowSet(256, concat(v4Record, []byte{0, 0})))},
+			want: []flowRecord{{
+				Version: 9, Exporter: flowExporter, Src: src, Dst: dst, SrcPort: 51000, DstPort: 443,
+				Protocol: 6, Bytes: 1500, Packets: 3,
+				Start: flowExported.Add(-10 * time.Second), End: flowExported.Add(-time.Second),
+			}},
+		},
+		{
+			name: "v9 data after its template's packet",
+			packets: [][]byte{
+				templatedPacket(9, flowSet(netflowV9TemplateSetID, v4Template)),
+				templatedPacket(9, flowSet(256, concat(v4Record, v4Record))),
+			},
+			want: []flowRecord{
+				{Version: 9, Exporter: flowExporter, Src: src, Dst: dst, SrcPort: 51000, DstPort: 443, Protocol: 6, Bytes: 1500, Packets: 3, Start: flowExported.Add(-10 * time.Second), End: flowExported.Add(-time.Second)},
+				{Version: 9, Exporter: flowExporter, Src: src, Dst: dst, SrcPort: 51000, DstPort: 443, Protocol: 6, Bytes: 1500, Packets: 3, Start: flowExported.Add(-10 * time.Second), End: flowExported.Add(-time.Second)},
+			},
+		},
+		{
+			name:        "v9 data before its template",
+			packets:     [][]byte{templatedPacket(9, flowSet(256, v4Record))},
+			wantMissing: 1,
+		},
+		{
+			name: "ipfix ipv6 with totals, reduced-size, and variable-length fields",
+			packets: [][]byte{templatedPacket(10,
+				flowSet(ipfixTemplateSetID, flowTemplateRecord(300,
+					[2]uint16{ieSourceIPv6Address, 16}, [2]uint16{ieDestIPv6Address, 16},
+					[2]uint16{ieProtocolIdentifier, 1}, [2]uint16{ieOctetTotalCount, 2},
+					[2]uint16{iePacketTotalCount, 1}, [2]uint16{82, ipfixVariableLength}, // interfaceName
+					[2]uint16{ieFlowStartMillis, 8}, [2]uint16{ieFlowEndMillis, 8},
+				)),
+				flowSet(300, concat(v6src.AsSlice(), v6dst.AsSlice(), []byte{17},
+					be.AppendUint16(nil, 4096), []byte{8}, []byte{4}, []byte("eth0"),
+					be.AppendUint64(nil, 1699999990000), be.AppendUint64(nil, 1699999995000),
+				)),
+			)},
+			want: []flowRecord{{
+				Version: 10, Exporter: flowExporter, Src: v6src, Dst: v6dst, Protocol: 17, Bytes: 4096, Packets: 8,
+				Start: time.UnixMilli(1699999990000), End: time.UnixMilli(1699999995000),
+			}},
+		},
+		{
+			name: "ipfix withdrawn template",
+			packets: [][]byte{
+				templatedPacket(10, flowSet(ipfixTemplateSetID, v4Template)),
+				templatedPacket(10, flowSet(ipfixTemplateSetID, flowTemplateRecord(256))),
+				templatedPacket(10, flowSet(256, v4Record)),
+			},
+			wantMissing: 1,
+		},
+		{
+			name:    "truncated v5",
+			packets: [][]byte{v5Packet(flowRecord{Src: src, Dst: dst})[:60]},
+			wantErr: errShortFlowPacket,
+		},
+		{
+			name:    "set longer than the packet",
+			packets: [][]byte{templatedPacket(9, flowSet(netflowV9TemplateSetID, v4Template)[:10])},
+			wantErr: errShortFlowPacket,
+		},
+		{
+			name:    "unsupported version",
+			packets: [][]byte{{0, 7, 0, 0}},
+			wantErr: errors.New("unsupported flow export version 7"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewNetFlowCollector(NetFlowConfig{TemplateTTL: "30m"}.WithDefaults(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var (
+				records []flowRecord
+				missing int
+			)
+			for _, p := range tt.packets {
+				records, missing, err = c.decode(flowExporter, p, flowExported)
+			}
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if missing != tt.wantMissing {
+				t.Errorf("missing = %d, want %d", missing, tt.wantMissing)
+			}
+			if len(records) != len(tt.want) {
+				t.Fatalf("got %d records, want %d: %+v", len(records), len(tt.want), records)
+			}
+			for i := range records {
+				got, want := records[i], tt.want[i]
+				if !got.Start.Equal(want.Start) || !got.End.Equal(want.End) {
+					t.Errorf("record %d spans %v-%v, want %v-%v", i, got.Start, got.End, want.Start, want.End)
+				}
+				got.Start, got.End, want.Start, want.End = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("record %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNetFlowTemplateExpiry(t *testing.T) {
+	c, err := NewNetFlowCollector(NetFlowConfig{TemplateTTL: "1m"}.WithDefaults(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := flowTemplateRecord(256, [2]uint16{ieSourceIPv4Address, 4}, [2]uint16{ieDestIPv4Address, 4})
+	data := concat(netip.MustParseAddr("10.0.0.1").AsSlice(), netip.MustParseAddr("10.0.0.2").AsSlice())
+	if _, _, err := c.decode(flowExporter, templatedPacket(9, flowSet(netflowV9TemplateSetID, template)), flowExported); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		after       time.Duration
+		wantRecords int
+	}{
+		{30 * time.Second, 1},
+		{2 * time.Minute, 0},
+	} {
+		records, _, err := c.decode(flowExporter, templatedPacket(9, flowSet(256, data)), flowExported.Add(tt.after))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != tt.wantRecords {
+			t.Errorf("%v after the template: %d records, want %d", tt.after, len(records), tt.wantRecords)
+		}
+	}
+}
+
+func TestFlowRecordEntry(t *testing.T) {
+	src, dst := netip.MustParseAddr("10.0.0.5"), netip.MustParseAddr("10.0.0.9")
+	tests := []struct {
+		name        string
+		record      flowRecord
+		wantMessage string
+		wantFields  map[string]string
+	}{
+		{
+			name: "tcp with flags",
+			record: flowRecord{
+				Version: 5, Exporter: flowExporter, Src: src, Dst: dst, SrcPort: 51000, DstPort: 22,
+				Protocol: 6, TCPFlags: 0x12, Bytes: 840, Packets: 6, Start: flowExported, End: flowExported.Add(1500 * time.Millisecond),
+			},
+			wantMessage: "TCP 10.0.0.5:51000 -> 10.0.0.9:22, 840 bytes in 6 packets, flags SYN,ACK",
+			wantFields: map[string]string{
+				"src": "10.0.0.5", "dst": "10.0.0.9", "spt": "51000", "dpt": "22", "proto": "TCP",
+				"bytes": "840", "packets": "6", "exporter": "192.0.2.1", "flow_version": "5",
+				"tcp_flags": "SYN,ACK", "duration_ms": "1500",
+			},
+		},
+		{
+			name:        "icmp without ports",
+			record:      flowRecord{Version: 9, Exporter: flowExporter, Src: src, Dst: dst, SrcPort: 8, Protocol: 1, Bytes: 84, Packets: 1, End: flowExported},
+			wantMessage: "ICMP 10.0.0.5 -> 10.0.0.9, 84 bytes in 1 packets",
+			wantFields: map[string]string{
+				"src": "10.0.0.5", "dst": "10.0.0.9", "proto": "ICMP",
+				"bytes": "84", "packets": "1", "exporter": "192.0.2.1", "flow_version": "9",
+			},
+		},
+		{
+			name:        "unnamed protocol over ipv6",
+			record:      flowRecord{Version: 10, Exporter: flowExporter, Src: netip.MustParseAddr("2001:db8::1"), Dst: netip.MustParseAddr("2001:db8::2"), Protocol: 89, Bytes: 64, Packets: 1, End: flowExported},
+			wantMessage: "89 2001:db8::1 -> 2001:db8::2, 64 bytes in 1 packets",
+			wantFields: map[string]string{
+				"src": "2001:db8::1", "dst": "2001:db8::2", "proto": "89",
+				"bytes": "64", "packets": "1", "exporter": "192.0.2.1", "flow_version": "10",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tt.record.entry("NetFlow")
+			if e.Source != "NetFlow" || e.Severity != SeverityInfo || e.IPAddress != tt.record.Src.String() || !e.Timestamp.Equal(tt.record.End) {
+				t.Errorf("entry = %+v", e)
+			}
+			if e.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", e.Message, tt.wantMessage)
+			}
+			if !maps.Equal(e.Fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", e.Fields, tt.wantFields)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+const (
+	// filePollInterval is how often a tailed file at its end is checked
+	// for new lines, rotation, and truncation.
+	filePollInterval = time.Second
+	// fileFingerprintSize bytes from the start of a file identify it across
+	// restarts and rotations.
+	fileFingerprintSize = 256
+)
+
+// ingestCheckpoint is a replayable input's position, stored in
+// ingest_offsets in the transaction that stores the entries read up to it.
+type ingestCheckpoint struct {
+	key        string
+	generation string // which file, or stream, position is in
+	position   int64
+}
+
+// loadCheckpoint returns key's stored checkpoint, or a zero one if it has
+// none yet.
+func loadCheckpoint(db *store.DB, key string) (ingestCheckpoint, error) {
+	cp := ingestCheckpoint{key: key}
+	err := db.QueryRow("SELECT generation, position FROM ingest_offsets WHERE source_key = ?", key).Scan(&cp.generation, &cp.position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cp, nil
+	}
+	return cp, err
+}
+
+func saveCheckpoint(tx store.Conn, cp ingestCheckpoint) error {
+	_, err := tx.Exec(`
+		INSERT INTO ingest_offsets (source_key, generation, position, updated_at)
+		VALUES (?, ?, ?, ?)
+		`+tx.Upsert("source_key")+` generation = `+tx.Excluded("generation")+`, position = `+tx.Excluded("position")+`,
+			updated_at = `+tx.Excluded("updated_at"),
+		cp.key, cp.generation, cp.position, time.Now(),
+	)
+	return err
+}
+
+// fileGeneration identifies f by a hash of its first n bytes, capped at
+// fileFingerprintSize, as "<n>:<hash>". Those bytes don't change while a
+// file is appended to, so the generation only differs for another file.
+func fileGeneration(f *os.File, n int64) (string, error) {
+	n = min(n, fileFingerprintSize)
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, 0); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return strconv.FormatInt(n, 10) + ":" + hex.EncodeToString(sum[:8]), nil
+}
+
+// resumesAt reports whether cp is a position in f: f is the file cp was
+// taken in, and hasn't been truncated below it since.
+func (cp ingestCheckpoint) resumesAt(f *os.File) bool {
+	n, _, ok := strings.Cut(cp.generation, ":")
+	size, err := strconv.ParseInt(n, 10, 64)
+	if !ok || err != nil {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil || info.Size() < cp.position {
+		return false
+	}
+	generation, err := fileGeneration(f, size)
+	return err == nil && generation == cp.generation
+}
+
+// tailFile follows the pipeline's file from its checkpoint, moving on to
+// the new file when it's rotated and starting over when it's truncated.
+// Each line is an entry carrying the position after it, so a restart
+// resumes after the last stored line.
+func (p *Pipeline) tailFile() error {
+	key := "file:" + p.cfg.Name
+	cp, err := loadCheckpoint(p.in.DB(), key)
+	if err != nil {
+		return fmt.Errorf("load checkpoint: %w", err)
+	}
+	for {
+		f, err := os.Open(p.cfg.Input.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			time.Sleep(filePollInterval)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		start := int64(0)
+		if cp.resumesAt(f) {
+			start = cp.position
+		} else if cp.generation != "" {
+			slog.Warn("Tailed file changed since its checkpoint, reading from the start", "pipeline", p.cfg.Name, "path", p.cfg.Input.Path)
+		}
+		err = p.followFile(f, key, start)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		cp = ingestCheckpoint{}
+	}
+}
+
+// followFile queues f's complete lines from start until f is rotated away
+// or truncated. A line still being written waits for its newline.
+func (p *Pipeline) followFile(f *os.File, key string, start int64) error {
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	generation, err := fileGeneration(f, start)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	pos, partial, rotated := start, "", false
+	for {
+		line, err := r.ReadString('\n')
+		partial += line
+		if err == nil {
+			pos += int64(len(partial))
+			msg := strings.TrimRight(partial, "\r\n")
+			partial = ""
+			if !strings.HasPrefix(generation, strconv.Itoa(fileFingerprintSize)+":") {
+				if generation, err = fileGeneration(f, pos); err != nil {
+					return err
+				}
+			}
+			cp := &ingestCheckpoint{key: key, generation: generation, position: pos}
+			if strings.TrimSpace(msg) == "" {
+				// Nothing to store, but the position still moves past it.
+				p.enqueue(pipelineItem{checkpoint: cp})
+				continue
+			}
+			p.enqueue(pipelineItem{entry: LogEntry{Message: msg, TenantID: p.cfg.Tenant}, checkpoint: cp})
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+		if rotated {
+			// The old file is drained; lines written after the rotation
+			// belong to the new one.
+			return nil
+		}
+		time.Sleep(filePollInterval)
+		current, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if current.Size() < pos {
+			slog.Info("Tailed file truncated, reading from the start", "pipeline", p.cfg.Name, "path", p.cfg.Input.Path)
+			return nil
+		}
+		if info, err := os.Stat(p.cfg.Input.Path); err == nil && !os.SameFile(info, current) {
+			slog.Info("Tailed file rotated", "pipeline", p.cfg.Name, "path", p.cfg.Input.Path)
+			rotated = true
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/pipeline"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpIPAttributes are checked, in order, for the entry's IP address. Log
+// record attributes take precedence over resource attributes.
+var otlpIPAttributes = []string{"client.address", "source.address", "net.peer.ip", "net.sock.peer.addr", "host.ip"}
+
+// otlpReceiver accepts OTLP/logs exports over HTTP and gRPC and feeds each
+// record through the Ingestor, so OpenTelemetry SDKs can ship logs directly.
+type otlpReceiver struct {
+	collogspb.UnimplementedLogsServiceServer
+	in *pipeline.Ingestor
+}
+
+// Export implements the OTLP gRPC LogsService.
+func (o *otlpReceiver) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	origin := grpcOrigin(ctx)
+	origin.Input = "otlp"
+	resp, err := o.export(tenantFromContext(ctx), origin, req)
+	if errors.Is(err, pipeline.ErrRateLimited) {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if errors.Is(err, pipeline.ErrSourcePaused) {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, "failed to store logs")
+	}
+	return resp, nil
+}
+
+// export ingests every record in req. Records that fail validation or are
+// shed by the circuit breaker are reported as a partial success; a rate limit
+// or storage failure aborts the export so the client retries it.
+func (o *otlpReceiver) export(tenant string, origin pipeline.Origin, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	var (
+		rejected int64
+		firstErr string
+	)
+	for _, rl := range req.GetResourceLogs() {
+		resource := rl.GetResource().GetAttributes()
+		for _, sl := range rl.GetScopeLogs() {
+			for _, rec := range sl.GetLogRecords() {
+				entry := logEntryFromOTLP(resource, sl.GetScope(), rec)
+				entry.TenantID = tenant
+				if _, err := o.in.IngestFrom(origin, entry); err != nil && !errors.Is(err, pipeline.ErrQueuedForRetry) {
+					if errors.Is(err, pipeline.ErrRateLimited) || errors.Is(err, pipeline.ErrSourcePaused) {
+						return nil, err
+					}
+					if !errors.Is(err, pipeline.ErrInvalidEntry) && !errors.Is(err, pipeline.ErrShed) {
+						slog.Error("Failed to ingest OTLP log", "err", err)
+						return nil, err
+					}
+					rejected++
+					if firstErr == "" {
+						firstErr = err.Error()
+					}
+				}
+			}
+		}
+	}
+
+	resp := &collogspb.ExportLogsServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &collogspb.ExportLogsPartialSuccess{RejectedLogRecords: rejected, ErrorMessage: firstErr}
+	}
+	return resp, nil
+}
+
+// logEntryFromOTLP maps an OTLP log record onto a LogEntry. Resource
+// attributes become labels and record attributes become fields; the source
+// is the service.name resource attribute, falling back to the scope name.
+func logEntryFromOTLP(resource []*commonpb.KeyValue, scope *commonpb.InstrumentationScope, rec *logspb.LogRecord) LogEntry {
+	entry := LogEntry{
+		Severity: otlpSeverity(rec.GetSeverityNumber(), rec.GetSeverityText()),
+		Message:  otlpValueString(rec.GetBody()),
+		Labels:   otlpAttributes(resource),
+		Fields:   otlpAttributes(rec.GetAttributes()),
+	}
+
+	switch {
+	case rec.GetTimeUnixNano() != 0:
+		entry.Timestamp = time.Unix(0, int64(rec.GetTimeUnixNano()))
+	case rec.GetObservedTimeUnixNano() != 0:
+		entry.Timestamp = time.Unix(0, int64(rec.GetObservedTimeUnixNano()))
+	}
+
+	entry.Source = entry.Labels["service.name"]
+	if entry.Source == "" {
+		entry.Source = scope.GetName()
+	}
+	if entry.Source == "" {
+		entry.Source = "OTLP"
+	}
+
+	for _, key := range otlpIPAttributes {
+		if ip := entry.Fields[key]; ip != "" {
+			entry.IPAddress = ip
+			break
+		}
+		if ip := entry.Labels[key]; ip != "" {
+			entry.IPAddress = ip
+			break
+		}
+	}
+
+	if len(rec.GetTraceId()) > 0 || len(rec.GetSpanId()) > 0 {
+		if entry.Fields == nil {
+			entry.Fields = map[string]string{}
+		}
+		if id := rec.GetTraceId(); len(id) > 0 {
+			entry.Fields["trace_id"] = hex.EncodeToString(id)
+		}
+		if id := rec.GetSpanId(); len(id) > 0 {
+			entry.Fields["span_id"] = hex.EncodeToString(id)
+		}
+	}
+	return entry
+}
+
+// otlpSeverity maps OTLP severity numbers (1-24, in bands of four) onto the
+// canonical levels, falling back to the severity text when unset.
+func otlpSeverity(n logspb.SeverityNumber, text string) Severity {
+	switch {
+	case n >= logspb.SeverityNumber_SEVERITY_NUMBER_FATAL:
+		return SeverityCritical
+	case n >= logspb.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return SeverityAlert
+	case n >= logspb.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return SeverityWarning
+	case n > logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED:
+		return SeverityInfo
+	}
+	return NormalizeSeverity(text)
+}
+
+// otlpAttributes flattens attributes into string values. Attributes whose
+// keys can't be stored are dropped, as are any beyond pipeline.MaxAttributes.
+func otlpAttributes(kvs []*commonpb.KeyValue) map[string]string {
+	if len(kvs) == 0 {
+		return nil
+	}
+	attrs := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		if len(attrs) == pipeline.MaxAttributes {
+			break
+		}
+		if !pipeline.AttributeKeyPattern.MatchString(kv.GetKey()) {
+			continue
+		}
+		v := otlpValueString(kv.GetValue())
+		if len(v) > pipeline.MaxAttributeValue {
+			v = v[:pipeline.MaxAttributeValue]
+		}
+		attrs[kv.GetKey()] = v
+	}
+	return attrs
+}
+
+// otlpValueString renders an AnyValue as text; arrays and maps become JSON.
+func otlpValueString(v *commonpb.AnyValue) string {
+	switch x := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return x.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(x.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(x.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(x.DoubleValue, 'g', -1, 64)
+	case *commonpb.AnyValue_BytesValue:
+		return hex.EncodeToString(x.BytesValue)
+	case *commonpb.AnyValue_ArrayValue, *commonpb.AnyValue_KvlistValue:
+		data, _ := json.Marshal(otlpValue(v))
+		return string(data)
+	}
+	return ""
+}
+
+func otlpValue(v *commonpb.AnyValue) any {
+	switch x := v.GetValue().(type) {
+	case *commonpb.AnyValue_ArrayValue:
+		out := make([]any, 0, len(x.ArrayValue.GetValues()))
+		for _, e := range x.ArrayValue.GetValues() {
+			out = append(out, otlpValue(e))
+		}
+		return out
+	case *commonpb.AnyValue_KvlistValue:
+		out := make(map[string]any, len(x.KvlistValue.GetValues()))
+		for _, kv := range x.KvlistValue.GetValues() {
+			out[kv.GetKey()] = otlpValue(kv.GetValue())
+		}
+		return out
+	}
+	return otlpValueString(v)
+}
+
+// httpHandler serves POST /v1/logs (OTLP/HTTP) in protobuf or JSON encoding,
+// optionally gzip-compressed, replying in the request's encoding.
+func (o *otlpReceiver) httpHandler(w http.ResponseWriter, r *http.Request) {
+	data, ok := readIngestBody(w, r)
+	if !ok {
+		return
+	}
+
+	isJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+	req := &collogspb.ExportLogsServiceRequest{}
+	var err error
+	if isJSON {
+		err = protojson.Unmarshal(data, req)
+	} else {
+		err = proto.Unmarshal(data, req)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid OTLP payload: %v", err))
+		return
+	}
+
+	origin := requestOrigin(r)
+	origin.Input = "otlp"
+	resp, err := o.export(tenantFromRequest(r), origin, req)
+	if errors.Is(err, pipeline.ErrRateLimited) {
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	if errors.Is(err, pipeline.ErrSourcePaused) {
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, "failed to store logs")
+		return
+	}
+	if isJSON {
+		data, err = protojson.Marshal(resp)
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		data, err = proto.Marshal(resp)
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
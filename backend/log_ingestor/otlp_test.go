@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOTLPHTTPBodyLimit(t *testing.T) {
+	gzipped := func(n int) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(make([]byte, n))
+		zw.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+		want     int
+	}{
+		{name: "gzip bomb", body: gzipped(maxIngestBody + 1), encoding: "gzip", want: http.StatusRequestEntityTooLarge},
+		{name: "plain over the limit", body: make([]byte, maxIngestBody+1), want: http.StatusRequestEntityTooLarge},
+		{name: "invalid gzip", body: []byte("not gzip"), encoding: "gzip", want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.encoding != "" && len(tt.body) >= maxIngestBody {
+				t.Fatalf("compressed body is %d bytes, want it under the limit", len(tt.body))
+			}
+			r := httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/x-protobuf")
+			if tt.encoding != "" {
+				r.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			(&otlpReceiver{}).httpHandler(w, r)
+			if w.Code != tt.want {
+				t.Errorf("POST /v1/logs = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
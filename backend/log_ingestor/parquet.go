@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+)
+
+// A minimal Parquet writer for log exports: flat, REQUIRED columns, PLAIN
+// encoding, optional GZIP pages, one data page per column chunk. Rows are
+// buffered into row groups, so memory is bounded by the row group size
+// rather than the export. See https://github.com/apache/parquet-format.
+
+// Parquet physical and converted types, encodings, and page types used here.
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetRequired = 0
+	parquetPlain    = 0
+	parquetRLE      = 3
+	parquetDataPage = 0
+
+	parquetUncompressed = 0
+	parquetGzip         = 2
+)
+
+// parquetColumn maps one LogEntry attribute onto a column.
+type parquetColumn struct {
+	name      string
+	physical  int32
+	converted int32
+	int64Of   func(LogEntry) int64
+	bytesOf   func(LogEntry) []byte
+}
+
+func jsonColumn(m map[string]string) []byte {
+	if len(m) == 0 {
+		return []byte("{}")
+	}
+	data, _ := json.Marshal(m)
+	return data
+}
+
+var logParquetColumns = []parquetColumn{
+	{name: "id", physical: parquetInt64, converted: -1, int64Of: func(e LogEntry) int64 { return e.ID }},
+	{name: "tenant_id", physical: parquetByteArray, converted: parquetUTF8, bytesOf: func(e LogEntry) []byte { return []byte(e.TenantID) }},
+	{name: "timestamp", physical: parquetInt64, converted: parquetTimestampMillis, int64Of: func(e LogEntry) int64 { return e.Timestamp.UnixMilli() }},
+	{name: "source", physical: parquetByteArray, converted: parquetUTF8, bytesOf: func(e LogEntry) []byte { return []byte(e.Source) }},
+	{name: "severity", physical: parquetByteArray, converted: parquetUTF8, bytesOf: func(e LogEntry) []byte { return []byte(e.Severity.String()) }},
+	{name: "message", physical: parquetByteArray, converted: parquetUTF8, bytesOf: func(e LogEntry) []byte { return []byte(e.Message) }},
+	{name: "ip_address", physical: parquetByteArray, converted: parquetUTF8, bytesOf: func(e LogEntry) []byte { return []byte(e.IPAddress) }},
+	{name: "fields", physical: parquetByteArray, converted: parquetUTF8, bytesOf: func(e LogEntry) []byte { return jsonColumn(e.Fields) }},
+	{name: "labels", physical: parquetByteArray, converted: parquetUTF8, bytesOf: func(e LogEntry) []byte { return jsonColumn(e.Labels) }},
+	{name: "risk_score", physical: parquetInt64, converted: -1, int64Of: func(e LogEntry) int64 { return int64(e.RiskScore) }},
+}
+
+// parquetChunk is the metadata of one written column chunk.
+type parquetChunk struct {
+	column           parquetColumn
+	offset, size     int64
+	numValues        int64
+	uncompressedSize int64
+}
+
+type parquetRowGroup struct {
+	chunks  []parquetChunk
+	numRows int64
+	size    int64
+}
+
+// parquetWriter streams LogEntries into a Parquet file.
+type parquetWriter struct {
+	w         io.Writer
+	offset    int64
+	groupSize int
+	pending   []LogEntry
+	groups    []parquetRowGroup
+	numRows   int64
+	gzip      bool // compress pages; set before the first Write
+}
+
+func newParquetWriter(w io.Writer, groupSize int) (*parquetWriter, error) {
+	p := &parquetWriter{w: w, groupSize: groupSize}
+	return p, p.write([]byte("PAR1"))
+}
+
+func (p *parquetWriter) write(b []byte) error {
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	return err
+}
+
+// Write buffers e, flushing a row group when it is full.
+func (p *parquetWriter) Write(e LogEntry) error {
+	p.pending = append(p.pending, e)
+	if len(p.pending) >= p.groupSize {
+		return p.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered rows as a row group.
+func (p *parquetWriter) flush() error {
+	if len(p.pending) == 0 {
+		return nil
+	}
+	group := parquetRowGroup{numRows: int64(len(p.pending))}
+	var page, compressed bytes.Buffer
+	for _, col := range logParquetColumns {
+		page.Reset()
+		for _, e := range p.pending {
+			if col.int64Of != nil {
+				binary.Write(&page, binary.LittleEndian, col.int64Of(e))
+			} else {
+				v := col.bytesOf(e)
+				binary.Write(&page, binary.LittleEndian, uint32(len(v)))
+				page.Write(v)
+			}
+		}
+
+		body := page.Bytes()
+		if p.gzip {
+			compressed.Reset()
+			zw := gzip.NewWriter(&compressed)
+			zw.Write(body)
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			body = compressed.Bytes()
+		}
+
+		header := newThriftCompact()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(page.Len()))
+		header.i32(3, int32(len(body)))
+		header.structBegin(5) // DataPageHeader
+		header.i32(1, int32(len(p.pending)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.structEnd()
+		header.stop()
+
+		chunk := parquetChunk{
+			column:    col,
+			offset:    p.offset,
+			numValues: int64(len(p.pending)),
+			size:      int64(header.buf.Len() + len(body)),
+		}
+		chunk.uncompressedSize = int64(header.buf.Len() + page.Len())
+		if err := p.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := p.write(body); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.size
+	}
+	p.groups = append(p.groups, group)
+	p.numRows += group.numRows
+	p.pending = p.pending[:0]
+	return nil
+}
+
+func (p *parquetWriter) codec() int32 {
+	if p.gzip {
+		return parquetGzip
+	}
+	return parquetUncompressed
+}
+
+// Close flushes the last row group and writes the footer.
+func (p *parquetWriter) Close() error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+
+	meta := newThriftCompact()
+	meta.i32(1, 1) // version
+	meta.listBegin(2, thriftStruct, len(logParquetColumns)+1)
+	meta.elemBegin()
+	meta.binary(4, []byte("log"))
+	meta.i32(5, int32(len(logParquetColumns)))
+	meta.elemEnd()
+	for _, col := range logParquetColumns {
+		meta.elemBegin()
+		meta.i32(1, col.physical)
+		meta.i32(3, parquetRequired)
+		meta.binary(4, []byte(col.name))
+		if col.converted >= 0 {
+			meta.i32(6, col.converted)
+		}
+		meta.elemEnd()
+	}
+	meta.i64(3, p.numRows)
+	meta.listBegin(4, thriftStruct, len(p.groups))
+	for _, g := range p.groups {
+		meta.elemBegin()
+		meta.listBegin(1, thriftStruct, len(g.chunks))
+		for _, c := range g.chunks {
+			meta.elemBegin()
+			meta.i64(2, c.offset) // file_offset
+			meta.structBegin(3)   // ColumnMetaData
+			meta.i32(1, c.column.physical)
+			meta.listBegin(2, thriftI32, 1)
+			meta.varint(zigzag(parquetPlain))
+			meta.listBegin(3, thriftBinary, 1)
+			meta.varint(uint64(len(c.column.name)))
+			meta.buf.WriteString(c.column.name)
+			meta.i32(4, p.codec())
+			meta.i64(5, c.numValues)
+			meta.i64(6, c.uncompressedSize)
+			meta.i64(7, c.size)
+			meta.i64(9, c.offset) // data_page_offset
+			meta.structEnd()
+			meta.elemEnd()
+		}
+		meta.i64(2, g.size)
+		meta.i64(3, g.numRows)
+		meta.elemEnd()
+	}
+	meta.binary(6, []byte("1L0Gx log_ingestor"))
+	meta.stop()
+
+	if err := p.write(meta.buf.Bytes()); err != nil {
+		return err
+	}
+	var tail [8]byte
+	binary.LittleEndian.PutUint32(tail[:4], uint32(meta.buf.Len()))
+	copy(tail[4:], "PAR1")
+	return p.write(tail[:])
+}
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftCompact encodes the Thrift compact protocol, which Parquet uses for
+// page headers and file metadata.
+type thriftCompact struct {
+	buf  bytes.Buffer
+	last []int16 // last field id of each open struct, outermost first
+}
+
+func newThriftCompact() *thriftCompact {
+	return &thriftCompact{last: []int16{0}}
+}
+
+func zigzag(v int64) uint64 { return uint64((v << 1) ^ (v >> 63)) }
+
+func (t *thriftCompact) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (t *thriftCompact) field(id int16, typ byte) {
+	top := &t.last[len(t.last)-1]
+	last := *top
+	*top = id
+	if delta := id - last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+		return
+	}
+	t.buf.WriteByte(typ)
+	t.varint(zigzag(int64(id)))
+}
+
+func (t *thriftCompact) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftCompact) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftCompact) binary(id int16, v []byte) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(v)))
+	t.buf.Write(v)
+}
+
+func (t *thriftCompact) listBegin(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elem)
+	t.varint(uint64(n))
+}
+
+func (t *thriftCompact) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.elemBegin()
+}
+
+func (t *thriftCompact) structEnd() { t.elemEnd() }
+
+// elemBegin starts a struct inside a list, which has no field header.
+func (t *thriftCompact) elemBegin() { t.last = append(t.last, 0) }
+
+func (t *thriftCompact) elemEnd() {
+	t.stop()
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftCompact) stop() { t.buf.WriteByte(0) }
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parsers for common firewall/IDS export formats. Both extract header values
+// and extension attributes into LogEntry.Fields.
+
+var errNotStructured = errors.New("not a CEF or LEEF message")
+
+// parserVersions are the registered CEF/LEEF parser implementations. A new
+// version is added here, validated on live traffic as the canary, and then
+// promoted by making it the stable version in config.
+var parserVersions = map[string]func(string) (LogEntry, error){
+	"v1": parseStructuredMessage,
+}
+
+// structuredParser is the stable parser version used by the pipeline.
+var structuredParser = parserVersions["v1"]
+
+// parseStructuredMessage detects a CEF or LEEF payload anywhere in msg (so a
+// leading syslog header is tolerated) and parses it.
+func parseStructuredMessage(msg string) (LogEntry, error) {
+	if i := strings.Index(msg, "CEF:"); i >= 0 {
+		return parseCEF(msg[i:])
+	}
+	if i := strings.Index(msg, "LEEF:"); i >= 0 {
+		return parseLEEF(msg[i:])
+	}
+	return LogEntry{}, errNotStructured
+}
+
+// applyStructuredMessage replaces entry's message with the parsed CEF/LEEF
+// content when present, keeping any values the parser could not determine.
+func applyStructuredMessage(entry *LogEntry) error {
+	parsed, err := structuredParser(entry.Message)
+	if err == errNotStructured {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if parsed.Source != "" {
+		entry.Source = parsed.Source
+	}
+	if !parsed.Timestamp.IsZero() {
+		entry.Timestamp = parsed.Timestamp
+	}
+	if parsed.IPAddress != "" {
+		entry.IPAddress = parsed.IPAddress
+	}
+	entry.Severity = parsed.Severity
+	entry.Message = parsed.Message
+	if entry.Fields == nil {
+		entry.Fields = map[string]string{}
+	}
+	for k, v := range parsed.Fields {
+		entry.Fields[k] = v
+	}
+	return nil
+}
+
+// parseCEF parses an ArcSight Common Event Format line:
+//
+//	CEF:Version|Vendor|Product|DeviceVersion|SignatureID|Name|Severity|Extension
+func parseCEF(line string) (LogEntry, error) {
+	if !strings.HasPrefix(line, "CEF:") {
+		return LogEntry{}, fmt.Errorf("cef: missing CEF: prefix")
+	}
+	header, ext, err := splitHeader(line[len("CEF:"):], 7)
+	if err != nil {
+		return LogEntry{}, fmt.Errorf("cef: %v", err)
+	}
+
+	fields := parseCEFExtension(ext)
+	fields["cef_version"] = header[0]
+	fields["device_vendor"] = header[1]
+	fields["device_product"] = header[2]
+	fields["device_version"] = header[3]
+	fields["signature_id"] = header[4]
+
+	entry := LogEntry{
+		Source:    header[2],
+		Message:   header[5],
+		Severity:  parseVendorSeverity(header[6]),
+		IPAddress: fields["src"],
+		Fields:    fields,
+	}
+	if msg := fields["msg"]; msg != "" {
+		entry.Message = header[5] + ": " + msg
+	}
+	if rt, ok := fields["rt"]; ok {
+		entry.Timestamp = parseVendorTime(rt)
+	}
+	return entry, nil
+}
+
+// parseLEEF parses an IBM QRadar Log Event Extended Format line. LEEF 1.0
+// attributes are tab-delimited; LEEF 2.0 declares its delimiter in the header.
+//
+//	LEEF:1.0|Vendor|Product|Version|EventID|attrs
+//	LEEF:2.0|Vendor|Product|Version|EventID|Delimiter|attrs
+func parseLEEF(line string) (LogEntry, error) {
+	if !strings.HasPrefix(line, "LEEF:") {
+		return LogEntry{}, fmt.Errorf("leef: missing LEEF: prefix")
+	}
+	body := line[len("LEEF:"):]
+	header, rest, err := splitHeader(body, 5)
+	if err != nil {
+		return LogEntry{}, fmt.Errorf("leef: %v", err)
+	}
+
+	delim := "\t"
+	if strings.HasPrefix(header[0], "2") {
+		parts := strings.SplitN(rest, "|", 2)
+		if len(parts) == 2 {
+			if d := leefDelimiter(parts[0]); d != "" {
+				delim = d
+			}
+			rest = parts[1]
+		}
+	}
+
+	fields := map[string]string{}
+	for _, pair := range strings.Split(rest, delim) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			continue
+		}
+		fields[strings.TrimSpace(k)] = v
+	}
+	fields["leef_version"] = header[0]
+	fields["device_vendor"] = header[1]
+	fields["device_product"] = header[2]
+	fields["device_version"] = header[3]
+	fields["event_id"] = header[4]
+
+	entry := LogEntry{
+		Source:    header[2],
+		Message:   header[4],
+		Severity:  SeverityInfo,
+		IPAddress: fields["src"],
+		Fields:    fields,
+	}
+	if cat := fields["cat"]; cat != "" {
+		entry.Message = cat + ": " + header[4]
+	}
+	if sev, ok := fields["sev"]; ok {
+		entry.Severity = parseVendorSeverity(sev)
+	}
+	if t, ok := fields["devTime"]; ok {
+		entry.Timestamp = parseVendorTime(t)
+	}
+	return entry, nil
+}
+
+// splitHeader splits n pipe-delimited header fields (honouring \| and \\
+// escapes) and returns them along with the unparsed remainder.
+func splitHeader(s string, n int) ([]string, string, error) {
+	var (
+		fields []string
+		cur    strings.Builder
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) && (s[i+1] == '|' || s[i+1] == '\\') {
+			cur.WriteByte(s[i+1])
+			i++
+			continue
+		}
+		if c == '|' {
+			fields = append(fields, cur.String())
+			cur.Reset()
+			if len(fields) == n {
+				return fields, s[i+1:], nil
+			}
+			continue
+		}
+		cur.WriteByte(c)
+	}
+	return nil, "", fmt.Errorf("expected %d header fields, got %d", n, len(fields))
+}
+
+// parseCEFExtension parses space-separated key=value pairs whose values may
+// themselves contain spaces; a new pair starts at the next unescaped "key=".
+func parseCEFExtension(ext string) map[string]string {
+	fields := map[string]string{}
+	ext = strings.TrimSpace(ext)
+
+	var keys, values []string
+	start := 0 // start of the current key
+	for start < len(ext) {
+		eq := indexUnescaped(ext[start:], '=')
+		if eq < 0 {
+			break
+		}
+		key := ext[start : start+eq]
+		valStart := start + eq + 1
+
+		// The value runs until the last space before the next unescaped "=".
+		end := len(ext)
+		if next := indexUnescaped(ext[valStart:], '='); next >= 0 {
+			if sp := strings.LastIndexByte(ext[valStart:valStart+next], ' '); sp >= 0 {
+				end = valStart + sp
+			}
+		}
+		keys = append(keys, strings.TrimSpace(key))
+		values = append(values, ext[valStart:end])
+		start = end + 1
+	}
+
+	unescape := strings.NewReplacer(`\=`, "=", `\\`, `\`, `\n`, "\n", `\r`, "\r")
+	for i, k := range keys {
+		if k != "" {
+			fields[k] = unescape.Replace(values[i])
+		}
+	}
+	return fields
+}
+
+func indexUnescaped(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// leefDelimiter decodes a LEEF 2.0 delimiter: a single character or a hex
+// code such as "0x5e" or "x09".
+func leefDelimiter(s string) string {
+	if len(s) == 1 {
+		return s
+	}
+	hex := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "0"), "x")
+	if n, err := strconv.ParseUint(hex, 16, 8); err == nil && n > 0 {
+		return string(rune(n))
+	}
+	return ""
+}
+
+// parseVendorSeverity maps the CEF/LEEF 0-10 scale (or CEF's Low/Medium/
+// High/Very-High names) onto canonical severities.
+func parseVendorSeverity(s string) Severity {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		switch {
+		case n >= 9:
+			return SeverityCritical
+		case n >= 7:
+			return SeverityAlert
+		case n >= 4:
+			return SeverityWarning
+		default:
+			return SeverityInfo
+		}
+	}
+	if strings.EqualFold(s, "very-high") {
+		return SeverityCritical
+	}
+	return NormalizeSeverity(s)
+}
+
+var vendorTimeLayouts = []string{
+	time.RFC3339,
+	"Jan 02 2006 15:04:05",
+	"Jan 02 2006 15:04:05.000",
+	"Jan 02 2006 15:04:05 MST",
+	"Jan 02 15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// parseVendorTime parses epoch milliseconds or one of the common textual
+// layouts, returning the zero time if none match.
+func parseVendorTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms)
+	}
+	for _, layout := range vendorTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			if t.Year() == 0 {
+				t = t.AddDate(time.Now().Year(), 0, 0)
+			}
+			return t
+		}
+	}
+	return time.Time{}
+}
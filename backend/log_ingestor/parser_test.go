@@ -0,0 +1,178 @@
+package main
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestParseStructuredMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     string
+		want    LogEntry
+		wantErr bool
+	}{
+		{
+			name: "cef",
+			msg:  `CEF:0|Palo Alto|PAN-OS|10.1|THREAT|Port scan|8|src=10.0.0.5 dst=10.0.0.9 msg=scan detected`,
+			want: LogEntry{
+				Source:    "PAN-OS",
+				Message:   "Port scan: scan detected",
+				Severity:  SeverityAlert,
+				IPAddress: "10.0.0.5",
+				Fields: map[string]string{
+					"src": "10.0.0.5", "dst": "10.0.0.9", "msg": "scan detected",
+					"cef_version": "0", "device_vendor": "Palo Alto", "device_product": "PAN-OS",
+					"device_version": "10.1", "signature_id": "THREAT",
+				},
+			},
+		},
+		{
+			name: "cef after a syslog header",
+			msg:  `<134>Jan 12 10:00:00 fw01 CEF:0|Fortinet|FortiGate|7.0|100|Login failed|Medium|src=192.168.1.20`,
+			want: LogEntry{
+				Source:    "FortiGate",
+				Message:   "Login failed",
+				Severity:  SeverityWarning,
+				IPAddress: "192.168.1.20",
+				Fields: map[string]string{
+					"src":         "192.168.1.20",
+					"cef_version": "0", "device_vendor": "Fortinet", "device_product": "FortiGate",
+					"device_version": "7.0", "signature_id": "100",
+				},
+			},
+		},
+		{
+			name: "cef escapes",
+			msg:  `CEF:0|Acme|Gate\|Way|1.0|7|Rule a\\b|Very-High|cs1=a\=b request=/x?y\=1 act=blocked`,
+			want: LogEntry{
+				Source:   "Gate|Way",
+				Message:  `Rule a\b`,
+				Severity: SeverityCritical,
+				Fields: map[string]string{
+					"cs1": "a=b", "request": "/x?y=1", "act": "blocked",
+					"cef_version": "0", "device_vendor": "Acme", "device_product": "Gate|Way",
+					"device_version": "1.0", "signature_id": "7",
+				},
+			},
+		},
+		{
+			name: "leef 1.0",
+			msg:  "LEEF:1.0|IBM|QRadar|7.4|4625|src=10.1.1.1\tsev=9\tcat=Authentication",
+			want: LogEntry{
+				Source:    "QRadar",
+				Message:   "Authentication: 4625",
+				Severity:  SeverityCritical,
+				IPAddress: "10.1.1.1",
+				Fields: map[string]string{
+					"src": "10.1.1.1", "sev": "9", "cat": "Authentication",
+					"leef_version": "1.0", "device_vendor": "IBM", "device_product": "QRadar",
+					"device_version": "7.4", "event_id": "4625",
+				},
+			},
+		},
+		{
+			name: "leef 2.0 hex delimiter",
+			msg:  "LEEF:2.0|Cisco|ASA|9.8|106023|0x5e|src=172.16.0.1^sev=2^proto=TCP",
+			want: LogEntry{
+				Source:    "ASA",
+				Message:   "106023",
+				Severity:  SeverityInfo,
+				IPAddress: "172.16.0.1",
+				Fields: map[string]string{
+					"src": "172.16.0.1", "sev": "2", "proto": "TCP",
+					"leef_version": "2.0", "device_vendor": "Cisco", "device_product": "ASA",
+					"device_version": "9.8", "event_id": "106023",
+				},
+			},
+		},
+		{
+			name:    "not structured",
+			msg:     "plain text message",
+			wantErr: true,
+		},
+		{
+			name:    "truncated cef header",
+			msg:     "CEF:0|Acme|Gate|1.0",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStructuredMessage(tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Source != tt.want.Source || got.Message != tt.want.Message ||
+				got.Severity != tt.want.Severity || got.IPAddress != tt.want.IPAddress {
+				t.Errorf("got source %q message %q severity %v ip %q, want %q %q %v %q",
+					got.Source, got.Message, got.Severity, got.IPAddress,
+					tt.want.Source, tt.want.Message, tt.want.Severity, tt.want.IPAddress)
+			}
+			if !maps.Equal(got.Fields, tt.want.Fields) {
+				t.Errorf("fields = %v, want %v", got.Fields, tt.want.Fields)
+			}
+		})
+	}
+}
+
+func TestParseVendorSeverity(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Severity
+	}{
+		{"0", SeverityInfo},
+		{"3", SeverityInfo},
+		{"4", SeverityWarning},
+		{"7", SeverityAlert},
+		{"10", SeverityCritical},
+		{"Low", SeverityInfo},
+		{"Medium", SeverityWarning},
+		{"High", SeverityAlert},
+		{"very-high", SeverityCritical},
+		{"bogus", SeverityInfo},
+	}
+	for _, tt := range tests {
+		if got := parseVendorSeverity(tt.in); got != tt.want {
+			t.Errorf("parseVendorSeverity(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseVendorTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"1700000000000", time.UnixMilli(1700000000000)},
+		{"2024-03-01T12:30:00Z", time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{"Mar 01 2024 12:30:00", time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{"2024-03-01 12:30:00", time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{"Mar 01 12:30:00", time.Date(time.Now().Year(), 3, 1, 12, 30, 0, 0, time.UTC)},
+		{"yesterday", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := parseVendorTime(tt.in); !got.Equal(tt.want) {
+			t.Errorf("parseVendorTime(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLEEFDelimiter(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"^", "^"},
+		{"0x5e", "^"},
+		{"x09", "\t"},
+		{"0x00", ""},
+		{"zz", ""},
+	}
+	for _, tt := range tests {
+		if got := leefDelimiter(tt.in); got != tt.want {
+			t.Errorf("leefDelimiter(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
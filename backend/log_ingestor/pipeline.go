@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"1logx/log_ingestor/internal/pipeline"
+)
+
+// pipelineTestRequest is a sample for the dry-run endpoint: either a raw
+// line (with an optional source hint) or a full entry as sent to /api/ingest.
+type pipelineTestRequest struct {
+	Raw    string    `json:"raw,omitempty"`
+	Source string    `json:"source,omitempty"`
+	Entry  *LogEntry `json:"entry,omitempty"`
+}
+
+// pipelineTestHandler serves POST /api/v1/pipeline/test. It runs a sample
+// through every stage and returns the intermediate and final results
+// without storing or broadcasting anything. A text/plain body is treated
+// as a raw line.
+func pipelineTestHandler(in *pipeline.Ingestor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBody))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed to read body")
+			return
+		}
+
+		var req pipelineTestRequest
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "text/plain") {
+			req.Raw = strings.TrimSpace(string(body))
+		} else if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+
+		var entry LogEntry
+		switch {
+		case req.Entry != nil:
+			entry = *req.Entry
+		case req.Raw != "":
+			entry = LogEntry{Message: req.Raw, Source: req.Source}
+		default:
+			writeError(w, http.StatusBadRequest, "provide 'raw' or 'entry'")
+			return
+		}
+		entry.TenantID = tenantFromRequest(r)
+
+		input := pipeline.CloneEntry(entry)
+		var trace []pipeline.Trace
+		_, err = in.Process(&entry, &trace)
+
+		resp := map[string]any{
+			"input":    input,
+			"stages":   trace,
+			"accepted": err == nil,
+		}
+		if err != nil {
+			resp["error"] = err.Error()
+		} else {
+			resp["result"] = entry
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
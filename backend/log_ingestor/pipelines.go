@@ -0,0 +1,689 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"1logx/log_ingestor/internal/config"
+	"1logx/log_ingestor/internal/pipeline"
+	"1logx/log_ingestor/internal/store"
+)
+
+// Batch retries before a pipeline gives up on a batch. Replayable inputs
+// never give up; they wait for the database, up to pipelineMaxBackoff apart.
+const (
+	pipelineMaxAttempts    = 5
+	pipelineInitialBackoff = 500 * time.Millisecond
+	pipelineMaxBackoff     = 30 * time.Second
+)
+
+// errPipelineFull reports that a pipeline's queue is full and the entry was
+// dropped.
+var errPipelineFull = errors.New("pipeline queue full")
+
+var (
+	pipelineNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+	sqlIdentPattern     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+)
+
+// validatePipeline reports the first problem with c on db, with parsers,
+// if any.
+func validatePipeline(c PipelineConfig, db store.Store, parsers *CustomParsers) error {
+	if !pipelineNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("invalid pipeline name %q", c.Name)
+	}
+	switch c.Input.Type {
+	case "http", "netflow":
+	case "file":
+		if c.Input.Path == "" {
+			return fmt.Errorf("pipeline %s: file input requires a path", c.Name)
+		}
+		// Checkpoints must be committed in read order.
+		if c.Workers > 1 {
+			return fmt.Errorf("pipeline %s: file input allows one worker", c.Name)
+		}
+	case "syslog":
+		if c.Input.Protocol != "udp" && c.Input.Protocol != "tcp" {
+			return fmt.Errorf("pipeline %s: syslog protocol must be udp or tcp", c.Name)
+		}
+	default:
+		return fmt.Errorf("pipeline %s: input type must be http, syslog, netflow, or file", c.Name)
+	}
+	switch c.Parser {
+	case "", "cef", "none":
+	default:
+		if parsers.named(c.Parser) == nil {
+			return fmt.Errorf("pipeline %s: no parser named %q", c.Name, c.Parser)
+		}
+	}
+	for _, name := range c.Enrich {
+		if !slices.Contains(config.PipelineEnrichStages, name) {
+			return fmt.Errorf("pipeline %s: unknown enrichment stage %q", c.Name, name)
+		}
+	}
+	if !sqlIdentPattern.MatchString(c.Table) {
+		return fmt.Errorf("pipeline %s: invalid table %q", c.Name, c.Table)
+	}
+	if c.Partition != "" {
+		if db.Driver() != "tidb" {
+			return fmt.Errorf("pipeline %s: partition requires the tidb driver", c.Name)
+		}
+		if !sqlIdentPattern.MatchString(c.Partition) {
+			return fmt.Errorf("pipeline %s: invalid partition %q", c.Name, c.Partition)
+		}
+	}
+	return nil
+}
+
+// pipelineStagesFor returns c's stages, in the order of the ingest path's
+// stages, with custom parsing by parsers.
+func pipelineStagesFor(c PipelineConfig, ingest []pipeline.Stage, parsers *CustomParsers) []pipeline.Stage {
+	var stages []pipeline.Stage
+	for _, stage := range ingest {
+		switch stage.Name {
+		case "parse":
+			if c.Parser == "" || c.Parser == "cef" {
+				stages = append(stages, stage)
+			}
+		case "custom":
+			switch c.Parser {
+			case "":
+				stages = append(stages, stage)
+			case "cef", "none":
+			default:
+				stages = append(stages, namedParserStage(parsers, c.Parser))
+			}
+		default:
+			if !stage.Optional || slices.Contains(c.Enrich, stage.Name) {
+				stages = append(stages, stage)
+			}
+		}
+	}
+	return stages
+}
+
+// namedParserStage is the custom stage restricted to one parser, applied
+// whatever the entry's source.
+func namedParserStage(parsers *CustomParsers, name string) pipeline.Stage {
+	return pipeline.Stage{Name: "custom", Optional: true, Run: func(entry *LogEntry) error {
+		p := parsers.named(name)
+		if p == nil {
+			return fmt.Errorf("no parser named %q", name)
+		}
+		captures, ts, ok, err := p.parse(entry.Message)
+		if !ok || err != nil {
+			return err
+		}
+		p.apply(entry, captures, ts)
+		return nil
+	}}
+}
+
+// pipelineItem is a queued entry and when it arrived. Entries from
+// replayable inputs carry the input's position after them.
+type pipelineItem struct {
+	entry      LogEntry
+	received   time.Time
+	checkpoint *ingestCheckpoint
+}
+
+// Pipeline queues entries from one input and stores them in batches.
+type Pipeline struct {
+	cfg    PipelineConfig
+	in     *pipeline.Ingestor
+	retry  *RetryQueue // nil without a retry queue
+	stages []pipeline.Stage
+	into   string
+	flush  time.Duration
+	queue  chan pipelineItem
+	source *pipeline.Source // pipeline:<name>
+	stats  *pipeline.Stats
+
+	received, stored, dropped, rejected, failed atomic.Int64
+}
+
+func NewPipeline(cfg PipelineConfig, in *pipeline.Ingestor, parsers *CustomParsers, retry *RetryQueue) (*Pipeline, error) {
+	if err := validatePipeline(cfg, in.DB(), parsers); err != nil {
+		return nil, err
+	}
+	p := &Pipeline{cfg: cfg, in: in, retry: retry, stages: pipelineStagesFor(cfg, in.Stages(), parsers), queue: make(chan pipelineItem, cfg.QueueSize), stats: pipeline.NewStats()}
+	p.source = in.Sources().Add("pipeline:" + cfg.Name)
+	p.source.SetBacklog(func() int { return len(p.queue) })
+	p.flush, _ = time.ParseDuration(cfg.FlushInterval)
+	p.into = "INSERT INTO " + cfg.Table
+	if cfg.Partition != "" {
+		p.into += " PARTITION (" + cfg.Partition + ")"
+	}
+	return p, nil
+}
+
+// Run starts the pipeline's workers and serves its input until it fails.
+// HTTP pipelines are fed by Pipelines.ingestHandler and return at once.
+func (p *Pipeline) Run() error {
+	for range p.cfg.Workers {
+		go p.work()
+	}
+	args := []any{"pipeline", p.cfg.Name, "input", p.cfg.Input.Type, "table", p.cfg.Table}
+	switch {
+	case p.cfg.Input.Path != "":
+		args = append(args, "path", p.cfg.Input.Path)
+	case p.cfg.Input.Addr != "":
+		args = append(args, "addr", p.cfg.Input.Addr)
+	}
+	slog.Info("Ingestion pipeline running", args...)
+	switch p.cfg.Input.Type {
+	case "syslog":
+		if p.cfg.Input.Protocol == "tcp" {
+			return p.serveSyslogTCP()
+		}
+		return p.serveSyslogUDP()
+	case "netflow":
+		collector, err := NewNetFlowCollector(NetFlowConfig{
+			Addr: p.cfg.Input.Addr, Source: p.cfg.Source, Tenant: p.cfg.Tenant,
+		}.WithDefaults(), p.in)
+		if err != nil {
+			return err
+		}
+		collector.ingest = func(_ pipeline.Origin, entry LogEntry) error { return p.offer(entry) }
+		return collector.Run()
+	case "file":
+		return p.tailFile()
+	}
+	return nil
+}
+
+// offer queues entry, dropping it if the queue is full so a backed-up
+// pipeline never blocks its input.
+func (p *Pipeline) offer(entry LogEntry) error {
+	if p.source.Paused() {
+		return pipeline.ErrSourcePaused
+	}
+	p.received.Add(1)
+	select {
+	case p.queue <- pipelineItem{entry: entry, received: time.Now()}:
+		return nil
+	default:
+		p.dropped.Add(1)
+		return errPipelineFull
+	}
+}
+
+// enqueue queues item, waiting for room, and for the pipeline to be
+// resumed, for inputs that can be held back.
+func (p *Pipeline) enqueue(item pipelineItem) {
+	for p.source.Paused() {
+		time.Sleep(time.Second)
+	}
+	p.received.Add(1)
+	item.received = time.Now()
+	p.queue <- item
+}
+
+// replayable reports whether the pipeline's input can be re-read from a
+// checkpoint, so batches are retried rather than given up on.
+func (p *Pipeline) replayable() bool {
+	return p.cfg.Input.Type == "file"
+}
+
+// work processes queued entries and stores them once a batch fills or the
+// flush interval passes. The newest checkpoint is stored with the batch,
+// including those of entries that were rejected.
+func (p *Pipeline) work() {
+	batch := make([]LogEntry, 0, p.cfg.BatchSize)
+	var checkpoint *ingestCheckpoint
+	ticker := time.NewTicker(p.flush)
+	defer ticker.Stop()
+	for {
+		select {
+		case item := <-p.queue:
+			if item.checkpoint != nil {
+				checkpoint = item.checkpoint
+				if item.entry.Message == "" {
+					continue // a position past lines with nothing to store
+				}
+			}
+			if entry, ok := p.process(item); ok {
+				batch = append(batch, entry)
+			}
+			if len(batch) >= p.cfg.BatchSize {
+				p.write(batch, checkpoint)
+				batch, checkpoint = batch[:0], nil
+			}
+		case <-ticker.C:
+			if len(batch) > 0 || checkpoint != nil {
+				p.write(batch, checkpoint)
+				batch, checkpoint = batch[:0], nil
+			}
+		}
+	}
+}
+
+// process runs item through the pipeline's stages and the circuit breaker.
+func (p *Pipeline) process(item pipelineItem) (LogEntry, bool) {
+	entry, sentTime := item.entry, !item.entry.Timestamp.IsZero()
+	if entry.Source == "" {
+		entry.Source = p.cfg.Source
+	}
+	failed, err := pipeline.RunStages(p.stages, p.stats, &entry, nil)
+	err = p.in.Checked(entry, sentTime, item.received, failed, err)
+	p.source.Observe(entry, item.received, err)
+	if err != nil {
+		p.rejected.Add(1)
+		slog.Debug("Rejected pipeline entry", "pipeline", p.cfg.Name, "err", err)
+		return entry, false
+	}
+	if p.in.Shed(entry) {
+		p.dropped.Add(1)
+		return entry, false
+	}
+	return entry, true
+}
+
+// write stores batch and checkpoint, if any, in one transaction, retrying
+// with backoff. A batch from a replayable input is retried until it's
+// stored; any other that still fails is dead-lettered when bound for logs,
+// and dropped otherwise.
+func (p *Pipeline) write(batch []LogEntry, checkpoint *ingestCheckpoint) {
+	toLogs := p.cfg.Table == "logs"
+	embeddings := make([]store.Embedding, len(batch))
+	if toLogs {
+		for i := range batch {
+			embeddings[i] = p.in.Embed(batch[i])
+		}
+	}
+	var err error
+	backoff := pipelineInitialBackoff
+	for attempt := 1; ; attempt++ {
+		if err = p.insert(batch, embeddings, checkpoint); err == nil {
+			break
+		}
+		slog.Warn("Failed to store pipeline batch", "pipeline", p.cfg.Name, "entries", len(batch), "attempt", attempt, "err", err)
+		if attempt == pipelineMaxAttempts && !p.replayable() {
+			break
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, pipelineMaxBackoff)
+	}
+	if err != nil {
+		p.failed.Add(int64(len(batch)))
+		p.source.Fail(len(batch), err)
+		if toLogs && p.retry != nil {
+			p.retry.deadLetterBatch(batch, pipelineMaxAttempts, err, "pipeline retries exhausted")
+		} else {
+			slog.Error("Dropped pipeline batch", "pipeline", p.cfg.Name, "entries", len(batch), "err", err)
+		}
+		return
+	}
+	p.stored.Add(int64(len(batch)))
+	if toLogs {
+		for _, entry := range batch {
+			p.in.Publish(entry, false)
+		}
+	}
+}
+
+func (p *Pipeline) insert(batch []LogEntry, embeddings []store.Embedding, checkpoint *ingestCheckpoint) error {
+	start := time.Now()
+	tx, err := p.in.DB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i := range batch {
+		if err := store.InsertLogInto(tx, p.into, &batch[i], embeddings[i]); err != nil {
+			return fmt.Errorf("insert: %w", err)
+		}
+	}
+	if checkpoint != nil {
+		if err := saveCheckpoint(tx, *checkpoint); err != nil {
+			return fmt.Errorf("checkpoint: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	p.in.Load().ObserveInsert(time.Since(start))
+	return nil
+}
+
+// syslogEntry builds an entry from a syslog message received from sender.
+func (p *Pipeline) syslogEntry(line string, sender net.Addr) LogEntry {
+	entry := parseSyslog(line, time.Now())
+	entry.TenantID = p.cfg.Tenant
+	if host, _, err := net.SplitHostPort(sender.String()); err == nil {
+		entry.IPAddress = host
+	}
+	return entry
+}
+
+// serveSyslogUDP reads one message per datagram.
+func (p *Pipeline) serveSyslogUDP() error {
+	conn, err := net.ListenPacket("udp", p.cfg.Input.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		line := strings.TrimRight(string(buf[:n]), "\r\n\x00")
+		if line == "" {
+			continue
+		}
+		p.offer(p.syslogEntry(line, addr))
+	}
+}
+
+// serveSyslogTCP accepts connections framed by newlines or, per RFC 6587,
+// by octet counts. A full queue blocks only the sending connection.
+func (p *Pipeline) serveSyslogTCP() error {
+	ln, err := net.Listen("tcp", p.cfg.Input.Addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			for {
+				line, err := readSyslogFrame(r)
+				if err != nil {
+					if err != io.EOF {
+						slog.Debug("Closed syslog connection", "pipeline", p.cfg.Name, "remote", conn.RemoteAddr().String(), "err", err)
+					}
+					return
+				}
+				if line == "" {
+					continue
+				}
+				p.enqueue(pipelineItem{entry: p.syslogEntry(line, conn.RemoteAddr())})
+			}
+		}()
+	}
+}
+
+// readSyslogFrame reads one octet-counted ("<len> <msg>") or
+// newline-terminated message.
+func readSyslogFrame(r *bufio.Reader) (string, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+	if first[0] >= '1' && first[0] <= '9' {
+		count, err := r.ReadString(' ')
+		if err != nil {
+			return "", err
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(count, " "))
+		if err != nil || n > maxIngestBody {
+			return "", fmt.Errorf("invalid octet count %q", count)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(msg), "\r\n"), nil
+	}
+	line, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// parseSyslog reads an RFC 5424 or RFC 3164 message. Header values go to
+// fields; a message without a valid <PRI> header is kept whole.
+func parseSyslog(line string, now time.Time) LogEntry {
+	entry := LogEntry{Message: line}
+	end := strings.IndexByte(line, '>')
+	if !strings.HasPrefix(line, "<") || end < 2 || end > 4 {
+		return entry
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return entry
+	}
+	entry.Severity = syslogSeverity(pri % 8)
+	entry.Fields = map[string]string{"facility": strconv.Itoa(pri / 8)}
+	rest := line[end+1:]
+	if strings.HasPrefix(rest, "1 ") {
+		parseSyslog5424(&entry, rest[2:])
+	} else {
+		parseSyslog3164(&entry, rest, now)
+	}
+	return entry
+}
+
+// syslogSeverity maps syslog severities (0 emergency to 7 debug) onto the
+// canonical levels.
+func syslogSeverity(n int) Severity {
+	switch {
+	case n <= 2:
+		return SeverityCritical
+	case n == 3:
+		return SeverityAlert
+	case n == 4:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+// parseSyslog5424 parses "TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG",
+// where "-" marks an absent value. Structured data is dropped.
+func parseSyslog5424(entry *LogEntry, rest string) {
+	parts := strings.SplitN(rest, " ", 6)
+	if len(parts) < 6 {
+		return
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, parts[0]); err == nil {
+		entry.Timestamp = ts
+	}
+	for i, key := range []string{"", "host", "program", "pid", "msgid"} {
+		if key != "" && parts[i] != "-" {
+			entry.Fields[key] = parts[i]
+		}
+	}
+	msg := parts[5]
+	if strings.HasPrefix(msg, "-") {
+		msg = msg[1:]
+	} else {
+		msg = skipStructuredData(msg)
+	}
+	entry.Message = strings.TrimPrefix(strings.TrimLeft(msg, " "), "\ufeff")
+}
+
+// skipStructuredData returns msg after its leading [SD-ELEMENT]s.
+func skipStructuredData(msg string) string {
+	for strings.HasPrefix(msg, "[") {
+		end := sdElementEnd(msg)
+		if end < 0 {
+			return ""
+		}
+		msg = msg[end+1:]
+	}
+	return msg
+}
+
+// sdElementEnd returns the index of the "]" closing the SD-ELEMENT s starts
+// with, whose quoted values may contain escaped brackets, or -1.
+func sdElementEnd(s string) int {
+	quoted := false
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == ']' && !quoted:
+			return i
+		}
+	}
+	return -1
+}
+
+// parseSyslog3164 parses "Mmm dd hh:mm:ss HOST TAG[PID]: MSG". The year
+// isn't sent, so a timestamp more than a day ahead belongs to last year.
+func parseSyslog3164(entry *LogEntry, rest string, now time.Time) {
+	if len(rest) < 16 || rest[15] != ' ' {
+		return
+	}
+	ts, err := time.ParseInLocation(time.Stamp, rest[:15], time.Local)
+	if err != nil {
+		return
+	}
+	ts = ts.AddDate(now.Year(), 0, 0)
+	if ts.After(now.Add(24 * time.Hour)) {
+		ts = ts.AddDate(-1, 0, 0)
+	}
+	entry.Timestamp = ts
+	host, msg, ok := strings.Cut(rest[16:], " ")
+	if !ok {
+		entry.Message = rest[16:]
+		return
+	}
+	entry.Fields["host"] = host
+	entry.Message = msg
+	tag, body, ok := strings.Cut(msg, ": ")
+	if !ok || tag == "" || strings.ContainsAny(tag, " \t") {
+		return
+	}
+	if prog, pid, ok := strings.Cut(strings.TrimSuffix(tag, "]"), "["); ok {
+		entry.Fields["program"], entry.Fields["pid"] = prog, pid
+	} else {
+		entry.Fields["program"] = tag
+	}
+	entry.Message = body
+}
+
+// Pipelines are the configured named pipelines.
+type Pipelines map[string]*Pipeline
+
+func NewPipelines(configs []PipelineConfig, in *pipeline.Ingestor, parsers *CustomParsers, retry *RetryQueue) (Pipelines, error) {
+	pipelines := Pipelines{}
+	for _, cfg := range configs {
+		cfg = cfg.WithDefaults()
+		if pipelines[cfg.Name] != nil {
+			return nil, fmt.Errorf("duplicate pipeline name %q", cfg.Name)
+		}
+		p, err := NewPipeline(cfg, in, parsers, retry)
+		if err != nil {
+			return nil, err
+		}
+		pipelines[cfg.Name] = p
+	}
+	return pipelines, nil
+}
+
+// PipelineStatus is a pipeline's counters since startup.
+type PipelineStatus struct {
+	Name     string                 `json:"name"`
+	Input    string                 `json:"input"`
+	Table    string                 `json:"table"`
+	Queued   int                    `json:"queued"`
+	Received int64                  `json:"received"`
+	Stored   int64                  `json:"stored"`
+	Dropped  int64                  `json:"dropped"`  // queue full or shed
+	Rejected int64                  `json:"rejected"` // failed validation
+	Failed   int64                  `json:"failed"`   // entries in batches that exhausted their retries
+	Paused   bool                   `json:"paused"`
+	Stages   []pipeline.StageStatus `json:"stages"`
+}
+
+// handler serves GET /api/admin/pipelines, with in's stages as the shared
+// path behind /api/ingest and the other direct inputs.
+func (ps Pipelines) handler(in *pipeline.Ingestor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses := make([]PipelineStatus, 0, len(ps))
+		for _, p := range ps {
+			statuses = append(statuses, PipelineStatus{
+				Name: p.cfg.Name, Input: p.cfg.Input.Type, Table: p.cfg.Table, Queued: len(p.queue),
+				Received: p.received.Load(), Stored: p.stored.Load(), Dropped: p.dropped.Load(),
+				Rejected: p.rejected.Load(), Failed: p.failed.Load(),
+				Paused: p.source.Paused(), Stages: p.stats.Status(p.stages),
+			})
+		}
+		slices.SortFunc(statuses, func(a, b PipelineStatus) int { return strings.Compare(a.Name, b.Name) })
+		writeJSON(w, http.StatusOK, map[string]any{
+			"ingest":    map[string]any{"stages": in.StageStatus()},
+			"pipelines": statuses,
+		})
+	}
+}
+
+// ingestHandler serves POST /api/pipelines/{name}/ingest for HTTP pipelines:
+// a JSON entry or array as for /api/ingest, or text/plain lines as raw
+// messages. Rate limits apply as for /api/ingest, but entries are processed
+// after the response, so otherwise only a full queue is reported; it stops
+// at the first entry that was limited or didn't fit.
+func (ps Pipelines) ingestHandler(w http.ResponseWriter, r *http.Request) {
+	p := ps[r.PathValue("name")]
+	if p == nil || p.cfg.Input.Type != "http" {
+		writeError(w, http.StatusNotFound, "no HTTP pipeline named "+r.PathValue("name"))
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, maxIngestBody)
+	var entries []LogEntry
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/plain") {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed to read body")
+			return
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+				entries = append(entries, LogEntry{Message: line})
+			}
+		}
+	} else {
+		var err error
+		if entries, err = decodeEntries(body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+	}
+	// The tenant always comes from the credential, never the payload.
+	tenant := tenantFromRequest(r)
+	origin := requestOrigin(r)
+	origin.Input = p.source.Name()
+	for i, entry := range entries {
+		entry.TenantID = tenant
+		if entry.Source == "" {
+			entry.Source = p.cfg.Source
+		}
+		err := p.in.Allow(origin, entry)
+		if err == nil {
+			err = p.offer(entry)
+		}
+		if err != nil {
+			// Entries from index on weren't queued; the client resends them.
+			status := http.StatusServiceUnavailable
+			if errors.Is(err, pipeline.ErrRateLimited) {
+				status = http.StatusTooManyRequests
+			}
+			w.Header().Set("Retry-After", "1")
+			writeJSON(w, status, map[string]any{"error": err.Error(), "index": i, "queued": i})
+			return
+		}
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{"queued": len(entries)})
+}
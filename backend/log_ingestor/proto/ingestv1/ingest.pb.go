@@ -0,0 +1,508 @@
+// 1L0Gx gRPC ingestion API.
+//
+// Regenerate the Go code from backend/log_ingestor with:
+//   buf generate
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: ingestv1/ingest.proto
+
+package ingestv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Severity mirrors the canonical severity levels.
+type Severity int32
+
+const (
+	Severity_SEVERITY_UNSPECIFIED Severity = 0 // use severity_text, or INFO if that is empty
+	Severity_SEVERITY_INFO        Severity = 1
+	Severity_SEVERITY_WARNING     Severity = 2
+	Severity_SEVERITY_ALERT       Severity = 3
+	Severity_SEVERITY_CRITICAL    Severity = 4
+)
+
+// Enum value maps for Severity.
+var (
+	Severity_name = map[int32]string{
+		0: "SEVERITY_UNSPECIFIED",
+		1: "SEVERITY_INFO",
+		2: "SEVERITY_WARNING",
+		3: "SEVERITY_ALERT",
+		4: "SEVERITY_CRITICAL",
+	}
+	Severity_value = map[string]int32{
+		"SEVERITY_UNSPECIFIED": 0,
+		"SEVERITY_INFO":        1,
+		"SEVERITY_WARNING":     2,
+		"SEVERITY_ALERT":       3,
+		"SEVERITY_CRITICAL":    4,
+	}
+)
+
+func (x Severity) Enum() *Severity {
+	p := new(Severity)
+	*p = x
+	return p
+}
+
+func (x Severity) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Severity) Descriptor() protoreflect.EnumDescriptor {
+	return file_ingestv1_ingest_proto_enumTypes[0].Descriptor()
+}
+
+func (Severity) Type() protoreflect.EnumType {
+	return &file_ingestv1_ingest_proto_enumTypes[0]
+}
+
+func (x Severity) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Severity.Descriptor instead.
+func (Severity) EnumDescriptor() ([]byte, []int) {
+	return file_ingestv1_ingest_proto_rawDescGZIP(), []int{0}
+}
+
+// LogEntry is a single security log. The tenant is taken from the caller's
+// API key, never from the message.
+type LogEntry struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`              // set by the server
+	Timestamp *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // defaults to receive time
+	Source    string                 `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
+	Severity  Severity               `protobuf:"varint,4,opt,name=severity,proto3,enum=onelogx.ingest.v1.Severity" json:"severity,omitempty"`
+	// Free-form severity ("warn", "err", syslog numbers, ...), normalized by the
+	// server when severity is SEVERITY_UNSPECIFIED.
+	SeverityText  string            `protobuf:"bytes,5,opt,name=severity_text,json=severityText,proto3" json:"severity_text,omitempty"`
+	Message       string            `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	IpAddress     string            `protobuf:"bytes,7,opt,name=ip_address,json=ipAddress,proto3" json:"ip_address,omitempty"`
+	Fields        map[string]string `protobuf:"bytes,8,rep,name=fields,proto3" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Labels        map[string]string `protobuf:"bytes,9,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogEntry) Reset() {
+	*x = LogEntry{}
+	mi := &file_ingestv1_ingest_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogEntry) ProtoMessage() {}
+
+func (x *LogEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_ingestv1_ingest_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogEntry.ProtoReflect.Descriptor instead.
+func (*LogEntry) Descriptor() ([]byte, []int) {
+	return file_ingestv1_ingest_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LogEntry) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *LogEntry) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *LogEntry) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *LogEntry) GetSeverity() Severity {
+	if x != nil {
+		return x.Severity
+	}
+	return Severity_SEVERITY_UNSPECIFIED
+}
+
+func (x *LogEntry) GetSeverityText() string {
+	if x != nil {
+		return x.SeverityText
+	}
+	return ""
+}
+
+func (x *LogEntry) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *LogEntry) GetIpAddress() string {
+	if x != nil {
+		return x.IpAddress
+	}
+	return ""
+}
+
+func (x *LogEntry) GetFields() map[string]string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *LogEntry) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type IngestLogRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entry         *LogEntry              `protobuf:"bytes,1,opt,name=entry,proto3" json:"entry,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IngestLogRequest) Reset() {
+	*x = IngestLogRequest{}
+	mi := &file_ingestv1_ingest_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestLogRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestLogRequest) ProtoMessage() {}
+
+func (x *IngestLogRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ingestv1_ingest_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestLogRequest.ProtoReflect.Descriptor instead.
+func (*IngestLogRequest) Descriptor() ([]byte, []int) {
+	return file_ingestv1_ingest_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *IngestLogRequest) GetEntry() *LogEntry {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+type IngestLogResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IngestLogResponse) Reset() {
+	*x = IngestLogResponse{}
+	mi := &file_ingestv1_ingest_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestLogResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestLogResponse) ProtoMessage() {}
+
+func (x *IngestLogResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ingestv1_ingest_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestLogResponse.ProtoReflect.Descriptor instead.
+func (*IngestLogResponse) Descriptor() ([]byte, []int) {
+	return file_ingestv1_ingest_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *IngestLogResponse) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type IngestError struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int64                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"` // position in the stream
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IngestError) Reset() {
+	*x = IngestError{}
+	mi := &file_ingestv1_ingest_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestError) ProtoMessage() {}
+
+func (x *IngestError) ProtoReflect() protoreflect.Message {
+	mi := &file_ingestv1_ingest_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestError.ProtoReflect.Descriptor instead.
+func (*IngestError) Descriptor() ([]byte, []int) {
+	return file_ingestv1_ingest_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *IngestError) GetIndex() int64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *IngestError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type IngestLogsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Accepted      int64                  `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Ids           []int64                `protobuf:"varint,2,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+	Errors        []*IngestError         `protobuf:"bytes,3,rep,name=errors,proto3" json:"errors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IngestLogsResponse) Reset() {
+	*x = IngestLogsResponse{}
+	mi := &file_ingestv1_ingest_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestLogsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestLogsResponse) ProtoMessage() {}
+
+func (x *IngestLogsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ingestv1_ingest_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestLogsResponse.ProtoReflect.Descriptor instead.
+func (*IngestLogsResponse) Descriptor() ([]byte, []int) {
+	return file_ingestv1_ingest_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *IngestLogsResponse) GetAccepted() int64 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+func (x *IngestLogsResponse) GetIds() []int64 {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+func (x *IngestLogsResponse) GetErrors() []*IngestError {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+var File_ingestv1_ingest_proto protoreflect.FileDescriptor
+
+const file_ingestv1_ingest_proto_rawDesc = "" +
+	"\n" +
+	"\x15ingestv1/ingest.proto\x12\x11onelogx.ingest.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xfb\x03\n" +
+	"\bLogEntry\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x128\n" +
+	"\ttimestamp\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x16\n" +
+	"\x06source\x18\x03 \x01(\tR\x06source\x127\n" +
+	"\bseverity\x18\x04 \x01(\x0e2\x1b.onelogx.ingest.v1.SeverityR\bseverity\x12#\n" +
+	"\rseverity_text\x18\x05 \x01(\tR\fseverityText\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\x12\x1d\n" +
+	"\n" +
+	"ip_address\x18\a \x01(\tR\tipAddress\x12?\n" +
+	"\x06fields\x18\b \x03(\v2'.onelogx.ingest.v1.LogEntry.FieldsEntryR\x06fields\x12?\n" +
+	"\x06labels\x18\t \x03(\v2'.onelogx.ingest.v1.LogEntry.LabelsEntryR\x06labels\x1a9\n" +
+	"\vFieldsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"E\n" +
+	"\x10IngestLogRequest\x121\n" +
+	"\x05entry\x18\x01 \x01(\v2\x1b.onelogx.ingest.v1.LogEntryR\x05entry\"#\n" +
+	"\x11IngestLogResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"=\n" +
+	"\vIngestError\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x03R\x05index\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"z\n" +
+	"\x12IngestLogsResponse\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\x03R\baccepted\x12\x10\n" +
+	"\x03ids\x18\x02 \x03(\x03R\x03ids\x126\n" +
+	"\x06errors\x18\x03 \x03(\v2\x1e.onelogx.ingest.v1.IngestErrorR\x06errors*x\n" +
+	"\bSeverity\x12\x18\n" +
+	"\x14SEVERITY_UNSPECIFIED\x10\x00\x12\x11\n" +
+	"\rSEVERITY_INFO\x10\x01\x12\x14\n" +
+	"\x10SEVERITY_WARNING\x10\x02\x12\x12\n" +
+	"\x0eSEVERITY_ALERT\x10\x03\x12\x15\n" +
+	"\x11SEVERITY_CRITICAL\x10\x042\xc3\x01\n" +
+	"\rIngestService\x12V\n" +
+	"\tIngestLog\x12#.onelogx.ingest.v1.IngestLogRequest\x1a$.onelogx.ingest.v1.IngestLogResponse\x12Z\n" +
+	"\n" +
+	"IngestLogs\x12#.onelogx.ingest.v1.IngestLogRequest\x1a%.onelogx.ingest.v1.IngestLogsResponse(\x01B,Z*1logx/log_ingestor/proto/ingestv1;ingestv1b\x06proto3"
+
+var (
+	file_ingestv1_ingest_proto_rawDescOnce sync.Once
+	file_ingestv1_ingest_proto_rawDescData []byte
+)
+
+func file_ingestv1_ingest_proto_rawDescGZIP() []byte {
+	file_ingestv1_ingest_proto_rawDescOnce.Do(func() {
+		file_ingestv1_ingest_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_ingestv1_ingest_proto_rawDesc), len(file_ingestv1_ingest_proto_rawDesc)))
+	})
+	return file_ingestv1_ingest_proto_rawDescData
+}
+
+var file_ingestv1_ingest_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_ingestv1_ingest_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_ingestv1_ingest_proto_goTypes = []any{
+	(Severity)(0),                 // 0: onelogx.ingest.v1.Severity
+	(*LogEntry)(nil),              // 1: onelogx.ingest.v1.LogEntry
+	(*IngestLogRequest)(nil),      // 2: onelogx.ingest.v1.IngestLogRequest
+	(*IngestLogResponse)(nil),     // 3: onelogx.ingest.v1.IngestLogResponse
+	(*IngestError)(nil),           // 4: onelogx.ingest.v1.IngestError
+	(*IngestLogsResponse)(nil),    // 5: onelogx.ingest.v1.IngestLogsResponse
+	nil,                           // 6: onelogx.ingest.v1.LogEntry.FieldsEntry
+	nil,                           // 7: onelogx.ingest.v1.LogEntry.LabelsEntry
+	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
+}
+var file_ingestv1_ingest_proto_depIdxs = []int32{
+	8, // 0: onelogx.ingest.v1.LogEntry.timestamp:type_name -> google.protobuf.Timestamp
+	0, // 1: onelogx.ingest.v1.LogEntry.severity:type_name -> onelogx.ingest.v1.Severity
+	6, // 2: onelogx.ingest.v1.LogEntry.fields:type_name -> onelogx.ingest.v1.LogEntry.FieldsEntry
+	7, // 3: onelogx.ingest.v1.LogEntry.labels:type_name -> onelogx.ingest.v1.LogEntry.LabelsEntry
+	1, // 4: onelogx.ingest.v1.IngestLogRequest.entry:type_name -> onelogx.ingest.v1.LogEntry
+	4, // 5: onelogx.ingest.v1.IngestLogsResponse.errors:type_name -> onelogx.ingest.v1.IngestError
+	2, // 6: onelogx.ingest.v1.IngestService.IngestLog:input_type -> onelogx.ingest.v1.IngestLogRequest
+	2, // 7: onelogx.ingest.v1.IngestService.IngestLogs:input_type -> onelogx.ingest.v1.IngestLogRequest
+	3, // 8: onelogx.ingest.v1.IngestService.IngestLog:output_type -> onelogx.ingest.v1.IngestLogResponse
+	5, // 9: onelogx.ingest.v1.IngestService.IngestLogs:output_type -> onelogx.ingest.v1.IngestLogsResponse
+	8, // [8:10] is the sub-list for method output_type
+	6, // [6:8] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_ingestv1_ingest_proto_init() }
+func file_ingestv1_ingest_proto_init() {
+	if File_ingestv1_ingest_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_ingestv1_ingest_proto_rawDesc), len(file_ingestv1_ingest_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_ingestv1_ingest_proto_goTypes,
+		DependencyIndexes: file_ingestv1_ingest_proto_depIdxs,
+		EnumInfos:         file_ingestv1_ingest_proto_enumTypes,
+		MessageInfos:      file_ingestv1_ingest_proto_msgTypes,
+	}.Build()
+	File_ingestv1_ingest_proto = out.File
+	file_ingestv1_ingest_proto_goTypes = nil
+	file_ingestv1_ingest_proto_depIdxs = nil
+}
@@ -0,0 +1,164 @@
+// 1L0Gx gRPC ingestion API.
+//
+// Regenerate the Go code from backend/log_ingestor with:
+//   buf generate
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: ingestv1/ingest.proto
+
+package ingestv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	IngestService_IngestLog_FullMethodName  = "/onelogx.ingest.v1.IngestService/IngestLog"
+	IngestService_IngestLogs_FullMethodName = "/onelogx.ingest.v1.IngestService/IngestLogs"
+)
+
+// IngestServiceClient is the client API for IngestService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type IngestServiceClient interface {
+	// IngestLog stores a single entry.
+	IngestLog(ctx context.Context, in *IngestLogRequest, opts ...grpc.CallOption) (*IngestLogResponse, error)
+	// IngestLogs stores a client stream of entries. Invalid entries are
+	// reported in the response without ending the stream.
+	IngestLogs(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[IngestLogRequest, IngestLogsResponse], error)
+}
+
+type ingestServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewIngestServiceClient(cc grpc.ClientConnInterface) IngestServiceClient {
+	return &ingestServiceClient{cc}
+}
+
+func (c *ingestServiceClient) IngestLog(ctx context.Context, in *IngestLogRequest, opts ...grpc.CallOption) (*IngestLogResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IngestLogResponse)
+	err := c.cc.Invoke(ctx, IngestService_IngestLog_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ingestServiceClient) IngestLogs(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[IngestLogRequest, IngestLogsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &IngestService_ServiceDesc.Streams[0], IngestService_IngestLogs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[IngestLogRequest, IngestLogsResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IngestService_IngestLogsClient = grpc.ClientStreamingClient[IngestLogRequest, IngestLogsResponse]
+
+// IngestServiceServer is the server API for IngestService service.
+// All implementations must embed UnimplementedIngestServiceServer
+// for forward compatibility.
+type IngestServiceServer interface {
+	// IngestLog stores a single entry.
+	IngestLog(context.Context, *IngestLogRequest) (*IngestLogResponse, error)
+	// IngestLogs stores a client stream of entries. Invalid entries are
+	// reported in the response without ending the stream.
+	IngestLogs(grpc.ClientStreamingServer[IngestLogRequest, IngestLogsResponse]) error
+	mustEmbedUnimplementedIngestServiceServer()
+}
+
+// UnimplementedIngestServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedIngestServiceServer struct{}
+
+func (UnimplementedIngestServiceServer) IngestLog(context.Context, *IngestLogRequest) (*IngestLogResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method IngestLog not implemented")
+}
+func (UnimplementedIngestServiceServer) IngestLogs(grpc.ClientStreamingServer[IngestLogRequest, IngestLogsResponse]) error {
+	return status.Error(codes.Unimplemented, "method IngestLogs not implemented")
+}
+func (UnimplementedIngestServiceServer) mustEmbedUnimplementedIngestServiceServer() {}
+func (UnimplementedIngestServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeIngestServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to IngestServiceServer will
+// result in compilation errors.
+type UnsafeIngestServiceServer interface {
+	mustEmbedUnimplementedIngestServiceServer()
+}
+
+func RegisterIngestServiceServer(s grpc.ServiceRegistrar, srv IngestServiceServer) {
+	// If the following call panics, it indicates UnimplementedIngestServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&IngestService_ServiceDesc, srv)
+}
+
+func _IngestService_IngestLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IngestLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IngestServiceServer).IngestLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IngestService_IngestLog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IngestServiceServer).IngestLog(ctx, req.(*IngestLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IngestService_IngestLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(IngestServiceServer).IngestLogs(&grpc.GenericServerStream[IngestLogRequest, IngestLogsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IngestService_IngestLogsServer = grpc.ClientStreamingServer[IngestLogRequest, IngestLogsResponse]
+
+// IngestService_ServiceDesc is the grpc.ServiceDesc for IngestService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var IngestService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "onelogx.ingest.v1.IngestService",
+	HandlerType: (*IngestServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "IngestLog",
+			Handler:    _IngestService_IngestLog_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "IngestLogs",
+			Handler:       _IngestService_IngestLogs_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "ingestv1/ingest.proto",
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the per-client write latency
+// histogram; writes slower than the last bound land in an overflow bucket.
+var latencyBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 5 * time.Second,
+}
+
+// clientStats tracks one WebSocket client's writes for the current QoS window.
+type clientStats struct {
+	id          uint64
+	tenantID    string
+	identity    string // API key prefix, or remote IP for anonymous clients
+	remoteAddr  string
+	connectedAt time.Time
+
+	mu           sync.Mutex
+	buckets      []uint64
+	sent, drops  uint64
+	max          time.Duration
+	disconnected bool
+}
+
+func (s *clientStats) observe(d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.drops++
+		return
+	}
+	s.sent++
+	if d > s.max {
+		s.max = d
+	}
+	i := sort.Search(len(latencyBuckets), func(i int) bool { return d <= latencyBuckets[i] })
+	s.buckets[i]++
+}
+
+// drop counts a message that didn't fit in the client's send buffer.
+func (s *clientStats) drop() {
+	s.mu.Lock()
+	s.drops++
+	s.mu.Unlock()
+}
+
+// snapshot summarizes the window and resets it.
+func (s *clientStats) snapshot() ClientQoS {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := ClientQoS{
+		ID:           s.id,
+		TenantID:     s.tenantID,
+		Identity:     s.identity,
+		RemoteAddr:   s.remoteAddr,
+		ConnectedAt:  s.connectedAt,
+		Disconnected: s.disconnected,
+		Sent:         s.sent,
+		Drops:        s.drops,
+		P50Ms:        s.percentile(0.50),
+		P95Ms:        s.percentile(0.95),
+		P99Ms:        s.percentile(0.99),
+		MaxMs:        float64(s.max) / float64(time.Millisecond),
+	}
+	s.buckets = make([]uint64, len(latencyBuckets)+1)
+	s.sent, s.drops, s.max = 0, 0, 0
+	return q
+}
+
+// percentile estimates the p-th write latency, in ms, as the upper bound of
+// the bucket containing it. The overflow bucket reports the window's max.
+func (s *clientStats) percentile(p float64) float64 {
+	if s.sent == 0 {
+		return 0
+	}
+	rank := uint64(p*float64(s.sent) + 0.5)
+	var seen uint64
+	for i, n := range s.buckets {
+		seen += n
+		if seen >= rank && n > 0 {
+			if i == len(latencyBuckets) {
+				return float64(s.max) / float64(time.Millisecond)
+			}
+			return float64(latencyBuckets[i]) / float64(time.Millisecond)
+		}
+	}
+	return float64(s.max) / float64(time.Millisecond)
+}
+
+// ClientQoS is one client's delivery quality over a report window.
+type ClientQoS struct {
+	ID           uint64    `json:"id"`
+	TenantID     string    `json:"tenant_id"`
+	Identity     string    `json:"identity"`
+	RemoteAddr   string    `json:"remote_addr"`
+	ConnectedAt  time.Time `json:"connected_at"`
+	Disconnected bool      `json:"disconnected,omitempty"`
+	Sent         uint64    `json:"sent"`
+	Drops        uint64    `json:"drops"` // failed writes or a full send buffer; the log never reached the client
+	P50Ms        float64   `json:"p50_ms"`
+	P95Ms        float64   `json:"p95_ms"`
+	P99Ms        float64   `json:"p99_ms"`
+	MaxMs        float64   `json:"max_ms"`
+}
+
+// ReconnectQoS counts connections per client identity over a window.
+type ReconnectQoS struct {
+	TenantID   string `json:"tenant_id"`
+	Identity   string `json:"identity"`
+	Connects   int    `json:"connects"`
+	Reconnects int    `json:"reconnects"`
+}
+
+// QoSReport is the stream QoS report for one tenant.
+type QoSReport struct {
+	WindowStart time.Time      `json:"window_start"`
+	WindowEnd   time.Time      `json:"window_end"`
+	Clients     int            `json:"clients"`
+	Sent        uint64         `json:"sent"`
+	Drops       uint64         `json:"drops"`
+	Slowest     []ClientQoS    `json:"slowest"`
+	Reconnects  []ReconnectQoS `json:"reconnects"`
+}
+
+// qosTracker measures every live-stream client and keeps the last complete
+// report window, so "the dashboard is missing events" can be checked against
+// data.
+type qosTracker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	clients     map[uint64]*clientStats
+	connects    map[[2]string]int // tenant, identity -> connections this window
+	windowStart time.Time
+
+	// last complete window
+	lastStart, lastEnd time.Time
+	lastClients        []ClientQoS
+	lastReconnects     []ReconnectQoS
+}
+
+var streamQoS = &qosTracker{
+	clients:     map[uint64]*clientStats{},
+	connects:    map[[2]string]int{},
+	windowStart: time.Now(),
+}
+
+func (t *qosTracker) connect(tenantID, identity, remoteAddr string) *clientStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	s := &clientStats{
+		id:          t.nextID,
+		tenantID:    tenantID,
+		identity:    identity,
+		remoteAddr:  remoteAddr,
+		connectedAt: time.Now(),
+		buckets:     make([]uint64, len(latencyBuckets)+1),
+	}
+	t.clients[s.id] = s
+	t.connects[[2]string{tenantID, identity}]++
+	return s
+}
+
+// disconnect marks s closed; it is reported once more and then forgotten.
+func (t *qosTracker) disconnect(s *clientStats) {
+	s.mu.Lock()
+	s.disconnected = true
+	s.mu.Unlock()
+}
+
+// rotate closes the current window.
+func (t *qosTracker) rotate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	clients := make([]ClientQoS, 0, len(t.clients))
+	for id, s := range t.clients {
+		q := s.snapshot()
+		clients = append(clients, q)
+		if q.Disconnected {
+			delete(t.clients, id)
+		}
+	}
+	reconnects := []ReconnectQoS{}
+	for k, n := range t.connects {
+		if n > 1 {
+			reconnects = append(reconnects, ReconnectQoS{TenantID: k[0], Identity: k[1], Connects: n, Reconnects: n - 1})
+		}
+	}
+	sort.Slice(reconnects, func(i, j int) bool { return reconnects[i].Reconnects > reconnects[j].Reconnects })
+
+	t.lastStart, t.lastEnd = t.windowStart, now
+	t.lastClients, t.lastReconnects = clients, reconnects
+	t.windowStart = now
+	t.connects = map[[2]string]int{}
+}
+
+// report builds tenantID's report from the last complete window.
+func (t *qosTracker) report(tenantID string, slowest int) QoSReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := QoSReport{WindowStart: t.lastStart, WindowEnd: t.lastEnd, Slowest: []ClientQoS{}, Reconnects: []ReconnectQoS{}}
+	for _, c := range t.lastClients {
+		if c.TenantID != tenantID {
+			continue
+		}
+		r.Clients++
+		r.Sent += c.Sent
+		r.Drops += c.Drops
+		r.Slowest = append(r.Slowest, c)
+	}
+	sort.Slice(r.Slowest, func(i, j int) bool {
+		if r.Slowest[i].P95Ms != r.Slowest[j].P95Ms {
+			return r.Slowest[i].P95Ms > r.Slowest[j].P95Ms
+		}
+		return r.Slowest[i].Drops > r.Slowest[j].Drops
+	})
+	if len(r.Slowest) > slowest {
+		r.Slowest = r.Slowest[:slowest]
+	}
+	for _, rc := range t.lastReconnects {
+		if rc.TenantID == tenantID {
+			r.Reconnects = append(r.Reconnects, rc)
+		}
+	}
+	return r
+}
+
+// Run closes a report window every interval and logs a summary.
+func (t *qosTracker) Run(cfg StreamConfig) {
+	interval, _ := time.ParseDuration(cfg.QoSInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		t.rotate()
+		t.mu.Lock()
+		var (
+			sent, drops uint64
+			worst       ClientQoS
+		)
+		for _, c := range t.lastClients {
+			sent += c.Sent
+			drops += c.Drops
+			if c.P95Ms > worst.P95Ms {
+				worst = c
+			}
+		}
+		n, reconnects := len(t.lastClients), len(t.lastReconnects)
+		t.mu.Unlock()
+		if n == 0 {
+			continue
+		}
+		slog.Info("Stream QoS", "clients", n, "sent", sent, "dropped", drops, "flapping", reconnects,
+			"slowest_p95_ms", worst.P95Ms, "slowest", worst.Identity)
+	}
+}
+
+// qosHandler serves GET /api/admin/stream/qos with the last complete window
+// for the caller's tenant.
+func qosHandler(cfg StreamConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, streamQoS.report(tenantFromRequest(r), cfg.QoSSlowest))
+	}
+}
+
+// clientIdentity names a client across reconnects.
+func clientIdentity(r *http.Request) string {
+	if key := keyFromContext(r.Context()); key != nil {
+		return key.Prefix
+	}
+	return clientIP(r)
+}
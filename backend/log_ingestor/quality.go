@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// qualityCounters accumulate one source's window.
+type qualityCounters struct {
+	events, missing, invalidIP, parseFailures int
+	timed, skewed                             int
+	skewSum, skewMax                          float64 // absolute seconds, over timed events
+}
+
+// SourceQuality is one source's data quality over a window.
+type SourceQuality struct {
+	Source           string   `json:"source"`
+	Events           int      `json:"events"`
+	MissingRate      float64  `json:"missing_fields_rate"` // no timestamp, IP, or source
+	InvalidIPRate    float64  `json:"invalid_ip_rate"`
+	SkewedRate       float64  `json:"skewed_rate"`
+	MeanSkewSeconds  float64  `json:"mean_skew_seconds"`
+	MaxSkewSeconds   float64  `json:"max_skew_seconds"`
+	ParseFailureRate float64  `json:"parse_failure_rate"` // unparseable CEF/LEEF or rejected
+	Score            float64  `json:"score"`              // 0-100
+	Degraded         bool     `json:"degraded"`
+	Reasons          []string `json:"reasons,omitempty"`
+}
+
+// QualityWindow is every source's quality for one tenant and window.
+type QualityWindow struct {
+	Start   time.Time       `json:"start"`
+	End     time.Time       `json:"end"`
+	Sources []SourceQuality `json:"sources"`
+}
+
+// QualityTracker measures incoming entries per tenant and source.
+type QualityTracker struct {
+	db     *store.DB
+	alerts *Alerts
+	cfg    QualityConfig
+	skew   time.Duration
+
+	mu      sync.Mutex
+	start   time.Time
+	current map[string]map[string]*qualityCounters // tenant -> source -> counters
+	history map[string][]QualityWindow             // tenant -> windows, oldest first
+	alerted map[string]bool                        // tenant/source currently degraded
+}
+
+func NewQualityTracker(db *store.DB, alerts *Alerts, cfg QualityConfig) *QualityTracker {
+	skew, _ := time.ParseDuration(cfg.SkewThreshold)
+	return &QualityTracker{
+		db:      db,
+		alerts:  alerts,
+		cfg:     cfg,
+		skew:    skew,
+		start:   time.Now(),
+		current: map[string]map[string]*qualityCounters{},
+		history: map[string][]QualityWindow{},
+		alerted: map[string]bool{},
+	}
+}
+
+// Observe records an entry after the pipeline ran on it. sentTime reports
+// whether the entry arrived with a timestamp and received is when it arrived;
+// failed lists optional stages that failed and err is set when the entry was
+// rejected.
+func (t *QualityTracker) Observe(e LogEntry, sentTime bool, received time.Time, failed []string, err error) {
+	// Without a sent timestamp the parse stage may still have found one;
+	// otherwise the defaults stage stamped the entry while it was processed.
+	defaulted := !sentTime && !e.Timestamp.Before(received) && !e.Timestamp.After(time.Now())
+
+	source := e.Source
+	if source == "" {
+		source = "Unknown"
+	}
+	tenant := e.TenantID
+	if tenant == "" {
+		tenant = defaultTenant
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sources := t.current[tenant]
+	if sources == nil {
+		sources = map[string]*qualityCounters{}
+		t.current[tenant] = sources
+	}
+	c := sources[source]
+	if c == nil {
+		c = &qualityCounters{}
+		sources[source] = c
+	}
+
+	c.events++
+	if err != nil || contains(failed, "parse") {
+		c.parseFailures++
+	}
+	if err != nil {
+		return
+	}
+
+	if defaulted || e.IPAddress == "" || source == "Unknown" {
+		c.missing++
+	}
+	if e.IPAddress != "" && net.ParseIP(e.IPAddress) == nil {
+		c.invalidIP++
+	}
+	if !defaulted {
+		skew := math.Abs(received.Sub(e.Timestamp).Seconds())
+		c.timed++
+		c.skewSum += skew
+		c.skewMax = math.Max(c.skewMax, skew)
+		if skew > t.skew.Seconds() {
+			c.skewed++
+		}
+	}
+}
+
+// score converts counters into rates, a 0-100 score, and degradation
+// reasons. The score is the product of each metric's pass rate.
+func (t *QualityTracker) score(source string, c *qualityCounters) SourceQuality {
+	rate := func(n, of int) float64 {
+		if of == 0 {
+			return 0
+		}
+		return float64(n) / float64(of)
+	}
+	q := SourceQuality{
+		Source:           source,
+		Events:           c.events,
+		MissingRate:      rate(c.missing, c.events),
+		InvalidIPRate:    rate(c.invalidIP, c.events),
+		SkewedRate:       rate(c.skewed, c.timed),
+		MaxSkewSeconds:   c.skewMax,
+		ParseFailureRate: rate(c.parseFailures, c.events),
+	}
+	if c.timed > 0 {
+		q.MeanSkewSeconds = c.skewSum / float64(c.timed)
+	}
+	q.Score = 100 * (1 - q.MissingRate) * (1 - q.InvalidIPRate) * (1 - q.SkewedRate) * (1 - q.ParseFailureRate)
+	q.Score = math.Round(q.Score*10) / 10
+
+	if c.events < t.cfg.MinEvents {
+		return q
+	}
+	for _, check := range []struct {
+		name      string
+		rate, max float64
+	}{
+		{"missing fields", q.MissingRate, t.cfg.MaxMissing},
+		{"invalid IPs", q.InvalidIPRate, t.cfg.MaxInvalidIP},
+		{"timestamp skew", q.SkewedRate, t.cfg.MaxSkewed},
+		{"parse failures", q.ParseFailureRate, t.cfg.MaxParseFail},
+	} {
+		if check.rate > check.max {
+			q.Reasons = append(q.Reasons, fmt.Sprintf("%s %.1f%% > %.1f%%", check.name, 100*check.rate, 100*check.max))
+		}
+	}
+	if q.Score < t.cfg.MinScore {
+		q.Reasons = append(q.Reasons, fmt.Sprintf("score %.1f < %.1f", q.Score, t.cfg.MinScore))
+	}
+	q.Degraded = len(q.Reasons) > 0
+	return q
+}
+
+// rotate closes the current window and returns sources that became degraded
+// (per tenant) and those that recovered.
+func (t *QualityTracker) rotate() (degraded map[string][]SourceQuality, recovered []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	degraded = map[string][]SourceQuality{}
+	for tenant, sources := range t.current {
+		w := QualityWindow{Start: t.start, End: now}
+		for source, c := range sources {
+			q := t.score(source, c)
+			w.Sources = append(w.Sources, q)
+
+			key := tenant + "/" + source
+			switch {
+			case q.Degraded && !t.alerted[key]:
+				degraded[tenant] = append(degraded[tenant], q)
+			case !q.Degraded && t.alerted[key] && c.events >= t.cfg.MinEvents:
+				recovered = append(recovered, key)
+			}
+			if c.events >= t.cfg.MinEvents {
+				t.alerted[key] = q.Degraded
+			}
+		}
+		sort.Slice(w.Sources, func(i, j int) bool { return w.Sources[i].Score < w.Sources[j].Score })
+
+		h := append(t.history[tenant], w)
+		if len(h) > t.cfg.History {
+			h = h[len(h)-t.cfg.History:]
+		}
+		t.history[tenant] = h
+	}
+	t.start = now
+	t.current = map[string]map[string]*qualityCounters{}
+	return degraded, recovered
+}
+
+// Run scores every window and alerts when a source's quality degrades.
+// Alerts are edge-triggered: a source must recover before it alerts again.
+func (t *QualityTracker) Run() {
+	window, _ := time.ParseDuration(t.cfg.Window)
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		degraded, recovered := t.rotate()
+		for _, key := range recovered {
+			slog.Info("Data quality recovered", "key", key)
+		}
+		for tenant, sources := range degraded {
+			for _, q := range sources {
+				t.alerts.Raise(Alert{
+					TenantID: tenant,
+					Kind:     "data_quality",
+					Severity: SeverityWarning,
+					Title:    fmt.Sprintf("Data quality degraded for %s (score %.1f)", q.Source, q.Score),
+					Details: map[string]any{
+						"source":  q.Source,
+						"reasons": q.Reasons,
+						"quality": q,
+					},
+				})
+			}
+		}
+	}
+}
+
+// handler serves GET /api/quality: the caller's tenant's latest window, worst
+// sources first, plus each source's score history for trend charts.
+func (t *QualityTracker) handler(w http.ResponseWriter, r *http.Request) {
+	tenant := tenantFromRequest(r)
+	t.mu.Lock()
+	history := t.history[tenant]
+	t.mu.Unlock()
+
+	resp := map[string]any{"window": t.cfg.Window}
+	if len(history) == 0 {
+		resp["latest"] = nil
+		resp["history"] = map[string]any{}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	type point struct {
+		End   time.Time `json:"end"`
+		Score float64   `json:"score"`
+	}
+	trend := map[string][]point{}
+	for _, win := range history {
+		for _, q := range win.Sources {
+			trend[q.Source] = append(trend[q.Source], point{End: win.End, Score: q.Score})
+		}
+	}
+	resp["latest"] = history[len(history)-1]
+	resp["history"] = trend
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/pipeline"
+	"1logx/log_ingestor/internal/store"
+)
+
+// knownSourceCount is used to cost queries that don't filter by source.
+const knownSourceCount = 5
+
+// LogQuery describes a filtered, paginated read of the logs table.
+type LogQuery struct {
+	TenantID    string
+	From        time.Time
+	To          time.Time
+	Sources     []string
+	Severities  []Severity
+	MinSeverity Severity
+	IPAddress   string
+	Search      string
+	Fields      map[string]string // field.<key>=value
+	Labels      map[string]string // label.<key>=value
+	MinRisk     int               // only rows with risk_score >= MinRisk
+	ClusterID   int64             // only rows assigned to this log cluster
+	SortByRisk  bool              // highest risk first instead of newest first
+	Limit       int
+	Cursor      int64 // only rows after the row with this id; 0 means start from the top
+	CursorRisk  int   // with SortByRisk, the risk_score of the Cursor row
+	Force       bool
+}
+
+// EstimateCost returns a rough, unitless estimate of how much data a query
+// will scan. One unit is about one hour of logs from one source; queries
+// without selective filters (severity, IP, fields, labels) or with a
+// free-text search cost more.
+func (q LogQuery) EstimateCost() float64 {
+	hours := q.To.Sub(q.From).Hours()
+	if hours < 1 {
+		hours = 1
+	}
+	sources := float64(len(q.Sources))
+	if sources == 0 {
+		sources = knownSourceCount
+	}
+	cost := hours * sources
+	if len(q.Severities) == 0 && q.MinSeverity == SeverityInfo && q.IPAddress == "" && len(q.Fields) == 0 && len(q.Labels) == 0 && q.ClusterID == 0 {
+		cost *= 2
+	}
+	if q.Search != "" {
+		cost *= 1.5
+	}
+	return cost
+}
+
+// parseLogQuery builds a LogQuery from URL parameters, applying the
+// configured page size and default time window.
+func parseLogQuery(v url.Values, cfg APIConfig) (LogQuery, error) {
+	q := LogQuery{
+		To:        time.Now(),
+		Sources:   splitList(v.Get("source")),
+		IPAddress: v.Get("ip"),
+		Search:    v.Get("q"),
+		Limit:     cfg.DefaultPageSize,
+		Force:     v.Get("force") == "true" || v.Get("force") == "1",
+	}
+
+	for _, s := range splitList(v.Get("severity")) {
+		sev, err := ParseSeverity(s)
+		if err != nil {
+			return q, err
+		}
+		q.Severities = append(q.Severities, sev)
+	}
+	if s := v.Get("min_severity"); s != "" {
+		sev, err := ParseSeverity(s)
+		if err != nil {
+			return q, err
+		}
+		q.MinSeverity = sev
+	}
+
+	for key, vals := range v {
+		kind, name, ok := strings.Cut(key, ".")
+		if !ok || (kind != "field" && kind != "label") {
+			continue
+		}
+		if !pipeline.AttributeKeyPattern.MatchString(name) {
+			return q, fmt.Errorf("invalid %s key %q", kind, name)
+		}
+		if kind == "field" {
+			if q.Fields == nil {
+				q.Fields = map[string]string{}
+			}
+			q.Fields[name] = vals[0]
+		} else {
+			if q.Labels == nil {
+				q.Labels = map[string]string{}
+			}
+			q.Labels[name] = vals[0]
+		}
+	}
+
+	if s := v.Get("to"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return q, fmt.Errorf("invalid 'to': %v", err)
+		}
+		q.To = t
+	}
+	if s := v.Get("from"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return q, fmt.Errorf("invalid 'from': %v", err)
+		}
+		q.From = t
+	} else {
+		window, _ := time.ParseDuration(cfg.DefaultWindow)
+		q.From = q.To.Add(-window)
+	}
+	if !q.From.Before(q.To) {
+		return q, fmt.Errorf("'from' must be before 'to'")
+	}
+
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("invalid 'limit': %q", s)
+		}
+		if n > cfg.MaxPageSize {
+			n = cfg.MaxPageSize
+		}
+		q.Limit = n
+	}
+	if s := v.Get("min_risk"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > 100 {
+			return q, fmt.Errorf("invalid 'min_risk': %q (use 0-100)", s)
+		}
+		q.MinRisk = n
+	}
+	if s := v.Get("cluster"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("invalid 'cluster': %q", s)
+		}
+		q.ClusterID = n
+	}
+	switch s := v.Get("sort"); s {
+	case "", "newest":
+	case "risk":
+		q.SortByRisk = true
+	default:
+		return q, fmt.Errorf("invalid 'sort': %q (use newest or risk)", s)
+	}
+	if s := v.Get("cursor"); s != "" {
+		// Risk-sorted pages continue from "<risk_score>:<id>".
+		idPart := s
+		if q.SortByRisk {
+			risk, id, ok := strings.Cut(s, ":")
+			n, err := strconv.Atoi(risk)
+			if !ok || err != nil {
+				return q, fmt.Errorf("invalid 'cursor': %q", s)
+			}
+			q.CursorRisk, idPart = n, id
+		}
+		n, err := strconv.ParseInt(idPart, 10, 64)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("invalid 'cursor': %q", s)
+		}
+		q.Cursor = n
+	}
+	return q, nil
+}
+
+// nextCursor is the cursor of the page after one ending with last.
+func (q LogQuery) nextCursor(last LogEntry) string {
+	if q.SortByRisk {
+		return fmt.Sprintf("%d:%d", last.RiskScore, last.ID)
+	}
+	return strconv.FormatInt(last.ID, 10)
+}
+
+// splitList splits a comma-separated parameter, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// queryLogs runs q against the logs table, newest first.
+func queryLogs(db *store.DB, q LogQuery) ([]LogEntry, error) {
+	where, args := q.conditions(db)
+	order := "id DESC"
+	if q.SortByRisk {
+		order = "risk_score DESC, id DESC"
+	}
+	switch {
+	case q.Cursor > 0 && q.SortByRisk:
+		where = append(where, "(risk_score < ? OR (risk_score = ? AND id < ?))")
+		args = append(args, q.CursorRisk, q.CursorRisk, q.Cursor)
+	case q.Cursor > 0:
+		where = append(where, "id < ?")
+		args = append(args, q.Cursor)
+	}
+	args = append(args, q.Limit)
+
+	rows, err := db.Query(`
+		SELECT `+logColumns+`
+		FROM logs
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY `+order+`
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanLogs(rows)
+}
+
+// conditions returns the WHERE clauses and arguments for q's filters in db's
+// dialect, excluding pagination.
+func (q LogQuery) conditions(db store.Store) ([]string, []any) {
+	where := []string{"tenant_id = ?", "deleted_at IS NULL", "timestamp >= ?", "timestamp < ?"}
+	args := []any{q.TenantID, q.From, q.To}
+
+	if len(q.Sources) > 0 {
+		where = append(where, "source IN ("+placeholders(len(q.Sources))+")")
+		for _, s := range q.Sources {
+			args = append(args, s)
+		}
+	}
+	if len(q.Severities) > 0 {
+		where = append(where, "severity IN ("+placeholders(len(q.Severities))+")")
+		for _, s := range q.Severities {
+			args = append(args, s)
+		}
+	}
+	if q.MinSeverity > SeverityInfo {
+		var levels []Severity
+		for _, sev := range AllSeverities {
+			if sev >= q.MinSeverity {
+				levels = append(levels, sev)
+			}
+		}
+		where = append(where, "severity IN ("+placeholders(len(levels))+")")
+		for _, sev := range levels {
+			args = append(args, sev)
+		}
+	}
+	if q.IPAddress != "" {
+		where = append(where, "ip_address = ?")
+		args = append(args, q.IPAddress)
+	}
+	if q.Search != "" {
+		where = append(where, "message LIKE ?")
+		args = append(args, "%"+q.Search+"%")
+	}
+	for k, v := range q.Fields {
+		where = append(where, db.JSONText("fields")+" = ?")
+		args = append(args, jsonPath(k), v)
+	}
+	for k, v := range q.Labels {
+		where = append(where, db.JSONText("labels")+" = ?")
+		args = append(args, jsonPath(k), v)
+	}
+	if q.MinRisk > 0 {
+		where = append(where, "risk_score >= ?")
+		args = append(args, q.MinRisk)
+	}
+	if q.ClusterID > 0 {
+		where = append(where, "cluster_id = ?")
+		args = append(args, q.ClusterID)
+	}
+	return where, args
+}
+
+// logColumns are the columns scanLogs expects, in order.
+const logColumns = "id, tenant_id, timestamp, source, severity, message, ip_address, fields, labels, risk_score"
+
+// scanLogs reads logColumns rows into entries and closes rows.
+func scanLogs(rows *sql.Rows) ([]LogEntry, error) {
+	defer rows.Close()
+
+	logs := []LogEntry{}
+	for rows.Next() {
+		e, err := scanLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, e)
+	}
+	return logs, rows.Err()
+}
+
+// scanLog reads the current logColumns row, followed by any extra columns
+// into extra.
+func scanLog(rows *sql.Rows, extra ...any) (LogEntry, error) {
+	var (
+		e      LogEntry
+		fields []byte
+		labels []byte
+	)
+	dest := append([]any{&e.ID, &e.TenantID, &e.Timestamp, &e.Source, &e.Severity, &e.Message, &e.IPAddress, &fields, &labels, &e.RiskScore}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return e, err
+	}
+	var err error
+	if e.Fields, err = store.DecodeFields(fields); err != nil {
+		return e, err
+	}
+	e.Labels, err = store.DecodeFields(labels)
+	return e, err
+}
+
+// jsonPath builds a JSON path for a validated attribute key.
+func jsonPath(key string) string {
+	return `$."` + key + `"`
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// --- HTTP helpers ---
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("Failed to write response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// logsHandler serves GET /api/logs. Queries whose estimated cost exceeds
+// cfg.MaxQueryCost are rejected unless the caller passes force=true.
+func logsHandler(db *store.DB, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		q, err := parseLogQuery(r.URL.Query(), cfg)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		q.TenantID = tenantFromRequest(r)
+
+		cost := q.EstimateCost()
+		if cost > cfg.MaxQueryCost {
+			if !q.Force {
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+					"error":          "query too expensive; narrow the time range, add filters, or pass force=true",
+					"estimated_cost": cost,
+					"max_cost":       cfg.MaxQueryCost,
+				})
+				return
+			}
+			slog.Warn("Forced expensive query", "remote", r.RemoteAddr, "cost", cost, "max_cost", cfg.MaxQueryCost)
+		}
+
+		logs, err := queryLogs(db, q)
+		if err != nil {
+			slog.Error("Log query failed", "err", err)
+			writeError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+
+		resp := map[string]any{
+			"logs":           logs,
+			"estimated_cost": cost,
+		}
+		if len(logs) == q.Limit {
+			resp["next_cursor"] = q.nextCursor(logs[len(logs)-1])
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
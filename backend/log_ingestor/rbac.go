@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Roles, from least to most privileged. Each role can do everything the
+// ones before it can.
+const (
+	roleViewer  = "viewer"  // read-only stream, queries, and reports
+	roleAnalyst = "analyst" // also manages incidents, findings, and exports
+	roleAdmin   = "admin"   // also manages retention, holds, and ingest controls
+	// rolePlatform also manages what every tenant shares: config reloads,
+	// the running config, and pausing inputs.
+	rolePlatform = "platform"
+)
+
+var roleRank = map[string]int{roleViewer: 1, roleAnalyst: 2, roleAdmin: 3, rolePlatform: 4}
+
+func validRole(role string) bool { return roleRank[role] > 0 }
+
+// requireRole rejects requests whose credential's role ranks below role.
+func requireRole(cfg RBACConfig, role string, next http.Handler) http.Handler {
+	if !cfg.Enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := keyFromContext(r.Context())
+		if key == nil {
+			writeError(w, http.StatusUnauthorized, errMissingKey.Error())
+			return
+		}
+		if roleRank[key.Role] < roleRank[role] {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("requires the %s role", role))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// jwtLeeway tolerates clock skew between the issuer and this server.
+const jwtLeeway = time.Minute
+
+var errInvalidToken = errors.New("invalid token")
+
+type jwtVerifier struct {
+	cfg    JWTConfig
+	rsaKey *rsa.PublicKey
+}
+
+// newJWTVerifier returns nil when no verification key is configured.
+func newJWTVerifier(cfg JWTConfig) (*jwtVerifier, error) {
+	v := &jwtVerifier{cfg: cfg}
+	if cfg.PublicKey != "" {
+		data, err := os.ReadFile(cfg.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("jwt public key: %w", err)
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("jwt public key: no PEM block in %s", cfg.PublicKey)
+		}
+		var pub any
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("jwt public key: %w", err)
+			}
+			pub = cert.PublicKey
+		} else if pub, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("jwt public key: %w", err)
+		}
+		var ok bool
+		if v.rsaKey, ok = pub.(*rsa.PublicKey); !ok {
+			return nil, errors.New("jwt public key: only RSA keys are supported")
+		}
+	}
+	if cfg.Secret == "" && v.rsaKey == nil {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// looksLikeJWT tells tokens apart from API key secrets.
+func looksLikeJWT(secret string) bool {
+	return strings.Count(secret, ".") == 2 && !strings.HasPrefix(secret, "1lx_")
+}
+
+// verify checks token's signature and claims and returns its principal as
+// an ephemeral APIKey (ID 0), so tenancy, rate limits, and roles treat
+// tokens and keys alike.
+func (v *jwtVerifier) verify(token string) (*APIKey, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, errInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	switch {
+	case header.Alg == "HS256" && v.cfg.Secret != "":
+		mac := hmac.New(sha256.New, []byte(v.cfg.Secret))
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return nil, errInvalidToken
+		}
+	case header.Alg == "RS256" && v.rsaKey != nil:
+		sum := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(v.rsaKey, crypto.SHA256, sum[:], sig) != nil {
+			return nil, errInvalidToken
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported alg %q", errInvalidToken, header.Alg)
+	}
+
+	var claims map[string]any
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, errInvalidToken
+	}
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); !ok || now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return nil, fmt.Errorf("%w: expired or missing exp", errInvalidToken)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("%w: not yet valid", errInvalidToken)
+	}
+	if v.cfg.Issuer != "" && claims["iss"] != v.cfg.Issuer {
+		return nil, fmt.Errorf("%w: wrong issuer", errInvalidToken)
+	}
+	if v.cfg.Audience != "" && !contains(claimStrings(claims["aud"]), v.cfg.Audience) {
+		return nil, fmt.Errorf("%w: wrong audience", errInvalidToken)
+	}
+
+	sub, _ := claims["sub"].(string)
+	tenant, _ := claims[v.cfg.TenantClaim].(string)
+	if tenant == "" {
+		tenant = defaultTenant
+	}
+	role := roleViewer
+	for _, r := range claimStrings(claims[v.cfg.RoleClaim]) {
+		if roleRank[r] > roleRank[role] {
+			role = r
+		}
+	}
+	return &APIKey{Name: sub, Prefix: "jwt:" + sub, TenantID: tenant, Role: role}, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// claimStrings reads a claim that may be a string or a list of strings.
+func claimStrings(v any) []string {
+	switch x := v.(type) {
+	case string:
+		return []string{x}
+	case []any:
+		var out []string
+		for _, e := range x {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
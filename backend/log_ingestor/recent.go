@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// eventRing is a fixed-capacity ring of log entries, overwriting the oldest.
+type eventRing struct {
+	buf  []LogEntry
+	next int
+	full bool
+}
+
+func (r *eventRing) add(e LogEntry) {
+	r.buf[r.next] = e
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *eventRing) len() int {
+	if r.full {
+		return len(r.buf)
+	}
+	return r.next
+}
+
+// each calls fn on entries newest first until it returns false.
+func (r *eventRing) each(fn func(LogEntry) bool) {
+	for i := 1; i <= r.len(); i++ {
+		if !fn(r.buf[(r.next-i+len(r.buf))%len(r.buf)]) {
+			return
+		}
+	}
+}
+
+// RecentEvents keeps the last stored events of each tenant.
+type RecentEvents struct {
+	mu    sync.RWMutex
+	size  int
+	rings map[string]*eventRing
+}
+
+func NewRecentEvents(cfg RecentConfig) *RecentEvents {
+	return &RecentEvents{size: cfg.Size, rings: map[string]*eventRing{}}
+}
+
+func (r *RecentEvents) add(e LogEntry) {
+	tenant := e.TenantID
+	if tenant == "" {
+		tenant = defaultTenant
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ring := r.rings[tenant]
+	if ring == nil {
+		ring = &eventRing{buf: make([]LogEntry, r.size)}
+		r.rings[tenant] = ring
+	}
+	ring.add(e)
+}
+
+// RecentQuery selects events from the ring. Zero times leave the window open.
+type RecentQuery struct {
+	Filter   StreamFilter
+	From, To time.Time
+	Cursor   int64 // only events with id < Cursor
+	Limit    int
+}
+
+// query returns the tenant's buffered events matching q, newest first.
+func (r *RecentEvents) query(tenant string, q RecentQuery) []LogEntry {
+	out := []LogEntry{}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ring := r.rings[tenant]
+	if ring == nil {
+		return out
+	}
+	ring.each(func(e LogEntry) bool {
+		switch {
+		case q.Cursor > 0 && e.ID >= q.Cursor,
+			!q.From.IsZero() && e.Timestamp.Before(q.From),
+			!q.To.IsZero() && !e.Timestamp.Before(q.To),
+			!q.Filter.matches(e):
+			return true
+		}
+		out = append(out, e)
+		return len(out) < q.Limit
+	})
+	return out
+}
+
+// buffered reports how many events the tenant's ring holds.
+func (r *RecentEvents) buffered(tenant string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if ring := r.rings[tenant]; ring != nil {
+		return ring.len()
+	}
+	return 0
+}
+
+// recentHandler serves GET /api/logs/recent: the same filters as GET
+// /api/logs, answered from memory. Only the last events of each tenant are
+// kept, so "buffered" reports how many the ring held to search.
+func recentHandler(cfg APIConfig, recent *RecentEvents) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v := r.URL.Query()
+		lq, err := parseLogQuery(v, cfg)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if lq.ClusterID > 0 {
+			// Logs are clustered in the background, after they reach the ring.
+			writeError(w, http.StatusBadRequest, "'cluster' isn't supported for recent logs; use /api/logs")
+			return
+		}
+		q := RecentQuery{
+			Filter: StreamFilter{
+				Sources:    lq.Sources,
+				Severities: lq.Severities,
+				IPAddress:  lq.IPAddress,
+				Search:     lq.Search,
+				Fields:     lq.Fields,
+				Labels:     lq.Labels,
+				MinRisk:    lq.MinRisk,
+			},
+			Cursor: lq.Cursor,
+			Limit:  lq.Limit,
+		}
+		if lq.MinSeverity > SeverityInfo {
+			q.Filter.MinSeverity = &lq.MinSeverity
+		}
+		// Unlike the table, the ring isn't windowed unless asked to be.
+		if v.Has("from") {
+			q.From = lq.From
+		}
+		if v.Has("to") {
+			q.To = lq.To
+		}
+
+		tenant := tenantFromRequest(r)
+		logs := recent.query(tenant, q)
+		resp := map[string]any{"logs": logs, "buffered": recent.buffered(tenant)}
+		if len(logs) == q.Limit {
+			resp["next_cursor"] = logs[len(logs)-1].ID
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
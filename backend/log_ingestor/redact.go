@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// builtinRedactions are the rules available by name. Patterns favour
+// precision; credit card candidates must also pass the Luhn check.
+var builtinRedactions = map[string]struct {
+	pattern string
+	valid   func(string) bool
+}{
+	"email":       {pattern: `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`},
+	"credit_card": {pattern: `\b(?:\d[ \-]?){12,18}\d\b`, valid: luhnValid},
+	"us_ssn":      {pattern: `\b\d{3}-\d{2}-\d{4}\b`},
+	"uk_nino":     {pattern: `\b[A-CEGHJ-PR-TW-Z]{2} ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`},
+	"iban":        {pattern: `\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,4})?\b`},
+	"phone":       {pattern: `\+\d{1,3}[ \-.]?\(?\d{1,4}\)?(?:[ \-.]?\d{2,4}){2,4}\b`},
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum.
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+type compiledRedaction struct {
+	name        string
+	re          *regexp.Regexp
+	replacement string
+	valid       func(string) bool // nil: every match is redacted
+}
+
+// Redactor applies the redaction rules and counts matches per tenant and
+// rule for the audit log.
+type Redactor struct {
+	rulesMu    sync.RWMutex // guards rules and maskFields, which reload swaps
+	rules      []compiledRedaction
+	maskFields map[string]bool
+
+	mu     sync.Mutex
+	counts map[string]map[string]int64 // tenant -> rule -> redactions
+}
+
+func NewRedactor(cfg RedactionConfig) (*Redactor, error) {
+	r := &Redactor{counts: map[string]map[string]int64{}}
+	return r, r.reload(cfg)
+}
+
+// reload compiles cfg's rules and swaps them in. Invalid rules leave the
+// current ones in place.
+func (r *Redactor) reload(cfg RedactionConfig) error {
+	var rules []compiledRedaction
+	maskFields := map[string]bool{}
+	if !cfg.Enabled {
+		cfg = RedactionConfig{}
+	}
+	for _, name := range cfg.Builtins {
+		b, ok := builtinRedactions[name]
+		if !ok {
+			return fmt.Errorf("unknown builtin redaction %q", name)
+		}
+		rules = append(rules, compiledRedaction{
+			name:        name,
+			re:          regexp.MustCompile(b.pattern),
+			replacement: "[REDACTED:" + name + "]",
+			valid:       b.valid,
+		})
+	}
+	for _, rule := range cfg.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("redaction rule %q needs a name", rule.Pattern)
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("redaction rule %s: %w", rule.Name, err)
+		}
+		if rule.Replacement == "" {
+			rule.Replacement = "[REDACTED:" + rule.Name + "]"
+		}
+		rules = append(rules, compiledRedaction{name: rule.Name, re: re, replacement: rule.Replacement})
+	}
+	for _, key := range cfg.MaskFields {
+		maskFields[strings.ToLower(key)] = true
+	}
+	r.rulesMu.Lock()
+	r.rules, r.maskFields = rules, maskFields
+	r.rulesMu.Unlock()
+	return nil
+}
+
+// text masks every rule's matches in s, adding to hits per rule.
+func (r *Redactor) text(s string, hits map[string]int64) string {
+	r.rulesMu.RLock()
+	rules := r.rules
+	r.rulesMu.RUnlock()
+	for _, rule := range rules {
+		s = rule.re.ReplaceAllStringFunc(s, func(match string) string {
+			if rule.valid != nil && !rule.valid(match) {
+				return match
+			}
+			hits[rule.name]++
+			return rule.replacement
+		})
+	}
+	return s
+}
+
+// redact masks PII in entry's message, fields, and labels.
+func (r *Redactor) redact(entry *LogEntry) {
+	r.rulesMu.RLock()
+	maskFields := r.maskFields
+	r.rulesMu.RUnlock()
+	hits := map[string]int64{}
+	entry.Message = r.text(entry.Message, hits)
+	r.attributes(entry.Fields, maskFields, hits)
+	r.attributes(entry.Labels, maskFields, hits)
+	if len(hits) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tenant := entry.TenantID
+	if tenant == "" {
+		tenant = defaultTenant
+	}
+	if r.counts[tenant] == nil {
+		r.counts[tenant] = map[string]int64{}
+	}
+	for rule, n := range hits {
+		r.counts[tenant][rule] += n
+	}
+}
+
+// attributes masks the values of attrs whose keys are in maskFields, and
+// PII in the rest.
+func (r *Redactor) attributes(attrs map[string]string, maskFields map[string]bool, hits map[string]int64) {
+	for k, v := range attrs {
+		if maskFields[strings.ToLower(k)] {
+			if v != "" {
+				attrs[k] = "[REDACTED]"
+				hits["mask_fields"]++
+			}
+			continue
+		}
+		attrs[k] = r.text(v, hits)
+	}
+}
+
+// redactPII is the redact pipeline stage. It runs right after parsing so
+// fields extracted from the message are covered too. r has no rules while
+// redaction is disabled.
+func (r *Redactor) redactPII(entry *LogEntry) error {
+	if r != nil {
+		r.redact(entry)
+	}
+	return nil
+}
+
+// redactText masks PII in s with the configured rules, for raw messages kept
+// outside the pipeline (e.g. canary samples). Matches aren't counted.
+func (r *Redactor) redactText(s string) string {
+	if r == nil {
+		return s
+	}
+	return r.text(s, map[string]int64{})
+}
+
+// Run writes the redaction counts to the audit log every interval, one
+// record per tenant with redactions.
+func (r *Redactor) Run(db *store.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		r.mu.Lock()
+		counts := r.counts
+		r.counts = map[string]map[string]int64{}
+		r.mu.Unlock()
+
+		for tenant, rules := range counts {
+			var total int64
+			for _, n := range rules {
+				total += n
+			}
+			recordAudit(db, tenant, "redaction", "pii.redact", map[string]any{"rules": rules, "total": total})
+			slog.Info("Redacted PII matches", "matches", total, "tenant", tenant)
+		}
+	}
+}
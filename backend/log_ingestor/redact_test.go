@@ -0,0 +1,65 @@
+package main
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	r, err := NewRedactor(RedactionConfig{
+		Enabled:    true,
+		Rules:      []RedactionRule{{Name: "api_token", Pattern: `(?i)bearer [a-z0-9._\-]{20,}`}},
+		MaskFields: []string{"Password"},
+	}.WithDefaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		entry LogEntry
+		want  LogEntry
+	}{
+		{
+			name:  "message",
+			entry: LogEntry{Message: "login by alice@example.com with card 4111 1111 1111 1111"},
+			want:  LogEntry{Message: "login by [REDACTED:email] with card [REDACTED:credit_card]"},
+		},
+		{
+			name:  "card failing luhn kept",
+			entry: LogEntry{Message: "order 4111 1111 1111 1112"},
+			want:  LogEntry{Message: "order 4111 1111 1111 1112"},
+		},
+		{
+			name: "fields",
+			entry: LogEntry{Message: "ok", Fields: map[string]string{
+				"user": "bob@example.com", "password": "hunter2", "auth": "Bearer abcdefghijklmnopqrstuvwxyz",
+			}},
+			want: LogEntry{Message: "ok", Fields: map[string]string{
+				"user": "[REDACTED:email]", "password": "[REDACTED]", "auth": "[REDACTED:api_token]",
+			}},
+		},
+		{
+			name:  "labels",
+			entry: LogEntry{Message: "ok", Labels: map[string]string{"owner": "carol@example.com", "PASSWORD": "x", "ssn": "123-45-6789", "env": "prod"}},
+			want:  LogEntry{Message: "ok", Labels: map[string]string{"owner": "[REDACTED:email]", "PASSWORD": "[REDACTED]", "ssn": "[REDACTED:us_ssn]", "env": "prod"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := tt.entry
+			if err := r.redactPII(&entry); err != nil {
+				t.Fatal(err)
+			}
+			if entry.Message != tt.want.Message {
+				t.Errorf("message = %q, want %q", entry.Message, tt.want.Message)
+			}
+			if !maps.Equal(entry.Fields, tt.want.Fields) {
+				t.Errorf("fields = %v, want %v", entry.Fields, tt.want.Fields)
+			}
+			if !maps.Equal(entry.Labels, tt.want.Labels) {
+				t.Errorf("labels = %v, want %v", entry.Labels, tt.want.Labels)
+			}
+		})
+	}
+}
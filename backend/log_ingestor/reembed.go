@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"1logx/log_ingestor/internal/pipeline"
+	"1logx/log_ingestor/internal/store"
+)
+
+const (
+	// staleJobAge is how long a running job can go without progress before
+	// it's taken to have died with its process, and can be resumed.
+	staleJobAge = 5 * time.Minute
+	// jobProgressInterval is how often a running job logs its progress.
+	jobProgressInterval = 30 * time.Second
+)
+
+var (
+	errJobRunning = errors.New("a re-embedding job is already running")
+	errNoVectors  = errors.New("this database stores no embeddings")
+)
+
+// MaintenanceJob is a row of maintenance_jobs.
+type MaintenanceJob struct {
+	ID         int64      `json:"id"`
+	Kind       string     `json:"kind"`
+	Status     string     `json:"status"`
+	Model      string     `json:"model,omitempty"`
+	Scope      string     `json:"scope,omitempty"`
+	Total      int64      `json:"total"`
+	Processed  int64      `json:"processed"`
+	Percent    float64    `json:"percent"`
+	CursorID   int64      `json:"cursor_id"`
+	MaxID      int64      `json:"max_id"`
+	Error      string     `json:"error,omitempty"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+const jobColumns = `id, kind, status, model, scope, total, processed, cursor_id, max_id, error, created_by, started_at, updated_at, finished_at`
+
+func scanJob(row interface{ Scan(...any) error }) (MaintenanceJob, error) {
+	var (
+		j                      MaintenanceJob
+		model, scope, msg, who sql.NullString
+		finished               sql.NullTime
+	)
+	err := row.Scan(&j.ID, &j.Kind, &j.Status, &model, &scope, &j.Total, &j.Processed, &j.CursorID, &j.MaxID, &msg, &who, &j.StartedAt, &j.UpdatedAt, &finished)
+	j.Model, j.Scope, j.Error, j.CreatedBy = model.String, scope.String, msg.String, who.String
+	j.FinishedAt = timePtr(finished)
+	switch {
+	case j.Status == "completed":
+		j.Percent = 100
+	case j.Total > 0:
+		j.Percent = min(float64(j.Processed)/float64(j.Total)*100, 100)
+	}
+	return j, err
+}
+
+func loadJob(db *store.DB, id int64) (MaintenanceJob, error) {
+	return scanJob(db.QueryRow(`SELECT `+jobColumns+` FROM maintenance_jobs WHERE id = ?`, id))
+}
+
+// listJobs returns the newest jobs first, of kind if it's set.
+func listJobs(db *store.DB, kind string, limit int) ([]MaintenanceJob, error) {
+	query, args := `SELECT `+jobColumns+` FROM maintenance_jobs`, []any{}
+	if kind != "" {
+		query += ` WHERE kind = ?`
+		args = append(args, kind)
+	}
+	rows, err := db.Query(query+` ORDER BY id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := []MaintenanceJob{}
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// Reembedder recomputes the embeddings of logs stored without one, or with
+// another model's after embedding.model changes, in rate-limited batches.
+// Only one job runs at a time; a job that stops partway resumes where it
+// left off.
+type Reembedder struct {
+	db       *store.DB
+	embedder Embedder
+	model    string
+	cfg      EmbeddingBackfillConfig
+	running  atomic.Bool
+}
+
+func NewReembedder(db *store.DB, embedder Embedder, cfg EmbeddingConfig) *Reembedder {
+	return &Reembedder{db: db, embedder: embedder, model: cfg.ModelID(), cfg: cfg.Backfill}
+}
+
+// staleFilter matches the logs a job in scope recomputes: with scope all,
+// every log; otherwise those missing an embedding of the job's model.
+func staleFilter(scope string) string {
+	if scope == "all" {
+		return ""
+	}
+	return ` AND (embedding IS NULL OR embedding_model IS NULL OR embedding_model <> ?)`
+}
+
+// start records a new job over the logs stored so far and claims it for
+// this process. all recomputes every embedding rather than only stale ones.
+func (re *Reembedder) start(all bool, actor string) (MaintenanceJob, error) {
+	if !re.db.Vectors() {
+		return MaintenanceJob{}, errNoVectors
+	}
+	if !re.running.CompareAndSwap(false, true) {
+		return MaintenanceJob{}, errJobRunning
+	}
+	job, err := re.create(all, actor)
+	if err != nil {
+		re.running.Store(false)
+	}
+	return job, err
+}
+
+func (re *Reembedder) create(all bool, actor string) (MaintenanceJob, error) {
+	var active int
+	err := re.db.QueryRow(`SELECT COUNT(*) FROM maintenance_jobs WHERE kind = 'reembed' AND status = 'running' AND updated_at > ?`,
+		time.Now().Add(-staleJobAge)).Scan(&active)
+	if err != nil {
+		return MaintenanceJob{}, err
+	}
+	if active > 0 {
+		// Another replica's job.
+		return MaintenanceJob{}, errJobRunning
+	}
+
+	scope := "stale"
+	if all {
+		scope = "all"
+	}
+	var maxID int64
+	if err := re.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM logs`).Scan(&maxID); err != nil {
+		return MaintenanceJob{}, err
+	}
+	args := []any{maxID}
+	if scope == "stale" {
+		args = append(args, re.model)
+	}
+	var total int64
+	err = re.db.QueryRow(`SELECT COUNT(*) FROM logs WHERE id <= ? AND deleted_at IS NULL`+staleFilter(scope), args...).Scan(&total)
+	if err != nil {
+		return MaintenanceJob{}, err
+	}
+
+	now := time.Now()
+	id, err := re.db.Insert(`
+		INSERT INTO maintenance_jobs (kind, status, model, scope, total, processed, cursor_id, max_id, created_by, started_at, updated_at)
+		VALUES ('reembed', 'running', ?, ?, ?, 0, 0, ?, ?, ?, ?)`,
+		re.model, scope, total, maxID, nullString(actor), now, now,
+	)
+	if err != nil {
+		return MaintenanceJob{}, err
+	}
+	return loadJob(re.db, id)
+}
+
+// resume claims a job that failed, or whose process stopped, to carry on
+// from its cursor.
+func (re *Reembedder) resume(id int64) (MaintenanceJob, error) {
+	if !re.db.Vectors() {
+		return MaintenanceJob{}, errNoVectors
+	}
+	if !re.running.CompareAndSwap(false, true) {
+		return MaintenanceJob{}, errJobRunning
+	}
+	job, err := re.reopen(id)
+	if err != nil {
+		re.running.Store(false)
+	}
+	return job, err
+}
+
+func (re *Reembedder) reopen(id int64) (MaintenanceJob, error) {
+	job, err := loadJob(re.db, id)
+	if err != nil {
+		return job, err
+	}
+	switch {
+	case job.Kind != "reembed":
+		return job, fmt.Errorf("job %d is a %s job", id, job.Kind)
+	case job.Status == "completed":
+		return job, fmt.Errorf("job %d already completed", id)
+	case job.Status == "running" && time.Since(job.UpdatedAt) < staleJobAge:
+		return job, errJobRunning
+	case job.Model != re.model:
+		return job, fmt.Errorf("job %d computed %s embeddings, but embedding is now %s; start a new job", id, job.Model, re.model)
+	}
+	_, err = re.db.Exec(`UPDATE maintenance_jobs SET status = 'running', error = NULL, updated_at = ? WHERE id = ?`, time.Now(), id)
+	if err != nil {
+		return job, err
+	}
+	return loadJob(re.db, id)
+}
+
+// run processes a job claimed by start or resume until it's done, marking
+// it completed or failed.
+func (re *Reembedder) run(ctx context.Context, job MaintenanceJob) error {
+	defer re.running.Store(false)
+	slog.Info("Re-embedding logs", "job", job.ID, "model", job.Model, "scope", job.Scope, "total", job.Total, "processed", job.Processed)
+
+	err := re.process(ctx, &job)
+	now := time.Now()
+	if err != nil {
+		slog.Error("Re-embedding job failed", "job", job.ID, "processed", job.Processed, "err", err)
+		if _, dbErr := re.db.Exec(`UPDATE maintenance_jobs SET status = 'failed', error = ?, updated_at = ? WHERE id = ?`, err.Error(), now, job.ID); dbErr != nil {
+			slog.Warn("Failed to record re-embedding job failure", "job", job.ID, "err", dbErr)
+		}
+		return err
+	}
+	if _, err := re.db.Exec(`UPDATE maintenance_jobs SET status = 'completed', updated_at = ?, finished_at = ? WHERE id = ?`, now, now, job.ID); err != nil {
+		return err
+	}
+	slog.Info("Re-embedding job completed", "job", job.ID, "processed", job.Processed)
+	return nil
+}
+
+type reembedRow struct {
+	id              int64
+	source, message string
+}
+
+// process embeds the job's logs a batch at a time after its cursor, at no
+// more than the configured rate. Each batch's embeddings and the job's
+// progress are committed together.
+func (re *Reembedder) process(ctx context.Context, job *MaintenanceJob) error {
+	began, embedded := time.Now(), 0
+	lastReport := began
+	for {
+		args := []any{job.CursorID, job.MaxID}
+		if job.Scope != "all" {
+			args = append(args, job.Model)
+		}
+		rows, err := re.db.QueryContext(ctx, `
+			SELECT id, source, message FROM logs
+			WHERE id > ? AND id <= ? AND deleted_at IS NULL`+staleFilter(job.Scope)+`
+			ORDER BY id
+			LIMIT ?`, append(args, re.cfg.BatchSize)...)
+		if err != nil {
+			return err
+		}
+		var batch []reembedRow
+		for rows.Next() {
+			var row reembedRow
+			if err := rows.Scan(&row.id, &row.source, &row.message); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, row)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		vectors := make([]string, len(batch))
+		for i, row := range batch {
+			// Pace the provider: embedding n may start no sooner than
+			// n/rate seconds into the run.
+			wait := time.Until(began.Add(time.Duration(float64(embedded) / re.cfg.Rate * float64(time.Second))))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			vec, err := re.embedder.Embed(ctx, pipeline.EmbeddingText(LogEntry{Source: row.source, Message: row.message}))
+			if err != nil {
+				return fmt.Errorf("embed log %d: %w", row.id, err)
+			}
+			vectors[i] = store.FormatVector(vec)
+			embedded++
+		}
+
+		if err := re.save(job, batch, vectors); err != nil {
+			return err
+		}
+		if time.Since(lastReport) >= jobProgressInterval {
+			lastReport = time.Now()
+			slog.Info("Re-embedding progress", "job", job.ID, "processed", job.Processed, "total", job.Total)
+		}
+	}
+}
+
+func (re *Reembedder) save(job *MaintenanceJob, batch []reembedRow, vectors []string) error {
+	tx, err := re.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i, row := range batch {
+		if _, err := tx.Exec(`UPDATE logs SET embedding = ?, embedding_model = ? WHERE id = ?`, vectors[i], job.Model, row.id); err != nil {
+			return fmt.Errorf("update log %d: %w", row.id, err)
+		}
+	}
+	cursor, processed := batch[len(batch)-1].id, job.Processed+int64(len(batch))
+	_, err = tx.Exec(`UPDATE maintenance_jobs SET processed = ?, cursor_id = ?, updated_at = ? WHERE id = ?`, processed, cursor, time.Now(), job.ID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	job.CursorID, job.Processed = cursor, processed
+	return nil
+}
+
+// --- HTTP Handlers ---
+
+// reembedHandler serves POST /api/admin/embeddings/reembed, which starts a
+// job, or resumes one, in the background and returns it.
+func (re *Reembedder) reembedHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		All    bool  `json:"all"`    // recompute every embedding, not only stale ones
+		Resume int64 `json:"resume"` // id of a job to resume instead
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+	}
+	var (
+		job MaintenanceJob
+		err error
+	)
+	if req.Resume > 0 {
+		job, err = re.resume(req.Resume)
+	} else {
+		job, err = re.start(req.All, requestActor(r))
+	}
+	switch {
+	case errors.Is(err, errJobRunning):
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	case errors.Is(err, errNoVectors), err != nil && job.ID != 0:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		slog.Error("Failed to start re-embedding job", "err", err)
+		writeError(w, http.StatusInternalServerError, "failed to start job")
+		return
+	}
+	recordAudit(re.db, defaultTenant, requestActor(r), "embeddings.reembed", map[string]any{"job": job.ID, "scope": job.Scope, "model": job.Model})
+	go re.run(context.Background(), job)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// jobsHandler serves GET /api/admin/jobs, optionally ?kind=reembed.
+func jobsHandler(db *store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobs, err := listJobs(db, r.URL.Query().Get("kind"), 100)
+		if err != nil {
+			slog.Error("Failed to list maintenance jobs", "err", err)
+			writeError(w, http.StatusInternalServerError, "failed to list jobs")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"jobs": jobs})
+	}
+}
+
+// jobHandler serves GET /api/admin/jobs/{id}.
+func jobHandler(db *store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid job id")
+			return
+		}
+		job, err := loadJob(db, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "job not found")
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to load maintenance job", "id", id, "err", err)
+			writeError(w, http.StatusInternalServerError, "failed to load job")
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+// reembedCommand runs a re-embedding job in the foreground, or lists jobs.
+func reembedCommand(args []string) error {
+	fs := flag.NewFlagSet("reembed", flag.ExitOnError)
+	all := fs.Bool("all", false, "recompute every embedding, not only missing or stale ones")
+	resume := fs.Int64("resume", 0, "id of a failed or interrupted job to resume")
+	status := fs.Bool("status", false, "list recent jobs and their progress instead")
+	config, db, err := commandConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *status {
+		jobs, err := listJobs(db, "reembed", 20)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSTATUS\tMODEL\tSCOPE\tPROGRESS\tSTARTED")
+		for _, j := range jobs {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d/%d (%.1f%%)\t%s\n", j.ID, j.Status, j.Model, j.Scope, j.Processed, j.Total, j.Percent, j.StartedAt.Format(time.RFC3339))
+		}
+		return tw.Flush()
+	}
+
+	cfg := config.Embedding.WithDefaults()
+	embedder, err := newEmbedder(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid embedding config: %w", err)
+	}
+	re := NewReembedder(db, embedder, cfg)
+	var job MaintenanceJob
+	if *resume > 0 {
+		job, err = re.resume(*resume)
+	} else {
+		job, err = re.start(*all, "cli")
+	}
+	if err != nil {
+		return err
+	}
+	if err := re.run(context.Background(), job); err != nil {
+		return err
+	}
+	job, err = loadJob(db, job.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Job %d: re-embedded %d logs with %s\n", job.ID, job.Processed, job.Model)
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// reloadable is a component that can apply a freshly loaded config. apply
+// must validate everything before swapping anything in, so a bad file
+// leaves the component as it was.
+type reloadable struct {
+	name  string
+	apply func(Config) error
+	files func(Config) []string // files whose changes trigger a reload; may be nil
+}
+
+// Reloader re-reads the config file and hands it to every registered
+// component. Connections, WebSocket clients, and queued entries are
+// untouched; only rules and settings are swapped.
+type Reloader struct {
+	db   *store.DB
+	path string
+
+	mu    sync.Mutex // serializes reloads and guards parts
+	parts []reloadable
+}
+
+func NewReloader(db *store.DB, path string) *Reloader {
+	return &Reloader{db: db, path: path}
+}
+
+func (r *Reloader) register(name string, apply func(Config) error, files func(Config) []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.parts = append(r.parts, reloadable{name: name, apply: apply, files: files})
+}
+
+// ReloadResult is the outcome of one reload, per component.
+type ReloadResult struct {
+	Trigger  string            `json:"trigger"`
+	Reloaded []string          `json:"reloaded"`
+	Failed   map[string]string `json:"failed,omitempty"`
+}
+
+// Reload loads the config file and applies it to every component. A config
+// that fails to parse changes nothing.
+func (r *Reloader) Reload(trigger, actor string) (ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := ReloadResult{Trigger: trigger, Reloaded: []string{}}
+	config, err := loadConfig(r.path)
+	if err != nil {
+		slog.Error("Reload failed", "trigger", trigger, "err", err)
+		return res, err
+	}
+	for _, p := range r.parts {
+		if err := p.apply(config); err != nil {
+			if res.Failed == nil {
+				res.Failed = map[string]string{}
+			}
+			res.Failed[p.name] = err.Error()
+			slog.Error("Reload failed, keeping the previous version", "part", p.name, "err", err)
+			continue
+		}
+		res.Reloaded = append(res.Reloaded, p.name)
+	}
+	slog.Info("Reloaded", "parts", res.Reloaded, "trigger", trigger)
+	// Reloads affect every tenant, so they're audited as platform actions,
+	// under the default tenant.
+	recordAudit(r.db, defaultTenant, actor, "config.reload", res)
+	return res, nil
+}
+
+// watchSIGHUP reloads whenever the process gets SIGHUP.
+func (r *Reloader) watchSIGHUP() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		r.Reload("sighup", "signal")
+	}
+}
+
+// watchFiles polls the modification times of the config file and every
+// file the components depend on, reloading when any changes. Polling keeps
+// this working on network and container volumes where inotify doesn't.
+func (r *Reloader) watchFiles(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := r.fileTimes()
+	for range ticker.C {
+		now := r.fileTimes()
+		changed := len(now) != len(last)
+		for path, t := range now {
+			if !last[path].Equal(t) {
+				changed = true
+			}
+		}
+		last = now
+		if changed {
+			r.Reload("file change", "watch")
+			last = r.fileTimes()
+		}
+	}
+}
+
+func (r *Reloader) fileTimes() map[string]time.Time {
+	times := map[string]time.Time{}
+	stat := func(path string) {
+		if info, err := os.Stat(path); err == nil {
+			times[path] = info.ModTime()
+		}
+	}
+	stat(r.path)
+	config, err := loadConfig(r.path)
+	if err != nil {
+		return times
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.parts {
+		if p.files == nil {
+			continue
+		}
+		for _, f := range p.files(config) {
+			if f != "" {
+				stat(f)
+			}
+		}
+	}
+	return times
+}
+
+// handler serves POST /api/admin/reload.
+func (r *Reloader) handler(w http.ResponseWriter, req *http.Request) {
+	res, err := r.Reload("api", requestActor(req))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := http.StatusOK
+	if len(res.Failed) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, res)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	replayBatchSize = 500
+	// maxReplayGap caps the pause between two replayed logs so quiet periods
+	// in the original timeline don't stall the replay.
+	maxReplayGap   = 5 * time.Second
+	maxReplaySpeed = 1000
+)
+
+// parseSpeed parses a replay speed such as "10x", "0.5", or "max" (no pacing,
+// returned as 0).
+func parseSpeed(s string) (float64, error) {
+	s = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "x")
+	switch s {
+	case "":
+		return 1, nil
+	case "max":
+		return 0, nil
+	}
+	speed, err := strconv.ParseFloat(s, 64)
+	if err != nil || speed <= 0 || speed > maxReplaySpeed {
+		return 0, fmt.Errorf("invalid speed %q (use e.g. 10x, up to %dx, or max)", s, maxReplaySpeed)
+	}
+	return speed, nil
+}
+
+// parseReplay validates a replay request. Unlike paged queries, replays
+// require an explicit 'from' and are subject to the same cost limit.
+func parseReplay(v url.Values, cfg APIConfig) (LogQuery, float64, error) {
+	if v.Get("from") == "" {
+		return LogQuery{}, 0, fmt.Errorf("'from' is required")
+	}
+	q, err := parseLogQuery(v, cfg)
+	if err != nil {
+		return q, 0, err
+	}
+	speed, err := parseSpeed(v.Get("speed"))
+	if err != nil {
+		return q, 0, err
+	}
+	if cost := q.EstimateCost(); cost > cfg.MaxQueryCost && !q.Force {
+		return q, 0, fmt.Errorf("replay too expensive (estimated cost %.0f > %.0f); narrow the range or pass force", cost, cfg.MaxQueryCost)
+	}
+	return q, speed, nil
+}
+
+// replayLogs emits the logs matching q in timestamp order, pacing them by
+// their original spacing divided by speed (0 = as fast as possible).
+func replayLogs(ctx context.Context, db *store.DB, q LogQuery, speed float64, emit func(LogEntry) error) (int, error) {
+	where, args := q.conditions(db)
+	var (
+		lastTS time.Time
+		lastID int64
+		prev   time.Time
+		count  int
+	)
+	for {
+		w := append([]string{}, where...)
+		a := append([]any{}, args...)
+		if lastID > 0 {
+			w = append(w, "(timestamp > ? OR (timestamp = ? AND id > ?))")
+			a = append(a, lastTS, lastTS, lastID)
+		}
+		a = append(a, replayBatchSize)
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT `+logColumns+`
+			FROM logs
+			WHERE `+strings.Join(w, " AND ")+`
+			ORDER BY timestamp, id
+			LIMIT ?`, a...)
+		if err != nil {
+			return count, err
+		}
+		batch, err := scanLogs(rows)
+		if err != nil {
+			return count, err
+		}
+
+		for _, e := range batch {
+			if !prev.IsZero() && speed > 0 {
+				gap := time.Duration(float64(e.Timestamp.Sub(prev)) / speed)
+				if gap > maxReplayGap {
+					gap = maxReplayGap
+				}
+				if gap > 0 {
+					select {
+					case <-time.After(gap):
+					case <-ctx.Done():
+						return count, ctx.Err()
+					}
+				}
+			}
+			prev = e.Timestamp
+			if err := emit(e); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if len(batch) < replayBatchSize {
+			return count, nil
+		}
+		last := batch[len(batch)-1]
+		lastTS, lastID = last.Timestamp, last.ID
+	}
+}
+
+// replayRunner runs at most one replay per WebSocket client. Live broadcast
+// delivery to the client is paused while a replay runs.
+type replayRunner struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (rr *replayRunner) start(db *store.DB, cfg APIConfig, client *wsClient, cmd wsCommand) {
+	rr.stop()
+
+	v := url.Values{}
+	for k, val := range map[string]string{
+		"from": cmd.From, "to": cmd.To, "speed": cmd.Speed,
+		"source": cmd.Source, "severity": cmd.Severity, "ip": cmd.IP,
+	} {
+		if val != "" {
+			v.Set(k, val)
+		}
+	}
+	if cmd.Force {
+		v.Set("force", "true")
+	}
+	q, speed, err := parseReplay(v, cfg)
+	if err != nil {
+		client.send(wsMessage{Type: "error", Error: err.Error()})
+		return
+	}
+	q.TenantID = client.tenantID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	rr.mu.Lock()
+	rr.cancel, rr.done = cancel, done
+	rr.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		client.paused.Store(true)
+		defer client.paused.Store(false)
+
+		client.send(wsMessage{Type: "replay_started", Data: map[string]any{"from": q.From, "to": q.To, "speed": speed}})
+		n, err := replayLogs(ctx, db, q, speed, func(e LogEntry) error { return client.send(e) })
+		switch {
+		case ctx.Err() != nil:
+			client.send(wsMessage{Type: "replay_stopped", Data: map[string]any{"sent": n}})
+		case err != nil:
+			slog.Error("Replay failed", "err", err)
+			client.send(wsMessage{Type: "error", Error: "replay failed"})
+		default:
+			client.send(wsMessage{Type: "replay_finished", Data: map[string]any{"sent": n}})
+		}
+	}()
+}
+
+// stop cancels the running replay, if any, and waits for it to exit.
+func (rr *replayRunner) stop() {
+	rr.mu.Lock()
+	cancel, done := rr.cancel, rr.done
+	rr.cancel, rr.done = nil, nil
+	rr.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// replayHandler serves GET /api/logs/replay as a dedicated WebSocket that
+// streams stored logs (bare LogEntry objects, like the live feed) and then
+// closes, so a dashboard can simply point its socket at it.
+func replayHandler(db *store.DB, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, speed, err := parseReplay(r.URL.Query(), cfg)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		q.TenantID = tenantFromRequest(r)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			slog.Warn("WebSocket upgrade failed", "err", err)
+			return
+		}
+		defer conn.Close()
+		client := newWSClient(conn, q.TenantID, replayBatchSize)
+
+		// Stop replaying when the client goes away.
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		go func() {
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					cancel()
+					return
+				}
+			}
+		}()
+
+		slog.Info("Replaying logs", "from", q.From, "to", q.To, "speed", speed)
+		n, err := replayLogs(ctx, db, q, speed, func(e LogEntry) error { return client.send(e) })
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Replay failed", "sent", n, "err", err)
+			client.send(wsMessage{Type: "error", Error: "replay failed"})
+			client.close(websocket.CloseInternalServerErr, "replay failed", wsWriteTimeout)
+			return
+		}
+		client.send(wsMessage{Type: "replay_finished", Data: map[string]any{"sent": n}})
+		client.close(websocket.CloseNormalClosure, "replay finished", wsWriteTimeout)
+	}
+}
+
+// replayCommand re-sends stored logs in timestamp order, paced by their
+// original spacing: to stdout as NDJSON, or to an ingest endpoint, e.g. to
+// seed a staging environment with a real incident.
+func replayCommand(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	filters := queryFlag{}
+	fs.Var(filters, "filter", "GET /api/logs parameter as key=value, e.g. source=sshd (repeatable)")
+	from := fs.String("from", "", "start of time range, RFC3339 (required)")
+	to := fs.String("to", "", "end of time range, RFC3339 (default now)")
+	speed := fs.String("speed", "1x", "replay speed, e.g. 10x, up to 1000x, or max")
+	tenant := fs.String("tenant", defaultTenant, "tenant to replay")
+	target := fs.String("url", "", "ingest endpoint to send logs to (default: NDJSON on stdout)")
+	key := fs.String("key", os.Getenv("LOGX_API_KEY"), "ingest API key for -url (default $LOGX_API_KEY)")
+	config, db, err := commandConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	v := url.Values(filters)
+	v.Set("from", *from)
+	if *to != "" {
+		v.Set("to", *to)
+	}
+	v.Set("speed", *speed)
+	q, sp, err := parseReplay(v, config.API.WithDefaults())
+	if err != nil {
+		return err
+	}
+	q.TenantID = *tenant
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	enc := json.NewEncoder(os.Stdout)
+	client := &http.Client{Timeout: 10 * time.Second}
+	emit := func(e LogEntry) error {
+		if *target == "" {
+			return enc.Encode(e)
+		}
+		// The receiving side assigns IDs and takes the tenant from the key.
+		e.ID, e.TenantID = 0, ""
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return benchPost(ctx, client, *target, *key, body)
+	}
+	slog.Info("Replaying logs", "from", q.From, "to", q.To, "speed", sp, "target", *target)
+	n, err := replayLogs(ctx, db, q, sp, emit)
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("replay failed after %d logs: %w", n, err)
+	}
+	slog.Info("Replay finished", "sent", n)
+	return nil
+}
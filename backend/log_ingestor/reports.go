@@ -0,0 +1,750 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/netip"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// SavedSearch is a named log query, optionally run on a cron schedule.
+type SavedSearch struct {
+	ID          int64  `json:"id"`
+	TenantID    string `json:"tenant_id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Filters are GET /api/logs parameters, e.g. severity, source, ip, q,
+	// or field.user. The time range comes from Window.
+	Filters map[string]string `json:"filters,omitempty"`
+	// Query is an optional natural-language query; results are ranked as by
+	// POST /api/search instead of newest first.
+	Query     string     `json:"query,omitempty"`
+	Window    string     `json:"window,omitempty"`   // time span a run covers, ending when it runs
+	Limit     int        `json:"limit,omitempty"`    // default and cap: max_results
+	Schedule  string     `json:"schedule,omitempty"` // cron expression; empty runs on demand only
+	Email     []string   `json:"email,omitempty"`    // report recipients
+	Webhook   string     `json:"webhook,omitempty"`  // URL the report is POSTed to as JSON
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+}
+
+// ReportRun is one execution of a saved search.
+type ReportRun struct {
+	ID          int64      `json:"id"`
+	SearchID    int64      `json:"search_id"`
+	TenantID    string     `json:"tenant_id"`
+	Trigger     string     `json:"trigger"` // schedule or manual
+	WindowStart time.Time  `json:"window_start"`
+	WindowEnd   time.Time  `json:"window_end"`
+	Matches     int        `json:"matches"`
+	Results     []LogEntry `json:"results,omitempty"`
+	Delivered   []string   `json:"delivered,omitempty"` // email, webhook
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  time.Time  `json:"finished_at"`
+}
+
+var errSearchNotFound = errors.New("saved search not found")
+
+// Reporter validates and runs saved searches and delivers their reports.
+type Reporter struct {
+	db       *store.DB
+	embedder Embedder
+	api      APIConfig
+	search   SearchConfig
+	cfg      ReportsConfig
+	loc      *time.Location
+	client   *http.Client
+}
+
+func NewReporter(db *store.DB, embedder Embedder, api APIConfig, search SearchConfig, cfg ReportsConfig) *Reporter {
+	loc, _ := time.LoadLocation(cfg.TimeZone)
+	timeout, _ := time.ParseDuration(cfg.Timeout)
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if !cfg.AllowPrivateWebhooks {
+		dialer.Control = publicAddressOnly
+	}
+	return &Reporter{
+		db: db, embedder: embedder, api: api, search: search, cfg: cfg, loc: loc,
+		client: &http.Client{Timeout: timeout, Transport: &http.Transport{DialContext: dialer.DialContext}},
+	}
+}
+
+// publicAddressOnly refuses webhook connections to loopback, private,
+// link-local (including cloud metadata endpoints), and unspecified
+// addresses. It's checked on the resolved address, so a public hostname
+// that resolves to an internal one is refused too.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsPrivate() || !ip.IsGlobalUnicast() {
+		return fmt.Errorf("webhook address %s isn't public; set reports.allow_private_webhooks to allow it", ip)
+	}
+	return nil
+}
+
+// validate checks s and fills in its defaults, returning the query a run
+// ending now would execute.
+func (rp *Reporter) validate(s *SavedSearch) (LogQuery, error) {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" || len(s.Name) > 255 {
+		return LogQuery{}, fmt.Errorf("'name' is required and at most 255 characters")
+	}
+	if strings.ContainsFunc(s.Name, unicode.IsControl) {
+		return LogQuery{}, fmt.Errorf("'name' can't contain control characters")
+	}
+	s.Query = strings.TrimSpace(s.Query)
+	if len(s.Query) > maxSearchQuery {
+		return LogQuery{}, fmt.Errorf("'query' longer than %d characters", maxSearchQuery)
+	}
+	if s.Window == "" {
+		s.Window = rp.api.DefaultWindow
+	}
+	if d, err := time.ParseDuration(s.Window); err != nil || d <= 0 {
+		return LogQuery{}, fmt.Errorf("invalid 'window': %q", s.Window)
+	}
+	if s.Limit <= 0 || s.Limit > rp.cfg.MaxResults {
+		s.Limit = rp.cfg.MaxResults
+	}
+	for _, key := range []string{"from", "to", "cursor", "limit", "sort"} {
+		if _, ok := s.Filters[key]; ok {
+			return LogQuery{}, fmt.Errorf("filter %q isn't allowed; use 'window' and 'limit'", key)
+		}
+	}
+	if s.Schedule != "" {
+		sched, err := parseCron(s.Schedule)
+		if err != nil {
+			return LogQuery{}, err
+		}
+		if sched.next(time.Now()).IsZero() {
+			return LogQuery{}, fmt.Errorf("schedule %q never runs", s.Schedule)
+		}
+	}
+	for _, addr := range s.Email {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return LogQuery{}, fmt.Errorf("invalid email address %q", addr)
+		}
+	}
+	if len(s.Email) > 0 && rp.cfg.SMTP.Host == "" {
+		return LogQuery{}, fmt.Errorf("email delivery needs reports.smtp configured")
+	}
+	if s.Webhook != "" {
+		u, err := url.Parse(s.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return LogQuery{}, fmt.Errorf("'webhook' must be an http or https URL")
+		}
+	}
+	return rp.query(*s, time.Now())
+}
+
+// query builds the LogQuery of a run of s ending at end.
+func (rp *Reporter) query(s SavedSearch, end time.Time) (LogQuery, error) {
+	window, _ := time.ParseDuration(s.Window)
+	v := url.Values{}
+	for k, val := range s.Filters {
+		v.Set(k, val)
+	}
+	v.Set("from", end.Add(-window).UTC().Format(time.RFC3339))
+	v.Set("to", end.UTC().Format(time.RFC3339))
+	q, err := parseLogQuery(v, rp.api)
+	if err != nil {
+		return q, err
+	}
+	q.TenantID, q.Limit = s.TenantID, s.Limit
+	return q, nil
+}
+
+// nextRun is when s's schedule next fires after t, or nil if it isn't
+// scheduled.
+func (rp *Reporter) nextRun(s SavedSearch, t time.Time) *time.Time {
+	if s.Schedule == "" {
+		return nil
+	}
+	sched, err := parseCron(s.Schedule)
+	if err != nil {
+		return nil
+	}
+	next := sched.next(t.In(rp.loc))
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+// savedSearchColumns are the columns scanSavedSearch expects, in order.
+const savedSearchColumns = "id, tenant_id, name, description, filters, query, time_window, result_limit, schedule, email, webhook, created_by, created_at, updated_at, next_run_at, last_run_at"
+
+func scanSavedSearch(row interface{ Scan(...any) error }) (SavedSearch, error) {
+	var (
+		s                                         SavedSearch
+		filters, email                            []byte
+		description, query, schedule, webhook, by sql.NullString
+		next, last                                sql.NullTime
+	)
+	if err := row.Scan(&s.ID, &s.TenantID, &s.Name, &description, &filters, &query, &s.Window, &s.Limit,
+		&schedule, &email, &webhook, &by, &s.CreatedAt, &s.UpdatedAt, &next, &last); err != nil {
+		return s, err
+	}
+	s.Description, s.Query, s.Schedule, s.Webhook, s.CreatedBy = description.String, query.String, schedule.String, webhook.String, by.String
+	s.NextRunAt, s.LastRunAt = timePtr(next), timePtr(last)
+	if len(filters) > 0 {
+		if err := json.Unmarshal(filters, &s.Filters); err != nil {
+			return s, fmt.Errorf("decode filters of saved search %d: %w", s.ID, err)
+		}
+	}
+	if len(email) > 0 {
+		if err := json.Unmarshal(email, &s.Email); err != nil {
+			return s, fmt.Errorf("decode email of saved search %d: %w", s.ID, err)
+		}
+	}
+	return s, nil
+}
+
+func getSavedSearch(db *store.DB, tenant string, id int64) (SavedSearch, error) {
+	s, err := scanSavedSearch(db.QueryRow(`SELECT `+savedSearchColumns+` FROM saved_searches WHERE id = ? AND tenant_id = ?`, id, tenant))
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, errSearchNotFound
+	}
+	return s, err
+}
+
+func listSavedSearches(db *store.DB, tenant string) ([]SavedSearch, error) {
+	rows, err := db.Query(`SELECT `+savedSearchColumns+` FROM saved_searches WHERE tenant_id = ? ORDER BY name`, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	searches := []SavedSearch{}
+	for rows.Next() {
+		s, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, s)
+	}
+	return searches, rows.Err()
+}
+
+// saveSearch inserts s, or replaces the saved search with s's ID.
+func (rp *Reporter) saveSearch(s *SavedSearch) error {
+	filters, _ := json.Marshal(s.Filters)
+	email, _ := json.Marshal(s.Email)
+	s.UpdatedAt = time.Now().UTC()
+	s.NextRunAt = rp.nextRun(*s, s.UpdatedAt)
+	if s.ID == 0 {
+		s.CreatedAt = s.UpdatedAt
+		var err error
+		s.ID, err = rp.db.Insert(`
+			INSERT INTO saved_searches (tenant_id, name, description, filters, query, time_window, result_limit,
+				schedule, email, webhook, created_by, created_at, updated_at, next_run_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			s.TenantID, s.Name, nullString(s.Description), string(filters), nullString(s.Query), s.Window, s.Limit,
+			nullString(s.Schedule), string(email), nullString(s.Webhook), s.CreatedBy, s.CreatedAt, s.UpdatedAt, s.NextRunAt,
+		)
+		return err
+	}
+	res, err := rp.db.Exec(`
+		UPDATE saved_searches SET name = ?, description = ?, filters = ?, query = ?, time_window = ?, result_limit = ?,
+			schedule = ?, email = ?, webhook = ?, updated_at = ?, next_run_at = ?
+		WHERE id = ? AND tenant_id = ?`,
+		s.Name, nullString(s.Description), string(filters), nullString(s.Query), s.Window, s.Limit,
+		nullString(s.Schedule), string(email), nullString(s.Webhook), s.UpdatedAt, s.NextRunAt, s.ID, s.TenantID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errSearchNotFound
+	}
+	return nil
+}
+
+func deleteSavedSearch(db *store.DB, tenant string, id int64) error {
+	res, err := db.Exec(`DELETE FROM saved_searches WHERE id = ? AND tenant_id = ?`, id, tenant)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errSearchNotFound
+	}
+	_, err = db.Exec(`DELETE FROM report_runs WHERE search_id = ? AND tenant_id = ?`, id, tenant)
+	return err
+}
+
+// Run executes due scheduled searches every interval. Each run is claimed by
+// advancing its next_run_at, so only one replica runs it.
+func (rp *Reporter) Run() {
+	interval, _ := time.ParseDuration(rp.cfg.Interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := rp.runDue(time.Now().UTC()); err != nil {
+			slog.Error("Failed to run scheduled reports", "err", err)
+		}
+	}
+}
+
+func (rp *Reporter) runDue(now time.Time) error {
+	rows, err := rp.db.Query(`
+		SELECT `+savedSearchColumns+` FROM saved_searches
+		WHERE next_run_at IS NOT NULL AND next_run_at <= ?
+		ORDER BY next_run_at LIMIT 100`, now)
+	if err != nil {
+		return err
+	}
+	var due []SavedSearch
+	for rows.Next() {
+		s, err := scanSavedSearch(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		due = append(due, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range due {
+		res, err := rp.db.Exec(`UPDATE saved_searches SET next_run_at = ?, last_run_at = ? WHERE id = ? AND next_run_at = ?`,
+			rp.nextRun(s, now), now, s.ID, *s.NextRunAt)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue // another replica claimed it
+		}
+		// A run that was missed, e.g. while every replica was down, covers
+		// the window ending now rather than catching up.
+		if _, err := rp.execute(s, "schedule", now, true); err != nil {
+			slog.Warn("Scheduled report failed", "search", s.ID, "name", s.Name, "tenant", s.TenantID, "err", err)
+		}
+	}
+	return nil
+}
+
+// execute runs s over the window ending at end, stores the run in
+// report_runs, and delivers it if deliver is set. Delivery failures are
+// recorded on the run; the returned error is the first failure.
+func (rp *Reporter) execute(s SavedSearch, trigger string, end time.Time, deliver bool) (ReportRun, error) {
+	timeout, _ := time.ParseDuration(rp.cfg.Timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	run := ReportRun{SearchID: s.ID, TenantID: s.TenantID, Trigger: trigger, StartedAt: time.Now().UTC()}
+	q, err := rp.query(s, end)
+	if err == nil {
+		run.WindowStart, run.WindowEnd = q.From, q.To
+		run.Results, err = rp.results(ctx, s, q)
+	}
+	var errs []string
+	if err != nil {
+		errs = append(errs, "query: "+err.Error())
+	}
+	run.Matches = len(run.Results)
+	if err == nil && deliver {
+		if len(s.Email) > 0 {
+			if err := rp.sendEmail(s, run); err != nil {
+				errs = append(errs, "email: "+err.Error())
+			} else {
+				run.Delivered = append(run.Delivered, "email")
+			}
+		}
+		if s.Webhook != "" {
+			if err := rp.postWebhook(ctx, s, run); err != nil {
+				errs = append(errs, "webhook: "+err.Error())
+			} else {
+				run.Delivered = append(run.Delivered, "webhook")
+			}
+		}
+	}
+	run.Error = strings.Join(errs, "; ")
+	run.FinishedAt = time.Now().UTC()
+	if err := rp.storeRun(&run); err != nil {
+		return run, fmt.Errorf("store run: %w", err)
+	}
+	if run.Error != "" {
+		return run, errors.New(run.Error)
+	}
+	slog.Info("Report run", "search", s.ID, "name", s.Name, "trigger", trigger, "matches", run.Matches, "delivered", run.Delivered)
+	return run, nil
+}
+
+func (rp *Reporter) results(ctx context.Context, s SavedSearch, q LogQuery) ([]LogEntry, error) {
+	if s.Query == "" {
+		return queryLogs(rp.db, q)
+	}
+	weights, err := rp.search.Weights.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	halfLife, _ := time.ParseDuration(rp.search.HalfLife)
+	ranked, _, err := hybridSearch(ctx, rp.db, rp.embedder, q, s.Query, weights, halfLife, rp.search.Candidates)
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]LogEntry, len(ranked))
+	for i, r := range ranked {
+		logs[i] = r.Log
+	}
+	return logs, nil
+}
+
+func (rp *Reporter) storeRun(run *ReportRun) error {
+	results, _ := json.Marshal(run.Results)
+	delivered, _ := json.Marshal(run.Delivered)
+	id, err := rp.db.Insert(`
+		INSERT INTO report_runs (search_id, tenant_id, trigger_type, window_start, window_end, matches, results, delivered, error, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.SearchID, run.TenantID, run.Trigger, run.WindowStart, run.WindowEnd, run.Matches,
+		string(results), string(delivered), nullString(run.Error), run.StartedAt, run.FinishedAt,
+	)
+	if err != nil {
+		return err
+	}
+	run.ID = id
+
+	// Prune all but the newest KeepRuns runs of the search.
+	var oldest int64
+	err = rp.db.QueryRow(`
+		SELECT id FROM report_runs WHERE search_id = ? AND tenant_id = ?
+		ORDER BY id DESC LIMIT 1 OFFSET ?`, run.SearchID, run.TenantID, rp.cfg.KeepRuns-1).Scan(&oldest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = rp.db.Exec(`DELETE FROM report_runs WHERE search_id = ? AND tenant_id = ? AND id < ?`, run.SearchID, run.TenantID, oldest)
+	return err
+}
+
+// reportRunColumns are the columns scanReportRun expects, in order; results
+// is appended when a single run is read.
+const reportRunColumns = "id, search_id, tenant_id, trigger_type, window_start, window_end, matches, delivered, error, started_at, finished_at"
+
+func scanReportRun(row interface{ Scan(...any) error }, extra ...any) (ReportRun, error) {
+	var (
+		run       ReportRun
+		delivered []byte
+		errText   sql.NullString
+	)
+	dest := append([]any{&run.ID, &run.SearchID, &run.TenantID, &run.Trigger, &run.WindowStart, &run.WindowEnd,
+		&run.Matches, &delivered, &errText, &run.StartedAt, &run.FinishedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return run, err
+	}
+	run.Error = errText.String
+	if len(delivered) > 0 {
+		json.Unmarshal(delivered, &run.Delivered)
+	}
+	return run, nil
+}
+
+// listReportRuns returns a saved search's runs, newest first, without their
+// results.
+func listReportRuns(db *store.DB, tenant string, searchID int64, limit int) ([]ReportRun, error) {
+	rows, err := db.Query(`
+		SELECT `+reportRunColumns+` FROM report_runs
+		WHERE search_id = ? AND tenant_id = ? ORDER BY id DESC LIMIT ?`, searchID, tenant, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	runs := []ReportRun{}
+	for rows.Next() {
+		run, err := scanReportRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func getReportRun(db *store.DB, tenant string, searchID, id int64) (ReportRun, error) {
+	var results []byte
+	run, err := scanReportRun(db.QueryRow(`
+		SELECT `+reportRunColumns+`, results FROM report_runs
+		WHERE id = ? AND search_id = ? AND tenant_id = ?`, id, searchID, tenant), &results)
+	if err != nil {
+		return run, err
+	}
+	if len(results) > 0 {
+		if err := json.Unmarshal(results, &run.Results); err != nil {
+			return run, fmt.Errorf("decode results of report run %d: %w", id, err)
+		}
+	}
+	return run, nil
+}
+
+// reportPayload is the JSON body POSTed to a saved search's webhook.
+type reportPayload struct {
+	Search SavedSearch `json:"search"`
+	Run    ReportRun   `json:"run"`
+}
+
+func (rp *Reporter) postWebhook(ctx context.Context, s SavedSearch, run ReportRun) error {
+	body, err := json.Marshal(reportPayload{Search: s, Run: run})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := rp.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// maxEmailLines caps the logs listed in a report email; the full results
+// are kept with the run.
+const maxEmailLines = 100
+
+func (rp *Reporter) sendEmail(s SavedSearch, run ReportRun) error {
+	c := rp.cfg.SMTP
+	var b strings.Builder
+	subject := mime.QEncoding.Encode("utf-8", fmt.Sprintf("[1L0Gx] %s: %d matching logs", s.Name, run.Matches))
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\nSubject: %s\r\n", c.From, strings.Join(s.Email, ", "), subject)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "Saved search %q, %s to %s (%s).\r\n\r\n", s.Name,
+		run.WindowStart.In(rp.loc).Format(time.RFC3339), run.WindowEnd.In(rp.loc).Format(time.RFC3339), rp.cfg.TimeZone)
+	for i, e := range run.Results {
+		if i == maxEmailLines {
+			fmt.Fprintf(&b, "\r\n... and %d more; see run %d of saved search %d.\r\n", len(run.Results)-i, run.ID, s.ID)
+			break
+		}
+		fmt.Fprintf(&b, "%s  %-8s  %-12s  %-15s  %s\r\n", e.Timestamp.In(rp.loc).Format(time.DateTime), e.Severity, e.Source, e.IPAddress,
+			strings.ReplaceAll(e.Message, "\n", " "))
+	}
+	if run.Matches == 0 {
+		b.WriteString("No logs matched.\r\n")
+	}
+
+	var auth smtp.Auth
+	if c.Username != "" {
+		auth = smtp.PlainAuth("", c.Username, c.Password, c.Host)
+	}
+	return smtp.SendMail(c.Host+":"+strconv.Itoa(c.Port), auth, c.From, s.Email, []byte(b.String()))
+}
+
+// --- HTTP Handlers ---
+
+func searchID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid "+strings.ReplaceAll(name, "_", " "))
+		return 0, false
+	}
+	return id, true
+}
+
+// writeSearchError maps saved search errors from db onto responses.
+func writeSearchError(w http.ResponseWriter, db store.Store, op string, err error) {
+	switch {
+	case errors.Is(err, errSearchNotFound), errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, "not found")
+	case db.IsDuplicateKey(err):
+		writeError(w, http.StatusConflict, "a saved search with that name already exists")
+	default:
+		slog.Error("Saved search operation failed", "op", op, "err", err)
+		writeError(w, http.StatusInternalServerError, "failed to "+op)
+	}
+}
+
+// savedSearchesHandler serves GET and POST /api/searches.
+func (rp *Reporter) savedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	tenant := tenantFromRequest(r)
+	if r.Method == http.MethodGet {
+		searches, err := listSavedSearches(rp.db, tenant)
+		if err != nil {
+			writeSearchError(w, rp.db, "list saved searches", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, searches)
+		return
+	}
+
+	var s SavedSearch
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&s); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	s.ID, s.TenantID, s.CreatedBy = 0, tenant, requestActor(r)
+	if !rp.checkSearch(w, &s) {
+		return
+	}
+	if err := rp.saveSearch(&s); err != nil {
+		writeSearchError(w, rp.db, "save search", err)
+		return
+	}
+	recordAudit(rp.db, tenant, s.CreatedBy, "search.create", map[string]any{"id": s.ID, "name": s.Name, "schedule": s.Schedule})
+	writeJSON(w, http.StatusCreated, s)
+}
+
+// checkSearch validates s, rejecting queries too expensive to save unless
+// they set force like GET /api/logs.
+func (rp *Reporter) checkSearch(w http.ResponseWriter, s *SavedSearch) bool {
+	q, err := rp.validate(s)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if cost := q.EstimateCost(); cost > rp.api.MaxQueryCost && !q.Force {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error":          "saved search too expensive; shorten the window, add filters, or set the force filter",
+			"estimated_cost": cost,
+			"max_cost":       rp.api.MaxQueryCost,
+		})
+		return false
+	}
+	return true
+}
+
+// savedSearchHandler serves GET, PUT, and DELETE /api/searches/{id}. PUT
+// replaces the saved search.
+func (rp *Reporter) savedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := searchID(w, r, "id")
+	if !ok {
+		return
+	}
+	tenant, actor := tenantFromRequest(r), requestActor(r)
+	current, err := getSavedSearch(rp.db, tenant, id)
+	if err != nil {
+		writeSearchError(w, rp.db, "load saved search", err)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, current)
+
+	case http.MethodPut:
+		var s SavedSearch
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&s); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+		s.ID, s.TenantID, s.CreatedBy, s.CreatedAt, s.LastRunAt = id, tenant, current.CreatedBy, current.CreatedAt, current.LastRunAt
+		if !rp.checkSearch(w, &s) {
+			return
+		}
+		if err := rp.saveSearch(&s); err != nil {
+			writeSearchError(w, rp.db, "save search", err)
+			return
+		}
+		recordAudit(rp.db, tenant, actor, "search.update", map[string]any{"id": id, "name": s.Name, "schedule": s.Schedule})
+		writeJSON(w, http.StatusOK, s)
+
+	case http.MethodDelete:
+		if err := deleteSavedSearch(rp.db, tenant, id); err != nil {
+			writeSearchError(w, rp.db, "delete search", err)
+			return
+		}
+		recordAudit(rp.db, tenant, actor, "search.delete", map[string]any{"id": id, "name": current.Name})
+		writeJSON(w, http.StatusOK, map[string]any{"deleted": id})
+	}
+}
+
+// runSearchHandler serves POST /api/searches/{id}/run, running a saved
+// search now over the window ending now. Its report is only delivered with
+// ?deliver=true.
+func (rp *Reporter) runSearchHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := searchID(w, r, "id")
+	if !ok {
+		return
+	}
+	s, err := getSavedSearch(rp.db, tenantFromRequest(r), id)
+	if err != nil {
+		writeSearchError(w, rp.db, "load saved search", err)
+		return
+	}
+	run, err := rp.execute(s, "manual", time.Now().UTC(), r.URL.Query().Get("deliver") == "true")
+	if err != nil && run.ID == 0 {
+		writeSearchError(w, rp.db, "run search", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+// reportRunsHandler serves GET /api/searches/{id}/runs, newest first,
+// without results.
+func reportRunsHandler(db *store.DB, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := searchID(w, r, "id")
+		if !ok {
+			return
+		}
+		limit := cfg.DefaultPageSize
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > cfg.MaxPageSize {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("'limit' must be between 1 and %d", cfg.MaxPageSize))
+				return
+			}
+			limit = n
+		}
+		runs, err := listReportRuns(db, tenantFromRequest(r), id, limit)
+		if err != nil {
+			writeSearchError(w, db, "list report runs", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, runs)
+	}
+}
+
+// reportRunHandler serves GET /api/searches/{id}/runs/{run}, with results.
+func reportRunHandler(db *store.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := searchID(w, r, "id")
+		if !ok {
+			return
+		}
+		runID, ok := searchID(w, r, "run")
+		if !ok {
+			return
+		}
+		run, err := getReportRun(db, tenantFromRequest(r), id, runID)
+		if err != nil {
+			writeSearchError(w, db, "load report run", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, run)
+	}
+}
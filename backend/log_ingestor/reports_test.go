@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestSavedSearchName(t *testing.T) {
+	rp := NewReporter(nil, nil, APIConfig{DefaultWindow: "1h"}, SearchConfig{}, ReportsConfig{}.WithDefaults())
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "  Failed logins  "},
+		{name: "Failed logins\r\nBcc: victim@example.com", wantErr: true},
+		{name: "tab\tinside", wantErr: true},
+		{name: "   ", wantErr: true},
+	}
+	for _, tt := range tests {
+		s := SavedSearch{Name: tt.name}
+		if _, err := rp.validate(&s); (err != nil) != tt.wantErr {
+			t.Errorf("validate(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPublicAddressOnly(t *testing.T) {
+	tests := []struct {
+		addr    string
+		allowed bool
+	}{
+		{"203.0.113.9:443", true},
+		{"[2001:db8::1]:443", true},
+		{"127.0.0.1:80", false},
+		{"10.1.2.3:8080", false},
+		{"192.168.0.5:80", false},
+		{"169.254.169.254:80", false},
+		{"[::1]:80", false},
+		{"[fe80::1]:80", false},
+		{"[::ffff:10.0.0.1]:80", false},
+		{"0.0.0.0:80", false},
+	}
+	for _, tt := range tests {
+		if err := publicAddressOnly("tcp", tt.addr, nil); (err == nil) != tt.allowed {
+			t.Errorf("publicAddressOnly(%q) error = %v, want allowed %v", tt.addr, err, tt.allowed)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// ipRecord is one IP's reputation as this replica knows it: the merged
+// value last read back from ip_reputation plus what was observed since.
+type ipRecord struct {
+	points   float64 // decayed to at
+	at       time.Time
+	events   int64
+	offenses int
+	first    time.Time
+	last     time.Time
+	offender bool // score currently at or above the offender threshold
+
+	// not yet merged into ip_reputation
+	pending         float64 // decayed to pendingAt
+	pendingAt       time.Time
+	pendingEvents   int64
+	pendingOffenses int
+}
+
+// IPReputation tracks per-IP reputation. Every replica merges the points it
+// observed into ip_reputation, which adds them to the decayed stored value,
+// and reads the merged value back, so replicas converge on the cluster-wide
+// score within a flush interval.
+type IPReputation struct {
+	db       *store.DB
+	alerts   *Alerts
+	cfg      ReputationConfig
+	halfLife time.Duration
+
+	mu  sync.Mutex
+	ips map[string]*ipRecord // tenant/ip
+}
+
+func NewIPReputation(db *store.DB, alerts *Alerts, cfg ReputationConfig) *IPReputation {
+	halfLife, _ := time.ParseDuration(cfg.HalfLife)
+	return &IPReputation{db: db, alerts: alerts, cfg: cfg, halfLife: halfLife, ips: map[string]*ipRecord{}}
+}
+
+// decay returns points accumulated at from as of to.
+func (r *IPReputation) decay(points float64, from, to time.Time) float64 {
+	if points == 0 || !to.After(from) {
+		return points
+	}
+	return points * math.Pow(0.5, float64(to.Sub(from))/float64(r.halfLife))
+}
+
+func (r *IPReputation) score(points float64) int {
+	return int(math.Round(100 * (1 - math.Exp(-points/r.cfg.Scale))))
+}
+
+// current is rec's points as of now, including unmerged ones.
+func (r *IPReputation) current(rec *ipRecord, now time.Time) float64 {
+	return r.decay(rec.points, rec.at, now) + r.decay(rec.pending, rec.pendingAt, now)
+}
+
+// preview returns the score entry's IP would have with entry counted, without
+// counting it; the pipeline stage uses it so dry runs don't affect scores.
+func (r *IPReputation) preview(entry LogEntry) int {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	points := r.cfg.Weights[entry.Severity.String()]
+	if rec := r.ips[entry.TenantID+"/"+entry.IPAddress]; rec != nil {
+		points += r.current(rec, now)
+	}
+	return r.score(points)
+}
+
+// Observe counts a stored entry toward its IP's reputation. An IP whose
+// score crosses the offender threshold again after dropping below it is a
+// repeat offender, and is alerted on.
+func (r *IPReputation) Observe(entry LogEntry) {
+	if r == nil || entry.IPAddress == "" {
+		return
+	}
+	now := time.Now()
+	key := entry.TenantID + "/" + entry.IPAddress
+
+	r.mu.Lock()
+	rec := r.ips[key]
+	if rec == nil {
+		if len(r.ips) >= r.cfg.MaxTracked {
+			r.evict()
+		}
+		rec = &ipRecord{at: now, pendingAt: now, first: now}
+		r.ips[key] = rec
+	}
+	rec.pending = r.decay(rec.pending, rec.pendingAt, now) + r.cfg.Weights[entry.Severity.String()]
+	rec.pendingAt = now
+	rec.pendingEvents++
+	rec.last = now
+	score := r.score(r.current(rec, now))
+	crossed := score >= r.cfg.OffenderScore && !rec.offender
+	rec.offender = score >= r.cfg.OffenderScore
+	if crossed {
+		rec.pendingOffenses++
+	}
+	offenses := rec.offenses + rec.pendingOffenses
+	r.mu.Unlock()
+
+	if crossed && offenses > 1 {
+		go r.alerts.Raise(Alert{
+			TenantID: entry.TenantID,
+			Kind:     "repeat_offender",
+			Severity: SeverityAlert,
+			Title:    fmt.Sprintf("Repeat offender %s (reputation %d, offense %d)", entry.IPAddress, score, offenses),
+			Details: map[string]any{
+				"ip":       entry.IPAddress,
+				"score":    score,
+				"offenses": offenses,
+				"source":   entry.Source,
+				"log_id":   entry.ID,
+			},
+		})
+	}
+}
+
+// evict forgets merged IPs to make room; their state is in ip_reputation
+// and is read back the next time they're flushed. Called with r.mu held.
+func (r *IPReputation) evict() {
+	for key, rec := range r.ips {
+		if rec.pendingEvents == 0 {
+			delete(r.ips, key)
+		}
+	}
+}
+
+// Run merges observed points into ip_reputation every flush interval.
+func (r *IPReputation) Run() {
+	interval, _ := time.ParseDuration(r.cfg.FlushInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := r.flush(); err != nil {
+			slog.Warn("Failed to flush IP reputation", "err", err)
+		}
+	}
+}
+
+// reputationFlushBatch caps the rows per statement.
+const reputationFlushBatch = 500
+
+// flush merges pending points and reads the merged values back.
+func (r *IPReputation) flush() error {
+	type delta struct {
+		key, tenant, ip string
+		points          float64
+		events          int64
+		offenses        int
+		first, last     time.Time
+	}
+	now := time.Now().UTC()
+	var deltas []delta
+	r.mu.Lock()
+	for key, rec := range r.ips {
+		if rec.pendingEvents == 0 {
+			continue
+		}
+		i := strings.LastIndex(key, "/")
+		tenant, ip := key[:i], key[i+1:]
+		deltas = append(deltas, delta{key, tenant, ip, r.decay(rec.pending, rec.pendingAt, now),
+			rec.pendingEvents, rec.pendingOffenses, rec.first.UTC(), rec.last.UTC()})
+		rec.pending, rec.pendingAt, rec.pendingEvents, rec.pendingOffenses = 0, now, 0, 0
+	}
+	r.mu.Unlock()
+
+	halfLife := r.halfLife.Seconds()
+	for start := 0; start < len(deltas); start += reputationFlushBatch {
+		batch := deltas[start:min(start+reputationFlushBatch, len(deltas))]
+		rows := make([]string, len(batch))
+		args := make([]any, 0, len(batch)*8+1)
+		keys := make([]string, len(batch))
+		keyArgs := make([]any, 0, len(batch)*2)
+		for i, d := range batch {
+			rows[i] = "(?, ?, ?, ?, ?, ?, ?, ?)"
+			args = append(args, d.tenant, d.ip, d.points, d.events, d.offenses, d.first, d.last, now)
+			keys[i] = "(?, ?)"
+			keyArgs = append(keyArgs, d.tenant, d.ip)
+		}
+		args = append(args, halfLife)
+		s := r.db.Store
+		if _, err := r.db.Exec(`
+			INSERT INTO ip_reputation (tenant_id, ip_address, points, events, offenses, first_seen, last_seen, last_checked)
+			VALUES `+strings.Join(rows, ", ")+`
+			`+s.Upsert("tenant_id", "ip_address")+`
+				points = ip_reputation.points * POW(0.5, `+s.Greatest(s.SecondsBetween("ip_reputation.last_checked", s.Excluded("last_checked")), "0")+` / ?) + `+s.Excluded("points")+`,
+				events = ip_reputation.events + `+s.Excluded("events")+`,
+				offenses = ip_reputation.offenses + `+s.Excluded("offenses")+`,
+				first_seen = `+s.Least("COALESCE(ip_reputation.first_seen, "+s.Excluded("first_seen")+")", s.Excluded("first_seen"))+`,
+				last_seen = `+s.Greatest("COALESCE(ip_reputation.last_seen, "+s.Excluded("last_seen")+")", s.Excluded("last_seen"))+`,
+				last_checked = `+s.Greatest("ip_reputation.last_checked", s.Excluded("last_checked")), args...); err != nil {
+			// Put the points back so the next flush retries them.
+			r.mu.Lock()
+			for _, d := range batch {
+				if rec := r.ips[d.key]; rec != nil {
+					rec.pending += d.points
+					rec.pendingEvents += d.events
+					rec.pendingOffenses += d.offenses
+				}
+			}
+			r.mu.Unlock()
+			return err
+		}
+
+		merged, err := r.load(`(tenant_id, ip_address) IN (`+strings.Join(keys, ", ")+`)`, keyArgs...)
+		if err != nil {
+			return err
+		}
+		r.mu.Lock()
+		for _, m := range merged {
+			if rec := r.ips[m.TenantID+"/"+m.IP]; rec != nil {
+				rec.points, rec.at = m.points, m.checked
+				rec.events, rec.offenses = m.Events, m.Offenses
+				rec.first = m.FirstSeen
+			}
+		}
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// IPReputationRecord is an IP's stored reputation.
+type IPReputationRecord struct {
+	TenantID       string     `json:"tenant_id"`
+	IP             string     `json:"ip"`
+	Score          int        `json:"score"` // 0-100, as of now
+	Events         int64      `json:"events"`
+	Offenses       int        `json:"offenses"` // times the score crossed the offender threshold
+	RepeatOffender bool       `json:"repeat_offender"`
+	FirstSeen      time.Time  `json:"first_seen"`
+	LastSeen       time.Time  `json:"last_seen"`
+	History        []LogEntry `json:"history"` // newest first
+
+	points  float64
+	checked time.Time
+}
+
+// load reads stored reputations matching where.
+func (r *IPReputation) load(where string, args ...any) ([]IPReputationRecord, error) {
+	rows, err := r.db.Query(`
+		SELECT tenant_id, ip_address, points, events, offenses, first_seen, last_seen, last_checked
+		FROM ip_reputation
+		WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []IPReputationRecord
+	for rows.Next() {
+		var rec IPReputationRecord
+		if err := rows.Scan(&rec.TenantID, &rec.IP, &rec.points, &rec.Events, &rec.Offenses, &rec.FirstSeen, &rec.LastSeen, &rec.checked); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// handler serves GET /api/ips/{ip}: the IP's score, first and last
+// sighting, and its most recent events (?limit=, up to history).
+func (r *IPReputation) handler(w http.ResponseWriter, req *http.Request) {
+	addr, err := netip.ParseAddr(req.PathValue("ip"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid ip")
+		return
+	}
+	ip := addr.String()
+	limit := r.cfg.History
+	if v := req.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > r.cfg.History {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("'limit' must be between 0 and %d", r.cfg.History))
+			return
+		}
+		limit = n
+	}
+	tenant := tenantFromRequest(req)
+
+	records, err := r.load("tenant_id = ? AND ip_address = ?", tenant, ip)
+	if err != nil {
+		slog.Error("Failed to load IP reputation", "ip", ip, "err", err)
+		writeError(w, http.StatusInternalServerError, "failed to load IP reputation")
+		return
+	}
+	rec := IPReputationRecord{TenantID: tenant, IP: ip}
+	if len(records) > 0 {
+		rec = records[0]
+	}
+	// Add what this replica hasn't merged yet.
+	now := time.Now()
+	points := r.decay(rec.points, rec.checked, now)
+	r.mu.Lock()
+	if local := r.ips[tenant+"/"+ip]; local != nil {
+		points += r.decay(local.pending, local.pendingAt, now)
+		rec.Events += local.pendingEvents
+		rec.Offenses += local.pendingOffenses
+		if rec.FirstSeen.IsZero() {
+			rec.FirstSeen = local.first
+		}
+		if local.last.After(rec.LastSeen) {
+			rec.LastSeen = local.last
+		}
+	}
+	r.mu.Unlock()
+	if rec.Events == 0 {
+		writeError(w, http.StatusNotFound, "no events from that ip")
+		return
+	}
+	rec.Score = r.score(points)
+	rec.RepeatOffender = rec.Offenses > 1
+
+	if limit > 0 {
+		rows, err := r.db.Query(`
+			SELECT `+logColumns+`
+			FROM logs
+			WHERE tenant_id = ? AND ip_address = ? AND deleted_at IS NULL
+			ORDER BY timestamp DESC, id DESC
+			LIMIT ?`, tenant, ip, limit)
+		if err == nil {
+			rec.History, err = scanLogs(rows)
+		}
+		if err != nil {
+			slog.Error("Failed to load IP history", "ip", ip, "err", err)
+			writeError(w, http.StatusInternalServerError, "failed to load IP history")
+			return
+		}
+	}
+	if rec.History == nil {
+		rec.History = []LogEntry{}
+	}
+	writeJSON(w, http.StatusOK, rec)
+}
+
+// scoreReputation is the reputation pipeline stage: it records the IP's
+// score, counting this entry, in the ip_reputation field so it's stored and
+// streamed with the entry.
+func (r *IPReputation) scoreReputation(entry *LogEntry) error {
+	if r == nil || entry.IPAddress == "" {
+		return nil
+	}
+	if entry.Fields == nil {
+		entry.Fields = map[string]string{}
+	}
+	entry.Fields["ip_reputation"] = strconv.Itoa(r.preview(*entry))
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// runRetention periodically purges expired logs, archiving them first when
+// archiver is non-nil. Logs under an active legal hold are never purged,
+// whether expired or soft-deleted.
+func runRetention(db *store.DB, cfg RetentionConfig, archiver *Archiver) {
+	interval, _ := time.ParseDuration(cfg.Interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if archiver != nil {
+			n, err := archiver.archiveExpired(context.Background(), db, cfg)
+			if err != nil {
+				// Purging now would drop logs that never reached the archive.
+				slog.Error("Retention archive failed, skipping purge", "err", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("Retention archived logs", "archived", n)
+				recordAudit(db, defaultTenant, "retention", "logs.archive", map[string]any{"archived": n})
+			}
+		}
+		n, err := purgeExpiredLogs(db, cfg)
+		if err != nil {
+			slog.Error("Retention purge failed", "err", err)
+			continue
+		}
+		if n > 0 {
+			slog.Info("Retention purged logs", "purged", n)
+			recordAudit(db, defaultTenant, "retention", "logs.purge", map[string]any{"purged": n})
+		}
+	}
+}
+
+// purgeExpiredLogs deletes, in batches, logs past their grace period after
+// soft-deletion or older than MaxAge.
+func purgeExpiredLogs(db *store.DB, cfg RetentionConfig) (int64, error) {
+	grace, _ := time.ParseDuration(cfg.DeletedGrace)
+	expired := "(deleted_at IS NOT NULL AND deleted_at < ?)"
+	args := []any{time.Now().Add(-grace)}
+	if maxAge, err := time.ParseDuration(cfg.MaxAge); err == nil && maxAge > 0 {
+		expired = "(" + expired + " OR timestamp < ?)"
+		args = append(args, time.Now().Add(-maxAge))
+	}
+	args = append(args, cfg.BatchSize)
+
+	var total int64
+	for {
+		res, err := db.Exec(db.DeleteLimit("logs", expired+` AND `+notHeldClause), args...)
+		if err != nil {
+			return total, err
+		}
+		n, _ := res.RowsAffected()
+		total += n
+		if n < int64(cfg.BatchSize) {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"1logx/log_ingestor/internal/pipeline"
+)
+
+type retryItem struct {
+	entry    LogEntry
+	origin   pipeline.Origin // for Derived and OnStored
+	attempts int
+	next     time.Time
+	lastErr  error
+}
+
+// deadLetter is one line of the dead-letter file.
+type deadLetter struct {
+	Entry    LogEntry  `json:"entry"`
+	Derived  bool      `json:"derived,omitempty"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// RetryQueue retries failed inserts with exponential backoff from a bounded
+// in-memory queue. Entries that exhaust their retries, or arrive while the
+// queue is full, are appended to a JSONL dead-letter file that is re-drained
+// on the next start.
+type RetryQueue struct {
+	in  *pipeline.Ingestor
+	cfg RetryConfig
+
+	initial, max time.Duration
+	queue        chan retryItem
+
+	dlMu sync.Mutex
+}
+
+func NewRetryQueue(in *pipeline.Ingestor, cfg RetryConfig) *RetryQueue {
+	initial, _ := time.ParseDuration(cfg.InitialBackoff)
+	max, _ := time.ParseDuration(cfg.MaxBackoff)
+	return &RetryQueue{in: in, cfg: cfg, initial: initial, max: max, queue: make(chan retryItem, cfg.QueueSize)}
+}
+
+// Enqueue schedules entry, whose first insert failed with err.
+func (rq *RetryQueue) Enqueue(entry LogEntry, origin pipeline.Origin, err error) {
+	rq.push(retryItem{entry: entry, origin: origin, attempts: 1, lastErr: err})
+}
+
+func (rq *RetryQueue) push(it retryItem) {
+	it.next = time.Now().Add(rq.backoff(it.attempts))
+	select {
+	case rq.queue <- it:
+	default:
+		rq.deadLetter(it, "retry queue full")
+	}
+}
+
+// backoff doubles from InitialBackoff per attempt up to MaxBackoff, with
+// +/-20% jitter so a recovering database isn't hit in lockstep.
+func (rq *RetryQueue) backoff(attempts int) time.Duration {
+	d := rq.initial
+	for i := 1; i < attempts && d < rq.max; i++ {
+		d *= 2
+	}
+	if d > rq.max {
+		d = rq.max
+	}
+	return time.Duration(float64(d) * (0.8 + 0.4*rand.Float64()))
+}
+
+// Run retries queued entries in order until the process exits.
+func (rq *RetryQueue) Run() {
+	for it := range rq.queue {
+		time.Sleep(time.Until(it.next))
+		stored, err := rq.in.Store(it.entry, it.origin.Derived)
+		if err == nil {
+			slog.Info("Stored log after retries", "id", stored.ID, "attempts", it.attempts)
+			if it.origin.OnStored != nil {
+				it.origin.OnStored(stored)
+			}
+			continue
+		}
+		it.attempts++
+		it.lastErr = err
+		if it.attempts > rq.cfg.MaxAttempts {
+			rq.deadLetter(it, "retries exhausted")
+			continue
+		}
+		rq.push(it)
+	}
+}
+
+func (rq *RetryQueue) deadLetter(it retryItem, why string) {
+	rq.dlMu.Lock()
+	defer rq.dlMu.Unlock()
+	if err := appendDeadLetters(rq.cfg.DeadLetterPath, []deadLetter{{
+		Entry: it.entry, Derived: it.origin.Derived, Attempts: it.attempts, Error: fmt.Sprint(it.lastErr), FailedAt: time.Now(),
+	}}); err != nil {
+		slog.Error("Lost log; dead-letter write failed", "reason", why, "err", err)
+		return
+	}
+	slog.Warn("Log dead-lettered", "path", rq.cfg.DeadLetterPath, "reason", why, "err", it.lastErr)
+}
+
+// deadLetterBatch is deadLetter for entries that failed together.
+func (rq *RetryQueue) deadLetterBatch(entries []LogEntry, attempts int, err error, why string) {
+	letters := make([]deadLetter, len(entries))
+	for i, entry := range entries {
+		letters[i] = deadLetter{Entry: entry, Attempts: attempts, Error: fmt.Sprint(err), FailedAt: time.Now()}
+	}
+	rq.dlMu.Lock()
+	defer rq.dlMu.Unlock()
+	if werr := appendDeadLetters(rq.cfg.DeadLetterPath, letters); werr != nil {
+		slog.Error("Lost logs; dead-letter write failed", "entries", len(entries), "reason", why, "err", werr)
+		return
+	}
+	slog.Warn("Logs dead-lettered", "path", rq.cfg.DeadLetterPath, "entries", len(entries), "reason", why, "err", err)
+}
+
+func appendDeadLetters(path string, letters []deadLetter) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, l := range letters {
+		if err := enc.Encode(l); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// Drain re-queues entries from the dead-letter file, typically at startup.
+// The file is moved aside first so entries that fail again start a fresh one.
+func (rq *RetryQueue) Drain() (int, error) {
+	draining := rq.cfg.DeadLetterPath + ".draining"
+	// A leftover file means the last drain was interrupted; finish it first.
+	if _, err := os.Stat(draining); errors.Is(err, os.ErrNotExist) {
+		if err := os.Rename(rq.cfg.DeadLetterPath, draining); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return 0, nil
+			}
+			return 0, err
+		}
+	}
+	f, err := os.Open(draining)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var (
+		n       int
+		skipped []deadLetter
+	)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), maxIngestBody)
+	for sc.Scan() {
+		var l deadLetter
+		if err := json.Unmarshal(sc.Bytes(), &l); err != nil {
+			slog.Warn("Skipping unreadable dead-letter line", "err", err)
+			continue
+		}
+		select {
+		case rq.queue <- retryItem{entry: l.Entry, origin: pipeline.Origin{Derived: l.Derived}, lastErr: errors.New(l.Error)}:
+			n++
+		default:
+			skipped = append(skipped, l)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return n, err
+	}
+	if len(skipped) > 0 {
+		rq.dlMu.Lock()
+		err := appendDeadLetters(rq.cfg.DeadLetterPath, skipped)
+		rq.dlMu.Unlock()
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, os.Remove(draining)
+}
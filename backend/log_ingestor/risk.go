@@ -0,0 +1,92 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RiskScorer computes event risk scores.
+type RiskScorer struct {
+	// rules counts the detection rules an event matches; nil without any.
+	rules func(LogEntry) int
+	// threatIntel reports how strongly an event matches threat intelligence,
+	// 0-1; nil until a feed is configured.
+	threatIntel func(LogEntry) float64
+
+	mu      sync.Mutex // guards cfg and novelty too, which reload swaps
+	cfg     RiskConfig
+	novelty time.Duration
+	seen    map[string]time.Time // tenant/source/ip -> last seen
+}
+
+func NewRiskScorer(cfg RiskConfig) *RiskScorer {
+	novelty, _ := time.ParseDuration(cfg.NoveltyWindow)
+	return &RiskScorer{cfg: cfg, novelty: novelty, seen: map[string]time.Time{}}
+}
+
+// setConfig swaps in reloaded weights. Seen pairs are kept.
+func (s *RiskScorer) setConfig(cfg RiskConfig) {
+	novelty, _ := time.ParseDuration(cfg.NoveltyWindow)
+	s.mu.Lock()
+	s.cfg, s.novelty = cfg, novelty
+	s.mu.Unlock()
+}
+
+// anomaly is 1 the first time a source reports an IP within the novelty
+// window and 0 after. Entries without an IP aren't judged.
+func (s *RiskScorer) anomaly(e LogEntry) float64 {
+	if e.IPAddress == "" {
+		return 0
+	}
+	key := e.TenantID + "/" + e.Source + "/" + e.IPAddress
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.seen[key]
+	if len(s.seen) >= s.cfg.MaxTracked && !ok {
+		for k, t := range s.seen {
+			if now.Sub(t) > s.novelty {
+				delete(s.seen, k)
+			}
+		}
+		// Everything is still familiar: forget it all rather than grow,
+		// at the cost of briefly treating known pairs as new.
+		if len(s.seen) >= s.cfg.MaxTracked {
+			s.seen = map[string]time.Time{}
+		}
+	}
+	s.seen[key] = now
+	if ok && now.Sub(last) <= s.novelty {
+		return 0
+	}
+	return 1
+}
+
+// score computes e's 0-100 risk.
+func (s *RiskScorer) score(e LogEntry) int {
+	s.mu.Lock()
+	cfg := s.cfg
+	s.mu.Unlock()
+	base := cfg.Base[e.Severity.String()] / 100
+	remaining := 1 - math.Max(0, math.Min(1, base))
+
+	if s.threatIntel != nil {
+		remaining *= 1 - cfg.ThreatIntel*math.Max(0, math.Min(1, s.threatIntel(e)))
+	}
+	remaining *= 1 - cfg.Anomaly*s.anomaly(e)
+	if s.rules != nil {
+		// Each matching rule halves the distance to full weight.
+		if hits := s.rules(e); hits > 0 {
+			remaining *= 1 - cfg.RuleHits*(1-math.Pow(0.5, float64(hits)))
+		}
+	}
+	return int(math.Round(100 * (1 - remaining)))
+}
+
+// scoreRisk is the risk pipeline stage.
+func (s *RiskScorer) scoreRisk(entry *LogEntry) error {
+	entry.RiskScore = s.score(*entry)
+	return nil
+}
@@ -0,0 +1,590 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+type schemaIndex struct {
+	Unique  bool
+	Columns []string
+}
+
+type schemaTable struct {
+	Columns map[string]string // name -> normalized type
+	Order   []string          // column names in declaration order
+	Indexes map[string]schemaIndex
+	Foreign [][]string // foreign key columns; the database indexes them under its own names
+}
+
+func newSchemaTable() *schemaTable {
+	return &schemaTable{Columns: map[string]string{}, Indexes: map[string]schemaIndex{}}
+}
+
+var (
+	createTablePattern = regexp.MustCompile(`(?is)^CREATE TABLE (?:IF NOT EXISTS )?` + "`?" + `(\w+)` + "`?" + `\s*\((.*)\)[^)]*$`)
+	createIndexPattern = regexp.MustCompile(`(?is)^CREATE (UNIQUE )?INDEX (?:IF NOT EXISTS )?` + "`?" + `(\w+)` + "`?" + ` ON ` + "`?" + `(\w+)` + "`?" + `\s*\(([^)]*)\)`)
+	tableIndexPattern  = regexp.MustCompile(`(?is)^(UNIQUE )?(?:KEY|INDEX) ` + "`?" + `(\w+)` + "`?" + `\s*\(([^)]*)\)`)
+	columnPattern      = regexp.MustCompile(`(?is)^` + "`?" + `(\w+)` + "`?" + `\s+(\w+(?:\s*\([^)]*\))?(?:\s+unsigned)?)(.*)$`)
+	intWidthPattern    = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|bigint)\(\d+\)`)
+)
+
+// parseSchema reads the CREATE TABLE and CREATE INDEX statements of a schema
+// script. Other statements are ignored.
+func parseSchema(script string) (map[string]*schemaTable, error) {
+	tables := map[string]*schemaTable{}
+	for _, stmt := range schemaStatements(script) {
+		if m := createTablePattern.FindStringSubmatch(stmt); m != nil {
+			t, err := parseTableBody(m[2])
+			if err != nil {
+				return nil, fmt.Errorf("table %s: %w", m[1], err)
+			}
+			tables[strings.ToLower(m[1])] = t
+			continue
+		}
+		if m := createIndexPattern.FindStringSubmatch(stmt); m != nil {
+			t, ok := tables[strings.ToLower(m[3])]
+			if !ok {
+				return nil, fmt.Errorf("index %s on unknown table %s", m[2], m[3])
+			}
+			t.Indexes[strings.ToLower(m[2])] = schemaIndex{Unique: m[1] != "", Columns: indexColumns(m[4])}
+		}
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no CREATE TABLE statements found")
+	}
+	return tables, nil
+}
+
+// schemaStatements splits a schema script into statements, without comments
+// and with whitespace collapsed.
+func schemaStatements(script string) []string {
+	var lines []string
+	for _, line := range strings.Split(script, "\n") {
+		if i := strings.Index(line, "--"); i >= 0 {
+			line = line[:i]
+		}
+		lines = append(lines, line)
+	}
+	var stmts []string
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if stmt = strings.Join(strings.Fields(stmt), " "); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+func parseTableBody(body string) (*schemaTable, error) {
+	t := newSchemaTable()
+	for _, def := range splitTopLevel(body) {
+		upper := strings.ToUpper(def)
+		switch {
+		case strings.HasPrefix(upper, "PRIMARY KEY"):
+			t.Indexes["primary"] = schemaIndex{Unique: true, Columns: indexColumns(parenthesized(def))}
+		case strings.HasPrefix(upper, "FOREIGN KEY"):
+			t.Foreign = append(t.Foreign, indexColumns(parenthesized(def)))
+		case strings.HasPrefix(upper, "CONSTRAINT"), strings.HasPrefix(upper, "CHECK"):
+		case tableIndexPattern.MatchString(def):
+			m := tableIndexPattern.FindStringSubmatch(def)
+			t.Indexes[strings.ToLower(m[2])] = schemaIndex{Unique: m[1] != "", Columns: indexColumns(m[3])}
+		default:
+			m := columnPattern.FindStringSubmatch(def)
+			if m == nil {
+				return nil, fmt.Errorf("unrecognized definition %q", def)
+			}
+			name := strings.ToLower(m[1])
+			t.Columns[name] = normalizeColumnType(m[2])
+			t.Order = append(t.Order, name)
+			attrs := strings.ToUpper(m[3])
+			if strings.Contains(attrs, "PRIMARY KEY") {
+				t.Indexes["primary"] = schemaIndex{Unique: true, Columns: []string{name}}
+			} else if strings.Contains(attrs, "UNIQUE") {
+				t.Indexes[name] = schemaIndex{Unique: true, Columns: []string{name}}
+			}
+		}
+	}
+	return t, nil
+}
+
+// splitTopLevel splits s on commas outside parentheses and quotes.
+func splitTopLevel(s string) []string {
+	var (
+		parts []string
+		depth int
+		quote rune
+		start int
+	)
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(s[start:]); rest != "" {
+		parts = append(parts, rest)
+	}
+	return parts
+}
+
+func parenthesized(s string) string {
+	open, end := strings.Index(s, "("), strings.Index(s, ")")
+	if open < 0 || end < open {
+		return ""
+	}
+	return s[open+1 : end]
+}
+
+// indexColumns normalizes an index column list, dropping prefix lengths and
+// sort order.
+func indexColumns(list string) []string {
+	var cols []string
+	for _, c := range strings.Split(list, ",") {
+		c = strings.Trim(strings.TrimSpace(c), "`")
+		if i := strings.IndexAny(c, "( "); i >= 0 {
+			c = c[:i]
+		}
+		if c != "" {
+			cols = append(cols, strings.ToLower(strings.Trim(c, "`")))
+		}
+	}
+	return cols
+}
+
+// normalizeColumnType makes declared and reported types comparable: integer
+// display widths are dropped (MySQL 8 omits them, TiDB reports them) and
+// aliases are resolved.
+func normalizeColumnType(t string) string {
+	t = strings.ToLower(strings.Join(strings.Fields(t), " "))
+	t = strings.ReplaceAll(strings.ReplaceAll(t, " (", "("), ", ", ",")
+	switch t {
+	case "boolean", "bool":
+		return "tinyint"
+	case "integer":
+		return "int"
+	}
+	return intWidthPattern.ReplaceAllString(t, "$1")
+}
+
+// SchemaDrift is one difference between the expected and live schema.
+type SchemaDrift struct {
+	Table    string `json:"table"`
+	Kind     string `json:"kind"` // missing_table, missing_column, extra_column, column_type, missing_index, extra_index, index_columns
+	Object   string `json:"object,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+func (d SchemaDrift) String() string {
+	s := d.Kind + " " + d.Table
+	if d.Object != "" {
+		s += "." + d.Object
+	}
+	if d.Expected != "" || d.Actual != "" {
+		s += fmt.Sprintf(" (expected %q, found %q)", d.Expected, d.Actual)
+	}
+	return s
+}
+
+// breaking reports whether the drift can fail queries, not just slow them.
+func (d SchemaDrift) breaking() bool {
+	switch d.Kind {
+	case "missing_table", "missing_column", "column_type":
+		return true
+	}
+	return false
+}
+
+// loadLiveSchema reads the tables, columns, and indexes of the connected
+// database from information_schema.
+func loadLiveSchema(db *store.DB) (map[string]*schemaTable, error) {
+	tables := map[string]*schemaTable{}
+	table := func(name string) *schemaTable {
+		name = strings.ToLower(name)
+		if tables[name] == nil {
+			tables[name] = newSchemaTable()
+		}
+		return tables[name]
+	}
+
+	rows, err := db.Query(`
+		SELECT TABLE_NAME, COLUMN_NAME, COLUMN_TYPE FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() ORDER BY TABLE_NAME, ORDINAL_POSITION`)
+	if err != nil {
+		return nil, fmt.Errorf("read columns: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tbl, col, typ string
+		if err := rows.Scan(&tbl, &col, &typ); err != nil {
+			return nil, err
+		}
+		t, col := table(tbl), strings.ToLower(col)
+		t.Columns[col] = normalizeColumnType(typ)
+		t.Order = append(t.Order, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	idx, err := db.Query(`
+		SELECT TABLE_NAME, INDEX_NAME, NON_UNIQUE, COLUMN_NAME FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = DATABASE() ORDER BY TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX`)
+	if err != nil {
+		return nil, fmt.Errorf("read indexes: %w", err)
+	}
+	defer idx.Close()
+	for idx.Next() {
+		var (
+			tbl, name, col string
+			nonUnique      int
+		)
+		if err := idx.Scan(&tbl, &name, &nonUnique, &col); err != nil {
+			return nil, err
+		}
+		t, name := table(tbl), strings.ToLower(name)
+		i := t.Indexes[name]
+		i.Unique = nonUnique == 0
+		i.Columns = append(i.Columns, strings.ToLower(col))
+		t.Indexes[name] = i
+	}
+	return tables, idx.Err()
+}
+
+// diffSchema compares the expected tables with the live ones. Tables that
+// only exist live are not this service's and are ignored.
+func diffSchema(expected, live map[string]*schemaTable) []SchemaDrift {
+	var drift []SchemaDrift
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		want, have := expected[name], live[name]
+		if have == nil {
+			drift = append(drift, SchemaDrift{Table: name, Kind: "missing_table"})
+			continue
+		}
+		for _, col := range want.Order {
+			switch typ, ok := have.Columns[col]; {
+			case !ok:
+				drift = append(drift, SchemaDrift{Table: name, Kind: "missing_column", Object: col, Expected: want.Columns[col]})
+			case typ != want.Columns[col]:
+				drift = append(drift, SchemaDrift{Table: name, Kind: "column_type", Object: col, Expected: want.Columns[col], Actual: typ})
+			}
+		}
+		for _, col := range have.Order {
+			if _, ok := want.Columns[col]; !ok {
+				drift = append(drift, SchemaDrift{Table: name, Kind: "extra_column", Object: col, Actual: have.Columns[col]})
+			}
+		}
+
+		for _, idx := range sortedKeys(want.Indexes) {
+			w := want.Indexes[idx]
+			h, ok := have.Indexes[idx]
+			switch {
+			case !ok:
+				drift = append(drift, SchemaDrift{Table: name, Kind: "missing_index", Object: idx, Expected: describeIndex(w)})
+			case describeIndex(h) != describeIndex(w):
+				drift = append(drift, SchemaDrift{Table: name, Kind: "index_columns", Object: idx, Expected: describeIndex(w), Actual: describeIndex(h)})
+			}
+		}
+		for _, idx := range sortedKeys(have.Indexes) {
+			h := have.Indexes[idx]
+			if _, ok := want.Indexes[idx]; ok || coversForeignKey(want, h) {
+				continue
+			}
+			drift = append(drift, SchemaDrift{Table: name, Kind: "extra_index", Object: idx, Actual: describeIndex(h)})
+		}
+	}
+	return drift
+}
+
+// migrationStatements returns the statements that bring live up to script:
+// missing tables and indexes are created and missing columns added, using
+// the script's own definitions. Other drift (type changes, extra columns or
+// indexes) needs a reviewed migration and isn't touched.
+func migrationStatements(script string, live map[string]*schemaTable) ([]string, error) {
+	var stmts []string
+	for _, stmt := range schemaStatements(script) {
+		if m := createTablePattern.FindStringSubmatch(stmt); m != nil {
+			have := live[strings.ToLower(m[1])]
+			if have == nil {
+				stmts = append(stmts, stmt)
+				continue
+			}
+			for _, def := range splitTopLevel(m[2]) {
+				upper := strings.ToUpper(def)
+				switch {
+				case strings.HasPrefix(upper, "PRIMARY KEY"), strings.HasPrefix(upper, "FOREIGN KEY"),
+					strings.HasPrefix(upper, "CONSTRAINT"), strings.HasPrefix(upper, "CHECK"):
+				case tableIndexPattern.MatchString(def):
+					if _, ok := have.Indexes[strings.ToLower(tableIndexPattern.FindStringSubmatch(def)[2])]; !ok {
+						stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD %s", m[1], def))
+					}
+				default:
+					col := columnPattern.FindStringSubmatch(def)
+					if col == nil {
+						return nil, fmt.Errorf("table %s: unrecognized definition %q", m[1], def)
+					}
+					if _, ok := have.Columns[strings.ToLower(col[1])]; !ok {
+						stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", m[1], def))
+					}
+				}
+			}
+			continue
+		}
+		if m := createIndexPattern.FindStringSubmatch(stmt); m != nil {
+			have := live[strings.ToLower(m[3])]
+			if have == nil {
+				stmts = append(stmts, stmt)
+			} else if _, ok := have.Indexes[strings.ToLower(m[2])]; !ok {
+				stmts = append(stmts, stmt)
+			}
+		}
+	}
+	return stmts, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func describeIndex(i schemaIndex) string {
+	s := "(" + strings.Join(i.Columns, ", ") + ")"
+	if i.Unique {
+		s = "UNIQUE " + s
+	}
+	return s
+}
+
+// coversForeignKey reports whether i is the index the database created for
+// one of t's foreign keys.
+func coversForeignKey(t *schemaTable, i schemaIndex) bool {
+	for _, fk := range t.Foreign {
+		if strings.Join(fk, ",") == strings.Join(i.Columns, ",") {
+			return true
+		}
+	}
+	return false
+}
+
+// SchemaStatus is the result of the latest drift check.
+type SchemaStatus struct {
+	CheckedAt time.Time     `json:"checked_at"`
+	Error     string        `json:"error,omitempty"`
+	Drift     []SchemaDrift `json:"drift"`
+}
+
+// SchemaChecker periodically compares the live database with the expected
+// schema, reporting drift through /health and an alert.
+type SchemaChecker struct {
+	db       *store.DB
+	alerts   *Alerts
+	cfg      SchemaConfig
+	expected map[string]*schemaTable
+
+	mu      sync.RWMutex
+	status  SchemaStatus
+	alerted string // drift last alerted on, to alert only on changes
+}
+
+func NewSchemaChecker(db *store.DB, alerts *Alerts, cfg SchemaConfig) (*SchemaChecker, error) {
+	if db.Driver() != "tidb" {
+		return nil, fmt.Errorf("drift detection reads TiDB's information_schema; not supported on %s", db.Driver())
+	}
+	data, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read expected schema: %w", err)
+	}
+	expected, err := parseSchema(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", cfg.Path, err)
+	}
+	return &SchemaChecker{db: db, alerts: alerts, cfg: cfg, expected: expected}, nil
+}
+
+// Run checks at startup and then every interval.
+func (c *SchemaChecker) Run() {
+	interval, _ := time.ParseDuration(c.cfg.Interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.check()
+		<-ticker.C
+	}
+}
+
+func (c *SchemaChecker) check() {
+	status := SchemaStatus{CheckedAt: time.Now(), Drift: []SchemaDrift{}}
+	live, err := loadLiveSchema(c.db)
+	if err != nil {
+		slog.Error("Schema drift check failed", "err", err)
+		status.Error = err.Error()
+		c.mu.Lock()
+		c.status = status
+		c.mu.Unlock()
+		return
+	}
+	if drift := diffSchema(c.expected, live); drift != nil {
+		status.Drift = drift
+	}
+
+	var lines []string
+	for _, d := range status.Drift {
+		lines = append(lines, d.String())
+	}
+	fingerprint := strings.Join(lines, "\n")
+
+	c.mu.Lock()
+	c.status = status
+	changed := fingerprint != c.alerted
+	c.alerted = fingerprint
+	c.mu.Unlock()
+	if !changed {
+		return
+	}
+	if len(status.Drift) == 0 {
+		slog.Info("Database schema matches", "path", c.cfg.Path)
+		return
+	}
+
+	severity := SeverityWarning
+	for _, d := range status.Drift {
+		slog.Warn("Schema drift", "drift", d)
+		if d.breaking() {
+			severity = SeverityAlert
+		}
+	}
+	c.alerts.Raise(Alert{
+		TenantID: defaultTenant,
+		Kind:     "schema_drift",
+		Severity: severity,
+		Title:    fmt.Sprintf("Database schema drifted from %s (%d differences)", c.cfg.Path, len(status.Drift)),
+		Details:  map[string]any{"drift": status.Drift},
+	})
+}
+
+func (c *SchemaChecker) Status() SchemaStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+// healthHandler serves GET /health: 503 when the database is unreachable,
+// otherwise "ok", or "degraded" while the schema has drifted.
+func healthHandler(db *store.DB, schema *SchemaChecker, h *streamHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := map[string]any{"status": "ok"}
+		if h.Draining() {
+			resp["status"] = "draining"
+			writeJSON(w, http.StatusServiceUnavailable, resp)
+			return
+		}
+		if err := db.PingContext(r.Context()); err != nil {
+			resp["status"] = "unavailable"
+			resp["database"] = err.Error()
+			writeJSON(w, http.StatusServiceUnavailable, resp)
+			return
+		}
+		if schema != nil {
+			status := schema.Status()
+			resp["schema"] = status
+			if len(status.Drift) > 0 || status.Error != "" {
+				resp["status"] = "degraded"
+			}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// migrateCommand applies the additive part of the drift between the schema
+// script and the database, printing each statement it runs. Drift it can't
+// safely fix is logged for a hand-written migration. Stores other than TiDB
+// have no drift detection, so their whole script (all IF NOT EXISTS) is run.
+func migrateCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	schemaPath := fs.String("schema", "", "schema script (default schema.path from the config)")
+	dryRun := fs.Bool("dry-run", false, "print the statements without running them")
+	config, db, err := commandConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	path := *schemaPath
+	if path == "" {
+		path = config.Schema.WithDefaults(db.Schema()).Path
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read schema: %w", err)
+	}
+	var drift []SchemaDrift
+	stmts := schemaStatements(string(data))
+	if db.Driver() == "tidb" {
+		expected, err := parseSchema(string(data))
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+		live, err := loadLiveSchema(db)
+		if err != nil {
+			return err
+		}
+		if stmts, err = migrationStatements(string(data), live); err != nil {
+			return err
+		}
+		drift = diffSchema(expected, live)
+	}
+	for _, stmt := range stmts {
+		fmt.Println(stmt + ";")
+		if *dryRun {
+			continue
+		}
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("migration stopped at %q: %w", stmt, err)
+		}
+	}
+	if !*dryRun && len(stmts) > 0 {
+		recordAudit(db, defaultTenant, "cli", "schema.migrate", map[string]any{"schema": path, "statements": stmts})
+	}
+
+	for _, d := range drift {
+		switch d.Kind {
+		case "missing_table", "missing_column", "missing_index":
+			continue
+		}
+		slog.Warn("Schema drift needs a manual migration", "drift", d)
+	}
+	slog.Info("Schema migrated", "path", path, "statements", len(stmts), "dry_run", *dryRun)
+	return nil
+}
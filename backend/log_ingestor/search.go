@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+const maxSearchQuery = 1024
+
+// SearchRequest is the body of POST /api/search.
+type SearchRequest struct {
+	Query       string         `json:"query"`
+	From        *time.Time     `json:"from,omitempty"`
+	To          *time.Time     `json:"to,omitempty"`
+	Sources     []string       `json:"sources,omitempty"`
+	MinSeverity *Severity      `json:"min_severity,omitempty"`
+	Limit       int            `json:"limit,omitempty"`
+	Weights     *SearchWeights `json:"weights,omitempty"`
+	HalfLife    string         `json:"half_life,omitempty"`
+	Force       bool           `json:"force,omitempty"`
+}
+
+// SearchResult is a log with its hybrid score and the signals behind it.
+type SearchResult struct {
+	Log     LogEntry `json:"log"`
+	Score   float64  `json:"score"`
+	Vector  float64  `json:"vector"`
+	Keyword float64  `json:"keyword"`
+	Recency float64  `json:"recency"`
+}
+
+// searchStopwords are dropped from keyword matching so phrasing like "show me
+// things that look like" doesn't match every log.
+var searchStopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "that": true, "this": true, "with": true,
+	"from": true, "show": true, "find": true, "things": true, "look": true, "looks": true,
+	"like": true, "any": true, "all": true, "are": true, "was": true, "were": true,
+	"what": true, "which": true, "logs": true, "events": true,
+}
+
+// searchTerms extracts the keywords of a natural-language query.
+func searchTerms(query string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '.' && r != '_' && r != '-'
+	}) {
+		w = strings.Trim(w, ".-_")
+		if len(w) < 3 || searchStopwords[w] || seen[w] {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, w)
+		if len(terms) == 8 {
+			break
+		}
+	}
+	return terms
+}
+
+// keywordScore is the fraction of terms found in e's source, message, or
+// field values.
+func keywordScore(e LogEntry, terms []string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+	var text strings.Builder
+	text.WriteString(strings.ToLower(e.Source + " " + e.Message + " " + e.IPAddress))
+	for _, v := range e.Fields {
+		text.WriteString(" " + strings.ToLower(v))
+	}
+	hay := text.String()
+	matched := 0
+	for _, t := range terms {
+		if strings.Contains(hay, t) {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(terms))
+}
+
+// searchCandidates fetches up to limit logs matching q plus extra, along with
+// their cosine distance to vec (NULL without an embedding, or when vec is
+// empty).
+func searchCandidates(ctx context.Context, db *store.DB, q LogQuery, vec string, extra string, extraArgs []any, order string, limit int) (map[int64]LogEntry, map[int64]float64, error) {
+	where, args := q.conditions(db)
+	if extra != "" {
+		where = append(where, extra)
+		args = append(args, extraArgs...)
+	}
+	distance := "NULL"
+	if vec != "" {
+		distance = db.VectorDistance("embedding")
+		args = append([]any{vec}, args...)
+	}
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+logColumns+`, `+distance+` AS distance
+		FROM logs
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY `+order+`
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	logs, distances := map[int64]LogEntry{}, map[int64]float64{}
+	for rows.Next() {
+		var distance sql.NullFloat64
+		e, err := scanLog(rows, &distance)
+		if err != nil {
+			return nil, nil, err
+		}
+		logs[e.ID] = e
+		if distance.Valid {
+			distances[e.ID] = distance.Float64
+		}
+	}
+	return logs, distances, rows.Err()
+}
+
+// hybridSearch ranks logs matching q by a weighted sum of vector similarity
+// to the query, keyword overlap, and recency. Candidates come from the
+// nearest embeddings and from keyword matches, so exact terms aren't lost
+// when embeddings are weak. Stores without vector support rank by keywords
+// and recency only.
+func hybridSearch(ctx context.Context, db *store.DB, embedder Embedder, q LogQuery, query string, w SearchWeights, halfLife time.Duration, candidates int) ([]SearchResult, int, error) {
+	var literal string
+	if db.Vectors() {
+		vec, err := embedder.Embed(ctx, query)
+		if err != nil {
+			return nil, 0, fmt.Errorf("embed query: %w", err)
+		}
+		literal = store.FormatVector(vec)
+	}
+
+	logs, distances := map[int64]LogEntry{}, map[int64]float64{}
+	merge := func(l map[int64]LogEntry, d map[int64]float64) {
+		for id, e := range l {
+			logs[id] = e
+		}
+		for id, v := range d {
+			distances[id] = v
+		}
+	}
+
+	if w.Vector > 0 && literal != "" {
+		l, d, err := searchCandidates(ctx, db, q, literal, "embedding IS NOT NULL", nil, "distance", candidates)
+		if err != nil {
+			return nil, 0, fmt.Errorf("vector candidates: %w", err)
+		}
+		merge(l, d)
+	}
+	terms := searchTerms(query)
+	if len(terms) > 0 && w.Keyword > 0 {
+		var like []string
+		var args []any
+		for _, t := range terms {
+			like = append(like, "LOWER(message) LIKE ?")
+			args = append(args, "%"+t+"%")
+		}
+		l, d, err := searchCandidates(ctx, db, q, literal, "("+strings.Join(like, " OR ")+")", args, "id DESC", candidates)
+		if err != nil {
+			return nil, 0, fmt.Errorf("keyword candidates: %w", err)
+		}
+		merge(l, d)
+	}
+
+	results := make([]SearchResult, 0, len(logs))
+	for id, e := range logs {
+		r := SearchResult{Log: e, Keyword: keywordScore(e, terms)}
+		if d, ok := distances[id]; ok {
+			r.Vector = math.Max(0, math.Min(1, 1-d))
+		}
+		if age := q.To.Sub(e.Timestamp); age > 0 {
+			r.Recency = math.Pow(0.5, float64(age)/float64(halfLife))
+		} else {
+			r.Recency = 1
+		}
+		r.Score = w.Vector*r.Vector + w.Keyword*r.Keyword + w.Recency*r.Recency
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Log.ID > results[j].Log.ID
+	})
+	considered := len(results)
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, considered, nil
+}
+
+// searchHandler serves POST /api/search: a natural-language query ranked by
+// vector similarity, keyword match, and recency, with tunable weights. The
+// time window and cost limits match GET /api/logs.
+func searchHandler(db *store.DB, embedder Embedder, api APIConfig, cfg SearchConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req SearchRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		req.Query = strings.TrimSpace(req.Query)
+		if req.Query == "" {
+			writeError(w, http.StatusBadRequest, "'query' is required")
+			return
+		}
+		if len(req.Query) > maxSearchQuery {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("'query' longer than %d characters", maxSearchQuery))
+			return
+		}
+
+		weights := cfg.Weights
+		if req.Weights != nil {
+			weights = *req.Weights
+		}
+		weights, err := weights.Normalize()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		halfLifeStr := cfg.HalfLife
+		if req.HalfLife != "" {
+			halfLifeStr = req.HalfLife
+		}
+		halfLife, err := time.ParseDuration(halfLifeStr)
+		if err != nil || halfLife <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid 'half_life': %q", req.HalfLife))
+			return
+		}
+
+		q := LogQuery{
+			TenantID: tenantFromRequest(r),
+			To:       time.Now(),
+			Sources:  req.Sources,
+			Limit:    api.DefaultPageSize,
+			Force:    req.Force,
+		}
+		if req.To != nil {
+			q.To = *req.To
+		}
+		if req.From != nil {
+			q.From = *req.From
+		} else {
+			window, _ := time.ParseDuration(api.DefaultWindow)
+			q.From = q.To.Add(-window)
+		}
+		if !q.From.Before(q.To) {
+			writeError(w, http.StatusBadRequest, "'from' must be before 'to'")
+			return
+		}
+		if req.MinSeverity != nil {
+			q.MinSeverity = *req.MinSeverity
+		}
+		if req.Limit > 0 {
+			q.Limit = min(req.Limit, api.MaxPageSize)
+		}
+
+		cost := q.EstimateCost()
+		if cost > api.MaxQueryCost {
+			if !q.Force {
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+					"error":          "search too expensive; narrow the time range, add filters, or pass force=true",
+					"estimated_cost": cost,
+					"max_cost":       api.MaxQueryCost,
+				})
+				return
+			}
+			slog.Warn("Forced expensive search", "remote", r.RemoteAddr, "cost", cost, "max_cost", api.MaxQueryCost)
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+		results, considered, err := hybridSearch(ctx, db, embedder, q, req.Query, weights, halfLife, cfg.Candidates)
+		if err != nil {
+			slog.Error("Search failed", "err", err)
+			writeError(w, http.StatusInternalServerError, "search failed")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"results":    results,
+			"terms":      searchTerms(req.Query),
+			"weights":    weights,
+			"considered": considered,
+		})
+	}
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"1logx/log_ingestor/internal/hub"
+	"1logx/log_ingestor/internal/pipeline"
+	"1logx/log_ingestor/internal/store"
+)
+
+// simulatorFlags override the simulator config, shared by serve and simulate.
+type simulatorFlags struct {
+	rate            *float64
+	duration        *string
+	burstEvery      *string
+	burstLength     *string
+	burstMultiplier *float64
+}
+
+func addSimulatorFlags(fs *flag.FlagSet) simulatorFlags {
+	return simulatorFlags{
+		rate:            fs.Float64("rate", 0, "simulator target events/sec (overrides config)"),
+		duration:        fs.String("duration", "", "stop simulating after this long, e.g. 10m (overrides config)"),
+		burstEvery:      fs.String("burst-every", "", "start a rate burst this often, e.g. 1m"),
+		burstLength:     fs.String("burst-length", "", "how long each burst lasts, e.g. 5s"),
+		burstMultiplier: fs.Float64("burst-multiplier", 0, "rate multiplier during bursts"),
+	}
+}
+
+// apply overrides c with the flags that were set. Flags win over the config
+// on startup and on every reload.
+func (f simulatorFlags) apply(c SimulatorConfig) SimulatorConfig {
+	if *f.rate > 0 {
+		c.Rate = *f.rate
+	}
+	if *f.duration != "" {
+		c.Duration = *f.duration
+	}
+	if *f.burstEvery != "" || *f.burstLength != "" || *f.burstMultiplier > 0 {
+		c.Burst = BurstConfig{Every: *f.burstEvery, Length: *f.burstLength, Multiplier: *f.burstMultiplier}
+	}
+	return c
+}
+
+// ingestPipeline is the ingestor with every stage and engine it feeds, as
+// built from the config for both the server and the standalone simulator.
+type ingestPipeline struct {
+	ingestor   *pipeline.Ingestor
+	embedder   Embedder
+	parsers    *CustomParsers
+	canary     *CanaryMonitor  // nil without a canary parser or hunt pack
+	quality    *QualityTracker // nil when data quality scoring is disabled
+	joins      *JoinEngine     // nil when correlation joins are disabled
+	sigma      *SigmaEngine    // nil when Sigma rules are disabled
+	threat     *ThreatIntel    // nil when threat intel is disabled
+	reputation *IPReputation   // nil when IP reputation is disabled
+	// hub is the live stream stored entries and alerts are broadcast on.
+	hub    *streamHub
+	alerts *Alerts
+	// recent and aggregates are fed every stored entry; aggregates is nil
+	// when live aggregates are disabled.
+	recent     *RecentEvents
+	aggregates *LiveAggregates
+}
+
+// newPipeline builds the ingest pipeline, starts its background jobs, and
+// registers its reloadable parts with reloader.
+func newPipeline(db *store.DB, config Config, reloader *Reloader) (*ingestPipeline, error) {
+	embeddingConfig := config.Embedding.WithDefaults()
+	embedder, err := newEmbedder(embeddingConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedding config: %w", err)
+	}
+	p := &ingestPipeline{embedder: embedder}
+	redactionConfig := config.Redaction.WithDefaults()
+	redactor, err := NewRedactor(redactionConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redaction config: %w", err)
+	}
+	auditInterval, _ := time.ParseDuration(redactionConfig.AuditInterval)
+	go redactor.Run(db, auditInterval)
+	reloader.register("redaction", func(c Config) error {
+		return redactor.reload(c.Redaction.WithDefaults())
+	}, nil)
+	if p.parsers, err = NewCustomParsers(config.Parsers); err != nil {
+		return nil, fmt.Errorf("invalid parser config: %w", err)
+	}
+	reloader.register("parsers", func(c Config) error {
+		return p.parsers.reload(c.Parsers)
+	}, nil)
+	if p.canary, err = NewCanaryMonitor(config.Canary.WithDefaults(), redactor); err != nil {
+		return nil, fmt.Errorf("invalid canary config: %w", err)
+	}
+	p.hub = hub.New[*wsClient](config.Stream.WithDefaults().MaxClients)
+	p.alerts = NewAlerts(db, p.hub, config.Incidents)
+	p.recent = NewRecentEvents(config.Recent.WithDefaults())
+	if config.Aggregates.Enabled {
+		if p.aggregates, err = NewLiveAggregates(config.Aggregates.WithDefaults(), p.hub); err != nil {
+			return nil, fmt.Errorf("invalid aggregates config: %w", err)
+		}
+	}
+	if config.Quality.Enabled {
+		p.quality = NewQualityTracker(db, p.alerts, config.Quality.WithDefaults())
+	}
+	if config.Joins.Enabled {
+		joins, err := NewJoinEngine(db, p.alerts, config.Joins.WithDefaults())
+		if err != nil {
+			return nil, fmt.Errorf("load join rules: %w", err)
+		}
+		p.joins = joins
+		reloader.register("joins", func(c Config) error {
+			return joins.reload(c.Joins.WithDefaults())
+		}, func(c Config) []string { return []string{c.Joins.RulesPath} })
+	}
+	if config.Sigma.Enabled {
+		sigma, err := NewSigmaEngine(db, p.hub, config.Sigma)
+		if err != nil {
+			return nil, fmt.Errorf("load Sigma rules: %w", err)
+		}
+		p.sigma = sigma
+		reloader.register("sigma", func(c Config) error {
+			return sigma.reload(c.Sigma)
+		}, func(c Config) []string { return c.Sigma.Rules })
+	}
+	if config.ThreatIntel.Enabled {
+		if p.threat, err = NewThreatIntel(db, p.alerts, config.ThreatIntel.WithDefaults()); err != nil {
+			return nil, fmt.Errorf("invalid threat intel config: %w", err)
+		}
+		go p.threat.Run()
+	}
+	if config.Reputation.Enabled {
+		p.reputation = NewIPReputation(db, p.alerts, config.Reputation.WithDefaults())
+		go p.reputation.Run()
+	}
+	risk := NewRiskScorer(config.Risk.WithDefaults())
+	reloader.register("risk", func(c Config) error {
+		risk.setConfig(c.Risk.WithDefaults())
+		return nil
+	}, nil)
+	if p.joins != nil {
+		risk.rules = p.joins.hits
+	}
+	if p.threat != nil {
+		risk.threatIntel = threatScore
+	}
+
+	deps := pipeline.Deps{
+		// The stages run in order on every entry before it is stored.
+		Stages: []pipeline.Stage{
+			{Name: "parse", Run: applyStructuredMessage, Optional: true},
+			{Name: "custom", Run: p.parsers.applyCustomParsers, Optional: true},
+			{Name: "redact", Run: redactor.redactPII},
+			{Name: "defaults", Run: pipeline.ApplyDefaults},
+			{Name: "validate", Run: pipeline.ValidateEntry},
+			{Name: "threat", Run: p.threat.matchThreat, Optional: true},
+			{Name: "reputation", Run: p.reputation.scoreReputation, Optional: true},
+			{Name: "risk", Run: risk.scoreRisk, Optional: true},
+		},
+		Embedder:   embedder,
+		EmbedModel: embeddingConfig.ModelID(),
+	}
+	if !config.ChangeFeed.Enabled {
+		// Otherwise the change feed streams inserts.
+		deps.Live = func(entry LogEntry) {
+			p.recent.add(entry)
+			p.aggregates.observe(entry)
+			p.hub.BroadcastLog(entry)
+		}
+	}
+	if p.canary != nil {
+		deps.Canary = p.canary
+	}
+	if p.quality != nil {
+		deps.Quality = p.quality
+	}
+	if p.joins != nil {
+		deps.Joins = p.joins
+	}
+	if p.sigma != nil {
+		deps.Detectors = append(deps.Detectors, p.sigma)
+	}
+	if p.threat != nil {
+		deps.Detectors = append(deps.Detectors, p.threat)
+	}
+	if p.reputation != nil {
+		deps.Detectors = append(deps.Detectors, p.reputation)
+	}
+	p.ingestor = pipeline.New(db, deps)
+	if p.joins != nil {
+		p.joins.emit = p.ingestor.IngestDerived
+	}
+	return p, nil
+}
+
+// serveCommand starts the log simulator, ingest API, and WebSocket server.
+func serveCommand(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := fs.String("config", "../config.yaml", "path to config file")
+	simFlags := addSimulatorFlags(fs)
+	bench := fs.Bool("bench", false, "same as the simulate command's -bench")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *bench {
+		return simulateCommand(args)
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		fatal("Failed to load config", "err", err)
+	}
+	if err := setupLogging(config.Logging.WithDefaults()); err != nil {
+		fatal("Invalid logging config", "err", err)
+	}
+	slog.Info("Starting 1L0Gx Log Ingestor")
+	simConfig := simFlags.apply(config.Simulator)
+	var simDuration time.Duration
+	if simConfig.Duration != "" {
+		if simDuration, err = time.ParseDuration(simConfig.Duration); err != nil {
+			fatal("Invalid simulator duration", "err", err)
+		}
+	}
+
+	db, err := store.Open(config)
+	if err != nil {
+		fatal("Failed to connect to database", "driver", config.Database.WithDefaults().Driver, "err", err)
+	}
+	defer db.Close()
+	slog.Info("Connected to database", "driver", db.Driver())
+	reloader := NewReloader(db, *configPath)
+	reloader.register("logging", func(c Config) error {
+		return setLogLevel(c.Logging.WithDefaults().Level)
+	}, nil)
+
+	p, err := newPipeline(db, config, reloader)
+	if err != nil {
+		fatal("Failed to build ingest pipeline", "err", err)
+	}
+	ingestor, embedder, canary, quality, joins, sigma := p.ingestor, p.embedder, p.canary, p.quality, p.joins, p.sigma
+	h, alerts := p.hub, p.alerts
+	apiConfig := config.API.WithDefaults()
+
+	retry := NewRetryQueue(ingestor, config.Retry.WithDefaults())
+	ingestor.SetRetry(retry)
+	if n, err := retry.Drain(); err != nil {
+		slog.Warn("Failed to re-drain dead-letter file", "err", err)
+	} else if n > 0 {
+		slog.Info("Re-queued dead-lettered logs", "count", n)
+	}
+	go retry.Run()
+	var limits *IngestLimiter
+	if config.Limits.Enabled || config.Limits.Breaker.Enabled {
+		limits = NewIngestLimiter(config.Limits.WithDefaults(), retry, ingestor.Load())
+		ingestor.SetLimits(limits)
+		go limits.Run()
+		reloader.register("limits", func(c Config) error {
+			limits.setConfig(c.Limits.WithDefaults())
+			return nil
+		}, nil)
+	}
+	autoscaler := NewAutoscaler(config.Autoscale.WithDefaults(), retry, ingestor.Load())
+	go autoscaler.Run()
+
+	if err := config.Tenancy.Validate(); err != nil {
+		fatal("Invalid tenancy config", "err", err)
+	}
+	authConfig := config.Auth.WithDefaults()
+	keys, err := NewKeyStore(db, authConfig)
+	if err != nil {
+		fatal("Invalid auth config", "err", err)
+	}
+	if err := keys.Refresh(); err != nil {
+		slog.Warn("Failed to load API keys", "err", err)
+	}
+	go keys.Run()
+
+	fanout, err := NewFanout(config.Fanout.WithDefaults())
+	if err != nil {
+		fatal("Failed to start stream fan-out", "err", err)
+	}
+	if fanout != nil {
+		h.AddRelay(fanout)
+		go fanout.Run()
+	}
+	var bus *ClusterBus
+	if config.Cluster.Enabled {
+		clusterConfig := config.Cluster.WithDefaults()
+		if bus, err = NewClusterBus(clusterConfig, h, p.recent, p.aggregates); err != nil {
+			fatal("Failed to join cluster bus", "err", err)
+		}
+		slog.Info("Joined cluster bus", "backend", clusterConfig.Backend, "channel", clusterConfig.Channel, "replica", bus.id)
+		h.AddRelay(bus)
+		go bus.Run()
+	}
+	if config.ChangeFeed.Enabled {
+		feedConfig := config.ChangeFeed.WithDefaults()
+		if feedConfig.Database == "" {
+			feedConfig.Database = config.TiDB.Database
+		}
+		feed, err := NewChangeFeed(db, feedConfig, h, fanout, p.recent, p.aggregates)
+		if err != nil {
+			fatal("Invalid change feed config", "err", err)
+		}
+		go feed.Run()
+	}
+
+	// With tenancy enabled every endpoint needs a key to know its tenant, and
+	// with RBAC enabled one to know its role.
+	scopedAs := func(role string, h http.Handler) http.Handler {
+		return keys.Middleware(config.Tenancy.Enabled || config.RBAC.Enabled, requireRole(config.RBAC, role, h))
+	}
+	scoped := func(h http.HandlerFunc) http.Handler { return scopedAs(roleViewer, h) }
+	analyst := func(h http.HandlerFunc) http.Handler { return scopedAs(roleAnalyst, h) }
+	admin := func(h http.HandlerFunc) http.Handler { return scopedAs(roleAdmin, h) }
+	platform := func(h http.HandlerFunc) http.Handler { return scopedAs(rolePlatform, h) }
+
+	var schema *SchemaChecker
+	if config.Schema.Enabled {
+		if schema, err = NewSchemaChecker(db, alerts, config.Schema.WithDefaults(db.Schema())); err != nil {
+			slog.Warn("Schema drift detection disabled", "err", err)
+		} else {
+			go schema.Run()
+		}
+	}
+
+	retentionConfig := config.Retention.WithDefaults()
+	var archiver *Archiver
+	if retentionConfig.Archive.Enabled {
+		if archiver, err = NewArchiver(retentionConfig.Archive); err != nil {
+			fatal("Failed to configure log archive", "err", err)
+		}
+		slog.Info("Archiving expired logs", "backend", retentionConfig.Archive.Backend, "format", retentionConfig.Archive.Format)
+	}
+
+	// Start WebSocket, ingest, and query API server
+	streamConfig := config.Stream.WithDefaults()
+	compressionConfig := config.Compression.WithDefaults()
+	setupWSCompression(compressionConfig)
+	mux := http.NewServeMux()
+	mux.Handle("GET /health", healthHandler(db, schema, h))
+	mux.Handle("GET /autoscale", http.HandlerFunc(autoscaler.handler))
+	mux.Handle("/ws", scoped(wsHandler(db, h, apiConfig, streamConfig)))
+	mux.Handle("GET /api/admin/stream/qos", admin(qosHandler(streamConfig)))
+	mux.Handle("GET /api/admin/stream/clients", admin(listClientsHandler(h)))
+	mux.Handle("DELETE /api/admin/stream/clients/{id}", admin(disconnectClientHandler(db, h, streamConfig)))
+	mux.Handle("POST /api/admin/reload", platform(reloader.handler))
+	adminConfig := NewAdminConfig(config, simFlags, db)
+	reloader.register("admin", adminConfig.reload, nil)
+	mux.Handle("GET /api/admin/config", platform(adminConfig.handler))
+	mux.Handle("GET /api/admin/sources", admin(sourcesHandler(ingestor.Sources())))
+	mux.Handle("POST /api/admin/sources/{name}/pause", platform(pauseSourceHandler(db, ingestor.Sources(), true)))
+	mux.Handle("POST /api/admin/sources/{name}/resume", platform(pauseSourceHandler(db, ingestor.Sources(), false)))
+	reembedder := NewReembedder(db, embedder, config.Embedding.WithDefaults())
+	mux.Handle("POST /api/admin/embeddings/reembed", admin(reembedder.reembedHandler))
+	mux.Handle("GET /api/admin/jobs", admin(jobsHandler(db)))
+	mux.Handle("GET /api/admin/jobs/{id}", admin(jobHandler(db)))
+	mux.Handle("GET /api/audit", admin(auditHandler(db, apiConfig)))
+	if bus != nil {
+		mux.Handle("GET /api/admin/cluster", admin(http.HandlerFunc(bus.handler)))
+	}
+	if limits != nil {
+		mux.Handle("GET /api/admin/ingest/limits", admin(limitsHandler(limits)))
+	}
+	go streamQoS.Run(streamConfig)
+	if p.aggregates != nil {
+		go p.aggregates.Run()
+	}
+	mux.Handle("GET /api/logs/replay", scoped(replayHandler(db, apiConfig)))
+	requireIngestKey := authConfig.RequireIngestKey || config.Tenancy.Enabled
+	for _, name := range []string{"http", "windows", "otlp"} {
+		ingestor.Sources().Add(name)
+	}
+	mux.Handle("POST /api/ingest", keys.Middleware(requireIngestKey, ingestHandler(ingestor)))
+	mux.Handle("POST /v1/logs", keys.Middleware(requireIngestKey, http.HandlerFunc((&otlpReceiver{in: ingestor}).httpHandler)))
+	mux.Handle("POST /api/ingest/windows", keys.Middleware(requireIngestKey, windowsIngestHandler(ingestor)))
+	pipelines, err := NewPipelines(config.Pipelines, ingestor, p.parsers, retry)
+	if err != nil {
+		fatal("Invalid pipelines config", "err", err)
+	}
+	mux.Handle("POST /api/pipelines/{name}/ingest", keys.Middleware(requireIngestKey, http.HandlerFunc(pipelines.ingestHandler)))
+	mux.Handle("GET /api/admin/pipelines", admin(pipelines.handler(ingestor)))
+	mux.Handle("POST /api/v1/pipeline/test", analyst(pipelineTestHandler(ingestor)))
+	mux.Handle("POST /api/parsers/test", analyst(parserTestHandler(p.parsers)))
+	mux.Handle("POST /api/heartbeat", keys.Middleware(true, http.HandlerFunc(heartbeatHandler)))
+	mux.Handle("/api/logs", scoped(logsHandler(db, apiConfig)))
+	mux.Handle("GET /api/logs/recent", scoped(recentHandler(apiConfig, p.recent)))
+	mux.Handle("GET /api/export", analyst(exportHandler(db, apiConfig, config.Export.WithDefaults())))
+	mux.Handle("POST /api/search", scoped(searchHandler(db, embedder, apiConfig, config.Search.WithDefaults())))
+	mux.Handle("POST /api/incidents/summarize", analyst(summarizeHandler(db, h, NewSummarizer(config.LLM.WithDefaults()), apiConfig)))
+	mux.Handle("GET /api/incidents", scoped(listIncidentsHandler(db, apiConfig)))
+	mux.Handle("POST /api/incidents", analyst(createIncidentHandler(db, h)))
+	mux.Handle("GET /api/incidents/{id}", scoped(getIncidentHandler(db)))
+	mux.Handle("PATCH /api/incidents/{id}", analyst(updateIncidentHandler(db, h)))
+	mux.Handle("POST /api/incidents/{id}/logs", analyst(attachLogsHandler(db, h)))
+	mux.Handle("POST /api/incidents/{id}/comments", analyst(commentIncidentHandler(db, h)))
+	mux.Handle("POST /api/logs/delete", admin(deleteLogsHandler(db)))
+	mux.Handle("POST /api/logs/restore", admin(restoreLogsHandler(db)))
+	mux.Handle("GET /api/holds", scoped(holdsHandler(db)))
+	mux.Handle("POST /api/holds", admin(holdsHandler(db)))
+	mux.Handle("POST /api/holds/{id}/release", admin(releaseHoldHandler(db)))
+	if archiver != nil {
+		mux.Handle("POST /api/archive/restore", admin(archiveRestoreHandler(db, archiver)))
+	}
+	if config.Forecast.Enabled {
+		forecaster := NewForecaster(db, alerts, config.Forecast.WithDefaults())
+		mux.Handle("GET /api/forecast", scoped(forecaster.handler))
+		go forecaster.Run()
+	}
+	if canary != nil {
+		mux.Handle("GET /api/canary", admin(canary.handler))
+		go canary.Run()
+	}
+	if quality != nil {
+		mux.Handle("GET /api/quality", scoped(quality.handler))
+		go quality.Run()
+	}
+	if joins != nil {
+		mux.Handle("GET /api/joins", scoped(joins.handler))
+		mux.Handle("GET /api/correlations", scoped(correlationsHandler(db, apiConfig)))
+		mux.Handle("GET /api/correlations/{id}", scoped(correlationHandler(db)))
+		go joins.Run()
+	}
+	if p.threat != nil {
+		mux.Handle("GET /api/threat-intel", scoped(p.threat.handler))
+	}
+	if p.reputation != nil {
+		mux.Handle("GET /api/ips/{ip}", scoped(p.reputation.handler))
+	}
+	if sigma != nil {
+		mux.Handle("GET /api/sigma/rules", scoped(sigma.rulesHandler))
+		mux.Handle("GET /api/detections", scoped(detectionsHandler(db, apiConfig)))
+	}
+	mux.Handle("GET /api/hunts/findings", scoped(findingsHandler(db, apiConfig)))
+	mux.Handle("POST /api/hunts/findings/{id}/review", analyst(reviewFindingHandler(db)))
+	if config.Hunts.Enabled {
+		hunts, err := NewHuntRunner(db, config.Hunts.WithDefaults())
+		if err != nil {
+			fatal("Failed to load hunting pack", "err", err)
+		}
+		if canary != nil && canary.cfg.HuntPack != "" {
+			pack, err := loadHuntPack(canary.cfg.HuntPack)
+			if err != nil {
+				fatal("Failed to load canary hunting pack", "err", err)
+			}
+			hunts.canary, hunts.monitor = &pack, canary
+		}
+		mux.Handle("GET /api/hunts", scoped(http.HandlerFunc(hunts.huntPackHandler)))
+		go hunts.Run()
+		reloader.register("hunts", func(c Config) error {
+			return hunts.reload(c.Hunts.WithDefaults())
+		}, func(c Config) []string { return []string{c.Hunts.PackPath} })
+	}
+
+	mux.Handle("GET /api/clusters", scoped(clustersHandler(db, apiConfig)))
+	mux.Handle("GET /api/clusters/{id}", scoped(clusterHandler(db, apiConfig)))
+	if config.Clustering.Enabled {
+		clusterer, err := NewClusterer(db, h, config.Clustering.WithDefaults())
+		if err != nil {
+			fatal("Failed to load log clusters", "err", err)
+		}
+		go clusterer.Run()
+	}
+
+	reports := config.Reports.WithDefaults()
+	reporter := NewReporter(db, embedder, apiConfig, config.Search.WithDefaults(), reports)
+	mux.Handle("GET /api/searches", scoped(http.HandlerFunc(reporter.savedSearchesHandler)))
+	mux.Handle("POST /api/searches", analyst(http.HandlerFunc(reporter.savedSearchesHandler)))
+	mux.Handle("GET /api/searches/{id}", scoped(http.HandlerFunc(reporter.savedSearchHandler)))
+	mux.Handle("PUT /api/searches/{id}", analyst(http.HandlerFunc(reporter.savedSearchHandler)))
+	mux.Handle("DELETE /api/searches/{id}", analyst(http.HandlerFunc(reporter.savedSearchHandler)))
+	mux.Handle("POST /api/searches/{id}/run", analyst(http.HandlerFunc(reporter.runSearchHandler)))
+	mux.Handle("GET /api/searches/{id}/runs", scoped(reportRunsHandler(db, apiConfig)))
+	mux.Handle("GET /api/searches/{id}/runs/{run}", scoped(reportRunHandler(db)))
+	if reports.Enabled {
+		go reporter.Run()
+	}
+
+	handler := gzipHandler(compressionConfig, mux)
+	plain := handler
+	if tlsConfig := config.TLS.WithDefaults(); tlsConfig.Enabled {
+		if err := tlsConfig.Validate(); err != nil {
+			fatal("Invalid TLS config", "err", err)
+		}
+		plain = httpsRedirect(tlsConfig, handler)
+		go func() {
+			if err := serveTLS(tlsConfig, handler, reloader); err != nil {
+				fatal("HTTPS server failed", "err", err)
+			}
+		}()
+	}
+	go func() {
+		slog.Info("HTTP server running", "addr", ":8080", "websocket", "/ws", "api", "/api/logs")
+		if err := http.ListenAndServe(":8080", plain); err != nil {
+			fatal("HTTP server failed", "err", err)
+		}
+	}()
+
+	if config.GRPC.Enabled {
+		ingestor.Sources().Add("grpc")
+		go func() {
+			if err := runGRPCServer(config.GRPC.WithDefaults(), ingestor, keys, requireIngestKey); err != nil {
+				fatal("gRPC server failed", "err", err)
+			}
+		}()
+	}
+	if config.NetFlow.Enabled {
+		ingestor.Sources().Add("netflow")
+		collector, err := NewNetFlowCollector(config.NetFlow.WithDefaults(), ingestor)
+		if err != nil {
+			fatal("Invalid netflow config", "err", err)
+		}
+		go func() {
+			if err := collector.Run(); err != nil {
+				fatal("NetFlow collector failed", "err", err)
+			}
+		}()
+	}
+	for _, p := range pipelines {
+		go func() {
+			if err := p.Run(); err != nil {
+				fatal("Ingestion pipeline failed", "pipeline", p.cfg.Name, "err", err)
+			}
+		}()
+	}
+
+	if config.Retention.Enabled {
+		go runRetention(db, retentionConfig, archiver)
+	}
+
+	var sim *Simulator
+	if simConfig.Enabled {
+		if sim, err = newSimulator(simConfig); err != nil {
+			fatal("Failed to load simulator scenarios", "err", err)
+		}
+	}
+	ingestor.Sources().Add("simulator")
+	simulation := newSimRunner(func(entry LogEntry) {
+		_, err := ingestor.IngestFrom(pipeline.Origin{Input: "simulator", Internal: true}, entry)
+		if err != nil && !errors.Is(err, pipeline.ErrQueuedForRetry) && !errors.Is(err, pipeline.ErrSourcePaused) {
+			slog.Error("Failed to ingest simulated log", "err", err)
+		}
+	})
+	reloader.register("simulator", func(c Config) error {
+		return simulation.reload(simFlags.apply(c.Simulator))
+	}, func(c Config) []string { return []string{simFlags.apply(c.Simulator).Scenarios} })
+
+	go watchShutdown(h, streamConfig)
+	reloadConfig := config.Reload.WithDefaults()
+	go reloader.watchSIGHUP()
+	if reloadConfig.Watch {
+		interval, _ := time.ParseDuration(reloadConfig.Interval)
+		go reloader.watchFiles(interval)
+	}
+
+	ctx := context.Background()
+	if simDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, simDuration)
+		defer cancel()
+	}
+	simulation.Run(ctx, sim)
+	if simDuration > 0 {
+		slog.Info("Simulation finished; still serving")
+	}
+	select {}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"1logx/log_ingestor/internal/pipeline"
+	"1logx/log_ingestor/internal/store"
+)
+
+// newTestPipeline builds the ingest pipeline on a fresh SQLite database with
+// the SQLite schema applied.
+func newTestPipeline(t *testing.T) (*ingestPipeline, *store.DB) {
+	t.Helper()
+	config := Config{Database: DatabaseConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "logs.db")}}
+	db, err := store.Open(config)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	script, err := os.ReadFile(db.Schema())
+	if err != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+	for _, stmt := range schemaStatements(string(script)) {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("apply schema: %v\n%s", err, stmt)
+		}
+	}
+	p, err := newPipeline(db, config, NewReloader(db, ""))
+	if err != nil {
+		t.Fatalf("newPipeline: %v", err)
+	}
+	return p, db
+}
+
+func TestPipelineIngestSQLite(t *testing.T) {
+	p, db := newTestPipeline(t)
+
+	tests := []struct {
+		name    string
+		entry   LogEntry
+		want    LogEntry
+		wantErr error
+	}{
+		{
+			name:  "defaults",
+			entry: LogEntry{Message: "service started", Severity: SeverityInfo},
+			want:  LogEntry{TenantID: defaultTenant, Source: "Unknown", Message: "service started", Severity: SeverityInfo},
+		},
+		{
+			name:  "tenant and source kept",
+			entry: LogEntry{TenantID: "acme", Source: "Auth", Message: "login failed", Severity: SeverityWarning, IPAddress: "10.0.0.7"},
+			want:  LogEntry{TenantID: "acme", Source: "Auth", Message: "login failed", Severity: SeverityWarning, IPAddress: "10.0.0.7"},
+		},
+		{
+			name:  "cef parsed",
+			entry: LogEntry{Message: `CEF:0|Palo Alto|PAN-OS|10.1|THREAT|Port scan|8|src=10.0.0.5 dst=10.0.0.9 msg=scan detected`},
+			want: LogEntry{
+				TenantID: defaultTenant, Source: "PAN-OS", Message: "Port scan: scan detected", Severity: SeverityAlert, IPAddress: "10.0.0.5",
+				Fields: map[string]string{"device_vendor": "Palo Alto", "signature_id": "THREAT"},
+			},
+		},
+		{
+			name:    "empty message",
+			entry:   LogEntry{Message: "  "},
+			wantErr: pipeline.ErrInvalidEntry,
+		},
+		{
+			name:    "invalid field key",
+			entry:   LogEntry{Message: "bad fields", Fields: map[string]string{"a b": "c"}},
+			wantErr: pipeline.ErrInvalidEntry,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored, err := p.ingestor.Ingest(tt.entry)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Ingest() error = %v, want %v", err, tt.wantErr)
+				}
+				if stored.ID != 0 {
+					t.Fatalf("rejected entry was stored with id %d", stored.ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Ingest() error = %v", err)
+			}
+			if stored.ID == 0 {
+				t.Fatal("stored entry has no id")
+			}
+
+			var got LogEntry
+			var severity string
+			var ip, fields *string
+			err = db.QueryRow(`SELECT tenant_id, source, severity, message, ip_address, fields FROM logs WHERE id = ?`, stored.ID).
+				Scan(&got.TenantID, &got.Source, &severity, &got.Message, &ip, &fields)
+			if err != nil {
+				t.Fatalf("read stored log: %v", err)
+			}
+			if got.TenantID != tt.want.TenantID || got.Source != tt.want.Source || got.Message != tt.want.Message {
+				t.Errorf("stored tenant, source, message = %q, %q, %q, want %q, %q, %q",
+					got.TenantID, got.Source, got.Message, tt.want.TenantID, tt.want.Source, tt.want.Message)
+			}
+			if severity != tt.want.Severity.String() {
+				t.Errorf("stored severity = %q, want %q", severity, tt.want.Severity)
+			}
+			if ip != nil {
+				got.IPAddress = *ip
+			}
+			if got.IPAddress != tt.want.IPAddress {
+				t.Errorf("stored ip_address = %q, want %q", got.IPAddress, tt.want.IPAddress)
+			}
+			if fields != nil {
+				if got.Fields, err = store.DecodeFields([]byte(*fields)); err != nil {
+					t.Fatalf("decode fields: %v", err)
+				}
+			}
+			for k, v := range tt.want.Fields {
+				if got.Fields[k] != v {
+					t.Errorf("stored fields[%q] = %q, want %q", k, got.Fields[k], v)
+				}
+			}
+
+			recent := p.recent.query(tt.want.TenantID, RecentQuery{Limit: 1})
+			if len(recent) == 0 || recent[0].ID != stored.ID {
+				t.Errorf("stored entry %d isn't the newest in the recent buffer", stored.ID)
+			}
+		})
+	}
+}
+
+func TestPipelineIngestHandlerRateLimited(t *testing.T) {
+	p, _ := newTestPipeline(t)
+	limits := LimitsConfig{Enabled: true, PerSource: RateLimit{Rate: 0.001, Burst: 2}}.WithDefaults()
+	p.ingestor.SetLimits(NewIngestLimiter(limits, nil, p.ingestor.Load()))
+	ps, err := NewPipelines([]PipelineConfig{{Name: "edge", Input: PipelineInput{Type: "http"}}}, p.ingestor, p.parsers, nil)
+	if err != nil {
+		t.Fatalf("NewPipelines: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/pipelines/edge/ingest", strings.NewReader("one\ntwo\nthree\n"))
+	r.Header.Set("Content-Type", "text/plain")
+	r.SetPathValue("name", "edge")
+	w := httptest.NewRecorder()
+	ps.ingestHandler(w, r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("POST /api/pipelines/edge/ingest = %d, want %d: %s", w.Code, http.StatusTooManyRequests, w.Body)
+	}
+	var resp struct{ Index, Queued int }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Index != 2 || resp.Queued != 2 {
+		t.Errorf("stopped at index %d with %d queued, want 2 and 2", resp.Index, resp.Queued)
+	}
+}
@@ -0,0 +1,603 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SigmaRule is a Sigma detection rule (https://sigmahq.io), as far as the
+// fields the ingestor uses.
+type SigmaRule struct {
+	ID          string         `yaml:"id" json:"id"`
+	Title       string         `yaml:"title" json:"title"`
+	Status      string         `yaml:"status" json:"status,omitempty"`
+	Description string         `yaml:"description" json:"description,omitempty"`
+	Level       string         `yaml:"level" json:"level"`
+	Tags        []string       `yaml:"tags" json:"tags,omitempty"`
+	LogSource   sigmaLogSource `yaml:"logsource" json:"logsource"`
+	Detection   map[string]any `yaml:"detection" json:"-"`
+
+	match func(*sigmaEvent) bool
+	file  string
+}
+
+type sigmaLogSource struct {
+	Product  string `yaml:"product" json:"product,omitempty"`
+	Service  string `yaml:"service" json:"service,omitempty"`
+	Category string `yaml:"category" json:"category,omitempty"`
+}
+
+// sigmaLevels maps Sigma levels onto severities.
+var sigmaLevels = map[string]Severity{
+	"informational": SeverityInfo,
+	"low":           SeverityInfo,
+	"medium":        SeverityWarning,
+	"high":          SeverityAlert,
+	"critical":      SeverityCritical,
+}
+
+// sigmaEvent resolves Sigma field names against an entry.
+type sigmaEvent struct {
+	entry    LogEntry
+	fieldMap map[string]string
+}
+
+// field returns the named field. Names go through the configured field
+// map, then match the entry's fields and labels, then its core columns.
+func (ev *sigmaEvent) field(name string) (string, bool) {
+	if mapped, ok := ev.fieldMap[name]; ok {
+		name = mapped
+	}
+	e := ev.entry
+	if kind, key, ok := strings.Cut(name, "."); ok && (kind == "field" || kind == "label") {
+		m := e.Fields
+		if kind == "label" {
+			m = e.Labels
+		}
+		v, ok := m[key]
+		return v, ok
+	}
+	if v, ok := e.Fields[name]; ok {
+		return v, true
+	}
+	if v, ok := e.Labels[name]; ok {
+		return v, true
+	}
+	switch strings.ToLower(name) {
+	case "message", "msg":
+		return e.Message, true
+	case "source":
+		return e.Source, true
+	case "severity":
+		return e.Severity.String(), true
+	case "ip_address", "ip":
+		return e.IPAddress, e.IPAddress != ""
+	case "tenant_id":
+		return e.TenantID, true
+	}
+	return "", false
+}
+
+// keyword reports whether any of the entry's text contains the pattern,
+// for Sigma's keyword lists.
+func (ev *sigmaEvent) keyword(p sigmaPattern) bool {
+	if p.match(ev.entry.Message) {
+		return true
+	}
+	for _, v := range ev.entry.Fields {
+		if p.match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSigmaRule reads one rule and compiles its detection. Features the
+// ingestor can't evaluate (aggregations, near, encoding modifiers) are
+// errors, so the rule is skipped rather than half-applied.
+func parseSigmaRule(data []byte) (*SigmaRule, error) {
+	var r SigmaRule
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+	if r.Title == "" {
+		return nil, fmt.Errorf("missing title")
+	}
+	if r.ID == "" {
+		r.ID = r.Title
+	}
+	if r.Level == "" {
+		r.Level = "medium"
+	}
+	if _, ok := sigmaLevels[r.Level]; !ok {
+		return nil, fmt.Errorf("unknown level %q", r.Level)
+	}
+
+	rawCond, ok := r.Detection["condition"]
+	if !ok {
+		return nil, fmt.Errorf("detection has no condition")
+	}
+	var conditions []string
+	switch c := rawCond.(type) {
+	case string:
+		conditions = []string{c}
+	case []any:
+		// A list of conditions means any of them.
+		for _, v := range c {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("condition must be a string or list of strings")
+			}
+			conditions = append(conditions, s)
+		}
+	default:
+		return nil, fmt.Errorf("condition must be a string or list of strings")
+	}
+
+	searches := map[string]func(*sigmaEvent) bool{}
+	for name, def := range r.Detection {
+		if name == "condition" || name == "timeframe" {
+			continue
+		}
+		m, err := compileSigmaSearch(def)
+		if err != nil {
+			return nil, fmt.Errorf("detection %s: %w", name, err)
+		}
+		searches[name] = m
+	}
+
+	var matchers []func(*sigmaEvent) bool
+	for _, cond := range conditions {
+		m, err := compileSigmaCondition(cond, searches)
+		if err != nil {
+			return nil, fmt.Errorf("condition %q: %w", cond, err)
+		}
+		matchers = append(matchers, m)
+	}
+	r.match = anyOf(matchers)
+	return &r, nil
+}
+
+func anyOf(ms []func(*sigmaEvent) bool) func(*sigmaEvent) bool {
+	if len(ms) == 1 {
+		return ms[0]
+	}
+	return func(ev *sigmaEvent) bool {
+		for _, m := range ms {
+			if m(ev) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func allOf(ms []func(*sigmaEvent) bool) func(*sigmaEvent) bool {
+	return func(ev *sigmaEvent) bool {
+		for _, m := range ms {
+			if !m(ev) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// compileSigmaSearch compiles one named search: a map of field matches
+// (all must hold), a list of such maps (any), or a list of keywords (any).
+func compileSigmaSearch(def any) (func(*sigmaEvent) bool, error) {
+	switch d := def.(type) {
+	case map[string]any:
+		return compileSigmaMap(d)
+	case []any:
+		var ms []func(*sigmaEvent) bool
+		for _, item := range d {
+			if m, ok := item.(map[string]any); ok {
+				f, err := compileSigmaMap(m)
+				if err != nil {
+					return nil, err
+				}
+				ms = append(ms, f)
+				continue
+			}
+			p, err := newSigmaPattern(sigmaString(item), "contains", false)
+			if err != nil {
+				return nil, err
+			}
+			ms = append(ms, func(ev *sigmaEvent) bool { return ev.keyword(p) })
+		}
+		return anyOf(ms), nil
+	case string:
+		p, err := newSigmaPattern(d, "contains", false)
+		if err != nil {
+			return nil, err
+		}
+		return func(ev *sigmaEvent) bool { return ev.keyword(p) }, nil
+	}
+	return nil, fmt.Errorf("unsupported search of type %T", def)
+}
+
+// compileSigmaMap compiles field|modifier: value(s) pairs, all of which
+// must match.
+func compileSigmaMap(m map[string]any) (func(*sigmaEvent) bool, error) {
+	var ms []func(*sigmaEvent) bool
+	for key, raw := range m {
+		f, err := compileSigmaField(key, raw)
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, f)
+	}
+	return allOf(ms), nil
+}
+
+func compileSigmaField(key string, raw any) (func(*sigmaEvent) bool, error) {
+	parts := strings.Split(key, "|")
+	field, mods := parts[0], parts[1:]
+
+	var values []any
+	if list, ok := raw.([]any); ok {
+		values = list
+	} else {
+		values = []any{raw}
+	}
+
+	op, all, cased := "", false, false
+	for _, mod := range mods {
+		switch mod {
+		case "contains", "startswith", "endswith", "re", "cidr", "exists", "lt", "lte", "gt", "gte":
+			if op != "" {
+				return nil, fmt.Errorf("%s: conflicting modifiers", key)
+			}
+			op = mod
+		case "all":
+			all = true
+		case "cased":
+			cased = true
+		case "i", "m", "s":
+			// Regex flags, applied below.
+		default:
+			return nil, fmt.Errorf("%s: unsupported modifier %q", key, mod)
+		}
+	}
+
+	var preds []func(string, bool) bool // value, present
+	for _, v := range values {
+		pred, err := sigmaValuePredicate(op, mods, v, cased)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		preds = append(preds, pred)
+	}
+	if len(preds) == 0 {
+		return nil, fmt.Errorf("%s: no values", key)
+	}
+
+	return func(ev *sigmaEvent) bool {
+		v, ok := "", false
+		if field != "" {
+			v, ok = ev.field(field)
+		} else {
+			v, ok = ev.entry.Message, true
+		}
+		for _, p := range preds {
+			hit := p(v, ok)
+			if all && !hit {
+				return false
+			}
+			if !all && hit {
+				return true
+			}
+		}
+		return all
+	}, nil
+}
+
+// sigmaValuePredicate compiles one value under op.
+func sigmaValuePredicate(op string, mods []string, v any, cased bool) (func(string, bool) bool, error) {
+	switch op {
+	case "exists":
+		want, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("exists needs true or false")
+		}
+		return func(_ string, present bool) bool { return present == want }, nil
+	case "re":
+		flags := ""
+		for _, m := range mods {
+			if m == "i" || m == "m" || m == "s" {
+				flags += m
+			}
+		}
+		expr := sigmaString(v)
+		if flags != "" {
+			expr = "(?" + flags + ")" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		return func(s string, present bool) bool { return present && re.MatchString(s) }, nil
+	case "cidr":
+		p, err := netip.ParsePrefix(sigmaString(v))
+		if err != nil {
+			return nil, err
+		}
+		return func(s string, present bool) bool {
+			addr, err := netip.ParseAddr(s)
+			return present && err == nil && p.Contains(addr.Unmap())
+		}, nil
+	case "lt", "lte", "gt", "gte":
+		want, err := strconv.ParseFloat(sigmaString(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s needs a number", op)
+		}
+		return func(s string, present bool) bool {
+			n, err := strconv.ParseFloat(s, 64)
+			if !present || err != nil {
+				return false
+			}
+			switch op {
+			case "lt":
+				return n < want
+			case "lte":
+				return n <= want
+			case "gt":
+				return n > want
+			}
+			return n >= want
+		}, nil
+	}
+	if v == nil {
+		// null matches a missing or empty field.
+		return func(s string, present bool) bool { return !present || s == "" }, nil
+	}
+	p, err := newSigmaPattern(sigmaString(v), op, cased)
+	if err != nil {
+		return nil, err
+	}
+	return func(s string, present bool) bool { return present && p.match(s) }, nil
+}
+
+// sigmaString renders a YAML scalar the way Sigma compares it.
+func sigmaString(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// sigmaPattern is a Sigma string value: * and ? are wildcards, \ escapes,
+// and matching is case-insensitive unless cased.
+type sigmaPattern struct {
+	exact string // when there are no wildcards
+	fold  bool
+	mode  string // "", contains, startswith, or endswith
+	re    *regexp.Regexp
+}
+
+func newSigmaPattern(s, mode string, cased bool) (sigmaPattern, error) {
+	p := sigmaPattern{fold: !cased, mode: mode}
+	var (
+		b        strings.Builder
+		wildcard bool
+		lit      strings.Builder
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(`*?\`, s[i+1]) >= 0:
+			i++
+			b.WriteString(regexp.QuoteMeta(string(s[i])))
+			lit.WriteByte(s[i])
+		case c == '*':
+			b.WriteString(".*")
+			wildcard = true
+		case c == '?':
+			b.WriteString(".")
+			wildcard = true
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			lit.WriteByte(c)
+		}
+	}
+	if !wildcard {
+		p.exact = lit.String()
+		if p.fold {
+			p.exact = strings.ToLower(p.exact)
+		}
+		return p, nil
+	}
+	expr := b.String()
+	switch mode {
+	case "contains":
+		expr = ".*" + expr + ".*"
+	case "startswith":
+		expr += ".*"
+	case "endswith":
+		expr = ".*" + expr
+	}
+	flags := "(?s)"
+	if p.fold {
+		flags = "(?is)"
+	}
+	re, err := regexp.Compile(flags + "^" + expr + "$")
+	if err != nil {
+		return p, err
+	}
+	p.re = re
+	return p, nil
+}
+
+func (p sigmaPattern) match(s string) bool {
+	if p.re != nil {
+		return p.re.MatchString(s)
+	}
+	if p.fold {
+		s = strings.ToLower(s)
+	}
+	switch p.mode {
+	case "contains":
+		return strings.Contains(s, p.exact)
+	case "startswith":
+		return strings.HasPrefix(s, p.exact)
+	case "endswith":
+		return strings.HasSuffix(s, p.exact)
+	}
+	return s == p.exact
+}
+
+// compileSigmaCondition parses a condition such as
+// "selection and not 1 of filter_*" over the rule's named searches.
+func compileSigmaCondition(cond string, searches map[string]func(*sigmaEvent) bool) (func(*sigmaEvent) bool, error) {
+	if strings.Contains(cond, "|") {
+		return nil, fmt.Errorf("aggregations are not supported")
+	}
+	p := &sigmaCondParser{tokens: tokenizeSigmaCondition(cond), searches: searches}
+	m, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return m, nil
+}
+
+func tokenizeSigmaCondition(cond string) []string {
+	cond = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(cond)
+	return strings.Fields(cond)
+}
+
+// sigmaCondParser is a recursive descent parser for conditions:
+//
+//	or      = and { "or" and }
+//	and     = not { "and" not }
+//	not     = "not" not | primary
+//	primary = "(" or ")" | ("1" | "any" | "all") "of" (pattern | "them") | name
+type sigmaCondParser struct {
+	tokens   []string
+	pos      int
+	searches map[string]func(*sigmaEvent) bool
+}
+
+func (p *sigmaCondParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return strings.ToLower(p.tokens[p.pos])
+	}
+	return ""
+}
+
+func (p *sigmaCondParser) next() string {
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *sigmaCondParser) or() (func(*sigmaEvent) bool, error) {
+	m, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	ms := []func(*sigmaEvent) bool{m}
+	for p.peek() == "or" {
+		p.next()
+		if m, err = p.and(); err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	return anyOf(ms), nil
+}
+
+func (p *sigmaCondParser) and() (func(*sigmaEvent) bool, error) {
+	m, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	ms := []func(*sigmaEvent) bool{m}
+	for p.peek() == "and" {
+		p.next()
+		if m, err = p.not(); err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	if len(ms) == 1 {
+		return m, nil
+	}
+	return allOf(ms), nil
+}
+
+func (p *sigmaCondParser) not() (func(*sigmaEvent) bool, error) {
+	if p.peek() != "not" {
+		return p.primary()
+	}
+	p.next()
+	m, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	return func(ev *sigmaEvent) bool { return !m(ev) }, nil
+}
+
+func (p *sigmaCondParser) primary() (func(*sigmaEvent) bool, error) {
+	switch tok := p.peek(); tok {
+	case "":
+		return nil, fmt.Errorf("unexpected end of condition")
+	case "(":
+		p.next()
+		m, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.next()
+		return m, nil
+	case "1", "any", "all":
+		p.next()
+		if p.peek() != "of" {
+			return nil, fmt.Errorf("expected 'of' after %q", tok)
+		}
+		p.next()
+		if p.peek() == "" {
+			return nil, fmt.Errorf("expected a search pattern after 'of'")
+		}
+		pattern := p.next()
+		if strings.ToLower(pattern) == "them" {
+			pattern = "*"
+		}
+		var ms []func(*sigmaEvent) bool
+		for name, m := range p.searches {
+			if ok, _ := path.Match(pattern, name); ok && !strings.HasPrefix(name, "_") {
+				ms = append(ms, m)
+			}
+		}
+		if len(ms) == 0 {
+			return nil, fmt.Errorf("no search matches %q", pattern)
+		}
+		if tok == "all" {
+			return allOf(ms), nil
+		}
+		return anyOf(ms), nil
+	case "near":
+		return nil, fmt.Errorf("near is not supported")
+	}
+	name := p.next()
+	m, ok := p.searches[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown search %q", name)
+	}
+	return m, nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSigmaRuleMatch(t *testing.T) {
+	failedLogon := LogEntry{
+		Source:    "Security",
+		Severity:  SeverityWarning,
+		Message:   "An account failed to log on.",
+		IPAddress: "203.0.113.9",
+		Fields:    map[string]string{"EventID": "4625", "TargetUserName": "Administrator", "LogonType": "10", "ProcessName": `C:\Windows\System32\svchost.exe`},
+		Labels:    map[string]string{"host": "dc01"},
+	}
+	tests := []struct {
+		name      string
+		detection string
+		fieldMap  map[string]string
+		entry     LogEntry
+		want      bool
+	}{
+		{
+			name:      "field equality is case-insensitive",
+			detection: "sel: {EventID: 4625, TargetUserName: administrator}\ncondition: sel",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "cased",
+			detection: "sel: {TargetUserName|cased: administrator}\ncondition: sel",
+			entry:     failedLogon,
+		},
+		{
+			name:      "value list is any",
+			detection: "sel: {LogonType: [2, 10]}\ncondition: sel",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "all modifier",
+			detection: "sel: {ProcessName|contains|all: [System32, svchost]}\ncondition: sel",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "all modifier with a miss",
+			detection: "sel: {ProcessName|contains|all: [System32, lsass]}\ncondition: sel",
+			entry:     failedLogon,
+		},
+		{
+			name:      "wildcards and escapes",
+			detection: `sel: {ProcessName: 'C:\\Windows\\*\\svc?ost.exe'}` + "\ncondition: sel",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "escaped wildcard is literal",
+			detection: `sel: {TargetUserName: 'Admin\*'}` + "\ncondition: sel",
+			entry:     failedLogon,
+		},
+		{
+			name:      "startswith and endswith",
+			detection: "a: {ProcessName|startswith: 'c:\\windows'}\nb: {ProcessName|endswith: '.EXE'}\ncondition: a and b",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "regex with flags",
+			detection: "sel: {TargetUserName|re|i: '^admin.*$'}\ncondition: sel",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "cidr on the ip column",
+			detection: "sel: {ip_address|cidr: 203.0.113.0/24}\ncondition: sel",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "numeric comparison",
+			detection: "sel: {LogonType|gte: 10, EventID|lt: 5000}\ncondition: sel",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "exists",
+			detection: "sel: {SubjectUserName|exists: false, host|exists: true}\ncondition: sel",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "null matches a missing field",
+			detection: "sel: {SubjectUserName: null}\ncondition: sel",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "keywords search the message and fields",
+			detection: "keywords: [mimikatz, svchost]\ncondition: keywords",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "list of maps is any",
+			detection: "sel:\n  - {EventID: 4624}\n  - {EventID: 4625, LogonType: 10}\ncondition: sel",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "not with a filter",
+			detection: "sel: {EventID: 4625}\nfilter: {TargetUserName|endswith: '$'}\ncondition: sel and not filter",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "parentheses and or",
+			detection: "a: {EventID: 4624}\nb: {EventID: 4625}\nc: {LogonType: 3}\ncondition: (a or b) and not c",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "1 of pattern",
+			detection: "sel_a: {EventID: 1}\nsel_b: {EventID: 4625}\ncondition: 1 of sel_*",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "all of them",
+			detection: "sel_a: {EventID: 1}\nsel_b: {EventID: 4625}\ncondition: all of them",
+			entry:     failedLogon,
+		},
+		{
+			name:      "condition list is any",
+			detection: "a: {EventID: 1}\nb: {EventID: 4625}\ncondition: [a, b]",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "field map",
+			detection: "sel: {User: administrator}\ncondition: sel",
+			fieldMap:  map[string]string{"User": "field.TargetUserName"},
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "label prefix",
+			detection: "sel: {label.host: DC01}\ncondition: sel",
+			entry:     failedLogon,
+			want:      true,
+		},
+		{
+			name:      "core columns",
+			detection: "sel: {source: security, severity: warning}\ncondition: sel",
+			entry:     failedLogon,
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := parseSigmaRule([]byte("title: test\ndetection:\n" + indent(tt.detection)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.match(&sigmaEvent{entry: tt.entry, fieldMap: tt.fieldMap}); got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSigmaRuleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{"no title", "detection:\n  sel: {a: 1}\n  condition: sel"},
+		{"unknown level", "title: t\nlevel: severe\ndetection:\n  sel: {a: 1}\n  condition: sel"},
+		{"no condition", "title: t\ndetection:\n  sel: {a: 1}"},
+		{"aggregation", "title: t\ndetection:\n  sel: {a: 1}\n  condition: sel | count() > 5"},
+		{"near", "title: t\ndetection:\n  sel: {a: 1}\n  condition: near sel"},
+		{"unknown search", "title: t\ndetection:\n  sel: {a: 1}\n  condition: other"},
+		{"unbalanced parentheses", "title: t\ndetection:\n  sel: {a: 1}\n  condition: (sel"},
+		{"trailing tokens", "title: t\ndetection:\n  sel: {a: 1}\n  condition: sel sel"},
+		{"unsupported modifier", "title: t\ndetection:\n  sel: {a|base64: x}\n  condition: sel"},
+		{"conflicting modifiers", "title: t\ndetection:\n  sel: {a|contains|endswith: x}\n  condition: sel"},
+		{"invalid regex", "title: t\ndetection:\n  sel: {a|re: '('}\n  condition: sel"},
+		{"invalid cidr", "title: t\ndetection:\n  sel: {a|cidr: 10.0.0.0/33}\n  condition: sel"},
+		{"non-numeric comparison", "title: t\ndetection:\n  sel: {a|gt: many}\n  condition: sel"},
+		{"no pattern match", "title: t\ndetection:\n  sel: {a: 1}\n  condition: 1 of filter_*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseSigmaRule([]byte(tt.rule)); err == nil {
+				t.Error("rule parsed")
+			}
+		})
+	}
+}
+
+func TestSigmaPattern(t *testing.T) {
+	tests := []struct {
+		pattern, mode string
+		cased         bool
+		in            string
+		want          bool
+	}{
+		{"cmd.exe", "", false, "CMD.EXE", true},
+		{"cmd.exe", "", true, "CMD.EXE", false},
+		{"cmd.exe", "", false, "cmd.exe /c", false},
+		{"*.exe", "", false, "evil.exe", true},
+		{"ev?l", "", false, "evil", true},
+		{"ev?l", "", false, "evl", false},
+		{`100\%`, "", false, `100\%`, true},
+		{`a\*b`, "", false, "a*b", true},
+		{`a\*b`, "", false, "axb", false},
+		{"powershell", "contains", false, "C:\\PowerShell.exe -enc", true},
+		{"-enc*bypass", "contains", false, "x -enc foo bypass y", true},
+		{"c:\\windows", "startswith", false, "C:\\Windows\\System32", true},
+		{".ps1", "endswith", false, "script.PS1", true},
+		{"line*end", "", false, "line\nend", true},
+	}
+	for _, tt := range tests {
+		p, err := newSigmaPattern(tt.pattern, tt.mode, tt.cased)
+		if err != nil {
+			t.Fatalf("newSigmaPattern(%q): %v", tt.pattern, err)
+		}
+		if got := p.match(tt.in); got != tt.want {
+			t.Errorf("%q (%s, cased %v) matching %q = %v, want %v", tt.pattern, tt.mode, tt.cased, tt.in, got, tt.want)
+		}
+	}
+}
+
+// indent nests YAML lines one level deeper.
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ") + "\n"
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultScenarios is the scenario set shipped with the ingestor.
+//
+//go:embed scenarios/default.yaml
+var defaultScenarios []byte
+
+// ScenarioSet is the simulator's YAML definition.
+type ScenarioSet struct {
+	IPPools   map[string][]string `yaml:"ip_pools"`
+	Users     []string            `yaml:"users"`
+	Scenarios []Scenario          `yaml:"scenarios"`
+}
+
+// Scenario is a named event sequence, e.g. a brute-force campaign.
+type Scenario struct {
+	Name     string            `yaml:"name"`
+	Rate     float64           `yaml:"rate"`      // runs per second
+	TimeSkew string            `yaml:"time_skew"` // max timestamp jitter
+	Vars     map[string]string `yaml:"vars"`      // var -> IP pool
+	Steps    []ScenarioStep    `yaml:"steps"`
+
+	skew time.Duration
+}
+
+type ScenarioStep struct {
+	Source   string            `yaml:"source"`
+	Severity Severity          `yaml:"severity"`
+	Message  string            `yaml:"message"`
+	IP       string            `yaml:"ip"` // var holding the entry's IP; default "src"
+	Fields   map[string]string `yaml:"fields"`
+	Labels   map[string]string `yaml:"labels"`
+	Repeat   int               `yaml:"repeat"`
+	Interval string            `yaml:"interval"` // between repeats
+	Delay    string            `yaml:"delay"`    // before the step
+	Ports    []int             `yaml:"ports"`    // {{port}} range [lo, hi]
+
+	interval, delay time.Duration
+}
+
+// templateVar matches a {{name}} placeholder in step templates.
+var templateVar = regexp.MustCompile(`\{\{\w+\}\}`)
+
+// ipPool draws addresses from a mix of single IPs and CIDR ranges.
+type ipPool []*net.IPNet
+
+func (p ipPool) pick(r *rand.Rand) string {
+	n := p[r.Intn(len(p))]
+	ones, bits := n.Mask.Size()
+	ip4 := n.IP.To4()
+	if ip4 == nil || bits-ones == 0 {
+		return n.IP.String()
+	}
+	size := uint32(1) << (bits - ones)
+	base := binary.BigEndian.Uint32(ip4)
+	host := uint32(1)
+	if size > 2 {
+		host += uint32(r.Int63n(int64(size - 2))) // skip network and broadcast
+	}
+	out := make(net.IP, 4)
+	binary.BigEndian.PutUint32(out, base+host)
+	return out.String()
+}
+
+// Simulator generates realistic log traffic from a ScenarioSet.
+type Simulator struct {
+	set   ScenarioSet
+	pools map[string]ipPool
+
+	scale                   float64 // applied to every scenario's rate
+	burstEvery, burstLength time.Duration
+	burstMultiplier         float64
+	start                   time.Time
+}
+
+// newSimulator loads the configured scenarios and applies rate and burst
+// settings.
+func newSimulator(cfg SimulatorConfig) (*Simulator, error) {
+	sim, err := loadScenarios(cfg.Scenarios)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Rate < 0 {
+		return nil, fmt.Errorf("simulator rate must not be negative")
+	}
+	if cfg.Rate > 0 {
+		sim.scale = cfg.Rate / sim.naturalRate()
+	}
+	if b := cfg.Burst; b.Every != "" || b.Length != "" {
+		every, err := time.ParseDuration(b.Every)
+		if err != nil {
+			return nil, fmt.Errorf("invalid burst every: %w", err)
+		}
+		length, err := time.ParseDuration(b.Length)
+		if err != nil || length <= 0 || length >= every {
+			return nil, fmt.Errorf("burst length must be a duration shorter than every")
+		}
+		if b.Multiplier <= 1 {
+			return nil, fmt.Errorf("burst multiplier must be greater than 1")
+		}
+		sim.burstEvery, sim.burstLength, sim.burstMultiplier = every, length, b.Multiplier
+	}
+	return sim, nil
+}
+
+// naturalRate is the expected events/sec at the scenarios' own rates.
+func (s *Simulator) naturalRate() float64 {
+	var eps float64
+	for _, sc := range s.set.Scenarios {
+		events := 0
+		for _, st := range sc.Steps {
+			events += st.Repeat
+		}
+		eps += sc.Rate * float64(events)
+	}
+	return eps
+}
+
+// rateFactor is the current multiplier on scenario rates.
+func (s *Simulator) rateFactor(now time.Time) float64 {
+	f := s.scale
+	if s.burstEvery > 0 && now.Sub(s.start)%s.burstEvery < s.burstLength {
+		f *= s.burstMultiplier
+	}
+	return f
+}
+
+// loadScenarios reads the scenario file at path, or the built-in set.
+func loadScenarios(path string) (*Simulator, error) {
+	data := defaultScenarios
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	var set ScenarioSet
+	if err := yaml.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse scenarios: %w", err)
+	}
+	if len(set.Users) == 0 {
+		set.Users = []string{"testuser"}
+	}
+
+	sim := &Simulator{set: set, pools: map[string]ipPool{}, scale: 1}
+	for name, entries := range set.IPPools {
+		for _, e := range entries {
+			if !strings.Contains(e, "/") {
+				e += "/32"
+			}
+			_, n, err := net.ParseCIDR(e)
+			if err != nil {
+				return nil, fmt.Errorf("ip pool %s: %w", name, err)
+			}
+			sim.pools[name] = append(sim.pools[name], n)
+		}
+	}
+
+	for i := range set.Scenarios {
+		sc := &set.Scenarios[i]
+		if sc.Rate <= 0 || len(sc.Steps) == 0 {
+			return nil, fmt.Errorf("scenario %q needs a positive rate and at least one step", sc.Name)
+		}
+		if sc.TimeSkew != "" {
+			d, err := time.ParseDuration(sc.TimeSkew)
+			if err != nil {
+				return nil, fmt.Errorf("scenario %s: invalid time_skew: %w", sc.Name, err)
+			}
+			sc.skew = d
+		}
+		for v, pool := range sc.Vars {
+			if len(sim.pools[pool]) == 0 {
+				return nil, fmt.Errorf("scenario %s: var %s uses unknown ip pool %q", sc.Name, v, pool)
+			}
+		}
+		for j := range sc.Steps {
+			st := &sc.Steps[j]
+			if st.IP == "" {
+				st.IP = "src"
+			}
+			if _, ok := sc.Vars[st.IP]; !ok {
+				return nil, fmt.Errorf("scenario %s step %d: ip var %q is not defined", sc.Name, j+1, st.IP)
+			}
+			if st.Repeat <= 0 {
+				st.Repeat = 1
+			}
+			if len(st.Ports) != 2 || st.Ports[0] > st.Ports[1] {
+				st.Ports = []int{1, 65535}
+			}
+			for _, d := range []struct {
+				s   string
+				dst *time.Duration
+			}{{st.Interval, &st.interval}, {st.Delay, &st.delay}} {
+				if d.s == "" {
+					continue
+				}
+				parsed, err := time.ParseDuration(d.s)
+				if err != nil {
+					return nil, fmt.Errorf("scenario %s step %d: %w", sc.Name, j+1, err)
+				}
+				*d.dst = parsed
+			}
+		}
+	}
+	return sim, nil
+}
+
+// Run starts every scenario and emits generated entries until ctx is done.
+// It returns once every in-flight run has stopped emitting.
+func (s *Simulator) Run(ctx context.Context, emit func(LogEntry)) {
+	s.start = time.Now()
+	var wg sync.WaitGroup
+	for i := range s.set.Scenarios {
+		wg.Add(1)
+		go func(sc *Scenario) {
+			defer wg.Done()
+			s.runScenario(ctx, sc, &wg, emit)
+		}(&s.set.Scenarios[i])
+	}
+	<-ctx.Done()
+	wg.Wait()
+}
+
+// simRunner keeps one simulator running and swaps it for a new one when
+// scenarios are reloaded. A nil simulator means simulation is disabled.
+type simRunner struct {
+	emit    func(LogEntry)
+	restart chan *Simulator
+	done    chan struct{} // closed once Run returns
+}
+
+func newSimRunner(emit func(LogEntry)) *simRunner {
+	return &simRunner{emit: emit, restart: make(chan *Simulator), done: make(chan struct{})}
+}
+
+// Run runs sim, and each simulator reload hands it, until ctx is done.
+func (r *simRunner) Run(ctx context.Context, sim *Simulator) {
+	defer close(r.done)
+	for {
+		runCtx, cancel := context.WithCancel(ctx)
+		stopped := make(chan struct{})
+		if sim != nil {
+			slog.Info("Simulating scenarios", "scenarios", len(sim.set.Scenarios), "rate", sim.naturalRate()*sim.scale)
+			go func(sim *Simulator) {
+				sim.Run(runCtx, r.emit)
+				close(stopped)
+			}(sim)
+		} else {
+			close(stopped)
+		}
+
+		select {
+		case next := <-r.restart:
+			cancel()
+			<-stopped
+			sim = next
+		case <-ctx.Done():
+			cancel()
+			<-stopped
+			return
+		}
+	}
+}
+
+// reload restarts simulation with cfg's scenarios, or stops it if cfg
+// disables it. Scenarios that fail to load leave the current run going.
+func (r *simRunner) reload(cfg SimulatorConfig) error {
+	var sim *Simulator
+	if cfg.Enabled {
+		var err error
+		if sim, err = newSimulator(cfg); err != nil {
+			return err
+		}
+	}
+	select {
+	case r.restart <- sim:
+	case <-r.done:
+	}
+	return nil
+}
+
+// runScenario starts runs of sc with exponentially distributed gaps, so
+// campaigns arrive irregularly and can overlap.
+func (s *Simulator) runScenario(ctx context.Context, sc *Scenario, wg *sync.WaitGroup, emit func(LogEntry)) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for {
+		gap := time.Duration(r.ExpFloat64() / (sc.Rate * s.rateFactor(time.Now())) * float64(time.Second))
+		if !sleepCtx(ctx, gap) {
+			return
+		}
+		wg.Add(1)
+		go func(r *rand.Rand) {
+			defer wg.Done()
+			s.play(ctx, sc, r, emit)
+		}(rand.New(rand.NewSource(r.Int63())))
+	}
+}
+
+// play walks one run of sc.
+func (s *Simulator) play(ctx context.Context, sc *Scenario, r *rand.Rand, emit func(LogEntry)) {
+	vars := map[string]string{"user": s.set.Users[r.Intn(len(s.set.Users))]}
+	for v, pool := range sc.Vars {
+		vars[v] = s.pools[pool].pick(r)
+	}
+
+	for _, st := range sc.Steps {
+		if !sleepCtx(ctx, st.delay) {
+			return
+		}
+		for n := 1; n <= st.Repeat; n++ {
+			if n > 1 && !sleepCtx(ctx, st.interval) {
+				return
+			}
+			emit(s.event(sc, st, vars, n, r))
+		}
+	}
+}
+
+func (s *Simulator) event(sc *Scenario, st ScenarioStep, vars map[string]string, n int, r *rand.Rand) LogEntry {
+	local := map[string]string{
+		"port":  strconv.Itoa(st.Ports[0] + r.Intn(st.Ports[1]-st.Ports[0]+1)),
+		"sport": strconv.Itoa(1024 + r.Intn(60000)),
+		"n":     strconv.Itoa(n),
+		"sev":   strconv.Itoa(3*int(st.Severity) + 1),
+	}
+	expand := func(tmpl string) string {
+		return templateVar.ReplaceAllStringFunc(tmpl, func(m string) string {
+			k := m[2 : len(m)-2]
+			if v, ok := local[k]; ok {
+				return v
+			}
+			return vars[k]
+		})
+	}
+
+	ts := time.Now()
+	if sc.skew > 0 {
+		ts = ts.Add(time.Duration(r.Int63n(int64(2*sc.skew))) - sc.skew)
+	}
+	entry := LogEntry{
+		Timestamp: ts,
+		Source:    st.Source,
+		Severity:  st.Severity,
+		Message:   expand(st.Message),
+		IPAddress: vars[st.IP],
+	}
+	if len(st.Fields) > 0 {
+		entry.Fields = map[string]string{}
+		for k, v := range st.Fields {
+			entry.Fields[k] = expand(v)
+		}
+	}
+	entry.Labels = map[string]string{"scenario": sc.Name}
+	for k, v := range st.Labels {
+		entry.Labels[k] = expand(v)
+	}
+	return entry
+}
+
+// sleepCtx sleeps for d, reporting false if ctx ended first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// simulateCommand drives the scenario simulator through the full ingest
+// pipeline without serving the API, until -duration elapses or it's
+// interrupted. With -bench it reports insert throughput and latency instead.
+func simulateCommand(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	configPath := fs.String("config", "../config.yaml", "path to config file")
+	simFlags := addSimulatorFlags(fs)
+	bench := fs.Bool("bench", false, "load-test inserts for -duration (default 1m) and report throughput and latency")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if err := setupLogging(config.Logging.WithDefaults()); err != nil {
+		return fmt.Errorf("invalid logging config: %w", err)
+	}
+	simConfig := simFlags.apply(config.Simulator)
+	var duration time.Duration
+	if simConfig.Duration != "" {
+		if duration, err = time.ParseDuration(simConfig.Duration); err != nil {
+			return fmt.Errorf("invalid simulator duration: %w", err)
+		}
+	}
+	if *bench && duration == 0 {
+		duration = time.Minute
+	}
+	sim, err := newSimulator(simConfig)
+	if err != nil {
+		return fmt.Errorf("load simulator scenarios: %w", err)
+	}
+
+	db, err := store.Open(config)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	p, err := newPipeline(db, config, NewReloader(db, *configPath))
+	if err != nil {
+		return err
+	}
+	if *bench {
+		runBench(p.ingestor, sim, duration)
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+	slog.Info("Simulating scenarios", "scenarios", len(sim.set.Scenarios), "rate", sim.naturalRate()*sim.scale, "duration", duration)
+	var ingested, failed atomic.Int64
+	sim.Run(ctx, func(entry LogEntry) {
+		if _, err := p.ingestor.Ingest(entry); err != nil {
+			failed.Add(1)
+			slog.Error("Failed to ingest simulated log", "err", err)
+			return
+		}
+		ingested.Add(1)
+	})
+	slog.Info("Simulation finished", "ingested", ingested.Load(), "failed", failed.Load())
+	return nil
+}
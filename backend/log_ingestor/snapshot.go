@@ -0,0 +1,440 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// Snapshots are gzipped tar archives containing:
+//
+//	manifest.json     format version, time range, row counts
+//	schema.sql        CREATE TABLE statements for the exported tables (TiDB only)
+//	<table>.ndjson    one JSON object per row, columns as strings (or null)
+//
+// Rows keep their original IDs in the archive; restore assigns new IDs and
+// remaps incident log_ids and action incident_ids accordingly.
+
+const snapshotFormatVersion = 1
+
+type snapshotManifest struct {
+	FormatVersion int            `json:"format_version"`
+	From          time.Time      `json:"from"`
+	To            time.Time      `json:"to"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Rows          map[string]int `json:"rows"`
+}
+
+// snapshotTable describes how one table is exported and restored.
+type snapshotTable struct {
+	name        string
+	columns     []string // first column is always id
+	timeColumns map[string]bool
+	where       string // filter with two placeholders: from, to
+}
+
+// snapshotTables are exported in dependency order.
+var snapshotTables = []snapshotTable{
+	{
+		name:        "logs",
+		columns:     []string{"id", "tenant_id", "timestamp", "source", "severity", "message", "ip_address", "fields", "labels", "risk_score", "embedding", "embedding_model", "processed", "created_at"},
+		timeColumns: map[string]bool{"timestamp": true, "created_at": true},
+		where:       "deleted_at IS NULL AND timestamp >= ? AND timestamp < ?",
+	},
+	{
+		name:        "incidents",
+		columns:     []string{"id", "tenant_id", "log_ids", "summary", "severity", "recommendation", "status", "assignee", "created_at", "updated_at"},
+		timeColumns: map[string]bool{"created_at": true, "updated_at": true},
+		where:       "created_at >= ? AND created_at < ?",
+	},
+	{
+		name:        "incident_comments",
+		columns:     []string{"id", "incident_id", "tenant_id", "author", "body", "created_at"},
+		timeColumns: map[string]bool{"created_at": true},
+		where:       "incident_id IN (SELECT id FROM incidents WHERE created_at >= ? AND created_at < ?)",
+	},
+	{
+		name:        "actions",
+		columns:     []string{"id", "incident_id", "action_type", "details", "status", "executed_at", "created_at"},
+		timeColumns: map[string]bool{"executed_at": true, "created_at": true},
+		where:       "incident_id IN (SELECT id FROM incidents WHERE created_at >= ? AND created_at < ?)",
+	},
+}
+
+type snapshotRow map[string]*string
+
+func snapshotCommand(args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	fromFlag := fs.String("from", "", "start of time range, RFC3339 (required)")
+	toFlag := fs.String("to", "", "end of time range, RFC3339 (default now)")
+	out := fs.String("out", "", "archive path (default snapshot-<from>.tar.gz)")
+	db, err := commandDB(fs, args)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	from, err := time.Parse(time.RFC3339, *fromFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %v", err)
+	}
+	to := time.Now()
+	if *toFlag != "" {
+		if to, err = time.Parse(time.RFC3339, *toFlag); err != nil {
+			return fmt.Errorf("invalid -to: %v", err)
+		}
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("snapshot-%s.tar.gz", from.UTC().Format("20060102T150405Z"))
+	}
+
+	manifest, err := writeSnapshot(db, from, to, *out)
+	if err != nil {
+		return err
+	}
+	slog.Info("Snapshot written", "from", from, "to", to, "path", *out, "rows", manifest.Rows)
+	return nil
+}
+
+func restoreCommand(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	in := fs.String("in", "", "snapshot archive to restore (required)")
+	applySchema := fs.Bool("apply-schema", true, "create missing tables from the archived schema")
+	db, err := commandDB(fs, args)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *in == "" {
+		return fmt.Errorf("-in is required")
+	}
+	counts, err := restoreSnapshot(db, *in, *applySchema)
+	if err != nil {
+		return err
+	}
+	slog.Info("Restored snapshot", "path", *in, "rows", counts)
+	recordAudit(db, defaultTenant, "cli", "snapshot.restore", map[string]any{"archive": *in, "rows": counts})
+	return nil
+}
+
+// writeSnapshot exports [from, to) into a gzipped tar archive at path.
+func writeSnapshot(db *store.DB, from, to time.Time, path string) (*snapshotManifest, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	manifest := &snapshotManifest{
+		FormatVersion: snapshotFormatVersion,
+		From:          from,
+		To:            to,
+		CreatedAt:     time.Now(),
+		Rows:          map[string]int{},
+	}
+
+	// Only TiDB can describe its tables; other stores are restored into a
+	// database created from their schema script.
+	var schema strings.Builder
+	for _, t := range snapshotTables {
+		if db.Driver() != "tidb" {
+			break
+		}
+		var name, create string
+		if err := db.QueryRow("SHOW CREATE TABLE "+t.name).Scan(&name, &create); err != nil {
+			return nil, fmt.Errorf("read schema of %s: %w", t.name, err)
+		}
+		create = strings.Replace(create, "CREATE TABLE ", "CREATE TABLE IF NOT EXISTS ", 1)
+		schema.WriteString(create + ";\n\n")
+	}
+
+	// Export every table before writing so the manifest can lead the archive.
+	exports := make([]*os.File, 0, len(snapshotTables))
+	defer func() {
+		for _, tmp := range exports {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	for _, t := range snapshotTables {
+		tmp, n, err := exportTable(db, t, from, to)
+		if tmp != nil {
+			exports = append(exports, tmp)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("export %s: %w", t.name, err)
+		}
+		manifest.Rows[t.name] = n
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := addTarFile(tw, "manifest.json", data); err != nil {
+		return nil, err
+	}
+	if schema.Len() > 0 {
+		if err := addTarFile(tw, "schema.sql", []byte(schema.String())); err != nil {
+			return nil, err
+		}
+	}
+	for i, t := range snapshotTables {
+		if err := copyTarFile(tw, t.name+".ndjson", exports[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, f.Close()
+}
+
+// exportTable streams matching rows to a temp file (tar entries need their
+// size up front). The caller removes the returned file.
+func exportTable(db *store.DB, t snapshotTable, from, to time.Time) (*os.File, int, error) {
+	tmp, err := os.CreateTemp("", "snapshot-"+t.name+"-*.ndjson")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query("SELECT "+strings.Join(t.columns, ", ")+" FROM "+t.name+" WHERE "+t.where+" ORDER BY id", from, to)
+	if err != nil {
+		return tmp, 0, err
+	}
+	defer rows.Close()
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	values := make([]sql.NullString, len(t.columns))
+	ptrs := make([]any, len(values))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return tmp, count, err
+		}
+		row := snapshotRow{}
+		for i, col := range t.columns {
+			if values[i].Valid {
+				v := values[i].String
+				row[col] = &v
+			} else {
+				row[col] = nil
+			}
+		}
+		if err := enc.Encode(row); err != nil {
+			return tmp, count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return tmp, count, err
+	}
+	return tmp, count, w.Flush()
+}
+
+func copyTarFile(tw *tar.Writer, name string, f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: info.Size(), ModTime: time.Now()}); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func addTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: time.Now()}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// restoreSnapshot imports an archive in a single transaction.
+func restoreSnapshot(db *store.DB, path string, applySchema bool) (map[string]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	idMaps := map[string]map[string]int64{} // table -> old id -> new id
+	counts := map[string]int{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case hdr.Name == "schema.sql":
+			// The archived schema is TiDB DDL.
+			if !applySchema || db.Driver() != "tidb" {
+				continue
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			// DDL auto-commits, so apply it outside the data transaction.
+			for _, stmt := range strings.Split(string(data), ";\n") {
+				if stmt = strings.TrimSpace(stmt); stmt != "" {
+					if _, err := db.Exec(stmt); err != nil {
+						return nil, fmt.Errorf("apply schema: %w", err)
+					}
+				}
+			}
+
+		case hdr.Name == "manifest.json":
+			// Always the first entry, so a bad version aborts before any writes.
+			var m snapshotManifest
+			if err := json.NewDecoder(tr).Decode(&m); err != nil {
+				return nil, fmt.Errorf("read manifest: %w", err)
+			}
+			if m.FormatVersion != snapshotFormatVersion {
+				return nil, fmt.Errorf("unsupported snapshot format version %d", m.FormatVersion)
+			}
+
+		case strings.HasSuffix(hdr.Name, ".ndjson"):
+			t, ok := findSnapshotTable(strings.TrimSuffix(hdr.Name, ".ndjson"))
+			if !ok {
+				slog.Warn("Skipping unknown snapshot entry", "name", hdr.Name)
+				continue
+			}
+			ids, err := importTable(tx, tr, t, idMaps)
+			if err != nil {
+				return nil, fmt.Errorf("import %s: %w", t.name, err)
+			}
+			idMaps[t.name] = ids
+			counts[t.name] = len(ids)
+		}
+	}
+	return counts, tx.Commit()
+}
+
+func findSnapshotTable(name string) (snapshotTable, bool) {
+	for _, t := range snapshotTables {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return snapshotTable{}, false
+}
+
+// importTable inserts rows with fresh IDs, rewriting references to tables
+// restored earlier in the archive.
+func importTable(tx *store.Tx, r io.Reader, t snapshotTable, idMaps map[string]map[string]int64) (map[string]int64, error) {
+	cols := t.columns[1:]
+	insert := "INSERT INTO " + t.name + " (" + strings.Join(cols, ", ") + ") VALUES (" + placeholders(len(cols)) + ")"
+
+	ids := map[string]int64{}
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var row snapshotRow
+		if err := dec.Decode(&row); err != nil {
+			return ids, err
+		}
+		remapReferences(t.name, row, idMaps)
+
+		args := make([]any, len(cols))
+		for i, col := range cols {
+			v := row[col]
+			switch {
+			case v == nil:
+				args[i] = nil
+			case t.timeColumns[col]:
+				ts, err := time.Parse(time.RFC3339Nano, *v)
+				if err != nil {
+					return ids, fmt.Errorf("column %s: %w", col, err)
+				}
+				args[i] = ts
+			default:
+				args[i] = *v
+			}
+		}
+		id, err := tx.Insert(insert, args...)
+		if err != nil {
+			return ids, err
+		}
+		if old := row["id"]; old != nil {
+			ids[*old] = id
+		}
+	}
+	return ids, nil
+}
+
+// remapReferences rewrites foreign keys to the IDs assigned on restore.
+// References to rows outside the snapshot are dropped.
+func remapReferences(table string, row snapshotRow, idMaps map[string]map[string]int64) {
+	switch table {
+	case "incidents":
+		if row["log_ids"] == nil {
+			return
+		}
+		var old []int64
+		if err := json.Unmarshal([]byte(*row["log_ids"]), &old); err != nil {
+			return
+		}
+		remapped := []int64{}
+		for _, id := range old {
+			if newID, ok := idMaps["logs"][strconv.FormatInt(id, 10)]; ok {
+				remapped = append(remapped, newID)
+			}
+		}
+		data, _ := json.Marshal(remapped)
+		s := string(data)
+		row["log_ids"] = &s
+
+	case "actions", "incident_comments":
+		if row["incident_id"] == nil {
+			return
+		}
+		if newID, ok := idMaps["incidents"][*row["incident_id"]]; ok {
+			s := strconv.FormatInt(newID, 10)
+			row["incident_id"] = &s
+		} else {
+			row["incident_id"] = nil
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// StreamFilter selects live logs for a subscription. All set conditions must
+// match; list conditions match any of their values.
+type StreamFilter struct {
+	Sources     []string          `json:"sources,omitempty" yaml:"sources"`
+	Severities  []Severity        `json:"severities,omitempty" yaml:"severities"`
+	MinSeverity *Severity         `json:"min_severity,omitempty" yaml:"min_severity"`
+	IPAddress   string            `json:"ip,omitempty" yaml:"ip"`
+	Search      string            `json:"q,omitempty" yaml:"q"` // case-insensitive substring of the message
+	Fields      map[string]string `json:"fields,omitempty" yaml:"fields"`
+	Labels      map[string]string `json:"labels,omitempty" yaml:"labels"`
+	MinRisk     int               `json:"min_risk,omitempty" yaml:"min_risk"` // risk_score at least
+}
+
+// terms counts the conditions the filter evaluates per event.
+func (f StreamFilter) terms() int {
+	n := len(f.Sources) + len(f.Severities) + len(f.Fields) + len(f.Labels)
+	for _, set := range []bool{f.MinSeverity != nil, f.IPAddress != "", f.Search != "", f.MinRisk > 0} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+func (f StreamFilter) validate(cfg StreamConfig) error {
+	if n := f.terms(); n > cfg.MaxFilterTerms {
+		return fmt.Errorf("filter too complex (%d terms > %d)", n, cfg.MaxFilterTerms)
+	}
+	if len(f.Search) > cfg.MaxSearchLength {
+		return fmt.Errorf("search longer than %d characters", cfg.MaxSearchLength)
+	}
+	return nil
+}
+
+func (f StreamFilter) matches(e LogEntry) bool {
+	if len(f.Sources) > 0 && !contains(f.Sources, e.Source) {
+		return false
+	}
+	if len(f.Severities) > 0 && !contains(f.Severities, e.Severity) {
+		return false
+	}
+	if f.MinSeverity != nil && e.Severity < *f.MinSeverity {
+		return false
+	}
+	if f.IPAddress != "" && e.IPAddress != f.IPAddress {
+		return false
+	}
+	if e.RiskScore < f.MinRisk {
+		return false
+	}
+	if f.Search != "" && !strings.Contains(strings.ToLower(e.Message), strings.ToLower(f.Search)) {
+		return false
+	}
+	for k, v := range f.Fields {
+		if e.Fields[k] != v {
+			return false
+		}
+	}
+	for k, v := range f.Labels {
+		if e.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func contains[T comparable](list []T, v T) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// keySubscriptions counts live subscriptions per API key across connections.
+var keySubscriptions = struct {
+	sync.Mutex
+	count map[int64]int
+}{count: map[int64]int{}}
+
+// subscriptions is a connection's set of live queries. A connection without
+// subscriptions receives every log of its tenant.
+type subscriptions struct {
+	mu      sync.RWMutex
+	cfg     StreamConfig
+	keyID   int64 // 0 for anonymous connections
+	filters map[string]StreamFilter
+}
+
+func newSubscriptions(cfg StreamConfig, key *APIKey) *subscriptions {
+	s := &subscriptions{cfg: cfg, filters: map[string]StreamFilter{}}
+	if key != nil {
+		s.keyID = key.ID
+	}
+	return s
+}
+
+// add registers or replaces the subscription id, enforcing the connection
+// and per-key limits.
+func (s *subscriptions) add(id string, f StreamFilter) error {
+	if id == "" {
+		return fmt.Errorf("subscription 'id' is required")
+	}
+	if err := f.validate(s.cfg); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.filters[id]; exists {
+		s.filters[id] = f
+		return nil
+	}
+	if len(s.filters) >= s.cfg.MaxSubscriptions {
+		return fmt.Errorf("subscription limit reached (%d per connection)", s.cfg.MaxSubscriptions)
+	}
+	if s.keyID != 0 {
+		keySubscriptions.Lock()
+		defer keySubscriptions.Unlock()
+		if keySubscriptions.count[s.keyID] >= s.cfg.MaxKeySubscriptions {
+			return fmt.Errorf("subscription limit reached (%d per API key)", s.cfg.MaxKeySubscriptions)
+		}
+		keySubscriptions.count[s.keyID]++
+	}
+	s.filters[id] = f
+	return nil
+}
+
+func (s *subscriptions) remove(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.filters[id]; !ok {
+		return false
+	}
+	delete(s.filters, id)
+	s.release(1)
+	return true
+}
+
+// list copies the connection's subscriptions.
+func (s *subscriptions) list() map[string]StreamFilter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	filters := make(map[string]StreamFilter, len(s.filters))
+	for id, f := range s.filters {
+		filters[id] = f
+	}
+	return filters
+}
+
+// clear drops every subscription, e.g. when the connection closes.
+func (s *subscriptions) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.release(len(s.filters))
+	s.filters = map[string]StreamFilter{}
+}
+
+func (s *subscriptions) release(n int) {
+	if s.keyID == 0 || n == 0 {
+		return
+	}
+	keySubscriptions.Lock()
+	defer keySubscriptions.Unlock()
+	if keySubscriptions.count[s.keyID] -= n; keySubscriptions.count[s.keyID] <= 0 {
+		delete(keySubscriptions.count, s.keyID)
+	}
+}
+
+// wants reports whether e matches any subscription, or there are none.
+func (s *subscriptions) wants(e LogEntry) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.filters) == 0 {
+		return true
+	}
+	for _, f := range s.filters {
+		if f.matches(e) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// LogDigest is the factual summary of a set of logs handed to the LLM, and
+// returned alongside its narrative.
+type LogDigest struct {
+	From          time.Time      `json:"from"`
+	To            time.Time      `json:"to"`
+	Total         int            `json:"total"`
+	Truncated     bool           `json:"truncated,omitempty"`
+	Severities    map[string]int `json:"severities"`
+	TopSources    []SourceCount  `json:"top_sources"`
+	SuspiciousIPs []IPActivity   `json:"suspicious_ips"`
+	PatternHint   string         `json:"pattern_hint,omitempty"`
+}
+
+type SourceCount struct {
+	Source string `json:"source"`
+	Count  int    `json:"count"`
+}
+
+// IPActivity summarizes one address, ranked by ALERT/CRITICAL volume.
+type IPActivity struct {
+	IP      string   `json:"ip"`
+	Events  int      `json:"events"`
+	Severe  int      `json:"severe"`
+	Sources []string `json:"sources"`
+}
+
+// attackPatterns map message keywords to the attack they usually indicate;
+// the first pattern with the most matching logs is offered as a hint.
+var attackPatterns = []struct {
+	name     string
+	keywords []string
+}{
+	{"brute force / credential stuffing", []string{"failed login", "failed password", "authentication failure", "invalid user", "login failed"}},
+	{"port scanning / reconnaissance", []string{"port scan", "scan detected", "syn scan", "connection attempt"}},
+	{"lateral movement", []string{"lateral", "psexec", "smb", "rdp", "remote service"}},
+	{"malware / command and control", []string{"malware", "beacon", "c2", "trojan", "ransomware"}},
+	{"data exfiltration", []string{"exfil", "large upload", "outbound transfer", "dns tunnel"}},
+	{"privilege escalation", []string{"privilege", "sudo", "admin group", "escalat"}},
+}
+
+// digestLogs computes the facts of logs, which must be newest first.
+func digestLogs(logs []LogEntry, truncated bool) LogDigest {
+	d := LogDigest{Total: len(logs), Truncated: truncated, Severities: map[string]int{}}
+	if len(logs) == 0 {
+		return d
+	}
+	d.From, d.To = logs[0].Timestamp, logs[0].Timestamp
+
+	sources := map[string]int{}
+	ips := map[string]*IPActivity{}
+	patterns := make([]int, len(attackPatterns))
+	for _, e := range logs {
+		if e.Timestamp.Before(d.From) {
+			d.From = e.Timestamp
+		}
+		if e.Timestamp.After(d.To) {
+			d.To = e.Timestamp
+		}
+		d.Severities[e.Severity.String()]++
+		sources[e.Source]++
+		if e.IPAddress != "" {
+			a := ips[e.IPAddress]
+			if a == nil {
+				a = &IPActivity{IP: e.IPAddress}
+				ips[e.IPAddress] = a
+			}
+			a.Events++
+			if e.Severity >= SeverityAlert {
+				a.Severe++
+			}
+			if !contains(a.Sources, e.Source) {
+				a.Sources = append(a.Sources, e.Source)
+			}
+		}
+		msg := strings.ToLower(e.Message)
+		for i, p := range attackPatterns {
+			for _, kw := range p.keywords {
+				if strings.Contains(msg, kw) {
+					patterns[i]++
+					break
+				}
+			}
+		}
+	}
+
+	for s, n := range sources {
+		d.TopSources = append(d.TopSources, SourceCount{Source: s, Count: n})
+	}
+	sort.Slice(d.TopSources, func(i, j int) bool { return d.TopSources[i].Count > d.TopSources[j].Count })
+	if len(d.TopSources) > 5 {
+		d.TopSources = d.TopSources[:5]
+	}
+
+	for _, a := range ips {
+		d.SuspiciousIPs = append(d.SuspiciousIPs, *a)
+	}
+	sort.Slice(d.SuspiciousIPs, func(i, j int) bool {
+		a, b := d.SuspiciousIPs[i], d.SuspiciousIPs[j]
+		if a.Severe != b.Severe {
+			return a.Severe > b.Severe
+		}
+		return a.Events > b.Events
+	})
+	if len(d.SuspiciousIPs) > 10 {
+		d.SuspiciousIPs = d.SuspiciousIPs[:10]
+	}
+
+	best := 0
+	for i, n := range patterns {
+		if n > best {
+			best, d.PatternHint = n, attackPatterns[i].name
+		}
+	}
+	return d
+}
+
+// LogSummary is the narrative produced for a digest.
+type LogSummary struct {
+	Summary        string `json:"summary"`
+	AttackPattern  string `json:"attack_pattern"`
+	Severity       string `json:"severity"` // LOW, MEDIUM, HIGH, CRITICAL
+	Recommendation string `json:"recommendation"`
+}
+
+// incidentSeverity maps the highest log severity onto the incident scale.
+func incidentSeverity(d LogDigest) string {
+	for _, sev := range []Severity{SeverityCritical, SeverityAlert, SeverityWarning} {
+		if d.Severities[sev.String()] > 0 {
+			return incidentSeverityOf(sev)
+		}
+	}
+	return incidentSeverityOf(SeverityInfo)
+}
+
+// Summarizer turns a set of logs into an incident narrative, via the
+// configured LLM or, with the mock provider, a template over the digest.
+type Summarizer struct {
+	cfg    LLMConfig
+	client *http.Client
+}
+
+func NewSummarizer(cfg LLMConfig) *Summarizer {
+	timeout, _ := time.ParseDuration(cfg.Timeout)
+	return &Summarizer{cfg: cfg, client: &http.Client{Timeout: timeout}}
+}
+
+const summarizePrompt = `You are a SOC analyst. Given a digest of security logs and a sample of the
+most severe entries, explain what happened in a few sentences: which sources
+and IP addresses were involved and the most probable attack pattern. Reply
+with only a JSON object with the keys "summary", "attack_pattern",
+"severity" (one of LOW, MEDIUM, HIGH, CRITICAL), and "recommendation".`
+
+func (s *Summarizer) Summarize(ctx context.Context, d LogDigest, logs []LogEntry) (LogSummary, error) {
+	if s.cfg.Provider == "mock" {
+		return templateSummary(d), nil
+	}
+
+	// The most severe logs first, capped so the prompt stays small.
+	sample := append([]LogEntry(nil), logs...)
+	sort.SliceStable(sample, func(i, j int) bool { return sample[i].Severity > sample[j].Severity })
+	if len(sample) > 30 {
+		sample = sample[:30]
+	}
+	var lines []string
+	for _, e := range sample {
+		lines = append(lines, fmt.Sprintf("%s [%s] %s %s: %s", e.Timestamp.Format(time.RFC3339), e.Severity, e.Source, e.IPAddress, e.Message))
+	}
+	digest, _ := json.MarshalIndent(d, "", "  ")
+	user := "Digest:\n" + string(digest) + "\n\nSample logs:\n" + strings.Join(lines, "\n")
+
+	text, err := s.complete(ctx, summarizePrompt, user)
+	if err != nil {
+		return LogSummary{}, err
+	}
+	var out LogSummary
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start || json.Unmarshal([]byte(text[start:end+1]), &out) != nil || out.Summary == "" {
+		// Not JSON after all; keep the prose and fill in the rest.
+		out = templateSummary(d)
+		out.Summary = strings.TrimSpace(text)
+	}
+	switch out.Severity = strings.ToUpper(out.Severity); out.Severity {
+	case "LOW", "MEDIUM", "HIGH", "CRITICAL":
+	default:
+		out.Severity = incidentSeverity(d)
+	}
+	return out, nil
+}
+
+// complete runs one chat completion against the provider.
+func (s *Summarizer) complete(ctx context.Context, system, user string) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"model": s.cfg.Model,
+		"messages": []map[string]string{
+			{"role": "system", "content": system},
+			{"role": "user", "content": user},
+		},
+		"temperature": 0.2,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(s.cfg.URL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("%s returned %s: %s", s.cfg.Provider, resp.Status, bytes.TrimSpace(msg))
+	}
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode completion: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return "", fmt.Errorf("%s returned no choices", s.cfg.Provider)
+	}
+	return out.Choices[0].Message.Content, nil
+}
+
+// templateSummary writes a narrative from the digest alone.
+func templateSummary(d LogDigest) LogSummary {
+	out := LogSummary{Severity: incidentSeverity(d), AttackPattern: d.PatternHint}
+	if out.AttackPattern == "" {
+		out.AttackPattern = "undetermined"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d logs between %s and %s", d.Total, d.From.Format(time.RFC3339), d.To.Format(time.RFC3339))
+	if len(d.TopSources) > 0 {
+		var names []string
+		for _, s := range d.TopSources {
+			names = append(names, fmt.Sprintf("%s (%d)", s.Source, s.Count))
+		}
+		fmt.Fprintf(&b, ", mostly from %s", strings.Join(names, ", "))
+	}
+	b.WriteString(".")
+	if len(d.SuspiciousIPs) > 0 {
+		top := d.SuspiciousIPs[0]
+		fmt.Fprintf(&b, " The most active address was %s with %d events (%d ALERT/CRITICAL) across %s.",
+			top.IP, top.Events, top.Severe, strings.Join(top.Sources, ", "))
+	}
+	if d.PatternHint != "" {
+		fmt.Fprintf(&b, " The activity is consistent with %s.", d.PatternHint)
+	}
+	out.Summary = b.String()
+
+	switch {
+	case len(d.SuspiciousIPs) > 0 && d.SuspiciousIPs[0].Severe > 0:
+		out.Recommendation = fmt.Sprintf("Action: BLOCK_IP %s\nAction: CREATE_TICKET", d.SuspiciousIPs[0].IP)
+	case out.Severity == "LOW":
+		out.Recommendation = "No action required; keep monitoring."
+	default:
+		out.Recommendation = "Action: CREATE_TICKET"
+	}
+	return out
+}
+
+// summarizeRequest selects the logs to summarize: explicit IDs, or a time
+// range with the usual query filters.
+type summarizeRequest struct {
+	LogIDs  []int64    `json:"log_ids,omitempty"`
+	From    *time.Time `json:"from,omitempty"`
+	To      *time.Time `json:"to,omitempty"`
+	Sources []string   `json:"sources,omitempty"`
+	IP      string     `json:"ip,omitempty"`
+}
+
+// loadLogsByID reads the given logs of tenant, newest first.
+func loadLogsByID(db *store.DB, tenant string, ids []int64) ([]LogEntry, error) {
+	args := []any{tenant}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := db.Query(`
+		SELECT `+logColumns+`
+		FROM logs
+		WHERE tenant_id = ? AND deleted_at IS NULL AND id IN (`+placeholders(len(ids))+`)
+		ORDER BY id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanLogs(rows)
+}
+
+// summarizeHandler serves POST /api/incidents/summarize: it narrates the
+// selected logs (top sources, suspicious IPs, probable attack pattern),
+// stores the narrative as an OPEN incident, and pushes it to the stream.
+func summarizeHandler(db *store.DB, h *streamHub, s *Summarizer, api APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req summarizeRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		tenant := tenantFromRequest(r)
+
+		var (
+			logs []LogEntry
+			err  error
+		)
+		switch {
+		case len(req.LogIDs) > s.cfg.MaxLogs:
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d log_ids per summary", s.cfg.MaxLogs))
+			return
+		case len(req.LogIDs) > 0:
+			logs, err = loadLogsByID(db, tenant, req.LogIDs)
+		case req.From != nil:
+			q := LogQuery{TenantID: tenant, From: *req.From, To: time.Now(), Sources: req.Sources, IPAddress: req.IP, Limit: s.cfg.MaxLogs + 1}
+			if req.To != nil {
+				q.To = *req.To
+			}
+			if !q.From.Before(q.To) {
+				writeError(w, http.StatusBadRequest, "'from' must be before 'to'")
+				return
+			}
+			if cost := q.EstimateCost(); cost > api.MaxQueryCost {
+				writeError(w, http.StatusUnprocessableEntity, "time range too expensive; narrow it or add filters")
+				return
+			}
+			logs, err = queryLogs(db, q)
+		default:
+			writeError(w, http.StatusBadRequest, "provide 'log_ids' or 'from'")
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to load logs to summarize", "err", err)
+			writeError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		if len(logs) == 0 {
+			writeError(w, http.StatusNotFound, "no logs matched")
+			return
+		}
+		truncated := len(logs) > s.cfg.MaxLogs
+		if truncated {
+			logs = logs[:s.cfg.MaxLogs]
+		}
+
+		digest := digestLogs(logs, truncated)
+		summary, err := s.Summarize(r.Context(), digest, logs)
+		if err != nil {
+			slog.Error("LLM summary failed", "err", err)
+			writeError(w, http.StatusBadGateway, "summary provider failed")
+			return
+		}
+
+		inc := Incident{
+			TenantID:       tenant,
+			Summary:        summary.Summary,
+			Severity:       summary.Severity,
+			Recommendation: summary.Recommendation,
+		}
+		if summary.AttackPattern != "" && summary.AttackPattern != "undetermined" && !strings.Contains(summary.Summary, summary.AttackPattern) {
+			inc.Summary += "\n\nProbable attack pattern: " + summary.AttackPattern
+		}
+		for _, e := range logs {
+			inc.LogIDs = append(inc.LogIDs, e.ID)
+		}
+		if err := storeIncident(db, &inc); err != nil {
+			slog.Error("Failed to store incident", "err", err)
+			writeError(w, http.StatusInternalServerError, "failed to store incident")
+			return
+		}
+		slog.Info("Incident summarized", "id", inc.ID, "logs", len(logs), "provider", s.cfg.Provider)
+		publishIncident(h, inc)
+
+		writeJSON(w, http.StatusCreated, map[string]any{
+			"incident":       inc,
+			"attack_pattern": summary.AttackPattern,
+			"digest":         digest,
+			"provider":       s.cfg.Provider,
+		})
+	}
+}
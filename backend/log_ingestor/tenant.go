@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+// tenantFromRequest returns the tenant of the authenticating API key, or the
+// default tenant for anonymous requests (only possible with tenancy disabled).
+func tenantFromRequest(r *http.Request) string {
+	return tenantFromContext(r.Context())
+}
+
+// tenantFromContext is tenantFromRequest for non-HTTP callers such as gRPC.
+func tenantFromContext(ctx context.Context) string {
+	if key := keyFromContext(ctx); key != nil && key.TenantID != "" {
+		return key.TenantID
+	}
+	return defaultTenant
+}
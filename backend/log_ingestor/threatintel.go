@@ -0,0 +1,546 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"1logx/log_ingestor/internal/store"
+)
+
+// threatIndicator is a known-bad IP or CIDR range.
+type threatIndicator struct {
+	Feed        string `json:"feed"`
+	Indicator   string `json:"indicator"`
+	Confidence  int    `json:"confidence"`
+	Description string `json:"description,omitempty"`
+}
+
+// threatIndex answers IP lookups from the loaded indicators. It's rebuilt
+// after every pull and swapped in whole.
+type threatIndex struct {
+	ips    map[netip.Addr]threatIndicator
+	ranges []threatRange // few enough in practice to scan
+}
+
+type threatRange struct {
+	prefix netip.Prefix
+	threatIndicator
+}
+
+// lookup returns the highest-confidence indicator covering ip.
+func (x *threatIndex) lookup(ip string) (threatIndicator, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return threatIndicator{}, false
+	}
+	addr = addr.Unmap()
+	best, ok := x.ips[addr]
+	for _, r := range x.ranges {
+		if r.prefix.Contains(addr) && (!ok || r.Confidence > best.Confidence) {
+			best, ok = r.threatIndicator, true
+		}
+	}
+	return best, ok
+}
+
+// parseIndicator reads an IP or CIDR, normalized so lookups match.
+func parseIndicator(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		return p.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// feedStatus is the outcome of a feed's last pull.
+type feedStatus struct {
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	Indicators int       `json:"indicators"`
+	PulledAt   time.Time `json:"pulled_at,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ThreatIntel pulls the feeds and matches entries against them.
+type ThreatIntel struct {
+	db       *store.DB
+	alerts   *Alerts
+	cfg      ThreatIntelConfig
+	escalate Severity
+	cooldown time.Duration
+	client   *http.Client
+	index    atomic.Pointer[threatIndex]
+
+	mu       sync.Mutex
+	status   map[string]feedStatus
+	alerted  map[string]time.Time // tenant/ip -> last alert
+	matches  int64
+	loadedAt time.Time
+}
+
+func NewThreatIntel(db *store.DB, alerts *Alerts, cfg ThreatIntelConfig) (*ThreatIntel, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	escalate, _ := ParseSeverity(cfg.EscalateTo)
+	cooldown, _ := time.ParseDuration(cfg.AlertCooldown)
+	t := &ThreatIntel{
+		db:       db,
+		alerts:   alerts,
+		cfg:      cfg,
+		escalate: escalate,
+		cooldown: cooldown,
+		client:   &http.Client{Timeout: time.Minute},
+		status:   map[string]feedStatus{},
+		alerted:  map[string]time.Time{},
+	}
+	t.index.Store(&threatIndex{ips: map[netip.Addr]threatIndicator{}})
+	return t, nil
+}
+
+// Run loads the indicators already stored, so matching starts before the
+// first pull finishes, then pulls every feed each interval.
+func (t *ThreatIntel) Run() {
+	if err := t.load(); err != nil {
+		slog.Warn("Failed to load threat indicators", "err", err)
+	}
+	interval, _ := time.ParseDuration(t.cfg.Interval)
+	for {
+		t.pullAll(context.Background())
+		time.Sleep(interval)
+	}
+}
+
+// pullAll refreshes every feed and reloads the index. A feed that fails
+// keeps its previous indicators.
+func (t *ThreatIntel) pullAll(ctx context.Context) {
+	for _, feed := range t.cfg.Feeds {
+		st := feedStatus{Name: feed.Name, Type: feed.Type, PulledAt: time.Now()}
+		indicators, err := t.fetch(ctx, feed)
+		if err == nil {
+			err = t.store(feed.Name, indicators)
+		}
+		if err != nil {
+			slog.Warn("Threat feed failed", "feed", feed.Name, "err", err)
+			st.Error = err.Error()
+		} else {
+			st.Indicators = len(indicators)
+			slog.Info("Threat feed pulled", "feed", feed.Name, "indicators", len(indicators))
+		}
+		t.mu.Lock()
+		if err != nil {
+			st.Indicators = t.status[feed.Name].Indicators
+		}
+		t.status[feed.Name] = st
+		t.mu.Unlock()
+	}
+	if err := t.load(); err != nil {
+		slog.Warn("Failed to load threat indicators", "err", err)
+	}
+}
+
+// fetch reads a feed's current indicators, dropping any below its
+// minimum confidence or not parseable as an IP or range.
+func (t *ThreatIntel) fetch(ctx context.Context, feed ThreatFeed) ([]threatIndicator, error) {
+	var (
+		indicators []threatIndicator
+		err        error
+	)
+	switch feed.Type {
+	case "abuseipdb":
+		indicators, err = t.fetchAbuseIPDB(ctx, feed)
+	default:
+		var data []byte
+		if data, err = t.read(ctx, feed); err != nil {
+			return nil, err
+		}
+		if feed.Type == "csv" {
+			indicators, err = parseIndicatorCSV(data)
+		} else {
+			indicators, err = parseSTIXBundle(data, time.Now())
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	kept := indicators[:0]
+	for _, ind := range indicators {
+		p, err := parseIndicator(ind.Indicator)
+		if err != nil || ind.Confidence < feed.MinConfidence {
+			continue
+		}
+		ind.Feed, ind.Indicator = feed.Name, p.String()
+		if p.IsSingleIP() {
+			ind.Indicator = p.Addr().String()
+		}
+		kept = append(kept, ind)
+	}
+	return kept, nil
+}
+
+// read returns a csv or stix feed's contents.
+func (t *ThreatIntel) read(ctx context.Context, feed ThreatFeed) ([]byte, error) {
+	if feed.Path != "" {
+		return os.ReadFile(feed.Path)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return t.do(req)
+}
+
+func (t *ThreatIntel) do(req *http.Request) ([]byte, error) {
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return body, nil
+}
+
+// fetchAbuseIPDB pulls AbuseIPDB's blacklist, whose entries carry an abuse
+// confidence score we keep as the indicator's confidence.
+func (t *ThreatIntel) fetchAbuseIPDB(ctx context.Context, feed ThreatFeed) ([]threatIndicator, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.URL+"?confidenceMinimum="+strconv.Itoa(feed.MinConfidence), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Key", feed.APIKey)
+	req.Header.Set("Accept", "application/json")
+	body, err := t.do(req)
+	if err != nil {
+		return nil, err
+	}
+	var page struct {
+		Data []struct {
+			IPAddress            string `json:"ipAddress"`
+			AbuseConfidenceScore int    `json:"abuseConfidenceScore"`
+			CountryCode          string `json:"countryCode"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("decode blacklist: %w", err)
+	}
+	indicators := make([]threatIndicator, 0, len(page.Data))
+	for _, d := range page.Data {
+		desc := "reported to AbuseIPDB"
+		if d.CountryCode != "" {
+			desc += " (" + d.CountryCode + ")"
+		}
+		indicators = append(indicators, threatIndicator{Indicator: d.IPAddress, Confidence: d.AbuseConfidenceScore, Description: desc})
+	}
+	return indicators, nil
+}
+
+// parseIndicatorCSV reads indicator[,confidence[,description]] lines. Blank
+// lines, # comments, and a header row are skipped; confidence defaults to
+// 100.
+func parseIndicatorCSV(data []byte) ([]threatIndicator, error) {
+	r := csv.NewReader(bufio.NewReader(bytes.NewReader(data)))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	var indicators []threatIndicator
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return indicators, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) == 0 || rec[0] == "" {
+			continue
+		}
+		if _, err := parseIndicator(rec[0]); err != nil {
+			continue // header or junk
+		}
+		ind := threatIndicator{Indicator: rec[0], Confidence: 100}
+		if len(rec) > 1 && rec[1] != "" {
+			if ind.Confidence, err = strconv.Atoi(strings.TrimSpace(rec[1])); err != nil {
+				continue
+			}
+		}
+		if len(rec) > 2 {
+			ind.Description = rec[2]
+		}
+		indicators = append(indicators, ind)
+	}
+}
+
+// stixAddress matches IP comparisons in STIX patterns, e.g.
+// [ipv4-addr:value = '198.51.100.7'] or [ipv6-addr:value = '2001:db8::/32'].
+var stixAddress = regexp.MustCompile(`ipv[46]-addr:value\s*=\s*'([^']+)'`)
+
+// parseSTIXBundle reads the IP indicators in a STIX 2.1 bundle: indicator
+// objects with IP patterns that haven't expired, and bare ipv4-addr and
+// ipv6-addr objects.
+func parseSTIXBundle(data []byte, now time.Time) ([]threatIndicator, error) {
+	var bundle struct {
+		Type    string `json:"type"`
+		Objects []struct {
+			Type        string    `json:"type"`
+			Name        string    `json:"name"`
+			Description string    `json:"description"`
+			Pattern     string    `json:"pattern"`
+			PatternType string    `json:"pattern_type"`
+			Value       string    `json:"value"`
+			Confidence  *int      `json:"confidence"`
+			ValidUntil  time.Time `json:"valid_until"`
+			Revoked     bool      `json:"revoked"`
+		} `json:"objects"`
+	}
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("decode STIX bundle: %w", err)
+	}
+	if bundle.Type != "bundle" {
+		return nil, fmt.Errorf("not a STIX bundle (type %q)", bundle.Type)
+	}
+	var indicators []threatIndicator
+	for _, o := range bundle.Objects {
+		confidence := 100
+		if o.Confidence != nil {
+			confidence = *o.Confidence
+		}
+		switch o.Type {
+		case "indicator":
+			if o.Revoked || (o.PatternType != "" && o.PatternType != "stix") || (!o.ValidUntil.IsZero() && o.ValidUntil.Before(now)) {
+				continue
+			}
+			desc := o.Name
+			if desc == "" {
+				desc = o.Description
+			}
+			for _, m := range stixAddress.FindAllStringSubmatch(o.Pattern, -1) {
+				indicators = append(indicators, threatIndicator{Indicator: m[1], Confidence: confidence, Description: desc})
+			}
+		case "ipv4-addr", "ipv6-addr":
+			indicators = append(indicators, threatIndicator{Indicator: o.Value, Confidence: confidence})
+		}
+	}
+	return indicators, nil
+}
+
+// store replaces feed's indicators in one transaction.
+func (t *ThreatIntel) store(feed string, indicators []threatIndicator) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM threat_indicators WHERE feed = ?", feed); err != nil {
+		return err
+	}
+	// A feed may list an indicator twice; keep the higher confidence.
+	seen := make(map[string]int, len(indicators))
+	unique := indicators[:0]
+	for _, ind := range indicators {
+		if i, ok := seen[ind.Indicator]; ok {
+			unique[i].Confidence = max(unique[i].Confidence, ind.Confidence)
+			continue
+		}
+		seen[ind.Indicator] = len(unique)
+		unique = append(unique, ind)
+	}
+	indicators = unique
+
+	const batch = 500
+	for start := 0; start < len(indicators); start += batch {
+		chunk := indicators[start:min(start+batch, len(indicators))]
+		args := make([]any, 0, 4*len(chunk))
+		rows := make([]string, len(chunk))
+		for i, ind := range chunk {
+			desc := []rune(ind.Description)
+			if len(desc) > 255 {
+				desc = desc[:255]
+			}
+			rows[i] = "(?, ?, ?, ?)"
+			args = append(args, feed, ind.Indicator, ind.Confidence, string(desc))
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO threat_indicators (feed, indicator, confidence, description)
+			VALUES `+strings.Join(rows, ", "), args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// load rebuilds the index from the table, so every replica matches the
+// same indicators whichever pulled them.
+func (t *ThreatIntel) load() error {
+	rows, err := t.db.Query("SELECT feed, indicator, confidence, COALESCE(description, '') FROM threat_indicators")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	x := &threatIndex{ips: map[netip.Addr]threatIndicator{}}
+	for rows.Next() {
+		var ind threatIndicator
+		if err := rows.Scan(&ind.Feed, &ind.Indicator, &ind.Confidence, &ind.Description); err != nil {
+			return err
+		}
+		p, err := parseIndicator(ind.Indicator)
+		if err != nil {
+			continue
+		}
+		if !p.IsSingleIP() {
+			x.ranges = append(x.ranges, threatRange{prefix: p, threatIndicator: ind})
+		} else if cur, ok := x.ips[p.Addr()]; !ok || ind.Confidence > cur.Confidence {
+			x.ips[p.Addr()] = ind
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	t.index.Store(x)
+	t.mu.Lock()
+	t.loadedAt = time.Now()
+	t.mu.Unlock()
+	return nil
+}
+
+// matchThreat is the threat pipeline stage. An entry from a known-bad IP
+// gets threat_match, threat_indicator, and threat_confidence fields and is
+// raised to at least the configured severity. t is nil when disabled.
+func (t *ThreatIntel) matchThreat(entry *LogEntry) error {
+	if t == nil || entry.IPAddress == "" {
+		return nil
+	}
+	ind, ok := t.index.Load().lookup(entry.IPAddress)
+	if !ok {
+		return nil
+	}
+	if entry.Fields == nil {
+		entry.Fields = map[string]string{}
+	}
+	entry.Fields["threat_match"] = ind.Feed
+	entry.Fields["threat_indicator"] = ind.Indicator
+	entry.Fields["threat_confidence"] = strconv.Itoa(ind.Confidence)
+	if ind.Description != "" {
+		entry.Fields["threat_description"] = ind.Description
+	}
+	if entry.Severity < t.escalate {
+		entry.Severity = t.escalate
+	}
+	return nil
+}
+
+// threatScore is the risk scorer's threat intelligence signal: the
+// matched indicator's confidence, 0-1.
+func threatScore(e LogEntry) float64 {
+	if e.Fields["threat_match"] == "" {
+		return 0
+	}
+	c, _ := strconv.Atoi(e.Fields["threat_confidence"])
+	return float64(c) / 100
+}
+
+// Observe alerts on a stored entry that matched an indicator, at most once
+// per tenant and IP per cooldown.
+func (t *ThreatIntel) Observe(entry LogEntry) {
+	if t == nil || entry.Fields["threat_match"] == "" {
+		return
+	}
+	now := time.Now()
+	key := entry.TenantID + "/" + entry.IPAddress
+
+	t.mu.Lock()
+	t.matches++
+	if last, ok := t.alerted[key]; ok && now.Sub(last) < t.cooldown {
+		t.mu.Unlock()
+		return
+	}
+	t.alerted[key] = now
+	for k, last := range t.alerted {
+		if now.Sub(last) >= t.cooldown {
+			delete(t.alerted, k)
+		}
+	}
+	t.mu.Unlock()
+
+	go t.alerts.Raise(Alert{
+		TenantID: entry.TenantID,
+		Kind:     "threat_match",
+		Severity: entry.Severity,
+		Title:    fmt.Sprintf("Activity from known-bad IP %s (%s)", entry.IPAddress, entry.Fields["threat_match"]),
+		Details: map[string]any{
+			"ip":          entry.IPAddress,
+			"feed":        entry.Fields["threat_match"],
+			"indicator":   entry.Fields["threat_indicator"],
+			"confidence":  entry.Fields["threat_confidence"],
+			"description": entry.Fields["threat_description"],
+			"source":      entry.Source,
+			"log_id":      entry.ID,
+		},
+	})
+}
+
+// ThreatIntelStatus reports the feeds and the loaded index.
+type ThreatIntelStatus struct {
+	Feeds      []feedStatus     `json:"feeds"`
+	Indicators int              `json:"indicators"`
+	LoadedAt   time.Time        `json:"loaded_at"`
+	Matches    int64            `json:"matches"` // stored entries that matched since startup
+	Match      *threatIndicator `json:"match,omitempty"`
+}
+
+// handler serves GET /api/threat-intel, and with ?ip= looks that IP up.
+func (t *ThreatIntel) handler(w http.ResponseWriter, r *http.Request) {
+	x := t.index.Load()
+	t.mu.Lock()
+	status := ThreatIntelStatus{
+		Feeds:      []feedStatus{},
+		Indicators: len(x.ips) + len(x.ranges),
+		LoadedAt:   t.loadedAt,
+		Matches:    t.matches,
+	}
+	for _, f := range t.cfg.Feeds {
+		st, ok := t.status[f.Name]
+		if !ok {
+			st = feedStatus{Name: f.Name, Type: f.Type}
+		}
+		status.Feeds = append(status.Feeds, st)
+	}
+	t.mu.Unlock()
+	if ip := r.URL.Query().Get("ip"); ip != "" {
+		if _, err := netip.ParseAddr(ip); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid ip")
+			return
+		}
+		if ind, ok := x.lookup(ip); ok {
+			status.Match = &ind
+		}
+	}
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// certReloader holds the server certificate and client CA pool and swaps
+// them atomically on reload. Handshakes in progress keep what they loaded.
+type certReloader struct {
+	minVersion uint16
+
+	mu       sync.RWMutex
+	cfg      TLSConfig
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+}
+
+func newCertReloader(cfg TLSConfig) (*certReloader, error) {
+	r := &certReloader{minVersion: tls.VersionTLS12}
+	if cfg.MinVersion == "1.3" {
+		r.minVersion = tls.VersionTLS13
+	}
+	return r, r.reload(cfg)
+}
+
+// reload loads the certificate and client CA named by cfg. On error the
+// current ones stay in use.
+func (r *certReloader) reload(cfg TLSConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if cfg.ClientCA != "" {
+		pem, err := os.ReadFile(cfg.ClientCA)
+		if err != nil {
+			return fmt.Errorf("load client CA: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("load client CA: no certificates in %s", cfg.ClientCA)
+		}
+	}
+	r.mu.Lock()
+	r.cfg, r.cert, r.clientCA = cfg, &cert, pool
+	r.mu.Unlock()
+	return nil
+}
+
+// tlsConfig returns a server config that resolves the certificate and
+// client CA on every handshake.
+func (r *certReloader) tlsConfig() *tls.Config {
+	base := &tls.Config{MinVersion: r.minVersion}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		c := &tls.Config{MinVersion: r.minVersion, Certificates: []tls.Certificate{*r.cert}}
+		if r.clientCA != nil {
+			c.ClientCAs = r.clientCA
+			c.ClientAuth = tls.RequireAndVerifyClientCert
+			if r.cfg.ClientAuth == "optional" {
+				c.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+		}
+		return c, nil
+	}
+	return base
+}
+
+// serveTLS serves handler over TLS on cfg.Addr, reloading certificates
+// along with the rest of the config.
+func serveTLS(cfg TLSConfig, handler http.Handler, reloader *Reloader) error {
+	certs, err := newCertReloader(cfg)
+	if err != nil {
+		return err
+	}
+	reloader.register("tls", func(c Config) error {
+		return certs.reload(c.TLS.WithDefaults())
+	}, func(c Config) []string {
+		return []string{c.TLS.Cert, c.TLS.Key, c.TLS.ClientCA}
+	})
+	srv := &http.Server{Addr: cfg.Addr, Handler: handler, TLSConfig: certs.tlsConfig()}
+	slog.Info("HTTPS/WSS server running", "addr", cfg.Addr, "mtls", cfg.ClientCA != "")
+	return srv.ListenAndServeTLS("", "")
+}
+
+// httpsRedirect is the plain HTTP listener once TLS is enabled: requests are
+// redirected to the TLS listener, or refused without redirect_http, so the
+// API and client certificate checks can't be bypassed over plain HTTP.
+// Health and autoscaling probes are still served directly, since load
+// balancers and metrics adapters often probe over plain HTTP.
+func httpsRedirect(cfg TLSConfig, handler http.Handler) http.Handler {
+	_, port, _ := net.SplitHostPort(cfg.Addr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" || r.URL.Path == "/autoscale" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if !cfg.RedirectHTTP {
+			http.Error(w, "HTTPS required", http.StatusForbidden)
+			return
+		}
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// certActor names the client by its verified certificate, if it sent one.
+func certActor(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+	return "cert:" + r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSRedirect(t *testing.T) {
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		redirect bool
+		path     string
+		want     int
+		location string
+	}{
+		{name: "api refused", path: "/api/logs", want: http.StatusForbidden},
+		{name: "websocket refused", path: "/ws", want: http.StatusForbidden},
+		{name: "api redirected", redirect: true, path: "/api/logs?limit=5", want: http.StatusPermanentRedirect, location: "https://logs.example:8443/api/logs?limit=5"},
+		{name: "health served", path: "/health", want: http.StatusOK},
+		{name: "autoscale served", redirect: true, path: "/autoscale", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := TLSConfig{Enabled: true, RedirectHTTP: tt.redirect}.WithDefaults()
+			r := httptest.NewRequest(http.MethodGet, "http://logs.example:8080"+tt.path, nil)
+			w := httptest.NewRecorder()
+			httpsRedirect(cfg, api).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("GET %s = %d, want %d", tt.path, w.Code, tt.want)
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+		})
+	}
+}
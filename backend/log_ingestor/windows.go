@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"1logx/log_ingestor/internal/pipeline"
+)
+
+// windowsIPFields are event data fields checked, in order, for the entry's
+// IP address: logons, Kerberos/NTLM, and Sysmon/firewall events.
+var windowsIPFields = []string{"IpAddress", "SourceAddress", "ClientAddress", "SourceIp"}
+
+// windowsEvent is a Windows event, as shipped by winlogbeat or rendered by a
+// Windows Event Forwarding collector, before it's mapped onto a LogEntry.
+// Field names follow the event schema (and Sigma's Windows rules), so
+// EventID, Channel, and event data such as TargetUserName match as is.
+type windowsEvent struct {
+	EventID   string
+	Channel   string
+	Provider  string
+	Computer  string
+	RecordID  string
+	Task      string
+	Opcode    string
+	Level     int    // 1 critical ... 5 verbose; 0 is "log always"
+	LevelText string // the rendered level, preferred over Level
+	Keywords  []string
+	Message   string
+	Time      time.Time
+	SourceIP  string // set by shippers that resolve it, e.g. ECS source.ip
+	Data      map[string]string
+}
+
+// entry maps e onto a LogEntry. The source is the channel, so Sigma rules
+// with service: security match Security log events without configuration.
+func (e windowsEvent) entry() LogEntry {
+	entry := LogEntry{
+		Timestamp: e.Time,
+		Source:    e.Channel,
+		Severity:  windowsSeverity(e.Level, e.LevelText, e.Keywords),
+		Message:   strings.TrimSpace(e.Message),
+		Fields:    map[string]string{},
+	}
+	if entry.Source == "" {
+		entry.Source = "Windows"
+	}
+	if entry.Message == "" {
+		// Events whose provider isn't installed on the collector arrive
+		// unrendered.
+		entry.Message = fmt.Sprintf("Event %s from %s", e.EventID, e.Provider)
+	}
+	if e.Computer != "" {
+		entry.Labels = map[string]string{"host": e.Computer}
+	}
+
+	for key, v := range map[string]string{
+		"EventID": e.EventID, "Channel": e.Channel, "Provider_Name": e.Provider, "Computer": e.Computer,
+		"EventRecordID": e.RecordID, "Task": e.Task, "Opcode": e.Opcode, "Keywords": strings.Join(e.Keywords, ", "),
+	} {
+		if v != "" {
+			entry.Fields[key] = v
+		}
+	}
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if len(entry.Fields) == pipeline.MaxAttributes {
+			break
+		}
+		key := windowsFieldKey(k)
+		if _, taken := entry.Fields[key]; taken || key == "" {
+			continue
+		}
+		v := e.Data[k]
+		if len(v) > pipeline.MaxAttributeValue {
+			v = v[:pipeline.MaxAttributeValue]
+		}
+		entry.Fields[key] = v
+	}
+
+	entry.IPAddress = windowsIP(e.SourceIP)
+	for _, key := range windowsIPFields {
+		if entry.IPAddress != "" {
+			break
+		}
+		entry.IPAddress = windowsIP(e.Data[key])
+	}
+	return entry
+}
+
+// windowsFieldKey makes an event data name usable as a field key.
+func windowsFieldKey(name string) string {
+	key := strings.Map(func(r rune) rune {
+		if r < 128 && pipeline.AttributeKeyPattern.MatchString(string(r)) {
+			return r
+		}
+		return '_'
+	}, name)
+	if len(key) > 64 {
+		key = key[:64]
+	}
+	return key
+}
+
+// windowsIP returns s as an IP address, or "" for the placeholders Windows
+// uses when there is none ("-", "::1" from local logons, host names).
+func windowsIP(s string) string {
+	s = strings.TrimPrefix(strings.TrimSpace(s), `\\`)
+	s = strings.TrimPrefix(s, "::ffff:")
+	ip := net.ParseIP(s)
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
+		return ""
+	}
+	return ip.String()
+}
+
+// windowsSeverity maps an event level onto the canonical levels. Security
+// log events are all informational, so failed audits are raised to WARNING.
+func windowsSeverity(level int, text string, keywords []string) Severity {
+	var sev Severity
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "critical":
+		sev = SeverityCritical
+	case "error":
+		sev = SeverityAlert
+	case "warning":
+		sev = SeverityWarning
+	case "":
+		switch level {
+		case 1:
+			sev = SeverityCritical
+		case 2:
+			sev = SeverityAlert
+		case 3:
+			sev = SeverityWarning
+		}
+	}
+	for _, kw := range keywords {
+		if strings.EqualFold(kw, "Audit Failure") && sev < SeverityWarning {
+			sev = SeverityWarning
+		}
+	}
+	return sev
+}
+
+// winlogbeatEvent is the subset of a winlogbeat (ECS) document that's
+// mapped. Older versions send event_id and record_id as numbers.
+type winlogbeatEvent struct {
+	Timestamp time.Time `json:"@timestamp"`
+	Message   string    `json:"message"`
+	Log       struct {
+		Level string `json:"level"`
+	} `json:"log"`
+	Source struct {
+		IP string `json:"ip"`
+	} `json:"source"`
+	Winlog struct {
+		EventID      json.RawMessage `json:"event_id"`
+		RecordID     json.RawMessage `json:"record_id"`
+		Channel      string          `json:"channel"`
+		ProviderName string          `json:"provider_name"`
+		ComputerName string          `json:"computer_name"`
+		Task         string          `json:"task"`
+		Opcode       string          `json:"opcode"`
+		Keywords     []string        `json:"keywords"`
+		EventData    map[string]any  `json:"event_data"`
+		UserData     map[string]any  `json:"user_data"`
+	} `json:"winlog"`
+}
+
+func (b winlogbeatEvent) event() windowsEvent {
+	w := b.Winlog
+	e := windowsEvent{
+		EventID:   rawScalar(w.EventID),
+		Channel:   w.Channel,
+		Provider:  w.ProviderName,
+		Computer:  w.ComputerName,
+		RecordID:  rawScalar(w.RecordID),
+		Task:      w.Task,
+		Opcode:    w.Opcode,
+		LevelText: b.Log.Level,
+		Keywords:  w.Keywords,
+		Message:   b.Message,
+		Time:      b.Timestamp,
+		SourceIP:  b.Source.IP,
+		Data:      make(map[string]string, len(w.EventData)+len(w.UserData)),
+	}
+	for _, data := range []map[string]any{w.UserData, w.EventData} {
+		for k, v := range data {
+			if s, ok := v.(string); ok {
+				e.Data[k] = s
+				continue
+			}
+			raw, _ := json.Marshal(v)
+			e.Data[k] = string(raw)
+		}
+	}
+	return e
+}
+
+// rawScalar renders a JSON string or number as text.
+func rawScalar(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// decodeWinlogbeat reads winlogbeat documents: one, an array, or a stream of
+// newline-delimited documents as sent by Logstash's http output.
+func decodeWinlogbeat(body []byte) ([]windowsEvent, error) {
+	if bytes.HasPrefix(body, []byte("[")) {
+		var docs []winlogbeatEvent
+		if err := json.Unmarshal(body, &docs); err != nil {
+			return nil, err
+		}
+		events := make([]windowsEvent, len(docs))
+		for i, doc := range docs {
+			events[i] = doc.event()
+		}
+		return events, nil
+	}
+	var events []windowsEvent
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var doc winlogbeatEvent
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", len(events), err)
+		}
+		events = append(events, doc.event())
+	}
+}
+
+// evtxEvent is an event in the Windows event XML schema, as forwarded by
+// WEF with RenderedText content or exported with wevtutil.
+type evtxEvent struct {
+	System struct {
+		Provider struct {
+			Name string `xml:"Name,attr"`
+		} `xml:"Provider"`
+		EventID     string `xml:"EventID"`
+		Level       int    `xml:"Level"`
+		Task        string `xml:"Task"`
+		Opcode      string `xml:"Opcode"`
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+		EventRecordID string `xml:"EventRecordID"`
+		Channel       string `xml:"Channel"`
+		Computer      string `xml:"Computer"`
+	} `xml:"System"`
+	EventData []evtxData `xml:"EventData>Data"`
+	UserData  struct {
+		Inner []byte `xml:",innerxml"`
+	} `xml:"UserData"`
+	RenderingInfo struct {
+		Message  string   `xml:"Message"`
+		Level    string   `xml:"Level"`
+		Task     string   `xml:"Task"`
+		Opcode   string   `xml:"Opcode"`
+		Keywords []string `xml:"Keywords>Keyword"`
+	} `xml:"RenderingInfo"`
+}
+
+type evtxData struct {
+	Name  string `xml:"Name,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (x evtxEvent) event() windowsEvent {
+	s, r := x.System, x.RenderingInfo
+	e := windowsEvent{
+		EventID:   s.EventID,
+		Channel:   s.Channel,
+		Provider:  s.Provider.Name,
+		Computer:  s.Computer,
+		RecordID:  s.EventRecordID,
+		Task:      r.Task,
+		Opcode:    r.Opcode,
+		Level:     s.Level,
+		LevelText: r.Level,
+		Keywords:  r.Keywords,
+		Message:   r.Message,
+		Data:      make(map[string]string, len(x.EventData)),
+	}
+	e.Time, _ = time.Parse(time.RFC3339Nano, s.TimeCreated.SystemTime)
+	for i, d := range x.EventData {
+		name := d.Name
+		if name == "" {
+			// Classic events have positional, unnamed data.
+			name = "param" + strconv.Itoa(i+1)
+		}
+		e.Data[name] = strings.TrimSpace(d.Value)
+	}
+	if inner := bytes.TrimSpace(x.UserData.Inner); len(inner) > 0 {
+		e.Data["UserData"] = string(inner)
+	}
+	return e
+}
+
+// decodeEventXML reads every <Event> element in body, whether bare, wrapped
+// in <Events>, or concatenated.
+func decodeEventXML(body []byte) ([]windowsEvent, error) {
+	var events []windowsEvent
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "Event" {
+			continue
+		}
+		var x evtxEvent
+		if err := dec.DecodeElement(&x, &start); err != nil {
+			return nil, fmt.Errorf("event %d: %w", len(events), err)
+		}
+		events = append(events, x.event())
+	}
+}
+
+// windowsIngestHandler serves POST /api/ingest/windows. It accepts
+// winlogbeat JSON (one document, an array, or NDJSON) or, with an XML
+// content type, WEF/EVTX event XML, optionally gzip-compressed.
+func windowsIngestHandler(in *pipeline.Ingestor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, ok := readIngestBody(w, r)
+		if !ok {
+			return
+		}
+		data = bytes.TrimSpace(data)
+
+		var events []windowsEvent
+		var err error
+		if strings.Contains(r.Header.Get("Content-Type"), "xml") || bytes.HasPrefix(data, []byte("<")) {
+			events, err = decodeEventXML(data)
+		} else {
+			events, err = decodeWinlogbeat(data)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid Windows event payload: "+err.Error())
+			return
+		}
+		entries := make([]LogEntry, len(events))
+		for i, e := range events {
+			entries[i] = e.entry()
+		}
+		ingestEntries(w, r, in, "windows", entries)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWindowsEventEntries(t *testing.T) {
+	const logon = `{"@timestamp":"2024-05-01T08:00:00Z","message":"An account failed to log on.","log":{"level":"information"},` +
+		`"winlog":{"event_id":4625,"record_id":"991","channel":"Security","provider_name":"Microsoft-Windows-Security-Auditing",` +
+		`"computer_name":"dc01.corp","task":"Logon","keywords":["Audit Failure"],` +
+		`"event_data":{"TargetUserName":"alice","IpAddress":"::ffff:203.0.113.9","LogonType":"3","Status":{"code":"0xc000006d"}}}}`
+	const xmlEvent = `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event"><System>` +
+		`<Provider Name="Service Control Manager"/><EventID>7045</EventID><Level>4</Level>` +
+		`<TimeCreated SystemTime="2024-05-01T09:30:00.5Z"/><EventRecordID>12</EventRecordID>` +
+		`<Channel>System</Channel><Computer>web01</Computer></System>` +
+		`<EventData><Data Name="ServiceName">evilsvc</Data><Data Name="ImagePath"> C:\tmp\x.exe </Data></EventData>` +
+		`<RenderingInfo><Message>A service was installed in the system.</Message><Level>Information</Level></RenderingInfo></Event>`
+
+	tests := []struct {
+		name   string
+		decode func([]byte) ([]windowsEvent, error)
+		body   string
+		want   []LogEntry
+	}{
+		{
+			name:   "winlogbeat failed logon",
+			decode: decodeWinlogbeat,
+			body:   logon,
+			want: []LogEntry{{
+				Timestamp: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC),
+				Source:    "Security",
+				Severity:  SeverityWarning,
+				Message:   "An account failed to log on.",
+				IPAddress: "203.0.113.9",
+				Labels:    map[string]string{"host": "dc01.corp"},
+				Fields: map[string]string{
+					"EventID": "4625", "Channel": "Security", "Provider_Name": "Microsoft-Windows-Security-Auditing",
+					"Computer": "dc01.corp", "EventRecordID": "991", "Task": "Logon", "Keywords": "Audit Failure",
+					"TargetUserName": "alice", "IpAddress": "::ffff:203.0.113.9", "LogonType": "3", "Status": `{"code":"0xc000006d"}`,
+				},
+			}},
+		},
+		{
+			name:   "winlogbeat ndjson, unrendered and without a channel",
+			decode: decodeWinlogbeat,
+			body: logon + "\n" +
+				`{"@timestamp":"2024-05-01T08:00:01Z","log":{"level":"error"},"source":{"ip":"198.51.100.2"},` +
+				`"winlog":{"event_id":"1000","provider_name":"Application Error","event_data":{"Bad Key!":"x","IpAddress":"-"}}}`,
+			want: []LogEntry{
+				{Source: "Security", Severity: SeverityWarning, IPAddress: "203.0.113.9"},
+				{
+					Timestamp: time.Date(2024, 5, 1, 8, 0, 1, 0, time.UTC),
+					Source:    "Windows",
+					Severity:  SeverityAlert,
+					Message:   "Event 1000 from Application Error",
+					IPAddress: "198.51.100.2",
+					Fields: map[string]string{
+						"EventID": "1000", "Provider_Name": "Application Error", "Bad_Key_": "x", "IpAddress": "-",
+					},
+				},
+			},
+		},
+		{
+			name:   "event xml",
+			decode: decodeEventXML,
+			body:   "<Events>" + xmlEvent + "</Events>",
+			want: []LogEntry{{
+				Timestamp: time.Date(2024, 5, 1, 9, 30, 0, 5e8, time.UTC),
+				Source:    "System",
+				Severity:  SeverityInfo,
+				Message:   "A service was installed in the system.",
+				Labels:    map[string]string{"host": "web01"},
+				Fields: map[string]string{
+					"EventID": "7045", "Channel": "System", "Provider_Name": "Service Control Manager",
+					"Computer": "web01", "EventRecordID": "12", "ServiceName": "evilsvc", "ImagePath": `C:\tmp\x.exe`,
+				},
+			}},
+		},
+		{
+			name:   "classic event xml with positional data",
+			decode: decodeEventXML,
+			body: `<Event><System><Provider Name="Legacy"/><EventID>1</EventID><Level>2</Level><Channel>Application</Channel></System>` +
+				`<EventData><Data>first</Data><Data>second</Data></EventData></Event>`,
+			want: []LogEntry{{
+				Source:   "Application",
+				Severity: SeverityAlert,
+				Message:  "Event 1 from Legacy",
+				Fields: map[string]string{
+					"EventID": "1", "Channel": "Application", "Provider_Name": "Legacy", "param1": "first", "param2": "second",
+				},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := tt.decode([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != len(tt.want) {
+				t.Fatalf("got %d events, want %d", len(events), len(tt.want))
+			}
+			for i, e := range events {
+				got, want := e.entry(), tt.want[i]
+				if got.Source != want.Source || got.Severity != want.Severity || got.IPAddress != want.IPAddress {
+					t.Errorf("event %d: source %q severity %v ip %q, want %q %v %q",
+						i, got.Source, got.Severity, got.IPAddress, want.Source, want.Severity, want.IPAddress)
+				}
+				if want.Fields == nil {
+					continue // only the columns above are checked
+				}
+				if !got.Timestamp.Equal(want.Timestamp) || got.Message != want.Message {
+					t.Errorf("event %d: timestamp %v message %q, want %v %q", i, got.Timestamp, got.Message, want.Timestamp, want.Message)
+				}
+				if !maps.Equal(got.Labels, want.Labels) {
+					t.Errorf("event %d: labels = %v, want %v", i, got.Labels, want.Labels)
+				}
+				if !maps.Equal(got.Fields, want.Fields) {
+					t.Errorf("event %d: fields = %v, want %v", i, got.Fields, want.Fields)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeWindowsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		decode func([]byte) ([]windowsEvent, error)
+		body   string
+	}{
+		{"json array", decodeWinlogbeat, `[{"winlog":`},
+		{"ndjson", decodeWinlogbeat, "{\"message\":\"ok\"}\n{not json}"},
+		{"xml", decodeEventXML, `<Event><System><EventID>1</System></Event>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.decode([]byte(tt.body)); err == nil {
+				t.Error("decoded invalid payload")
+			}
+		})
+	}
+}
+
+func TestWindowsSeverity(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    int
+		text     string
+		keywords []string
+		want     Severity
+	}{
+		{"log always", 0, "", nil, SeverityInfo},
+		{"critical level", 1, "", nil, SeverityCritical},
+		{"error level", 2, "", nil, SeverityAlert},
+		{"warning level", 3, "", nil, SeverityWarning},
+		{"verbose level", 5, "", nil, SeverityInfo},
+		{"rendered text wins", 1, "Information", nil, SeverityInfo},
+		{"rendered error", 0, " Error ", nil, SeverityAlert},
+		{"audit failure", 0, "Information", []string{"Audit Failure"}, SeverityWarning},
+		{"audit failure doesn't lower", 2, "", []string{"audit failure"}, SeverityAlert},
+		{"audit success", 0, "", []string{"Audit Success"}, SeverityInfo},
+	}
+	for _, tt := range tests {
+		if got := windowsSeverity(tt.level, tt.text, tt.keywords); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWindowsIP(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"203.0.113.9", "203.0.113.9"},
+		{"::ffff:203.0.113.9", "203.0.113.9"},
+		{`\\10.1.2.3`, "10.1.2.3"},
+		{"2001:db8::5", "2001:db8::5"},
+		{"-", ""},
+		{"::1", ""},
+		{"127.0.0.1", ""},
+		{"0.0.0.0", ""},
+		{"WORKSTATION7", ""},
+	}
+	for _, tt := range tests {
+		if got := windowsIP(tt.in); got != tt.want {
+			t.Errorf("windowsIP(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWindowsIngestGzipBomb(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(bytes.Repeat([]byte(" "), maxIngestBody+1))
+	zw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/ingest/windows", &buf)
+	r.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	windowsIngestHandler(nil).ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /api/ingest/windows = %d, want %d: %s", w.Code, http.StatusRequestEntityTooLarge, w.Body)
+	}
+}
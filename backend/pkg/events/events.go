@@ -0,0 +1,75 @@
+// Package events defines the typed events 1L0Gx emits on its live stream and
+// fan-out brokers, so Go consumers can share one set of definitions instead
+// of copying structs that drift.
+//
+// Live log entries are sent bare as JSON LogEntry objects; everything else is
+// wrapped in an Envelope. Every type also has a protobuf form (see
+// proto/eventsv1).
+package events
+
+import "time"
+
+// Schema versions of each event type. A version is bumped whenever a type's
+// wire format changes incompatibly; additive fields don't bump it.
+const (
+	LogEntryVersion = 1
+	AlertVersion    = 1
+	IncidentVersion = 1
+	EnvelopeVersion = 1
+)
+
+// LogEntry represents a single security log.
+type LogEntry struct {
+	ID        int64     `json:"id,omitempty"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Source    string    `json:"source"`
+	Severity  Severity  `json:"severity"`
+	Message   string    `json:"message"`
+	IPAddress string    `json:"ip_address"`
+	// Fields holds structured attributes extracted by parsers (e.g. CEF/LEEF
+	// extensions). Stored as JSON in the logs table.
+	Fields map[string]string `json:"fields,omitempty"`
+	// Labels are caller-assigned tags (e.g. env, team, host) used for routing
+	// and filtering. Stored as JSON in the logs table.
+	Labels map[string]string `json:"labels,omitempty"`
+	// RiskScore is the 0-100 triage priority computed at ingest from
+	// severity and enrichment signals.
+	RiskScore int `json:"risk_score"`
+}
+
+// Alert is a notable condition raised by a background job (e.g. a rising
+// severity trend). Alerts are stored and pushed to the tenant's stream.
+type Alert struct {
+	ID        int64          `json:"id,omitempty"`
+	TenantID  string         `json:"tenant_id"`
+	Kind      string         `json:"kind"`
+	Severity  Severity       `json:"severity"`
+	Title     string         `json:"title"`
+	Details   map[string]any `json:"details,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Incident is a group of related logs under triage, opened by the incident
+// agent, from an alert, or by an analyst.
+type Incident struct {
+	ID             int64     `json:"id"`
+	TenantID       string    `json:"tenant_id"`
+	LogIDs         []int64   `json:"log_ids"`
+	Summary        string    `json:"summary"`
+	Severity       string    `json:"severity"` // LOW, MEDIUM, HIGH, CRITICAL
+	Recommendation string    `json:"recommendation"`
+	Status         string    `json:"status"` // OPEN, INVESTIGATING, MITIGATED, CLOSED
+	Assignee       string    `json:"assignee,omitempty"`
+	AlertID        int64     `json:"alert_id,omitempty"` // alert the incident was opened from
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Envelope is the wrapper for anything on the stream other than a live log
+// entry, e.g. {"type": "alert", "data": {...}}.
+type Envelope struct {
+	Type  string `json:"type"`
+	Error string `json:"error,omitempty"`
+	Data  any    `json:"data,omitempty"`
+}
@@ -0,0 +1,5 @@
+module 1logx/events
+
+go 1.22.0
+
+require google.golang.org/protobuf v1.35.1
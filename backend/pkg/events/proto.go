@@ -0,0 +1,244 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+
+	eventsv1 "1logx/events/proto/eventsv1"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ToProto converts e to its protobuf form.
+func (e LogEntry) ToProto() *eventsv1.LogEntry {
+	return &eventsv1.LogEntry{
+		Id:        e.ID,
+		TenantId:  e.TenantID,
+		Timestamp: timestamppb.New(e.Timestamp),
+		Source:    e.Source,
+		Severity:  e.Severity.String(),
+		Message:   e.Message,
+		IpAddress: e.IPAddress,
+		Fields:    e.Fields,
+		Labels:    e.Labels,
+		RiskScore: int32(e.RiskScore),
+	}
+}
+
+// LogEntryFromProto converts a protobuf log entry.
+func LogEntryFromProto(pb *eventsv1.LogEntry) (LogEntry, error) {
+	sev, err := ParseSeverity(pb.GetSeverity())
+	if err != nil && pb.GetSeverity() != "" {
+		return LogEntry{}, err
+	}
+	return LogEntry{
+		ID:        pb.GetId(),
+		TenantID:  pb.GetTenantId(),
+		Timestamp: pb.GetTimestamp().AsTime(),
+		Source:    pb.GetSource(),
+		Severity:  sev,
+		Message:   pb.GetMessage(),
+		IPAddress: pb.GetIpAddress(),
+		Fields:    pb.GetFields(),
+		Labels:    pb.GetLabels(),
+		RiskScore: int(pb.GetRiskScore()),
+	}, nil
+}
+
+func (e LogEntry) MarshalProto() ([]byte, error) { return proto.Marshal(e.ToProto()) }
+
+func (e *LogEntry) UnmarshalProto(data []byte) error {
+	var pb eventsv1.LogEntry
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return err
+	}
+	out, err := LogEntryFromProto(&pb)
+	if err != nil {
+		return err
+	}
+	*e = out
+	return nil
+}
+
+// ToProto converts a to its protobuf form. Details are carried as JSON.
+func (a Alert) ToProto() (*eventsv1.Alert, error) {
+	pb := &eventsv1.Alert{
+		Id:        a.ID,
+		TenantId:  a.TenantID,
+		Kind:      a.Kind,
+		Severity:  a.Severity.String(),
+		Title:     a.Title,
+		CreatedAt: timestamppb.New(a.CreatedAt),
+	}
+	if a.Details != nil {
+		details, err := json.Marshal(a.Details)
+		if err != nil {
+			return nil, fmt.Errorf("encode alert details: %w", err)
+		}
+		pb.DetailsJson = details
+	}
+	return pb, nil
+}
+
+// AlertFromProto converts a protobuf alert.
+func AlertFromProto(pb *eventsv1.Alert) (Alert, error) {
+	sev, err := ParseSeverity(pb.GetSeverity())
+	if err != nil && pb.GetSeverity() != "" {
+		return Alert{}, err
+	}
+	a := Alert{
+		ID:        pb.GetId(),
+		TenantID:  pb.GetTenantId(),
+		Kind:      pb.GetKind(),
+		Severity:  sev,
+		Title:     pb.GetTitle(),
+		CreatedAt: pb.GetCreatedAt().AsTime(),
+	}
+	if len(pb.GetDetailsJson()) > 0 {
+		if err := json.Unmarshal(pb.GetDetailsJson(), &a.Details); err != nil {
+			return a, fmt.Errorf("decode alert details: %w", err)
+		}
+	}
+	return a, nil
+}
+
+func (a Alert) MarshalProto() ([]byte, error) {
+	pb, err := a.ToProto()
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(pb)
+}
+
+func (a *Alert) UnmarshalProto(data []byte) error {
+	var pb eventsv1.Alert
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return err
+	}
+	out, err := AlertFromProto(&pb)
+	if err != nil {
+		return err
+	}
+	*a = out
+	return nil
+}
+
+// ToProto converts i to its protobuf form.
+func (i Incident) ToProto() *eventsv1.Incident {
+	return &eventsv1.Incident{
+		Id:             i.ID,
+		TenantId:       i.TenantID,
+		LogIds:         i.LogIDs,
+		Summary:        i.Summary,
+		Severity:       i.Severity,
+		Recommendation: i.Recommendation,
+		Status:         i.Status,
+		CreatedAt:      timestamppb.New(i.CreatedAt),
+		Assignee:       i.Assignee,
+		AlertId:        i.AlertID,
+		UpdatedAt:      timestamppb.New(i.UpdatedAt),
+	}
+}
+
+// IncidentFromProto converts a protobuf incident.
+func IncidentFromProto(pb *eventsv1.Incident) Incident {
+	return Incident{
+		ID:             pb.GetId(),
+		TenantID:       pb.GetTenantId(),
+		LogIDs:         pb.GetLogIds(),
+		Summary:        pb.GetSummary(),
+		Severity:       pb.GetSeverity(),
+		Recommendation: pb.GetRecommendation(),
+		Status:         pb.GetStatus(),
+		CreatedAt:      pb.GetCreatedAt().AsTime(),
+		Assignee:       pb.GetAssignee(),
+		AlertID:        pb.GetAlertId(),
+		UpdatedAt:      pb.GetUpdatedAt().AsTime(),
+	}
+}
+
+func (i Incident) MarshalProto() ([]byte, error) { return proto.Marshal(i.ToProto()) }
+
+func (i *Incident) UnmarshalProto(data []byte) error {
+	var pb eventsv1.Incident
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return err
+	}
+	*i = IncidentFromProto(&pb)
+	return nil
+}
+
+// ToProto converts m to its protobuf form. LogEntry, Alert, and Incident
+// payloads are typed; any other payload is carried as JSON.
+func (m Envelope) ToProto() (*eventsv1.Envelope, error) {
+	pb := &eventsv1.Envelope{Type: m.Type, Error: m.Error}
+	data := m.Data
+	switch d := data.(type) {
+	case *LogEntry:
+		data = *d
+	case *Alert:
+		data = *d
+	case *Incident:
+		data = *d
+	}
+	switch d := data.(type) {
+	case nil:
+	case LogEntry:
+		pb.Data = &eventsv1.Envelope_Log{Log: d.ToProto()}
+	case Alert:
+		alert, err := d.ToProto()
+		if err != nil {
+			return nil, err
+		}
+		pb.Data = &eventsv1.Envelope_Alert{Alert: alert}
+	case Incident:
+		pb.Data = &eventsv1.Envelope_Incident{Incident: d.ToProto()}
+	default:
+		encoded, err := json.Marshal(d)
+		if err != nil {
+			return nil, fmt.Errorf("encode %s payload: %w", m.Type, err)
+		}
+		pb.Data = &eventsv1.Envelope_Json{Json: encoded}
+	}
+	return pb, nil
+}
+
+// EnvelopeFromProto converts a protobuf envelope. Typed payloads decode to
+// LogEntry, Alert, or Incident; JSON payloads to json.RawMessage.
+func EnvelopeFromProto(pb *eventsv1.Envelope) (Envelope, error) {
+	m := Envelope{Type: pb.GetType(), Error: pb.GetError()}
+	var err error
+	switch d := pb.GetData().(type) {
+	case *eventsv1.Envelope_Log:
+		m.Data, err = LogEntryFromProto(d.Log)
+	case *eventsv1.Envelope_Alert:
+		m.Data, err = AlertFromProto(d.Alert)
+	case *eventsv1.Envelope_Incident:
+		m.Data = IncidentFromProto(d.Incident)
+	case *eventsv1.Envelope_Json:
+		m.Data = json.RawMessage(d.Json)
+	}
+	return m, err
+}
+
+func (m Envelope) MarshalProto() ([]byte, error) {
+	pb, err := m.ToProto()
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(pb)
+}
+
+func (m *Envelope) UnmarshalProto(data []byte) error {
+	var pb eventsv1.Envelope
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return err
+	}
+	out, err := EnvelopeFromProto(&pb)
+	if err != nil {
+		return err
+	}
+	*m = out
+	return nil
+}
@@ -0,0 +1,618 @@
+// Wire format of 1L0Gx stream events for protobuf consumers.
+//
+// Regenerate the Go code from backend/pkg/events with:
+//   buf generate
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: eventsv1/events.proto
+
+package eventsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type LogEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	TenantId      string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Source        string                 `protobuf:"bytes,4,opt,name=source,proto3" json:"source,omitempty"`
+	Severity      string                 `protobuf:"bytes,5,opt,name=severity,proto3" json:"severity,omitempty"` // INFO, WARNING, ALERT, CRITICAL
+	Message       string                 `protobuf:"bytes,6,opt,name=message,proto3" json:"message,omitempty"`
+	IpAddress     string                 `protobuf:"bytes,7,opt,name=ip_address,json=ipAddress,proto3" json:"ip_address,omitempty"`
+	Fields        map[string]string      `protobuf:"bytes,8,rep,name=fields,proto3" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Labels        map[string]string      `protobuf:"bytes,9,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	RiskScore     int32                  `protobuf:"varint,10,opt,name=risk_score,json=riskScore,proto3" json:"risk_score,omitempty"` // 0-100
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogEntry) Reset() {
+	*x = LogEntry{}
+	mi := &file_eventsv1_events_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogEntry) ProtoMessage() {}
+
+func (x *LogEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_eventsv1_events_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogEntry.ProtoReflect.Descriptor instead.
+func (*LogEntry) Descriptor() ([]byte, []int) {
+	return file_eventsv1_events_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LogEntry) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *LogEntry) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *LogEntry) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *LogEntry) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *LogEntry) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *LogEntry) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *LogEntry) GetIpAddress() string {
+	if x != nil {
+		return x.IpAddress
+	}
+	return ""
+}
+
+func (x *LogEntry) GetFields() map[string]string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *LogEntry) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *LogEntry) GetRiskScore() int32 {
+	if x != nil {
+		return x.RiskScore
+	}
+	return 0
+}
+
+type Alert struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	TenantId      string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	Kind          string                 `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"`
+	Severity      string                 `protobuf:"bytes,4,opt,name=severity,proto3" json:"severity,omitempty"`
+	Title         string                 `protobuf:"bytes,5,opt,name=title,proto3" json:"title,omitempty"`
+	DetailsJson   []byte                 `protobuf:"bytes,6,opt,name=details_json,json=detailsJson,proto3" json:"details_json,omitempty"` // free-form JSON object
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Alert) Reset() {
+	*x = Alert{}
+	mi := &file_eventsv1_events_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Alert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Alert) ProtoMessage() {}
+
+func (x *Alert) ProtoReflect() protoreflect.Message {
+	mi := &file_eventsv1_events_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Alert.ProtoReflect.Descriptor instead.
+func (*Alert) Descriptor() ([]byte, []int) {
+	return file_eventsv1_events_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Alert) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Alert) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *Alert) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Alert) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *Alert) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Alert) GetDetailsJson() []byte {
+	if x != nil {
+		return x.DetailsJson
+	}
+	return nil
+}
+
+func (x *Alert) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+type Incident struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	TenantId       string                 `protobuf:"bytes,2,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	LogIds         []int64                `protobuf:"varint,3,rep,packed,name=log_ids,json=logIds,proto3" json:"log_ids,omitempty"`
+	Summary        string                 `protobuf:"bytes,4,opt,name=summary,proto3" json:"summary,omitempty"`
+	Severity       string                 `protobuf:"bytes,5,opt,name=severity,proto3" json:"severity,omitempty"` // LOW, MEDIUM, HIGH, CRITICAL
+	Recommendation string                 `protobuf:"bytes,6,opt,name=recommendation,proto3" json:"recommendation,omitempty"`
+	Status         string                 `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"` // OPEN, INVESTIGATING, MITIGATED, CLOSED
+	CreatedAt      *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Assignee       string                 `protobuf:"bytes,9,opt,name=assignee,proto3" json:"assignee,omitempty"`
+	AlertId        int64                  `protobuf:"varint,10,opt,name=alert_id,json=alertId,proto3" json:"alert_id,omitempty"` // alert the incident was opened from, if any
+	UpdatedAt      *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Incident) Reset() {
+	*x = Incident{}
+	mi := &file_eventsv1_events_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Incident) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Incident) ProtoMessage() {}
+
+func (x *Incident) ProtoReflect() protoreflect.Message {
+	mi := &file_eventsv1_events_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Incident.ProtoReflect.Descriptor instead.
+func (*Incident) Descriptor() ([]byte, []int) {
+	return file_eventsv1_events_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Incident) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Incident) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *Incident) GetLogIds() []int64 {
+	if x != nil {
+		return x.LogIds
+	}
+	return nil
+}
+
+func (x *Incident) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *Incident) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *Incident) GetRecommendation() string {
+	if x != nil {
+		return x.Recommendation
+	}
+	return ""
+}
+
+func (x *Incident) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Incident) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Incident) GetAssignee() string {
+	if x != nil {
+		return x.Assignee
+	}
+	return ""
+}
+
+func (x *Incident) GetAlertId() int64 {
+	if x != nil {
+		return x.AlertId
+	}
+	return 0
+}
+
+func (x *Incident) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+// Envelope wraps every stream message other than a bare live log entry.
+type Envelope struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Type  string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Error string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	// Types that are valid to be assigned to Data:
+	//
+	//	*Envelope_Log
+	//	*Envelope_Alert
+	//	*Envelope_Incident
+	//	*Envelope_Json
+	Data          isEnvelope_Data `protobuf_oneof:"data"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Envelope) Reset() {
+	*x = Envelope{}
+	mi := &file_eventsv1_events_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Envelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Envelope) ProtoMessage() {}
+
+func (x *Envelope) ProtoReflect() protoreflect.Message {
+	mi := &file_eventsv1_events_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Envelope.ProtoReflect.Descriptor instead.
+func (*Envelope) Descriptor() ([]byte, []int) {
+	return file_eventsv1_events_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Envelope) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Envelope) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Envelope) GetData() isEnvelope_Data {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Envelope) GetLog() *LogEntry {
+	if x != nil {
+		if x, ok := x.Data.(*Envelope_Log); ok {
+			return x.Log
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetAlert() *Alert {
+	if x != nil {
+		if x, ok := x.Data.(*Envelope_Alert); ok {
+			return x.Alert
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetIncident() *Incident {
+	if x != nil {
+		if x, ok := x.Data.(*Envelope_Incident); ok {
+			return x.Incident
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetJson() []byte {
+	if x != nil {
+		if x, ok := x.Data.(*Envelope_Json); ok {
+			return x.Json
+		}
+	}
+	return nil
+}
+
+type isEnvelope_Data interface {
+	isEnvelope_Data()
+}
+
+type Envelope_Log struct {
+	Log *LogEntry `protobuf:"bytes,3,opt,name=log,proto3,oneof"`
+}
+
+type Envelope_Alert struct {
+	Alert *Alert `protobuf:"bytes,4,opt,name=alert,proto3,oneof"`
+}
+
+type Envelope_Incident struct {
+	Incident *Incident `protobuf:"bytes,5,opt,name=incident,proto3,oneof"`
+}
+
+type Envelope_Json struct {
+	Json []byte `protobuf:"bytes,6,opt,name=json,proto3,oneof"` // any other payload, JSON-encoded
+}
+
+func (*Envelope_Log) isEnvelope_Data() {}
+
+func (*Envelope_Alert) isEnvelope_Data() {}
+
+func (*Envelope_Incident) isEnvelope_Data() {}
+
+func (*Envelope_Json) isEnvelope_Data() {}
+
+var File_eventsv1_events_proto protoreflect.FileDescriptor
+
+const file_eventsv1_events_proto_rawDesc = "" +
+	"\n" +
+	"\x15eventsv1/events.proto\x12\x11onelogx.events.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf5\x03\n" +
+	"\bLogEntry\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\x128\n" +
+	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x16\n" +
+	"\x06source\x18\x04 \x01(\tR\x06source\x12\x1a\n" +
+	"\bseverity\x18\x05 \x01(\tR\bseverity\x12\x18\n" +
+	"\amessage\x18\x06 \x01(\tR\amessage\x12\x1d\n" +
+	"\n" +
+	"ip_address\x18\a \x01(\tR\tipAddress\x12?\n" +
+	"\x06fields\x18\b \x03(\v2'.onelogx.events.v1.LogEntry.FieldsEntryR\x06fields\x12?\n" +
+	"\x06labels\x18\t \x03(\v2'.onelogx.events.v1.LogEntry.LabelsEntryR\x06labels\x12\x1d\n" +
+	"\n" +
+	"risk_score\x18\n" +
+	" \x01(\x05R\triskScore\x1a9\n" +
+	"\vFieldsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xd8\x01\n" +
+	"\x05Alert\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\x12\x12\n" +
+	"\x04kind\x18\x03 \x01(\tR\x04kind\x12\x1a\n" +
+	"\bseverity\x18\x04 \x01(\tR\bseverity\x12\x14\n" +
+	"\x05title\x18\x05 \x01(\tR\x05title\x12!\n" +
+	"\fdetails_json\x18\x06 \x01(\fR\vdetailsJson\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"\xf3\x02\n" +
+	"\bIncident\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1b\n" +
+	"\ttenant_id\x18\x02 \x01(\tR\btenantId\x12\x17\n" +
+	"\alog_ids\x18\x03 \x03(\x03R\x06logIds\x12\x18\n" +
+	"\asummary\x18\x04 \x01(\tR\asummary\x12\x1a\n" +
+	"\bseverity\x18\x05 \x01(\tR\bseverity\x12&\n" +
+	"\x0erecommendation\x18\x06 \x01(\tR\x0erecommendation\x12\x16\n" +
+	"\x06status\x18\a \x01(\tR\x06status\x129\n" +
+	"\n" +
+	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12\x1a\n" +
+	"\bassignee\x18\t \x01(\tR\bassignee\x12\x19\n" +
+	"\balert_id\x18\n" +
+	" \x01(\x03R\aalertId\x129\n" +
+	"\n" +
+	"updated_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"\xf0\x01\n" +
+	"\bEnvelope\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12/\n" +
+	"\x03log\x18\x03 \x01(\v2\x1b.onelogx.events.v1.LogEntryH\x00R\x03log\x120\n" +
+	"\x05alert\x18\x04 \x01(\v2\x18.onelogx.events.v1.AlertH\x00R\x05alert\x129\n" +
+	"\bincident\x18\x05 \x01(\v2\x1b.onelogx.events.v1.IncidentH\x00R\bincident\x12\x14\n" +
+	"\x04json\x18\x06 \x01(\fH\x00R\x04jsonB\x06\n" +
+	"\x04dataB&Z$1logx/events/proto/eventsv1;eventsv1b\x06proto3"
+
+var (
+	file_eventsv1_events_proto_rawDescOnce sync.Once
+	file_eventsv1_events_proto_rawDescData []byte
+)
+
+func file_eventsv1_events_proto_rawDescGZIP() []byte {
+	file_eventsv1_events_proto_rawDescOnce.Do(func() {
+		file_eventsv1_events_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_eventsv1_events_proto_rawDesc), len(file_eventsv1_events_proto_rawDesc)))
+	})
+	return file_eventsv1_events_proto_rawDescData
+}
+
+var file_eventsv1_events_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_eventsv1_events_proto_goTypes = []any{
+	(*LogEntry)(nil),              // 0: onelogx.events.v1.LogEntry
+	(*Alert)(nil),                 // 1: onelogx.events.v1.Alert
+	(*Incident)(nil),              // 2: onelogx.events.v1.Incident
+	(*Envelope)(nil),              // 3: onelogx.events.v1.Envelope
+	nil,                           // 4: onelogx.events.v1.LogEntry.FieldsEntry
+	nil,                           // 5: onelogx.events.v1.LogEntry.LabelsEntry
+	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+}
+var file_eventsv1_events_proto_depIdxs = []int32{
+	6, // 0: onelogx.events.v1.LogEntry.timestamp:type_name -> google.protobuf.Timestamp
+	4, // 1: onelogx.events.v1.LogEntry.fields:type_name -> onelogx.events.v1.LogEntry.FieldsEntry
+	5, // 2: onelogx.events.v1.LogEntry.labels:type_name -> onelogx.events.v1.LogEntry.LabelsEntry
+	6, // 3: onelogx.events.v1.Alert.created_at:type_name -> google.protobuf.Timestamp
+	6, // 4: onelogx.events.v1.Incident.created_at:type_name -> google.protobuf.Timestamp
+	6, // 5: onelogx.events.v1.Incident.updated_at:type_name -> google.protobuf.Timestamp
+	0, // 6: onelogx.events.v1.Envelope.log:type_name -> onelogx.events.v1.LogEntry
+	1, // 7: onelogx.events.v1.Envelope.alert:type_name -> onelogx.events.v1.Alert
+	2, // 8: onelogx.events.v1.Envelope.incident:type_name -> onelogx.events.v1.Incident
+	9, // [9:9] is the sub-list for method output_type
+	9, // [9:9] is the sub-list for method input_type
+	9, // [9:9] is the sub-list for extension type_name
+	9, // [9:9] is the sub-list for extension extendee
+	0, // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_eventsv1_events_proto_init() }
+func file_eventsv1_events_proto_init() {
+	if File_eventsv1_events_proto != nil {
+		return
+	}
+	file_eventsv1_events_proto_msgTypes[3].OneofWrappers = []any{
+		(*Envelope_Log)(nil),
+		(*Envelope_Alert)(nil),
+		(*Envelope_Incident)(nil),
+		(*Envelope_Json)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_eventsv1_events_proto_rawDesc), len(file_eventsv1_events_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_eventsv1_events_proto_goTypes,
+		DependencyIndexes: file_eventsv1_events_proto_depIdxs,
+		MessageInfos:      file_eventsv1_events_proto_msgTypes,
+	}.Build()
+	File_eventsv1_events_proto = out.File
+	file_eventsv1_events_proto_goTypes = nil
+	file_eventsv1_events_proto_depIdxs = nil
+}
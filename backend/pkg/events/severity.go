@@ -0,0 +1,176 @@
+package events
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Severity is the canonical, ordered severity level of a log entry.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityAlert
+	SeverityCritical
+)
+
+// AllSeverities lists the canonical levels from lowest to highest.
+var AllSeverities = []Severity{SeverityInfo, SeverityWarning, SeverityAlert, SeverityCritical}
+
+var severityNames = map[Severity]string{
+	SeverityInfo:     "INFO",
+	SeverityWarning:  "WARNING",
+	SeverityAlert:    "ALERT",
+	SeverityCritical: "CRITICAL",
+}
+
+// severityAliases maps lower-cased external spellings onto canonical levels.
+var severityAliases = map[string]Severity{
+	"info": SeverityInfo, "informational": SeverityInfo, "notice": SeverityInfo,
+	"debug": SeverityInfo, "trace": SeverityInfo, "low": SeverityInfo,
+	"warning": SeverityWarning, "warn": SeverityWarning, "medium": SeverityWarning,
+	"alert": SeverityAlert, "error": SeverityAlert, "err": SeverityAlert, "high": SeverityAlert,
+	"critical": SeverityCritical, "crit": SeverityCritical, "fatal": SeverityCritical,
+	"emerg": SeverityCritical, "emergency": SeverityCritical, "panic": SeverityCritical,
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Valid reports whether s is one of the canonical levels.
+func (s Severity) Valid() bool {
+	_, ok := severityNames[s]
+	return ok
+}
+
+// ParseSeverity normalizes an external severity into the canonical set.
+// It accepts canonical names, common aliases ("warn", "err", "fatal"),
+// syslog severities (0-7) and PRI values ("<34>"), and bunyan/pino style
+// numeric levels (10-60).
+func ParseSeverity(s string) (Severity, error) {
+	raw := strings.TrimSpace(s)
+	key := strings.ToLower(raw)
+	if sev, ok := severityAliases[key]; ok {
+		return sev, nil
+	}
+
+	if strings.HasPrefix(key, "<") && strings.HasSuffix(key, ">") {
+		pri, err := strconv.Atoi(key[1 : len(key)-1])
+		if err != nil || pri < 0 || pri > 191 {
+			return SeverityInfo, fmt.Errorf("invalid syslog priority %q", raw)
+		}
+		return fromSyslog(pri % 8), nil
+	}
+
+	if n, err := strconv.Atoi(key); err == nil {
+		switch {
+		case n >= 0 && n <= 7:
+			return fromSyslog(n), nil
+		case n >= 10 && n <= 60 && n%10 == 0:
+			return fromLevelNumber(n), nil
+		}
+		return SeverityInfo, fmt.Errorf("unrecognized numeric severity %d", n)
+	}
+	return SeverityInfo, fmt.Errorf("unrecognized severity %q", raw)
+}
+
+// NormalizeSeverity is ParseSeverity with unknown values defaulting to INFO.
+func NormalizeSeverity(s string) Severity {
+	sev, _ := ParseSeverity(s)
+	return sev
+}
+
+// fromSyslog maps RFC 5424 severities (0 = emergency ... 7 = debug).
+func fromSyslog(n int) Severity {
+	switch {
+	case n <= 2:
+		return SeverityCritical
+	case n == 3:
+		return SeverityAlert
+	case n == 4:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+// fromLevelNumber maps bunyan/pino levels (10 = trace ... 60 = fatal).
+func fromLevelNumber(n int) Severity {
+	switch {
+	case n >= 60:
+		return SeverityCritical
+	case n == 50:
+		return SeverityAlert
+	case n == 40:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON accepts any level ParseSeverity does, as a string or a
+// number. Like a missing severity, null leaves s unchanged.
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		return nil
+	}
+	sev, err := ParseSeverity(fmt.Sprint(raw))
+	if err != nil {
+		return err
+	}
+	*s = sev
+	return nil
+}
+
+func (s Severity) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+func (s *Severity) UnmarshalYAML(unmarshal func(any) error) error {
+	var raw string
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	sev, err := ParseSeverity(raw)
+	if err != nil {
+		return err
+	}
+	*s = sev
+	return nil
+}
+
+// Value stores the canonical name in the severity column.
+func (s Severity) Value() (driver.Value, error) {
+	return s.String(), nil
+}
+
+// Scan reads a severity column, normalizing legacy free-string values.
+func (s *Severity) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*s = SeverityInfo
+	case []byte:
+		*s = NormalizeSeverity(string(v))
+	case string:
+		*s = NormalizeSeverity(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Severity", src)
+	}
+	return nil
+}
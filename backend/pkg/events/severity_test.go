@@ -0,0 +1,102 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Severity
+		wantErr bool
+	}{
+		{in: "INFO", want: SeverityInfo},
+		{in: " warning ", want: SeverityWarning},
+		{in: "Warn", want: SeverityWarning},
+		{in: "err", want: SeverityAlert},
+		{in: "fatal", want: SeverityCritical},
+		{in: "0", want: SeverityCritical},
+		{in: "3", want: SeverityAlert},
+		{in: "4", want: SeverityWarning},
+		{in: "7", want: SeverityInfo},
+		{in: "<34>", want: SeverityCritical},
+		{in: "<12>", want: SeverityWarning},
+		{in: "<191>", want: SeverityInfo},
+		{in: "30", want: SeverityInfo},
+		{in: "40", want: SeverityWarning},
+		{in: "50", want: SeverityAlert},
+		{in: "60", want: SeverityCritical},
+		{in: "<192>", wantErr: true},
+		{in: "<x>", wantErr: true},
+		{in: "8", wantErr: true},
+		{in: "45", wantErr: true},
+		{in: "loud", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSeverity(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSeverity(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if got != SeverityInfo {
+					t.Errorf("ParseSeverity(%q) = %v on error, want INFO", tt.in, got)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseSeverity(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeverityJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Severity
+		wantErr bool
+	}{
+		{in: `"CRITICAL"`, want: SeverityCritical},
+		{in: `"medium"`, want: SeverityWarning},
+		{in: `50`, want: SeverityAlert},
+		{in: `null`, want: SeverityInfo},
+		{in: `"nope"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var got Severity
+			err := json.Unmarshal([]byte(tt.in), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal(%s) = %v, want %v", tt.in, got, tt.want)
+			}
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("Marshal(%v): %v", got, err)
+			}
+			if want := `"` + tt.want.String() + `"`; string(data) != want {
+				t.Errorf("Marshal(%v) = %s, want %s", got, data, want)
+			}
+		})
+	}
+}
+
+func TestSeverityJSONNullKeepsValue(t *testing.T) {
+	entry := struct {
+		Severity Severity `json:"severity"`
+	}{Severity: SeverityCritical}
+	if err := json.Unmarshal([]byte(`{"severity":null}`), &entry); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if entry.Severity != SeverityCritical {
+		t.Errorf("severity = %v after null, want it unchanged (CRITICAL)", entry.Severity)
+	}
+}